@@ -18,8 +18,10 @@
 package pulsar
 
 import (
+	"sync"
 	"time"
 
+	"github.com/apache/pulsar-client-go/pulsar/internal"
 	"github.com/bits-and-blooms/bitset"
 )
 
@@ -34,20 +36,49 @@ type ackGroupingTracker interface {
 
 	flushAndClean()
 
+	// requestFlush asks for the cached acks to be flushed, without waiting for it
+	requestFlush()
+
 	close()
+
+	// pendingAcks returns the number of acknowledgments waiting to be sent to the broker
+	pendingAcks() int
 }
 
-type ackFlushType int
+// ackFlushTrigger is what triggered a flush of the acknowledgments, to label the metrics of the flushes
+type ackFlushTrigger string
 
 const (
-	flushOnly ackFlushType = iota
-	flushAndClean
-	flushAndClose
+	// ackFlushSize flushes the individual acknowledgments once MaxSize of them are cached
+	ackFlushSize ackFlushTrigger = "size"
+	// ackFlushTime flushes the acknowledgments every MaxTime
+	ackFlushTime ackFlushTrigger = "time"
+	// ackFlushRequest flushes the acknowledgments on request of the consumer, e.g. when it is closed
+	ackFlushRequest ackFlushTrigger = "request"
+	// ackFlushImmediate sends the cumulative acknowledgments right away when MaxTime is not positive
+	ackFlushImmediate ackFlushTrigger = "immediate"
 )
 
+// cumulativeAckMaxTime returns the interval of the flushes of the cumulative acknowledgments, not positive to send
+// them right away
+func (o *AckGroupingOptions) cumulativeAckMaxTime() time.Duration {
+	if o.CumulativeAckMaxTime == 0 {
+		return o.MaxTime
+	}
+	if o.CumulativeAckMaxTime < 0 {
+		return 0
+	}
+	return o.CumulativeAckMaxTime
+}
+
+// newAckGroupingTracker returns a tracker sending the grouped individual acknowledgments with ackList, which must
+// not retain the ids, or one by one with ackIndividual if ackList is nil. The flushes are exported to the metrics,
+// unless they are nil.
 func newAckGroupingTracker(options *AckGroupingOptions,
 	ackIndividual func(id MessageID),
-	ackCumulative func(id MessageID)) ackGroupingTracker {
+	ackCumulative func(id MessageID),
+	ackList func(ids []MessageID),
+	metrics *internal.LeveledMetrics) ackGroupingTracker {
 	if options == nil {
 		options = &AckGroupingOptions{
 			MaxSize: 1000,
@@ -62,66 +93,37 @@ func newAckGroupingTracker(options *AckGroupingOptions,
 		}
 	}
 
-	c := &cachedAcks{
-		singleAcks:        make([]MessageID, options.MaxSize),
-		pendingAcks:       make(map[int64]*bitset.BitSet),
-		lastCumulativeAck: EarliestMessageID(),
-		ackIndividual:     ackIndividual,
-		ackCumulative:     ackCumulative,
-		ackList: func(ids []MessageID) {
-			// TODO: support ack a list of MessageIDs
+	if ackList == nil {
+		ackList = func(ids []MessageID) {
 			for _, id := range ids {
 				ackIndividual(id)
 			}
+		}
+	}
+	t := &timedAckGroupingTracker{
+		acks: &cachedAcks{
+			singleAcks:        make([]MessageID, options.MaxSize),
+			pendingAcks:       make(map[int64]*bitset.BitSet),
+			lastCumulativeAck: EarliestMessageID(),
 		},
+		maxTime:        options.MaxTime,
+		ackCumulative:  ackCumulative,
+		ackList:        ackList,
+		metrics:        metrics,
+		requestFlushCh: make(chan struct{}, 1),
+		closeCh:        make(chan struct{}),
 	}
-
-	timeout := time.NewTicker(time.Hour)
-	if options.MaxTime > 0 {
-		timeout = time.NewTicker(options.MaxTime)
+	t.timeout = time.NewTicker(time.Hour)
+	if t.maxTime > 0 {
+		t.timeout.Reset(t.maxTime)
 	} else {
-		timeout.Stop()
+		t.timeout.Stop()
 	}
-	t := &timedAckGroupingTracker{
-		ackIndividualCh:   make(chan MessageID),
-		ackCumulativeCh:   make(chan MessageID),
-		duplicateIDCh:     make(chan MessageID),
-		duplicateResultCh: make(chan bool),
-		flushCh:           make(chan ackFlushType),
-		waitFlushCh:       make(chan bool),
-	}
-	go func() {
-		for {
-			select {
-			case id := <-t.ackIndividualCh:
-				if c.addAndCheckIfFull(id) {
-					c.flushIndividualAcks()
-					if options.MaxTime > 0 {
-						timeout.Reset(options.MaxTime)
-					}
-				}
-			case id := <-t.ackCumulativeCh:
-				c.tryUpdateLastCumulativeAck(id)
-				if options.MaxTime <= 0 {
-					c.flushCumulativeAck()
-				}
-			case id := <-t.duplicateIDCh:
-				t.duplicateResultCh <- c.isDuplicate(id)
-			case <-timeout.C:
-				c.flush()
-			case ackFlushType := <-t.flushCh:
-				timeout.Stop()
-				c.flush()
-				if ackFlushType == flushAndClean {
-					c.clean()
-				}
-				t.waitFlushCh <- true
-				if ackFlushType == flushAndClose {
-					return
-				}
-			}
-		}
-	}()
+	t.cumulativeMaxTime = options.cumulativeAckMaxTime()
+	if t.cumulativeMaxTime > 0 && t.cumulativeMaxTime != t.maxTime {
+		t.cumulativeTimeout = time.NewTicker(t.cumulativeMaxTime)
+	}
+	go t.run()
 	return t
 }
 
@@ -148,9 +150,16 @@ func (i *immediateAckGroupingTracker) flush() {
 func (i *immediateAckGroupingTracker) flushAndClean() {
 }
 
+func (i *immediateAckGroupingTracker) requestFlush() {
+}
+
 func (i *immediateAckGroupingTracker) close() {
 }
 
+func (i *immediateAckGroupingTracker) pendingAcks() int {
+	return 0
+}
+
 type cachedAcks struct {
 	singleAcks []MessageID
 	index      int
@@ -165,10 +174,8 @@ type cachedAcks struct {
 
 	lastCumulativeAck     MessageID
 	cumulativeAckRequired bool
-
-	ackIndividual func(id MessageID)
-	ackCumulative func(id MessageID)
-	ackList       func(ids []MessageID)
+	// cumulativeAckSince is when the cumulative acknowledgment to send was first required
+	cumulativeAckSince time.Time
 }
 
 func (t *cachedAcks) addAndCheckIfFull(id MessageID) bool {
@@ -196,7 +203,10 @@ func (t *cachedAcks) addAndCheckIfFull(id MessageID) bool {
 func (t *cachedAcks) tryUpdateLastCumulativeAck(id MessageID) {
 	if messageIDCompare(t.lastCumulativeAck, id) < 0 {
 		t.lastCumulativeAck = id
-		t.cumulativeAckRequired = true
+		if !t.cumulativeAckRequired {
+			t.cumulativeAckRequired = true
+			t.cumulativeAckSince = time.Now()
+		}
 	}
 }
 
@@ -216,39 +226,38 @@ func (t *cachedAcks) isDuplicate(id MessageID) bool {
 	return !ackSet.Test(uint(id.BatchIdx()))
 }
 
-func (t *cachedAcks) flushIndividualAcks() {
-	if t.index > 0 {
-		t.ackList(t.singleAcks[0:t.index])
-		for _, id := range t.singleAcks[0:t.index] {
-			key := messageIDHash(id)
-			ackSet, found := t.pendingAcks[key]
-			if !found {
-				continue
-			}
-			if ackSet == nil {
-				delete(t.pendingAcks, key)
-			} else {
-				ackSet.Clear(uint(id.BatchIdx()))
-				if ackSet.None() { // all messages have been acknowledged
-					delete(t.pendingAcks, key)
-				}
-			}
-			delete(t.pendingAcks, messageIDHash(id))
-		}
-		t.index = 0
+// takeIndividualAcks returns the cached individual acknowledgments to send, they are not pending anymore
+func (t *cachedAcks) takeIndividualAcks() []MessageID {
+	if t.index == 0 {
+		return nil
+	}
+	ids := make([]MessageID, t.index)
+	copy(ids, t.singleAcks[0:t.index])
+	for _, id := range ids {
+		delete(t.pendingAcks, messageIDHash(id))
 	}
+	for i := 0; i < t.index; i++ {
+		t.singleAcks[i] = nil
+	}
+	t.index = 0
+	return ids
 }
 
-func (t *cachedAcks) flushCumulativeAck() {
+func (t *cachedAcks) unflushed() int {
 	if t.cumulativeAckRequired {
-		t.ackCumulative(t.lastCumulativeAck)
-		t.cumulativeAckRequired = false
+		return t.index + 1
 	}
+	return t.index
 }
 
-func (t *cachedAcks) flush() {
-	t.flushIndividualAcks()
-	t.flushCumulativeAck()
+// takeCumulativeAck returns the cumulative acknowledgment to send, nil if there is none, and since when it is
+// required
+func (t *cachedAcks) takeCumulativeAck() (MessageID, time.Time) {
+	if !t.cumulativeAckRequired {
+		return nil, time.Time{}
+	}
+	t.cumulativeAckRequired = false
+	return t.lastCumulativeAck, t.cumulativeAckSince
 }
 
 func (t *cachedAcks) clean() {
@@ -260,39 +269,195 @@ func (t *cachedAcks) clean() {
 	t.cumulativeAckRequired = false
 }
 
+// timedAckGroupingTracker caches the acknowledgments until MaxSize individual acknowledgments are cached or
+// MaxTime elapsed. The cache is protected by a mutex rather than owned by a goroutine, so that the receivers
+// acknowledging concurrently and the connection checking the duplicates only contend for the short updates of
+// the cache, and the acknowledgments are sent outside of the lock.
 type timedAckGroupingTracker struct {
-	ackIndividualCh   chan MessageID
-	ackCumulativeCh   chan MessageID
-	duplicateIDCh     chan MessageID
-	duplicateResultCh chan bool
-	flushCh           chan ackFlushType
-	waitFlushCh       chan bool
+	sync.Mutex
+	acks *cachedAcks
+
+	// flushLock serializes the flushes, so that a flush returns once the acknowledgments cached before it are sent,
+	// except the ones taken by an add filling the cache which sends them by itself
+	flushLock sync.Mutex
+
+	maxTime       time.Duration
+	timeout       *time.Ticker
+	ackCumulative func(id MessageID)
+	ackList       func(ids []MessageID)
+	metrics       *internal.LeveledMetrics
+
+	// cumulativeMaxTime is the interval of the flushes of the cumulative acknowledgments, which have their own
+	// cumulativeTimeout when it differs from maxTime, not positive to send them right away
+	cumulativeMaxTime time.Duration
+	cumulativeTimeout *time.Ticker
+
+	requestFlushCh chan struct{}
+	closeCh        chan struct{}
+	closeOnce      sync.Once
+}
+
+// run flushes the acknowledgments every MaxTime and on request, until the tracker is closed
+func (t *timedAckGroupingTracker) run() {
+	// the cumulative acknowledgments are flushed with the individual ones unless they have their own interval
+	var cumulativeTimeoutCh <-chan time.Time
+	if t.cumulativeTimeout != nil {
+		cumulativeTimeoutCh = t.cumulativeTimeout.C
+	}
+	for {
+		select {
+		case <-t.timeout.C:
+			if t.cumulativeTimeout != nil {
+				t.flushIndividualAcks(ackFlushTime)
+			} else {
+				t.flushWith(ackFlushTime)
+			}
+		case <-cumulativeTimeoutCh:
+			t.flushCumulativeAck(ackFlushTime)
+		case <-t.requestFlushCh:
+			t.flushWith(ackFlushRequest)
+			t.resetTimeout()
+		case <-t.closeCh:
+			t.timeout.Stop()
+			if t.cumulativeTimeout != nil {
+				t.cumulativeTimeout.Stop()
+			}
+			return
+		}
+	}
+}
+
+func (t *timedAckGroupingTracker) resetTimeout() {
+	if t.maxTime > 0 {
+		t.timeout.Reset(t.maxTime)
+	}
 }
 
 func (t *timedAckGroupingTracker) add(id MessageID) {
-	t.ackIndividualCh <- id
+	t.Lock()
+	var ids []MessageID
+	if t.acks.addAndCheckIfFull(id) {
+		// take the acks while holding the lock, the concurrent adds must not overflow the cache
+		ids = t.acks.takeIndividualAcks()
+	}
+	t.updatePendingAcks()
+	t.Unlock()
+	if len(ids) > 0 {
+		t.send(ackFlushSize, ids, nil, time.Time{})
+		t.resetTimeout()
+	}
 }
 
 func (t *timedAckGroupingTracker) addCumulative(id MessageID) {
-	t.ackCumulativeCh <- id
+	t.Lock()
+	t.acks.tryUpdateLastCumulativeAck(id)
+	t.updatePendingAcks()
+	t.Unlock()
+	if t.cumulativeMaxTime <= 0 {
+		t.flushCumulativeAck(ackFlushImmediate)
+	}
 }
 
 func (t *timedAckGroupingTracker) isDuplicate(id MessageID) bool {
-	t.duplicateIDCh <- id
-	return <-t.duplicateResultCh
+	t.Lock()
+	defer t.Unlock()
+	return t.acks.isDuplicate(id)
+}
+
+// updatePendingAcks exports the number of acknowledgments cached, with the lock held
+func (t *timedAckGroupingTracker) updatePendingAcks() {
+	if t.metrics != nil {
+		t.metrics.AckGroupingPendingAcks.Set(float64(t.acks.unflushed()))
+	}
+}
+
+// send sends the acknowledgments taken from the cache and exports the flush, the cumulative acknowledgment being
+// required since the given time
+func (t *timedAckGroupingTracker) send(trigger ackFlushTrigger, ids []MessageID, cumulativeID MessageID,
+	since time.Time) {
+	if len(ids) == 0 && cumulativeID == nil {
+		return
+	}
+	start := time.Now()
+	if len(ids) > 0 {
+		t.ackList(ids)
+	}
+	if cumulativeID != nil {
+		t.ackCumulative(cumulativeID)
+	}
+	if t.metrics == nil {
+		return
+	}
+	t.metrics.AckGroupingFlushes.WithLabelValues(string(trigger)).Inc()
+	t.metrics.AckGroupingFlushLatency.Observe(time.Since(start).Seconds())
+	if cumulativeID != nil {
+		t.metrics.AckGroupingCumulativeAckLag.Set(start.Sub(since).Seconds())
+	}
 }
 
 func (t *timedAckGroupingTracker) flush() {
-	t.flushCh <- flushOnly
-	<-t.waitFlushCh
+	t.flushWith(ackFlushRequest)
+}
+
+// flushWith sends all the cached acknowledgments, the flushes being serialized by the flushLock
+func (t *timedAckGroupingTracker) flushWith(trigger ackFlushTrigger) {
+	t.flushLock.Lock()
+	defer t.flushLock.Unlock()
+	t.Lock()
+	ids := t.acks.takeIndividualAcks()
+	cumulativeID, since := t.acks.takeCumulativeAck()
+	t.updatePendingAcks()
+	t.Unlock()
+	t.send(trigger, ids, cumulativeID, since)
+}
+
+// flushIndividualAcks sends the cached individual acknowledgments only
+func (t *timedAckGroupingTracker) flushIndividualAcks(trigger ackFlushTrigger) {
+	t.flushLock.Lock()
+	defer t.flushLock.Unlock()
+	t.Lock()
+	ids := t.acks.takeIndividualAcks()
+	t.updatePendingAcks()
+	t.Unlock()
+	t.send(trigger, ids, nil, time.Time{})
+}
+
+// flushCumulativeAck sends the cumulative acknowledgment only
+func (t *timedAckGroupingTracker) flushCumulativeAck(trigger ackFlushTrigger) {
+	t.flushLock.Lock()
+	defer t.flushLock.Unlock()
+	t.Lock()
+	cumulativeID, since := t.acks.takeCumulativeAck()
+	t.updatePendingAcks()
+	t.Unlock()
+	t.send(trigger, nil, cumulativeID, since)
 }
 
 func (t *timedAckGroupingTracker) flushAndClean() {
-	t.flushCh <- flushAndClean
-	<-t.waitFlushCh
+	t.flush()
+	t.Lock()
+	defer t.Unlock()
+	t.acks.clean()
+	t.updatePendingAcks()
+}
+
+func (t *timedAckGroupingTracker) requestFlush() {
+	select {
+	case t.requestFlushCh <- struct{}{}:
+	default:
+		// a flush is already requested
+	}
+}
+
+func (t *timedAckGroupingTracker) pendingAcks() int {
+	t.Lock()
+	defer t.Unlock()
+	return t.acks.unflushed()
 }
 
 func (t *timedAckGroupingTracker) close() {
-	t.flushCh <- flushAndClose
-	<-t.waitFlushCh
+	t.flush()
+	t.closeOnce.Do(func() {
+		close(t.closeCh)
+	})
 }
@@ -0,0 +1,58 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+//go:build bench
+// +build bench
+
+package pulsar
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newBenchAckGroupingTracker() ackGroupingTracker {
+	return newAckGroupingTracker(&AckGroupingOptions{MaxSize: 1000, MaxTime: 100 * time.Millisecond},
+		func(id MessageID) {}, func(id MessageID) {}, func(ids []MessageID) {}, nil)
+}
+
+func BenchmarkAckGroupingTrackerAdd(b *testing.B) {
+	tracker := newBenchAckGroupingTracker()
+	defer tracker.close()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tracker.add(&messageID{ledgerID: 1, entryID: int64(i), batchIdx: -1})
+	}
+}
+
+// BenchmarkAckGroupingTrackerParallel acknowledges from concurrent receivers while the messages received are
+// checked for duplicates
+func BenchmarkAckGroupingTrackerParallel(b *testing.B) {
+	tracker := newBenchAckGroupingTracker()
+	defer tracker.close()
+	var entryID int64
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			id := &messageID{ledgerID: 1, entryID: atomic.AddInt64(&entryID, 1), batchIdx: -1}
+			if !tracker.isDuplicate(id) {
+				tracker.add(id)
+			}
+		}
+	})
+}
@@ -24,6 +24,9 @@ import (
 	"testing"
 	"time"
 
+	"github.com/apache/pulsar-client-go/pulsar/internal"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -45,7 +48,7 @@ func TestNoCacheTracker(t *testing.T) {
 				ledgerID1 := int64(-1)
 				tracker := newAckGroupingTracker(&option,
 					func(id MessageID) { ledgerID0 = id.LedgerID() },
-					func(id MessageID) { ledgerID1 = id.LedgerID() })
+					func(id MessageID) { ledgerID1 = id.LedgerID() }, nil, nil)
 
 				tracker.add(&messageID{ledgerID: 1})
 				assert.Equal(t, atomic.LoadInt64(&ledgerID0), int64(1))
@@ -89,7 +92,7 @@ func (a *mockAcker) reset() {
 func TestCachedTracker(t *testing.T) {
 	var acker mockAcker
 	tracker := newAckGroupingTracker(&AckGroupingOptions{MaxSize: 3, MaxTime: 0},
-		func(id MessageID) { acker.ack(id) }, func(id MessageID) { acker.ackCumulative(id) })
+		func(id MessageID) { acker.ack(id) }, func(id MessageID) { acker.ackCumulative(id) }, nil, nil)
 
 	tracker.add(&messageID{ledgerID: 1})
 	tracker.add(&messageID{ledgerID: 2})
@@ -126,7 +129,7 @@ func TestCachedTracker(t *testing.T) {
 func TestTimedTrackerIndividualAck(t *testing.T) {
 	var acker mockAcker
 	// MaxSize: 1000, MaxTime: 100ms
-	tracker := newAckGroupingTracker(nil, func(id MessageID) { acker.ack(id) }, nil)
+	tracker := newAckGroupingTracker(nil, func(id MessageID) { acker.ack(id) }, nil, nil, nil)
 
 	expected := make([]int64, 0)
 	for i := 0; i < 999; i++ {
@@ -161,7 +164,7 @@ func TestTimedTrackerIndividualAck(t *testing.T) {
 func TestTimedTrackerCumulativeAck(t *testing.T) {
 	var acker mockAcker
 	// MaxTime is 100ms
-	tracker := newAckGroupingTracker(nil, nil, func(id MessageID) { acker.ackCumulative(id) })
+	tracker := newAckGroupingTracker(nil, nil, func(id MessageID) { acker.ackCumulative(id) }, nil, nil)
 
 	// case 1: flush because of the timeout
 	tracker.addCumulative(&messageID{ledgerID: 1})
@@ -182,7 +185,7 @@ func TestTimedTrackerCumulativeAck(t *testing.T) {
 }
 
 func TestTimedTrackerIsDuplicate(t *testing.T) {
-	tracker := newAckGroupingTracker(nil, func(id MessageID) {}, func(id MessageID) {})
+	tracker := newAckGroupingTracker(nil, func(id MessageID) {}, func(id MessageID) {}, nil, nil)
 
 	tracker.add(&messageID{batchIdx: 0, batchSize: 3})
 	tracker.add(&messageID{batchIdx: 2, batchSize: 3})
@@ -195,3 +198,99 @@ func TestTimedTrackerIsDuplicate(t *testing.T) {
 	assert.False(t, tracker.isDuplicate(&messageID{batchIdx: 1, batchSize: 3}))
 	assert.False(t, tracker.isDuplicate(&messageID{batchIdx: 2, batchSize: 3}))
 }
+
+func TestTimedTrackerRequestFlush(t *testing.T) {
+	var acker mockAcker
+	tracker := newAckGroupingTracker(&AckGroupingOptions{MaxSize: 1000, MaxTime: time.Hour},
+		func(id MessageID) { acker.ack(id) }, func(id MessageID) { acker.ackCumulative(id) }, nil, nil)
+	defer tracker.close()
+
+	tracker.add(&messageID{ledgerID: 1})
+	tracker.addCumulative(&messageID{ledgerID: 2})
+	assert.Equal(t, 0, len(acker.getLedgerIDs()))
+
+	// the requests coalesce while a flush is pending
+	tracker.requestFlush()
+	tracker.requestFlush()
+	assert.Eventually(t, func() bool { return tracker.pendingAcks() == 0 }, time.Second, time.Millisecond)
+	assert.Equal(t, []int64{1}, acker.getLedgerIDs())
+	assert.Equal(t, int64(2), acker.getCumulativeLedgerID())
+}
+
+func TestCachedTrackerAckList(t *testing.T) {
+	var mu sync.Mutex
+	var lists [][]int64
+	tracker := newAckGroupingTracker(&AckGroupingOptions{MaxSize: 3, MaxTime: 0},
+		func(id MessageID) { t.Error("unexpected individual ack") }, func(id MessageID) {},
+		func(ids []MessageID) {
+			ledgerIDs := make([]int64, 0, len(ids))
+			for _, id := range ids {
+				ledgerIDs = append(ledgerIDs, id.LedgerID())
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			lists = append(lists, ledgerIDs)
+		}, nil)
+	defer tracker.close()
+
+	for i := 1; i <= 4; i++ {
+		tracker.add(&messageID{ledgerID: int64(i)})
+	}
+	tracker.flush()
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, [][]int64{{1, 2, 3}, {4}}, lists)
+}
+
+func TestTimedTrackerMetrics(t *testing.T) {
+	metrics := internal.NewMetricsProvider(4, map[string]string{}, prometheus.NewRegistry()).
+		GetLeveledConsumerMetrics("topic", "sub")
+	tracker := newAckGroupingTracker(&AckGroupingOptions{MaxSize: 2, MaxTime: time.Hour},
+		func(id MessageID) {}, func(id MessageID) {}, nil, metrics)
+	defer tracker.close()
+
+	tracker.add(&messageID{ledgerID: 1})
+	tracker.addCumulative(&messageID{ledgerID: 1})
+	assert.Equal(t, 2.0, testutil.ToFloat64(metrics.AckGroupingPendingAcks))
+
+	tracker.add(&messageID{ledgerID: 2})
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.AckGroupingPendingAcks))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.AckGroupingFlushes.WithLabelValues("size")))
+
+	tracker.flush()
+	assert.Equal(t, 0.0, testutil.ToFloat64(metrics.AckGroupingPendingAcks))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.AckGroupingFlushes.WithLabelValues("request")))
+	assert.Greater(t, testutil.ToFloat64(metrics.AckGroupingCumulativeAckLag), 0.0)
+
+	// the flushes without acknowledgments to send are not counted
+	tracker.flush()
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.AckGroupingFlushes.WithLabelValues("request")))
+}
+
+func TestTimedTrackerCumulativeAckMaxTime(t *testing.T) {
+	assert.Equal(t, time.Second, (&AckGroupingOptions{MaxTime: time.Second}).cumulativeAckMaxTime())
+	assert.Equal(t, time.Duration(0),
+		(&AckGroupingOptions{MaxTime: time.Second, CumulativeAckMaxTime: -1}).cumulativeAckMaxTime())
+
+	var acker mockAcker
+	tracker := newAckGroupingTracker(
+		&AckGroupingOptions{MaxSize: 1000, MaxTime: time.Hour, CumulativeAckMaxTime: 10 * time.Millisecond},
+		func(id MessageID) { acker.ack(id) }, func(id MessageID) { acker.ackCumulative(id) }, nil, nil)
+	defer tracker.close()
+
+	// the cumulative acknowledgment is flushed on its own interval, the individual one waits for MaxTime
+	tracker.add(&messageID{ledgerID: 1})
+	tracker.addCumulative(&messageID{ledgerID: 2})
+	assert.Eventually(t, func() bool { return acker.getCumulativeLedgerID() == 2 }, time.Second, time.Millisecond)
+	assert.Empty(t, acker.getLedgerIDs())
+	assert.Equal(t, 1, tracker.pendingAcks())
+
+	// sent right away
+	var immediateAcker mockAcker
+	immediate := newAckGroupingTracker(&AckGroupingOptions{MaxSize: 1000, MaxTime: time.Hour, CumulativeAckMaxTime: -1},
+		nil, func(id MessageID) { immediateAcker.ackCumulative(id) }, nil, nil)
+	defer immediate.close()
+	immediate.addCumulative(&messageID{ledgerID: 3})
+	assert.Equal(t, int64(3), immediateAcker.getCumulativeLedgerID())
+}
@@ -0,0 +1,220 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsar
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/apache/pulsar-client-go/pulsar/log"
+)
+
+const defaultAckHoleWarningThreshold = 1000
+
+// AckHoleTrackingOptions configures the tracking of the ack holes of a consumer: the unacknowledged messages
+// delivered before acknowledged ones. The broker keeps every range of individually acknowledged messages after
+// the first unacknowledged one in the memory of the subscription cursor, so a large number of holes, e.g. caused
+// by a few messages which are never acknowledged, makes the cursor memory grow without bounds.
+type AckHoleTrackingOptions struct {
+	// WarningThreshold is the number of acknowledged ranges of a partition above which a warning is logged.
+	// Default is 1000.
+	WarningThreshold int
+}
+
+type ackHolePosition struct {
+	ledgerID int64
+	entryID  int64
+	acked    bool
+}
+
+// ackHoleTracker tracks the entries delivered to the application after the first unacknowledged one, to count the
+// ranges of acknowledged entries the broker keeps after its mark-delete position. All the methods can be called on
+// a nil tracker when the tracking is disabled.
+type ackHoleTracker struct {
+	sync.Mutex
+	// entries delivered after the mark-delete position candidate, the first one is not acknowledged
+	positions []ackHolePosition
+	// number of ranges of acknowledged entries in positions
+	ranges int
+
+	warningThreshold int
+	warned           bool
+	log              log.Logger
+}
+
+func newAckHoleTracker(options *AckHoleTrackingOptions, logger log.Logger) *ackHoleTracker {
+	if options == nil {
+		return nil
+	}
+	threshold := options.WarningThreshold
+	if threshold <= 0 {
+		threshold = defaultAckHoleWarningThreshold
+	}
+	return &ackHoleTracker{
+		warningThreshold: threshold,
+		log:              logger,
+	}
+}
+
+// search returns the index of the first position not before the entry of the message id
+func (t *ackHoleTracker) search(id MessageID) int {
+	return sort.Search(len(t.positions), func(i int) bool {
+		p := t.positions[i]
+		return p.ledgerID > id.LedgerID() || (p.ledgerID == id.LedgerID() && p.entryID >= id.EntryID())
+	})
+}
+
+func (t *ackHoleTracker) find(id MessageID) (int, bool) {
+	idx := t.search(id)
+	found := idx < len(t.positions) &&
+		t.positions[idx].ledgerID == id.LedgerID() && t.positions[idx].entryID == id.EntryID()
+	return idx, found
+}
+
+func (t *ackHoleTracker) isAcked(idx int) bool {
+	return idx >= 0 && idx < len(t.positions) && t.positions[idx].acked
+}
+
+// delivered records that a message was delivered to the application
+func (t *ackHoleTracker) delivered(id MessageID) {
+	if t == nil {
+		return
+	}
+	t.Lock()
+	defer t.Unlock()
+
+	idx, found := t.find(id)
+	if found {
+		return
+	}
+	if t.isAcked(idx-1) && t.isAcked(idx) {
+		// a redelivered entry splits a range
+		t.ranges++
+	}
+	t.positions = append(t.positions, ackHolePosition{})
+	copy(t.positions[idx+1:], t.positions[idx:])
+	t.positions[idx] = ackHolePosition{ledgerID: id.LedgerID(), entryID: id.EntryID()}
+}
+
+// acked records the acknowledgment of the entry of a message. When the acknowledged entries at the front are
+// trimmed, the last one is returned: all the delivered entries up to it are acknowledged.
+func (t *ackHoleTracker) acked(id MessageID) *ackHolePosition {
+	if t == nil {
+		return nil
+	}
+	t.Lock()
+	defer t.Unlock()
+
+	idx, found := t.find(id)
+	if !found || t.positions[idx].acked {
+		return nil
+	}
+	t.positions[idx].acked = true
+	left, right := t.isAcked(idx-1), t.isAcked(idx+1)
+	if left && right {
+		t.ranges--
+	} else if !left && !right {
+		t.ranges++
+	}
+	last := t.trim()
+	t.checkThreshold()
+	return last
+}
+
+// ackedCumulative records the acknowledgment of all the entries up to the entry of a message
+func (t *ackHoleTracker) ackedCumulative(id MessageID) {
+	if t == nil {
+		return
+	}
+	t.Lock()
+	defer t.Unlock()
+
+	idx, found := t.find(id)
+	if found {
+		idx++
+	}
+	t.positions = append(t.positions[:0], t.positions[idx:]...)
+	t.ranges = 0
+	for i := range t.positions {
+		if t.positions[i].acked && !t.isAcked(i-1) {
+			t.ranges++
+		}
+	}
+	t.trim()
+	t.checkThreshold()
+}
+
+// trim removes the acknowledged entries at the front, the broker moves its mark-delete position past them.
+// It returns the last removed entry, if any.
+func (t *ackHoleTracker) trim() *ackHolePosition {
+	n := 0
+	for n < len(t.positions) && t.positions[n].acked {
+		n++
+	}
+	if n == 0 {
+		return nil
+	}
+	last := t.positions[n-1]
+	t.ranges--
+	t.positions = append(t.positions[:0], t.positions[n:]...)
+	return &last
+}
+
+func (t *ackHoleTracker) checkThreshold() {
+	if t.ranges > t.warningThreshold && !t.warned {
+		t.warned = true
+		t.log.Warnf("%d ranges of acknowledged messages after the first unacknowledged message, the broker keeps "+
+			"them in the memory of the subscription cursor until the holes are acknowledged", t.ranges)
+	} else if t.ranges <= t.warningThreshold/2 {
+		t.warned = false
+	}
+}
+
+// reset forgets the delivered entries, when the subscription is moved with a seek
+func (t *ackHoleTracker) reset() {
+	if t == nil {
+		return
+	}
+	t.Lock()
+	defer t.Unlock()
+
+	t.positions = nil
+	t.ranges = 0
+	t.warned = false
+}
+
+// stats returns the number of ack holes, as ranges of acknowledged entries after the first unacknowledged one,
+// and the number of unacknowledged entries in the holes
+func (t *ackHoleTracker) stats() (holes int, unacked int) {
+	if t == nil {
+		return 0, 0
+	}
+	t.Lock()
+	defer t.Unlock()
+
+	last := len(t.positions) - 1
+	for last >= 0 && !t.positions[last].acked {
+		last--
+	}
+	for i := 0; i < last; i++ {
+		if !t.positions[i].acked {
+			unacked++
+		}
+	}
+	return t.ranges, unacked
+}
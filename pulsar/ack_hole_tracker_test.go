@@ -0,0 +1,126 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsar
+
+import (
+	"testing"
+
+	"github.com/apache/pulsar-client-go/pulsar/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAckHoleTracker(t *testing.T) {
+	tracker := newAckHoleTracker(&AckHoleTrackingOptions{}, log.DefaultNopLogger())
+	assert.Equal(t, defaultAckHoleWarningThreshold, tracker.warningThreshold)
+	for i := int64(0); i < 10; i++ {
+		tracker.delivered(newMessageID(1, i, -1, 0, 0))
+	}
+
+	// acknowledging the first entries moves the mark-delete position without creating holes
+	tracker.acked(newMessageID(1, 0, -1, 0, 0))
+	tracker.acked(newMessageID(1, 1, -1, 0, 0))
+	holes, unacked := tracker.stats()
+	assert.Equal(t, 0, holes)
+	assert.Equal(t, 0, unacked)
+	assert.Len(t, tracker.positions, 8)
+
+	// 2 unacked, 3 4 acked, 5 6 unacked, 7 acked
+	tracker.acked(newMessageID(1, 3, -1, 0, 0))
+	tracker.acked(newMessageID(1, 4, -1, 0, 0))
+	tracker.acked(newMessageID(1, 7, -1, 0, 0))
+	holes, unacked = tracker.stats()
+	assert.Equal(t, 2, holes)
+	assert.Equal(t, 3, unacked)
+
+	// acknowledging 5 and 6 merges the ranges
+	tracker.acked(newMessageID(1, 5, -1, 0, 0))
+	tracker.acked(newMessageID(1, 6, -1, 0, 0))
+	holes, unacked = tracker.stats()
+	assert.Equal(t, 1, holes)
+	assert.Equal(t, 1, unacked)
+
+	// acknowledging the first hole trims the acknowledged entries
+	tracker.acked(newMessageID(1, 2, -1, 0, 0))
+	holes, unacked = tracker.stats()
+	assert.Equal(t, 0, holes)
+	assert.Equal(t, 0, unacked)
+	assert.Len(t, tracker.positions, 2)
+
+	// acknowledging unknown or already acknowledged entries is ignored
+	tracker.acked(newMessageID(1, 2, -1, 0, 0))
+	tracker.acked(newMessageID(2, 0, -1, 0, 0))
+	holes, _ = tracker.stats()
+	assert.Equal(t, 0, holes)
+}
+
+func TestAckHoleTrackerRedelivery(t *testing.T) {
+	tracker := newAckHoleTracker(&AckHoleTrackingOptions{}, log.DefaultNopLogger())
+	for i := int64(0); i < 4; i++ {
+		tracker.delivered(newMessageID(1, i, -1, 0, 0))
+	}
+	tracker.acked(newMessageID(1, 1, -1, 0, 0))
+	tracker.acked(newMessageID(1, 3, -1, 0, 0))
+	holes, unacked := tracker.stats()
+	assert.Equal(t, 2, holes)
+	assert.Equal(t, 2, unacked)
+
+	// the redelivery of an entry already delivered is ignored
+	tracker.delivered(newMessageID(1, 2, -1, 0, 0))
+	assert.Len(t, tracker.positions, 4)
+
+	// cumulative acknowledgment drops the entries up to the position
+	tracker.ackedCumulative(newMessageID(1, 2, -1, 0, 0))
+	holes, unacked = tracker.stats()
+	assert.Equal(t, 0, holes)
+	assert.Equal(t, 0, unacked)
+	assert.Len(t, tracker.positions, 0)
+
+	tracker.delivered(newMessageID(1, 4, -1, 0, 0))
+	tracker.delivered(newMessageID(1, 5, -1, 0, 0))
+	tracker.acked(newMessageID(1, 5, -1, 0, 0))
+	tracker.reset()
+	holes, unacked = tracker.stats()
+	assert.Equal(t, 0, holes)
+	assert.Equal(t, 0, unacked)
+}
+
+func TestAckHoleTrackerWarning(t *testing.T) {
+	tracker := newAckHoleTracker(&AckHoleTrackingOptions{WarningThreshold: 2}, log.DefaultNopLogger())
+	for i := int64(0); i < 8; i++ {
+		tracker.delivered(newMessageID(1, i, -1, 0, 0))
+	}
+	tracker.acked(newMessageID(1, 1, -1, 0, 0))
+	tracker.acked(newMessageID(1, 3, -1, 0, 0))
+	assert.False(t, tracker.warned)
+	tracker.acked(newMessageID(1, 5, -1, 0, 0))
+	assert.True(t, tracker.warned)
+
+	tracker.ackedCumulative(newMessageID(1, 4, -1, 0, 0))
+	assert.False(t, tracker.warned)
+}
+
+func TestAckHoleTrackerDisabled(t *testing.T) {
+	tracker := newAckHoleTracker(nil, log.DefaultNopLogger())
+	tracker.delivered(newMessageID(1, 0, -1, 0, 0))
+	tracker.acked(newMessageID(1, 0, -1, 0, 0))
+	tracker.ackedCumulative(newMessageID(1, 0, -1, 0, 0))
+	tracker.reset()
+	holes, unacked := tracker.stats()
+	assert.Equal(t, 0, holes)
+	assert.Equal(t, 0, unacked)
+}
@@ -0,0 +1,57 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsar
+
+import "time"
+
+// Admin is a minimal administration surface over the Pulsar REST API, covering the operations
+// applications commonly run at startup. It reuses the authentication and TLS configuration of
+// the client. Operations are only available when the client has an HTTP service URL, either
+// ClientOptions.URL or ClientOptions.AdminURL.
+type Admin interface {
+	// CreateTopic creates a non-partitioned topic.
+	CreateTopic(topic string) error
+
+	// CreatePartitionedTopic creates a partitioned topic with the given number of partitions.
+	CreatePartitionedTopic(topic string, partitions int) error
+
+	// DeleteTopic deletes a topic, partitioned topics have all their partitions deleted.
+	// If force is true, the topic is deleted even if it has active producers or consumers.
+	DeleteTopic(topic string, force bool) error
+
+	// GetPartitions returns the number of partitions of the topic, 0 for a non-partitioned topic.
+	GetPartitions(topic string) (int, error)
+
+	// ListSubscriptions returns the names of the subscriptions of the topic.
+	ListSubscriptions(topic string) ([]string, error)
+
+	// CreateSubscription creates a subscription on the topic starting at the given message id.
+	// The message id can be LatestMessageID() or EarliestMessageID().
+	CreateSubscription(topic, subscription string, messageID MessageID) error
+
+	// DeleteSubscription deletes a subscription of the topic.
+	// If force is true, the subscription is deleted even if it has active consumers.
+	DeleteSubscription(topic, subscription string, force bool) error
+
+	// ResetCursor resets the position of the subscription to the given message id.
+	ResetCursor(topic, subscription string, messageID MessageID) error
+
+	// ResetCursorByTime resets the position of the subscription to the first message published
+	// at or after the given time.
+	ResetCursorByTime(topic, subscription string, t time.Time) error
+}
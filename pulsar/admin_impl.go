@@ -0,0 +1,179 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsar
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/apache/pulsar-client-go/pulsar/internal"
+)
+
+type admin struct {
+	httpClient internal.HTTPClient
+	// err is returned by all the operations when the admin can not be used
+	err error
+}
+
+// adminMessageID is the JSON representation of a message id in the REST API
+type adminMessageID struct {
+	LedgerID       int64 `json:"ledgerId"`
+	EntryID        int64 `json:"entryId"`
+	PartitionIndex int32 `json:"partitionIndex"`
+	BatchIndex     int32 `json:"batchIndex"`
+}
+
+func newAdmin(httpClient internal.HTTPClient) *admin {
+	return &admin{httpClient: httpClient}
+}
+
+func newUnavailableAdmin(err error) *admin {
+	return &admin{err: err}
+}
+
+func (a *admin) topicPath(topic string, parts ...string) (string, error) {
+	if a.err != nil {
+		return "", a.err
+	}
+	tn, err := internal.ParseTopicName(topic)
+	if err != nil {
+		return "", newError(InvalidTopicName, err.Error())
+	}
+	base := "/admin/v2/"
+	if !internal.IsV2TopicName(tn) {
+		base = "/admin/"
+	}
+	path := base + internal.GetTopicRestPath(tn)
+	for _, p := range parts {
+		path += "/" + url.PathEscape(p)
+	}
+	return path, nil
+}
+
+func (a *admin) CreateTopic(topic string) error {
+	path, err := a.topicPath(topic)
+	if err != nil {
+		return err
+	}
+	return a.httpClient.Put(path, nil, nil)
+}
+
+func (a *admin) CreatePartitionedTopic(topic string, partitions int) error {
+	if partitions <= 0 {
+		return newError(InvalidConfiguration, "the number of partitions must be > 0")
+	}
+	path, err := a.topicPath(topic, "partitions")
+	if err != nil {
+		return err
+	}
+	return a.httpClient.Put(path, partitions, nil)
+}
+
+func (a *admin) DeleteTopic(topic string, force bool) error {
+	partitions, err := a.GetPartitions(topic)
+	if err != nil {
+		return err
+	}
+	var path string
+	if partitions > 0 {
+		path, err = a.topicPath(topic, "partitions")
+	} else {
+		path, err = a.topicPath(topic)
+	}
+	if err != nil {
+		return err
+	}
+	return a.httpClient.Delete(path, map[string]string{"force": strconv.FormatBool(force)})
+}
+
+func (a *admin) GetPartitions(topic string) (int, error) {
+	path, err := a.topicPath(topic, "partitions")
+	if err != nil {
+		return 0, err
+	}
+	var metadata internal.PartitionedTopicMetadata
+	if err := a.httpClient.Get(path, &metadata, nil); err != nil {
+		return 0, err
+	}
+	return metadata.Partitions, nil
+}
+
+func (a *admin) ListSubscriptions(topic string) ([]string, error) {
+	path, err := a.topicPath(topic, "subscriptions")
+	if err != nil {
+		return nil, err
+	}
+	var subscriptions []string
+	if err := a.httpClient.Get(path, &subscriptions, nil); err != nil {
+		return nil, err
+	}
+	return subscriptions, nil
+}
+
+func (a *admin) CreateSubscription(topic, subscription string, messageID MessageID) error {
+	if subscription == "" {
+		return newError(InvalidConfiguration, "subscription name is required")
+	}
+	path, err := a.topicPath(topic, "subscription", subscription)
+	if err != nil {
+		return err
+	}
+	return a.httpClient.Put(path, toAdminMessageID(messageID), nil)
+}
+
+func (a *admin) DeleteSubscription(topic, subscription string, force bool) error {
+	path, err := a.topicPath(topic, "subscription", subscription)
+	if err != nil {
+		return err
+	}
+	return a.httpClient.Delete(path, map[string]string{"force": strconv.FormatBool(force)})
+}
+
+func (a *admin) ResetCursor(topic, subscription string, messageID MessageID) error {
+	if messageID == nil {
+		return newError(InvalidConfiguration, "message id is required")
+	}
+	path, err := a.topicPath(topic, "subscription", subscription, "resetcursor")
+	if err != nil {
+		return err
+	}
+	return a.httpClient.Post(path, toAdminMessageID(messageID), nil)
+}
+
+func (a *admin) ResetCursorByTime(topic, subscription string, t time.Time) error {
+	timestamp := fmt.Sprintf("%d", internal.TimestampMillis(t))
+	path, err := a.topicPath(topic, "subscription", subscription, "resetcursor", timestamp)
+	if err != nil {
+		return err
+	}
+	return a.httpClient.Post(path, nil, nil)
+}
+
+func toAdminMessageID(msgID MessageID) *adminMessageID {
+	if msgID == nil {
+		return nil
+	}
+	return &adminMessageID{
+		LedgerID:       msgID.LedgerID(),
+		EntryID:        msgID.EntryID(),
+		PartitionIndex: msgID.PartitionIdx(),
+		BatchIndex:     msgID.BatchIdx(),
+	}
+}
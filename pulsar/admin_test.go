@@ -0,0 +1,116 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsar
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type recordedRequest struct {
+	method string
+	path   string
+	body   interface{}
+	params map[string]string
+}
+
+type recordingHTTPClient struct {
+	requests   []recordedRequest
+	partitions int
+}
+
+func (c *recordingHTTPClient) Get(endpoint string, obj interface{}, params map[string]string) error {
+	c.requests = append(c.requests, recordedRequest{method: "GET", path: endpoint, params: params})
+	switch v := obj.(type) {
+	case *[]string:
+		*v = []string{"sub-1", "sub-2"}
+	default:
+		return errors.New("unexpected type")
+	}
+	return nil
+}
+
+func (c *recordingHTTPClient) Put(endpoint string, in interface{}, params map[string]string) error {
+	c.requests = append(c.requests, recordedRequest{method: "PUT", path: endpoint, body: in, params: params})
+	return nil
+}
+
+func (c *recordingHTTPClient) Post(endpoint string, in interface{}, params map[string]string) error {
+	c.requests = append(c.requests, recordedRequest{method: "POST", path: endpoint, body: in, params: params})
+	return nil
+}
+
+func (c *recordingHTTPClient) Delete(endpoint string, params map[string]string) error {
+	c.requests = append(c.requests, recordedRequest{method: "DELETE", path: endpoint, params: params})
+	return nil
+}
+
+func (c *recordingHTTPClient) Close() {}
+
+func TestAdminPaths(t *testing.T) {
+	httpClient := &recordingHTTPClient{}
+	a := newAdmin(httpClient)
+
+	assert.Nil(t, a.CreateTopic("my-topic"))
+	assert.Nil(t, a.CreatePartitionedTopic("persistent://tenant/ns/part-topic", 3))
+	subs, err := a.ListSubscriptions("my-topic")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"sub-1", "sub-2"}, subs)
+	assert.Nil(t, a.CreateSubscription("my-topic", "my sub", EarliestMessageID()))
+	assert.Nil(t, a.DeleteSubscription("my-topic", "my-sub", true))
+	assert.Nil(t, a.ResetCursor("my-topic", "my-sub", LatestMessageID()))
+
+	assert.Equal(t, []recordedRequest{
+		{method: "PUT", path: "/admin/v2/persistent/public/default/my-topic"},
+		{method: "PUT", path: "/admin/v2/persistent/tenant/ns/part-topic/partitions", body: 3},
+		{method: "GET", path: "/admin/v2/persistent/public/default/my-topic/subscriptions"},
+		{method: "PUT", path: "/admin/v2/persistent/public/default/my-topic/subscription/my%20sub",
+			body: toAdminMessageID(EarliestMessageID())},
+		{method: "DELETE", path: "/admin/v2/persistent/public/default/my-topic/subscription/my-sub",
+			params: map[string]string{"force": "true"}},
+		{method: "POST", path: "/admin/v2/persistent/public/default/my-topic/subscription/my-sub/resetcursor",
+			body: toAdminMessageID(LatestMessageID())},
+	}, httpClient.requests)
+}
+
+func TestAdminInvalidArguments(t *testing.T) {
+	a := newAdmin(&recordingHTTPClient{})
+
+	err := a.CreatePartitionedTopic("my-topic", 0)
+	assert.Equal(t, InvalidConfiguration, err.(*Error).Result())
+
+	err = a.CreateTopic("invalid://topic")
+	assert.Equal(t, InvalidTopicName, err.(*Error).Result())
+
+	err = a.CreateSubscription("my-topic", "", EarliestMessageID())
+	assert.Equal(t, InvalidConfiguration, err.(*Error).Result())
+}
+
+func TestAdminRequiresHTTPServiceURL(t *testing.T) {
+	client, err := NewClient(ClientOptions{
+		URL: "pulsar://localhost:6650",
+	})
+	assert.Nil(t, err)
+	defer client.Close()
+
+	err = client.Admin().CreateTopic("my-topic")
+	assert.NotNil(t, err)
+	assert.Equal(t, InvalidConfiguration, err.(*Error).Result())
+}
@@ -0,0 +1,147 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsar
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"time"
+)
+
+// AuditAction is the action recorded by an AuditRecord
+type AuditAction int
+
+const (
+	// AuditProduced is recorded when a message is persisted by the broker
+	AuditProduced AuditAction = iota + 1
+	// AuditAcknowledged is recorded when a consumer acknowledges a message
+	AuditAcknowledged
+)
+
+func (a AuditAction) String() string {
+	switch a {
+	case AuditProduced:
+		return "Produced"
+	case AuditAcknowledged:
+		return "Acknowledged"
+	}
+	return "Unknown"
+}
+
+// AuditRecord describes a message produced or acknowledged by the client
+type AuditRecord struct {
+	Action    AuditAction
+	Timestamp time.Time
+
+	// Topic is the topic, or the partition of the topic, of the message
+	Topic     string
+	MessageID MessageID
+
+	// Subscription is the subscription the message was acknowledged on
+	Subscription string
+
+	// Key and Size are the key and the payload size of the message. They are not known for the messages
+	// acknowledged by MessageID only, e.g. with Consumer.AckID.
+	Key  string
+	Size int
+
+	// Cumulative is true for cumulative acknowledgments, which cover all the previous messages
+	Cumulative bool
+}
+
+// AuditLogger receives the audit records of a client, see ClientOptions.AuditLogger.
+// Audit is called from the goroutines of the client and must not block.
+type AuditLogger interface {
+	Audit(record AuditRecord)
+}
+
+// auditLog samples the records sent to the audit logger. The sampling is based on the message id, so that the
+// produced and acknowledged records of a message are both kept, or both dropped.
+type auditLog struct {
+	logger    AuditLogger
+	threshold uint32
+}
+
+func newAuditLog(logger AuditLogger, samplingRate float64) (*auditLog, error) {
+	if logger == nil {
+		return nil, nil
+	}
+	if samplingRate < 0 || samplingRate > 1 {
+		return nil, newError(InvalidConfiguration, "AuditSamplingRate must be between 0 and 1")
+	}
+	if samplingRate == 0 {
+		samplingRate = 1
+	}
+	return &auditLog{
+		logger:    logger,
+		threshold: uint32(samplingRate * float64(auditSamplingRange)),
+	}, nil
+}
+
+const auditSamplingRange = 10000
+
+func (a *auditLog) sampled(msgID MessageID) bool {
+	if a.threshold >= auditSamplingRange {
+		return true
+	}
+	var buf [20]byte
+	binary.BigEndian.PutUint64(buf[0:], uint64(msgID.LedgerID()))
+	binary.BigEndian.PutUint64(buf[8:], uint64(msgID.EntryID()))
+	binary.BigEndian.PutUint32(buf[16:], uint32(msgID.BatchIdx()))
+	h := fnv.New32a()
+	_, _ = h.Write(buf[:])
+	return h.Sum32()%auditSamplingRange < a.threshold
+}
+
+func (a *auditLog) produced(topic string, msgID MessageID, msg *ProducerMessage) {
+	if a == nil || msg == nil || !a.sampled(msgID) {
+		return
+	}
+	a.logger.Audit(AuditRecord{
+		Action:    AuditProduced,
+		Timestamp: time.Now(),
+		Topic:     topic,
+		MessageID: msgID,
+		Key:       msg.Key,
+		Size:      len(msg.Payload),
+	})
+}
+
+// acked records the acknowledgment of msgID, msg is nil if the message was acknowledged by id
+func (a *auditLog) acked(subscription string, msgID MessageID, msg Message, cumulative bool) {
+	if a == nil || !a.sampled(msgID) {
+		return
+	}
+	record := AuditRecord{
+		Action:       AuditAcknowledged,
+		Timestamp:    time.Now(),
+		MessageID:    msgID,
+		Subscription: subscription,
+		Cumulative:   cumulative,
+	}
+	if msg != nil {
+		record.Topic = msg.Topic()
+		record.Key = msg.Key()
+		record.Size = len(msg.Payload())
+	} else if mid := toTrackingMessageID(msgID); mid != nil {
+		if pc, ok := mid.consumer.(*partitionConsumer); ok {
+			record.Topic = pc.topic
+		}
+	}
+	a.logger.Audit(record)
+}
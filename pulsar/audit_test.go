@@ -0,0 +1,135 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsar
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type recordingAuditLogger struct {
+	sync.Mutex
+	records []AuditRecord
+}
+
+func (l *recordingAuditLogger) Audit(record AuditRecord) {
+	l.Lock()
+	defer l.Unlock()
+	l.records = append(l.records, record)
+}
+
+func (l *recordingAuditLogger) get() []AuditRecord {
+	l.Lock()
+	defer l.Unlock()
+	return append([]AuditRecord(nil), l.records...)
+}
+
+func TestNewAuditLog(t *testing.T) {
+	audit, err := newAuditLog(nil, 0.5)
+	assert.Nil(t, err)
+	assert.Nil(t, audit)
+
+	_, err = newAuditLog(&recordingAuditLogger{}, 1.5)
+	assert.Error(t, err)
+	_, err = newAuditLog(&recordingAuditLogger{}, -1)
+	assert.Error(t, err)
+
+	audit, err = newAuditLog(&recordingAuditLogger{}, 0)
+	assert.Nil(t, err)
+	assert.True(t, audit.sampled(newMessageID(1, 2, 3, 0, 0)))
+}
+
+func TestAuditLogSampling(t *testing.T) {
+	logger := &recordingAuditLogger{}
+	audit, err := newAuditLog(logger, 0.1)
+	assert.Nil(t, err)
+
+	msg := &ProducerMessage{Key: "k", Payload: []byte("hello")}
+	for i := 0; i < 1000; i++ {
+		msgID := newMessageID(1, int64(i), 0, 0, 0)
+		audit.produced("topic", msgID, msg)
+		audit.acked("sub", msgID, nil, false)
+	}
+
+	records := logger.get()
+	// both records of a sampled message are kept
+	assert.Equal(t, 0, len(records)%2)
+	assert.InDelta(t, 200, len(records), 100)
+	for i := 0; i < len(records); i += 2 {
+		assert.Equal(t, AuditProduced, records[i].Action)
+		assert.Equal(t, "k", records[i].Key)
+		assert.Equal(t, 5, records[i].Size)
+		assert.Equal(t, AuditAcknowledged, records[i+1].Action)
+		assert.Equal(t, "sub", records[i+1].Subscription)
+		assert.Equal(t, records[i].MessageID, records[i+1].MessageID)
+	}
+
+	// a nil audit log records nothing
+	var nilAudit *auditLog
+	nilAudit.produced("topic", newMessageID(1, 1, 0, 0, 0), msg)
+}
+
+func TestAuditLogProduceConsume(t *testing.T) {
+	logger := &recordingAuditLogger{}
+	client, err := NewClient(ClientOptions{
+		URL:         lookupURL,
+		AuditLogger: logger,
+	})
+	assert.Nil(t, err)
+	defer client.Close()
+
+	topic := newTopicName()
+	consumer, err := client.Subscribe(ConsumerOptions{
+		Topic:            topic,
+		SubscriptionName: "audit-sub",
+	})
+	assert.Nil(t, err)
+	defer consumer.Close()
+
+	producer, err := client.CreateProducer(ProducerOptions{
+		Topic:           topic,
+		DisableBatching: true,
+	})
+	assert.Nil(t, err)
+	defer producer.Close()
+
+	ctx := context.Background()
+	msgID, err := producer.Send(ctx, &ProducerMessage{Key: "key", Payload: []byte("payload")})
+	assert.Nil(t, err)
+
+	msg, err := consumer.Receive(ctx)
+	assert.Nil(t, err)
+	assert.Nil(t, consumer.Ack(msg))
+
+	records := logger.get()
+	assert.Len(t, records, 2)
+	assert.Equal(t, AuditProduced, records[0].Action)
+	assert.Equal(t, msg.Topic(), records[0].Topic)
+	assert.Equal(t, msgID.String(), records[0].MessageID.String())
+	assert.Equal(t, "key", records[0].Key)
+	assert.Equal(t, 7, records[0].Size)
+
+	assert.Equal(t, AuditAcknowledged, records[1].Action)
+	assert.Equal(t, msg.Topic(), records[1].Topic)
+	assert.Equal(t, "audit-sub", records[1].Subscription)
+	assert.Equal(t, "key", records[1].Key)
+	assert.Equal(t, 7, records[1].Size)
+}
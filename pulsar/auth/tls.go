@@ -18,8 +18,14 @@
 package auth
 
 import (
+	"crypto"
 	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
 	"net/http"
+	"os"
 )
 
 type tlsAuthProvider struct {
@@ -51,6 +57,55 @@ func NewAuthenticationFromTLSCertSupplier(tlsCertSupplier func() (*tls.Certifica
 	}
 }
 
+// NewAuthenticationTLSWithSigner initialize the authentication provider with the certificate chain of a PEM file
+// and a signer holding the private key, e.g. a key stored in an HSM, a PKCS#11 token or a TPM which cannot be
+// exported to a file
+func NewAuthenticationTLSWithSigner(certificatePath string, signer crypto.Signer) Provider {
+	return &tlsAuthProvider{
+		tlsCertSupplier: func() (*tls.Certificate, error) {
+			return LoadX509CertificateWithSigner(certificatePath, signer)
+		},
+	}
+}
+
+// LoadX509CertificateWithSigner returns a TLS certificate with the certificate chain of a PEM file, the leaf
+// certificate first, and the private key of a signer. The public key of the signer must be the public key of
+// the leaf certificate.
+func LoadX509CertificateWithSigner(certificatePath string, signer crypto.Signer) (*tls.Certificate, error) {
+	if signer == nil {
+		return nil, errors.New("no signer for the TLS certificate")
+	}
+	data, err := os.ReadFile(certificatePath)
+	if err != nil {
+		return nil, err
+	}
+
+	cert := &tls.Certificate{PrivateKey: signer}
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type == "CERTIFICATE" {
+			cert.Certificate = append(cert.Certificate, block.Bytes)
+		}
+	}
+	if len(cert.Certificate) == 0 {
+		return nil, fmt.Errorf("no certificate found in %s", certificatePath)
+	}
+
+	cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return nil, err
+	}
+	public, ok := signer.Public().(interface{ Equal(crypto.PublicKey) bool })
+	if !ok || !public.Equal(cert.Leaf.PublicKey) {
+		return nil, fmt.Errorf("the public key of the signer does not match the certificate %s", certificatePath)
+	}
+	return cert, nil
+}
+
 func (p *tlsAuthProvider) Init() error {
 	// Try to read certificates immediately to provide better error at startup
 	_, err := p.GetTLSCertificate()
@@ -0,0 +1,92 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeTestCertificate(t *testing.T, key *ecdsa.PrivateKey) string {
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "client-cert.pem")
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	return path
+}
+
+func TestLoadX509CertificateWithSigner(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	path := writeTestCertificate(t, key)
+
+	cert, err := LoadX509CertificateWithSigner(path, key)
+	assert.NoError(t, err)
+	assert.Len(t, cert.Certificate, 1)
+	assert.Equal(t, "client", cert.Leaf.Subject.CommonName)
+	assert.Equal(t, key, cert.PrivateKey)
+
+	// the key of the signer must be the key of the certificate
+	other, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	_, err = LoadX509CertificateWithSigner(path, other)
+	assert.Error(t, err)
+
+	_, err = LoadX509CertificateWithSigner(path, nil)
+	assert.Error(t, err)
+
+	empty := filepath.Join(t.TempDir(), "empty.pem")
+	require.NoError(t, os.WriteFile(empty, []byte("no certificate"), 0600))
+	_, err = LoadX509CertificateWithSigner(empty, key)
+	assert.Error(t, err)
+}
+
+func TestAuthenticationTLSWithSigner(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	provider := NewAuthenticationTLSWithSigner(writeTestCertificate(t, key), key)
+	assert.NoError(t, provider.Init())
+	assert.Equal(t, "tls", provider.Name())
+
+	cert, err := provider.GetTLSCertificate()
+	assert.NoError(t, err)
+	assert.Equal(t, key, cert.PrivateKey)
+
+	provider = NewAuthenticationTLSWithSigner(filepath.Join(t.TempDir(), "missing.pem"), key)
+	assert.Error(t, provider.Init())
+}
@@ -28,6 +28,17 @@ const (
 	KeyBasedBatchBuilder
 )
 
+// KeyBatchEviction selects the key batch the KeyBasedBatchBuilder flushes first when a message with a new key
+// would exceed ProducerOptions.BatchingMaxKeys
+type KeyBatchEviction = internal.KeyBatchEviction
+
+const (
+	// EvictLargestKeyBatch flushes the key batch buffering the most bytes
+	EvictLargestKeyBatch = internal.EvictLargestKeyBatch
+	// EvictOldestKeyBatch flushes the key batch opened first
+	EvictOldestKeyBatch = internal.EvictOldestKeyBatch
+)
+
 func GetBatcherBuilderProvider(typ BatcherBuilderType) (
 	internal.BatcherBuilderProvider, error,
 ) {
@@ -0,0 +1,79 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsar
+
+import (
+	"sync"
+
+	"github.com/apache/pulsar-client-go/pulsar/internal"
+)
+
+// brokerAffinity is the broker a producer or consumer is connected to. With ClientOptions.StickyReconnect, the
+// producer or consumer reconnects first to this broker after a transient disconnection rather than looking its
+// topic up again, which may resolve it through another proxy. The broker rejects the reconnection if it doesn't
+// own the topic anymore, and the next attempt looks the topic up.
+type brokerAffinity struct {
+	sync.Mutex
+	last *internal.LookupResult
+	// sticky is set while the next lookup returns the last broker
+	sticky bool
+}
+
+// lookup returns the broker to connect to
+func (a *brokerAffinity) lookup(c *client, topic string) (*internal.LookupResult, error) {
+	a.Lock()
+	lr, sticky := a.last, a.sticky
+	a.sticky = false
+	a.Unlock()
+	if sticky && lr != nil {
+		return lr, nil
+	}
+	return c.lookupService.Lookup(topic)
+}
+
+// connected records the broker the producer or consumer connected to
+func (a *brokerAffinity) connected(lr *internal.LookupResult) {
+	a.Lock()
+	defer a.Unlock()
+	a.last = lr
+}
+
+// reconnecting makes the first reconnection attempt go to the last broker, if sticky
+func (a *brokerAffinity) reconnecting(sticky bool) {
+	a.Lock()
+	defer a.Unlock()
+	a.sticky = sticky
+}
+
+// forget drops the last broker, when the broker closed the producer or consumer as the topic moves elsewhere
+func (a *brokerAffinity) forget() {
+	a.Lock()
+	defer a.Unlock()
+	a.last = nil
+}
+
+// key returns the affinity key of the producer or consumer, the logical address of the broker it is connected to,
+// which a proxy in front of the brokers routes the connections to
+func (a *brokerAffinity) key() string {
+	a.Lock()
+	defer a.Unlock()
+	if a.last == nil {
+		return ""
+	}
+	return a.last.LogicalAddr.String()
+}
@@ -0,0 +1,75 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsar
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/apache/pulsar-client-go/pulsar/internal"
+	"github.com/stretchr/testify/assert"
+)
+
+type affinityLookupService struct {
+	mockTopicsLookupService
+	broker  string
+	lookups int
+}
+
+func (m *affinityLookupService) Lookup(topic string) (*internal.LookupResult, error) {
+	m.lookups++
+	addr, _ := url.Parse(m.broker)
+	return &internal.LookupResult{LogicalAddr: addr, PhysicalAddr: addr}, nil
+}
+
+func TestBrokerAffinity(t *testing.T) {
+	lookupService := &affinityLookupService{broker: "pulsar://broker-1:6650"}
+	c := &client{lookupService: lookupService}
+	var a brokerAffinity
+	assert.Equal(t, "", a.key())
+
+	lr, err := a.lookup(c, "topic")
+	assert.NoError(t, err)
+	a.connected(lr)
+	assert.Equal(t, "pulsar://broker-1:6650", a.key())
+
+	// the first reconnection attempt goes to the last broker, the next ones look the topic up
+	lookupService.broker = "pulsar://broker-2:6650"
+	a.reconnecting(true)
+	lr, err = a.lookup(c, "topic")
+	assert.NoError(t, err)
+	assert.Equal(t, "pulsar://broker-1:6650", lr.LogicalAddr.String())
+	assert.Equal(t, 1, lookupService.lookups)
+	lr, err = a.lookup(c, "topic")
+	assert.NoError(t, err)
+	assert.Equal(t, "pulsar://broker-2:6650", lr.LogicalAddr.String())
+	assert.Equal(t, 2, lookupService.lookups)
+
+	a.reconnecting(false)
+	_, err = a.lookup(c, "topic")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, lookupService.lookups)
+
+	// closed by the broker, the topic moves to another broker
+	a.forget()
+	a.reconnecting(true)
+	_, err = a.lookup(c, "topic")
+	assert.NoError(t, err)
+	assert.Equal(t, 4, lookupService.lookups)
+	assert.Equal(t, "", a.key())
+}
@@ -0,0 +1,119 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsar
+
+import (
+	"fmt"
+
+	"github.com/apache/pulsar-client-go/pulsar/internal"
+	pb "github.com/apache/pulsar-client-go/pulsar/internal/pulsar_proto"
+)
+
+// Minimum protocol versions a broker must speak for the optional features of the client
+const (
+	minProtocolVersionSnappy              = int32(pb.ProtocolVersion_v14)
+	minProtocolVersionBatchIndexAck       = int32(pb.ProtocolVersion_v15)
+	minProtocolVersionBrokerEntryMetadata = int32(pb.ProtocolVersion_v16)
+	minProtocolVersionAckReceipt          = int32(pb.ProtocolVersion_v17)
+	minProtocolVersionTransactions        = int32(pb.ProtocolVersion_v17)
+)
+
+// BrokerInfo describes the broker serving a topic, as negotiated during the connection handshake
+type BrokerInfo struct {
+	// ServerVersion is the version string reported by the broker
+	ServerVersion string
+
+	// ProtocolVersion is the protocol version negotiated with the broker
+	ProtocolVersion int32
+
+	// Features supported by the broker
+	Features BrokerFeatures
+}
+
+// BrokerFeatures are the optional features a broker supports
+type BrokerFeatures struct {
+	// AuthRefresh is true if the broker can refresh the authentication data of a connection
+	AuthRefresh bool
+
+	// BrokerEntryMetadata is true if the broker can attach broker entry metadata, e.g. the broker publish time
+	BrokerEntryMetadata bool
+
+	// BatchIndexAck is true if the broker accepts acknowledgments of single messages within a batch
+	BatchIndexAck bool
+
+	// AckReceipt is true if the broker responds to acknowledgments, see ConsumerOptions.AckWithResponse
+	AckReceipt bool
+
+	// Snappy is true if the broker accepts messages compressed with Snappy
+	Snappy bool
+
+	// Transactions is true if the broker supports transactions
+	Transactions bool
+
+	// PartialProducer is true if the broker supports partial producers
+	PartialProducer bool
+
+	// TopicWatchers is true if the broker can notify the client of topic list changes
+	TopicWatchers bool
+}
+
+func newBrokerInfo(info internal.ServerInfo) BrokerInfo {
+	return BrokerInfo{
+		ServerVersion:   info.ServerVersion,
+		ProtocolVersion: info.ProtocolVersion,
+		Features: BrokerFeatures{
+			AuthRefresh:         info.SupportsAuthRefresh,
+			BrokerEntryMetadata: info.ProtocolVersion >= minProtocolVersionBrokerEntryMetadata,
+			BatchIndexAck:       info.ProtocolVersion >= minProtocolVersionBatchIndexAck,
+			AckReceipt:          info.ProtocolVersion >= minProtocolVersionAckReceipt,
+			Snappy:              info.ProtocolVersion >= minProtocolVersionSnappy,
+			Transactions:        info.ProtocolVersion >= minProtocolVersionTransactions,
+			PartialProducer:     info.SupportsPartialProducer,
+			TopicWatchers:       info.SupportsTopicWatchers,
+		},
+	}
+}
+
+// require returns an UnsupportedVersionError if the broker does not support the feature
+func (i BrokerInfo) require(feature string, supported bool) error {
+	if supported {
+		return nil
+	}
+	return newError(UnsupportedVersionError, fmt.Sprintf("%s is not supported by broker %s (protocol version %d)",
+		feature, i.ServerVersion, i.ProtocolVersion))
+}
+
+// BrokerInfo returns the version and the features of the broker serving the topic. For partitioned topics the
+// broker serving the first partition is returned.
+func (c *client) BrokerInfo(topic string) (BrokerInfo, error) {
+	partitions, err := c.TopicPartitions(topic)
+	if err != nil {
+		return BrokerInfo{}, err
+	}
+
+	lr, err := c.lookupService.Lookup(partitions[0])
+	if err != nil {
+		return BrokerInfo{}, err
+	}
+
+	cnx, err := c.cnxPool.GetConnection(lr.LogicalAddr, lr.PhysicalAddr)
+	if err != nil {
+		return BrokerInfo{}, err
+	}
+	return newBrokerInfo(cnx.GetServerInfo()), nil
+}
@@ -0,0 +1,76 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsar
+
+import (
+	"testing"
+
+	"github.com/apache/pulsar-client-go/pulsar/internal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewBrokerInfo(t *testing.T) {
+	info := newBrokerInfo(internal.ServerInfo{
+		ServerVersion:       "Pulsar Server2.7.0",
+		ProtocolVersion:     15,
+		SupportsAuthRefresh: true,
+	})
+	assert.Equal(t, "Pulsar Server2.7.0", info.ServerVersion)
+	assert.Equal(t, int32(15), info.ProtocolVersion)
+	assert.True(t, info.Features.AuthRefresh)
+	assert.True(t, info.Features.BatchIndexAck)
+	assert.False(t, info.Features.BrokerEntryMetadata)
+	assert.False(t, info.Features.AckReceipt)
+	assert.False(t, info.Features.Transactions)
+	assert.True(t, info.Features.Snappy)
+
+	err := info.require("AckWithResponse", info.Features.AckReceipt)
+	assert.Error(t, err)
+	assert.Equal(t, UnsupportedVersionError, err.(*Error).Result())
+	assert.Contains(t, err.Error(), "Pulsar Server2.7.0")
+	assert.NoError(t, info.require("EnableBatchIndexAcknowledgment", info.Features.BatchIndexAck))
+
+	info = newBrokerInfo(internal.ServerInfo{ProtocolVersion: 13})
+	assert.False(t, info.Features.Snappy)
+	assert.Error(t, info.require("Snappy compression", info.Features.Snappy))
+
+	info = newBrokerInfo(internal.ServerInfo{ProtocolVersion: 18})
+	assert.True(t, info.Features.BrokerEntryMetadata)
+	assert.True(t, info.Features.AckReceipt)
+	assert.True(t, info.Features.Transactions)
+}
+
+func TestClientBrokerInfo(t *testing.T) {
+	client, err := NewClient(ClientOptions{
+		URL: lookupURL,
+	})
+	assert.Nil(t, err)
+	defer client.Close()
+
+	topic := newTopicName()
+	producer, err := client.CreateProducer(ProducerOptions{
+		Topic: topic,
+	})
+	assert.Nil(t, err)
+	defer producer.Close()
+
+	info, err := client.BrokerInfo(topic)
+	assert.Nil(t, err)
+	assert.NotEmpty(t, info.ServerVersion)
+	assert.True(t, info.ProtocolVersion > 0)
+}
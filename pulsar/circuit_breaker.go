@@ -0,0 +1,72 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsar
+
+import (
+	"github.com/apache/pulsar-client-go/pulsar/internal"
+	"github.com/apache/pulsar-client-go/pulsar/log"
+)
+
+// CircuitBreakerState is the state of the circuit breaker of a broker.
+type CircuitBreakerState = internal.CircuitState
+
+const (
+	// CircuitBreakerClosed lets the requests to the broker through
+	CircuitBreakerClosed CircuitBreakerState = internal.CircuitClosed
+	// CircuitBreakerOpen fails the requests to the broker fast
+	CircuitBreakerOpen CircuitBreakerState = internal.CircuitOpen
+	// CircuitBreakerHalfOpen lets a single probe request to the broker through
+	CircuitBreakerHalfOpen CircuitBreakerState = internal.CircuitHalfOpen
+)
+
+// ErrCircuitBreakerOpen is wrapped by the errors of the requests failed fast because the circuit breaker of the
+// broker is open.
+var ErrCircuitBreakerOpen = internal.ErrCircuitOpen
+
+// CircuitBreakerEvent is a change of the state of the circuit breaker of a broker.
+type CircuitBreakerEvent struct {
+	// Broker is the address of the broker, or of the proxy, the client connects to
+	Broker string
+	State  CircuitBreakerState
+}
+
+// CircuitBreakerListener is notified of the changes of the state of the circuit breakers of the brokers. It's
+// called in order from the goroutines of the client and must not block.
+type CircuitBreakerListener interface {
+	CircuitBreakerStateChanged(event CircuitBreakerEvent)
+}
+
+func newCircuitBreakers(options ClientOptions, logger log.Logger,
+	metrics *internal.Metrics) *internal.CircuitBreakers {
+	listener := options.CircuitBreakerListener
+	return internal.NewCircuitBreakers(options.CircuitBreakerThreshold, options.CircuitBreakerOpenDuration,
+		func(broker string, state internal.CircuitState) {
+			logger.Infof("Circuit breaker of the broker %s is %s", broker, state)
+			if listener != nil {
+				listener.CircuitBreakerStateChanged(CircuitBreakerEvent{Broker: broker, State: state})
+			}
+		}, metrics)
+}
+
+func newRetryBudget(options ClientOptions, metrics *internal.Metrics) *internal.RetryBudget {
+	ratio := options.RetryBudgetRatio
+	if ratio == 0 {
+		ratio = internal.DefaultRetryBudgetRatio
+	}
+	return internal.NewRetryBudget(ratio, metrics)
+}
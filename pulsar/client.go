@@ -18,7 +18,11 @@
 package pulsar
 
 import (
+	"context"
+	"crypto"
 	"crypto/tls"
+	"io"
+	"net/url"
 	"time"
 
 	"github.com/apache/pulsar-client-go/pulsar/auth"
@@ -31,6 +35,18 @@ func NewClient(options ClientOptions) (Client, error) {
 	return newClient(options)
 }
 
+// GetOrCreateClient returns a client from the process-wide shared registry, creating it on first use.
+// Calls with the same service URL, listener name, TLS and authentication configuration share the
+// connections, the lookup service and the memory limit of a single underlying client; the other options
+// are taken from the call that created it.
+// Each returned instance must be closed, its Close only closes the producers, consumers and readers
+// created through it, and the underlying client is closed once all the instances are closed.
+// When ClientOptions.Owner is set, the metrics of the resources created through the instance are
+// labeled with it, the other metrics of the underlying client have an empty owner label.
+func GetOrCreateClient(options ClientOptions) (Client, error) {
+	return sharedClients.getOrCreate(options)
+}
+
 // Authentication Opaque interface that represents the authentication credentials
 type Authentication interface{}
 
@@ -62,6 +78,12 @@ func NewAuthenticationTLS(certificatePath string, privateKeyPath string) Authent
 	return auth.NewAuthenticationTLS(certificatePath, privateKeyPath)
 }
 
+// NewAuthenticationTLSWithSigner Creates new Authentication provider with specified TLS certificate and a signer
+// holding the private key, e.g. in an HSM, a PKCS#11 token or a TPM
+func NewAuthenticationTLSWithSigner(certificatePath string, signer crypto.Signer) Authentication {
+	return auth.NewAuthenticationTLSWithSigner(certificatePath, signer)
+}
+
 // NewAuthenticationFromTLSCertSupplier Create new Authentication provider with specified TLS certificate supplier
 func NewAuthenticationFromTLSCertSupplier(tlsCertSupplier func() (*tls.Certificate, error)) Authentication {
 	return auth.NewAuthenticationFromTLSCertSupplier(tlsCertSupplier)
@@ -90,6 +112,10 @@ type ClientOptions struct {
 	// This parameter is required
 	URL string
 
+	// Configure the HTTP(S) service URL used by Client.Admin(), for example "https://localhost:8443".
+	// It defaults to URL when URL is an http(s) URL. The authentication and TLS options of the client are reused.
+	AdminURL string
+
 	// Timeout for the establishment of a TCP connection (default: 5 seconds)
 	ConnectionTimeout time.Duration
 
@@ -98,6 +124,10 @@ type ClientOptions struct {
 	// operation will be marked as failed
 	OperationTimeout time.Duration
 
+	// OperationTimeouts overrides the OperationTimeout of some operations, e.g. to fail a seek faster than the
+	// creation of a producer.
+	OperationTimeouts OperationTimeouts
+
 	// Configure the ping send and check interval, default to 30 seconds.
 	KeepAliveInterval time.Duration
 
@@ -111,6 +141,10 @@ type ClientOptions struct {
 	// Set the path to the TLS certificate file
 	TLSCertificateFile string
 
+	// TLSKeySigner, if set, holds the private key of the TLS certificate file instead of TLSKeyFilePath, e.g. a key
+	// stored in an HSM, a PKCS#11 token or a TPM. Its public key must be the key of the certificate.
+	TLSKeySigner crypto.Signer
+
 	// Set the path to the trusted TLS certificate file
 	TLSTrustCertsFilePath string
 
@@ -120,12 +154,100 @@ type ClientOptions struct {
 	// Configure whether the Pulsar client verify the validity of the host name from broker (default: false)
 	TLSValidateHostname bool
 
+	// TLSSessionCacheSize is the number of TLS sessions cached to resume the sessions when reconnecting to the
+	// brokers, which saves the cost of full handshakes. Default is 64, negative such as -1 to disable.
+	TLSSessionCacheSize int
+
+	// TLSCertificateExpiryWarning is how long before the expiry of the client certificate or of the certificate
+	// served by a broker the client logs a warning when connecting. The expiry times of the certificates are also
+	// exported by the pulsar_client_tls_certificate_expiry_timestamp_seconds metric.
+	// Default is 30 days, negative such as -1 to disable the warning.
+	TLSCertificateExpiryWarning time.Duration
+
+	// IOThreads is the number of goroutines handling the commands received on all the connections, which keeps the
+	// latency predictable with many connections to the brokers. A connection is handled by one goroutine at a time,
+	// so its commands keep their order. Default is 0, each connection has its own goroutine.
+	//
+	// Handling a command may block, e.g. while the receiver queue of a consumer is full or while a send callback
+	// runs in place without CallbackThreads. A goroutine blocked for more than 100ms is replaced by a new one so
+	// that the other connections are still handled, and exits once unblocked; the connection it handles waits
+	// meanwhile. Setting CallbackThreads keeps slow send callbacks from blocking the goroutines at all.
+	IOThreads int
+
+	// CallbackThreads is the number of goroutines invoking the send callbacks of the producers and the event
+	// listeners of the consumers. The callbacks of a partition are always invoked by the same goroutine, in order,
+	// while the partitions are spread over the goroutines. Default is 0, the callbacks are invoked in place by the
+	// goroutines of the client, e.g. the one handling the connection.
+	CallbackThreads int
+
+	// CallbackQueueSize is the number of callbacks queued for each of the CallbackThreads, queueing a callback
+	// blocks while the queue is full. Default is 1000.
+	CallbackQueueSize int
+
+	// TopicUnloadGracePeriod is how long the producers and consumers closed by a broker, e.g. when the bundle of
+	// their topic is unloaded or its ownership transferred, have to reconnect before the errors are reported to
+	// the application. The sends are paused until reconnected, and the send timeouts are not reported within the
+	// grace period. Default is 30 seconds, negative such as -1 to handle them as connection failures.
+	TopicUnloadGracePeriod time.Duration
+
+	// TopicUnloadListener is notified when a producer or consumer closed by a broker reconnected, or when the
+	// TopicUnloadGracePeriod elapsed first.
+	TopicUnloadListener TopicUnloadListener
+
+	// RetryBudgetRatio is the max ratio of the retries to the requests of the client, e.g. 0.2 lets 20% of the
+	// requests be retries of the failed requests and reconnections of the producers and consumers, so the
+	// retries do not amplify the load on the brokers during partial outages. A few retries per second are
+	// always allowed. Default is 0.2, negative such as -1 to not bound the retries.
+	RetryBudgetRatio float64
+
+	// CircuitBreakerThreshold is the number of consecutive failures of the requests to a broker, e.g.
+	// connection failures and timeouts, opening its circuit breaker. The requests to the broker fail fast with
+	// ErrCircuitBreakerOpen until a probe request succeeds, a probe request being let through once the circuit
+	// has been open for CircuitBreakerOpenDuration. Default is 5, negative such as -1 to disable the breakers.
+	CircuitBreakerThreshold int
+
+	// CircuitBreakerOpenDuration is how long the circuit breaker of a broker stays open before letting a probe
+	// request through. Default is 10 seconds.
+	CircuitBreakerOpenDuration time.Duration
+
+	// CircuitBreakerListener is notified of the changes of the state of the circuit breakers, which are also
+	// exported by the pulsar_client_circuit_breaker_state metric.
+	CircuitBreakerListener CircuitBreakerListener
+
+	// EndpointFilter is consulted with the address of each broker or proxy before connecting to it, including the
+	// brokers the lookups redirect to, e.g. to enforce an allowlist of hosts. The connections to the addresses it
+	// returns an error for fail with an *EndpointRejectedError, and are counted by the
+	// pulsar_client_connections_rejected metric. It's called while the other connections are being opened, so it
+	// must return quickly. Default is nil, connecting to any address.
+	EndpointFilter func(addr *url.URL) error
+
 	// Configure the net model for vpc user to connect the pulsar broker
 	ListenerName string
 
+	// LookupOverrides pins topics to brokers, bypassing the broker lookup, so tests and special deployments
+	// can route topics to specific brokers or proxies. The keys are topic names, an override of a partitioned
+	// topic applies to all its partitions.
+	LookupOverrides map[string]LookupOverride
+
+	// LookupServiceWrapper wraps or replaces the lookup service of the client. It is called once with the
+	// default lookup service, including the LookupOverrides, and the returned service is used for all the
+	// lookups of the client.
+	LookupServiceWrapper func(defaultService LookupService) LookupService
+
 	// Max number of connections to a single broker that will kept in the pool. (Default: 1 connection)
 	MaxConnectionsPerBroker int
 
+	// MaxConcurrentLookupRequests bounds the lookup and partitioned metadata requests the client keeps
+	// outstanding at once on its lookup connections. The requests are multiplexed by request id and each
+	// one times out on its own after the OperationTimeout. A negative value does not bound them.
+	// (Default: 5000)
+	MaxConcurrentLookupRequests int
+
+	// MaxLookupRedirects is the max number of redirects between the brokers a topic lookup follows. The lookup
+	// fails with an error naming the chain of brokers when it is exceeded, or as soon as the brokers redirect it
+	// in a loop. (Default: 20)
+	MaxLookupRedirects int
+
 	// Configure the logger used by the client.
 	// By default, a wrapped logrus.StandardLogger will be used, namely,
 	// log.NewLoggerWithLogrus(logrus.StandardLogger())
@@ -139,10 +261,40 @@ type ClientOptions struct {
 	// Add custom labels to all the metrics reported by this client instance
 	CustomMetricsLabels map[string]string
 
+	// Owner is the logical owner, such as a library, of a client obtained with GetOrCreateClient.
+	// The metrics of the producers, consumers and readers created through it are labeled with `owner`.
+	Owner string
+
+	// ClockSkewWarning is how far the time a broker received a message may be from the publish time set by its
+	// producer before a consumer logs a warning, e.g. as the event time logic relying on the publish times breaks.
+	// The skew is measured for the messages received with broker entry metadata, the brokers must be configured
+	// with the AppendBrokerTimestampMetadataInterceptor, and it is also exported by the
+	// pulsar_client_consumer_publish_time_skew_seconds metric to correct the end to end latencies.
+	// Default is 1 minute, negative such as -1 to disable the warning.
+	ClockSkewWarning time.Duration
+
+	// StickyReconnect reconnects the producers and consumers to the broker they were connected to after a transient
+	// disconnection, rather than looking their topic up again, as long as the broker still owns the topic. It keeps
+	// the sessions on the same broker through the proxies and load balancers fronting the brokers, which route the
+	// connections by the affinity key of the producers and consumers: the logical address of their broker, also
+	// reported by DumpState. The producers and consumers closed by the broker look their topic up right away.
+	// Default is false.
+	StickyReconnect bool
+
 	// Specify metric registerer used to register metrics.
 	// Default prometheus.DefaultRegisterer
 	MetricsRegisterer prometheus.Registerer
 
+	// MetricsExporter exports the metrics of the client every MetricsExportInterval and when the client is closed,
+	// besides the Prometheus registerer, for the environments Prometheus can't scrape such as short-lived batch
+	// jobs. See NewJSONMetricsExporter and NewOTLPMetricsExporter. The metrics are gathered from the
+	// MetricsRegisterer, which must also be a prometheus.Gatherer such as a *prometheus.Registry.
+	MetricsExporter MetricsExporter
+
+	// MetricsExportInterval is the interval between the exports of the metrics to the MetricsExporter.
+	// Default is 1 minute.
+	MetricsExportInterval time.Duration
+
 	// Release the connection if it is not used for more than ConnectionMaxIdleTime.
 	// Default is 60 seconds, negative such as -1 to disable.
 	ConnectionMaxIdleTime time.Duration
@@ -152,6 +304,73 @@ type ClientOptions struct {
 	// Limit of client memory usage (in byte). The 64M default can guarantee a high producer throughput.
 	// Config less than 0 indicates off memory limit.
 	MemoryLimitBytes int64
+
+	// EnableFIPSMode restricts TLS and end-to-end encryption to FIPS 140 approved algorithms.
+	// TLS connections are limited to TLS 1.2 with AES-GCM cipher suites over NIST curves, client
+	// certificates and encryption keys must be RSA (at least 2048 bits) or ECDSA on a NIST curve, and
+	// insecure TLS connections are rejected. Building the library with the `fips` tag forces this mode on.
+	EnableFIPSMode bool
+
+	// EnableTopicExistenceCheck verifies that the namespace and the topic exist before creating a producer,
+	// consumer or reader, and fails fast with a TopicNotFound or NamespaceNotFound error naming the missing
+	// resource. Enable it when topic auto-creation is disabled on the brokers, instead of retrying the lookup
+	// until the operation times out. Non-persistent topics are not checked.
+	EnableTopicExistenceCheck bool
+
+	// ContextPropagators carry values of the context given to the producers in the properties of the messages,
+	// to be extracted from the received messages with MessageContext.
+	ContextPropagators []ContextPropagator
+
+	// DefaultProducerOptions, if set, are the default options of the producers created by the client.
+	// The fields left to their zero value in the options of a producer are set from the defaults, except the
+	// ones listed in ProducerOptions.ExplicitZeroFields.
+	DefaultProducerOptions *ProducerOptions
+
+	// DefaultConsumerOptions, if set, are the default options of the consumers created by the client.
+	// The fields left to their zero value in the options of a consumer are set from the defaults, except the
+	// ones listed in ConsumerOptions.ExplicitZeroFields.
+	DefaultConsumerOptions *ConsumerOptions
+
+	// NamespaceProducerOptions are the default options of the producers of the topics of a namespace, keyed by
+	// "tenant/namespace", e.g. to compress the messages of all the topics of "tenant/bulk".
+	// They take precedence over the DefaultProducerOptions and are overridden by the options of the producer.
+	NamespaceProducerOptions map[string]*ProducerOptions
+
+	// NamespaceConsumerOptions are the default options of the consumers of the topics of a namespace, keyed by
+	// "tenant/namespace", e.g. to set the DLQ policy of all the consumers of "tenant/orders".
+	// They take precedence over the DefaultConsumerOptions and are overridden by the options of the consumer.
+	NamespaceConsumerOptions map[string]*ConsumerOptions
+
+	// AuditLogger, if set, receives a record of every message produced and acknowledged by the client,
+	// e.g. to prove the lineage of the messages.
+	AuditLogger AuditLogger
+
+	// AuditSamplingRate is the fraction, between 0 and 1, of the messages recorded in the audit log.
+	// The produced and acknowledged records of a message are either both recorded or both skipped.
+	// Default is 1, all the messages are recorded.
+	AuditSamplingRate float64
+
+	// SchemaCacheFile, if set, is the file the schemas fetched by the consumers are imported from when the client
+	// is created and exported to when it is closed, so that short-lived consumers, e.g. command line tools, do
+	// not fetch the schemas on every run. See also Client.ExportSchemaCache.
+	SchemaCacheFile string
+
+	// ProducerCacheIdleTime is the time after which a producer returned by Client.ProducerFor is closed when it
+	// has been released by all the callers. A negative value keeps the producers until the client is closed.
+	// Default is 1 minute.
+	ProducerCacheIdleTime time.Duration
+}
+
+// LookupOverride is the static lookup result of a topic set in ClientOptions.LookupOverrides.
+type LookupOverride struct {
+	// BrokerServiceURL is the service URL of the broker serving the topic, e.g. "pulsar://broker-1:6650"
+	BrokerServiceURL string
+
+	// ProxyServiceURL is the service URL of the proxy used to reach the broker, if any
+	ProxyServiceURL string
+
+	// Err, if not nil, is returned by the lookups of the topic to simulate lookup failures
+	Err error
 }
 
 // Client represents a pulsar client
@@ -166,6 +385,14 @@ type Client interface {
 	// creation will be retained until acknowledged, even if the consumer is not connected
 	Subscribe(ConsumerOptions) (Consumer, error)
 
+	// SubscribeToDLQ Creates a `Consumer` on the dead letter topic of a subscription, e.g. for reprocessing jobs.
+	// The dead letter topic is named after the original topic and subscription with the same rules as the
+	// dead letter producer of the consumers. If options.DLQ is set, it is the DLQPolicy of the original
+	// consumers and its DeadLetterTopic, if any, is used instead.
+	// The subscription name of the options defaults to the original subscription, the consumer does not
+	// route its own messages to a dead letter topic.
+	SubscribeToDLQ(originalTopic, subscription string, options ConsumerOptions) (Consumer, error)
+
 	// CreateReader Creates a Reader instance.
 	// This method will block until the reader is created successfully.
 	CreateReader(ReaderOptions) (Reader, error)
@@ -174,6 +401,64 @@ type Client interface {
 	// This method will block until the table view is created successfully.
 	CreateTableView(TableViewOptions) (TableView, error)
 
+	// ProducerFor returns a producer of the topic shared by all the callers, e.g. for services publishing to
+	// many topics. The producer is created with the default producer options of the client on the first call.
+	// Closing the returned producer releases it; the shared producer is closed once it has been released by
+	// all the callers and not used again for ClientOptions.ProducerCacheIdleTime.
+	ProducerFor(topic string) (Producer, error)
+
+	// GroupSend sends messages to several topics and waits for all of them to be sent. The result of each
+	// message is returned in the order of the messages, with an error if any message failed. The producers of
+	// the topics are shared with ProducerFor.
+	// The messages are sent on a best-effort basis unless options.Transactional is set, options can be nil.
+	GroupSend(ctx context.Context, messages []TopicMessage, options *GroupSendOptions) ([]GroupSendResult, error)
+
+	// NewTransaction creates a new transaction, the transaction is aborted by the broker if it is
+	// not committed within the given timeout (default: 3 minutes).
+	// The client must have been created with ClientOptions.EnableTransaction.
+	NewTransaction(timeout time.Duration) (Transaction, error)
+
+	// Admin returns the minimal administration interface of the client
+	Admin() Admin
+
+	// Reload changes the settings of the client which can be changed without recreating the producers
+	// and consumers, see ReloadableOptions. The settings are not changed if an error is returned.
+	Reload(options ReloadableOptions) error
+
+	// CloseMatching closes the producers, consumers, readers and table views created by this client for which
+	// match returns true, e.g. all the resources attached to a topic or with a given label. It returns the number
+	// of closed resources. The client itself stays open.
+	CloseMatching(match func(info ResourceInfo) bool) int
+
+	// Producers returns descriptors of the producers created by this client and not closed yet, oldest first,
+	// e.g. for an admin dashboard.
+	Producers() []ProducerDescriptor
+
+	// Consumers returns descriptors of the consumers created by this client and not closed yet, oldest first.
+	// The readers and table views are not included.
+	Consumers() []ConsumerDescriptor
+
+	// DumpState writes a JSON snapshot of the producers, consumers, readers and table views of the client with
+	// their queues and acknowledgments, the memory limit usage and the connections, e.g. to attach to a bug report.
+	// See NewStateHandler to serve it over HTTP.
+	DumpState(w io.Writer) error
+
+	// ExportSchemaCache writes the schemas fetched by the consumers of the client, by topic and schema version,
+	// to w. They can be imported with ImportSchemaCache in another client to skip fetching them again.
+	ExportSchemaCache(w io.Writer) error
+
+	// ImportSchemaCache reads schemas written by ExportSchemaCache from r. The imported schemas are used by the
+	// consumers instead of fetching them from the broker.
+	ImportSchemaCache(r io.Reader) error
+
+	// CheckReplay reports, for each partition of the topic, the first message still retained by the partition and
+	// whether the messages published since start may already have been removed by the retention policy, e.g. to
+	// check a replay of the topic from start is complete before creating the readers.
+	CheckReplay(ctx context.Context, topic string, start time.Time) ([]PartitionReplay, error)
+
+	// BrokerInfo returns the version and the features of the broker serving the given topic
+	BrokerInfo(topic string) (BrokerInfo, error)
+
 	// TopicPartitions Fetches the list of partitions for a given topic
 	//
 	// If the topic is partitioned, this will return a list of partition names.
@@ -188,6 +473,30 @@ type Client interface {
 	Close()
 }
 
+// OperationTimeouts are the timeouts of the operations of the client, see ClientOptions.OperationTimeouts.
+// A zero timeout is the ClientOptions.OperationTimeout.
+type OperationTimeouts struct {
+	// Subscribe bounds the subscription of a consumer, or reader, to each topic or partition
+	Subscribe time.Duration
+
+	// CreateProducer bounds the creation of a producer on each topic or partition
+	CreateProducer time.Duration
+
+	// Seek bounds the seeks of the consumers and readers, on each partition
+	Seek time.Duration
+
+	// Unsubscribe bounds the unsubscription of a consumer, on each partition
+	Unsubscribe time.Duration
+
+	// GetLastMessageID bounds the requests of the last message id of a topic, or partition, e.g. by
+	// Reader.HasNext
+	GetLastMessageID time.Duration
+
+	// Flush bounds Producer.Flush, which fails with a TimeoutError when the messages are not persisted in time.
+	// Unlike the other operations, a zero timeout does not bound it.
+	Flush time.Duration
+}
+
 // MetricsCardinality represents the specificty of labels on a per-metric basis
 type MetricsCardinality int
 
@@ -0,0 +1,548 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsar
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ClientConfigEnv is the environment variable holding the path of the client configuration file,
+// used when no path is given to NewClientFromConfig or LoadClientOptions.
+const ClientConfigEnv = "PULSAR_CLIENT_CONFIG"
+
+// NewClientFromConfig creates a client from a YAML or JSON configuration file, see LoadClientOptions.
+func NewClientFromConfig(path string) (Client, error) {
+	options, err := LoadClientOptions(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewClient(options)
+}
+
+// LoadClientOptions reads the client options from a YAML or JSON configuration file, so the client can be
+// tuned without code changes. If path is empty, the path is read from the PULSAR_CLIENT_CONFIG environment
+// variable. References to environment variables, `${NAME}` or `${NAME:-default}`, are replaced by their value
+// before the file is parsed.
+//
+// The file covers the client options, the authentication plugin and its parameters, and the default
+// producer and consumer options, for example:
+//
+//	url: pulsar+ssl://broker:6651
+//	operationTimeout: 30s
+//	tlsTrustCertsFilePath: /etc/pulsar/ca.pem
+//	authentication:
+//	  name: token
+//	  params:
+//	    token: ${PULSAR_TOKEN}
+//	producer:
+//	  compressionType: lz4
+//	  batchingMaxPublishDelay: 5ms
+//	consumer:
+//	  receiverQueueSize: 500
+//
+// The options that can only be set in code, such as the logger, can be set on the returned options.
+func LoadClientOptions(path string) (ClientOptions, error) {
+	if path == "" {
+		path = os.Getenv(ClientConfigEnv)
+	}
+	if path == "" {
+		return ClientOptions{}, newError(InvalidConfiguration,
+			fmt.Sprintf("no client configuration file, set the path or %s", ClientConfigEnv))
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ClientOptions{}, newError(InvalidConfiguration,
+			fmt.Sprintf("failed to read the client configuration file: %v", err))
+	}
+	return parseClientConfig(data)
+}
+
+var configEnvPattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// expandConfigEnv replaces the `${NAME}` and `${NAME:-default}` references with the environment variables
+func expandConfigEnv(data []byte) []byte {
+	return configEnvPattern.ReplaceAllFunc(data, func(ref []byte) []byte {
+		m := configEnvPattern.FindSubmatch(ref)
+		if v, ok := os.LookupEnv(string(m[1])); ok && (v != "" || len(m[2]) == 0) {
+			return []byte(v)
+		}
+		return m[3]
+	})
+}
+
+func parseClientConfig(data []byte) (ClientOptions, error) {
+	// YAML is a superset of JSON, the document is converted to JSON so a single set of tags is used
+	var doc map[string]interface{}
+	if err := yaml.Unmarshal(expandConfigEnv(data), &doc); err != nil {
+		return ClientOptions{}, newError(InvalidConfiguration, fmt.Sprintf("invalid client configuration: %v", err))
+	}
+	js, err := json.Marshal(doc)
+	if err != nil {
+		return ClientOptions{}, newError(InvalidConfiguration, fmt.Sprintf("invalid client configuration: %v", err))
+	}
+
+	var cfg clientConfig
+	decoder := json.NewDecoder(strings.NewReader(string(js)))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&cfg); err != nil {
+		return ClientOptions{}, newError(InvalidConfiguration, fmt.Sprintf("invalid client configuration: %v", err))
+	}
+	return cfg.toClientOptions()
+}
+
+// configDuration is a duration written as a string, e.g. "1m30s"
+type configDuration time.Duration
+
+func (d *configDuration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration must be a string such as \"10s\": %w", err)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = configDuration(v)
+	return nil
+}
+
+type authenticationConfig struct {
+	Name string `json:"name"`
+	// Params is either the JSON encoded parameters of the plugin or an object
+	Params json.RawMessage `json:"params"`
+}
+
+type clientConfig struct {
+	URL                         string                `json:"url"`
+	AdminURL                    string                `json:"adminUrl"`
+	ConnectionTimeout           configDuration        `json:"connectionTimeout"`
+	OperationTimeout            configDuration        `json:"operationTimeout"`
+	OperationTimeouts           operationTimeouts     `json:"operationTimeouts"`
+	KeepAliveInterval           configDuration        `json:"keepAliveInterval"`
+	Authentication              *authenticationConfig `json:"authentication"`
+	TLSTrustCertsFilePath       string                `json:"tlsTrustCertsFilePath"`
+	TLSCertificateFile          string                `json:"tlsCertificateFile"`
+	TLSKeyFilePath              string                `json:"tlsKeyFilePath"`
+	TLSAllowInsecureConnection  bool                  `json:"tlsAllowInsecureConnection"`
+	TLSValidateHostname         bool                  `json:"tlsValidateHostname"`
+	TLSSessionCacheSize         int                   `json:"tlsSessionCacheSize"`
+	TLSCertificateExpiryWarning configDuration        `json:"tlsCertificateExpiryWarning"`
+	TopicUnloadGracePeriod      configDuration        `json:"topicUnloadGracePeriod"`
+	ClockSkewWarning            configDuration        `json:"clockSkewWarning"`
+	StickyReconnect             bool                  `json:"stickyReconnect"`
+	RetryBudgetRatio            float64               `json:"retryBudgetRatio"`
+	CircuitBreakerThreshold     int                   `json:"circuitBreakerThreshold"`
+	CircuitBreakerOpenDuration  configDuration        `json:"circuitBreakerOpenDuration"`
+	ListenerName                string                `json:"listenerName"`
+	MaxConnectionsPerBroker     int                   `json:"maxConnectionsPerBroker"`
+	MaxConcurrentLookupRequests int                   `json:"maxConcurrentLookupRequests"`
+	MaxLookupRedirects          int                   `json:"maxLookupRedirects"`
+	IOThreads                   int                   `json:"ioThreads"`
+	CallbackThreads             int                   `json:"callbackThreads"`
+	CallbackQueueSize           int                   `json:"callbackQueueSize"`
+	MetricsCardinality          string                `json:"metricsCardinality"`
+	CustomMetricsLabels         map[string]string     `json:"customMetricsLabels"`
+	Owner                       string                `json:"owner"`
+	ConnectionMaxIdleTime       configDuration        `json:"connectionMaxIdleTime"`
+	EnableTransaction           bool                  `json:"enableTransaction"`
+	MemoryLimitBytes            int64                 `json:"memoryLimitBytes"`
+	SchemaCacheFile             string                `json:"schemaCacheFile"`
+	ProducerCacheIdleTime       configDuration        `json:"producerCacheIdleTime"`
+	EnableFIPSMode              bool                  `json:"enableFipsMode"`
+	EnableTopicExistenceCheck   bool                  `json:"enableTopicExistenceCheck"`
+	Producer                    *producerConfig       `json:"producer"`
+	Consumer                    *consumerConfig       `json:"consumer"`
+	Namespaces                  namespacesConfig      `json:"namespaces"`
+}
+
+// namespacesConfig are the default options of the producers and consumers keyed by "tenant/namespace"
+type namespacesConfig map[string]*namespaceConfig
+
+type namespaceConfig struct {
+	Producer *producerConfig `json:"producer"`
+	Consumer *consumerConfig `json:"consumer"`
+}
+
+type producerConfig struct {
+	SendTimeout                     configDuration `json:"sendTimeout"`
+	DisableBlockIfQueueFull         bool           `json:"disableBlockIfQueueFull"`
+	MaxPendingMessages              int            `json:"maxPendingMessages"`
+	HashingScheme                   string         `json:"hashingScheme"`
+	CompressionType                 string         `json:"compressionType"`
+	CompressionLevel                string         `json:"compressionLevel"`
+	DisableBatching                 bool           `json:"disableBatching"`
+	BatchingMaxPublishDelay         configDuration `json:"batchingMaxPublishDelay"`
+	MaxUnflushedDuration            configDuration `json:"maxUnflushedDuration"`
+	BatchingMaxMessages             uint           `json:"batchingMaxMessages"`
+	BatchingMaxSize                 uint           `json:"batchingMaxSize"`
+	PartitionsAutoDiscoveryInterval configDuration `json:"partitionsAutoDiscoveryInterval"`
+	DisableMultiSchema              bool           `json:"disableMultiSchema"`
+	EnableChunking                  bool           `json:"enableChunking"`
+	ChunkMaxMessageSize             uint           `json:"chunkMaxMessageSize"`
+	MaxInflightPerKey               int            `json:"maxInflightPerKey"`
+	BatchingMaxKeys                 int            `json:"batchingMaxKeys"`
+	BatchingKeyEviction             string         `json:"batchingKeyEviction"`
+}
+
+type consumerConfig struct {
+	Type                           string         `json:"type"`
+	SubscriptionInitialPosition    string         `json:"subscriptionInitialPosition"`
+	AutoDiscoveryPeriod            configDuration `json:"autoDiscoveryPeriod"`
+	ReceiverQueueSize              int            `json:"receiverQueueSize"`
+	NackRedeliveryDelay            configDuration `json:"nackRedeliveryDelay"`
+	ReadCompacted                  bool           `json:"readCompacted"`
+	ReplicateSubscriptionState     bool           `json:"replicateSubscriptionState"`
+	RetryEnable                    bool           `json:"retryEnable"`
+	EnableDefaultNackBackoffPolicy bool           `json:"enableDefaultNackBackoffPolicy"`
+	AckWithResponse                bool           `json:"ackWithResponse"`
+	MaxPendingChunkedMessage       int            `json:"maxPendingChunkedMessage"`
+	MaxPendingChunkedMessageBytes  int64          `json:"maxPendingChunkedMessageBytes"`
+	ExpireTimeOfIncompleteChunk    configDuration `json:"expireTimeOfIncompleteChunk"`
+	AutoAckIncompleteChunk         bool           `json:"autoAckIncompleteChunk"`
+	EnableBatchIndexAcknowledgment bool           `json:"enableBatchIndexAcknowledgment"`
+	DecodeKafkaEntries             bool           `json:"decodeKafkaEntries"`
+	SubscriptionMode               string         `json:"subscriptionMode"`
+	ValidateStartMessageID         bool           `json:"validateStartMessageID"`
+	RateLimit                      rateConfig     `json:"rateLimit"`
+	PartitionDispatch              string         `json:"partitionDispatch"`
+	OversizedMessageThreshold      int            `json:"oversizedMessageThreshold"`
+}
+
+type operationTimeouts struct {
+	Subscribe        configDuration `json:"subscribe"`
+	CreateProducer   configDuration `json:"createProducer"`
+	Seek             configDuration `json:"seek"`
+	Unsubscribe      configDuration `json:"unsubscribe"`
+	GetLastMessageID configDuration `json:"getLastMessageId"`
+	Flush            configDuration `json:"flush"`
+}
+
+func (c operationTimeouts) toOperationTimeouts() OperationTimeouts {
+	return OperationTimeouts{
+		Subscribe:        time.Duration(c.Subscribe),
+		CreateProducer:   time.Duration(c.CreateProducer),
+		Seek:             time.Duration(c.Seek),
+		Unsubscribe:      time.Duration(c.Unsubscribe),
+		GetLastMessageID: time.Duration(c.GetLastMessageID),
+		Flush:            time.Duration(c.Flush),
+	}
+}
+
+type rateConfig struct {
+	MessagesPerSecond int   `json:"messagesPerSecond"`
+	BytesPerSecond    int64 `json:"bytesPerSecond"`
+}
+
+func (c *clientConfig) toClientOptions() (ClientOptions, error) {
+	options := ClientOptions{
+		URL:                         c.URL,
+		AdminURL:                    c.AdminURL,
+		ConnectionTimeout:           time.Duration(c.ConnectionTimeout),
+		OperationTimeout:            time.Duration(c.OperationTimeout),
+		OperationTimeouts:           c.OperationTimeouts.toOperationTimeouts(),
+		KeepAliveInterval:           time.Duration(c.KeepAliveInterval),
+		TLSTrustCertsFilePath:       c.TLSTrustCertsFilePath,
+		TLSCertificateFile:          c.TLSCertificateFile,
+		TLSKeyFilePath:              c.TLSKeyFilePath,
+		TLSAllowInsecureConnection:  c.TLSAllowInsecureConnection,
+		TLSValidateHostname:         c.TLSValidateHostname,
+		TLSSessionCacheSize:         c.TLSSessionCacheSize,
+		TLSCertificateExpiryWarning: time.Duration(c.TLSCertificateExpiryWarning),
+		TopicUnloadGracePeriod:      time.Duration(c.TopicUnloadGracePeriod),
+		ClockSkewWarning:            time.Duration(c.ClockSkewWarning),
+		StickyReconnect:             c.StickyReconnect,
+		RetryBudgetRatio:            c.RetryBudgetRatio,
+		CircuitBreakerThreshold:     c.CircuitBreakerThreshold,
+		CircuitBreakerOpenDuration:  time.Duration(c.CircuitBreakerOpenDuration),
+		ListenerName:                c.ListenerName,
+		MaxConnectionsPerBroker:     c.MaxConnectionsPerBroker,
+		MaxConcurrentLookupRequests: c.MaxConcurrentLookupRequests,
+		MaxLookupRedirects:          c.MaxLookupRedirects,
+		IOThreads:                   c.IOThreads,
+		CallbackThreads:             c.CallbackThreads,
+		CallbackQueueSize:           c.CallbackQueueSize,
+		CustomMetricsLabels:         c.CustomMetricsLabels,
+		Owner:                       c.Owner,
+		ConnectionMaxIdleTime:       time.Duration(c.ConnectionMaxIdleTime),
+		EnableTransaction:           c.EnableTransaction,
+		MemoryLimitBytes:            c.MemoryLimitBytes,
+		SchemaCacheFile:             c.SchemaCacheFile,
+		ProducerCacheIdleTime:       time.Duration(c.ProducerCacheIdleTime),
+		EnableFIPSMode:              c.EnableFIPSMode,
+		EnableTopicExistenceCheck:   c.EnableTopicExistenceCheck,
+	}
+
+	switch strings.ToLower(c.MetricsCardinality) {
+	case "":
+	case "none":
+		options.MetricsCardinality = MetricsCardinalityNone
+	case "tenant":
+		options.MetricsCardinality = MetricsCardinalityTenant
+	case "namespace":
+		options.MetricsCardinality = MetricsCardinalityNamespace
+	case "topic":
+		options.MetricsCardinality = MetricsCardinalityTopic
+	default:
+		return options, invalidConfigValue("metricsCardinality", c.MetricsCardinality)
+	}
+
+	if c.Authentication != nil {
+		auth, err := c.Authentication.toAuthentication()
+		if err != nil {
+			return options, err
+		}
+		options.Authentication = auth
+	}
+
+	if c.Producer != nil {
+		producerOptions, err := c.Producer.toProducerOptions()
+		if err != nil {
+			return options, err
+		}
+		options.DefaultProducerOptions = producerOptions
+	}
+
+	if c.Consumer != nil {
+		consumerOptions, err := c.Consumer.toConsumerOptions()
+		if err != nil {
+			return options, err
+		}
+		options.DefaultConsumerOptions = consumerOptions
+	}
+
+	for namespace, config := range c.Namespaces {
+		if config == nil {
+			continue
+		}
+		if config.Producer != nil {
+			producerOptions, err := config.Producer.toProducerOptions()
+			if err != nil {
+				return options, err
+			}
+			if options.NamespaceProducerOptions == nil {
+				options.NamespaceProducerOptions = make(map[string]*ProducerOptions)
+			}
+			options.NamespaceProducerOptions[namespace] = producerOptions
+		}
+		if config.Consumer != nil {
+			consumerOptions, err := config.Consumer.toConsumerOptions()
+			if err != nil {
+				return options, err
+			}
+			if options.NamespaceConsumerOptions == nil {
+				options.NamespaceConsumerOptions = make(map[string]*ConsumerOptions)
+			}
+			options.NamespaceConsumerOptions[namespace] = consumerOptions
+		}
+	}
+	return options, nil
+}
+
+func (c *authenticationConfig) toAuthentication() (Authentication, error) {
+	params := "{}"
+	if len(c.Params) > 0 && string(c.Params) != "null" {
+		if err := json.Unmarshal(c.Params, &params); err != nil {
+			// the parameters are an object, the plugins accept them encoded to JSON
+			params = string(c.Params)
+		}
+	}
+	auth, err := NewAuthentication(c.Name, params)
+	if err != nil {
+		return nil, newError(InvalidConfiguration, fmt.Sprintf("invalid authentication configuration: %v", err))
+	}
+	return auth, nil
+}
+
+func (c *producerConfig) toProducerOptions() (*ProducerOptions, error) {
+	options := &ProducerOptions{
+		SendTimeout:                     time.Duration(c.SendTimeout),
+		DisableBlockIfQueueFull:         c.DisableBlockIfQueueFull,
+		MaxPendingMessages:              c.MaxPendingMessages,
+		DisableBatching:                 c.DisableBatching,
+		BatchingMaxPublishDelay:         time.Duration(c.BatchingMaxPublishDelay),
+		MaxUnflushedDuration:            time.Duration(c.MaxUnflushedDuration),
+		BatchingMaxMessages:             c.BatchingMaxMessages,
+		BatchingMaxSize:                 c.BatchingMaxSize,
+		PartitionsAutoDiscoveryInterval: time.Duration(c.PartitionsAutoDiscoveryInterval),
+		DisableMultiSchema:              c.DisableMultiSchema,
+		EnableChunking:                  c.EnableChunking,
+		ChunkMaxMessageSize:             c.ChunkMaxMessageSize,
+		MaxInflightPerKey:               c.MaxInflightPerKey,
+		BatchingMaxKeys:                 c.BatchingMaxKeys,
+	}
+
+	switch strings.ToLower(c.HashingScheme) {
+	case "", "javastringhash":
+		options.HashingScheme = JavaStringHash
+	case "murmur3_32hash":
+		options.HashingScheme = Murmur3_32Hash
+	default:
+		return nil, invalidConfigValue("producer.hashingScheme", c.HashingScheme)
+	}
+
+	switch strings.ToLower(c.CompressionType) {
+	case "", "none":
+		options.CompressionType = NoCompression
+	case "lz4":
+		options.CompressionType = LZ4
+	case "zlib":
+		options.CompressionType = ZLib
+	case "zstd":
+		options.CompressionType = ZSTD
+	case "snappy":
+		options.CompressionType = Snappy
+	case "lz4frame":
+		options.CompressionType = LZ4Frame
+	default:
+		return nil, invalidConfigValue("producer.compressionType", c.CompressionType)
+	}
+
+	switch strings.ToLower(c.CompressionLevel) {
+	case "", "default":
+		options.CompressionLevel = Default
+	case "faster":
+		options.CompressionLevel = Faster
+	case "better":
+		options.CompressionLevel = Better
+	default:
+		return nil, invalidConfigValue("producer.compressionLevel", c.CompressionLevel)
+	}
+
+	switch strings.ToLower(c.BatchingKeyEviction) {
+	case "", "largest":
+		options.BatchingKeyEviction = EvictLargestKeyBatch
+	case "oldest":
+		options.BatchingKeyEviction = EvictOldestKeyBatch
+	default:
+		return nil, invalidConfigValue("producer.batchingKeyEviction", c.BatchingKeyEviction)
+	}
+	return options, nil
+}
+
+func (c *consumerConfig) toConsumerOptions() (*ConsumerOptions, error) {
+	options := &ConsumerOptions{
+		AutoDiscoveryPeriod:            time.Duration(c.AutoDiscoveryPeriod),
+		ReceiverQueueSize:              c.ReceiverQueueSize,
+		NackRedeliveryDelay:            time.Duration(c.NackRedeliveryDelay),
+		ReadCompacted:                  c.ReadCompacted,
+		ReplicateSubscriptionState:     c.ReplicateSubscriptionState,
+		RetryEnable:                    c.RetryEnable,
+		EnableDefaultNackBackoffPolicy: c.EnableDefaultNackBackoffPolicy,
+		AckWithResponse:                c.AckWithResponse,
+		MaxPendingChunkedMessage:       c.MaxPendingChunkedMessage,
+		MaxPendingChunkedMessageBytes:  c.MaxPendingChunkedMessageBytes,
+		ExpireTimeOfIncompleteChunk:    time.Duration(c.ExpireTimeOfIncompleteChunk),
+		AutoAckIncompleteChunk:         c.AutoAckIncompleteChunk,
+		EnableBatchIndexAcknowledgment: c.EnableBatchIndexAcknowledgment,
+		DecodeKafkaEntries:             c.DecodeKafkaEntries,
+		ValidateStartMessageID:         c.ValidateStartMessageID,
+		OversizedMessageThreshold:      c.OversizedMessageThreshold,
+		RateLimit: RateLimit{
+			MessagesPerSecond: c.RateLimit.MessagesPerSecond,
+			BytesPerSecond:    c.RateLimit.BytesPerSecond,
+		},
+	}
+
+	switch strings.ToLower(c.Type) {
+	case "", "exclusive":
+		options.Type = Exclusive
+	case "shared":
+		options.Type = Shared
+	case "failover":
+		options.Type = Failover
+	case "key_shared", "keyshared":
+		options.Type = KeyShared
+	default:
+		return nil, invalidConfigValue("consumer.type", c.Type)
+	}
+
+	switch strings.ToLower(c.SubscriptionInitialPosition) {
+	case "", "latest":
+		options.SubscriptionInitialPosition = SubscriptionPositionLatest
+	case "earliest":
+		options.SubscriptionInitialPosition = SubscriptionPositionEarliest
+	default:
+		return nil, invalidConfigValue("consumer.subscriptionInitialPosition", c.SubscriptionInitialPosition)
+	}
+
+	switch strings.ToLower(c.SubscriptionMode) {
+	case "", "durable":
+		options.SubscriptionMode = Durable
+	case "non_durable", "nondurable":
+		options.SubscriptionMode = NonDurable
+	default:
+		return nil, invalidConfigValue("consumer.subscriptionMode", c.SubscriptionMode)
+	}
+
+	switch strings.ToLower(c.PartitionDispatch) {
+	case "", "strict_throughput", "strictthroughput":
+		options.PartitionDispatch = StrictThroughputDispatch
+	case "fair":
+		options.PartitionDispatch = FairDispatch
+	default:
+		return nil, invalidConfigValue("consumer.partitionDispatch", c.PartitionDispatch)
+	}
+	return options, nil
+}
+
+func invalidConfigValue(key, value string) error {
+	return newError(InvalidConfiguration, fmt.Sprintf("invalid client configuration: unknown %s '%s'", key, value))
+}
+
+// explicitZeroFields is the field of the options listing the fields whose zero value is not replaced by the defaults
+const explicitZeroFields = "ExplicitZeroFields"
+
+// applyDefaultOptions sets the fields of the options left to their zero value to the value in defaults, except the
+// fields listed in the ExplicitZeroFields of the options or of the defaults, which are merged.
+// options and defaults are pointers to structs of the same type.
+func applyDefaultOptions(options, defaults interface{}) {
+	dst := reflect.ValueOf(options).Elem()
+	src := reflect.ValueOf(defaults).Elem()
+
+	explicit := make(map[string]bool)
+	var names []string
+	for _, v := range []reflect.Value{dst, src} {
+		for _, name := range v.FieldByName(explicitZeroFields).Interface().([]string) {
+			if !explicit[name] {
+				explicit[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+
+	for i := 0; i < dst.NumField(); i++ {
+		field := dst.Field(i)
+		name := dst.Type().Field(i).Name
+		if name == explicitZeroFields {
+			field.Set(reflect.ValueOf(names))
+			continue
+		}
+		if field.CanSet() && field.IsZero() && !explicit[name] {
+			field.Set(src.Field(i))
+		}
+	}
+}
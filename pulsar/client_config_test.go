@@ -0,0 +1,197 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsar
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/apache/pulsar-client-go/pulsar/auth"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpandConfigEnv(t *testing.T) {
+	os.Setenv("PULSAR_TEST_CONFIG_SET", "value")
+	os.Setenv("PULSAR_TEST_CONFIG_EMPTY", "")
+	defer os.Unsetenv("PULSAR_TEST_CONFIG_SET")
+	defer os.Unsetenv("PULSAR_TEST_CONFIG_EMPTY")
+
+	assert.Equal(t, "a value b", string(expandConfigEnv([]byte("a ${PULSAR_TEST_CONFIG_SET} b"))))
+	assert.Equal(t, "value", string(expandConfigEnv([]byte("${PULSAR_TEST_CONFIG_SET:-default}"))))
+	assert.Equal(t, "", string(expandConfigEnv([]byte("${PULSAR_TEST_CONFIG_EMPTY}"))))
+	assert.Equal(t, "default", string(expandConfigEnv([]byte("${PULSAR_TEST_CONFIG_EMPTY:-default}"))))
+	assert.Equal(t, "default", string(expandConfigEnv([]byte("${PULSAR_TEST_CONFIG_UNSET:-default}"))))
+	assert.Equal(t, "$HOME", string(expandConfigEnv([]byte("$HOME"))))
+}
+
+func TestParseClientConfigYAML(t *testing.T) {
+	os.Setenv("PULSAR_TEST_CONFIG_TOKEN", "my-token")
+	defer os.Unsetenv("PULSAR_TEST_CONFIG_TOKEN")
+
+	options, err := parseClientConfig([]byte(`
+url: pulsar://localhost:6650
+operationTimeout: 45s
+operationTimeouts:
+  seek: 5s
+  flush: 1m
+connectionTimeout: 3s
+maxConnectionsPerBroker: 2
+stickyReconnect: true
+metricsCardinality: topic
+customMetricsLabels:
+  app: test
+memoryLimitBytes: 1048576
+authentication:
+  name: token
+  params:
+    token: ${PULSAR_TEST_CONFIG_TOKEN}
+producer:
+  compressionType: zstd
+  compressionLevel: better
+  batchingMaxPublishDelay: 5ms
+  maxPendingMessages: 100
+consumer:
+  type: key_shared
+  subscriptionInitialPosition: earliest
+  receiverQueueSize: 50
+  nackRedeliveryDelay: 10s
+  oversizedMessageThreshold: 2097152
+  rateLimit:
+    bytesPerSecond: 65536
+`))
+	assert.Nil(t, err)
+	assert.Equal(t, "pulsar://localhost:6650", options.URL)
+	assert.Equal(t, 45*time.Second, options.OperationTimeout)
+	assert.Equal(t, OperationTimeouts{Seek: 5 * time.Second, Flush: time.Minute}, options.OperationTimeouts)
+	assert.Equal(t, 3*time.Second, options.ConnectionTimeout)
+	assert.Equal(t, 2, options.MaxConnectionsPerBroker)
+	assert.True(t, options.StickyReconnect)
+	assert.Equal(t, MetricsCardinalityTopic, options.MetricsCardinality)
+	assert.Equal(t, map[string]string{"app": "test"}, options.CustomMetricsLabels)
+	assert.Equal(t, int64(1048576), options.MemoryLimitBytes)
+
+	assert.NotNil(t, options.Authentication)
+	data, err := options.Authentication.(auth.Provider).GetData()
+	assert.Nil(t, err)
+	assert.Equal(t, "my-token", string(data))
+
+	assert.NotNil(t, options.DefaultProducerOptions)
+	assert.Equal(t, ZSTD, options.DefaultProducerOptions.CompressionType)
+	assert.Equal(t, Better, options.DefaultProducerOptions.CompressionLevel)
+	assert.Equal(t, 5*time.Millisecond, options.DefaultProducerOptions.BatchingMaxPublishDelay)
+	assert.Equal(t, 100, options.DefaultProducerOptions.MaxPendingMessages)
+
+	assert.NotNil(t, options.DefaultConsumerOptions)
+	assert.Equal(t, KeyShared, options.DefaultConsumerOptions.Type)
+	assert.Equal(t, SubscriptionPositionEarliest, options.DefaultConsumerOptions.SubscriptionInitialPosition)
+	assert.Equal(t, 50, options.DefaultConsumerOptions.ReceiverQueueSize)
+	assert.Equal(t, 10*time.Second, options.DefaultConsumerOptions.NackRedeliveryDelay)
+	assert.Equal(t, RateLimit{BytesPerSecond: 65536}, options.DefaultConsumerOptions.RateLimit)
+	assert.Equal(t, 2097152, options.DefaultConsumerOptions.OversizedMessageThreshold)
+}
+
+func TestParseClientConfigJSON(t *testing.T) {
+	options, err := parseClientConfig([]byte(`{
+		"url": "pulsar://localhost:6650",
+		"keepAliveInterval": "1m",
+		"authentication": {"name": "token", "params": "{\"token\": \"json-token\"}"}
+	}`))
+	assert.Nil(t, err)
+	assert.Equal(t, "pulsar://localhost:6650", options.URL)
+	assert.Equal(t, time.Minute, options.KeepAliveInterval)
+	assert.Nil(t, options.DefaultProducerOptions)
+	assert.Nil(t, options.DefaultConsumerOptions)
+
+	data, err := options.Authentication.(auth.Provider).GetData()
+	assert.Nil(t, err)
+	assert.Equal(t, "json-token", string(data))
+}
+
+func TestParseClientConfigErrors(t *testing.T) {
+	configs := []string{
+		"url: [",
+		"unknownOption: 1",
+		"operationTimeout: 30",
+		"operationTimeout: soon",
+		"metricsCardinality: partition",
+		"authentication:\n  name: unknown",
+		"producer:\n  compressionType: gzip",
+		"producer:\n  batchingKeyEviction: newest",
+		"consumer:\n  type: queue",
+		"consumer:\n  subscriptionMode: temporary",
+		"consumer:\n  partitionDispatch: random",
+	}
+	for _, config := range configs {
+		_, err := parseClientConfig([]byte(config))
+		assert.Error(t, err, config)
+		assert.Equal(t, InvalidConfiguration, err.(*Error).Result(), config)
+	}
+}
+
+func TestLoadClientOptionsFromEnv(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "client.yaml")
+	assert.Nil(t, os.WriteFile(path, []byte("url: pulsar://from-env:6650\n"), 0600))
+
+	os.Setenv(ClientConfigEnv, path)
+	defer os.Unsetenv(ClientConfigEnv)
+
+	options, err := LoadClientOptions("")
+	assert.Nil(t, err)
+	assert.Equal(t, "pulsar://from-env:6650", options.URL)
+
+	os.Unsetenv(ClientConfigEnv)
+	_, err = LoadClientOptions("")
+	assert.Error(t, err)
+}
+
+func TestApplyDefaultOptions(t *testing.T) {
+	defaults := &ConsumerOptions{
+		Type:              Shared,
+		ReceiverQueueSize: 50,
+		Name:              "default-name",
+	}
+	options := ConsumerOptions{
+		Topic:             "my-topic",
+		ReceiverQueueSize: 10,
+	}
+	applyDefaultOptions(&options, defaults)
+
+	assert.Equal(t, "my-topic", options.Topic)
+	assert.Equal(t, Shared, options.Type)
+	assert.Equal(t, 10, options.ReceiverQueueSize)
+	assert.Equal(t, "default-name", options.Name)
+
+	// the zero values set on purpose are kept
+	defaults.SubscriptionInitialPosition = SubscriptionPositionEarliest
+	defaults.ExplicitZeroFields = []string{"ReceiverQueueSize"}
+	options = ConsumerOptions{ExplicitZeroFields: []string{"Type", "SubscriptionInitialPosition"}}
+	applyDefaultOptions(&options, defaults)
+
+	assert.Equal(t, Exclusive, options.Type)
+	assert.Equal(t, SubscriptionPositionLatest, options.SubscriptionInitialPosition)
+	assert.Equal(t, 0, options.ReceiverQueueSize)
+	assert.Equal(t, "default-name", options.Name)
+	assert.Equal(t, []string{"Type", "SubscriptionInitialPosition", "ReceiverQueueSize"}, options.ExplicitZeroFields)
+
+	producerOptions := ProducerOptions{ExplicitZeroFields: []string{"CompressionType", "DisableBatching"}}
+	applyDefaultOptions(&producerOptions, &ProducerOptions{CompressionType: ZSTD, DisableBatching: true})
+	assert.Equal(t, NoCompression, producerOptions.CompressionType)
+	assert.False(t, producerOptions.DisableBatching)
+}
@@ -18,12 +18,15 @@
 package pulsar
 
 import (
+	"crypto/tls"
 	"fmt"
 	"net/url"
+	"sync"
 	"time"
 
 	"github.com/apache/pulsar-client-go/pulsar/auth"
 	"github.com/apache/pulsar-client-go/pulsar/internal"
+	pb "github.com/apache/pulsar-client-go/pulsar/internal/pulsar_proto"
 	"github.com/apache/pulsar-client-go/pulsar/log"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/sirupsen/logrus"
@@ -36,6 +39,10 @@ const (
 	defaultMemoryLimitBytes  = 64 * 1024 * 1024
 	defaultConnMaxIdleTime   = 180 * time.Second
 	minConnMaxIdleTime       = 60 * time.Second
+
+	defaultTLSSessionCacheSize         = 64
+	defaultMaxConcurrentLookupRequests = 5000
+	defaultTLSCertificateExpiryWarning = 30 * 24 * time.Hour
 )
 
 type client struct {
@@ -46,6 +53,42 @@ type client struct {
 	metrics       *internal.Metrics
 	tcClient      *transactionCoordinatorClient
 	memLimit      internal.MemoryLimitController
+	fipsMode      bool
+	admin         Admin
+	// adminHTTPClient is the HTTP client of ClientOptions.AdminURL, closed with the client
+	adminHTTPClient internal.HTTPClient
+
+	topicExistenceCheck bool
+	producerDefaults    *ProducerOptions
+	consumerDefaults    *ConsumerOptions
+	namespaceDefaults   *namespaceDefaults
+	reload              *clientReloadState
+	propagators         []ContextPropagator
+	audit               *auditLog
+	schemas             *schemaStore
+	schemaCacheFile     string
+	producerCache       *producerCache
+	authProviders       *authProviders
+	callbackExecutor    *orderedExecutor
+
+	topicUnloadGracePeriod time.Duration
+	topicUnloadListener    TopicUnloadListener
+
+	// clockSkewWarning is the skew between the publish times and the broker times the consumers warn about,
+	// 0 when disabled
+	clockSkewWarning time.Duration
+
+	// stickyReconnect reconnects the producers and consumers to the broker they were connected to first
+	stickyReconnect bool
+
+	// flushTimeout bounds the flushes of the producers, 0 when not bounded
+	flushTimeout time.Duration
+
+	// retryBudget bounds the reconnections of the producers and consumers, nil when disabled
+	retryBudget *internal.RetryBudget
+
+	// metricsExport exports the metrics to the MetricsExporter, nil without exporter
+	metricsExport *metricsExport
 
 	log log.Logger
 }
@@ -78,18 +121,32 @@ func newClient(options ClientOptions) (Client, error) {
 		return nil, newError(InvalidConfiguration, "Invalid service URL")
 	}
 
+	audit, err := newAuditLog(options.AuditLogger, options.AuditSamplingRate)
+	if err != nil {
+		return nil, err
+	}
+
+	fipsMode := options.EnableFIPSMode || internal.FIPSBuild
+	if fipsMode && options.TLSAllowInsecureConnection {
+		return nil, newError(InvalidConfiguration, "TLSAllowInsecureConnection can not be enabled in FIPS mode")
+	}
+
 	var tlsConfig *internal.TLSOptions
 	switch url.Scheme {
 	case "pulsar", "http":
 		tlsConfig = nil
 	case "pulsar+ssl", "https":
 		tlsConfig = &internal.TLSOptions{
-			AllowInsecureConnection: options.TLSAllowInsecureConnection,
-			KeyFile:                 options.TLSKeyFilePath,
-			CertFile:                options.TLSCertificateFile,
-			TrustCertsFilePath:      options.TLSTrustCertsFilePath,
-			ValidateHostname:        options.TLSValidateHostname,
-			ServerName:              url.Hostname(),
+			AllowInsecureConnection:  options.TLSAllowInsecureConnection,
+			KeyFile:                  options.TLSKeyFilePath,
+			CertFile:                 options.TLSCertificateFile,
+			KeySigner:                options.TLSKeySigner,
+			TrustCertsFilePath:       options.TLSTrustCertsFilePath,
+			ValidateHostname:         options.TLSValidateHostname,
+			ServerName:               url.Hostname(),
+			FIPSMode:                 fipsMode,
+			SessionCache:             newTLSSessionCache(options.TLSSessionCacheSize),
+			CertificateExpiryWarning: certificateExpiryWarning(options.TLSCertificateExpiryWarning),
 		}
 	default:
 		return nil, newError(InvalidConfiguration, fmt.Sprintf("Invalid URL scheme '%s'", url.Scheme))
@@ -126,22 +183,12 @@ func newClient(options ClientOptions) (Client, error) {
 		maxConnectionsPerHost = 1
 	}
 
-	if options.MetricsCardinality == 0 {
-		options.MetricsCardinality = MetricsCardinalityNamespace
-	}
-
-	if options.MetricsRegisterer == nil {
-		options.MetricsRegisterer = prometheus.DefaultRegisterer
+	maxConcurrentLookups := options.MaxConcurrentLookupRequests
+	if maxConcurrentLookups == 0 {
+		maxConcurrentLookups = defaultMaxConcurrentLookupRequests
 	}
 
-	var metrics *internal.Metrics
-	if options.CustomMetricsLabels != nil {
-		metrics = internal.NewMetricsProvider(
-			int(options.MetricsCardinality), options.CustomMetricsLabels, options.MetricsRegisterer)
-	} else {
-		metrics = internal.NewMetricsProvider(
-			int(options.MetricsCardinality), map[string]string{}, options.MetricsRegisterer)
-	}
+	metrics := newMetricsProvider(options, nil)
 
 	keepAliveInterval := options.KeepAliveInterval
 	if keepAliveInterval.Nanoseconds() == 0 {
@@ -155,19 +202,52 @@ func newClient(options ClientOptions) (Client, error) {
 
 	c := &client{
 		cnxPool: internal.NewConnectionPool(tlsConfig, authProvider, connectionTimeout, keepAliveInterval,
-			maxConnectionsPerHost, logger, metrics, connectionMaxIdleTime),
+			maxConnectionsPerHost, logger, metrics, connectionMaxIdleTime, options.IOThreads,
+			internal.EndpointFilter(options.EndpointFilter)),
 		log:      logger,
 		metrics:  metrics,
 		memLimit: internal.NewMemoryLimitController(memLimitBytes),
+		fipsMode: fipsMode,
+
+		topicExistenceCheck: options.EnableTopicExistenceCheck,
+		producerDefaults:    options.DefaultProducerOptions,
+		consumerDefaults:    options.DefaultConsumerOptions,
+		namespaceDefaults:   newNamespaceDefaults(options.NamespaceProducerOptions, options.NamespaceConsumerOptions),
+		reload:              newClientReloadState(options, nil, metrics),
+		propagators:         options.ContextPropagators,
+		audit:               audit,
+		schemas:             newSchemaStore(),
+		schemaCacheFile:     options.SchemaCacheFile,
+		authProviders:       &authProviders{initialized: make(map[auth.Provider]struct{})},
+		callbackExecutor:    newOrderedExecutor(options.CallbackThreads, options.CallbackQueueSize),
+
+		topicUnloadGracePeriod: options.TopicUnloadGracePeriod,
+		topicUnloadListener:    options.TopicUnloadListener,
+		clockSkewWarning:       clockSkewWarning(options.ClockSkewWarning),
+		flushTimeout:           options.OperationTimeouts.Flush,
+		stickyReconnect:        options.StickyReconnect,
+	}
+	if c.topicUnloadGracePeriod == 0 {
+		c.topicUnloadGracePeriod = defaultTopicUnloadGracePeriod
+	}
+	c.producerCache = newProducerCache(options.ProducerCacheIdleTime, c.createCachedProducer)
+	if c.schemaCacheFile != "" {
+		if err := c.schemas.loadFile(c.schemaCacheFile); err != nil {
+			logger.WithError(err).Warnf("Failed to import the schema cache from %s", c.schemaCacheFile)
+		}
 	}
 	serviceNameResolver := internal.NewPulsarServiceNameResolver(url)
 
-	c.rpcClient = internal.NewRPCClient(url, serviceNameResolver, c.cnxPool, operationTimeout, logger, metrics)
+	c.retryBudget = newRetryBudget(options, metrics)
+	c.rpcClient = internal.NewRPCClient(url, serviceNameResolver, c.cnxPool, operationTimeout,
+		options.OperationTimeouts.requestTimeouts(), logger, metrics, c.retryBudget,
+		newCircuitBreakers(options, logger, metrics))
 
 	switch url.Scheme {
 	case "pulsar", "pulsar+ssl":
 		c.lookupService = internal.NewLookupService(c.rpcClient, url, serviceNameResolver,
-			tlsConfig != nil, options.ListenerName, logger, metrics)
+			tlsConfig != nil, options.ListenerName, maxConcurrentLookups, options.MaxLookupRedirects, operationTimeout,
+			logger, metrics)
 	case "http", "https":
 		httpClient, err := internal.NewHTTPClient(url, serviceNameResolver, tlsConfig,
 			operationTimeout, logger, metrics, authProvider)
@@ -177,10 +257,35 @@ func newClient(options ClientOptions) (Client, error) {
 		}
 		c.lookupService = internal.NewHTTPLookupService(httpClient, url, serviceNameResolver,
 			tlsConfig != nil, logger, metrics)
+		c.admin = newAdmin(httpClient)
 	default:
 		return nil, newError(InvalidConfiguration, fmt.Sprintf("Invalid URL scheme '%s'", url.Scheme))
 	}
 
+	if len(options.LookupOverrides) > 0 {
+		overrides, err := toInternalLookupOverrides(options.LookupOverrides)
+		if err != nil {
+			return nil, err
+		}
+		c.lookupService = internal.NewLookupServiceWithOverrides(c.lookupService, overrides)
+	}
+
+	if options.LookupServiceWrapper != nil {
+		c.lookupService = newPluggableLookupService(c.lookupService, options.LookupServiceWrapper)
+	}
+
+	if options.AdminURL != "" {
+		admin, err := newAdminFromURL(options, fipsMode, operationTimeout, authProvider, logger, metrics)
+		if err != nil {
+			return nil, err
+		}
+		c.admin = admin
+		c.adminHTTPClient = admin.httpClient
+	} else if c.admin == nil {
+		c.admin = newUnavailableAdmin(newError(InvalidConfiguration,
+			"admin operations require an http(s) service URL, set ClientOptions.AdminURL"))
+	}
+
 	c.handlers = internal.NewClientHandlers()
 
 	if options.EnableTransaction {
@@ -191,10 +296,152 @@ func newClient(options ClientOptions) (Client, error) {
 		}
 	}
 
+	c.metricsExport, err = newMetricsExport(options, logger)
+	if err != nil {
+		return nil, err
+	}
+
 	return c, nil
 }
 
+// requestTimeouts returns the timeouts by command type overriding the operation timeout
+func (t OperationTimeouts) requestTimeouts() map[pb.BaseCommand_Type]time.Duration {
+	timeouts := make(map[pb.BaseCommand_Type]time.Duration)
+	for cmdType, timeout := range map[pb.BaseCommand_Type]time.Duration{
+		pb.BaseCommand_SUBSCRIBE:           t.Subscribe,
+		pb.BaseCommand_PRODUCER:            t.CreateProducer,
+		pb.BaseCommand_SEEK:                t.Seek,
+		pb.BaseCommand_UNSUBSCRIBE:         t.Unsubscribe,
+		pb.BaseCommand_GET_LAST_MESSAGE_ID: t.GetLastMessageID,
+	} {
+		if timeout > 0 {
+			timeouts[cmdType] = timeout
+		}
+	}
+	return timeouts
+}
+
+func certificateExpiryWarning(warning time.Duration) time.Duration {
+	if warning == 0 {
+		return defaultTLSCertificateExpiryWarning
+	}
+	if warning < 0 {
+		return 0
+	}
+	return warning
+}
+
+func newTLSSessionCache(size int) tls.ClientSessionCache {
+	if size < 0 {
+		return nil
+	}
+	if size == 0 {
+		size = defaultTLSSessionCacheSize
+	}
+	return tls.NewLRUClientSessionCache(size)
+}
+
+func toInternalLookupOverrides(overrides map[string]LookupOverride) (map[string]internal.LookupOverride, error) {
+	res := make(map[string]internal.LookupOverride, len(overrides))
+	for topic, override := range overrides {
+		tn, err := internal.ParseTopicName(topic)
+		if err != nil {
+			return nil, newError(InvalidConfiguration, fmt.Sprintf("Invalid lookup override topic '%s'", topic))
+		}
+
+		if override.Err != nil {
+			res[tn.Name] = internal.LookupOverride{Err: override.Err}
+			continue
+		}
+
+		brokerURL, err := url.ParseRequestURI(override.BrokerServiceURL)
+		if err != nil {
+			return nil, newError(InvalidConfiguration,
+				fmt.Sprintf("Invalid lookup override broker URL '%s'", override.BrokerServiceURL))
+		}
+		physicalURL := brokerURL
+		if override.ProxyServiceURL != "" {
+			physicalURL, err = url.ParseRequestURI(override.ProxyServiceURL)
+			if err != nil {
+				return nil, newError(InvalidConfiguration,
+					fmt.Sprintf("Invalid lookup override proxy URL '%s'", override.ProxyServiceURL))
+			}
+		}
+		res[tn.Name] = internal.LookupOverride{
+			Result: &internal.LookupResult{
+				LogicalAddr:  brokerURL,
+				PhysicalAddr: physicalURL,
+			},
+		}
+	}
+	return res, nil
+}
+
+// newMetricsProvider creates the metrics provider of the client, extraLabels are added to the custom labels
+func newMetricsProvider(options ClientOptions, extraLabels map[string]string) *internal.Metrics {
+	if options.MetricsCardinality == 0 {
+		options.MetricsCardinality = MetricsCardinalityNamespace
+	}
+
+	if options.MetricsRegisterer == nil {
+		options.MetricsRegisterer = prometheus.DefaultRegisterer
+	}
+
+	labels := make(map[string]string, len(options.CustomMetricsLabels)+len(extraLabels))
+	for k, v := range options.CustomMetricsLabels {
+		labels[k] = v
+	}
+	for k, v := range extraLabels {
+		labels[k] = v
+	}
+	return internal.NewMetricsProvider(int(options.MetricsCardinality), labels, options.MetricsRegisterer)
+}
+
+func newAdminFromURL(options ClientOptions, fipsMode bool, operationTimeout time.Duration,
+	authProvider auth.Provider, logger log.Logger, metrics *internal.Metrics) (*admin, error) {
+	adminURL, err := url.Parse(options.AdminURL)
+	if err != nil {
+		logger.WithError(err).Error("Failed to parse admin URL")
+		return nil, newError(InvalidConfiguration, "Invalid admin URL")
+	}
+
+	var tlsConfig *internal.TLSOptions
+	switch adminURL.Scheme {
+	case "http":
+		tlsConfig = nil
+	case "https":
+		tlsConfig = &internal.TLSOptions{
+			AllowInsecureConnection: options.TLSAllowInsecureConnection,
+			KeyFile:                 options.TLSKeyFilePath,
+			CertFile:                options.TLSCertificateFile,
+			KeySigner:               options.TLSKeySigner,
+			TrustCertsFilePath:      options.TLSTrustCertsFilePath,
+			ValidateHostname:        options.TLSValidateHostname,
+			ServerName:              adminURL.Hostname(),
+			FIPSMode:                fipsMode,
+		}
+	default:
+		return nil, newError(InvalidConfiguration, fmt.Sprintf("Invalid admin URL scheme '%s'", adminURL.Scheme))
+	}
+
+	httpClient, err := internal.NewHTTPClient(adminURL, internal.NewPulsarServiceNameResolver(adminURL), tlsConfig,
+		operationTimeout, logger, metrics, authProvider)
+	if err != nil {
+		return nil, newError(InvalidConfiguration, fmt.Sprintf("Failed to init admin http client with err: '%s'",
+			err.Error()))
+	}
+	return newAdmin(httpClient), nil
+}
+
+func (c *client) Admin() Admin {
+	return c.admin
+}
+
 func (c *client) CreateProducer(options ProducerOptions) (Producer, error) {
+	c.namespaceDefaults.applyProducer(&options)
+	if c.producerDefaults != nil {
+		applyDefaultOptions(&options, c.producerDefaults)
+	}
 	producer, err := newProducer(c, &options)
 	if err == nil {
 		c.handlers.Add(producer)
@@ -203,6 +450,10 @@ func (c *client) CreateProducer(options ProducerOptions) (Producer, error) {
 }
 
 func (c *client) Subscribe(options ConsumerOptions) (Consumer, error) {
+	c.namespaceDefaults.applyConsumer(&options)
+	if c.consumerDefaults != nil {
+		applyDefaultOptions(&options, c.consumerDefaults)
+	}
 	consumer, err := newConsumer(c, options)
 	if err != nil {
 		return nil, err
@@ -211,6 +462,36 @@ func (c *client) Subscribe(options ConsumerOptions) (Consumer, error) {
 	return consumer, nil
 }
 
+func (c *client) SubscribeToDLQ(originalTopic, subscription string, options ConsumerOptions) (Consumer, error) {
+	if originalTopic == "" || subscription == "" {
+		return nil, newError(InvalidConfiguration, "the original topic and subscription are required")
+	}
+	if options.Topic != "" || len(options.Topics) > 0 || options.TopicsPattern != "" {
+		return nil, newError(InvalidConfiguration, "the topic of the dead letter consumer is derived, "+
+			"it can not be set in the options")
+	}
+
+	dlqTopic := ""
+	if options.DLQ != nil {
+		dlqTopic = options.DLQ.DeadLetterTopic
+	}
+	if dlqTopic == "" {
+		var err error
+		_, dlqTopic, err = defaultRetryAndDeadLetterTopics(c, originalTopic, subscription)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	options.Topic = dlqTopic
+	options.DLQ = nil
+	options.RetryEnable = false
+	if options.SubscriptionName == "" {
+		options.SubscriptionName = subscription
+	}
+	return c.Subscribe(options)
+}
+
 func (c *client) CreateReader(options ReaderOptions) (Reader, error) {
 	reader, err := newReader(c, options)
 	if err != nil {
@@ -229,6 +510,20 @@ func (c *client) CreateTableView(options TableViewOptions) (TableView, error) {
 	return tableView, nil
 }
 
+func (c *client) NewTransaction(timeout time.Duration) (Transaction, error) {
+	if c.tcClient == nil {
+		return nil, newError(InvalidConfiguration, "transaction is not enabled, set ClientOptions.EnableTransaction")
+	}
+	if timeout <= 0 {
+		timeout = defaultTransactionTimeout
+	}
+	id, err := c.tcClient.newTransaction(timeout)
+	if err != nil {
+		return nil, err
+	}
+	return newTransaction(*id, c.tcClient, timeout), nil
+}
+
 func (c *client) TopicPartitions(topic string) ([]string, error) {
 	topicName, err := internal.ParseTopicName(topic)
 	if err != nil {
@@ -253,8 +548,64 @@ func (c *client) TopicPartitions(topic string) ([]string, error) {
 	return []string{topicName.Name}, nil
 }
 
+// authProviders are the initialized providers of the Authentication of the producers and consumers
+type authProviders struct {
+	sync.Mutex
+	initialized map[auth.Provider]struct{}
+}
+
+// initAuth returns the provider of the Authentication of a producer or consumer, initialized on first use. It
+// returns nil for a nil Authentication, the producer or consumer uses the authentication of the client.
+func (c *client) initAuth(authentication Authentication) (auth.Provider, error) {
+	if authentication == nil {
+		return nil, nil
+	}
+	provider, ok := authentication.(auth.Provider)
+	if !ok {
+		return nil, newError(AuthenticationError, "invalid auth provider interface")
+	}
+
+	c.authProviders.Lock()
+	defer c.authProviders.Unlock()
+	if _, ok := c.authProviders.initialized[provider]; !ok {
+		if err := provider.Init(); err != nil {
+			return nil, err
+		}
+		c.authProviders.initialized[provider] = struct{}{}
+	}
+	return provider, nil
+}
+
+// authProviderOf returns the provider of an Authentication validated by initAuth
+func authProviderOf(authentication Authentication) auth.Provider {
+	provider, _ := authentication.(auth.Provider)
+	return provider
+}
+
+// withResources returns a client sharing the connections of c, with its own set of producers, consumers and
+// readers reported to the metrics of the given reload state
+func (c *client) withResources(reload *clientReloadState) *client {
+	owned := *c
+	owned.handlers = internal.NewClientHandlers()
+	owned.producerCache = newProducerCache(c.producerCache.idleTime, owned.createCachedProducer)
+	owned.metrics = reload.metrics
+	owned.reload = reload
+	return &owned
+}
+
 func (c *client) Close() {
+	c.producerCache.close()
 	c.handlers.Close()
 	c.cnxPool.Close()
 	c.lookupService.Close()
+	if c.adminHTTPClient != nil {
+		c.adminHTTPClient.Close()
+	}
+	c.callbackExecutor.close()
+	if c.schemaCacheFile != "" {
+		if err := c.schemas.saveFile(c.schemaCacheFile); err != nil {
+			c.log.WithError(err).Warnf("Failed to export the schema cache to %s", c.schemaCacheFile)
+		}
+	}
+	c.metricsExport.close()
 }
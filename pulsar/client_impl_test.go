@@ -20,6 +20,7 @@ package pulsar
 import (
 	"context"
 	"crypto/tls"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -31,6 +32,7 @@ import (
 
 	"github.com/apache/pulsar-client-go/pulsar/auth"
 	"github.com/apache/pulsar-client-go/pulsar/internal"
+	pb "github.com/apache/pulsar-client-go/pulsar/internal/pulsar_proto"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -209,6 +211,72 @@ func TestTokenAuth(t *testing.T) {
 	client.Close()
 }
 
+func TestProducerConsumerAuthentication(t *testing.T) {
+	token, err := os.ReadFile(tokenFilePath)
+	assert.NoError(t, err)
+
+	client, err := NewClient(ClientOptions{
+		URL: serviceURL,
+	})
+	assert.NoError(t, err)
+	defer client.Close()
+
+	topic := newAuthTopicName()
+
+	// the client itself is not authorized on the topic
+	_, err = client.CreateProducer(ProducerOptions{
+		Topic: topic,
+	})
+	assert.Error(t, err)
+
+	authentication := NewAuthenticationToken(string(token))
+	producer, err := client.CreateProducer(ProducerOptions{
+		Topic:          topic,
+		Authentication: authentication,
+	})
+	assert.NoError(t, err)
+	defer producer.Close()
+
+	consumer, err := client.Subscribe(ConsumerOptions{
+		Topic:            topic,
+		SubscriptionName: "my-sub",
+		Authentication:   authentication,
+	})
+	assert.NoError(t, err)
+	defer consumer.Close()
+}
+
+type initCountingProvider struct {
+	auth.Provider
+	inits int
+}
+
+func (p *initCountingProvider) Init() error {
+	p.inits++
+	return nil
+}
+
+func TestClientInitAuth(t *testing.T) {
+	c := &client{authProviders: &authProviders{initialized: make(map[auth.Provider]struct{})}}
+
+	provider, err := c.initAuth(nil)
+	assert.NoError(t, err)
+	assert.Nil(t, provider)
+
+	counting := &initCountingProvider{}
+	for i := 0; i < 3; i++ {
+		provider, err = c.initAuth(counting)
+		assert.NoError(t, err)
+		assert.Equal(t, counting, provider)
+	}
+	assert.Equal(t, 1, counting.inits)
+	assert.Equal(t, counting, authProviderOf(counting))
+
+	_, err = c.initAuth("token")
+	assert.Error(t, err)
+	assert.Equal(t, AuthenticationError, err.(*Error).Result())
+}
+
 func TestTokenAuthWithSupplier(t *testing.T) {
 	client, err := NewClient(ClientOptions{
 		URL: serviceURL,
@@ -1238,3 +1306,84 @@ func TestAutoCloseIdleConnection(t *testing.T) {
 
 	cli.Close()
 }
+
+func TestFIPSModeInsecureConnection(t *testing.T) {
+	client, err := NewClient(ClientOptions{
+		URL:                        serviceURLTLS,
+		TLSAllowInsecureConnection: true,
+		EnableFIPSMode:             true,
+	})
+	assert.Nil(t, client)
+	assert.NotNil(t, err)
+	assert.Equal(t, InvalidConfiguration, err.(*Error).Result())
+}
+
+func TestLookupOverrides(t *testing.T) {
+	lookupErr := errors.New("lookup failed")
+	c, err := NewClient(ClientOptions{
+		URL: "pulsar://localhost:6650",
+		LookupOverrides: map[string]LookupOverride{
+			"pinned": {
+				BrokerServiceURL: "pulsar://broker-1:6650",
+				ProxyServiceURL:  "pulsar://proxy:6650",
+			},
+			"persistent://public/default/failing": {Err: lookupErr},
+		},
+	})
+	assert.Nil(t, err)
+	defer c.Close()
+
+	ci := c.(*client)
+	res, err := ci.lookupService.Lookup("persistent://public/default/pinned-partition-2")
+	assert.Nil(t, err)
+	assert.Equal(t, "pulsar://broker-1:6650", res.LogicalAddr.String())
+	assert.Equal(t, "pulsar://proxy:6650", res.PhysicalAddr.String())
+
+	_, err = ci.lookupService.Lookup("failing")
+	assert.Equal(t, lookupErr, err)
+
+	_, err = NewClient(ClientOptions{
+		URL: "pulsar://localhost:6650",
+		LookupOverrides: map[string]LookupOverride{
+			"pinned": {BrokerServiceURL: "broker-1"},
+		},
+	})
+	assert.NotNil(t, err)
+	assert.Equal(t, InvalidConfiguration, err.(*Error).Result())
+}
+
+func TestOperationTimeoutsRequestTimeouts(t *testing.T) {
+	timeouts := OperationTimeouts{
+		Subscribe: 10 * time.Second,
+		Seek:      time.Second,
+		Flush:     time.Minute,
+	}.requestTimeouts()
+	assert.Equal(t, map[pb.BaseCommand_Type]time.Duration{
+		pb.BaseCommand_SUBSCRIBE: 10 * time.Second,
+		pb.BaseCommand_SEEK:      time.Second,
+	}, timeouts)
+	assert.Empty(t, OperationTimeouts{}.requestTimeouts())
+}
+
+func TestClientCloseAdminHTTPClient(t *testing.T) {
+	c, err := NewClient(ClientOptions{
+		URL:      "pulsar://localhost:6650",
+		AdminURL: "http://localhost:8080",
+	})
+	require.NoError(t, err)
+	require.NotNil(t, c.(*client).adminHTTPClient)
+
+	adminHTTPClient := &closeTrackingHTTPClient{}
+	c.(*client).adminHTTPClient = adminHTTPClient
+	c.Close()
+	assert.True(t, adminHTTPClient.closed)
+}
+
+type closeTrackingHTTPClient struct {
+	internal.HTTPClient
+	closed bool
+}
+
+func (c *closeTrackingHTTPClient) Close() {
+	c.closed = true
+}
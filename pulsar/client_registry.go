@@ -0,0 +1,161 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsar
+
+import (
+	"crypto"
+	"reflect"
+	"sync"
+)
+
+var sharedClients = &clientRegistry{
+	clients: make(map[sharedClientKey][]*sharedClient),
+}
+
+// sharedClientKey holds the options that identify the connections of a shared client,
+// the authentication and the TLS key signer are compared separately since they are not always comparable
+type sharedClientKey struct {
+	url                        string
+	listenerName               string
+	tlsTrustCertsFilePath      string
+	tlsCertificateFile         string
+	tlsKeyFilePath             string
+	tlsAllowInsecureConnection bool
+	tlsValidateHostname        bool
+	enableFIPSMode             bool
+}
+
+type sharedClient struct {
+	key            sharedClientKey
+	authentication Authentication
+	tlsKeySigner   crypto.Signer
+	client         *client
+	refCount       int
+}
+
+type clientRegistry struct {
+	sync.Mutex
+	clients map[sharedClientKey][]*sharedClient
+}
+
+func newSharedClientKey(options ClientOptions) sharedClientKey {
+	return sharedClientKey{
+		url:                        options.URL,
+		listenerName:               options.ListenerName,
+		tlsTrustCertsFilePath:      options.TLSTrustCertsFilePath,
+		tlsCertificateFile:         options.TLSCertificateFile,
+		tlsKeyFilePath:             options.TLSKeyFilePath,
+		tlsAllowInsecureConnection: options.TLSAllowInsecureConnection,
+		tlsValidateHostname:        options.TLSValidateHostname,
+		enableFIPSMode:             options.EnableFIPSMode,
+	}
+}
+
+func (r *clientRegistry) getOrCreate(options ClientOptions) (Client, error) {
+	r.Lock()
+	defer r.Unlock()
+
+	key := newSharedClientKey(options)
+	var shared *sharedClient
+	for _, s := range r.clients[key] {
+		if reflect.DeepEqual(s.authentication, options.Authentication) &&
+			reflect.DeepEqual(s.tlsKeySigner, options.TLSKeySigner) {
+			shared = s
+			break
+		}
+	}
+
+	if shared == nil {
+		c, err := newClient(withOwnerLabel(options))
+		if err != nil {
+			return nil, err
+		}
+		shared = &sharedClient{
+			key:            key,
+			authentication: options.Authentication,
+			tlsKeySigner:   options.TLSKeySigner,
+			client:         c.(*client),
+		}
+		r.clients[key] = append(r.clients[key], shared)
+	}
+	shared.refCount++
+
+	metrics := shared.client.metrics
+	var extraLabels map[string]string
+	if options.Owner != "" {
+		extraLabels = map[string]string{"owner": options.Owner}
+		metrics = newMetricsProvider(options, extraLabels)
+	}
+	return &sharedClientRef{
+		client:   shared.client.withResources(newClientReloadState(options, extraLabels, metrics)),
+		registry: r,
+		shared:   shared,
+	}, nil
+}
+
+// withOwnerLabel adds an empty owner label to the metrics of the underlying client, the metrics of the instances
+// with an Owner have the same labels so that they are registered along with them
+func withOwnerLabel(options ClientOptions) ClientOptions {
+	labels := make(map[string]string, len(options.CustomMetricsLabels)+1)
+	for k, v := range options.CustomMetricsLabels {
+		labels[k] = v
+	}
+	labels["owner"] = ""
+	options.CustomMetricsLabels = labels
+	return options
+}
+
+func (r *clientRegistry) release(shared *sharedClient) {
+	r.Lock()
+	shared.refCount--
+	if shared.refCount > 0 {
+		r.Unlock()
+		return
+	}
+
+	clients := r.clients[shared.key]
+	for i, s := range clients {
+		if s == shared {
+			clients = append(clients[:i], clients[i+1:]...)
+			break
+		}
+	}
+	if len(clients) == 0 {
+		delete(r.clients, shared.key)
+	} else {
+		r.clients[shared.key] = clients
+	}
+	r.Unlock()
+
+	shared.client.Close()
+}
+
+// sharedClientRef is an instance of a shared client returned by GetOrCreateClient
+type sharedClientRef struct {
+	*client
+	registry  *clientRegistry
+	shared    *sharedClient
+	closeOnce sync.Once
+}
+
+func (c *sharedClientRef) Close() {
+	c.closeOnce.Do(func() {
+		c.client.handlers.Close()
+		c.registry.release(c.shared)
+	})
+}
@@ -0,0 +1,109 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsar
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+// labelValues returns the values of the label of the series of the metric
+func labelValues(t *testing.T, gatherer prometheus.Gatherer, name, labelName string) []string {
+	families, err := gatherer.Gather()
+	assert.Nil(t, err)
+	var values []string
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+		for _, m := range family.GetMetric() {
+			for _, label := range m.GetLabel() {
+				if label.GetName() == labelName {
+					values = append(values, label.GetValue())
+				}
+			}
+		}
+	}
+	sort.Strings(values)
+	return values
+}
+
+func TestGetOrCreateClientSharesConnections(t *testing.T) {
+	registerer := prometheus.NewRegistry()
+	c1, err := GetOrCreateClient(ClientOptions{
+		URL:               "pulsar://localhost:6650",
+		Owner:             "lib-1",
+		MetricsRegisterer: registerer,
+	})
+	assert.Nil(t, err)
+	c2, err := GetOrCreateClient(ClientOptions{
+		URL:               "pulsar://localhost:6650",
+		Owner:             "lib-2",
+		MetricsRegisterer: registerer,
+	})
+	assert.Nil(t, err)
+	other, err := GetOrCreateClient(ClientOptions{
+		URL:               "pulsar://localhost:6651",
+		MetricsRegisterer: registerer,
+	})
+	assert.Nil(t, err)
+	defer other.Close()
+
+	ref1 := c1.(*sharedClientRef)
+	ref2 := c2.(*sharedClientRef)
+	assert.Equal(t, ref1.shared, ref2.shared)
+	assert.Equal(t, ref1.cnxPool, ref2.cnxPool)
+	assert.Equal(t, []string{"", "lib-1", "lib-2"}, labelValues(t, registerer, "pulsar_client_connections_opened",
+		"owner"))
+	assert.NotEqual(t, ref1.shared, other.(*sharedClientRef).shared)
+	assert.Equal(t, 2, ref1.shared.refCount)
+
+	c1.Close()
+	// closing twice only releases the instance once
+	c1.Close()
+	assert.Equal(t, 1, ref2.shared.refCount)
+
+	c2.Close()
+	assert.Equal(t, 0, ref2.shared.refCount)
+
+	sharedClients.Lock()
+	_, ok := sharedClients.clients[newSharedClientKey(ClientOptions{URL: "pulsar://localhost:6650"})]
+	sharedClients.Unlock()
+	assert.False(t, ok)
+}
+
+func TestGetOrCreateClientDifferentAuthentication(t *testing.T) {
+	auth1 := NewAuthenticationToken("token-1")
+	auth2 := NewAuthenticationToken("token-2")
+
+	c1, err := GetOrCreateClient(ClientOptions{URL: "pulsar://localhost:6650", Authentication: auth1})
+	assert.Nil(t, err)
+	defer c1.Close()
+	c2, err := GetOrCreateClient(ClientOptions{URL: "pulsar://localhost:6650", Authentication: auth2})
+	assert.Nil(t, err)
+	defer c2.Close()
+	c3, err := GetOrCreateClient(ClientOptions{URL: "pulsar://localhost:6650", Authentication: auth1})
+	assert.Nil(t, err)
+	defer c3.Close()
+
+	assert.NotEqual(t, c1.(*sharedClientRef).shared, c2.(*sharedClientRef).shared)
+	assert.Equal(t, c1.(*sharedClientRef).shared, c3.(*sharedClientRef).shared)
+}
@@ -0,0 +1,201 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsar
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/apache/pulsar-client-go/pulsar/internal"
+	"github.com/apache/pulsar-client-go/pulsar/log"
+)
+
+// ReloadableOptions holds the client settings that can be changed with Client.Reload while the
+// producers and consumers are running. Only the settings which are set are changed.
+type ReloadableOptions struct {
+	// MemoryLimitBytes, if not nil, is the new limit of the memory used by the producers of the client, with the
+	// same meaning as ClientOptions.MemoryLimitBytes. When the limit is lowered below the current usage, the
+	// messages are blocked until enough memory is released.
+	MemoryLimitBytes *int64
+
+	// LogLevel, if not empty, is the new level of the client logger: "trace", "debug", "info", "warn" or "error".
+	// The logger must implement log.LevelSetter, as the default logger does.
+	LogLevel string
+
+	// CustomMetricsLabels, if not nil, are the new values of the labels set with ClientOptions.CustomMetricsLabels,
+	// the label names can not change. The new values apply to the producers, consumers and readers created
+	// after the reload, the series of the ones created before are no longer exported.
+	CustomMetricsLabels map[string]string
+
+	// BackoffPolicy, if not nil, is the reconnection backoff of the producers, consumers and readers which
+	// have no BackoffPolicy in their options, including the existing ones.
+	BackoffPolicy internal.BackoffPolicy
+
+	// NackRedeliveryDelay, if not nil, is the redelivery delay of the negatively acknowledged messages of the
+	// consumers which have no NackRedeliveryDelay in their options, including the existing ones.
+	// The consumers using a NackBackoffPolicy are not affected.
+	NackRedeliveryDelay *time.Duration
+}
+
+// clientReloadState holds the reloadable settings which are read when the resources of a client are created
+// or reconnected
+type clientReloadState struct {
+	sync.RWMutex
+	// reloading serializes the reloads, the settings are only changed by them
+	reloading     sync.Mutex
+	metrics       *internal.Metrics
+	metricsLabels map[string]string
+	newMetrics    func(labels map[string]string) *internal.Metrics
+	// clientMetricsLabels are the labels of the metrics of the client itself, e.g. of the connections, which
+	// are not reloaded
+	clientMetricsLabels map[string]string
+	backoffPolicy       internal.BackoffPolicy
+	nackRedeliveryDelay time.Duration
+}
+
+func newClientReloadState(options ClientOptions, extraLabels map[string]string,
+	metrics *internal.Metrics) *clientReloadState {
+	return &clientReloadState{
+		metrics:             metrics,
+		metricsLabels:       options.CustomMetricsLabels,
+		clientMetricsLabels: options.CustomMetricsLabels,
+		newMetrics: func(labels map[string]string) *internal.Metrics {
+			options.CustomMetricsLabels = labels
+			return newMetricsProvider(options, extraLabels)
+		},
+		nackRedeliveryDelay: defaultNackRedeliveryDelay,
+	}
+}
+
+// nackDelayReloadable is implemented by the consumers whose negative ack redelivery delay can be changed
+type nackDelayReloadable interface {
+	reloadNackRedeliveryDelay(delay time.Duration)
+}
+
+func (c *client) Reload(options ReloadableOptions) error {
+	c.reload.reloading.Lock()
+	defer c.reload.reloading.Unlock()
+
+	if options.CustomMetricsLabels != nil && !sameLabelNames(c.reload.metricsLabels, options.CustomMetricsLabels) {
+		return newError(InvalidConfiguration, "the names of the custom metrics labels can not be changed")
+	}
+	if options.NackRedeliveryDelay != nil && *options.NackRedeliveryDelay <= 0 {
+		return newError(InvalidConfiguration, "the nack redelivery delay must be positive")
+	}
+	if options.LogLevel != "" {
+		setter, ok := c.log.(log.LevelSetter)
+		if !ok {
+			return newError(InvalidConfiguration, "the level of the client logger can not be changed")
+		}
+		if err := setter.SetLevel(options.LogLevel); err != nil {
+			return newError(InvalidConfiguration, fmt.Sprintf("invalid log level: %v", err))
+		}
+	}
+
+	if options.MemoryLimitBytes != nil {
+		limit := *options.MemoryLimitBytes
+		if limit == 0 {
+			limit = defaultMemoryLimitBytes
+		}
+		c.memLimit.SetLimit(limit)
+	}
+
+	var metrics *internal.Metrics
+	if options.CustomMetricsLabels != nil && !sameLabels(c.reload.metricsLabels, options.CustomMetricsLabels) {
+		metrics = c.reload.newMetrics(options.CustomMetricsLabels)
+		// the metrics of the client itself keep their labels, the ones registered with the new labels are unused
+		// unless they are the ones of the client
+		if !sameLabels(c.reload.clientMetricsLabels, options.CustomMetricsLabels) {
+			metrics.UnregisterClientMetrics()
+		}
+	}
+
+	c.reload.Lock()
+	var oldMetrics *internal.Metrics
+	if metrics != nil {
+		oldMetrics = c.reload.metrics
+		c.reload.metrics = metrics
+		c.reload.metricsLabels = options.CustomMetricsLabels
+	}
+	if options.BackoffPolicy != nil {
+		c.reload.backoffPolicy = options.BackoffPolicy
+	}
+	if options.NackRedeliveryDelay != nil {
+		c.reload.nackRedeliveryDelay = *options.NackRedeliveryDelay
+	}
+	c.reload.Unlock()
+	if oldMetrics != nil {
+		oldMetrics.UnregisterLeveledMetrics()
+	}
+
+	if options.NackRedeliveryDelay != nil {
+		for _, handler := range c.handlers.Handlers() {
+			if consumer, ok := handler.(nackDelayReloadable); ok {
+				consumer.reloadNackRedeliveryDelay(*options.NackRedeliveryDelay)
+			}
+		}
+	}
+
+	c.log.Info("Reloaded client configuration")
+	return nil
+}
+
+// resourceMetrics returns the metrics of the producers, consumers and readers created by the client
+func (c *client) resourceMetrics() *internal.Metrics {
+	c.reload.RLock()
+	defer c.reload.RUnlock()
+	return c.reload.metrics
+}
+
+// reconnectBackoffPolicy returns the backoff policy set by Reload, if any
+func (c *client) reconnectBackoffPolicy() internal.BackoffPolicy {
+	c.reload.RLock()
+	defer c.reload.RUnlock()
+	return c.reload.backoffPolicy
+}
+
+func (c *client) defaultNackRedeliveryDelay() time.Duration {
+	c.reload.RLock()
+	defer c.reload.RUnlock()
+	return c.reload.nackRedeliveryDelay
+}
+
+func sameLabels(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if other, ok := b[k]; !ok || other != v {
+			return false
+		}
+	}
+	return true
+}
+
+func sameLabelNames(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k := range a {
+		if _, ok := b[k]; !ok {
+			return false
+		}
+	}
+	return true
+}
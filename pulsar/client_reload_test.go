@@ -0,0 +1,145 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsar
+
+import (
+	"testing"
+	"time"
+
+	"github.com/apache/pulsar-client-go/pulsar/internal"
+	"github.com/apache/pulsar-client-go/pulsar/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReloadMemoryLimit(t *testing.T) {
+	cli, err := NewClient(ClientOptions{
+		URL:              "pulsar://localhost:6650",
+		MemoryLimitBytes: 100,
+	})
+	assert.Nil(t, err)
+	defer cli.Close()
+	c := cli.(*client)
+
+	assert.True(t, c.memLimit.TryReserveMemory(101))
+	assert.False(t, c.memLimit.TryReserveMemory(1))
+
+	limit := int64(1000)
+	assert.Nil(t, c.Reload(ReloadableOptions{MemoryLimitBytes: &limit}))
+	assert.True(t, c.memLimit.TryReserveMemory(1))
+
+	limit = -1
+	assert.Nil(t, c.Reload(ReloadableOptions{MemoryLimitBytes: &limit}))
+	assert.False(t, c.memLimit.IsMemoryLimited())
+}
+
+func TestReloadLogLevel(t *testing.T) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.InfoLevel)
+	cli, err := NewClient(ClientOptions{
+		URL:    "pulsar://localhost:6650",
+		Logger: log.NewLoggerWithLogrus(logger),
+	})
+	assert.Nil(t, err)
+	defer cli.Close()
+
+	assert.Nil(t, cli.Reload(ReloadableOptions{LogLevel: "debug"}))
+	assert.Equal(t, logrus.DebugLevel, logger.GetLevel())
+
+	assert.Error(t, cli.Reload(ReloadableOptions{LogLevel: "verbose"}))
+	assert.Equal(t, logrus.DebugLevel, logger.GetLevel())
+
+	nopClient, err := NewClient(ClientOptions{
+		URL:    "pulsar://localhost:6650",
+		Logger: log.DefaultNopLogger(),
+	})
+	assert.Nil(t, err)
+	defer nopClient.Close()
+	assert.Error(t, nopClient.Reload(ReloadableOptions{LogLevel: "debug"}))
+}
+
+func TestReloadMetricsLabels(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	cli, err := NewClient(ClientOptions{
+		URL:                 "pulsar://localhost:6650",
+		CustomMetricsLabels: map[string]string{"env": "staging"},
+		MetricsRegisterer:   registry,
+	})
+	assert.Nil(t, err)
+	defer cli.Close()
+	c := cli.(*client)
+
+	metrics := c.resourceMetrics()
+	err = c.Reload(ReloadableOptions{CustomMetricsLabels: map[string]string{"region": "eu"}})
+	assert.Error(t, err)
+	assert.Equal(t, InvalidConfiguration, err.(*Error).Result())
+	assert.Same(t, metrics, c.resourceMetrics())
+
+	assert.Nil(t, c.Reload(ReloadableOptions{CustomMetricsLabels: map[string]string{"env": "production"}}))
+	assert.NotSame(t, metrics, c.resourceMetrics())
+
+	// the series of the previous labels are no longer exported, except the ones of the client itself
+	metrics.GetLeveledMetrics("my-topic").ProducersOpened.Inc()
+	c.resourceMetrics().GetLeveledMetrics("my-topic").ProducersOpened.Inc()
+	assert.Equal(t, []string{"production"}, labelValues(t, registry, "pulsar_client_producers_opened", "env"))
+	assert.Equal(t, []string{"staging"}, labelValues(t, registry, "pulsar_client_connections_opened", "env"))
+
+	// the labels of the client can be set back
+	metrics = c.resourceMetrics()
+	assert.Nil(t, c.Reload(ReloadableOptions{CustomMetricsLabels: map[string]string{"env": "production"}}))
+	assert.Same(t, metrics, c.resourceMetrics())
+	assert.Nil(t, c.Reload(ReloadableOptions{CustomMetricsLabels: map[string]string{"env": "staging"}}))
+	c.resourceMetrics().GetLeveledMetrics("my-topic").ProducersOpened.Inc()
+	assert.Equal(t, []string{"staging"}, labelValues(t, registry, "pulsar_client_producers_opened", "env"))
+	assert.Equal(t, []string{"staging"}, labelValues(t, registry, "pulsar_client_connections_opened", "env"))
+}
+
+func TestReloadBackoffAndNackDelay(t *testing.T) {
+	cli, err := NewClient(ClientOptions{
+		URL: "pulsar://localhost:6650",
+	})
+	assert.Nil(t, err)
+	defer cli.Close()
+	c := cli.(*client)
+
+	assert.Nil(t, c.reconnectBackoffPolicy())
+	assert.Equal(t, defaultNackRedeliveryDelay, c.defaultNackRedeliveryDelay())
+
+	nmc := newNackMockedConsumer(nil)
+	tracker := newNegativeAcksTracker(nmc, defaultNackRedeliveryDelay, nil, log.DefaultNopLogger())
+	defer tracker.Close()
+	cons := &consumer{
+		client:    c,
+		consumers: []*partitionConsumer{{nackTracker: tracker}},
+	}
+	c.handlers.Add(cons)
+	defer c.handlers.Del(cons)
+
+	delay := 5 * time.Second
+	assert.Error(t, c.Reload(ReloadableOptions{NackRedeliveryDelay: new(time.Duration)}))
+
+	backoff := &internal.DefaultBackoff{}
+	assert.Nil(t, c.Reload(ReloadableOptions{BackoffPolicy: backoff, NackRedeliveryDelay: &delay}))
+	assert.Equal(t, backoff, c.reconnectBackoffPolicy())
+	assert.Equal(t, delay, c.defaultNackRedeliveryDelay())
+
+	tracker.Lock()
+	assert.Equal(t, delay, tracker.delay)
+	tracker.Unlock()
+}
@@ -0,0 +1,308 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsar
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/apache/pulsar-client-go/pulsar/internal"
+)
+
+// ResourceKind is the kind of object created by a client
+type ResourceKind int
+
+const (
+	// ProducerResource is a producer created with Client.CreateProducer
+	ProducerResource ResourceKind = iota + 1
+	// ConsumerResource is a consumer created with Client.Subscribe
+	ConsumerResource
+	// ReaderResource is a reader created with Client.CreateReader
+	ReaderResource
+	// TableViewResource is a table view created with Client.CreateTableView
+	TableViewResource
+)
+
+func (k ResourceKind) String() string {
+	switch k {
+	case ProducerResource:
+		return "Producer"
+	case ConsumerResource:
+		return "Consumer"
+	case ReaderResource:
+		return "Reader"
+	case TableViewResource:
+		return "TableView"
+	}
+	return "Unknown"
+}
+
+// ResourceInfo describes a producer, consumer, reader or table view owned by a client
+type ResourceInfo struct {
+	// Kind of the resource
+	Kind ResourceKind
+
+	// Topics the resource is attached to, as fully qualified names. For regex consumers these are the topics
+	// currently matched.
+	Topics []string
+
+	// Subscription name, for consumers and readers
+	Subscription string
+
+	// Name of the producer, consumer or reader
+	Name string
+
+	// Labels are the application defined properties the resource was created with
+	Labels map[string]string
+
+	// Pattern is the topics pattern of a regex consumer
+	Pattern string
+}
+
+// HasTopic returns true if the resource is attached to the given topic, short or fully qualified
+func (i ResourceInfo) HasTopic(topic string) bool {
+	topic = resourceTopic(topic)
+	for _, t := range i.Topics {
+		if t == topic {
+			return true
+		}
+	}
+	return false
+}
+
+type resourceInfoProvider interface {
+	resourceInfo() ResourceInfo
+}
+
+// ProducerDescriptor describes a producer of a client, see Client.Producers
+type ProducerDescriptor struct {
+	ResourceInfo
+
+	// State is the state of the producer, the state of its first partition producer not ready if any
+	State string
+
+	// CreatedAt is the time the producer was created at
+	CreatedAt time.Time
+
+	// Producer is the producer itself, e.g. to look at its Pending messages
+	Producer Producer
+}
+
+// ConsumerDescriptor describes a consumer of a client, see Client.Consumers
+type ConsumerDescriptor struct {
+	ResourceInfo
+
+	// State is the state of the consumer, the state of its first partition consumer not ready if any
+	State string
+
+	// CreatedAt is the time the consumer was created at
+	CreatedAt time.Time
+
+	// Consumer is the consumer itself, e.g. to look at its Errors
+	Consumer Consumer
+}
+
+func (c *client) Producers() []ProducerDescriptor {
+	var descriptors []ProducerDescriptor
+	for _, handler := range c.handlers.Handlers() {
+		if p, ok := handler.(*producer); ok {
+			descriptors = append(descriptors, ProducerDescriptor{
+				ResourceInfo: p.resourceInfo(),
+				State:        p.state(),
+				CreatedAt:    c.handlers.AddedAt(handler),
+				Producer:     p,
+			})
+		}
+	}
+	sort.SliceStable(descriptors, func(i, j int) bool {
+		return descriptors[i].CreatedAt.Before(descriptors[j].CreatedAt)
+	})
+	return descriptors
+}
+
+func (c *client) Consumers() []ConsumerDescriptor {
+	var descriptors []ConsumerDescriptor
+	for _, handler := range c.handlers.Handlers() {
+		provider, ok := handler.(resourceInfoProvider)
+		if !ok {
+			continue
+		}
+		var state string
+		switch h := handler.(type) {
+		case *consumer:
+			state = h.state()
+		case *multiTopicConsumer:
+			state = consumersState(h.consumers, nil)
+		case *regexConsumer:
+			state = consumersState(h.consumers, &h.consumersLock)
+		default:
+			continue
+		}
+		descriptors = append(descriptors, ConsumerDescriptor{
+			ResourceInfo: provider.resourceInfo(),
+			State:        state,
+			CreatedAt:    c.handlers.AddedAt(handler),
+			Consumer:     handler.(Consumer),
+		})
+	}
+	sort.SliceStable(descriptors, func(i, j int) bool {
+		return descriptors[i].CreatedAt.Before(descriptors[j].CreatedAt)
+	})
+	return descriptors
+}
+
+func (p *producer) state() string {
+	p.RLock()
+	defer p.RUnlock()
+	for _, pp := range p.producers {
+		if s := pp.(*partitionProducer).getProducerState(); s != producerReady {
+			return s.String()
+		}
+	}
+	return producerState(producerReady).String()
+}
+
+func (c *consumer) state() string {
+	c.Lock()
+	defer c.Unlock()
+	for _, pc := range c.consumers {
+		if s := pc.getConsumerState(); s != consumerReady {
+			return s.String()
+		}
+	}
+	return consumerState(consumerReady).String()
+}
+
+// consumersState is the state of the first topic consumer not ready, the topic consumers being guarded by l if set
+func consumersState(consumers map[string]Consumer, l sync.Locker) string {
+	if l != nil {
+		l.Lock()
+		defer l.Unlock()
+	}
+	for _, topic := range sortedTopics(consumers) {
+		if c, ok := consumers[topic].(*consumer); ok {
+			if s := c.state(); s != consumerState(consumerReady).String() {
+				return s
+			}
+		}
+	}
+	return consumerState(consumerReady).String()
+}
+
+// CloseMatching closes the producers, consumers, readers and table views of the client for which match
+// returns true, and returns the number of closed resources.
+func (c *client) CloseMatching(match func(info ResourceInfo) bool) int {
+	var wg sync.WaitGroup
+	closed := 0
+	for _, handler := range c.handlers.Handlers() {
+		provider, ok := handler.(resourceInfoProvider)
+		if !ok || !match(provider.resourceInfo()) {
+			continue
+		}
+		// table views are not removed from the handlers when closed
+		c.handlers.Del(handler)
+		closed++
+		wg.Add(1)
+		go func(h interface{ Close() }) {
+			defer wg.Done()
+			h.Close()
+		}(handler)
+	}
+	wg.Wait()
+	return closed
+}
+
+func (p *producer) resourceInfo() ResourceInfo {
+	return ResourceInfo{
+		Kind:   ProducerResource,
+		Topics: []string{resourceTopic(p.topic)},
+		Name:   p.Name(),
+		Labels: p.options.Properties,
+	}
+}
+
+func (c *consumer) resourceInfo() ResourceInfo {
+	return ResourceInfo{
+		Kind:         ConsumerResource,
+		Topics:       []string{c.topic},
+		Subscription: c.options.SubscriptionName,
+		Name:         c.consumerName,
+		Labels:       c.options.Properties,
+	}
+}
+
+func (c *multiTopicConsumer) resourceInfo() ResourceInfo {
+	return ResourceInfo{
+		Kind:         ConsumerResource,
+		Topics:       sortedTopics(c.consumers),
+		Subscription: c.options.SubscriptionName,
+		Name:         c.consumerName,
+		Labels:       c.options.Properties,
+	}
+}
+
+func (c *regexConsumer) resourceInfo() ResourceInfo {
+	c.consumersLock.Lock()
+	topics := sortedTopics(c.consumers)
+	c.consumersLock.Unlock()
+
+	return ResourceInfo{
+		Kind:         ConsumerResource,
+		Topics:       topics,
+		Subscription: c.options.SubscriptionName,
+		Name:         c.consumerName,
+		Labels:       c.options.Properties,
+		Pattern:      c.pattern.String(),
+	}
+}
+
+func (r *reader) resourceInfo() ResourceInfo {
+	return ResourceInfo{
+		Kind:         ReaderResource,
+		Topics:       []string{resourceTopic(r.pc.topic)},
+		Subscription: r.pc.options.subscription,
+		Name:         r.pc.options.consumerName,
+		Labels:       r.pc.options.metadata,
+	}
+}
+
+func (tv *TableViewImpl) resourceInfo() ResourceInfo {
+	return ResourceInfo{
+		Kind:   TableViewResource,
+		Topics: []string{resourceTopic(tv.options.Topic)},
+	}
+}
+
+// resourceTopic returns the fully qualified name of the topic, the topics of the resources being created with
+// short names as well
+func resourceTopic(topic string) string {
+	if tn, err := internal.ParseTopicName(topic); err == nil {
+		return tn.Name
+	}
+	return topic
+}
+
+func sortedTopics(consumers map[string]Consumer) []string {
+	topics := make([]string, 0, len(consumers))
+	for topic := range consumers {
+		topics = append(topics, topic)
+	}
+	sort.Strings(topics)
+	return topics
+}
@@ -0,0 +1,131 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsar
+
+import (
+	"testing"
+	"time"
+
+	"github.com/apache/pulsar-client-go/pulsar/internal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientCloseMatching(t *testing.T) {
+	c := &client{handlers: internal.NewClientHandlers()}
+
+	orders := &TableViewImpl{
+		options:  TableViewOptions{Topic: "persistent://public/default/orders"},
+		closedCh: make(chan struct{}),
+	}
+	payments := &TableViewImpl{
+		options:  TableViewOptions{Topic: "persistent://public/default/payments"},
+		closedCh: make(chan struct{}),
+	}
+	c.handlers.Add(orders)
+	c.handlers.Add(payments)
+
+	closed := c.CloseMatching(func(info ResourceInfo) bool {
+		return info.Kind == TableViewResource && info.HasTopic("persistent://public/default/orders")
+	})
+	assert.Equal(t, 1, closed)
+	assert.True(t, orders.closed)
+	assert.False(t, payments.closed)
+	assert.False(t, c.handlers.Val(orders))
+	assert.True(t, c.handlers.Val(payments))
+
+	closed = c.CloseMatching(func(info ResourceInfo) bool {
+		return info.Kind == ProducerResource
+	})
+	assert.Equal(t, 0, closed)
+	assert.False(t, payments.closed)
+}
+
+func TestResourceInfoMultiTopicConsumer(t *testing.T) {
+	c := &multiTopicConsumer{
+		options: ConsumerOptions{
+			SubscriptionName: "sub",
+			Properties:       map[string]string{"team": "billing"},
+		},
+		consumerName: "my-consumer",
+		consumers: map[string]Consumer{
+			"persistent://public/default/b": nil,
+			"persistent://public/default/a": nil,
+		},
+	}
+
+	info := c.resourceInfo()
+	assert.Equal(t, ConsumerResource, info.Kind)
+	assert.Equal(t, []string{"persistent://public/default/a", "persistent://public/default/b"}, info.Topics)
+	assert.Equal(t, "sub", info.Subscription)
+	assert.Equal(t, "my-consumer", info.Name)
+	assert.Equal(t, "billing", info.Labels["team"])
+}
+
+func TestClientProducersAndConsumers(t *testing.T) {
+	c := &client{handlers: internal.NewClientHandlers()}
+
+	ready := &partitionProducer{}
+	ready.setProducerState(producerReady)
+	closing := &partitionProducer{}
+	closing.setProducerState(producerClosing)
+	orders := &producer{
+		topic:     "persistent://public/default/orders",
+		options:   &ProducerOptions{Name: "orders-producer"},
+		producers: []Producer{ready, closing},
+	}
+	// the topic of a producer created with a short name is reported fully qualified
+	payments := &producer{
+		topic:     "payments",
+		options:   &ProducerOptions{},
+		producers: []Producer{ready},
+	}
+	pc := &partitionConsumer{}
+	pc.setConsumerState(consumerReady)
+	billing := &consumer{
+		topic:        "persistent://public/default/payments",
+		options:      ConsumerOptions{SubscriptionName: "billing"},
+		consumerName: "billing-consumer",
+		consumers:    []*partitionConsumer{pc},
+	}
+	c.handlers.Add(orders)
+	c.handlers.Add(billing)
+	time.Sleep(time.Millisecond)
+	c.handlers.Add(payments)
+	c.handlers.Add(&TableViewImpl{options: TableViewOptions{Topic: "persistent://public/default/orders"}})
+
+	producers := c.Producers()
+	assert.Len(t, producers, 2)
+	assert.Equal(t, []string{"persistent://public/default/orders"}, producers[0].Topics)
+	assert.Equal(t, "Closing", producers[0].State)
+	assert.Equal(t, Producer(orders), producers[0].Producer)
+	assert.False(t, producers[0].CreatedAt.IsZero())
+	assert.Equal(t, "Ready", producers[1].State)
+	assert.False(t, producers[1].CreatedAt.Before(producers[0].CreatedAt))
+	assert.Equal(t, []string{"persistent://public/default/payments"}, producers[1].Topics)
+	assert.True(t, producers[1].HasTopic("payments"))
+
+	consumers := c.Consumers()
+	assert.Len(t, consumers, 1)
+	assert.Equal(t, "billing", consumers[0].Subscription)
+	assert.Equal(t, "billing-consumer", consumers[0].Name)
+	assert.Equal(t, "Ready", consumers[0].State)
+	assert.Equal(t, Consumer(billing), consumers[0].Consumer)
+
+	c.handlers.Del(billing)
+	assert.Empty(t, c.Consumers())
+}
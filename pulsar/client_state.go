@@ -0,0 +1,252 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsar
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/apache/pulsar-client-go/pulsar/internal"
+)
+
+// clientState is the snapshot of the state of a client written by DumpState. Each producer, consumer and
+// connection is captured consistently on its own, not all of them at the same instant.
+type clientState struct {
+	Time        time.Time                  `json:"time"`
+	Memory      memoryState                `json:"memory"`
+	Connections []internal.ConnectionStats `json:"connections"`
+	Producers   []resourceState            `json:"producers"`
+	Consumers   []resourceState            `json:"consumers"`
+	Readers     []resourceState            `json:"readers"`
+	TableViews  []resourceState            `json:"tableViews"`
+}
+
+type memoryState struct {
+	UsedBytes    int64   `json:"usedBytes"`
+	UsagePercent float64 `json:"usagePercent"`
+}
+
+type resourceState struct {
+	Topics       []string                 `json:"topics"`
+	Subscription string                   `json:"subscription,omitempty"`
+	Name         string                   `json:"name,omitempty"`
+	Pattern      string                   `json:"pattern,omitempty"`
+	Producers    []partitionProducerState `json:"partitionProducers,omitempty"`
+	Consumers    []partitionConsumerState `json:"partitionConsumers,omitempty"`
+}
+
+type partitionProducerState struct {
+	Topic           string          `json:"topic"`
+	State           string          `json:"state"`
+	Connection      string          `json:"connection"`
+	Broker          string          `json:"broker,omitempty"`
+	PendingMessages int             `json:"pendingMessages"`
+	QueuedEvents    int             `json:"queuedEvents"`
+	LastSequenceID  int64           `json:"lastSequenceId"`
+	Epoch           uint64          `json:"epoch"`
+	LastError       *lastErrorState `json:"lastError,omitempty"`
+	// BatchingDelay is the publish delay of the batches tuned by the adaptive batching
+	BatchingDelay string `json:"batchingDelay,omitempty"`
+}
+
+type partitionConsumerState struct {
+	Topic            string          `json:"topic"`
+	State            string          `json:"state"`
+	Connection       string          `json:"connection"`
+	Broker           string          `json:"broker,omitempty"`
+	QueuedEntries    int             `json:"queuedEntries"`
+	QueuedMessages   int             `json:"queuedMessages"`
+	AvailablePermits int32           `json:"availablePermits"`
+	PendingAcks      int             `json:"pendingAcks"`
+	AckHoles         int             `json:"ackHoles,omitempty"`
+	AckHoleMessages  int             `json:"ackHoleMessages,omitempty"`
+	LastError        *lastErrorState `json:"lastError,omitempty"`
+	Perf             *consumePerf    `json:"perf,omitempty"`
+}
+
+type lastErrorState struct {
+	Error string    `json:"error"`
+	Time  time.Time `json:"time"`
+}
+
+// lastError records the last error of a producer or consumer, e.g. when reconnecting
+type lastError struct {
+	value atomic.Value
+}
+
+func (e *lastError) set(err error) {
+	e.value.Store(&lastErrorState{Error: err.Error(), Time: time.Now()})
+}
+
+func (e *lastError) get() *lastErrorState {
+	state, _ := e.value.Load().(*lastErrorState)
+	return state
+}
+
+func (s producerState) String() string {
+	switch s {
+	case producerInit:
+		return "Initializing"
+	case producerReady:
+		return "Ready"
+	case producerClosing:
+		return "Closing"
+	case producerClosed:
+		return "Closed"
+	default:
+		return "Unknown"
+	}
+}
+
+// DumpState writes a JSON snapshot of the state of the client to w
+func (c *client) DumpState(w io.Writer) error {
+	state := clientState{
+		Time: time.Now(),
+		Memory: memoryState{
+			UsedBytes:    c.memLimit.CurrentUsage(),
+			UsagePercent: c.memLimit.CurrentUsagePercent(),
+		},
+		Connections: c.cnxPool.Stats(),
+	}
+
+	for _, handler := range c.handlers.Handlers() {
+		provider, ok := handler.(resourceInfoProvider)
+		if !ok {
+			continue
+		}
+		info := provider.resourceInfo()
+		rs := resourceState{
+			Topics:       info.Topics,
+			Subscription: info.Subscription,
+			Name:         info.Name,
+			Pattern:      info.Pattern,
+		}
+
+		switch h := handler.(type) {
+		case *producer:
+			rs.Producers = h.partitionStates()
+			state.Producers = append(state.Producers, rs)
+		case *reader:
+			rs.Consumers = []partitionConsumerState{h.pc.dumpState()}
+			state.Readers = append(state.Readers, rs)
+		case *TableViewImpl:
+			state.TableViews = append(state.TableViews, rs)
+		default:
+			for _, pc := range partitionConsumersOf(handler) {
+				rs.Consumers = append(rs.Consumers, pc.dumpState())
+			}
+			state.Consumers = append(state.Consumers, rs)
+		}
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(state)
+}
+
+// NewStateHandler returns an HTTP handler responding with the state of the client written by
+// Client.DumpState, to be mounted e.g. under /debug/pulsar
+func NewStateHandler(client Client) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := client.DumpState(w); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
+
+func (p *producer) partitionStates() []partitionProducerState {
+	p.RLock()
+	defer p.RUnlock()
+
+	states := make([]partitionProducerState, 0, len(p.producers))
+	for _, pp := range p.producers {
+		if pp, ok := pp.(*partitionProducer); ok {
+			states = append(states, pp.dumpState())
+		}
+	}
+	return states
+}
+
+func (p *partitionProducer) dumpState() partitionProducerState {
+	s := partitionProducerState{
+		Topic:           p.topic,
+		State:           p.getProducerState().String(),
+		PendingMessages: p.pendingQueue.Size(),
+		QueuedEvents:    p.eventsQueue.Size(),
+		LastSequenceID:  p.LastSequenceID(),
+		Epoch:           atomic.LoadUint64(&p.epoch),
+		LastError:       p.lastError.get(),
+		Broker:          p.affinity.key(),
+	}
+	if p.adaptiveDelay != nil {
+		s.BatchingDelay = p.adaptiveDelay.current().String()
+	}
+	if cnx, ok := p.conn.Load().(internal.Connection); ok {
+		s.Connection = cnx.ID()
+	}
+	return s
+}
+
+func partitionConsumersOf(handler interface{}) []*partitionConsumer {
+	switch c := handler.(type) {
+	case *consumer:
+		c.Lock()
+		defer c.Unlock()
+		return append([]*partitionConsumer(nil), c.consumers...)
+	case *multiTopicConsumer:
+		var pcs []*partitionConsumer
+		for _, consumer := range c.consumers {
+			pcs = append(pcs, partitionConsumersOf(consumer)...)
+		}
+		return pcs
+	case *regexConsumer:
+		c.consumersLock.Lock()
+		defer c.consumersLock.Unlock()
+		var pcs []*partitionConsumer
+		for _, consumer := range c.consumers {
+			pcs = append(pcs, partitionConsumersOf(consumer)...)
+		}
+		return pcs
+	}
+	return nil
+}
+
+func (pc *partitionConsumer) dumpState() partitionConsumerState {
+	s := partitionConsumerState{
+		Topic:            pc.topic,
+		State:            pc.getConsumerState().String(),
+		QueuedEntries:    pc.queue.Size() + int(atomic.LoadInt32(&pc.dequeued)),
+		QueuedMessages:   len(pc.messageCh),
+		AvailablePermits: atomic.LoadInt32(&pc.availablePermits.permits),
+		LastError:        pc.lastError.get(),
+		Perf:             pc.perf.state(),
+		Broker:           pc.affinity.key(),
+	}
+	if pc.ackGroupingTracker != nil {
+		s.PendingAcks = pc.ackGroupingTracker.pendingAcks()
+	}
+	s.AckHoles, s.AckHoleMessages = pc.ackHoleTracker.stats()
+	if cnx, ok := pc.conn.Load().(internal.Connection); ok {
+		s.Connection = cnx.ID()
+	}
+	return s
+}
@@ -0,0 +1,103 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsar
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/apache/pulsar-client-go/pulsar/internal"
+	"github.com/apache/pulsar-client-go/pulsar/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientDumpState(t *testing.T) {
+	metrics := internal.NewMetricsProvider(4, map[string]string{}, prometheus.NewRegistry())
+	c := &client{
+		handlers: internal.NewClientHandlers(),
+		memLimit: internal.NewMemoryLimitController(1000),
+		cnxPool: internal.NewConnectionPool(nil, nil, time.Second, time.Minute, 1, log.DefaultNopLogger(),
+			metrics, time.Minute, 0, nil),
+	}
+	defer c.cnxPool.Close()
+	c.memLimit.ForceReserveMemory(250)
+
+	pp := &partitionProducer{
+		topic:        "persistent://public/default/orders",
+		producerName: "orders-producer",
+		pendingQueue: internal.NewBlockingQueue(10),
+		eventsQueue:  internal.NewRingBuffer(10),
+	}
+	pp.setProducerState(producerReady)
+	pp.pendingQueue.Put(&pendingItem{})
+	c.handlers.Add(&producer{
+		topic:     "persistent://public/default/orders",
+		options:   &ProducerOptions{},
+		producers: []Producer{pp},
+	})
+
+	pc := &partitionConsumer{
+		topic:            "persistent://public/default/payments",
+		queue:            internal.NewRingBuffer(10),
+		messageCh:        make(chan ConsumerMessage, 10),
+		availablePermits: &availablePermits{permits: 7},
+	}
+	pc.queue.Put([]*message{{}})
+	c.handlers.Add(&consumer{
+		topic:        "persistent://public/default/payments",
+		options:      ConsumerOptions{SubscriptionName: "billing"},
+		consumerName: "payments-consumer",
+		consumers:    []*partitionConsumer{pc},
+	})
+
+	buf := &bytes.Buffer{}
+	assert.NoError(t, c.DumpState(buf))
+
+	var state clientState
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &state))
+	assert.Equal(t, int64(250), state.Memory.UsedBytes)
+	assert.Equal(t, 0.25, state.Memory.UsagePercent)
+	assert.Empty(t, state.Connections)
+
+	assert.Len(t, state.Producers, 1)
+	assert.Equal(t, "orders-producer", state.Producers[0].Name)
+	assert.Equal(t, []partitionProducerState{{
+		Topic:           "persistent://public/default/orders",
+		State:           "Ready",
+		PendingMessages: 1,
+	}}, state.Producers[0].Producers)
+
+	assert.Len(t, state.Consumers, 1)
+	assert.Equal(t, "billing", state.Consumers[0].Subscription)
+	assert.Equal(t, []partitionConsumerState{{
+		Topic:            "persistent://public/default/payments",
+		State:            "Initializing",
+		QueuedEntries:    1,
+		AvailablePermits: 7,
+	}}, state.Consumers[0].Consumers)
+
+	recorder := httptest.NewRecorder()
+	NewStateHandler(c).ServeHTTP(recorder, httptest.NewRequest("GET", "/debug/pulsar", nil))
+	assert.Equal(t, 200, recorder.Code)
+	assert.Equal(t, "application/json", recorder.Header().Get("Content-Type"))
+	assert.Contains(t, recorder.Body.String(), "orders-producer")
+}
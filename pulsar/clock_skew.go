@@ -0,0 +1,61 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsar
+
+import (
+	"time"
+)
+
+const defaultClockSkewWarning = time.Minute
+
+func clockSkewWarning(warning time.Duration) time.Duration {
+	if warning == 0 {
+		return defaultClockSkewWarning
+	}
+	if warning < 0 {
+		return 0
+	}
+	return warning
+}
+
+// clockSkew estimates the skew of the clock of the broker ahead of the clock of the producer from the publish time
+// of an entry set by the producer and the time the broker received it. The estimate includes the publish latency, so
+// it is an upper bound of the skew when the publish time is behind the broker time.
+func clockSkew(publishTime, brokerTimestamp uint64) time.Duration {
+	return time.Duration(int64(brokerTimestamp)-int64(publishTime)) * time.Millisecond
+}
+
+// observeClockSkew exports the clock skew of an entry received with broker entry metadata and logs a warning when the
+// skew starts to exceed ClientOptions.ClockSkewWarning, as the skew breaks the logic relying on the event times
+func (pc *partitionConsumer) observeClockSkew(publishTime, brokerTimestamp uint64) {
+	if publishTime == 0 || brokerTimestamp == 0 {
+		return
+	}
+	skew := clockSkew(publishTime, brokerTimestamp)
+	pc.metrics.PublishTimeSkew.Set(skew.Seconds())
+
+	warning := pc.client.clockSkewWarning
+	if warning <= 0 {
+		return
+	}
+	exceeded := skew > warning || skew < -warning
+	if pc.clockSkewExceeded.Swap(exceeded) != exceeded && exceeded {
+		pc.log.WithField("skew", skew).Warnf("The broker time is skewed from the publish time by more than %v, "+
+			"the event times of the messages are unreliable", warning)
+	}
+}
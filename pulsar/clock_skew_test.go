@@ -0,0 +1,60 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsar
+
+import (
+	"testing"
+	"time"
+
+	"github.com/apache/pulsar-client-go/pulsar/internal"
+	plog "github.com/apache/pulsar-client-go/pulsar/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClockSkewWarning(t *testing.T) {
+	assert.Equal(t, defaultClockSkewWarning, clockSkewWarning(0))
+	assert.Equal(t, time.Duration(0), clockSkewWarning(-1))
+	assert.Equal(t, time.Second, clockSkewWarning(time.Second))
+}
+
+func TestObserveClockSkew(t *testing.T) {
+	pc := &partitionConsumer{
+		client: &client{clockSkewWarning: time.Second},
+		metrics: internal.NewMetricsProvider(4, map[string]string{}, prometheus.NewRegistry()).
+			GetLeveledConsumerMetrics("topic", "sub"),
+		log: plog.DefaultNopLogger(),
+	}
+
+	pc.observeClockSkew(10_000, 10_250)
+	assert.Equal(t, 0.25, testutil.ToFloat64(pc.metrics.PublishTimeSkew))
+	assert.False(t, pc.clockSkewExceeded.Load())
+
+	// the producer clock is ahead of the broker clock
+	pc.observeClockSkew(10_000, 8_000)
+	assert.Equal(t, -2.0, testutil.ToFloat64(pc.metrics.PublishTimeSkew))
+	assert.True(t, pc.clockSkewExceeded.Load())
+
+	pc.observeClockSkew(10_000, 10_100)
+	assert.False(t, pc.clockSkewExceeded.Load())
+
+	// entries without publish time or broker timestamp are ignored
+	pc.observeClockSkew(0, 10_000)
+	assert.Equal(t, 0.1, testutil.ToFloat64(pc.metrics.PublishTimeSkew))
+}
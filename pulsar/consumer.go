@@ -30,6 +30,15 @@ type ConsumerMessage struct {
 	Message
 }
 
+// AckResult is the result of an acknowledgment sent to ConsumerOptions.AckReceiptChannel
+type AckResult struct {
+	// MessageID is the id given to the acknowledgment
+	MessageID MessageID
+
+	// Err is the error of the acknowledgment, nil if the broker has processed it
+	Err error
+}
+
 // SubscriptionType of subscription supported by Pulsar
 type SubscriptionType int
 
@@ -64,6 +73,19 @@ const (
 	SubscriptionPositionEarliest
 )
 
+// SubscriptionMode defines whether the subscription is backed by a cursor on the broker
+type SubscriptionMode int
+
+const (
+	// Durable makes the subscription to be backed by a durable cursor that will retain messages and persist the
+	// current position
+	Durable SubscriptionMode = iota
+
+	// NonDurable is a lightweight subscription mode that doesn't have a durable cursor associated, the subscription
+	// starts from StartMessageID or else SubscriptionInitialPosition each time the consumer connects
+	NonDurable
+)
+
 // DLQPolicy represents the configuration for the Dead Letter Queue consumer policy.
 type DLQPolicy struct {
 	// MaxDeliveries specifies the maximum number of times that a message will be delivered before being
@@ -78,6 +100,20 @@ type DLQPolicy struct {
 
 	// RetryLetterTopic specifies the name of the topic where the retry messages will be sent.
 	RetryLetterTopic string
+
+	// InitialSubscriptionName is the name of a subscription created along with the dead letter topic, so that the
+	// messages sent to the topic are retained. No subscription is created if empty.
+	InitialSubscriptionName string
+
+	// RetryDelays are the delays of the successive retries of a message, used by Consumer.ReconsumeLater when
+	// called with a zero delay. The last delay is used for the retries beyond the number of delays.
+	RetryDelays []time.Duration
+
+	// MaxPendingMessages is the maximum number of messages waiting to be sent to each of the DLQ and RLQ topics,
+	// e.g. while their producer is created or the topic is unavailable, so that the consumer is not blocked.
+	// The messages beyond are negatively acknowledged to be redelivered later.
+	// Default is DefaultDLQMaxPendingMessages
+	MaxPendingMessages int
 }
 
 // AckGroupingOptions controls how to group ACK requests
@@ -85,13 +121,34 @@ type DLQPolicy struct {
 // Otherwise, the ACK requests will be cached until one of the following conditions meets:
 // 1. There are `MaxSize` pending ACK requests.
 // 2. `MaxTime` is greater than 1 microsecond and ACK requests have been cached for `maxTime`.
-// Specially, for cumulative acknowledgment, only the latest ACK is cached and it will only be sent after `MaxTime`.
+// Specially, for cumulative acknowledgment, only the latest ACK is cached and it will only be sent after
+// `CumulativeAckMaxTime`, `MaxTime` by default.
+// The pulsar_client_consumer_ack_grouping_* metrics export the pending ACK requests and the flushes, by trigger,
+// to tune these options.
 type AckGroupingOptions struct {
 	// The maximum number of ACK requests to cache
 	MaxSize uint32
 
 	// The maximum time to cache ACK requests
 	MaxTime time.Duration
+
+	// CumulativeAckMaxTime is the maximum time to cache the cumulative ACK requests, when it differs from MaxTime,
+	// e.g. to flush the cumulative acknowledgments more often without flushing the individual ones as often.
+	// Default is MaxTime, negative such as -1 to send the cumulative ACK requests immediately.
+	CumulativeAckMaxTime time.Duration
+
+	// FlushWithPermits flushes the cached ACK requests when the consumer sends more flow permits to the broker, so
+	// that the acknowledgments of the consumed messages reach the broker along with the permits. It avoids the
+	// broker stalling the dispatch on its unacked messages limits while the ACK requests wait for MaxTime, which
+	// mostly matters for small receiver queues.
+	FlushWithPermits bool
+
+	// ReceiptEnabled requests a receipt of the broker for the ACK commands flushed, as AckWithResponse does for
+	// the acknowledgments which are not grouped. The acknowledgments the broker failed, or which were lost with
+	// the connection, are reported to Consumer.Errors with ConsumerOpAck, and the results of all the flushed
+	// acknowledgments are sent to ConsumerOptions.AckReceiptChannel if it is set. Requires a broker supporting
+	// acknowledgment receipts.
+	ReceiptEnabled bool
 }
 
 // ConsumerOptions is used to configure and create instances of Consumer.
@@ -109,7 +166,8 @@ type ConsumerOptions struct {
 	TopicsPattern string
 
 	// AutoDiscoveryPeriod specifies the interval in which to poll for new partitions or new topics
-	// if using a TopicsPattern.
+	// if using a TopicsPattern. When the broker supports the topic list watchers, the new and deleted topics
+	// are pushed by the broker instead, and the watcher is registered again on the next period after a disconnection.
 	AutoDiscoveryPeriod time.Duration
 
 	// SubscriptionName specifies the subscription name for this consumer
@@ -134,7 +192,29 @@ type ConsumerOptions struct {
 	// Default is `Latest`
 	SubscriptionInitialPosition
 
-	// EventListener will be called when active consumer changed (in failover subscription type)
+	// SubscriptionMode selects whether the subscription is durable or, like the one of a Reader, non-durable while
+	// keeping the features of a consumer such as shared dispatch and listeners.
+	// Default is `Durable`
+	SubscriptionMode SubscriptionMode
+
+	// StartMessageID positions the subscription at the given message id once the consumer is subscribed, e.g. to
+	// resume from a checkpoint kept outside of Pulsar. EarliestMessageID and LatestMessageID apply to every
+	// partition, other message ids only to the partition they belong to. Default is nil, which keeps the position
+	// of the subscription.
+	StartMessageID MessageID
+
+	// StartMessageIDInclusive, if true, the consumer delivers the `StartMessageID` message itself, otherwise it
+	// starts right after it. Default is false.
+	StartMessageIDInclusive bool
+
+	// ValidateStartMessageID, if true, checks at creation that the `StartMessageID` is not beyond the last message
+	// of its partition, e.g. a stale message id of a deleted and re-created topic or one of another topic, and fails
+	// with an InvalidConfiguration error rather than consuming from an unexpected position. Default is false.
+	// The partition of the `StartMessageID` is always checked to belong to the topic.
+	ValidateStartMessageID bool
+
+	// EventListener will be called when active consumer changed (in failover subscription type), and when the key
+	// hash ranges of the consumer change (in key shared subscription type) if it implements KeyHashRangesListener
 	EventListener ConsumerEventListener
 
 	// DLQ represents the configuration for Dead Letter Queue consumer policy.
@@ -160,6 +240,26 @@ type ConsumerOptions struct {
 	// Default value is `1000` messages and should be good for most use cases.
 	ReceiverQueueSize int
 
+	// TopicWeights sets the weights of the topics of a consumer subscribed with Topics, so that a busy topic
+	// can not fill the receive queue of the consumer at the expense of the others. The receiver queue size of
+	// the partitions of a topic is ReceiverQueueSize scaled by the weight of the topic relative to the highest
+	// weight. The topics without weight have a weight of 1. The weights can be changed while consuming with
+	// WeightedConsumer.SetTopicWeights.
+	TopicWeights map[string]int
+
+	// EventTimeOrderingWindow, if set, enables the delivery of the messages of the partitions of a topic in
+	// approximate event time order. A message is held until a message with an event time later by at least the
+	// window has been received from any partition, or for at most the window. The messages without event time are
+	// ordered by publish time. As the messages of a partition may be reordered, individual acknowledgments should
+	// be used rather than cumulative ones.
+	EventTimeOrderingWindow time.Duration
+
+	// PartitionDispatch selects how the messages of the partitions of a topic are interleaved in the receive queue
+	// of the consumer: as received, or round-robin so a hot partition doesn't starve the others. It is ignored with
+	// an EventTimeOrderingWindow. The topics of a consumer subscribed with Topics are balanced by TopicWeights.
+	// Default is `StrictThroughputDispatch`.
+	PartitionDispatch PartitionDispatchMode
+
 	// NackRedeliveryDelay specifies the delay after which to redeliver the messages that failed to be
 	// processed. Default is 1 min. (See `Consumer.Nack()`)
 	NackRedeliveryDelay time.Duration
@@ -216,9 +316,26 @@ type ConsumerOptions struct {
 	// Default: false
 	AckWithResponse bool
 
+	// AckReceiptChannel, if set, receives the results of the acknowledgments, which requires AckWithResponse or
+	// AckGroupingOptions.ReceiptEnabled.
+	// The acknowledgments do not wait for the response of the broker anymore, the result is sent to the channel
+	// once the broker has processed the acknowledgment, e.g. to track the acknowledgments which are durable.
+	// The channel must be drained, the consumer blocks when it is full. The messages of a batch are
+	// acknowledged to the broker together, unless EnableBatchIndexAcknowledgment is set, the receipts of the
+	// messages acknowledged with AckWithResponse are sent once the batch is acknowledged while the grouped
+	// acknowledgments have a single receipt sent with the message completing the batch.
+	AckReceiptChannel chan AckResult
+
 	// MaxPendingChunkedMessage sets the maximum pending chunked messages. (default: 100)
 	MaxPendingChunkedMessage int
 
+	// MaxPendingChunkedMessageBytes bounds the bytes buffered to reassemble the pending chunked messages of each
+	// topic, or partition. The buffered bytes are also reserved from the memory limit of the client, see
+	// ClientOptions.MemoryLimitBytes. The oldest pending chunked messages are discarded to fit a new one within
+	// the limits, as when MaxPendingChunkedMessage is reached, and the new one is discarded if it does not fit
+	// alone. (default: 0, only bounded by the memory limit of the client)
+	MaxPendingChunkedMessageBytes int64
+
 	// ExpireTimeOfIncompleteChunk sets the expiry time of discarding incomplete chunked message. (default: 60 seconds)
 	ExpireTimeOfIncompleteChunk time.Duration
 
@@ -236,6 +353,82 @@ type ConsumerOptions struct {
 	// NOTE: This option does not work if AckWithResponse is true
 	//	because there are only synchronous APIs for acknowledgment
 	AckGroupingOptions *AckGroupingOptions
+
+	// PartitionAckGroupingOptions returns the AckGroupingOptions of a partition of a topic, given its index and the
+	// number of partitions of the topic, 1 for a non-partitioned topic. It is called when the consumer subscribes
+	// to the partition, e.g. to scale MaxSize down with the number of partitions or to group the acknowledgments of
+	// some partitions less. The partitions it returns nil for use AckGroupingOptions.
+	PartitionAckGroupingOptions func(topic string, partition, numPartitions int) *AckGroupingOptions
+
+	// AckHoleTracking enables the tracking of the ack holes of each partition: the unacknowledged messages
+	// delivered before acknowledged ones. The number of holes is reported in the client state and a warning is
+	// logged when it exceeds the threshold. Default is nil, which disables the tracking.
+	AckHoleTracking *AckHoleTrackingOptions
+
+	// AutoCumulativeAck promotes the individual acknowledgments to a single cumulative acknowledgment when the
+	// acknowledged messages form a contiguous prefix from the subscription cursor, which keeps the ack state of
+	// the cursor small for in-order consumers acknowledging each message. It only applies to the Exclusive and
+	// Failover subscriptions, where the consumer receives all the messages of a partition. Default is false.
+	AutoCumulativeAck bool
+
+	// Authentication, if set, is the authentication of the consumer instead of the authentication of the client,
+	// e.g. for a gateway consuming on behalf of several tenants. The consumer uses separate connections to the
+	// brokers, shared with the producers and consumers with the same Authentication instance, while the lookups
+	// are done with the authentication of the client. The provider is initialized on first use.
+	Authentication Authentication
+
+	// DecodeKafkaEntries enables the decoding of the entries written by Kafka-on-Pulsar with the `kafka`
+	// entry format. Each Kafka record is delivered as a separate message, the record headers are mapped into
+	// the message properties and the Kafka timestamp type is exposed by Message.KafkaTimestampType(). The record
+	// batches compressed with gzip, snappy, lz4 and zstd are decompressed, the ones of an unknown codec are
+	// negatively acknowledged and reported by Errors. (default: false)
+	DecodeKafkaEntries bool
+
+	// MessageFilter, if set, is called with each message before it is delivered. The messages it returns false
+	// for are rejected and handled as set by FilterRejection.
+	MessageFilter func(Message) bool
+
+	// FilterRejection is what the consumer does with the messages rejected by the MessageFilter.
+	// Default is AckAndDropMessage.
+	FilterRejection MessageDisposition
+
+	// ValidateSchema enables the validation of the payload of each message with its schema, or the Schema of the
+	// consumer, before it is delivered. The messages failing it are handled as set by DecodeFailure, instead of
+	// failing later in Message.GetSchemaValue. Default is false.
+	ValidateSchema bool
+
+	// DecodeFailure is what the consumer does with the messages failing the schema validation.
+	// Default is AckAndDropMessage.
+	DecodeFailure MessageDisposition
+
+	// RateLimit bounds the rate the consumer receives messages at, across all its topics and partitions, by pacing
+	// the flow permits sent to the brokers rather than by a dispatch rate policy on the brokers.
+	// Default is no limit.
+	RateLimit RateLimit
+
+	// SeekProgressListener is notified each time the seek of a partition completes while seeking a partitioned
+	// topic, e.g. to report the progress of a seek of a topic with many partitions.
+	SeekProgressListener SeekProgressListener
+
+	// OversizedMessageThreshold is the size of the payloads, in bytes, above which the messages received without
+	// being chunked are counted, logged and reported to an EventListener implementing OversizedMessageListener.
+	// Default is 1 MiB, a negative value disables the reports.
+	OversizedMessageThreshold int
+
+	// ExplicitZeroFields lists the names of the fields, e.g. "Type" or "SubscriptionInitialPosition", left to their
+	// zero value on purpose, such as Exclusive, SubscriptionPositionLatest or false, which are not set from the
+	// default consumer options of the client, see ClientOptions.DefaultConsumerOptions and
+	// ClientOptions.NamespaceConsumerOptions.
+	ExplicitZeroFields []string
+}
+
+// WeightedConsumer is implemented by the consumers subscribed with ConsumerOptions.Topics
+type WeightedConsumer interface {
+	Consumer
+
+	// SetTopicWeights replaces the weights of the topics, see ConsumerOptions.TopicWeights.
+	// The receiver queues of the topics are resized without reconnecting.
+	SetTopicWeights(weights map[string]int) error
 }
 
 // Consumer is an interface that abstracts behavior of Pulsar's consumer
@@ -246,10 +439,21 @@ type Consumer interface {
 	// Unsubscribe the consumer
 	Unsubscribe() error
 
+	// UnsubscribeForce deletes the subscription even if other consumers are connected to it, which are
+	// disconnected, or if it has a backlog.
+	// The error of Unsubscribe has the ConsumerBusy result when other consumers are connected and the
+	// AuthorizationError result when the client is not authorized to delete the subscription.
+	UnsubscribeForce() error
+
 	// Receive a single message.
 	// This calls blocks until a message is available.
+	// The concurrent calls are served in FIFO order, each one until its own context is done.
 	Receive(context.Context) (Message, error)
 
+	// TryReceive returns a message if one is available right away, it returns nil otherwise, including when
+	// concurrent Receive calls are waiting for a message.
+	TryReceive() (Message, error)
+
 	// Chan returns a channel to consume messages from
 	Chan() <-chan ConsumerMessage
 
@@ -259,6 +463,12 @@ type Consumer interface {
 	// AckID the consumption of a single message, identified by its MessageID
 	AckID(MessageID) error
 
+	// AckIDList the consumption of the messages, identified by their MessageID, with a single ACK command per
+	// partition rather than one per message. The ids are all validated before any is acknowledged and the
+	// duplicates are acknowledged once. The ACK commands are sent right away rather than grouped by
+	// AckGroupingOptions, and with AckWithResponse it waits for the response of the broker.
+	AckIDList(msgIDs []MessageID) error
+
 	// AckCumulative the reception of all the messages in the stream up to (and including)
 	// the provided message.
 	AckCumulative(msg Message) error
@@ -267,6 +477,15 @@ type Consumer interface {
 	// the provided message, identified by its MessageID
 	AckIDCumulative(msgID MessageID) error
 
+	// AckWithTxn the consumption of a single message as part of the transaction, the acknowledgment
+	// takes effect once the transaction is committed.
+	AckWithTxn(msg Message, txn Transaction) error
+
+	// AckCumulativeWithTxn the reception of all the messages in the stream up to (and including)
+	// the provided message as part of the transaction, the acknowledgment takes effect once the
+	// transaction is committed.
+	AckCumulativeWithTxn(msg Message, txn Transaction) error
+
 	// ReconsumeLater mark a message for redelivery after custom delay
 	ReconsumeLater(msg Message, delay time.Duration)
 
@@ -298,10 +517,14 @@ type Consumer interface {
 	// The message id can either be a specific message or represent the first or last messages in the topic.
 	//
 	// Note: this operation can only be done on non-partitioned topics. For these, one can rather perform the
-	//       seek() on the individual partitions.
+	//       seek() on the individual partitions, except for EarliestMessageID and LatestMessageID which reset all
+	//       the partitions as SeekByTime does.
 	Seek(MessageID) error
 
 	// SeekByTime resets the subscription associated with this consumer to a specific message publish time.
+	// It returns once the broker confirmed the reset of the cursor of every partition, or the seek of a partition
+	// failed. If the seek failed on some of the partitions of a partitioned topic, a *SeekError lists the partitions
+	// that failed and those that were reset.
 	//
 	// @param time
 	//            the message publish time when to reposition the subscription
@@ -310,4 +533,28 @@ type Consumer interface {
 
 	// Name returns the name of consumer.
 	Name() string
+
+	// KeyHashRanges returns the key hash ranges assigned to the consumer of a Key_Shared subscription, by
+	// partition topic name. A partition the consumer is not connected to has no entry.
+	// The ranges are only known in the Sticky mode, where the broker accepts the ranges requested by the
+	// KeySharedPolicy when subscribing; in the AutoSplit mode the broker doesn't report them to the consumer.
+	// Set a ConsumerOptions.EventListener implementing KeyHashRangesListener to be notified of the changes.
+	KeyHashRanges() map[string][]KeyHashRange
+
+	// FlushPartitionAcks sends the acknowledgments grouped for a partition right away, without flushing the other
+	// partitions of the consumer, the topic being the name of the partition as returned by Message.Topic.
+	// It returns once the acknowledgments are sent, or an error if the consumer doesn't consume the partition.
+	FlushPartitionAcks(topic string) error
+
+	// FlushAcks sends the acknowledgments grouped by AckGroupingOptions right away and waits until they are sent,
+	// or acknowledged by the broker when AckGroupingOptions.ReceiptEnabled is set, e.g. before the process shuts
+	// down so that the acknowledged messages are not redelivered. It returns the last failure of the receipts
+	// since the previous FlushAcks, or the error of the context if it's done first.
+	FlushAcks(ctx context.Context) error
+
+	// Errors returns a channel receiving the asynchronous failures of the consumer, as *ConsumerAsyncError,
+	// which are otherwise only logged: the corrupted messages discarded, the messages failing to be decrypted or
+	// published to the DLQ and RLQ topics, the partitions giving up reconnecting and the topics a pattern consumer
+	// fails to subscribe to. The failures are dropped when the channel is full, after 100 failures.
+	Errors() <-chan error
 }
@@ -0,0 +1,142 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsar
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+func (c *consumer) FlushPartitionAcks(topic string) error {
+	// the flush waits for the broker, the consumer is not locked meanwhile
+	var partition *partitionConsumer
+	c.Lock()
+	for _, pc := range c.consumers {
+		if pc.topic == topic {
+			partition = pc
+			break
+		}
+	}
+	c.Unlock()
+
+	if partition == nil {
+		return newError(TopicNotFound, fmt.Sprintf("the consumer doesn't consume the partition %s", topic))
+	}
+	partition.ackGroupingTracker.flush()
+	return nil
+}
+
+func (c *consumer) FlushAcks(ctx context.Context) error {
+	c.Lock()
+	pcs := append([]*partitionConsumer(nil), c.consumers...)
+	c.Unlock()
+
+	errs := make([]error, len(pcs))
+	var wg sync.WaitGroup
+	for i, pc := range pcs {
+		wg.Add(1)
+		go func(i int, pc *partitionConsumer) {
+			defer wg.Done()
+			errs[i] = pc.flushAcks(ctx)
+		}(i, pc)
+	}
+	wg.Wait()
+	return firstError(errs)
+}
+
+// flushAcks flushes the acknowledgments of the consumers of a multi-topic consumer
+func flushAcks(ctx context.Context, consumers map[string]Consumer) error {
+	errs := make([]error, 0, len(consumers))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, consumer := range consumers {
+		wg.Add(1)
+		go func(consumer Consumer) {
+			defer wg.Done()
+			err := consumer.FlushAcks(ctx)
+			mu.Lock()
+			defer mu.Unlock()
+			errs = append(errs, err)
+		}(consumer)
+	}
+	wg.Wait()
+	return firstError(errs)
+}
+
+func firstError(errs []error) error {
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// flushAcks flushes the acknowledgments grouped by the tracker and waits until the events loop sent them, and
+// received their receipts if requested
+func (pc *partitionConsumer) flushAcks(ctx context.Context) error {
+	if state := pc.getConsumerState(); state == consumerClosed || state == consumerClosing {
+		return newError(ConsumerClosed, "consumer closed")
+	}
+	pc.ackGroupingTracker.flush()
+
+	req := &flushAcksRequest{doneCh: make(chan struct{})}
+	select {
+	case pc.eventsCh <- req:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case <-req.doneCh:
+		return req.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// internalFlushAcks completes a flushAcksRequest once the acknowledgments sent by the events loop before it have
+// received their receipts
+func (pc *partitionConsumer) internalFlushAcks(req *flushAcksRequest) {
+	pc.ackReceipts.flush(func(err error) {
+		req.err = err
+		close(req.doneCh)
+	})
+}
+
+// flushPartitionAcks flushes the acknowledgments of a partition consumed by one of the consumers of a multi-topic
+// consumer
+func flushPartitionAcks(consumers map[string]Consumer, topic string) error {
+	for _, consumer := range consumers {
+		err := consumer.FlushPartitionAcks(topic)
+		if pulsarErr, ok := err.(*Error); !ok || pulsarErr.Result() != TopicNotFound {
+			return err
+		}
+	}
+	return newError(TopicNotFound, fmt.Sprintf("the consumer doesn't consume the partition %s", topic))
+}
+
+// ackGroupingOptions returns the AckGroupingOptions of a partition, see ConsumerOptions.PartitionAckGroupingOptions
+func (c *consumer) ackGroupingOptions(partition, numPartitions int) *AckGroupingOptions {
+	if c.options.PartitionAckGroupingOptions != nil {
+		if options := c.options.PartitionAckGroupingOptions(c.topic, partition, numPartitions); options != nil {
+			return options
+		}
+	}
+	return c.options.AckGroupingOptions
+}
@@ -0,0 +1,160 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsar
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConsumerPartitionAckGroupingOptions(t *testing.T) {
+	c := &consumer{
+		topic: "topic",
+		options: ConsumerOptions{
+			AckGroupingOptions: &AckGroupingOptions{MaxSize: 1000, MaxTime: time.Second},
+			PartitionAckGroupingOptions: func(topic string, partition, numPartitions int) *AckGroupingOptions {
+				assert.Equal(t, "topic", topic)
+				if partition == 0 {
+					return nil
+				}
+				return &AckGroupingOptions{MaxSize: uint32(1000 / numPartitions), MaxTime: time.Second}
+			},
+		},
+	}
+
+	assert.Equal(t, c.options.AckGroupingOptions, c.ackGroupingOptions(0, 4))
+	assert.Equal(t, &AckGroupingOptions{MaxSize: 250, MaxTime: time.Second}, c.ackGroupingOptions(1, 4))
+
+	c.options.PartitionAckGroupingOptions = nil
+	assert.Equal(t, c.options.AckGroupingOptions, c.ackGroupingOptions(1, 4))
+}
+
+func TestConsumerFlushPartitionAcks(t *testing.T) {
+	newPartition := func(topic string, acker *mockAcker) *partitionConsumer {
+		return &partitionConsumer{
+			topic: topic,
+			ackGroupingTracker: newAckGroupingTracker(&AckGroupingOptions{MaxSize: 1000, MaxTime: time.Hour},
+				func(id MessageID) { acker.ack(id) }, nil, nil, nil),
+		}
+	}
+	var acker0, acker1 mockAcker
+	c := &consumer{
+		consumers: []*partitionConsumer{
+			newPartition("topic-partition-0", &acker0),
+			newPartition("topic-partition-1", &acker1),
+		},
+	}
+	defer func() {
+		for _, pc := range c.consumers {
+			pc.ackGroupingTracker.close()
+		}
+	}()
+	c.consumers[0].ackGroupingTracker.add(&messageID{ledgerID: 1})
+	c.consumers[1].ackGroupingTracker.add(&messageID{ledgerID: 2})
+
+	// only the partition flushed sends its acknowledgments
+	assert.NoError(t, c.FlushPartitionAcks("topic-partition-1"))
+	assert.Empty(t, acker0.getLedgerIDs())
+	assert.Equal(t, []int64{2}, acker1.getLedgerIDs())
+
+	multi := map[string]Consumer{"other": &consumer{}, "topic": c}
+	assert.NoError(t, flushPartitionAcks(multi, "topic-partition-0"))
+	assert.Equal(t, []int64{1}, acker0.getLedgerIDs())
+
+	err := flushPartitionAcks(multi, "topic-partition-2")
+	assert.Error(t, err)
+	assert.Equal(t, TopicNotFound, err.(*Error).Result())
+}
+
+func TestConsumerFlushAcks(t *testing.T) {
+	pc := &partitionConsumer{eventsCh: make(chan interface{}, 10)}
+	pc.ackGroupingTracker = newAckGroupingTracker(&AckGroupingOptions{MaxSize: 1000, MaxTime: time.Hour},
+		nil, nil, func(ids []MessageID) {
+			pc.eventsCh <- &ackListRequest{msgIDs: make([]trackingMessageID, len(ids)), receipt: true}
+		}, nil)
+	defer pc.ackGroupingTracker.close()
+	c := &consumer{consumers: []*partitionConsumer{pc}}
+
+	// the events loop is not running, the flush waits for the context
+	pc.ackGroupingTracker.add(&messageID{ledgerID: 1})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, c.FlushAcks(ctx), context.DeadlineExceeded)
+	assert.IsType(t, &ackListRequest{}, <-pc.eventsCh)
+	assert.IsType(t, &flushAcksRequest{}, <-pc.eventsCh)
+
+	// the events loop sends the acknowledgment, which fails, before completing the flush
+	failure := errors.New("ack failed")
+	go func() {
+		for e := range pc.eventsCh {
+			switch req := e.(type) {
+			case *ackListRequest:
+				seq := pc.ackReceipts.sent()
+				// the receipt is received after the flush request
+				time.AfterFunc(10*time.Millisecond, func() { pc.ackReceipts.received(seq, true, failure) })
+			case *flushAcksRequest:
+				pc.internalFlushAcks(req)
+			}
+		}
+	}()
+	defer close(pc.eventsCh)
+	pc.ackGroupingTracker.add(&messageID{ledgerID: 2})
+	assert.Equal(t, failure, c.FlushAcks(context.Background()))
+	assert.Equal(t, 0, pc.ackGroupingTracker.pendingAcks())
+	assert.NoError(t, c.FlushAcks(context.Background()))
+
+	pc.setConsumerState(consumerClosed)
+	assert.Error(t, c.FlushAcks(context.Background()))
+}
+
+type lockingFlushConsumer struct {
+	Consumer
+	regex *regexConsumer
+}
+
+func (c *lockingFlushConsumer) FlushPartitionAcks(topic string) error {
+	// the topics may be discovered while the acknowledgments are flushed
+	c.regex.consumersLock.Lock()
+	defer c.regex.consumersLock.Unlock()
+	return nil
+}
+
+func (c *lockingFlushConsumer) FlushAcks(ctx context.Context) error {
+	return c.FlushPartitionAcks("")
+}
+
+func TestRegexConsumerFlushWithoutLock(t *testing.T) {
+	regex := &regexConsumer{}
+	regex.consumers = map[string]Consumer{"topic": &lockingFlushConsumer{regex: regex}}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		assert.NoError(t, regex.FlushPartitionAcks("topic-partition-0"))
+		assert.NoError(t, regex.FlushAcks(context.Background()))
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the flush holds the lock of the consumers")
+	}
+}
@@ -0,0 +1,212 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsar
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// AckIDList the consumption of the messages, identified by their MessageID, with an ACK command per partition
+func (c *consumer) AckIDList(msgIDs []MessageID) error {
+	byPartition := make(map[int32][]MessageID)
+	for _, msgID := range msgIDs {
+		if msgID == nil {
+			return errors.New("nil message id")
+		}
+		if err := c.checkMsgIDPartition(msgID); err != nil {
+			return err
+		}
+		byPartition[msgID.PartitionIdx()] = append(byPartition[msgID.PartitionIdx()], msgID)
+	}
+
+	var firstErr error
+	for partition, ids := range byPartition {
+		var err error
+		if c.options.AckWithResponse {
+			err = c.consumers[partition].AckIDListWithResponse(ids)
+		} else {
+			err = c.consumers[partition].AckIDList(ids)
+		}
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		for _, msgID := range ids {
+			c.client.audit.acked(c.options.SubscriptionName, msgID, nil, false)
+		}
+	}
+	return firstErr
+}
+
+func (c *multiTopicConsumer) AckIDList(msgIDs []MessageID) error {
+	return ackIDList(msgIDs, c.options.AckWithResponse, func(msgID MessageID) {
+		c.client.audit.acked(c.options.SubscriptionName, msgID, nil, false)
+	})
+}
+
+func (c *regexConsumer) AckIDList(msgIDs []MessageID) error {
+	return ackIDList(msgIDs, c.options.AckWithResponse, func(msgID MessageID) {
+		c.client.audit.acked(c.options.SubscriptionName, msgID, nil, false)
+	})
+}
+
+// ackIDList acknowledges the messages of a multi-topic consumer with an ACK command per partition consumer, acked
+// is called for each message acknowledged
+func ackIDList(msgIDs []MessageID, withResponse bool, acked func(MessageID)) error {
+	byConsumer := make(map[acker][]MessageID)
+	for _, msgID := range msgIDs {
+		mid := toTrackingMessageID(msgID)
+		if mid == nil {
+			return fmt.Errorf("invalid message id type %T", msgID)
+		}
+		if mid.consumer == nil {
+			return fmt.Errorf("unable to ack messageID=%+v can not determine topic", msgID)
+		}
+		byConsumer[mid.consumer] = append(byConsumer[mid.consumer], msgID)
+	}
+
+	var firstErr error
+	for consumer, ids := range byConsumer {
+		var err error
+		if withResponse {
+			err = consumer.AckIDListWithResponse(ids)
+		} else {
+			err = consumer.AckIDList(ids)
+		}
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		for _, msgID := range ids {
+			acked(msgID)
+		}
+	}
+	return firstErr
+}
+
+func (pc *partitionConsumer) AckIDList(msgIDs []MessageID) error {
+	return pc.ackIDList(msgIDs, false)
+}
+
+func (pc *partitionConsumer) AckIDListWithResponse(msgIDs []MessageID) error {
+	return pc.ackIDList(msgIDs, true)
+}
+
+// ackIDList acknowledges the messages of the partition with a single ACK command, the messages of a batch are
+// acknowledged with their batch, or with the batch index acknowledgment
+func (pc *partitionConsumer) ackIDList(msgIDs []MessageID, withResponse bool) error {
+	if state := pc.getConsumerState(); state == consumerClosed || state == consumerClosing {
+		pc.log.WithField("state", state).Error("Failed to ack by closing or closed consumer")
+		return errors.New("consumer state is closed")
+	}
+
+	type position struct {
+		ledgerID int64
+		entryID  int64
+		batchIdx int32
+	}
+	type entry struct {
+		msgID      MessageID
+		trackingID *trackingMessageID
+	}
+	// all the ids are validated and converted before changing the ack state, so that a failure leaves it as is
+	positions := make(map[position]struct{}, len(msgIDs))
+	entries := make([]entry, 0, len(msgIDs))
+	var chunkIDs []*chunkMessageID
+	addEntry := func(msgID MessageID) error {
+		trackingID := toTrackingMessageID(msgID)
+		if trackingID == nil {
+			return errors.New("failed to convert trackingMessageID")
+		}
+		p := position{ledgerID: trackingID.ledgerID, entryID: trackingID.entryID, batchIdx: trackingID.batchIdx}
+		if _, ok := positions[p]; !ok {
+			positions[p] = struct{}{}
+			entries = append(entries, entry{msgID: msgID, trackingID: trackingID})
+		}
+		return nil
+	}
+	for _, msgID := range msgIDs {
+		cmid, ok := msgID.(*chunkMessageID)
+		if !ok {
+			if err := addEntry(msgID); err != nil {
+				return err
+			}
+			continue
+		}
+		// the chunks of a message are acknowledged in place of the message
+		chunkIDs = append(chunkIDs, cmid)
+		for _, id := range pc.unAckChunksTracker.get(cmid) {
+			if err := addEntry(id); err != nil {
+				return err
+			}
+		}
+	}
+
+	req := &ackListRequest{
+		msgIDs: make([]trackingMessageID, 0, len(entries)),
+		listed: true,
+	}
+	receipt := withResponse && pc.options.ackReceiptCh != nil
+	var prefixID *trackingMessageID
+	for _, e := range entries {
+		trackingID := e.trackingID
+		acked, receiptIDs := pc.ackHoldingReceipt(trackingID, false, e.msgID, receipt)
+		if acked {
+			if prefix := pc.promoteAck(pc.ackHoleTracker.acked(trackingID)); prefix != nil {
+				prefixID = prefix
+			}
+			pc.metrics.AcksCounter.Inc()
+			pc.metrics.ProcessingTime.Observe(time.Since(trackingID.receivedTime).Seconds())
+		} else if !pc.options.enableBatchIndexAck {
+			continue
+		}
+		req.msgIDs = append(req.msgIDs, *trackingID)
+		if receipt {
+			req.receiptIDs = append(req.receiptIDs, receiptIDs...)
+		}
+		pc.options.interceptors.OnAcknowledge(pc.parentConsumer, e.msgID)
+	}
+	for _, cmid := range chunkIDs {
+		pc.unAckChunksTracker.remove(cmid)
+	}
+	if prefixID != nil {
+		pc.ackGroupingTracker.addCumulative(prefixID)
+	}
+	if len(req.msgIDs) == 0 {
+		return nil
+	}
+
+	if receipt {
+		// the results are sent to the receipts channel instead
+		req.receipt = true
+	} else if withResponse {
+		req.doneCh = make(chan struct{})
+	}
+	pc.eventsCh <- req
+	if req.doneCh == nil {
+		return nil
+	}
+	<-req.doneCh
+	return req.err
+}
@@ -0,0 +1,157 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsar
+
+import (
+	"errors"
+	"testing"
+
+	plog "github.com/apache/pulsar-client-go/pulsar/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newAckListTestConsumer(partition int32) *partitionConsumer {
+	return &partitionConsumer{
+		partitionIdx: partition,
+		eventsCh:     make(chan interface{}, 10),
+		options:      &partitionConsumerOpts{},
+		metrics:      newTestMetrics(),
+		log:          plog.DefaultNopLogger(),
+	}
+}
+
+func TestPartitionConsumerAckIDList(t *testing.T) {
+	pc := newAckListTestConsumer(0)
+
+	assert.NoError(t, pc.AckIDList([]MessageID{
+		newTrackingMessageID(1, 2, -1, 0, 0, nil),
+		newTrackingMessageID(1, 3, -1, 0, 0, nil),
+		newTrackingMessageID(1, 2, -1, 0, 0, nil),
+	}))
+	req := (<-pc.eventsCh).(*ackListRequest)
+	assert.True(t, req.listed)
+	assert.False(t, req.receipt)
+	assert.Nil(t, req.doneCh)
+	require.Len(t, req.msgIDs, 2)
+	assert.Equal(t, int64(2), req.msgIDs[0].entryID)
+	assert.Equal(t, int64(3), req.msgIDs[1].entryID)
+
+	// the messages of a batch are acknowledged once the whole batch is
+	tracker := newAckTracker(2)
+	assert.NoError(t, pc.AckIDList([]MessageID{newTrackingMessageID(1, 4, 0, 0, 2, tracker)}))
+	assert.Empty(t, pc.eventsCh)
+	assert.NoError(t, pc.AckIDList([]MessageID{newTrackingMessageID(1, 4, 1, 0, 2, tracker)}))
+	req = (<-pc.eventsCh).(*ackListRequest)
+	require.Len(t, req.msgIDs, 1)
+	assert.Equal(t, int64(4), req.msgIDs[0].entryID)
+
+	// the ids are validated before any is acknowledged
+	assert.Error(t, pc.AckIDList([]MessageID{newTrackingMessageID(1, 5, -1, 0, 0, nil), nil}))
+	assert.Empty(t, pc.eventsCh)
+
+	pc.setConsumerState(consumerClosed)
+	assert.Error(t, pc.AckIDList([]MessageID{newTrackingMessageID(1, 5, -1, 0, 0, nil)}))
+}
+
+func TestPartitionConsumerAckIDListChunks(t *testing.T) {
+	pc := newAckListTestConsumer(0)
+	pc.unAckChunksTracker = newUnAckChunksTracker(pc)
+	first := newMessageID(1, 6, -1, 0, 0).(*messageID)
+	last := newMessageID(1, 7, -1, 0, 0).(*messageID)
+	cmid := newChunkMessageID(first, last)
+	pc.unAckChunksTracker.add(cmid, []*messageID{first, last})
+
+	// a failure leaves the ack state of the valid ids as is
+	tracker := newAckTracker(2)
+	assert.Error(t, pc.AckIDList([]MessageID{newTrackingMessageID(1, 8, 0, 0, 2, tracker), cmid, nil}))
+	assert.Empty(t, pc.eventsCh)
+	assert.Len(t, pc.unAckChunksTracker.get(cmid), 2)
+	assert.NoError(t, pc.AckIDList([]MessageID{newTrackingMessageID(1, 8, 1, 0, 2, tracker)}))
+	assert.Empty(t, pc.eventsCh)
+
+	// the chunks are acknowledged with the other messages in a single request
+	assert.NoError(t, pc.AckIDList([]MessageID{cmid, newTrackingMessageID(1, 8, 0, 0, 2, tracker)}))
+	req := (<-pc.eventsCh).(*ackListRequest)
+	require.Len(t, req.msgIDs, 3)
+	assert.Equal(t, int64(6), req.msgIDs[0].entryID)
+	assert.Equal(t, int64(7), req.msgIDs[1].entryID)
+	assert.Equal(t, int64(8), req.msgIDs[2].entryID)
+	assert.Empty(t, pc.unAckChunksTracker.get(cmid))
+}
+
+func TestPartitionConsumerAckIDListWithResponse(t *testing.T) {
+	pc := newAckListTestConsumer(0)
+	failure := errors.New("ack failed")
+	go func() {
+		req := (<-pc.eventsCh).(*ackListRequest)
+		req.err = failure
+		close(req.doneCh)
+	}()
+	assert.Equal(t, failure, pc.AckIDListWithResponse([]MessageID{newTrackingMessageID(1, 2, -1, 0, 0, nil)}))
+
+	// the results are sent to the receipts channel rather than waited for
+	pc.options.ackReceiptCh = make(chan AckResult, 1)
+	assert.NoError(t, pc.AckIDListWithResponse([]MessageID{newTrackingMessageID(1, 3, -1, 0, 0, nil)}))
+	req := (<-pc.eventsCh).(*ackListRequest)
+	assert.True(t, req.receipt)
+	assert.Nil(t, req.doneCh)
+}
+
+func TestConsumerAckIDList(t *testing.T) {
+	pcs := []*partitionConsumer{newAckListTestConsumer(0), newAckListTestConsumer(1)}
+	c := &consumer{consumers: pcs, client: &client{}, log: plog.DefaultNopLogger()}
+
+	assert.NoError(t, c.AckIDList([]MessageID{
+		newTrackingMessageID(1, 2, -1, 0, 0, nil),
+		newTrackingMessageID(2, 2, -1, 1, 0, nil),
+		newTrackingMessageID(1, 3, -1, 0, 0, nil),
+	}))
+	assert.Len(t, (<-pcs[0].eventsCh).(*ackListRequest).msgIDs, 2)
+	assert.Len(t, (<-pcs[1].eventsCh).(*ackListRequest).msgIDs, 1)
+
+	// an id of an unknown partition fails the whole list
+	assert.Error(t, c.AckIDList([]MessageID{
+		newTrackingMessageID(1, 4, -1, 0, 0, nil),
+		newTrackingMessageID(1, 4, -1, 2, 0, nil),
+	}))
+	assert.Error(t, c.AckIDList([]MessageID{nil}))
+	assert.Empty(t, pcs[0].eventsCh)
+}
+
+func TestMultiTopicAckIDList(t *testing.T) {
+	pcs := []*partitionConsumer{newAckListTestConsumer(0), newAckListTestConsumer(0)}
+	ids := []MessageID{
+		newTrackingMessageID(1, 2, -1, 0, 0, nil),
+		newTrackingMessageID(2, 2, -1, 0, 0, nil),
+		newTrackingMessageID(1, 3, -1, 0, 0, nil),
+	}
+	ids[0].(*trackingMessageID).consumer = pcs[0]
+	ids[1].(*trackingMessageID).consumer = pcs[1]
+	ids[2].(*trackingMessageID).consumer = pcs[0]
+
+	var acked []MessageID
+	assert.NoError(t, ackIDList(ids, false, func(id MessageID) { acked = append(acked, id) }))
+	assert.Len(t, (<-pcs[0].eventsCh).(*ackListRequest).msgIDs, 2)
+	assert.Len(t, (<-pcs[1].eventsCh).(*ackListRequest).msgIDs, 1)
+	assert.ElementsMatch(t, ids, acked)
+
+	// the topic of an id without consumer can't be determined
+	assert.Error(t, ackIDList([]MessageID{ids[0], newTrackingMessageID(3, 2, -1, 0, 0, nil)}, false, nil))
+	assert.Empty(t, pcs[0].eventsCh)
+}
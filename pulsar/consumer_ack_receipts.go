@@ -0,0 +1,105 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsar
+
+import (
+	"sync"
+)
+
+// ackReceipts tracks the acknowledgments sent by the events loop which wait for the receipt of the broker, so that
+// a flush of the acknowledgments waits for the receipts of the ones sent before it. The zero value is ready to use.
+type ackReceipts struct {
+	sync.Mutex
+	seq      uint64
+	inflight map[uint64]struct{}
+	flushes  []ackReceiptsFlush
+	// err is the last failure of the grouped acknowledgments since the last flush
+	err error
+}
+
+type ackReceiptsFlush struct {
+	seq  uint64
+	done func(err error)
+}
+
+// sent registers an acknowledgment waiting for its receipt, the sequence number returned completes it
+func (r *ackReceipts) sent() uint64 {
+	r.Lock()
+	defer r.Unlock()
+	if r.inflight == nil {
+		r.inflight = make(map[uint64]struct{})
+	}
+	r.seq++
+	r.inflight[r.seq] = struct{}{}
+	return r.seq
+}
+
+// received completes the acknowledgment with its receipt, grouped tells the acknowledgments of the ack grouping
+// tracker whose failures are returned by the next flush
+func (r *ackReceipts) received(seq uint64, grouped bool, err error) {
+	r.Lock()
+	delete(r.inflight, seq)
+	if grouped && err != nil {
+		r.err = err
+	}
+	done := r.completedFlushes()
+	r.Unlock()
+	for _, f := range done {
+		f()
+	}
+}
+
+// flush calls done once the receipts of the acknowledgments sent so far are received, with the last failure of the
+// grouped acknowledgments
+func (r *ackReceipts) flush(done func(err error)) {
+	r.Lock()
+	r.flushes = append(r.flushes, ackReceiptsFlush{seq: r.seq, done: done})
+	completed := r.completedFlushes()
+	r.Unlock()
+	for _, f := range completed {
+		f()
+	}
+}
+
+// completedFlushes removes the flushes whose acknowledgments are all received, it must be called with the lock held
+func (r *ackReceipts) completedFlushes() []func() {
+	var done []func()
+	pending := r.flushes[:0]
+	for _, f := range r.flushes {
+		if r.waiting(f.seq) {
+			pending = append(pending, f)
+			continue
+		}
+		f := f
+		err := r.err
+		r.err = nil
+		done = append(done, func() { f.done(err) })
+	}
+	r.flushes = pending
+	return done
+}
+
+// waiting returns true if an acknowledgment sent up to seq waits for its receipt
+func (r *ackReceipts) waiting(seq uint64) bool {
+	for s := range r.inflight {
+		if s <= seq {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,210 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+//go:build bench
+// +build bench
+
+package pulsar
+
+// The benchmarks of the consume path are run with the bench build tag:
+//
+//	go test -tags bench -run '^$' -bench BenchmarkConsume ./pulsar
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sync"
+	"testing"
+
+	"github.com/apache/pulsar-client-go/pulsar/crypto"
+	"github.com/apache/pulsar-client-go/pulsar/internal"
+	cryptointernal "github.com/apache/pulsar-client-go/pulsar/internal/crypto"
+	pb "github.com/apache/pulsar-client-go/pulsar/internal/pulsar_proto"
+	"github.com/apache/pulsar-client-go/pulsar/log"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	benchBatchSize   = 100
+	benchPayloadSize = 1024
+)
+
+// benchEntry builds the headers and payload of an entry of a batch of messages, as received from the broker
+func benchEntry(b *testing.B, compressionType pb.CompressionType, encryptor cryptointernal.Encryptor) []byte {
+	payload := make([]byte, benchPayloadSize)
+	for i := range payload {
+		payload[i] = byte('a' + i%16)
+	}
+
+	var batch []byte
+	for i := 0; i < benchBatchSize; i++ {
+		smm, err := proto.Marshal(&pb.SingleMessageMetadata{
+			PayloadSize:  proto.Int32(int32(len(payload))),
+			PartitionKey: proto.String(fmt.Sprintf("key-%d", i)),
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+		batch = appendUint32(batch, uint32(len(smm)))
+		batch = append(batch, smm...)
+		batch = append(batch, payload...)
+	}
+
+	msgMeta := &pb.MessageMetadata{
+		ProducerName:       proto.String("bench"),
+		SequenceId:         proto.Uint64(0),
+		PublishTime:        proto.Uint64(1),
+		NumMessagesInBatch: proto.Int32(benchBatchSize),
+		Compression:        compressionType.Enum(),
+		UncompressedSize:   proto.Uint32(uint32(len(batch))),
+	}
+	provider, err := newCompressionProvider(compressionType)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer provider.Close()
+	body := provider.Compress(nil, batch)
+	if encryptor != nil {
+		if body, err = encryptor.Encrypt(body, msgMeta); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	meta, err := proto.Marshal(msgMeta)
+	if err != nil {
+		b.Fatal(err)
+	}
+	checksummed := appendUint32(nil, uint32(len(meta)))
+	checksummed = append(append(checksummed, meta...), body...)
+
+	// magic number of the checksum
+	entry := []byte{0x0e, 0x01}
+	entry = appendUint32(entry, internal.Crc32cCheckSum(checksummed))
+	return append(entry, checksummed...)
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	var buf [4]byte
+	binary.BigEndian.PutUint32(buf[:], v)
+	return append(b, buf[:]...)
+}
+
+func newBenchPartitionConsumer(decryptor cryptointernal.Decryptor) *partitionConsumer {
+	pc := &partitionConsumer{
+		queue:                internal.NewRingBuffer(1),
+		eventsCh:             make(chan interface{}, 1),
+		compressionProviders: sync.Map{},
+		options:              &partitionConsumerOpts{},
+		metrics:              newTestMetrics(),
+		decryptor:            decryptor,
+		log:                  log.DefaultNopLogger(),
+	}
+	pc.ackGroupingTracker = newAckGroupingTracker(&AckGroupingOptions{MaxSize: 0},
+		func(id MessageID) { pc.sendIndividualAck(id) }, nil, nil, nil)
+	return pc
+}
+
+func benchMessageReceived(b *testing.B, pc *partitionConsumer, entry []byte) {
+	b.SetBytes(int64(benchBatchSize * benchPayloadSize))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := pc.MessageReceived(nil, internal.NewBufferWrapper(entry)); err != nil {
+			b.Fatal(err)
+		}
+		pc.queue.Drain(nil)
+	}
+	b.StopTimer()
+
+	perf := pc.perf.state()
+	for name, s := range map[string]perfState{
+		"decode-ns/msg":       perf.Decode,
+		"decompress-ns/entry": perf.Decompress,
+		"decrypt-ns/entry":    perf.Decrypt,
+	} {
+		if s.Ops > 0 {
+			b.ReportMetric(s.NsPerOp, name)
+		}
+	}
+}
+
+func BenchmarkConsumeDecode(b *testing.B) {
+	entry := benchEntry(b, pb.CompressionType_NONE, nil)
+	benchMessageReceived(b, newBenchPartitionConsumer(cryptointernal.NewNoopDecryptor()), entry)
+}
+
+func BenchmarkConsumeDecompress(b *testing.B) {
+	for _, compressionType := range []pb.CompressionType{
+		pb.CompressionType_LZ4,
+		pb.CompressionType_ZLIB,
+		pb.CompressionType_ZSTD,
+		pb.CompressionType_SNAPPY,
+	} {
+		b.Run(compressionType.String(), func(b *testing.B) {
+			entry := benchEntry(b, compressionType, nil)
+			benchMessageReceived(b, newBenchPartitionConsumer(cryptointernal.NewNoopDecryptor()), entry)
+		})
+	}
+}
+
+func BenchmarkConsumeDecrypt(b *testing.B) {
+	keyReader := crypto.NewFileKeyReader("crypto/testdata/pub_key_rsa.pem", "crypto/testdata/pri_key_rsa.pem")
+	producerCrypto, err := crypto.NewDefaultMessageCrypto("bench", true, log.DefaultNopLogger())
+	if err != nil {
+		b.Fatal(err)
+	}
+	encryptor := cryptointernal.NewProducerEncryptor([]string{"bench"}, keyReader, producerCrypto,
+		crypto.ProducerCryptoFailureActionFail, log.DefaultNopLogger())
+	entry := benchEntry(b, pb.CompressionType_LZ4, encryptor)
+
+	consumerCrypto, err := crypto.NewDefaultMessageCrypto("bench", false, log.DefaultNopLogger())
+	if err != nil {
+		b.Fatal(err)
+	}
+	decryptor := cryptointernal.NewConsumerDecryptor(keyReader, consumerCrypto, log.DefaultNopLogger())
+	benchMessageReceived(b, newBenchPartitionConsumer(decryptor), entry)
+}
+
+func BenchmarkConsumeDispatch(b *testing.B) {
+	entry := benchEntry(b, pb.CompressionType_NONE, nil)
+	pc := newBenchPartitionConsumer(cryptointernal.NewNoopDecryptor())
+	pc.messageCh = make(chan ConsumerMessage, benchBatchSize)
+	pc.closeCh = make(chan struct{})
+	pc.connectedCh = make(chan struct{})
+	pc.clearQueueCh = make(chan func(id *trackingMessageID))
+	pc.dlq = &dlqRouter{}
+	pc.availablePermits = &availablePermits{pc: pc}
+	// never request permits
+	pc.queueSize = math.MaxInt32
+	go pc.dispatcher()
+	defer close(pc.closeCh)
+
+	b.SetBytes(int64(benchBatchSize * benchPayloadSize))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := pc.MessageReceived(nil, internal.NewBufferWrapper(entry)); err != nil {
+			b.Fatal(err)
+		}
+		for j := 0; j < benchBatchSize; j++ {
+			<-pc.messageCh
+		}
+	}
+	b.StopTimer()
+	b.ReportMetric(pc.perf.state().Dispatch.NsPerOp, "dispatch-ns/entry")
+}
@@ -0,0 +1,97 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsar
+
+import (
+	"fmt"
+)
+
+// The operations of the asynchronous failures of the consumers, see ConsumerAsyncError
+const (
+	// ConsumerOpDecode is the discarding of a corrupted message, e.g. with a checksum mismatch
+	ConsumerOpDecode = "decode"
+	// ConsumerOpDecrypt is the decryption of a message
+	ConsumerOpDecrypt = "decrypt"
+	// ConsumerOpDLQ is the publishing of a message to the dead letter topic
+	ConsumerOpDLQ = "dlq"
+	// ConsumerOpRLQ is the publishing of a message to the retry letter topic
+	ConsumerOpRLQ = "rlq"
+	// ConsumerOpReconnect is the reconnection of a partition consumer, reported when it gives up
+	ConsumerOpReconnect = "reconnect"
+	// ConsumerOpSubscribe is the subscription to a topic discovered by a pattern consumer
+	ConsumerOpSubscribe = "subscribe"
+	// ConsumerOpAck is the acknowledgment of a message flushed by the ack grouping, reported when
+	// AckGroupingOptions.ReceiptEnabled is set and the broker failed it or the connection was lost
+	ConsumerOpAck = "ack"
+)
+
+// consumerErrorsQueueSize is the number of asynchronous failures kept until they are received from
+// Consumer.Errors, the failures beyond are dropped
+const consumerErrorsQueueSize = 100
+
+// ConsumerAsyncError is an asynchronous failure of a consumer, received from Consumer.Errors
+type ConsumerAsyncError struct {
+	// Op is the operation which failed, one of the ConsumerOp constants
+	Op string
+	// Topic is the topic, or partition, of the failure
+	Topic string
+	// MessageID is the id of the message of the failure, if any
+	MessageID MessageID
+	// Err is the cause of the failure
+	Err error
+}
+
+func (e *ConsumerAsyncError) Error() string {
+	if e.MessageID != nil {
+		return fmt.Sprintf("%s failed on topic %s for message %s: %v", e.Op, e.Topic, e.MessageID, e.Err)
+	}
+	return fmt.Sprintf("%s failed on topic %s: %v", e.Op, e.Topic, e.Err)
+}
+
+func (e *ConsumerAsyncError) Unwrap() error {
+	return e.Err
+}
+
+// consumerErrors passes the asynchronous failures of a consumer, and of its partitions, to Consumer.Errors
+type consumerErrors struct {
+	ch chan error
+}
+
+func newConsumerErrors() *consumerErrors {
+	return &consumerErrors{
+		ch: make(chan error, consumerErrorsQueueSize),
+	}
+}
+
+// report passes the failure without blocking, it is dropped if the application doesn't receive the failures
+func (e *consumerErrors) report(op, topic string, msgID MessageID, err error) {
+	if e == nil {
+		return
+	}
+	select {
+	case e.ch <- &ConsumerAsyncError{Op: op, Topic: topic, MessageID: msgID, Err: err}:
+	default:
+	}
+}
+
+func (e *consumerErrors) errors() <-chan error {
+	if e == nil {
+		return nil
+	}
+	return e.ch
+}
@@ -0,0 +1,50 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsar
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConsumerErrors(t *testing.T) {
+	e := newConsumerErrors()
+	cause := errors.New("producer unavailable")
+	e.report(ConsumerOpDLQ, "my-topic", newMessageID(1, 2, 0, 0, 0), cause)
+
+	err := <-e.errors()
+	assert.True(t, errors.Is(err, cause))
+	var asyncErr *ConsumerAsyncError
+	assert.True(t, errors.As(err, &asyncErr))
+	assert.Equal(t, ConsumerOpDLQ, asyncErr.Op)
+	assert.Equal(t, "my-topic", asyncErr.Topic)
+	assert.Equal(t, "dlq failed on topic my-topic for message 1:2:0: producer unavailable", err.Error())
+
+	// the failures are dropped instead of blocking when they are not received
+	for i := 0; i < consumerErrorsQueueSize+10; i++ {
+		e.report(ConsumerOpReconnect, "my-topic", nil, cause)
+	}
+	assert.Len(t, e.errors(), consumerErrorsQueueSize)
+	assert.Equal(t, "reconnect failed on topic my-topic: producer unavailable", (<-e.errors()).Error())
+
+	var none *consumerErrors
+	none.report(ConsumerOpDecode, "my-topic", nil, cause)
+	assert.Nil(t, none.errors())
+}
@@ -0,0 +1,185 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsar
+
+import (
+	"container/heap"
+	"time"
+)
+
+const minEventTimeMergeTick = 10 * time.Millisecond
+
+// eventTimeMerger reorders the messages received from the partitions of a topic by event time, see
+// ConsumerOptions.EventTimeOrderingWindow. A message is held until a message later by at least the window has been
+// received, or for at most the window, or until more than maxBuffered messages are held. No more messages are read from
+// in while the queue is full so that the receiver queue keeps applying the flow control.
+type eventTimeMerger struct {
+	window      time.Duration
+	maxBuffered int
+
+	in      chan ConsumerMessage
+	out     chan ConsumerMessage
+	clearCh chan chan struct{}
+	closeCh <-chan struct{}
+
+	queue   eventTimeQueue
+	maxSeen time.Time
+	seq     uint64
+}
+
+func newEventTimeMerger(window time.Duration, maxBuffered int, out chan ConsumerMessage,
+	closeCh <-chan struct{}) *eventTimeMerger {
+	if maxBuffered <= 0 {
+		maxBuffered = defaultReceiverQueueSize
+	}
+	m := &eventTimeMerger{
+		window:      window,
+		maxBuffered: maxBuffered,
+		in:          make(chan ConsumerMessage, cap(out)),
+		out:         out,
+		clearCh:     make(chan chan struct{}),
+		closeCh:     closeCh,
+	}
+	go m.run()
+	return m
+}
+
+func (m *eventTimeMerger) run() {
+	tick := m.window / 2
+	if tick < minEventTimeMergeTick {
+		tick = minEventTimeMergeTick
+	}
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+
+	for {
+		var in chan ConsumerMessage
+		if len(m.queue) <= m.maxBuffered {
+			in = m.in
+		}
+		var out chan ConsumerMessage
+		var next ConsumerMessage
+		if m.ready(time.Now()) {
+			out = m.out
+			next = m.queue[0].msg
+		}
+
+		select {
+		case <-m.closeCh:
+			return
+		case cm := <-in:
+			m.push(cm, time.Now())
+		case out <- next:
+			heap.Pop(&m.queue)
+		case done := <-m.clearCh:
+			m.queue = nil
+			m.maxSeen = time.Time{}
+			m.drainIn()
+			close(done)
+		case <-ticker.C:
+		}
+	}
+}
+
+func (m *eventTimeMerger) push(cm ConsumerMessage, now time.Time) {
+	ts := messageEventTime(cm.Message)
+	if ts.After(m.maxSeen) {
+		m.maxSeen = ts
+	}
+	m.seq++
+	heap.Push(&m.queue, &eventTimeItem{
+		msg:     cm,
+		ts:      ts,
+		arrival: now,
+		seq:     m.seq,
+	})
+}
+
+// ready returns true if the earliest message can be delivered
+func (m *eventTimeMerger) ready(now time.Time) bool {
+	if len(m.queue) == 0 {
+		return false
+	}
+	if len(m.queue) > m.maxBuffered {
+		return true
+	}
+	top := m.queue[0]
+	return !top.ts.After(m.maxSeen.Add(-m.window)) || now.Sub(top.arrival) >= m.window
+}
+
+// drainIn drops the messages received from the partitions but not yet read
+func (m *eventTimeMerger) drainIn() {
+	for {
+		select {
+		case <-m.in:
+		default:
+			return
+		}
+	}
+}
+
+// clear drops the held messages and the ones waiting to be read, e.g. after a seek
+func (m *eventTimeMerger) clear() {
+	done := make(chan struct{})
+	select {
+	case m.clearCh <- done:
+		<-done
+	case <-m.closeCh:
+	}
+}
+
+// messageEventTime is the event time of the message, or its publish time if it has no event time
+func messageEventTime(msg Message) time.Time {
+	if ts := msg.EventTime(); !ts.IsZero() && ts.UnixNano() != 0 {
+		return ts
+	}
+	return msg.PublishTime()
+}
+
+type eventTimeItem struct {
+	msg     ConsumerMessage
+	ts      time.Time
+	arrival time.Time
+	seq     uint64
+}
+
+type eventTimeQueue []*eventTimeItem
+
+func (q eventTimeQueue) Len() int { return len(q) }
+
+func (q eventTimeQueue) Less(i, j int) bool {
+	if q[i].ts.Equal(q[j].ts) {
+		return q[i].seq < q[j].seq
+	}
+	return q[i].ts.Before(q[j].ts)
+}
+
+func (q eventTimeQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q *eventTimeQueue) Push(x interface{}) {
+	*q = append(*q, x.(*eventTimeItem))
+}
+
+func (q *eventTimeQueue) Pop() interface{} {
+	old := *q
+	n := len(old)
+	item := old[n-1]
+	old[n-1] = nil
+	*q = old[:n-1]
+	return item
+}
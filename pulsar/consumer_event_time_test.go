@@ -0,0 +1,139 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsar
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func eventTimeMessage(seconds int64) ConsumerMessage {
+	return ConsumerMessage{Message: &message{
+		eventTime:   time.Unix(seconds, 0),
+		publishTime: time.Unix(1000+seconds, 0),
+	}}
+}
+
+func receiveEventTimes(t *testing.T, ch chan ConsumerMessage, n int, timeout time.Duration) []int64 {
+	var res []int64
+	for i := 0; i < n; i++ {
+		select {
+		case cm := <-ch:
+			res = append(res, cm.EventTime().Unix())
+		case <-time.After(timeout):
+			t.Fatalf("timed out after receiving %v", res)
+		}
+	}
+	return res
+}
+
+func TestEventTimeMergerOrdersByEventTime(t *testing.T) {
+	out := make(chan ConsumerMessage, 10)
+	closeCh := make(chan struct{})
+	defer close(closeCh)
+	m := newEventTimeMerger(time.Hour, 100, out, closeCh)
+
+	m.in <- eventTimeMessage(30)
+	m.in <- eventTimeMessage(10)
+	m.in <- eventTimeMessage(20)
+
+	// nothing is released until the watermark passes the held messages
+	select {
+	case cm := <-out:
+		t.Fatalf("unexpected message %v", cm.EventTime())
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	m.in <- eventTimeMessage(30 + 3600)
+	assert.Equal(t, []int64{10, 20, 30}, receiveEventTimes(t, out, 3, time.Second))
+}
+
+func TestEventTimeMergerLatenessWindow(t *testing.T) {
+	out := make(chan ConsumerMessage, 10)
+	closeCh := make(chan struct{})
+	defer close(closeCh)
+	m := newEventTimeMerger(20*time.Millisecond, 100, out, closeCh)
+
+	m.in <- eventTimeMessage(2)
+	m.in <- eventTimeMessage(1)
+
+	// the messages are released after being held for the window
+	assert.Equal(t, []int64{1, 2}, receiveEventTimes(t, out, 2, time.Second))
+}
+
+func TestEventTimeMergerMaxBuffered(t *testing.T) {
+	out := make(chan ConsumerMessage, 10)
+	closeCh := make(chan struct{})
+	defer close(closeCh)
+	m := newEventTimeMerger(time.Hour, 2, out, closeCh)
+
+	m.in <- eventTimeMessage(3)
+	m.in <- eventTimeMessage(1)
+	m.in <- eventTimeMessage(2)
+
+	assert.Equal(t, []int64{1}, receiveEventTimes(t, out, 1, time.Second))
+
+	m.clear()
+	select {
+	case cm := <-out:
+		t.Fatalf("unexpected message %v", cm.EventTime())
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestEventTimeMergerBoundedQueue(t *testing.T) {
+	out := make(chan ConsumerMessage, 10)
+	// the application doesn't receive, the receiver queue is full
+	for i := 0; i < cap(out); i++ {
+		out <- eventTimeMessage(-1)
+	}
+	closeCh := make(chan struct{})
+	defer close(closeCh)
+	m := newEventTimeMerger(time.Hour, 2, out, closeCh)
+
+	for i := 0; i < 10; i++ {
+		m.in <- eventTimeMessage(int64(i))
+	}
+	// the merger stops reading once more than maxBuffered messages are held
+	assert.Eventually(t, func() bool { return len(m.in) == 7 }, time.Second, 10*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, 7, len(m.in))
+
+	// the messages not read yet are dropped along with the held ones
+	m.clear()
+	assert.Equal(t, 0, len(m.in))
+	assert.Equal(t, []int64{-1, -1, -1, -1, -1, -1, -1, -1, -1, -1}, receiveEventTimes(t, out, 10, time.Second))
+	select {
+	case cm := <-out:
+		t.Fatalf("unexpected message %v", cm.EventTime())
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestMessageEventTime(t *testing.T) {
+	msg := &message{
+		eventTime:   timeFromUnixTimestampMillis(0),
+		publishTime: time.Unix(100, 0),
+	}
+	assert.Equal(t, time.Unix(100, 0), messageEventTime(msg))
+
+	msg.eventTime = time.Unix(50, 0)
+	assert.Equal(t, time.Unix(50, 0), messageEventTime(msg))
+}
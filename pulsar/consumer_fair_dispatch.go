@@ -0,0 +1,135 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsar
+
+// PartitionDispatchMode selects how the messages of the partitions of a topic are interleaved in the receive queue
+// of a consumer
+type PartitionDispatchMode int
+
+const (
+	// StrictThroughputDispatch delivers the messages of the partitions as they are received, a hot partition may
+	// fill the receive queue of the consumer at the expense of the others
+	StrictThroughputDispatch PartitionDispatchMode = iota
+
+	// FairDispatch queues the messages per partition and delivers them round-robin across the partitions, so a hot
+	// partition can't starve the others, at the cost of an extra hand-off for each message
+	FairDispatch
+)
+
+// fairDispatcher interleaves the messages received from the partitions of a topic round-robin, see
+// ConsumerOptions.PartitionDispatch. The messages are queued per partition, at most maxBuffered of them in total.
+type fairDispatcher struct {
+	maxBuffered int
+
+	in      chan ConsumerMessage
+	out     chan ConsumerMessage
+	clearCh chan chan struct{}
+	closeCh <-chan struct{}
+
+	queues   map[string][]ConsumerMessage
+	buffered int
+	// ring is the partitions with queued messages in round-robin order, next the one delivering next
+	ring []string
+	next int
+}
+
+func newFairDispatcher(maxBuffered int, out chan ConsumerMessage, closeCh <-chan struct{}) *fairDispatcher {
+	if maxBuffered <= 0 {
+		maxBuffered = defaultReceiverQueueSize
+	}
+	d := &fairDispatcher{
+		maxBuffered: maxBuffered,
+		in:          make(chan ConsumerMessage),
+		out:         out,
+		clearCh:     make(chan chan struct{}),
+		closeCh:     closeCh,
+		queues:      make(map[string][]ConsumerMessage),
+	}
+	go d.run()
+	return d
+}
+
+func (d *fairDispatcher) run() {
+	for {
+		var in chan ConsumerMessage
+		if d.buffered < d.maxBuffered {
+			in = d.in
+		}
+		var out chan ConsumerMessage
+		var next ConsumerMessage
+		if d.buffered > 0 {
+			out = d.out
+			next = d.queues[d.ring[d.next]][0]
+		}
+
+		select {
+		case <-d.closeCh:
+			return
+		case cm := <-in:
+			d.push(cm)
+		case out <- next:
+			d.pop()
+		case done := <-d.clearCh:
+			d.queues = make(map[string][]ConsumerMessage)
+			d.buffered = 0
+			d.ring = nil
+			d.next = 0
+			close(done)
+		}
+	}
+}
+
+func (d *fairDispatcher) push(cm ConsumerMessage) {
+	partition := cm.Message.Topic()
+	queue := d.queues[partition]
+	if len(queue) == 0 {
+		d.ring = append(d.ring, partition)
+	}
+	d.queues[partition] = append(queue, cm)
+	d.buffered++
+}
+
+// pop removes the message delivered and moves on to the next partition
+func (d *fairDispatcher) pop() {
+	partition := d.ring[d.next]
+	queue := d.queues[partition]
+	queue[0] = ConsumerMessage{}
+	queue = queue[1:]
+	d.buffered--
+
+	if len(queue) == 0 {
+		delete(d.queues, partition)
+		d.ring = append(d.ring[:d.next], d.ring[d.next+1:]...)
+	} else {
+		d.queues[partition] = queue
+		d.next++
+	}
+	if d.next >= len(d.ring) {
+		d.next = 0
+	}
+}
+
+// clear drops the queued messages, e.g. after a seek
+func (d *fairDispatcher) clear() {
+	done := make(chan struct{})
+	select {
+	case d.clearCh <- done:
+		<-done
+	case <-d.closeCh:
+	}
+}
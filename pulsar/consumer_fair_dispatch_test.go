@@ -0,0 +1,82 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsar
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func partitionMessage(partition string, i int) ConsumerMessage {
+	return ConsumerMessage{Message: &message{topic: partition, key: partition + string(rune('0'+i))}}
+}
+
+func receiveKeys(t *testing.T, ch chan ConsumerMessage, n int) []string {
+	var res []string
+	for i := 0; i < n; i++ {
+		select {
+		case cm := <-ch:
+			res = append(res, cm.Key())
+		case <-time.After(time.Second):
+			t.Fatalf("timed out after receiving %v", res)
+		}
+	}
+	return res
+}
+
+func TestFairDispatcherRoundRobin(t *testing.T) {
+	out := make(chan ConsumerMessage)
+	closeCh := make(chan struct{})
+	defer close(closeCh)
+	d := newFairDispatcher(100, out, closeCh)
+
+	for i := 0; i < 4; i++ {
+		d.in <- partitionMessage("a", i)
+	}
+	d.in <- partitionMessage("b", 0)
+	d.in <- partitionMessage("b", 1)
+	d.in <- partitionMessage("c", 0)
+
+	assert.Equal(t, []string{"a0", "b0", "c0", "a1", "b1", "a2", "a3"}, receiveKeys(t, out, 7))
+
+	// a partition with no more messages joins the end of the round
+	d.in <- partitionMessage("c", 1)
+	d.in <- partitionMessage("a", 4)
+	assert.Equal(t, []string{"c1", "a4"}, receiveKeys(t, out, 2))
+}
+
+func TestFairDispatcherMaxBuffered(t *testing.T) {
+	out := make(chan ConsumerMessage)
+	closeCh := make(chan struct{})
+	defer close(closeCh)
+	d := newFairDispatcher(2, out, closeCh)
+
+	d.in <- partitionMessage("a", 0)
+	d.in <- partitionMessage("a", 1)
+	select {
+	case d.in <- partitionMessage("b", 0):
+		t.Fatal("the dispatcher must not hold more than maxBuffered messages")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	d.clear()
+	d.in <- partitionMessage("b", 0)
+	assert.Equal(t, []string{"b0"}, receiveKeys(t, out, 1))
+}
@@ -19,6 +19,7 @@ package pulsar
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"math/rand"
 	"strconv"
@@ -38,8 +39,12 @@ type acker interface {
 	// AckID does not handle errors returned by the Broker side, so no need to wait for doneCh to finish.
 	AckID(id MessageID) error
 	AckIDWithResponse(id MessageID) error
+	AckIDList(msgIDs []MessageID) error
+	AckIDListWithResponse(msgIDs []MessageID) error
 	AckIDCumulative(msgID MessageID) error
 	AckIDWithResponseCumulative(msgID MessageID) error
+	AckIDWithTxn(msgID MessageID, txn Transaction) error
+	AckIDCumulativeWithTxn(msgID MessageID, txn Transaction) error
 	NackID(id MessageID)
 	NackMsg(msg Message)
 }
@@ -52,16 +57,25 @@ type consumer struct {
 	consumers                 []*partitionConsumer
 	consumerName              string
 	disableForceTopicCreation bool
+	receiverQueueSize         int
+	startMessageID            *trackingMessageID
 
 	// channel used to deliver message to clients
 	messageCh chan ConsumerMessage
+	// channel the partition consumers deliver the messages to, messageCh unless the messages are reordered
+	// by eventTimeMerger or interleaved by fairDispatcher
+	partitionsCh    chan ConsumerMessage
+	eventTimeMerger *eventTimeMerger
+	fairDispatcher  *fairDispatcher
 
 	dlq           *dlqRouter
 	rlq           *retryRouter
 	closeOnce     sync.Once
 	closeCh       chan struct{}
-	errorCh       chan error
+	asyncErrors   *consumerErrors
+	limiter       *rateLimiter
 	stopDiscovery func()
+	receivers     receiverQueue
 
 	log     log.Logger
 	metrics *internal.LeveledMetrics
@@ -72,14 +86,37 @@ func newConsumer(client *client, options ConsumerOptions) (Consumer, error) {
 		return nil, newError(TopicNotFound, "topic is required")
 	}
 
+	if options.Topic != "" {
+		if err := client.checkTopicsExist(options.Topic); err != nil {
+			return nil, err
+		}
+	} else if err := client.checkTopicsExist(options.Topics...); err != nil {
+		return nil, err
+	}
+
 	if options.SubscriptionName == "" {
 		return nil, newError(SubscriptionNotFound, "subscription name is required for consumer")
 	}
 
+	if _, err := client.initAuth(options.Authentication); err != nil {
+		return nil, err
+	}
+
 	if options.ReceiverQueueSize <= 0 {
 		options.ReceiverQueueSize = defaultReceiverQueueSize
 	}
 
+	if options.AckReceiptChannel != nil && !options.AckWithResponse &&
+		(options.AckGroupingOptions == nil || !options.AckGroupingOptions.ReceiptEnabled) {
+		return nil, newError(InvalidConfiguration,
+			"AckReceiptChannel requires AckWithResponse or AckGroupingOptions.ReceiptEnabled")
+	}
+
+	if options.DLQ == nil && (options.MessageFilter != nil && options.FilterRejection == RouteMessageToDLQ ||
+		options.ValidateSchema && options.DecodeFailure == RouteMessageToDLQ) {
+		return nil, newError(InvalidConfiguration, "RouteMessageToDLQ requires a DLQ policy")
+	}
+
 	if options.Interceptors == nil {
 		options.Interceptors = defaultConsumerInterceptors
 	}
@@ -119,31 +156,11 @@ func newConsumer(client *client, options ConsumerOptions) (Consumer, error) {
 		} else if len(options.Topics) > 0 {
 			usingTopic = options.Topics[0]
 		}
-		tn, err := internal.ParseTopicName(usingTopic)
+		retryTopic, dlqTopic, err := defaultRetryAndDeadLetterTopics(client, usingTopic, options.SubscriptionName)
 		if err != nil {
 			return nil, err
 		}
 
-		topicName := internal.TopicNameWithoutPartitionPart(tn)
-
-		retryTopic := topicName + "-" + options.SubscriptionName + RetryTopicSuffix
-		dlqTopic := topicName + "-" + options.SubscriptionName + DlqTopicSuffix
-
-		oldRetryTopic := tn.Domain + "://" + tn.Namespace + "/" + options.SubscriptionName + RetryTopicSuffix
-		oldDlqTopic := tn.Domain + "://" + tn.Namespace + "/" + options.SubscriptionName + DlqTopicSuffix
-
-		if r, err := client.lookupService.GetPartitionedTopicMetadata(oldRetryTopic); err == nil &&
-			r != nil &&
-			r.Partitions > 0 {
-			retryTopic = oldRetryTopic
-		}
-
-		if r, err := client.lookupService.GetPartitionedTopicMetadata(oldDlqTopic); err == nil &&
-			r != nil &&
-			r.Partitions > 0 {
-			dlqTopic = oldDlqTopic
-		}
-
 		if options.DLQ == nil {
 			options.DLQ = &DLQPolicy{
 				MaxDeliveries:    MaxReconsumeTimes,
@@ -174,6 +191,8 @@ func newConsumer(client *client, options ConsumerOptions) (Consumer, error) {
 	if err != nil {
 		return nil, err
 	}
+	asyncErrors := newConsumerErrors()
+	limiter := newRateLimiter(options.RateLimit)
 
 	// normalize as FQDN topics
 	var tns []*internal.TopicName
@@ -192,7 +211,7 @@ func newConsumer(client *client, options ConsumerOptions) (Consumer, error) {
 		if err != nil {
 			return nil, err
 		}
-		return newInternalConsumer(client, options, topic, messageCh, dlq, rlq, false)
+		return newInternalConsumer(client, options, topic, messageCh, dlq, rlq, asyncErrors, limiter, false)
 	}
 
 	if len(options.Topics) > 1 {
@@ -204,12 +223,16 @@ func newConsumer(client *client, options ConsumerOptions) (Consumer, error) {
 		}
 		options.Topics = distinct(options.Topics)
 
+		if options.TopicWeights, err = normalizeTopicWeights(options.Topics, options.TopicWeights); err != nil {
+			return nil, err
+		}
+
 		err = addMessageCryptoIfMissing(client, &options, options.Topics)
 		if err != nil {
 			return nil, err
 		}
 
-		return newMultiTopicConsumer(client, options, options.Topics, messageCh, dlq, rlq)
+		return newMultiTopicConsumer(client, options, options.Topics, messageCh, dlq, rlq, asyncErrors, limiter)
 	}
 
 	if options.TopicsPattern != "" {
@@ -228,14 +251,47 @@ func newConsumer(client *client, options ConsumerOptions) (Consumer, error) {
 			return nil, err
 		}
 
-		return newRegexConsumer(client, options, tn, pattern, messageCh, dlq, rlq)
+		return newRegexConsumer(client, options, tn, pattern, messageCh, dlq, rlq, asyncErrors, limiter)
 	}
 
 	return nil, newError(InvalidTopicName, "topic name is required for consumer")
 }
 
+// defaultRetryAndDeadLetterTopics returns the names of the retry and dead letter topics of the subscription,
+// the topics named after the namespace by older versions are used if they exist.
+func defaultRetryAndDeadLetterTopics(client *client, topic, subscription string) (retryTopic, dlqTopic string,
+	err error) {
+	tn, err := internal.ParseTopicName(topic)
+	if err != nil {
+		return "", "", err
+	}
+
+	topicName := internal.TopicNameWithoutPartitionPart(tn)
+
+	retryTopic = topicName + "-" + subscription + RetryTopicSuffix
+	dlqTopic = topicName + "-" + subscription + DlqTopicSuffix
+
+	oldRetryTopic := tn.Domain + "://" + tn.Namespace + "/" + subscription + RetryTopicSuffix
+	oldDlqTopic := tn.Domain + "://" + tn.Namespace + "/" + subscription + DlqTopicSuffix
+
+	if r, err := client.lookupService.GetPartitionedTopicMetadata(oldRetryTopic); err == nil &&
+		r != nil &&
+		r.Partitions > 0 {
+		retryTopic = oldRetryTopic
+	}
+
+	if r, err := client.lookupService.GetPartitionedTopicMetadata(oldDlqTopic); err == nil &&
+		r != nil &&
+		r.Partitions > 0 {
+		dlqTopic = oldDlqTopic
+	}
+
+	return retryTopic, dlqTopic, nil
+}
+
 func newInternalConsumer(client *client, options ConsumerOptions, topic string,
-	messageCh chan ConsumerMessage, dlq *dlqRouter, rlq *retryRouter, disableForceTopicCreation bool) (*consumer, error) {
+	messageCh chan ConsumerMessage, dlq *dlqRouter, rlq *retryRouter, asyncErrors *consumerErrors,
+	limiter *rateLimiter, disableForceTopicCreation bool) (*consumer, error) {
 
 	consumer := &consumer{
 		topic:                     topic,
@@ -244,16 +300,41 @@ func newInternalConsumer(client *client, options ConsumerOptions, topic string,
 		disableForceTopicCreation: disableForceTopicCreation,
 		messageCh:                 messageCh,
 		closeCh:                   make(chan struct{}),
-		errorCh:                   make(chan error),
+		asyncErrors:               asyncErrors,
+		limiter:                   limiter,
 		dlq:                       dlq,
 		rlq:                       rlq,
 		log:                       client.log.SubLogger(log.Fields{"topic": topic}),
 		consumerName:              options.Name,
-		metrics:                   client.metrics.GetLeveledMetrics(topic),
+		metrics:                   client.resourceMetrics().GetLeveledConsumerMetrics(topic, options.SubscriptionName),
+		receiverQueueSize:         topicReceiverQueueSize(options.ReceiverQueueSize, options.TopicWeights, topic),
+	}
+
+	if options.StartMessageID != nil {
+		startMessageID, err := toStartMessageID(options.StartMessageID)
+		if err != nil {
+			return nil, newError(InvalidConfiguration, fmt.Sprintf("invalid StartMessageID: %v", err))
+		}
+		if err := consumer.checkStartPartition(startMessageID); err != nil {
+			return nil, err
+		}
+		consumer.startMessageID = startMessageID
+	}
+
+	consumer.partitionsCh = messageCh
+	if options.EventTimeOrderingWindow > 0 {
+		consumer.eventTimeMerger = newEventTimeMerger(options.EventTimeOrderingWindow, options.ReceiverQueueSize,
+			messageCh, consumer.closeCh)
+		consumer.partitionsCh = consumer.eventTimeMerger.in
+	} else if options.PartitionDispatch == FairDispatch {
+		consumer.fairDispatcher = newFairDispatcher(options.ReceiverQueueSize, messageCh, consumer.closeCh)
+		consumer.partitionsCh = consumer.fairDispatcher.in
 	}
 
 	err := consumer.internalTopicSubscribeToPartitions()
 	if err != nil {
+		// stops the event time merger
+		close(consumer.closeCh)
 		return nil, err
 	}
 
@@ -327,12 +408,18 @@ func (c *consumer) internalTopicSubscribeToPartitions() error {
 
 	c.consumers = make([]*partitionConsumer, newNumPartitions)
 
-	// When for some reason (eg: forced deletion of sub partition) causes oldNumPartitions> newNumPartitions,
-	// we need to rebuild the cache of new consumers, otherwise the array will be out of bounds.
-	if oldConsumers != nil && oldNumPartitions < newNumPartitions {
-		// Copy over the existing consumer instances
-		for i := 0; i < oldNumPartitions; i++ {
+	// When the topic has fewer partitions (eg: recreated with fewer partitions), the consumers of the removed
+	// partitions are closed instead of reconnecting to partitions which don't exist anymore
+	oldTopics := make([]string, oldNumPartitions)
+	for i, pc := range oldConsumers {
+		oldTopics[i] = pc.topic
+	}
+	var removed []*partitionConsumer
+	for i, reuse := range partitionsToReuse(oldTopics, partitions) {
+		if reuse {
 			c.consumers[i] = oldConsumers[i]
+		} else {
+			removed = append(removed, oldConsumers[i])
 		}
 	}
 
@@ -342,23 +429,25 @@ func (c *consumer) internalTopicSubscribeToPartitions() error {
 		consumer  *partitionConsumer
 	}
 
-	receiverQueueSize := c.options.ReceiverQueueSize
+	receiverQueueSize := c.receiverQueueSize
 	metadata := c.options.Properties
 	subProperties := c.options.SubscriptionProperties
 
-	startPartition := oldNumPartitions
-	partitionsToAdd := newNumPartitions - oldNumPartitions
-
-	if partitionsToAdd < 0 {
-		partitionsToAdd = newNumPartitions
-		startPartition = 0
+	partitionsToAdd := 0
+	for _, pc := range c.consumers {
+		if pc == nil {
+			partitionsToAdd++
+		}
 	}
 
 	var wg sync.WaitGroup
 	ch := make(chan ConsumerError, partitionsToAdd)
 	wg.Add(partitionsToAdd)
 
-	for partitionIdx := startPartition; partitionIdx < newNumPartitions; partitionIdx++ {
+	for partitionIdx := 0; partitionIdx < newNumPartitions; partitionIdx++ {
+		if c.consumers[partitionIdx] != nil {
+			continue
+		}
 		partitionTopic := partitions[partitionIdx]
 
 		go func(idx int, pt string) {
@@ -366,7 +455,7 @@ func (c *consumer) internalTopicSubscribeToPartitions() error {
 
 			var nackRedeliveryDelay time.Duration
 			if c.options.NackRedeliveryDelay == 0 {
-				nackRedeliveryDelay = defaultNackRedeliveryDelay
+				nackRedeliveryDelay = c.client.defaultNackRedeliveryDelay()
 			} else {
 				nackRedeliveryDelay = c.options.NackRedeliveryDelay
 			}
@@ -376,15 +465,18 @@ func (c *consumer) internalTopicSubscribeToPartitions() error {
 				subscription:                c.options.SubscriptionName,
 				subscriptionType:            c.options.Type,
 				subscriptionInitPos:         c.options.SubscriptionInitialPosition,
+				subscriptionStartID:         c.startMessageID,
+				startMessageIDInclusive:     c.options.StartMessageIDInclusive,
 				partitionIdx:                idx,
 				receiverQueueSize:           receiverQueueSize,
+				queueCapacity:               c.options.ReceiverQueueSize,
 				nackRedeliveryDelay:         nackRedeliveryDelay,
 				nackBackoffPolicy:           c.options.NackBackoffPolicy,
 				metadata:                    metadata,
 				subProperties:               subProperties,
 				replicateSubscriptionState:  c.options.ReplicateSubscriptionState,
-				startMessageID:              nil,
-				subscriptionMode:            durable,
+				startMessageID:              c.nonDurableStartID(idx),
+				subscriptionMode:            c.options.SubscriptionMode,
 				readCompacted:               c.options.ReadCompacted,
 				interceptors:                c.options.Interceptors,
 				maxReconnectToBroker:        c.options.MaxReconnectToBroker,
@@ -393,14 +485,28 @@ func (c *consumer) internalTopicSubscribeToPartitions() error {
 				schema:                      c.options.Schema,
 				decryption:                  c.options.Decryption,
 				ackWithResponse:             c.options.AckWithResponse,
+				ackReceiptCh:                c.options.AckReceiptChannel,
 				maxPendingChunkedMessage:    c.options.MaxPendingChunkedMessage,
+				maxPendingChunkedBytes:      c.options.MaxPendingChunkedMessageBytes,
 				expireTimeOfIncompleteChunk: c.options.ExpireTimeOfIncompleteChunk,
 				autoAckIncompleteChunk:      c.options.AutoAckIncompleteChunk,
 				consumerEventListener:       c.options.EventListener,
 				enableBatchIndexAck:         c.options.EnableBatchIndexAcknowledgment,
-				ackGroupingOptions:          c.options.AckGroupingOptions,
+				ackGroupingOptions:          c.ackGroupingOptions(idx, newNumPartitions),
+				ackHoleTracking:             c.options.AckHoleTracking,
+				autoCumulativeAck:           c.options.AutoCumulativeAck,
+				authProvider:                authProviderOf(c.options.Authentication),
+				decodeKafkaEntries:          c.options.DecodeKafkaEntries,
+				messageFilter:               c.options.MessageFilter,
+				filterRejection:             c.options.FilterRejection,
+				validateSchema:              c.options.ValidateSchema,
+				validateStartMessageID:      c.options.ValidateStartMessageID,
+				decodeFailure:               c.options.DecodeFailure,
+				asyncErrors:                 c.asyncErrors,
+				rateLimiter:                 c.limiter,
+				oversizedMessageThreshold:   oversizedMessageThreshold(c.options.OversizedMessageThreshold),
 			}
-			cons, err := newPartitionConsumer(c, c.client, opts, c.messageCh, c.dlq, c.metrics)
+			cons, err := newPartitionConsumer(c, c.client, opts, c.partitionsCh, c.dlq, c.metrics)
 			ch <- ConsumerError{
 				err:       err,
 				partition: idx,
@@ -414,67 +520,116 @@ func (c *consumer) internalTopicSubscribeToPartitions() error {
 		close(ch)
 	}()
 
+	var created []*partitionConsumer
 	for ce := range ch {
 		if ce.err != nil {
 			err = ce.err
 		} else {
 			c.consumers[ce.partition] = ce.consumer
+			created = append(created, ce.consumer)
 		}
 	}
 
 	if err != nil {
 		// Since there were some failures,
-		// cleanup all the partitions that succeeded in creating the consumer
-		for _, c := range c.consumers {
-			if c != nil {
-				c.Close()
-			}
+		// cleanup the partitions that succeeded in creating the consumer
+		for _, pc := range created {
+			pc.Close()
+		}
+		if oldConsumers != nil {
+			c.consumers = oldConsumers
 		}
 		return err
 	}
 
-	if newNumPartitions < oldNumPartitions {
-		c.metrics.ConsumersPartitions.Set(float64(newNumPartitions))
-	} else {
-		c.metrics.ConsumersPartitions.Add(float64(partitionsToAdd))
+	if len(removed) > 0 {
+		c.removePartitions(removed, oldNumPartitions, newNumPartitions)
 	}
+
+	c.metrics.ConsumersPartitions.Add(float64(len(created) - len(removed)))
 	return nil
 }
 
+// removePartitions closes the consumers of the partitions removed from the topic
+func (c *consumer) removePartitions(removed []*partitionConsumer, oldNumPartitions, newNumPartitions int) {
+	event := PartitionsRemovedEvent{
+		Topic:         c.topic,
+		OldPartitions: oldNumPartitions,
+		NewPartitions: newNumPartitions,
+		DetectedAt:    time.Now(),
+	}
+	for _, pc := range removed {
+		event.Removed = append(event.Removed, pc.topic)
+		pc.Close()
+	}
+	c.log.WithField("removed", event.Removed).
+		Warn("Closed the consumers of the partitions removed from the topic")
+	if listener, ok := c.options.EventListener.(PartitionsRemovedListener); ok {
+		listener.PartitionsRemoved(event)
+	}
+}
+
 func (c *consumer) Subscription() string {
 	return c.options.SubscriptionName
 }
 
+func (c *consumer) KeyHashRanges() map[string][]KeyHashRange {
+	c.Lock()
+	defer c.Unlock()
+
+	ranges := make(map[string][]KeyHashRange)
+	for _, pc := range c.consumers {
+		if r := pc.KeyHashRanges(); len(r) > 0 {
+			ranges[pc.topic] = r
+		}
+	}
+	return ranges
+}
+
+func (c *consumer) Errors() <-chan error {
+	return c.asyncErrors.errors()
+}
+
 func (c *consumer) Unsubscribe() error {
+	return c.unsubscribe(false)
+}
+
+func (c *consumer) UnsubscribeForce() error {
+	return c.unsubscribe(true)
+}
+
+func (c *consumer) unsubscribe(force bool) error {
 	c.Lock()
 	defer c.Unlock()
 
 	var errMsg string
+	var firstErr error
 	for _, consumer := range c.consumers {
-		if err := consumer.Unsubscribe(); err != nil {
+		if err := consumer.unsubscribe(force); err != nil {
 			errMsg += fmt.Sprintf("topic %s, subscription %s: %s", consumer.topic, c.Subscription(), err)
+			if firstErr == nil {
+				firstErr = err
+			}
 		}
 	}
-	if errMsg != "" {
+	if firstErr != nil {
+		// keep the result of the error, e.g. ConsumerBusy
+		var pulsarErr *Error
+		if errors.As(firstErr, &pulsarErr) {
+			return newError(pulsarErr.Result(), errMsg)
+		}
 		return fmt.Errorf(errMsg)
 	}
 	return nil
 }
 
 func (c *consumer) Receive(ctx context.Context) (message Message, err error) {
-	for {
-		select {
-		case <-c.closeCh:
-			return nil, newError(ConsumerClosed, "consumer closed")
-		case cm, ok := <-c.messageCh:
-			if !ok {
-				return nil, newError(ConsumerClosed, "consumer closed")
-			}
-			return cm.Message, nil
-		case <-ctx.Done():
-			return nil, ctx.Err()
-		}
-	}
+	return c.receivers.receive(ctx, c.messageCh, c.closeCh)
+}
+
+// TryReceive returns a message if one is available right away, nil otherwise
+func (c *consumer) TryReceive() (Message, error) {
+	return c.receivers.tryReceive(c.messageCh, c.closeCh)
 }
 
 // Chan return the message chan to users
@@ -484,40 +639,87 @@ func (c *consumer) Chan() <-chan ConsumerMessage {
 
 // Ack the consumption of a single message
 func (c *consumer) Ack(msg Message) error {
-	return c.AckID(msg.ID())
+	return c.ackID(msg.ID(), msg)
 }
 
 // AckID the consumption of a single message, identified by its MessageID
 func (c *consumer) AckID(msgID MessageID) error {
+	return c.ackID(msgID, nil)
+}
+
+func (c *consumer) ackID(msgID MessageID, msg Message) error {
 	if err := c.checkMsgIDPartition(msgID); err != nil {
 		return err
 	}
 
+	var err error
 	if c.options.AckWithResponse {
-		return c.consumers[msgID.PartitionIdx()].AckIDWithResponse(msgID)
+		err = c.consumers[msgID.PartitionIdx()].AckIDWithResponse(msgID)
+	} else {
+		err = c.consumers[msgID.PartitionIdx()].AckID(msgID)
 	}
-
-	return c.consumers[msgID.PartitionIdx()].AckID(msgID)
+	if err == nil {
+		c.client.audit.acked(c.options.SubscriptionName, msgID, msg, false)
+	}
+	return err
 }
 
 // AckCumulative the reception of all the messages in the stream up to (and including)
 // the provided message, identified by its MessageID
 func (c *consumer) AckCumulative(msg Message) error {
-	return c.AckIDCumulative(msg.ID())
+	return c.ackIDCumulative(msg.ID(), msg)
 }
 
 // AckIDCumulative the reception of all the messages in the stream up to (and including)
 // the provided message, identified by its MessageID
 func (c *consumer) AckIDCumulative(msgID MessageID) error {
+	return c.ackIDCumulative(msgID, nil)
+}
+
+func (c *consumer) ackIDCumulative(msgID MessageID, msg Message) error {
 	if err := c.checkMsgIDPartition(msgID); err != nil {
 		return err
 	}
 
+	var err error
 	if c.options.AckWithResponse {
-		return c.consumers[msgID.PartitionIdx()].AckIDWithResponseCumulative(msgID)
+		err = c.consumers[msgID.PartitionIdx()].AckIDWithResponseCumulative(msgID)
+	} else {
+		err = c.consumers[msgID.PartitionIdx()].AckIDCumulative(msgID)
+	}
+	if err == nil {
+		c.client.audit.acked(c.options.SubscriptionName, msgID, msg, true)
+	}
+	return err
+}
+
+// AckWithTxn the consumption of a single message as part of the transaction
+func (c *consumer) AckWithTxn(msg Message, txn Transaction) error {
+	msgID := msg.ID()
+	if err := c.checkMsgIDPartition(msgID); err != nil {
+		return err
+	}
+
+	err := c.consumers[msgID.PartitionIdx()].AckIDWithTxn(msgID, txn)
+	if err == nil {
+		c.client.audit.acked(c.options.SubscriptionName, msgID, msg, false)
 	}
+	return err
+}
 
-	return c.consumers[msgID.PartitionIdx()].AckIDCumulative(msgID)
+// AckCumulativeWithTxn the reception of all the messages in the stream up to (and including)
+// the provided message as part of the transaction
+func (c *consumer) AckCumulativeWithTxn(msg Message, txn Transaction) error {
+	msgID := msg.ID()
+	if err := c.checkMsgIDPartition(msgID); err != nil {
+		return err
+	}
+
+	err := c.consumers[msgID.PartitionIdx()].AckIDCumulativeWithTxn(msgID, txn)
+	if err == nil {
+		c.client.audit.acked(c.options.SubscriptionName, msgID, msg, true)
+	}
+	return err
 }
 
 // ReconsumeLater mark a message for redelivery after custom delay
@@ -553,6 +755,9 @@ func (c *consumer) ReconsumeLaterWithCustomProperties(msg Message, customPropert
 		props[SysPropertyOriginMessageID] = msgID.messageID.String()
 	}
 	props[SysPropertyReconsumeTimes] = strconv.Itoa(reconsumeTimes)
+	if delay == 0 {
+		delay = c.dlq.policy.retryDelay(reconsumeTimes)
+	}
 	props[SysPropertyDelayTime] = fmt.Sprintf("%d", int64(delay)/1e6)
 
 	consumerMsg := ConsumerMessage{
@@ -564,9 +769,9 @@ func (c *consumer) ReconsumeLaterWithCustomProperties(msg Message, customPropert
 		},
 	}
 	if uint32(reconsumeTimes) > c.dlq.policy.MaxDeliveries {
-		c.dlq.Chan() <- consumerMsg
+		c.dlq.route(consumerMsg)
 	} else {
-		c.rlq.Chan() <- RetryMessage{
+		c.rlq.route(RetryMessage{
 			consumerMsg: consumerMsg,
 			producerMsg: ProducerMessage{
 				Payload:      msg.Payload(),
@@ -575,7 +780,7 @@ func (c *consumer) ReconsumeLaterWithCustomProperties(msg Message, customPropert
 				Properties:   props,
 				DeliverAfter: delay,
 			},
-		}
+		})
 	}
 }
 
@@ -605,6 +810,31 @@ func (c *consumer) NackID(msgID MessageID) {
 	c.consumers[msgID.PartitionIdx()].NackID(msgID)
 }
 
+// setReceiverQueueSize resizes the receiver queues of the partitions
+func (c *consumer) setReceiverQueueSize(size int) {
+	c.Lock()
+	defer c.Unlock()
+	c.receiverQueueSize = size
+	for _, pc := range c.consumers {
+		if pc != nil {
+			pc.setReceiverQueueSize(size)
+		}
+	}
+}
+
+func (c *consumer) reloadNackRedeliveryDelay(delay time.Duration) {
+	if c.options.NackRedeliveryDelay != 0 {
+		return
+	}
+	c.Lock()
+	defer c.Unlock()
+	for _, pc := range c.consumers {
+		if pc != nil {
+			pc.nackTracker.setDelay(delay)
+		}
+	}
+}
+
 func (c *consumer) Close() {
 	c.closeOnce.Do(func() {
 		c.stopDiscovery()
@@ -635,6 +865,15 @@ func (c *consumer) Seek(msgID MessageID) error {
 	defer c.Unlock()
 
 	if len(c.consumers) > 1 {
+		// the first and the last message ids are the same on all the partitions
+		if mid := toTrackingMessageID(msgID); mid != nil &&
+			(mid.equal(earliestMessageID) || mid.equal(latestMessageID)) {
+			err := c.seekPartitions(func(pc *partitionConsumer) error {
+				return pc.Seek(msgID)
+			})
+			c.clearMessageCh()
+			return err
+		}
 		return newError(SeekFailed, "for partition topic, seek command should perform on the individual partitions")
 	}
 
@@ -646,10 +885,7 @@ func (c *consumer) Seek(msgID MessageID) error {
 		return err
 	}
 
-	// clear messageCh
-	for len(c.messageCh) > 0 {
-		<-c.messageCh
-	}
+	c.clearMessageCh()
 
 	return nil
 }
@@ -657,21 +893,30 @@ func (c *consumer) Seek(msgID MessageID) error {
 func (c *consumer) SeekByTime(time time.Time) error {
 	c.Lock()
 	defer c.Unlock()
-	var errs error
 	// run SeekByTime on every partition of topic
-	for _, cons := range c.consumers {
-		if err := cons.SeekByTime(time); err != nil {
-			msg := fmt.Sprintf("unable to SeekByTime for topic=%s subscription=%s", c.topic, c.Subscription())
-			errs = pkgerrors.Wrap(newError(SeekFailed, err.Error()), msg)
-		}
+	err := c.seekPartitions(func(pc *partitionConsumer) error {
+		return pc.SeekByTime(time)
+	})
+
+	c.clearMessageCh()
+
+	if len(c.consumers) == 1 && err != nil {
+		msg := fmt.Sprintf("unable to SeekByTime for topic=%s subscription=%s", c.topic, c.Subscription())
+		return pkgerrors.Wrap(newError(SeekFailed, err.(*SeekError).Failed[c.consumers[0].topic].Error()), msg)
 	}
+	return err
+}
 
-	// clear messageCh
+func (c *consumer) clearMessageCh() {
+	if c.eventTimeMerger != nil {
+		c.eventTimeMerger.clear()
+	}
+	if c.fairDispatcher != nil {
+		c.fairDispatcher.clear()
+	}
 	for len(c.messageCh) > 0 {
 		<-c.messageCh
 	}
-
-	return errs
 }
 
 func (c *consumer) checkMsgIDPartition(msgID MessageID) error {
@@ -732,6 +977,33 @@ func toProtoSubType(st SubscriptionType) pb.CommandSubscribe_SubType {
 	return pb.CommandSubscribe_Exclusive
 }
 
+// checkStartPartition checks the start message id belongs to one of the partitions of the topic
+func (c *consumer) checkStartPartition(start *trackingMessageID) error {
+	if start.partitionIdx < 0 {
+		return nil
+	}
+	partitions, err := c.client.TopicPartitions(c.topic)
+	if err != nil {
+		return err
+	}
+	return checkStartMessageIDPartition(start, c.topic, len(partitions))
+}
+
+// nonDurableStartID returns the position a non-durable subscription of the partition starts from, nil for a
+// durable subscription whose position is kept by the broker
+func (c *consumer) nonDurableStartID(partitionIdx int) *trackingMessageID {
+	if c.options.SubscriptionMode != NonDurable {
+		return nil
+	}
+	if start := c.startMessageID; start != nil && (start.partitionIdx < 0 || int(start.partitionIdx) == partitionIdx) {
+		return start
+	}
+	if c.options.SubscriptionInitialPosition == SubscriptionPositionEarliest {
+		return &trackingMessageID{messageID: earliestMessageID}
+	}
+	return &trackingMessageID{messageID: latestMessageID}
+}
+
 func toProtoInitialPosition(p SubscriptionInitialPosition) pb.CommandSubscribe_InitialPosition {
 	switch p {
 	case SubscriptionPositionLatest:
@@ -772,5 +1044,13 @@ func addMessageCryptoIfMissing(client *client, options *ConsumerOptions, topics
 		}
 		options.Decryption.MessageCrypto = messageCrypto
 	}
+
+	if options.Decryption != nil {
+		configureKeyCache(options.Decryption)
+	}
+
+	if options.Decryption != nil && client.fipsMode {
+		return enableFIPSMessageCrypto(options.Decryption.MessageCrypto)
+	}
 	return nil
 }
@@ -40,16 +40,22 @@ type multiTopicConsumer struct {
 
 	consumers map[string]Consumer
 
-	dlq       *dlqRouter
-	rlq       *retryRouter
+	weightsLock sync.Mutex
+
+	dlq         *dlqRouter
+	rlq         *retryRouter
+	asyncErrors *consumerErrors
+
 	closeOnce sync.Once
 	closeCh   chan struct{}
+	receivers receiverQueue
 
 	log log.Logger
 }
 
 func newMultiTopicConsumer(client *client, options ConsumerOptions, topics []string,
-	messageCh chan ConsumerMessage, dlq *dlqRouter, rlq *retryRouter) (Consumer, error) {
+	messageCh chan ConsumerMessage, dlq *dlqRouter, rlq *retryRouter, asyncErrors *consumerErrors,
+	limiter *rateLimiter) (Consumer, error) {
 	mtc := &multiTopicConsumer{
 		client:       client,
 		options:      options,
@@ -58,12 +64,13 @@ func newMultiTopicConsumer(client *client, options ConsumerOptions, topics []str
 		closeCh:      make(chan struct{}),
 		dlq:          dlq,
 		rlq:          rlq,
+		asyncErrors:  asyncErrors,
 		log:          client.log.SubLogger(log.Fields{"topic": topics}),
 		consumerName: options.Name,
 	}
 
 	var errs error
-	for ce := range subscriber(client, topics, options, messageCh, dlq, rlq) {
+	for ce := range subscriber(client, topics, options, messageCh, dlq, rlq, asyncErrors, limiter) {
 		if ce.err != nil {
 			errs = pkgerrors.Wrapf(ce.err, "unable to subscribe to topic=%s", ce.topic)
 		} else {
@@ -85,10 +92,44 @@ func (c *multiTopicConsumer) Subscription() string {
 	return c.options.SubscriptionName
 }
 
+func (c *multiTopicConsumer) KeyHashRanges() map[string][]KeyHashRange {
+	ranges := make(map[string][]KeyHashRange)
+	for _, consumer := range c.consumers {
+		for t, r := range consumer.KeyHashRanges() {
+			ranges[t] = r
+		}
+	}
+	return ranges
+}
+
+func (c *multiTopicConsumer) FlushPartitionAcks(topic string) error {
+	return flushPartitionAcks(c.consumers, topic)
+}
+
+func (c *multiTopicConsumer) FlushAcks(ctx context.Context) error {
+	return flushAcks(ctx, c.consumers)
+}
+
+func (c *multiTopicConsumer) Errors() <-chan error {
+	return c.asyncErrors.errors()
+}
+
 func (c *multiTopicConsumer) Unsubscribe() error {
+	return c.unsubscribe(false)
+}
+
+func (c *multiTopicConsumer) UnsubscribeForce() error {
+	return c.unsubscribe(true)
+}
+
+func (c *multiTopicConsumer) unsubscribe(force bool) error {
 	var errs error
 	for t, consumer := range c.consumers {
-		if err := consumer.Unsubscribe(); err != nil {
+		unsubscribe := consumer.Unsubscribe
+		if force {
+			unsubscribe = consumer.UnsubscribeForce
+		}
+		if err := unsubscribe(); err != nil {
 			msg := fmt.Sprintf("unable to unsubscribe from topic=%s subscription=%s",
 				t, c.Subscription())
 			errs = pkgerrors.Wrap(err, msg)
@@ -98,19 +139,12 @@ func (c *multiTopicConsumer) Unsubscribe() error {
 }
 
 func (c *multiTopicConsumer) Receive(ctx context.Context) (message Message, err error) {
-	for {
-		select {
-		case <-c.closeCh:
-			return nil, newError(ConsumerClosed, "consumer closed")
-		case cm, ok := <-c.messageCh:
-			if !ok {
-				return nil, newError(ConsumerClosed, "consumer closed")
-			}
-			return cm.Message, nil
-		case <-ctx.Done():
-			return nil, ctx.Err()
-		}
-	}
+	return c.receivers.receive(ctx, c.messageCh, c.closeCh)
+}
+
+// TryReceive returns a message if one is available right away, nil otherwise
+func (c *multiTopicConsumer) TryReceive() (Message, error) {
+	return c.receivers.tryReceive(c.messageCh, c.closeCh)
 }
 
 // Chan return the message chan to users
@@ -120,11 +154,15 @@ func (c *multiTopicConsumer) Chan() <-chan ConsumerMessage {
 
 // Ack the consumption of a single message
 func (c *multiTopicConsumer) Ack(msg Message) error {
-	return c.AckID(msg.ID())
+	return c.ackID(msg.ID(), msg)
 }
 
 // AckID the consumption of a single message, identified by its MessageID
 func (c *multiTopicConsumer) AckID(msgID MessageID) error {
+	return c.ackID(msgID, nil)
+}
+
+func (c *multiTopicConsumer) ackID(msgID MessageID, msg Message) error {
 	mid := toTrackingMessageID(msgID)
 	if mid == nil {
 		c.log.Warnf("invalid message id type %T", msgID)
@@ -136,22 +174,31 @@ func (c *multiTopicConsumer) AckID(msgID MessageID) error {
 		return errors.New("unable to ack message because consumer is nil")
 	}
 
+	var err error
 	if c.options.AckWithResponse {
-		return mid.consumer.AckIDWithResponse(msgID)
+		err = mid.consumer.AckIDWithResponse(msgID)
+	} else {
+		err = mid.consumer.AckID(msgID)
 	}
-
-	return mid.consumer.AckID(msgID)
+	if err == nil {
+		c.client.audit.acked(c.options.SubscriptionName, msgID, msg, false)
+	}
+	return err
 }
 
 // AckCumulative the reception of all the messages in the stream up to (and including)
 // the provided message
 func (c *multiTopicConsumer) AckCumulative(msg Message) error {
-	return c.AckIDCumulative(msg.ID())
+	return c.ackIDCumulative(msg.ID(), msg)
 }
 
 // AckIDCumulative the reception of all the messages in the stream up to (and including)
 // the provided message, identified by its MessageID
 func (c *multiTopicConsumer) AckIDCumulative(msgID MessageID) error {
+	return c.ackIDCumulative(msgID, nil)
+}
+
+func (c *multiTopicConsumer) ackIDCumulative(msgID MessageID, msg Message) error {
 	mid := toTrackingMessageID(msgID)
 	if mid == nil {
 		c.log.Warnf("invalid message id type %T", msgID)
@@ -163,11 +210,59 @@ func (c *multiTopicConsumer) AckIDCumulative(msgID MessageID) error {
 		return errors.New("unable to ack message because consumer is nil")
 	}
 
+	var err error
 	if c.options.AckWithResponse {
-		return mid.consumer.AckIDWithResponseCumulative(msgID)
+		err = mid.consumer.AckIDWithResponseCumulative(msgID)
+	} else {
+		err = mid.consumer.AckIDCumulative(msgID)
+	}
+	if err == nil {
+		c.client.audit.acked(c.options.SubscriptionName, msgID, msg, true)
+	}
+	return err
+}
+
+// AckWithTxn the consumption of a single message as part of the transaction
+func (c *multiTopicConsumer) AckWithTxn(msg Message, txn Transaction) error {
+	msgID := msg.ID()
+	mid := toTrackingMessageID(msgID)
+	if mid == nil {
+		c.log.Warnf("invalid message id type %T", msgID)
+		return errors.New("invalid message id type")
+	}
+
+	if mid.consumer == nil {
+		c.log.Warnf("unable to ack messageID=%+v can not determine topic", msgID)
+		return errors.New("unable to ack message because consumer is nil")
 	}
 
-	return mid.consumer.AckIDCumulative(msgID)
+	err := mid.consumer.AckIDWithTxn(msgID, txn)
+	if err == nil {
+		c.client.audit.acked(c.options.SubscriptionName, msgID, msg, false)
+	}
+	return err
+}
+
+// AckCumulativeWithTxn the reception of all the messages in the stream up to (and including)
+// the provided message as part of the transaction
+func (c *multiTopicConsumer) AckCumulativeWithTxn(msg Message, txn Transaction) error {
+	msgID := msg.ID()
+	mid := toTrackingMessageID(msgID)
+	if mid == nil {
+		c.log.Warnf("invalid message id type %T", msgID)
+		return errors.New("invalid message id type")
+	}
+
+	if mid.consumer == nil {
+		c.log.Warnf("unable to ack messageID=%+v can not determine topic", msgID)
+		return errors.New("unable to ack message because consumer is nil")
+	}
+
+	err := mid.consumer.AckIDCumulativeWithTxn(msgID, txn)
+	if err == nil {
+		c.client.audit.acked(c.options.SubscriptionName, msgID, msg, true)
+	}
+	return err
 }
 
 func (c *multiTopicConsumer) ReconsumeLater(msg Message, delay time.Duration) {
@@ -235,6 +330,36 @@ func (c *multiTopicConsumer) NackID(msgID MessageID) {
 	mid.consumer.NackID(msgID)
 }
 
+func (c *multiTopicConsumer) SetTopicWeights(weights map[string]int) error {
+	c.weightsLock.Lock()
+	defer c.weightsLock.Unlock()
+
+	topics := make([]string, 0, len(c.consumers))
+	for t := range c.consumers {
+		topics = append(topics, t)
+	}
+	weights, err := normalizeTopicWeights(topics, weights)
+	if err != nil {
+		return err
+	}
+
+	for t, con := range c.consumers {
+		if cons, ok := con.(*consumer); ok {
+			cons.setReceiverQueueSize(topicReceiverQueueSize(c.options.ReceiverQueueSize, weights, t))
+		}
+	}
+	c.options.TopicWeights = weights
+	return nil
+}
+
+func (c *multiTopicConsumer) reloadNackRedeliveryDelay(delay time.Duration) {
+	for _, consumer := range c.consumers {
+		if r, ok := consumer.(nackDelayReloadable); ok {
+			r.reloadNackRedeliveryDelay(delay)
+		}
+	}
+}
+
 func (c *multiTopicConsumer) Close() {
 	c.closeOnce.Do(func() {
 		var wg sync.WaitGroup
@@ -265,3 +390,49 @@ func (c *multiTopicConsumer) SeekByTime(time time.Time) error {
 func (c *multiTopicConsumer) Name() string {
 	return c.consumerName
 }
+
+// normalizeTopicWeights returns the weights keyed by the fully qualified topic names, the weights
+// must be positive and the topics must be some of the given topics
+func normalizeTopicWeights(topics []string, weights map[string]int) (map[string]int, error) {
+	if len(weights) == 0 {
+		return nil, nil
+	}
+	normalized := make(map[string]int, len(weights))
+	for topic, weight := range weights {
+		tn, err := internal.ParseTopicName(topic)
+		if err != nil {
+			return nil, err
+		}
+		if !containsTopic(topics, tn) {
+			return nil, newError(InvalidConfiguration, fmt.Sprintf("weighted topic %s is not subscribed", topic))
+		}
+		if weight <= 0 {
+			return nil, newError(InvalidConfiguration, fmt.Sprintf("weight of topic %s must be positive", topic))
+		}
+		normalized[tn.Name] = weight
+	}
+	return normalized, nil
+}
+
+// topicReceiverQueueSize returns the receiver queue size of the partitions of a topic, the queue size scaled
+// by the weight of the topic relative to the highest weight
+func topicReceiverQueueSize(queueSize int, weights map[string]int, topic string) int {
+	if len(weights) == 0 {
+		return queueSize
+	}
+	maxWeight := 1
+	for _, w := range weights {
+		if w > maxWeight {
+			maxWeight = w
+		}
+	}
+	weight, ok := weights[topic]
+	if !ok {
+		weight = 1
+	}
+	size := queueSize * weight / maxWeight
+	if size < 1 {
+		size = 1
+	}
+	return size
+}
@@ -84,3 +84,26 @@ func TestMultiTopicConsumerReceive(t *testing.T) {
 	}
 	assert.Equal(t, receivedTopic1, receivedTopic2)
 }
+
+func TestTopicReceiverQueueSize(t *testing.T) {
+	weights, err := normalizeTopicWeights(
+		[]string{"persistent://public/default/a", "persistent://public/default/b", "persistent://public/default/c"},
+		map[string]int{"a": 4, "persistent://public/default/b": 1})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]int{
+		"persistent://public/default/a": 4,
+		"persistent://public/default/b": 1,
+	}, weights)
+
+	assert.Equal(t, 1000, topicReceiverQueueSize(1000, weights, "persistent://public/default/a"))
+	assert.Equal(t, 250, topicReceiverQueueSize(1000, weights, "persistent://public/default/b"))
+	// the topics without weight have a weight of 1
+	assert.Equal(t, 250, topicReceiverQueueSize(1000, weights, "persistent://public/default/c"))
+	assert.Equal(t, 1, topicReceiverQueueSize(2, weights, "persistent://public/default/c"))
+	assert.Equal(t, 1000, topicReceiverQueueSize(1000, nil, "persistent://public/default/c"))
+
+	_, err = normalizeTopicWeights([]string{"persistent://public/default/a"}, map[string]int{"b": 1})
+	assert.Error(t, err)
+	_, err = normalizeTopicWeights([]string{"persistent://public/default/a"}, map[string]int{"a": 0})
+	assert.Error(t, err)
+}
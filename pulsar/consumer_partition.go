@@ -28,8 +28,10 @@ import (
 	"sync/atomic"
 	"time"
 
+	"google.golang.org/protobuf/encoding/protowire"
 	"google.golang.org/protobuf/proto"
 
+	"github.com/apache/pulsar-client-go/pulsar/auth"
 	"github.com/apache/pulsar-client-go/pulsar/crypto"
 	"github.com/apache/pulsar-client-go/pulsar/internal"
 	"github.com/apache/pulsar-client-go/pulsar/internal/compression"
@@ -66,29 +68,20 @@ func (s consumerState) String() string {
 	}
 }
 
-type subscriptionMode int
-
-const (
-	// Make the subscription to be backed by a durable cursor that will retain messages and persist the current
-	// position
-	durable subscriptionMode = iota
-
-	// Lightweight subscription mode that doesn't have a durable cursor associated
-	nonDurable
-)
-
 const (
 	noMessageEntry = -1
 )
 
 type partitionConsumerOpts struct {
-	topic                       string
-	consumerName                string
-	subscription                string
-	subscriptionType            SubscriptionType
-	subscriptionInitPos         SubscriptionInitialPosition
-	partitionIdx                int
-	receiverQueueSize           int
+	topic               string
+	consumerName        string
+	subscription        string
+	subscriptionType    SubscriptionType
+	subscriptionInitPos SubscriptionInitialPosition
+	partitionIdx        int
+	receiverQueueSize   int
+	// queueCapacity is the maximum receiver queue size, if the queue can be resized
+	queueCapacity               int
 	nackRedeliveryDelay         time.Duration
 	nackBackoffPolicy           NackBackoffPolicy
 	metadata                    map[string]string
@@ -96,7 +89,7 @@ type partitionConsumerOpts struct {
 	replicateSubscriptionState  bool
 	startMessageID              *trackingMessageID
 	startMessageIDInclusive     bool
-	subscriptionMode            subscriptionMode
+	subscriptionMode            SubscriptionMode
 	readCompacted               bool
 	disableForceTopicCreation   bool
 	interceptors                ConsumerInterceptors
@@ -106,13 +99,33 @@ type partitionConsumerOpts struct {
 	schema                      Schema
 	decryption                  *MessageDecryptionInfo
 	ackWithResponse             bool
+	ackReceiptCh                chan<- AckResult
 	maxPendingChunkedMessage    int
+	maxPendingChunkedBytes      int64
 	expireTimeOfIncompleteChunk time.Duration
 	autoAckIncompleteChunk      bool
 	// in failover mode, this callback will be called when consumer change
 	consumerEventListener ConsumerEventListener
 	enableBatchIndexAck   bool
 	ackGroupingOptions    *AckGroupingOptions
+	ackHoleTracking       *AckHoleTrackingOptions
+	autoCumulativeAck     bool
+	// subscriptionStartID is the position a durable subscription is moved to once subscribed
+	subscriptionStartID *trackingMessageID
+	authProvider        auth.Provider
+	decodeKafkaEntries  bool
+	messageFilter       func(Message) bool
+	filterRejection     MessageDisposition
+	validateSchema      bool
+	decodeFailure       MessageDisposition
+	// asyncErrors receives the asynchronous failures, nil for the readers
+	asyncErrors *consumerErrors
+	// validateStartMessageID checks the start message id is not beyond the last message of the partition
+	validateStartMessageID bool
+	// rateLimiter paces the flow permits, nil if the consumer has no RateLimit
+	rateLimiter *rateLimiter
+	// oversizedMessageThreshold is the size above which the messages not chunked are reported, 0 if disabled
+	oversizedMessageThreshold int
 }
 
 type ConsumerEventListener interface {
@@ -141,11 +154,16 @@ type partitionConsumer struct {
 	// the number of message slots available
 	availablePermits *availablePermits
 
-	// the size of the queue channel for buffering messages
-	queueSize       int32
-	queueCh         chan []*message
+	// the size of the queue for buffering messages
+	queueSize int32
+	// queue holds the messages of each entry received from the broker until the dispatcher takes them,
+	// dequeued is the number of entries taken by the dispatcher and not yet dispatched
+	queue           *internal.RingBuffer
+	dequeued        int32
 	startMessageID  atomicMessageID
 	lastDequeuedMsg *trackingMessageID
+	// subscriptionStart filters out the messages before the subscriptionStartID, until the next seek
+	subscriptionStart atomicMessageID
 
 	eventsCh        chan interface{}
 	connectedCh     chan struct{}
@@ -161,10 +179,38 @@ type partitionConsumer struct {
 	metrics              *internal.LeveledMetrics
 	decryptor            cryptointernal.Decryptor
 	schemaInfoCache      *schemaInfoCache
+	propagators          []ContextPropagator
 
 	chunkedMsgCtxMap   *chunkedMsgCtxMap
 	unAckChunksTracker *unAckChunksTracker
 	ackGroupingTracker ackGroupingTracker
+	ackHoleTracker     *ackHoleTracker
+	unsentAcks         *unsentAcks
+
+	delayedDeliveryWarning sync.Once
+
+	// key hash ranges assigned to the consumer, for Key_Shared subscriptions
+	keyHashRanges atomic.Value
+
+	lastError lastError
+
+	// perf estimates the cost of the stages of MessageReceived
+	perf consumePerfCounters
+
+	// callbacks invokes the event listener of the partition in order, nil to invoke it in place
+	callbacks *orderedExecutor
+
+	// unload tracks the recovery from the broker closing the consumer
+	unload *topicUnload
+
+	// affinity is the broker the consumer is connected to, see ClientOptions.StickyReconnect
+	affinity brokerAffinity
+
+	// clockSkewExceeded is set while the clock skew exceeds ClientOptions.ClockSkewWarning
+	clockSkewExceeded uAtomic.Bool
+
+	// ackReceipts tracks the acknowledgments waiting for the receipt of the broker
+	ackReceipts ackReceipts
 }
 
 func (pc *partitionConsumer) ActiveConsumerChanged(isActive bool) {
@@ -173,16 +219,43 @@ func (pc *partitionConsumer) ActiveConsumerChanged(isActive bool) {
 		// didn't set a listener
 		return
 	}
-	if isActive {
-		listener.BecameActive(pc.parentConsumer, pc.topic, pc.partitionIdx)
-	} else {
-		listener.BecameInactive(pc.parentConsumer, pc.topic, pc.partitionIdx)
+	pc.callbacks.execute(pc.topic, func() {
+		if isActive {
+			listener.BecameActive(pc.parentConsumer, pc.topic, pc.partitionIdx)
+		} else {
+			listener.BecameInactive(pc.parentConsumer, pc.topic, pc.partitionIdx)
+		}
+	})
+}
+
+// KeyHashRanges returns the key hash ranges assigned to the consumer, nil when it isn't connected
+func (pc *partitionConsumer) KeyHashRanges() []KeyHashRange {
+	ranges, _ := pc.keyHashRanges.Load().([]KeyHashRange)
+	return ranges
+}
+
+func (pc *partitionConsumer) setKeyHashRanges(ranges []KeyHashRange) {
+	if pc.options.subscriptionType != KeyShared {
+		return
+	}
+	previous := pc.KeyHashRanges()
+	pc.keyHashRanges.Store(ranges)
+	if len(previous) == 0 && len(ranges) == 0 {
+		return
+	}
+
+	if listener, ok := pc.options.consumerEventListener.(KeyHashRangesListener); ok {
+		pc.callbacks.execute(pc.topic, func() {
+			listener.KeyHashRangesChanged(pc.parentConsumer, pc.topic, pc.partitionIdx, ranges)
+		})
 	}
 }
 
 type availablePermits struct {
 	permits int32
 	pc      *partitionConsumer
+	// paced is set while the permits wait for the rate limit of the consumer
+	paced int32
 }
 
 func (p *availablePermits) inc() {
@@ -191,19 +264,40 @@ func (p *availablePermits) inc() {
 
 	// TODO implement a better flow controller
 	// send more permits if needed
-	flowThreshold := int32(math.Max(float64(p.pc.queueSize/2), 1))
+	flowThreshold := int32(math.Max(float64(atomic.LoadInt32(&p.pc.queueSize)/2), 1))
 	if ap >= flowThreshold {
-		availablePermits := ap
-		requestedPermits := ap
-		// check if permits changed
-		if !atomic.CompareAndSwapInt32(&p.permits, ap, 0) {
-			return
-		}
+		p.flow(ap)
+	}
+}
 
-		p.pc.log.Debugf("requesting more permits=%d available=%d", requestedPermits, availablePermits)
-		if err := p.pc.internalFlow(uint32(requestedPermits)); err != nil {
-			p.pc.log.WithError(err).Error("unable to send permits")
+// flow sends the available permits to the broker, once the messages received fit in the rate limit if any
+func (p *availablePermits) flow(ap int32) {
+	if delay := p.pc.options.rateLimiter.delay(); delay > 0 {
+		if atomic.CompareAndSwapInt32(&p.paced, 0, 1) {
+			time.AfterFunc(delay, func() {
+				atomic.StoreInt32(&p.paced, 0)
+				if ap := atomic.LoadInt32(&p.permits); ap > 0 {
+					p.flow(ap)
+				}
+			})
 		}
+		return
+	}
+
+	availablePermits := ap
+	requestedPermits := ap
+	// check if permits changed
+	if !atomic.CompareAndSwapInt32(&p.permits, ap, 0) {
+		return
+	}
+
+	if ack := p.pc.options.ackGroupingOptions; ack != nil && ack.FlushWithPermits &&
+		p.pc.ackGroupingTracker.pendingAcks() > 0 {
+		p.pc.ackGroupingTracker.requestFlush()
+	}
+	p.pc.log.Debugf("requesting more permits=%d available=%d", requestedPermits, availablePermits)
+	if err := p.pc.internalFlow(uint32(requestedPermits)); err != nil {
+		p.pc.log.WithError(err).Error("unable to send permits")
 	}
 }
 
@@ -234,13 +328,16 @@ type schemaInfoCache struct {
 	cache  map[string]Schema
 	client *client
 	topic  string
+	// topic of the schemas in the schema store of the client
+	storeTopic string
 }
 
 func newSchemaInfoCache(client *client, topic string) *schemaInfoCache {
 	return &schemaInfoCache{
-		cache:  make(map[string]Schema),
-		client: client,
-		topic:  topic,
+		cache:      make(map[string]Schema),
+		client:     client,
+		topic:      topic,
+		storeTopic: schemaStoreTopic(topic),
 	}
 }
 
@@ -253,6 +350,17 @@ func (s *schemaInfoCache) Get(schemaVersion []byte) (schema Schema, err error) {
 		return schema, nil
 	}
 
+	if s.client.schemas != nil {
+		if info, ok := s.client.schemas.get(s.storeTopic, key); ok {
+			schema, err = NewSchema(info.Type, []byte(info.Schema), info.Properties)
+			if err != nil {
+				return nil, err
+			}
+			s.add(key, schema)
+			return schema, nil
+		}
+	}
+
 	pbSchema, err := s.client.lookupService.GetSchema(s.topic, schemaVersion)
 	if err != nil {
 		return nil, err
@@ -269,6 +377,14 @@ func (s *schemaInfoCache) Get(schemaVersion []byte) (schema Schema, err error) {
 	if err != nil {
 		return nil, err
 	}
+	if s.client.schemas != nil {
+		s.client.schemas.put(s.storeTopic, key, SchemaInfo{
+			Name:       pbSchema.GetName(),
+			Type:       SchemaType(*pbSchema.Type),
+			Schema:     string(pbSchema.SchemaData),
+			Properties: properties,
+		})
+	}
 	s.add(key, schema)
 	return schema, nil
 }
@@ -283,9 +399,14 @@ func (s *schemaInfoCache) add(schemaVersionHash string, schema Schema) {
 func newPartitionConsumer(parent Consumer, client *client, options *partitionConsumerOpts,
 	messageCh chan ConsumerMessage, dlq *dlqRouter,
 	metrics *internal.LeveledMetrics) (*partitionConsumer, error) {
+	queueCapacity := options.receiverQueueSize
+	if options.queueCapacity > queueCapacity {
+		queueCapacity = options.queueCapacity
+	}
 	pc := &partitionConsumer{
 		parentConsumer:       parent,
 		client:               client,
+		callbacks:            client.callbackExecutor,
 		options:              options,
 		topic:                options.topic,
 		name:                 options.consumerName,
@@ -293,7 +414,7 @@ func newPartitionConsumer(parent Consumer, client *client, options *partitionCon
 		partitionIdx:         int32(options.partitionIdx),
 		eventsCh:             make(chan interface{}, 10),
 		queueSize:            int32(options.receiverQueueSize),
-		queueCh:              make(chan []*message, options.receiverQueueSize),
+		queue:                internal.NewRingBuffer(queueCapacity),
 		startMessageID:       atomicMessageID{msgID: options.startMessageID},
 		connectedCh:          make(chan struct{}),
 		messageCh:            messageCh,
@@ -304,13 +425,17 @@ func newPartitionConsumer(parent Consumer, client *client, options *partitionCon
 		dlq:                  dlq,
 		metrics:              metrics,
 		schemaInfoCache:      newSchemaInfoCache(client, options.topic),
+		propagators:          client.propagators,
 	}
 	pc.availablePermits = &availablePermits{pc: pc}
 	pc.chunkedMsgCtxMap = newChunkedMsgCtxMap(options.maxPendingChunkedMessage, pc)
 	pc.unAckChunksTracker = newUnAckChunksTracker(pc)
+	pc.unsentAcks = newUnsentAcks()
 	pc.ackGroupingTracker = newAckGroupingTracker(options.ackGroupingOptions,
-		func(id MessageID) { pc.sendIndividualAck(id) },
-		func(id MessageID) { pc.sendCumulativeAck(id) })
+		func(id MessageID) { pc.sendGroupedAck(id, individualAck) },
+		func(id MessageID) { pc.sendGroupedAck(id, cumulativeAck) },
+		func(ids []MessageID) { pc.sendListAck(ids) },
+		pc.metrics)
 	pc.setConsumerState(consumerInit)
 	pc.log = client.log.SubLogger(log.Fields{
 		"name":         pc.name,
@@ -318,6 +443,13 @@ func newPartitionConsumer(parent Consumer, client *client, options *partitionCon
 		"subscription": options.subscription,
 		"consumerID":   pc.consumerID,
 	})
+	ackHoleTracking := options.ackHoleTracking
+	if options.autoCumulativeAck && ackHoleTracking == nil &&
+		(options.subscriptionType == Exclusive || options.subscriptionType == Failover) {
+		// the delivered entries are tracked to find the acknowledged prefix
+		ackHoleTracking = &AckHoleTrackingOptions{}
+	}
+	pc.ackHoleTracker = newAckHoleTracker(ackHoleTracking, pc.log)
 
 	var decryptor cryptointernal.Decryptor
 	if pc.options.decryption == nil {
@@ -333,6 +465,7 @@ func newPartitionConsumer(parent Consumer, client *client, options *partitionCon
 	pc.decryptor = decryptor
 
 	pc.nackTracker = newNegativeAcksTracker(pc, options.nackRedeliveryDelay, options.nackBackoffPolicy, pc.log)
+	pc.unload = newTopicUnload(client, options.topic, options.subscription, pc.log)
 
 	err := pc.grabConn()
 	if err != nil {
@@ -362,6 +495,16 @@ func newPartitionConsumer(parent Consumer, client *client, options *partitionCon
 		}
 	}
 
+	if err := pc.validateStartMessageID(); err != nil {
+		pc.nackTracker.Close()
+		return nil, err
+	}
+
+	if err := pc.seekToSubscriptionStart(); err != nil {
+		pc.nackTracker.Close()
+		return nil, err
+	}
+
 	go pc.dispatcher()
 
 	go pc.runEventsLoop()
@@ -370,12 +513,20 @@ func newPartitionConsumer(parent Consumer, client *client, options *partitionCon
 }
 
 func (pc *partitionConsumer) Unsubscribe() error {
+	return pc.unsubscribe(false)
+}
+
+func (pc *partitionConsumer) UnsubscribeForce() error {
+	return pc.unsubscribe(true)
+}
+
+func (pc *partitionConsumer) unsubscribe(force bool) error {
 	if state := pc.getConsumerState(); state == consumerClosed || state == consumerClosing {
 		pc.log.WithField("state", state).Error("Failed to unsubscribe closing or closed consumer")
 		return nil
 	}
 
-	req := &unsubscribeRequest{doneCh: make(chan struct{})}
+	req := &unsubscribeRequest{doneCh: make(chan struct{}), force: force}
 	pc.eventsCh <- req
 
 	// wait for the request to complete
@@ -397,10 +548,13 @@ func (pc *partitionConsumer) internalUnsubscribe(unsub *unsubscribeRequest) {
 		RequestId:  proto.Uint64(requestID),
 		ConsumerId: proto.Uint64(pc.consumerID),
 	}
+	if unsub.force {
+		setUnsubscribeForce(cmdUnsubscribe)
+	}
 	_, err := pc.client.rpcClient.RequestOnCnx(pc._getConn(), requestID, pb.BaseCommand_UNSUBSCRIBE, cmdUnsubscribe)
 	if err != nil {
 		pc.log.WithError(err).Error("Failed to unsubscribe consumer")
-		unsub.err = err
+		unsub.err = toUnsubscribeError(err)
 		// Set the state to ready for closing the consumer
 		pc.setConsumerState(consumerReady)
 		// Should'nt remove the consumer handler
@@ -415,6 +569,32 @@ func (pc *partitionConsumer) internalUnsubscribe(unsub *unsubscribeRequest) {
 	pc.setConsumerState(consumerClosed)
 }
 
+// unsubscribeForceField is the number of the force field of CommandUnsubscribe, added to the protocol after the
+// version the protobuf code of the client is generated from
+const unsubscribeForceField protowire.Number = 3
+
+// setUnsubscribeForce sets the force field of the command, as an unknown field serialized with the command
+func setUnsubscribeForce(cmd *pb.CommandUnsubscribe) {
+	field := protowire.AppendTag(nil, unsubscribeForceField, protowire.VarintType)
+	field = protowire.AppendVarint(field, protowire.EncodeBool(true))
+	cmd.ProtoReflect().SetUnknown(field)
+}
+
+// toUnsubscribeError returns the error of a failed unsubscribe with the result of the server error, to tell a
+// subscription with other connected consumers from an authorization failure
+func toUnsubscribeError(err error) error {
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, pb.ServerError_ConsumerBusy.String()):
+		return newError(ConsumerBusy, fmt.Sprintf("the subscription has other connected consumers, "+
+			"use UnsubscribeForce to delete it: %s", msg))
+	case strings.Contains(msg, pb.ServerError_AuthorizationError.String()):
+		return newError(AuthorizationError, fmt.Sprintf("not authorized to unsubscribe: %s", msg))
+	default:
+		return err
+	}
+}
+
 func (pc *partitionConsumer) getLastMessageID() (*trackingMessageID, error) {
 	if state := pc.getConsumerState(); state == consumerClosed || state == consumerClosing {
 		pc.log.WithField("state", state).Error("Failed to redeliver closing or closed consumer")
@@ -469,7 +649,11 @@ func (pc *partitionConsumer) ackID(msgID MessageID, withResponse bool) error {
 		return errors.New("failed to convert trackingMessageID")
 	}
 
-	if trackingID != nil && trackingID.ack() {
+	receipt := withResponse && pc.options.ackReceiptCh != nil
+	var prefixID *trackingMessageID
+	acked, receiptIDs := pc.ackHoldingReceipt(trackingID, false, msgID, receipt)
+	if acked {
+		prefixID = pc.promoteAck(pc.ackHoleTracker.acked(trackingID))
 		pc.metrics.AcksCounter.Inc()
 		pc.metrics.ProcessingTime.Observe(float64(time.Now().UnixNano()-trackingID.receivedTime.UnixNano()) / 1.0e9)
 	} else if !pc.options.enableBatchIndexAck {
@@ -477,9 +661,26 @@ func (pc *partitionConsumer) ackID(msgID MessageID, withResponse bool) error {
 	}
 
 	var ackReq *ackRequest
-	if withResponse {
-		ackReq := pc.sendIndividualAck(trackingID)
+	if receipt {
+		req := &ackRequest{
+			doneCh:     make(chan struct{}),
+			ackType:    individualAck,
+			msgID:      *trackingID,
+			receiptIDs: receiptIDs,
+		}
+		if prefixID != nil {
+			req.ackType, req.msgID = cumulativeAck, *prefixID
+		}
+		pc.eventsCh <- req
+	} else if withResponse {
+		if prefixID != nil {
+			ackReq = pc.sendCumulativeAck(prefixID)
+		} else {
+			ackReq = pc.sendIndividualAck(trackingID)
+		}
 		<-ackReq.doneCh
+	} else if prefixID != nil {
+		pc.ackGroupingTracker.addCumulative(prefixID)
 	} else {
 		pc.ackGroupingTracker.add(trackingID)
 	}
@@ -490,6 +691,32 @@ func (pc *partitionConsumer) ackID(msgID MessageID, withResponse bool) error {
 	return ackReq.err
 }
 
+// promoteAck returns the id to acknowledge cumulatively instead of the individual acknowledgment, when the
+// acknowledged entries form a prefix from the cursor and the auto cumulative acknowledgment is enabled
+func (pc *partitionConsumer) promoteAck(prefix *ackHolePosition) *trackingMessageID {
+	if prefix == nil || !pc.options.autoCumulativeAck {
+		return nil
+	}
+	if pc.options.subscriptionType != Exclusive && pc.options.subscriptionType != Failover {
+		return nil
+	}
+	return newTrackingMessageID(prefix.ledgerID, prefix.entryID, -1, pc.partitionIdx, 0, nil)
+}
+
+// ackHoldingReceipt acknowledges the message in its batch, if any, cumulatively or not. When a receipt is requested,
+// the receipt of a message of a batch is held until the batch is acknowledged, the ids to report in the receipt of
+// the acknowledgment are returned.
+func (pc *partitionConsumer) ackHoldingReceipt(trackingID *trackingMessageID, cumulative bool, msgID MessageID,
+	receipt bool) (bool, []MessageID) {
+	if receipt && !pc.options.enableBatchIndexAck && trackingID.tracker != nil && trackingID.batchIdx > -1 {
+		return trackingID.tracker.ackHoldingReceipt(int(trackingID.batchIdx), cumulative, msgID)
+	}
+	if cumulative {
+		return trackingID.ackCumulative(), []MessageID{msgID}
+	}
+	return trackingID.ack(), []MessageID{msgID}
+}
+
 func (pc *partitionConsumer) sendIndividualAck(msgID MessageID) *ackRequest {
 	ackReq := &ackRequest{
 		doneCh:  make(chan struct{}),
@@ -500,6 +727,37 @@ func (pc *partitionConsumer) sendIndividualAck(msgID MessageID) *ackRequest {
 	return ackReq
 }
 
+// groupedAckReceipts returns true if the acknowledgments flushed by the ack grouping tracker request receipts
+func (pc *partitionConsumer) groupedAckReceipts() bool {
+	return pc.options.ackGroupingOptions != nil && pc.options.ackGroupingOptions.ReceiptEnabled
+}
+
+// sendGroupedAck sends an acknowledgment flushed by the ack grouping tracker, without waiting for it
+func (pc *partitionConsumer) sendGroupedAck(msgID MessageID, ackType int) {
+	req := &ackRequest{
+		doneCh:  make(chan struct{}),
+		ackType: ackType,
+		msgID:   *msgID.(*trackingMessageID),
+		receipt: pc.groupedAckReceipts(),
+	}
+	if req.receipt && pc.options.ackReceiptCh != nil {
+		req.receiptIDs = []MessageID{msgID}
+	}
+	pc.eventsCh <- req
+}
+
+// sendListAck acknowledges the messages individually with a single ACK command
+func (pc *partitionConsumer) sendListAck(msgIDs []MessageID) {
+	req := &ackListRequest{
+		msgIDs:  make([]trackingMessageID, 0, len(msgIDs)),
+		receipt: pc.groupedAckReceipts(),
+	}
+	for _, id := range msgIDs {
+		req.msgIDs = append(req.msgIDs, *id.(*trackingMessageID))
+	}
+	pc.eventsCh <- req
+}
+
 func (pc *partitionConsumer) AckIDWithResponse(msgID MessageID) error {
 	return pc.ackID(msgID, true)
 }
@@ -528,24 +786,36 @@ func (pc *partitionConsumer) internalAckIDCumulative(msgID MessageID, withRespon
 		return errors.New("failed to convert trackingMessageID")
 	}
 
+	receipt := withResponse && pc.options.ackReceiptCh != nil
+	acked, receiptIDs := pc.ackHoldingReceipt(trackingID, true, msgID, receipt)
 	var msgIDToAck *trackingMessageID
-	if trackingID.ackCumulative() || pc.options.enableBatchIndexAck {
+	if acked || pc.options.enableBatchIndexAck {
 		msgIDToAck = trackingID
 	} else if !trackingID.tracker.hasPrevBatchAcked() {
 		// get previous batch message id
 		msgIDToAck = trackingID.prev()
 		trackingID.tracker.setPrevBatchAcked()
+		// the receipt is held until the batch is acknowledged
+		receiptIDs = nil
 	} else {
 		// waiting for all the msgs are acked in this batch
 		return nil
 	}
 
+	pc.ackHoleTracker.ackedCumulative(msgIDToAck)
 	pc.metrics.AcksCounter.Inc()
 	pc.metrics.ProcessingTime.Observe(float64(time.Now().UnixNano()-trackingID.receivedTime.UnixNano()) / 1.0e9)
 
 	var ackReq *ackRequest
-	if withResponse {
-		ackReq := pc.sendCumulativeAck(msgIDToAck)
+	if receipt {
+		pc.eventsCh <- &ackRequest{
+			doneCh:     make(chan struct{}),
+			ackType:    cumulativeAck,
+			msgID:      *msgIDToAck,
+			receiptIDs: receiptIDs,
+		}
+	} else if withResponse {
+		ackReq = pc.sendCumulativeAck(msgIDToAck)
 		<-ackReq.doneCh
 	} else {
 		pc.ackGroupingTracker.addCumulative(msgIDToAck)
@@ -573,6 +843,109 @@ func (pc *partitionConsumer) sendCumulativeAck(msgID MessageID) *ackRequest {
 	return ackReq
 }
 
+func (pc *partitionConsumer) AckIDWithTxn(msgID MessageID, txn Transaction) error {
+	return pc.ackIDWithTxn(msgID, txn, individualAck)
+}
+
+func (pc *partitionConsumer) AckIDCumulativeWithTxn(msgID MessageID, txn Transaction) error {
+	return pc.ackIDWithTxn(msgID, txn, cumulativeAck)
+}
+
+// ackIDWithTxn acknowledges the message as part of the transaction. The acknowledgment bypasses the
+// ack grouping tracker since the broker has to confirm it before the transaction can be committed.
+func (pc *partitionConsumer) ackIDWithTxn(msgID MessageID, t Transaction, ackType int) error {
+	if state := pc.getConsumerState(); state == consumerClosed || state == consumerClosing {
+		pc.log.WithField("state", state).Error("Failed to ack by closing or closed consumer")
+		return errors.New("consumer state is closed")
+	}
+
+	if _, ok := msgID.(*chunkMessageID); ok {
+		return newError(OperationNotSupported, "acknowledging chunked messages with a transaction is not supported")
+	}
+
+	trackingID := toTrackingMessageID(msgID)
+	if trackingID == nil {
+		return errors.New("failed to convert trackingMessageID")
+	}
+
+	txn, err := toTransaction(t)
+	if err != nil {
+		return err
+	}
+	if err := txn.registerSendOrAckOp(); err != nil {
+		return err
+	}
+	err = txn.registerAckTopic(pc.topic, pc.options.subscription)
+	if err == nil {
+		err = pc.internalAckWithTxn(trackingID, txn.GetTxnID(), ackType)
+	}
+	txn.endSendOrAckOp(err)
+	if err != nil {
+		return err
+	}
+
+	pc.metrics.AcksCounter.Inc()
+	pc.metrics.ProcessingTime.Observe(float64(time.Now().UnixNano()-trackingID.receivedTime.UnixNano()) / 1.0e9)
+	pc.options.interceptors.OnAcknowledge(pc.parentConsumer, msgID)
+	return nil
+}
+
+func (pc *partitionConsumer) internalAckWithTxn(msgID *trackingMessageID, txnID TxnID, ackType int) error {
+	pbMsgID := &pb.MessageIdData{
+		LedgerId: proto.Uint64(uint64(msgID.ledgerID)),
+		EntryId:  proto.Uint64(uint64(msgID.entryID)),
+	}
+	if ackSet := txnAckSet(msgID, ackType); ackSet != nil {
+		pbMsgID.AckSet = ackSet
+	}
+
+	reqID := pc.client.rpcClient.NewRequestID()
+	cmdAck := &pb.CommandAck{
+		ConsumerId:     proto.Uint64(pc.consumerID),
+		MessageId:      []*pb.MessageIdData{pbMsgID},
+		RequestId:      proto.Uint64(reqID),
+		TxnidMostBits:  proto.Uint64(txnID.MostSigBits),
+		TxnidLeastBits: proto.Uint64(txnID.LeastSigBits),
+	}
+	switch ackType {
+	case individualAck:
+		cmdAck.AckType = pb.CommandAck_Individual.Enum()
+	case cumulativeAck:
+		cmdAck.AckType = pb.CommandAck_Cumulative.Enum()
+	}
+
+	_, err := pc.client.rpcClient.RequestOnCnx(pc._getConn(), reqID, pb.BaseCommand_ACK, cmdAck)
+	if err != nil {
+		pc.log.WithError(err).WithField("txnID", txnID.String()).Error("Ack with transaction error")
+	}
+	return err
+}
+
+// txnAckSet returns the ack set of the batch index acknowledged with a transaction,
+// or nil when the message is not part of a batch
+func txnAckSet(msgID *trackingMessageID, ackType int) []int64 {
+	if msgID.batchIdx < 0 || msgID.batchSize <= 1 {
+		return nil
+	}
+	batchIDs := bitset.New(uint(msgID.batchSize))
+	for i := uint(0); i < uint(msgID.batchSize); i++ {
+		batchIDs.Set(i)
+	}
+	if ackType == cumulativeAck {
+		for i := 0; i <= int(msgID.batchIdx); i++ {
+			batchIDs.Clear(uint(i))
+		}
+	} else {
+		batchIDs.Clear(uint(msgID.batchIdx))
+	}
+	words := batchIDs.Bytes()
+	ackSet := make([]int64, len(words))
+	for i := range words {
+		ackSet[i] = int64(words[i])
+	}
+	return ackSet
+}
+
 func (pc *partitionConsumer) NackID(msgID MessageID) {
 	if cmid, ok := msgID.(*chunkMessageID); ok {
 		pc.unAckChunksTracker.nack(cmid)
@@ -586,11 +959,13 @@ func (pc *partitionConsumer) NackID(msgID MessageID) {
 
 	pc.nackTracker.Add(trackingID.messageID)
 	pc.metrics.NacksCounter.Inc()
+	pc.metrics.SubscriptionNacks.Inc()
 }
 
 func (pc *partitionConsumer) NackMsg(msg Message) {
 	pc.nackTracker.AddMessage(msg)
 	pc.metrics.NacksCounter.Inc()
+	pc.metrics.SubscriptionNacks.Inc()
 }
 
 func (pc *partitionConsumer) Redeliver(msgIds []messageID) {
@@ -687,12 +1062,20 @@ func (pc *partitionConsumer) Seek(msgID MessageID) error {
 
 func (pc *partitionConsumer) internalSeek(seek *seekRequest) {
 	defer close(seek.doneCh)
+	if state := pc.getConsumerState(); state == consumerClosing || state == consumerClosed {
+		pc.log.WithField("state", state).Error("failed seek by consumer is closing or has closed")
+		seek.err = errors.New("failed to seek by closing or closed consumer")
+		return
+	}
 	seek.err = pc.requestSeek(seek.msgID)
 }
 func (pc *partitionConsumer) requestSeek(msgID *messageID) error {
 	if err := pc.requestSeekWithoutClear(msgID); err != nil {
 		return err
 	}
+	pc.subscriptionStart.set(nil)
+	pc.ackHoleTracker.reset()
+	pc.unsentAcks.clean()
 	pc.clearReceiverQueue()
 	return nil
 }
@@ -749,6 +1132,7 @@ func (pc *partitionConsumer) internalSeekByTime(seek *seekByTimeRequest) {
 	state := pc.getConsumerState()
 	if state == consumerClosing || state == consumerClosed {
 		pc.log.WithField("state", pc.state).Error("Failed seekByTime by consumer is closing or has closed")
+		seek.err = errors.New("failed seekByTime by consumer is closing or has closed")
 		return
 	}
 
@@ -765,13 +1149,17 @@ func (pc *partitionConsumer) internalSeekByTime(seek *seekByTimeRequest) {
 		seek.err = err
 		return
 	}
+	pc.subscriptionStart.set(nil)
+	pc.ackHoleTracker.reset()
+	pc.unsentAcks.clean()
 	pc.clearReceiverQueue()
 }
 
 func (pc *partitionConsumer) internalAck(req *ackRequest) {
-	defer close(req.doneCh)
 	if state := pc.getConsumerState(); state == consumerClosed || state == consumerClosing {
 		pc.log.WithField("state", state).Error("Failed to ack by closing or closed consumer")
+		req.err = errors.New("consumer state is closed")
+		pc.completeAck(req)
 		return
 	}
 	msgID := req.msgID
@@ -801,13 +1189,25 @@ func (pc *partitionConsumer) internalAck(req *ackRequest) {
 		cmdAck.AckType = pb.CommandAck_Cumulative.Enum()
 	}
 
-	if pc.options.ackWithResponse {
+	if pc.options.ackWithResponse || req.receipt {
+		// the events loop doesn't wait for the response, a slow broker would stall the partition
 		cmdAck.RequestId = proto.Uint64(reqID)
-		_, err := pc.client.rpcClient.RequestOnCnx(pc._getConn(), reqID, pb.BaseCommand_ACK, cmdAck)
-		if err != nil {
-			pc.log.WithError(err).Error("Ack with response error")
-			req.err = err
-		}
+		seq := pc.ackReceipts.sent()
+		pc.client.rpcClient.RequestOnCnxAsync(pc._getConn(), reqID, pb.BaseCommand_ACK, cmdAck,
+			func(_ *internal.RPCResult, err error) {
+				// the receipts channel may block, the connection must not
+				go func() {
+					if err != nil {
+						pc.log.WithError(err).Error("Ack with response error")
+						req.err = err
+						if isAckLost(err) {
+							pc.addUnsentAck(req.ackType, req.msgID)
+						}
+					}
+					pc.completeAck(req)
+					pc.ackReceipts.received(seq, req.receipt, err)
+				}()
+			})
 		return
 	}
 
@@ -815,12 +1215,127 @@ func (pc *partitionConsumer) internalAck(req *ackRequest) {
 	if err != nil {
 		pc.log.Error("Connection was closed when request ack cmd")
 		req.err = err
+		pc.addUnsentAck(req.ackType, req.msgID)
+	}
+	pc.completeAck(req)
+}
+
+// completeAck completes the ack request, reporting the failure of a grouped acknowledgment to Consumer.Errors and
+// the result to the receipts channel if requested
+func (pc *partitionConsumer) completeAck(req *ackRequest) {
+	if req.err != nil && req.receipt {
+		pc.options.asyncErrors.report(ConsumerOpAck, pc.topic, &req.msgID, req.err)
+	}
+	close(req.doneCh)
+	pc.sendAckReceipt(req)
+}
+
+func (pc *partitionConsumer) internalAckList(req *ackListRequest) {
+	if state := pc.getConsumerState(); state == consumerClosed || state == consumerClosing {
+		pc.log.WithField("state", state).Error("Failed to ack by closing or closed consumer")
+		req.err = errors.New("consumer state is closed")
+		pc.completeAckList(req)
+		return
+	}
+
+	type entry struct {
+		ledgerID int64
+		entryID  int64
+	}
+	// the messages of a batch are acknowledged by a single entry, with the ack set of the batch if any
+	entries := make(map[entry]struct{}, len(req.msgIDs))
+	messageIDs := make([]*pb.MessageIdData, 0, len(req.msgIDs))
+	for _, msgID := range req.msgIDs {
+		e := entry{ledgerID: msgID.ledgerID, entryID: msgID.entryID}
+		if _, ok := entries[e]; ok {
+			continue
+		}
+		entries[e] = struct{}{}
+		id := &pb.MessageIdData{
+			LedgerId: proto.Uint64(uint64(msgID.ledgerID)),
+			EntryId:  proto.Uint64(uint64(msgID.entryID)),
+		}
+		if pc.options.enableBatchIndexAck && msgID.tracker != nil {
+			if ackSet := msgID.tracker.toAckSet(); ackSet != nil {
+				id.AckSet = ackSet
+			}
+		}
+		messageIDs = append(messageIDs, id)
+	}
+
+	cmdAck := &pb.CommandAck{
+		ConsumerId: proto.Uint64(pc.consumerID),
+		AckType:    pb.CommandAck_Individual.Enum(),
+		MessageId:  messageIDs,
+	}
+	if req.receipt || req.doneCh != nil {
+		reqID := pc.client.rpcClient.NewRequestID()
+		cmdAck.RequestId = proto.Uint64(reqID)
+		seq := pc.ackReceipts.sent()
+		pc.client.rpcClient.RequestOnCnxAsync(pc._getConn(), reqID, pb.BaseCommand_ACK, cmdAck,
+			func(_ *internal.RPCResult, err error) {
+				go func() {
+					if err != nil {
+						pc.log.WithError(err).Error("Ack with response error")
+						req.err = err
+						if isAckLost(err) {
+							pc.addUnsentAck(individualAck, req.msgIDs...)
+						}
+					}
+					pc.completeAckList(req)
+					pc.ackReceipts.received(seq, !req.listed, err)
+				}()
+			})
+		return
+	}
+	if err := pc.client.rpcClient.RequestOnCnxNoWait(pc._getConn(), pb.BaseCommand_ACK, cmdAck); err != nil {
+		pc.log.WithError(err).Error("Connection was closed when request ack cmd")
+		req.err = err
+		pc.addUnsentAck(individualAck, req.msgIDs...)
+	}
+	pc.completeAckList(req)
+}
+
+// completeAckList completes the ack list request, reporting the results of the grouped acknowledgments requesting a
+// receipt: the failures to Consumer.Errors and all the results to the receipts channel, if any
+func (pc *partitionConsumer) completeAckList(req *ackListRequest) {
+	if req.receipt {
+		ids := req.receiptIDs
+		if ids == nil {
+			ids = make([]MessageID, len(req.msgIDs))
+			for i := range req.msgIDs {
+				ids[i] = &req.msgIDs[i]
+			}
+		}
+		for _, msgID := range ids {
+			if req.err != nil {
+				pc.options.asyncErrors.report(ConsumerOpAck, pc.topic, msgID, req.err)
+			}
+			if pc.options.ackReceiptCh != nil {
+				pc.options.ackReceiptCh <- AckResult{MessageID: msgID, Err: req.err}
+			}
+		}
+	}
+	if req.doneCh != nil {
+		close(req.doneCh)
+	}
+}
+
+// sendAckReceipt reports the result of an ack request to the receipts channel, if requested
+func (pc *partitionConsumer) sendAckReceipt(req *ackRequest) {
+	for _, msgID := range req.receiptIDs {
+		pc.options.ackReceiptCh <- AckResult{
+			MessageID: msgID,
+			Err:       req.err,
+		}
 	}
 }
 
 func (pc *partitionConsumer) MessageReceived(response *pb.CommandMessage, headersAndPayload internal.Buffer) error {
 	pbMsgID := response.GetMessageId()
+	entrySize := headersAndPayload.ReadableBytes()
 
+	start := time.Now()
 	reader := internal.NewMessageReader(headersAndPayload)
 	brokerMetadata, err := reader.ReadBrokerMetadata()
 	if err != nil {
@@ -833,7 +1348,17 @@ func (pc *partitionConsumer) MessageReceived(response *pb.CommandMessage, header
 		pc.discardCorruptedMessage(pbMsgID, pb.CommandAck_ChecksumMismatch)
 		return err
 	}
+	decodeElapsed := time.Since(start)
+	if brokerMetadata != nil {
+		pc.observeClockSkew(msgMeta.GetPublishTime(), brokerMetadata.GetBrokerTimestamp())
+	}
+	pc.options.rateLimiter.take(int(msgMeta.GetNumMessagesInBatch()), int(entrySize))
+
+	start = time.Now()
 	decryptedPayload, err := pc.decryptor.Decrypt(headersAndPayload.ReadableSlice(), pbMsgID, msgMeta)
+	if msgMeta.GetEncryptionKeys() != nil {
+		pc.perf.decrypt.observe(time.Since(start), 1)
+	}
 	// error decrypting the payload
 	if err != nil {
 		// default crypto failure action
@@ -845,7 +1370,9 @@ func (pc *partitionConsumer) MessageReceived(response *pb.CommandMessage, header
 		switch crypToFailureAction {
 		case crypto.ConsumerCryptoFailureActionFail:
 			pc.log.Errorf("consuming message failed due to decryption err :%v", err)
-			pc.NackID(newTrackingMessageID(int64(pbMsgID.GetLedgerId()), int64(pbMsgID.GetEntryId()), 0, 0, 0, nil))
+			msgID := newTrackingMessageID(int64(pbMsgID.GetLedgerId()), int64(pbMsgID.GetEntryId()), 0, 0, 0, nil)
+			pc.options.asyncErrors.report(ConsumerOpDecrypt, pc.topic, msgID, err)
+			pc.NackID(msgID)
 			return err
 		case crypto.ConsumerCryptoFailureActionDiscard:
 			pc.discardCorruptedMessage(pbMsgID, pb.CommandAck_DecryptionError)
@@ -876,7 +1403,7 @@ func (pc *partitionConsumer) MessageReceived(response *pb.CommandMessage, header
 					orderingKey:         string(msgMeta.OrderingKey),
 				},
 			}
-			pc.queueCh <- messages
+			pc.queue.Put(messages)
 			return nil
 		}
 	}
@@ -895,11 +1422,16 @@ func (pc *partitionConsumer) MessageReceived(response *pb.CommandMessage, header
 	}
 
 	// decryption is success, decompress the payload
+	start = time.Now()
 	uncompressedHeadersAndPayload, err := pc.Decompress(msgMeta, processedPayloadBuffer)
 	if err != nil {
 		pc.discardCorruptedMessage(pbMsgID, pb.CommandAck_DecompressionError)
 		return err
 	}
+	if msgMeta.GetCompression() != pb.CompressionType_NONE {
+		pc.perf.decompress.observe(time.Since(start), 1)
+	}
+	start = time.Now()
 
 	// Reset the reader on the uncompressed buffer
 	reader.ResetBuffer(uncompressedHeadersAndPayload)
@@ -909,6 +1441,26 @@ func (pc *partitionConsumer) MessageReceived(response *pb.CommandMessage, header
 		numMsgs = int(msgMeta.GetNumMessagesInBatch())
 	}
 
+	// entries written by Kafka-on-Pulsar carry a Kafka record batch instead of the Pulsar batch format
+	var kafkaBatch *internal.KafkaRecordBatch
+	if pc.options.decodeKafkaEntries && internal.IsKafkaEntry(msgMeta) {
+		kafkaBatch, err = internal.ParseKafkaRecordBatch(uncompressedHeadersAndPayload.ReadableSlice())
+		if errors.Is(err, internal.ErrKafkaCompressionUnsupported) {
+			// not corrupted, the entry is redelivered instead of being discarded
+			pc.log.WithError(err).Error("Failed to decode the kafka record batch")
+			msgID := newTrackingMessageID(int64(pbMsgID.GetLedgerId()), int64(pbMsgID.GetEntryId()), 0,
+				pc.partitionIdx, 0, nil)
+			pc.options.asyncErrors.report(ConsumerOpDecode, pc.topic, msgID, err)
+			pc.NackID(msgID)
+			return err
+		}
+		if err != nil {
+			pc.discardCorruptedMessage(pbMsgID, pb.CommandAck_BatchDeSerializeError)
+			return err
+		}
+		numMsgs = len(kafkaBatch.Records)
+	}
+
 	messages := make([]*message, 0)
 	var ackTracker *ackTracker
 	// are there multiple messages in this batch?
@@ -926,14 +1478,28 @@ func (pc *partitionConsumer) MessageReceived(response *pb.CommandMessage, header
 		ackSet = bitset.From(buf)
 	}
 
+	var deliverAtTime time.Time
+	if msgMeta.DeliverAtTime != nil {
+		deliverAtTime = timeFromUnixTimestampMillis(uint64(msgMeta.GetDeliverAtTime()))
+		pc.checkDelayedDelivery(pbMsgID, deliverAtTime)
+	}
+
 	pc.metrics.MessagesReceived.Add(float64(numMsgs))
 	pc.metrics.PrefetchedMessages.Add(float64(numMsgs))
 
 	for i := 0; i < numMsgs; i++ {
-		smm, payload, err := reader.ReadMessage()
-		if err != nil || payload == nil {
-			pc.discardCorruptedMessage(pbMsgID, pb.CommandAck_BatchDeSerializeError)
-			return err
+		var smm *pb.SingleMessageMetadata
+		var payload []byte
+		var kafkaRecord *internal.KafkaRecord
+		if kafkaBatch != nil {
+			kafkaRecord = &kafkaBatch.Records[i]
+			payload = kafkaRecord.Value
+		} else {
+			smm, payload, err = reader.ReadMessage()
+			if err != nil || payload == nil {
+				pc.discardCorruptedMessage(pbMsgID, pb.CommandAck_BatchDeSerializeError)
+				return err
+			}
 		}
 		if ackSet != nil && !ackSet.Test(uint(i)) {
 			pc.log.Debugf("Ignoring message from %vth message, which has been acknowledged", i)
@@ -978,9 +1544,10 @@ func (pc *partitionConsumer) MessageReceived(response *pb.CommandMessage, header
 			msgID = trackingMsgID
 		}
 
-		if pc.ackGroupingTracker.isDuplicate(msgID) {
+		if pc.ackGroupingTracker.isDuplicate(msgID) || pc.unsentAcks.isDuplicate(msgID) {
 			continue
 		}
+		pc.observeMessageSize(msgID, len(payload), isChunkedMsg)
 
 		var messageIndex *uint64
 		var brokerPublishTime *time.Time
@@ -996,7 +1563,33 @@ func (pc *partitionConsumer) MessageReceived(response *pb.CommandMessage, header
 		}
 
 		var msg *message
-		if smm != nil {
+		if kafkaRecord != nil {
+			kafkaTimestampType := KafkaCreateTime
+			if kafkaBatch.LogAppendTime {
+				kafkaTimestampType = KafkaLogAppendTime
+			}
+			msg = &message{
+				publishTime:         timeFromUnixTimestampMillis(msgMeta.GetPublishTime()),
+				eventTime:           timeFromUnixTimestampMillis(uint64(kafkaRecord.Timestamp)),
+				key:                 string(kafkaRecord.Key),
+				producerName:        msgMeta.GetProducerName(),
+				properties:          kafkaHeadersToProperties(kafkaRecord.Headers),
+				topic:               pc.topic,
+				msgID:               msgID,
+				payLoad:             payload,
+				schema:              pc.options.schema,
+				replicationClusters: msgMeta.GetReplicateTo(),
+				replicatedFrom:      msgMeta.GetReplicatedFrom(),
+				redeliveryCount:     response.GetRedeliveryCount(),
+				schemaVersion:       msgMeta.GetSchemaVersion(),
+				schemaInfoCache:     pc.schemaInfoCache,
+				index:               messageIndex,
+				brokerPublishTime:   brokerPublishTime,
+				kafkaTimestampType:  kafkaTimestampType,
+				deliverAtTime:       deliverAtTime,
+				propagators:         pc.propagators,
+			}
+		} else if smm != nil {
 			msg = &message{
 				publishTime:         timeFromUnixTimestampMillis(msgMeta.GetPublishTime()),
 				eventTime:           timeFromUnixTimestampMillis(smm.GetEventTime()),
@@ -1015,6 +1608,8 @@ func (pc *partitionConsumer) MessageReceived(response *pb.CommandMessage, header
 				orderingKey:         string(smm.OrderingKey),
 				index:               messageIndex,
 				brokerPublishTime:   brokerPublishTime,
+				deliverAtTime:       deliverAtTime,
+				propagators:         pc.propagators,
 			}
 		} else {
 			msg = &message{
@@ -1034,6 +1629,8 @@ func (pc *partitionConsumer) MessageReceived(response *pb.CommandMessage, header
 				schemaInfoCache:     pc.schemaInfoCache,
 				index:               messageIndex,
 				brokerPublishTime:   brokerPublishTime,
+				deliverAtTime:       deliverAtTime,
+				propagators:         pc.propagators,
 			}
 		}
 
@@ -1042,14 +1639,58 @@ func (pc *partitionConsumer) MessageReceived(response *pb.CommandMessage, header
 			Message:  msg,
 		})
 
+		pc.metrics.RedeliveryCount.Observe(float64(msg.redeliveryCount))
 		messages = append(messages, msg)
 	}
+	pc.perf.decode.observe(decodeElapsed+time.Since(start), numMsgs)
 
 	// send messages to the dispatcher
-	pc.queueCh <- messages
+	start = time.Now()
+	pc.queue.Put(messages)
+	pc.perf.dispatch.observe(time.Since(start), 1)
 	return nil
 }
 
+// delayedDeliveryTolerance is the clock skew tolerated before a delayed message is considered delivered early
+const delayedDeliveryTolerance = time.Second
+
+// checkDelayedDelivery warns, once per consumer, when a delayed message is received before its delivery time.
+// The broker only delays the messages on Shared and Key_Shared subscriptions, and only when delayed delivery
+// is enabled, otherwise the messages are silently delivered immediately.
+func (pc *partitionConsumer) checkDelayedDelivery(msgID *pb.MessageIdData, deliverAt time.Time) {
+	remaining := time.Until(deliverAt)
+	if remaining <= delayedDeliveryTolerance {
+		return
+	}
+
+	pc.delayedDeliveryWarning.Do(func() {
+		logger := pc.log.WithFields(log.Fields{
+			"ledgerID":       msgID.GetLedgerId(),
+			"entryID":        msgID.GetEntryId(),
+			"deliverAt":      deliverAt,
+			"remainingDelay": remaining,
+		})
+		switch pc.options.subscriptionType {
+		case Shared, KeyShared:
+			logger.Warn("Received a delayed message before its delivery time, " +
+				"delayed delivery is probably disabled on the broker (delayedDeliveryEnabled=false)")
+		default:
+			logger.Warn("Received a delayed message before its delivery time, " +
+				"delayed delivery is only supported on Shared and Key_Shared subscriptions")
+		}
+	})
+}
+
+// kafkaHeadersToProperties maps the Kafka record headers into message properties,
+// when a header key is repeated the last value wins
+func kafkaHeadersToProperties(headers []internal.KafkaRecordHeader) map[string]string {
+	properties := make(map[string]string, len(headers))
+	for _, h := range headers {
+		properties[h.Key] = string(h.Value)
+	}
+	return properties
+}
+
 func (pc *partitionConsumer) processMessageChunk(compressedPayload internal.Buffer,
 	msgMeta *pb.MessageMetadata,
 	pbMsgID *pb.MessageIdData) internal.Buffer {
@@ -1099,7 +1740,79 @@ func (pc *partitionConsumer) processMessageChunk(compressedPayload internal.Buff
 	return ctx.chunkedMsgBuffer
 }
 
+// validateStartMessageID checks the position the partition starts from is not beyond its last message, which
+// happens with a stale message id of a deleted and re-created topic or with one of another topic
+func (pc *partitionConsumer) validateStartMessageID() error {
+	if !pc.options.validateStartMessageID {
+		return nil
+	}
+	start := pc.options.startMessageID
+	if pc.options.subscriptionMode == Durable {
+		start = pc.options.subscriptionStartID
+		if start != nil && start.partitionIdx >= 0 && start.partitionIdx != pc.partitionIdx {
+			return nil
+		}
+	}
+	if start == nil || start.equal(earliestMessageID) || start.equal(latestMessageID) {
+		return nil
+	}
+
+	last, err := pc.requestGetLastMessageID()
+	if err != nil {
+		return err
+	}
+	if start.messageID.greater(last.messageID) {
+		return newError(InvalidConfiguration, fmt.Sprintf(
+			"invalid StartMessageID: %v is beyond the last message %v of %s", start.messageID, last.messageID, pc.topic))
+	}
+	return nil
+}
+
+// seekToSubscriptionStart moves the subscription to the subscriptionStartID of the consumer, if it applies to
+// this partition. It is called before the dispatcher is started.
+func (pc *partitionConsumer) seekToSubscriptionStart() error {
+	start := pc.options.subscriptionStartID
+	if start == nil || pc.options.subscriptionMode != Durable {
+		return nil
+	}
+
+	switch {
+	case start.equal(earliestMessageID):
+		return pc.requestSeekWithoutClear(earliestMessageID)
+	case start.equal(latestMessageID):
+		if !pc.options.startMessageIDInclusive {
+			return pc.requestSeekWithoutClear(latestMessageID)
+		}
+		msgID, err := pc.requestGetLastMessageID()
+		if err != nil {
+			return err
+		}
+		if msgID.entryID == noMessageEntry {
+			return pc.requestSeekWithoutClear(latestMessageID)
+		}
+		start = msgID
+	case start.partitionIdx >= 0 && start.partitionIdx != pc.partitionIdx:
+		return nil
+	}
+
+	pc.log.WithField("startMessageID", start).Info("Seeking the subscription to the start message id")
+	if err := pc.requestSeekWithoutClear(start.messageID); err != nil {
+		return err
+	}
+	pc.subscriptionStart.set(start)
+	return nil
+}
+
 func (pc *partitionConsumer) messageShouldBeDiscarded(msgID *trackingMessageID) bool {
+	if start := pc.subscriptionStart.get(); start != nil {
+		if pc.options.startMessageIDInclusive && start.greater(msgID.messageID) {
+			return true
+		}
+		if !pc.options.startMessageIDInclusive && start.greaterEqual(msgID.messageID) {
+			return true
+		}
+	}
+
 	if pc.startMessageID.get() == nil {
 		return false
 	}
@@ -1125,7 +1838,11 @@ func createEncryptionContext(msgMeta *pb.MessageMetadata) *EncryptionContext {
 		Algorithm:        msgMeta.GetEncryptionAlgo(),
 		Param:            msgMeta.GetEncryptionParam(),
 		UncompressedSize: int(msgMeta.GetUncompressedSize()),
-		BatchSize:        int(msgMeta.GetNumMessagesInBatch()),
+	}
+
+	// the batch size is left to 0 for messages which are not batched
+	if msgMeta.NumMessagesInBatch != nil {
+		encCtx.BatchSize = int(msgMeta.GetNumMessagesInBatch())
 	}
 
 	if msgMeta.Compression != nil {
@@ -1152,6 +1869,20 @@ func createEncryptionContext(msgMeta *pb.MessageMetadata) *EncryptionContext {
 func (pc *partitionConsumer) ConnectionClosed() {
 	// Trigger reconnection in the consumer goroutine
 	pc.log.Debug("connection closed and send to connectClosedCh")
+	pc.setKeyHashRanges(nil)
+	pc.connectClosedCh <- connectionClosed{}
+}
+
+// ClosedByBroker reconnects right away, the broker closes the consumer when the bundle of the topic is unloaded
+// or the ownership of the topic is transferred to another broker
+func (pc *partitionConsumer) ClosedByBroker() {
+	pc.affinity.forget()
+	if !pc.unload.begin() {
+		pc.ConnectionClosed()
+		return
+	}
+	pc.log.Info("Consumer closed by the broker, reconnecting")
+	pc.setKeyHashRanges(nil)
 	pc.connectClosedCh <- connectionClosed{}
 }
 
@@ -1181,15 +1912,47 @@ func (pc *partitionConsumer) internalFlow(permits uint32) error {
 	return nil
 }
 
-// dispatcher manages the internal message queue channel
+// setReceiverQueueSize resizes the receiver queue, up to the capacity of the queue. When the queue grows the
+// additional permits are sent right away, when it shrinks the permits of the next consumed messages are
+// withheld until the messages in flight fit in the new size.
+func (pc *partitionConsumer) setReceiverQueueSize(size int) {
+	if size > pc.queue.Cap() {
+		size = pc.queue.Cap()
+	}
+	if size < 1 {
+		size = 1
+	}
+	old := atomic.SwapInt32(&pc.queueSize, int32(size))
+	delta := int32(size) - old
+	if delta > 0 && pc.getConsumerState() == consumerReady {
+		if err := pc.internalFlow(uint32(delta)); err != nil {
+			pc.log.WithError(err).Warn("unable to send permits after resizing the receiver queue")
+		}
+	} else if delta < 0 {
+		atomic.AddInt32(&pc.availablePermits.permits, delta)
+	}
+}
+
+// dispatched removes the first of the messages, passed to the application or the DLQ router
+func (pc *partitionConsumer) dispatched(messages []*message) []*message {
+	pc.ackHoleTracker.delivered(messages[0].msgID)
+	// allow this message to be garbage collected
+	messages[0] = nil
+	pc.availablePermits.inc()
+	return messages[1:]
+}
+
+// dispatcher manages the internal message queue
 // and manages the flow control
 func (pc *partitionConsumer) dispatcher() {
 	defer func() {
 		pc.log.Debug("exiting dispatch loop")
 	}()
 	var messages []*message
+	// entries are the messages of the entries taken from the queue at once, dispatched in order
+	var entries []interface{}
 	for {
-		var queueCh chan []*message
+		var queueReady <-chan struct{}
 		var messageCh chan ConsumerMessage
 		var nextMessage ConsumerMessage
 
@@ -1200,20 +1963,30 @@ func (pc *partitionConsumer) dispatcher() {
 				Message:  messages[0],
 			}
 
-			if pc.dlq.shouldSendToDlq(&nextMessage) {
-				// pass the message to the DLQ router
-				pc.metrics.DlqCounter.Inc()
-				messageCh = pc.dlq.Chan()
-			} else {
-				// pass the message to application channel
-				messageCh = pc.messageCh
-			}
-
 			pc.metrics.PrefetchedMessages.Dec()
 			pc.metrics.PrefetchedBytes.Sub(float64(len(messages[0].payLoad)))
+
+			if messages[0].rejected || pc.dlq.shouldSendToDlq(&nextMessage) {
+				// pass the message to the DLQ router, which buffers it without blocking the dispatcher
+				pc.metrics.DlqCounter.Inc()
+				pc.dlq.route(nextMessage)
+				messages = pc.dispatched(messages)
+				continue
+			}
+			// pass the message to application channel
+			messageCh = pc.messageCh
+		} else if len(entries) > 0 {
+			// we only read messages here after the consumer has processed all messages
+			// in the previous batch
+			msgs := entries[0].([]*message)
+			entries[0] = nil
+			entries = entries[1:]
+			atomic.StoreInt32(&pc.dequeued, int32(len(entries)))
+			messages = pc.rejectMessages(msgs)
+			continue
 		} else {
 			// we are ready for more messages
-			queueCh = pc.queueCh
+			queueReady = pc.queue.Ready()
 		}
 
 		select {
@@ -1230,7 +2003,7 @@ func (pc *partitionConsumer) dispatcher() {
 
 			// reset available permits
 			pc.availablePermits.reset()
-			initialPermits := uint32(pc.queueSize)
+			initialPermits := uint32(atomic.LoadInt32(&pc.queueSize))
 
 			pc.log.Debugf("dispatcher requesting initial permits=%d", initialPermits)
 			// send initial permits
@@ -1238,38 +2011,27 @@ func (pc *partitionConsumer) dispatcher() {
 				pc.log.WithError(err).Error("unable to send initial permits to broker")
 			}
 
-		case msgs, ok := <-queueCh:
-			if !ok {
-				return
-			}
-			// we only read messages here after the consumer has processed all messages
-			// in the previous batch
-			messages = msgs
+		case <-queueReady:
+			// all the queued entries are taken with one lock
+			entries = pc.queue.Drain(entries[:0])
+			atomic.StoreInt32(&pc.dequeued, int32(len(entries)))
 
 		// if the messageCh is nil or the messageCh is full this will not be selected
 		case messageCh <- nextMessage:
-			// allow this message to be garbage collected
-			messages[0] = nil
-			messages = messages[1:]
-
-			pc.availablePermits.inc()
+			messages = pc.dispatched(messages)
 
 		case clearQueueCb := <-pc.clearQueueCh:
-			// drain the message queue on any new connection by sending a
-			// special nil message to the channel so we know when to stop dropping messages
+			// drop the entries taken from the queue and the ones still queued
 			var nextMessageInQueue *trackingMessageID
-			go func() {
-				pc.queueCh <- nil
-			}()
-
-			for m := range pc.queueCh {
-				// the queue has been drained
-				if m == nil {
-					break
-				} else if nextMessageInQueue == nil {
-					nextMessageInQueue = toTrackingMessageID(m[0].msgID)
+			entries = pc.queue.Drain(entries)
+			for i, entry := range entries {
+				if msgs := entry.([]*message); nextMessageInQueue == nil && len(msgs) > 0 {
+					nextMessageInQueue = toTrackingMessageID(msgs[0].msgID)
 				}
+				entries[i] = nil
 			}
+			entries = entries[:0]
+			atomic.StoreInt32(&pc.dequeued, 0)
 
 			messages = nil
 
@@ -1288,10 +2050,30 @@ type ackRequest struct {
 	msgID   trackingMessageID
 	ackType int
 	err     error
+	// receiptIDs are the message ids reported to the receipts channel, if the result is not waited for
+	receiptIDs []MessageID
+	// receipt requests a receipt of the broker for an acknowledgment flushed by the ack grouping tracker
+	receipt bool
+}
+
+// ackListRequest acknowledges the messages grouped by the ack grouping tracker, or given to Consumer.AckIDList
+type ackListRequest struct {
+	msgIDs []trackingMessageID
+	// receipt requests a receipt of the broker, see AckGroupingOptions.ReceiptEnabled
+	receipt bool
+
+	// listed is set for the acknowledgments of Consumer.AckIDList rather than of the ack grouping tracker
+	listed bool
+	// receiptIDs are the message ids reported to the receipts channel, the ids of msgIDs if nil
+	receiptIDs []MessageID
+	// doneCh is closed once the broker has processed the acknowledgments, nil if nothing waits for them
+	doneCh chan struct{}
+	err    error
 }
 
 type unsubscribeRequest struct {
 	doneCh chan struct{}
+	force  bool
 	err    error
 }
 
@@ -1309,6 +2091,12 @@ type getLastMsgIDRequest struct {
 	err    error
 }
 
+// flushAcksRequest is done once the acknowledgments requested before it are sent, see flushAcks
+type flushAcksRequest struct {
+	doneCh chan struct{}
+	err    error
+}
+
 type seekRequest struct {
 	doneCh chan struct{}
 	msgID  *messageID
@@ -1345,12 +2133,16 @@ func (pc *partitionConsumer) runEventsLoop() {
 			switch v := i.(type) {
 			case *ackRequest:
 				pc.internalAck(v)
+			case *ackListRequest:
+				pc.internalAckList(v)
 			case *redeliveryRequest:
 				pc.internalRedeliver(v)
 			case *unsubscribeRequest:
 				pc.internalUnsubscribe(v)
 			case *getLastMsgIDRequest:
 				pc.internalGetLastMessageID(v)
+			case *flushAcksRequest:
+				pc.internalFlushAcks(v)
 			case *seekRequest:
 				pc.internalSeek(v)
 			case *seekByTimeRequest:
@@ -1424,10 +2216,13 @@ func (pc *partitionConsumer) reconnectToBroker() {
 		maxRetry = int(*pc.options.maxReconnectToBroker)
 	}
 
-	for maxRetry != 0 {
+	pc.affinity.reconnecting(pc.client.stickyReconnect)
+	lastErr := errors.New("max reconnection attempts reached")
+	for attempt := 0; maxRetry != 0; attempt++ {
 		if pc.getConsumerState() != consumerReady {
 			// Consumer is already closing
 			pc.log.Info("consumer state not ready, exit reconnect")
+			pc.unload.finish(newError(ConsumerClosed, "consumer closed"))
 			return
 		}
 
@@ -1436,28 +2231,48 @@ func (pc *partitionConsumer) reconnectToBroker() {
 			defaultBackoff     = internal.DefaultBackoff{}
 		)
 
-		if pc.options.backoffPolicy == nil {
+		backoffPolicy := pc.options.backoffPolicy
+		if backoffPolicy == nil {
+			backoffPolicy = pc.client.reconnectBackoffPolicy()
+		}
+		if backoffPolicy == nil {
 			delayReconnectTime = defaultBackoff.Next()
 		} else {
-			delayReconnectTime = pc.options.backoffPolicy.Next()
+			delayReconnectTime = backoffPolicy.Next()
+		}
+		if attempt == 0 && pc.unload.paused() {
+			// look the topic up again right away, it's likely already owned by another broker
+			delayReconnectTime = 0
 		}
 
 		pc.log.Info("Reconnecting to broker in ", delayReconnectTime)
 		time.Sleep(delayReconnectTime)
+		if attempt > 0 && !pc.client.retryBudget.Withdraw() {
+			pc.log.Warn("Retry budget exhausted, delaying the reconnection")
+			continue
+		}
 
 		err := pc.grabConn()
 		if err == nil {
 			// Successfully reconnected
 			pc.log.Info("Reconnected consumer to broker")
+			pc.unload.finish(nil)
+			pc.resendUnsentAcks()
 			return
 		}
+		lastErr = err
 		pc.log.WithError(err).Error("Failed to create consumer at reconnect")
+		pc.lastError.set(err)
 		errMsg := err.Error()
 		if strings.Contains(errMsg, errTopicNotFount) {
 			// when topic is deleted, we should give up reconnection.
 			pc.log.Warn("Topic Not Found.")
 			break
 		}
+		if pulsarErr, ok := err.(*Error); ok && pulsarErr.Result() == UnsupportedVersionError {
+			// the broker serving the topic is too old, retrying would not help
+			break
+		}
 
 		if maxRetry > 0 {
 			maxRetry--
@@ -1467,16 +2282,49 @@ func (pc *partitionConsumer) reconnectToBroker() {
 			pc.metrics.ConsumersReconnectMaxRetry.Inc()
 		}
 	}
+	pc.options.asyncErrors.report(ConsumerOpReconnect, pc.topic, nil, lastErr)
+	pc.unload.finish(lastErr)
+}
+
+// checkBrokerFeatures fails if the broker is too old for the optional features enabled on the consumer
+func (pc *partitionConsumer) checkBrokerFeatures(lr *internal.LookupResult) error {
+	if !pc.options.ackWithResponse && !pc.groupedAckReceipts() && !pc.options.enableBatchIndexAck {
+		return nil
+	}
+	cnx, err := pc.client.cnxPool.GetConnectionWithAuth(lr.LogicalAddr, lr.PhysicalAddr, pc.options.authProvider)
+	if err != nil {
+		return err
+	}
+	info := newBrokerInfo(cnx.GetServerInfo())
+	if pc.options.ackWithResponse {
+		if err := info.require("AckWithResponse", info.Features.AckReceipt); err != nil {
+			return err
+		}
+	}
+	if pc.groupedAckReceipts() {
+		if err := info.require("AckGroupingOptions.ReceiptEnabled", info.Features.AckReceipt); err != nil {
+			return err
+		}
+	}
+	if pc.options.enableBatchIndexAck {
+		return info.require("EnableBatchIndexAcknowledgment", info.Features.BatchIndexAck)
+	}
+	return nil
 }
 
 func (pc *partitionConsumer) grabConn() error {
-	lr, err := pc.client.lookupService.Lookup(pc.topic)
+	lr, err := pc.affinity.lookup(pc.client, pc.topic)
 	if err != nil {
 		pc.log.WithError(err).Warn("Failed to lookup topic")
 		return err
 	}
 	pc.log.Debugf("Lookup result: %+v", lr)
 
+	if err := pc.checkBrokerFeatures(lr); err != nil {
+		pc.log.WithError(err).Error("Broker does not support the consumer options")
+		return err
+	}
+
 	subType := toProtoSubType(pc.options.subscriptionType)
 	initialPosition := toProtoInitialPosition(pc.options.subscriptionInitPos)
 	keySharedMeta := toProtoKeySharedMeta(pc.options.keySharedPolicy)
@@ -1505,7 +2353,7 @@ func (pc *partitionConsumer) grabConn() error {
 		RequestId:                  proto.Uint64(requestID),
 		ConsumerName:               proto.String(pc.name),
 		PriorityLevel:              nil,
-		Durable:                    proto.Bool(pc.options.subscriptionMode == durable),
+		Durable:                    proto.Bool(pc.options.subscriptionMode == Durable),
 		Metadata:                   internal.ConvertFromStringMap(pc.options.metadata),
 		SubscriptionProperties:     internal.ConvertFromStringMap(pc.options.subProperties),
 		ReadCompacted:              proto.Bool(pc.options.readCompacted),
@@ -1516,7 +2364,7 @@ func (pc *partitionConsumer) grabConn() error {
 	}
 
 	pc.startMessageID.set(pc.clearReceiverQueue())
-	if pc.options.subscriptionMode != durable {
+	if pc.options.subscriptionMode != Durable {
 		// For regular subscriptions the broker will determine the restarting point
 		cmdSubscribe.StartMessageId = convertToMessageIDData(pc.startMessageID.get())
 	}
@@ -1535,8 +2383,8 @@ func (pc *partitionConsumer) grabConn() error {
 		cmdSubscribe.ForceTopicCreation = proto.Bool(false)
 	}
 
-	res, err := pc.client.rpcClient.Request(lr.LogicalAddr, lr.PhysicalAddr, requestID,
-		pb.BaseCommand_SUBSCRIBE, cmdSubscribe)
+	res, err := pc.client.rpcClient.RequestWithAuth(pc.options.authProvider, lr.LogicalAddr, lr.PhysicalAddr,
+		requestID, pb.BaseCommand_SUBSCRIBE, cmdSubscribe)
 
 	if err != nil {
 		pc.log.WithError(err).Error("Failed to create consumer")
@@ -1548,6 +2396,7 @@ func (pc *partitionConsumer) grabConn() error {
 	}
 
 	pc._setConn(res.Cnx)
+	pc.affinity.connected(lr)
 	pc.log.Info("Connected consumer")
 	err = pc._getConn().AddConsumeHandler(pc.consumerID, pc)
 	if err != nil {
@@ -1559,6 +2408,8 @@ func (pc *partitionConsumer) grabConn() error {
 
 	switch msgType {
 	case pb.BaseCommand_SUCCESS:
+		// the broker rejects the subscription when the requested ranges conflict with other consumers
+		pc.setKeyHashRanges(toKeyHashRanges(pc.options.keySharedPolicy))
 		// notify the dispatcher we have connection
 		go func() {
 			pc.connectedCh <- struct{}{}
@@ -1644,7 +2495,7 @@ func getPreviousMessage(mid *trackingMessageID) *trackingMessageID {
 func (pc *partitionConsumer) Decompress(msgMeta *pb.MessageMetadata, payload internal.Buffer) (internal.Buffer, error) {
 	providerEntry, ok := pc.compressionProviders.Load(msgMeta.GetCompression())
 	if !ok {
-		newProvider, err := pc.initializeCompressionProvider(msgMeta.GetCompression())
+		newProvider, err := newCompressionProvider(msgMeta.GetCompression())
 		if err != nil {
 			pc.log.WithError(err).Error("Failed to decompress message.")
 			return nil, err
@@ -1672,8 +2523,7 @@ func (pc *partitionConsumer) Decompress(msgMeta *pb.MessageMetadata, payload int
 	return internal.NewBufferWrapper(uncompressed), nil
 }
 
-func (pc *partitionConsumer) initializeCompressionProvider(
-	compressionType pb.CompressionType) (compression.Provider, error) {
+func newCompressionProvider(compressionType pb.CompressionType) (compression.Provider, error) {
 	switch compressionType {
 	case pb.CompressionType_NONE:
 		return compression.NewNoopProvider(), nil
@@ -1683,6 +2533,8 @@ func (pc *partitionConsumer) initializeCompressionProvider(
 		return compression.NewLz4Provider(), nil
 	case pb.CompressionType_ZSTD:
 		return compression.NewZStdProvider(compression.Default), nil
+	case pb.CompressionType_SNAPPY:
+		return compression.NewSnappyProvider(compression.Default), nil
 	}
 
 	return nil, fmt.Errorf("unsupported compression type: %v", compressionType)
@@ -1699,6 +2551,10 @@ func (pc *partitionConsumer) discardCorruptedMessage(msgID *pb.MessageIdData,
 		"msgID":           msgID,
 		"validationError": validationError,
 	}).Error("Discarding corrupted message")
+	pc.options.asyncErrors.report(ConsumerOpDecode, pc.topic,
+		newMessageID(int64(msgID.GetLedgerId()), int64(msgID.GetEntryId()), msgID.GetBatchIndex(), pc.partitionIdx,
+			msgID.GetBatchSize()),
+		fmt.Errorf("corrupted message discarded: %s", validationError))
 
 	err := pc.client.rpcClient.RequestOnCnxNoWait(pc._getConn(),
 		pb.BaseCommand_ACK, &pb.CommandAck{
@@ -1763,6 +2619,8 @@ type chunkedMsgCtx struct {
 	lastChunkedMsgID int32
 	chunkedMsgIDs    []*messageID
 	receivedTime     int64
+	// reservedBytes is the size of the buffer, reserved from the memory limit of the client
+	reservedBytes int64
 
 	mu sync.Mutex
 }
@@ -1774,6 +2632,7 @@ func newChunkedMsgCtx(numChunksFromMsg int32, totalChunkMsgSize int) *chunkedMsg
 		lastChunkedMsgID: -1,
 		chunkedMsgIDs:    make([]*messageID, numChunksFromMsg),
 		receivedTime:     time.Now().Unix(),
+		reservedBytes:    int64(totalChunkMsgSize),
 	}
 }
 
@@ -1825,16 +2684,32 @@ type chunkedMsgCtxMap struct {
 	pc             *partitionConsumer
 	mu             sync.Mutex
 	closed         bool
+
+	// bufferedBytes are the bytes reserved by the pending chunked messages, up to maxBytes if positive
+	bufferedBytes int64
+	maxBytes      int64
+	memLimit      internal.MemoryLimitController
 }
 
+const (
+	chunkDiscardedExpired    = "expired"
+	chunkDiscardedMaxPending = "max_pending"
+	chunkDiscardedMaxBytes   = "max_bytes"
+)
+
 func newChunkedMsgCtxMap(maxPending int, pc *partitionConsumer) *chunkedMsgCtxMap {
-	return &chunkedMsgCtxMap{
+	c := &chunkedMsgCtxMap{
 		chunkedMsgCtxs: make(map[string]*chunkedMsgCtx, maxPending),
 		pendingQueue:   list.New(),
 		maxPending:     maxPending,
 		pc:             pc,
 		mu:             sync.Mutex{},
+		maxBytes:       pc.options.maxPendingChunkedBytes,
+	}
+	if pc.client != nil {
+		c.memLimit = pc.client.memLimit
 	}
+	return c
 }
 
 func (c *chunkedMsgCtxMap) addIfAbsent(uuid string, totalChunks int32, totalChunkMsgSize int) {
@@ -1844,8 +2719,15 @@ func (c *chunkedMsgCtxMap) addIfAbsent(uuid string, totalChunks int32, totalChun
 		return
 	}
 	if _, ok := c.chunkedMsgCtxs[uuid]; !ok {
+		if !c.reserveLocked(int64(totalChunkMsgSize)) {
+			c.pc.log.Warnf("Discarding the chunked message [%s] of %d bytes, over the limits of the buffered bytes",
+				uuid, totalChunkMsgSize)
+			c.pc.metrics.ChunkedMessagesDiscarded.WithLabelValues(chunkDiscardedMaxBytes).Inc()
+			return
+		}
 		c.chunkedMsgCtxs[uuid] = newChunkedMsgCtx(totalChunks, totalChunkMsgSize)
 		c.pendingQueue.PushBack(uuid)
+		c.pc.metrics.ChunkedMessagesPending.Inc()
 		go c.discardChunkIfExpire(uuid, true, c.pc.options.expireTimeOfIncompleteChunk)
 	}
 	if c.maxPending > 0 && c.pendingQueue.Len() > c.maxPending {
@@ -1853,6 +2735,56 @@ func (c *chunkedMsgCtxMap) addIfAbsent(uuid string, totalChunks int32, totalChun
 	}
 }
 
+// reserveLocked reserves the buffer of a new chunked message, discarding the oldest pending chunked messages
+// until it fits within maxBytes and the memory limit of the client
+func (c *chunkedMsgCtxMap) reserveLocked(size int64) bool {
+	for {
+		if c.maxBytes <= 0 || c.bufferedBytes+size <= c.maxBytes {
+			if c.memLimit == nil || c.memLimit.TryReserveMemory(size) {
+				break
+			}
+		}
+		oldest := c.pendingQueue.Front()
+		if oldest == nil {
+			return false
+		}
+		uuid := oldest.Value.(string)
+		c.pc.log.Infof("Discarding the chunked message [%s] to buffer a new one", uuid)
+		c.pc.metrics.ChunkedMessagesDiscarded.WithLabelValues(chunkDiscardedMaxBytes).Inc()
+		c.discardLocked(uuid, c.pc.options.autoAckIncompleteChunk)
+	}
+	c.bufferedBytes += size
+	c.pc.metrics.ChunkedBytesBuffered.Add(float64(size))
+	return true
+}
+
+// discardLocked removes the pending chunked message and releases its buffer
+func (c *chunkedMsgCtxMap) discardLocked(uuid string, autoAck bool) {
+	ctx, ok := c.chunkedMsgCtxs[uuid]
+	if ok {
+		if autoAck {
+			ctx.discard(c.pc)
+		}
+		c.releaseLocked(ctx)
+		delete(c.chunkedMsgCtxs, uuid)
+	}
+	for e := c.pendingQueue.Front(); e != nil; e = e.Next() {
+		if e.Value.(string) == uuid {
+			c.pendingQueue.Remove(e)
+			break
+		}
+	}
+}
+
+func (c *chunkedMsgCtxMap) releaseLocked(ctx *chunkedMsgCtx) {
+	c.bufferedBytes -= ctx.reservedBytes
+	if c.memLimit != nil {
+		c.memLimit.ReleaseMemory(ctx.reservedBytes)
+	}
+	c.pc.metrics.ChunkedBytesBuffered.Sub(float64(ctx.reservedBytes))
+	c.pc.metrics.ChunkedMessagesPending.Dec()
+}
+
 func (c *chunkedMsgCtxMap) get(uuid string) *chunkedMsgCtx {
 	c.mu.Lock()
 	defer c.mu.Unlock()
@@ -1868,14 +2800,7 @@ func (c *chunkedMsgCtxMap) remove(uuid string) {
 	if c.closed {
 		return
 	}
-	delete(c.chunkedMsgCtxs, uuid)
-	e := c.pendingQueue.Front()
-	for ; e != nil; e = e.Next() {
-		if e.Value.(string) == uuid {
-			c.pendingQueue.Remove(e)
-			break
-		}
-	}
+	c.discardLocked(uuid, false)
 }
 
 func (c *chunkedMsgCtxMap) discardOldestChunkMessage(autoAck bool) {
@@ -1885,14 +2810,11 @@ func (c *chunkedMsgCtxMap) discardOldestChunkMessage(autoAck bool) {
 		return
 	}
 	oldest := c.pendingQueue.Front().Value.(string)
-	ctx, ok := c.chunkedMsgCtxs[oldest]
-	if !ok {
+	if _, ok := c.chunkedMsgCtxs[oldest]; !ok {
 		return
 	}
-	if autoAck {
-		ctx.discard(c.pc)
-	}
-	delete(c.chunkedMsgCtxs, oldest)
+	c.discardLocked(oldest, autoAck)
+	c.pc.metrics.ChunkedMessagesDiscarded.WithLabelValues(chunkDiscardedMaxPending).Inc()
 	c.pc.log.Infof("Chunked message [%s] has been removed from chunkedMsgCtxMap", oldest)
 }
 
@@ -1902,21 +2824,11 @@ func (c *chunkedMsgCtxMap) discardChunkMessage(uuid string, autoAck bool) {
 	if c.closed {
 		return
 	}
-	ctx, ok := c.chunkedMsgCtxs[uuid]
-	if !ok {
+	if _, ok := c.chunkedMsgCtxs[uuid]; !ok {
 		return
 	}
-	if autoAck {
-		ctx.discard(c.pc)
-	}
-	delete(c.chunkedMsgCtxs, uuid)
-	e := c.pendingQueue.Front()
-	for ; e != nil; e = e.Next() {
-		if e.Value.(string) == uuid {
-			c.pendingQueue.Remove(e)
-			break
-		}
-	}
+	c.discardLocked(uuid, autoAck)
+	c.pc.metrics.ChunkedMessagesDiscarded.WithLabelValues(chunkDiscardedExpired).Inc()
 	c.pc.log.Infof("Chunked message [%s] has been removed from chunkedMsgCtxMap", uuid)
 }
 
@@ -1930,6 +2842,11 @@ func (c *chunkedMsgCtxMap) Close() {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	c.closed = true
+	for uuid, ctx := range c.chunkedMsgCtxs {
+		c.releaseLocked(ctx)
+		delete(c.chunkedMsgCtxs, uuid)
+	}
+	c.pendingQueue.Init()
 }
 
 type unAckChunksTracker struct {
@@ -18,19 +18,26 @@
 package pulsar
 
 import (
+	"errors"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/apache/pulsar-client-go/pulsar/internal"
 	"github.com/apache/pulsar-client-go/pulsar/internal/crypto"
+	pb "github.com/apache/pulsar-client-go/pulsar/internal/pulsar_proto"
+	plog "github.com/apache/pulsar-client-go/pulsar/log"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
 )
 
 func TestSingleMessageIDNoAckTracker(t *testing.T) {
 	eventsCh := make(chan interface{}, 1)
 	pc := partitionConsumer{
-		queueCh:              make(chan []*message, 1),
+		queue:                internal.NewRingBuffer(1),
 		eventsCh:             eventsCh,
 		compressionProviders: sync.Map{},
 		options:              &partitionConsumerOpts{},
@@ -38,7 +45,7 @@ func TestSingleMessageIDNoAckTracker(t *testing.T) {
 		decryptor:            crypto.NewNoopDecryptor(),
 	}
 	pc.ackGroupingTracker = newAckGroupingTracker(&AckGroupingOptions{MaxSize: 0},
-		func(id MessageID) { pc.sendIndividualAck(id) }, nil)
+		func(id MessageID) { pc.sendIndividualAck(id) }, nil, nil, nil)
 
 	headersAndPayload := internal.NewBufferWrapper(rawCompatSingleMessage)
 	if err := pc.MessageReceived(nil, headersAndPayload); err != nil {
@@ -46,7 +53,7 @@ func TestSingleMessageIDNoAckTracker(t *testing.T) {
 	}
 
 	// ensure the tracker was set on the message id
-	messages := <-pc.queueCh
+	messages := pc.queue.Drain(nil)[0].([]*message)
 	for _, m := range messages {
 		assert.Nil(t, m.ID().(*trackingMessageID).tracker)
 	}
@@ -62,13 +69,14 @@ func TestSingleMessageIDNoAckTracker(t *testing.T) {
 }
 
 func newTestMetrics() *internal.LeveledMetrics {
-	return internal.NewMetricsProvider(4, map[string]string{}, prometheus.DefaultRegisterer).GetLeveledMetrics("topic")
+	return internal.NewMetricsProvider(4, map[string]string{}, prometheus.DefaultRegisterer).
+		GetLeveledConsumerMetrics("topic", "sub")
 }
 
 func TestBatchMessageIDNoAckTracker(t *testing.T) {
 	eventsCh := make(chan interface{}, 1)
 	pc := partitionConsumer{
-		queueCh:              make(chan []*message, 1),
+		queue:                internal.NewRingBuffer(1),
 		eventsCh:             eventsCh,
 		compressionProviders: sync.Map{},
 		options:              &partitionConsumerOpts{},
@@ -76,7 +84,7 @@ func TestBatchMessageIDNoAckTracker(t *testing.T) {
 		decryptor:            crypto.NewNoopDecryptor(),
 	}
 	pc.ackGroupingTracker = newAckGroupingTracker(&AckGroupingOptions{MaxSize: 0},
-		func(id MessageID) { pc.sendIndividualAck(id) }, nil)
+		func(id MessageID) { pc.sendIndividualAck(id) }, nil, nil, nil)
 
 	headersAndPayload := internal.NewBufferWrapper(rawBatchMessage1)
 	if err := pc.MessageReceived(nil, headersAndPayload); err != nil {
@@ -84,7 +92,7 @@ func TestBatchMessageIDNoAckTracker(t *testing.T) {
 	}
 
 	// ensure the tracker was set on the message id
-	messages := <-pc.queueCh
+	messages := pc.queue.Drain(nil)[0].([]*message)
 	for _, m := range messages {
 		assert.Nil(t, m.ID().(*trackingMessageID).tracker)
 	}
@@ -103,7 +111,7 @@ func TestBatchMessageIDNoAckTracker(t *testing.T) {
 func TestBatchMessageIDWithAckTracker(t *testing.T) {
 	eventsCh := make(chan interface{}, 1)
 	pc := partitionConsumer{
-		queueCh:              make(chan []*message, 1),
+		queue:                internal.NewRingBuffer(1),
 		eventsCh:             eventsCh,
 		compressionProviders: sync.Map{},
 		options:              &partitionConsumerOpts{},
@@ -111,7 +119,7 @@ func TestBatchMessageIDWithAckTracker(t *testing.T) {
 		decryptor:            crypto.NewNoopDecryptor(),
 	}
 	pc.ackGroupingTracker = newAckGroupingTracker(&AckGroupingOptions{MaxSize: 0},
-		func(id MessageID) { pc.sendIndividualAck(id) }, nil)
+		func(id MessageID) { pc.sendIndividualAck(id) }, nil, nil, nil)
 
 	headersAndPayload := internal.NewBufferWrapper(rawBatchMessage10)
 	if err := pc.MessageReceived(nil, headersAndPayload); err != nil {
@@ -119,7 +127,7 @@ func TestBatchMessageIDWithAckTracker(t *testing.T) {
 	}
 
 	// ensure the tracker was set on the message id
-	messages := <-pc.queueCh
+	messages := pc.queue.Drain(nil)[0].([]*message)
 	for _, m := range messages {
 		assert.NotNil(t, m.ID().(*trackingMessageID).tracker)
 	}
@@ -223,3 +231,436 @@ var rawBatchMessage10 = []byte{
 	0x28, 0x05, 0x40, 0x09, 0x68, 0x65, 0x6c, 0x6c,
 	0x6f,
 }
+
+func TestKafkaHeadersToProperties(t *testing.T) {
+	properties := kafkaHeadersToProperties([]internal.KafkaRecordHeader{
+		{Key: "a", Value: []byte("1")},
+		{Key: "b", Value: nil},
+		{Key: "a", Value: []byte("2")},
+	})
+	assert.Equal(t, map[string]string{"a": "2", "b": ""}, properties)
+}
+
+func TestPartitionConsumerSetReceiverQueueSize(t *testing.T) {
+	pc := partitionConsumer{
+		queue:     internal.NewRingBuffer(100),
+		queueSize: 100,
+		options:   &partitionConsumerOpts{},
+	}
+	pc.availablePermits = &availablePermits{pc: &pc}
+
+	// the permits of the next consumed messages are withheld
+	pc.setReceiverQueueSize(40)
+	assert.Equal(t, int32(40), pc.queueSize)
+	assert.Equal(t, int32(-60), pc.availablePermits.permits)
+
+	// the queue can not grow beyond its capacity
+	pc.setReceiverQueueSize(500)
+	assert.Equal(t, int32(100), pc.queueSize)
+
+	pc.setReceiverQueueSize(0)
+	assert.Equal(t, int32(1), pc.queueSize)
+}
+
+func TestPartitionConsumerAckReceipt(t *testing.T) {
+	receipts := make(chan AckResult, 1)
+	pc := partitionConsumer{
+		options: &partitionConsumerOpts{
+			ackWithResponse: true,
+			ackReceiptCh:    receipts,
+		},
+		log: plog.DefaultNopLogger(),
+	}
+	pc.setConsumerState(consumerClosed)
+
+	msgID := newTrackingMessageID(1, 2, 0, 0, 1, nil)
+	req := &ackRequest{
+		doneCh:     make(chan struct{}),
+		ackType:    individualAck,
+		msgID:      *msgID,
+		receiptIDs: []MessageID{msgID},
+	}
+	pc.internalAck(req)
+
+	receipt := <-receipts
+	assert.Equal(t, msgID, receipt.MessageID)
+	assert.Error(t, receipt.Err)
+
+	// the acks waited for have no receipt
+	pc.internalAck(&ackRequest{
+		doneCh:  make(chan struct{}),
+		ackType: individualAck,
+		msgID:   *msgID,
+	})
+	assert.Equal(t, 0, len(receipts))
+}
+
+// asyncAckRPCClient holds the callbacks of the requests sent without waiting for the response
+type asyncAckRPCClient struct {
+	internal.RPCClient
+	callbacks chan func(*internal.RPCResult, error)
+}
+
+func (c *asyncAckRPCClient) NewRequestID() uint64 {
+	return 1
+}
+
+func (c *asyncAckRPCClient) RequestOnCnxAsync(_ internal.Connection, _ uint64, _ pb.BaseCommand_Type,
+	_ proto.Message, callback func(*internal.RPCResult, error)) {
+	c.callbacks <- callback
+}
+
+func TestPartitionConsumerAckReceiptAsync(t *testing.T) {
+	receipts := make(chan AckResult, 2)
+	rpcClient := &asyncAckRPCClient{callbacks: make(chan func(*internal.RPCResult, error), 1)}
+	pc := &partitionConsumer{
+		client:   &client{rpcClient: rpcClient},
+		eventsCh: make(chan interface{}, 10),
+		options: &partitionConsumerOpts{
+			ackWithResponse: true,
+			ackReceiptCh:    receipts,
+		},
+		metrics: newTestMetrics(),
+		log:     plog.DefaultNopLogger(),
+	}
+	pc.conn.Store(closedConnection{})
+
+	// the receipt of a message of a batch is held until the batch is acknowledged
+	tracker := newAckTracker(2)
+	first := newTrackingMessageID(1, 2, 0, 0, 2, tracker)
+	second := newTrackingMessageID(1, 2, 1, 0, 2, tracker)
+	assert.NoError(t, pc.AckIDWithResponse(first))
+	assert.Empty(t, pc.eventsCh)
+	assert.NoError(t, pc.AckIDWithResponse(second))
+	req := (<-pc.eventsCh).(*ackRequest)
+	assert.Equal(t, []MessageID{first, second}, req.receiptIDs)
+
+	// the events loop doesn't wait for the response of the broker
+	pc.internalAck(req)
+	flush := &flushAcksRequest{doneCh: make(chan struct{})}
+	pc.internalFlushAcks(flush)
+	assert.Empty(t, receipts)
+	select {
+	case <-flush.doneCh:
+		t.Fatal("the flush must wait for the receipts")
+	default:
+	}
+
+	(<-rpcClient.callbacks)(nil, nil)
+	<-flush.doneCh
+	assert.NoError(t, flush.err)
+	assert.Equal(t, AckResult{MessageID: first}, <-receipts)
+	assert.Equal(t, AckResult{MessageID: second}, <-receipts)
+}
+
+func TestPartitionConsumerGroupedAckReceipts(t *testing.T) {
+	receipts := make(chan AckResult, 2)
+	eventsCh := make(chan interface{}, 1)
+	pc := partitionConsumer{
+		topic:    "topic",
+		eventsCh: eventsCh,
+		options: &partitionConsumerOpts{
+			ackGroupingOptions: &AckGroupingOptions{MaxSize: 1000, ReceiptEnabled: true},
+			ackReceiptCh:       receipts,
+			asyncErrors:        newConsumerErrors(),
+		},
+		log: plog.DefaultNopLogger(),
+	}
+	pc.setConsumerState(consumerClosed)
+
+	pc.sendListAck([]MessageID{
+		newTrackingMessageID(1, 2, 0, 0, 1, nil),
+		newTrackingMessageID(1, 3, 0, 0, 1, nil),
+	})
+	req := (<-eventsCh).(*ackListRequest)
+	assert.True(t, req.receipt)
+	pc.internalAckList(req)
+
+	for _, entryID := range []int64{2, 3} {
+		receipt := <-receipts
+		assert.Equal(t, entryID, receipt.MessageID.EntryID())
+		assert.Error(t, receipt.Err)
+
+		err := (<-pc.options.asyncErrors.errors()).(*ConsumerAsyncError)
+		assert.Equal(t, ConsumerOpAck, err.Op)
+		assert.Equal(t, "topic", err.Topic)
+		assert.Equal(t, entryID, err.MessageID.EntryID())
+	}
+
+	msgID := newTrackingMessageID(1, 4, 0, 0, 1, nil)
+	pc.sendGroupedAck(msgID, cumulativeAck)
+	ackReq := (<-eventsCh).(*ackRequest)
+	assert.True(t, ackReq.receipt)
+	assert.Equal(t, []MessageID{msgID}, ackReq.receiptIDs)
+	pc.internalAck(ackReq)
+	assert.Error(t, (<-receipts).Err)
+	assert.Equal(t, ConsumerOpAck, (<-pc.options.asyncErrors.errors()).(*ConsumerAsyncError).Op)
+}
+
+func TestPartitionConsumerAutoCumulativeAck(t *testing.T) {
+	eventsCh := make(chan interface{}, 10)
+	pc := partitionConsumer{
+		eventsCh: eventsCh,
+		options: &partitionConsumerOpts{
+			subscriptionType:  Exclusive,
+			autoCumulativeAck: true,
+		},
+		metrics: newTestMetrics(),
+		log:     plog.DefaultNopLogger(),
+	}
+	pc.ackHoleTracker = newAckHoleTracker(&AckHoleTrackingOptions{}, pc.log)
+	pc.ackGroupingTracker = newAckGroupingTracker(&AckGroupingOptions{MaxSize: 0},
+		func(id MessageID) { pc.sendIndividualAck(id) },
+		func(id MessageID) { pc.sendCumulativeAck(id) }, nil, nil)
+
+	ids := make([]*trackingMessageID, 3)
+	for i := range ids {
+		ids[i] = newTrackingMessageID(1, int64(i), -1, 0, 0, nil)
+		pc.ackHoleTracker.delivered(ids[i])
+	}
+
+	// the acknowledgment after a hole is sent individually
+	assert.NoError(t, pc.AckID(ids[1]))
+	req := (<-eventsCh).(*ackRequest)
+	assert.Equal(t, individualAck, req.ackType)
+	assert.Equal(t, int64(1), req.msgID.entryID)
+
+	// acknowledging the hole acknowledges the prefix cumulatively
+	assert.NoError(t, pc.AckID(ids[0]))
+	req = (<-eventsCh).(*ackRequest)
+	assert.Equal(t, cumulativeAck, req.ackType)
+	assert.Equal(t, int64(1), req.msgID.entryID)
+
+	// the promotion does not apply to the shared subscriptions
+	pc.options.subscriptionType = Shared
+	assert.NoError(t, pc.AckID(ids[2]))
+	req = (<-eventsCh).(*ackRequest)
+	assert.Equal(t, individualAck, req.ackType)
+	assert.Equal(t, int64(2), req.msgID.entryID)
+}
+
+func TestUnsubscribeForceField(t *testing.T) {
+	cmd := &pb.CommandUnsubscribe{
+		ConsumerId: proto.Uint64(1),
+		RequestId:  proto.Uint64(2),
+	}
+	setUnsubscribeForce(cmd)
+
+	raw, err := proto.Marshal(cmd)
+	assert.NoError(t, err)
+
+	// the force field is serialized after the known fields
+	force := false
+	for data := raw; len(data) > 0; {
+		num, typ, n := protowire.ConsumeTag(data)
+		assert.True(t, n > 0)
+		data = data[n:]
+		v, n := protowire.ConsumeVarint(data)
+		assert.True(t, n > 0)
+		assert.Equal(t, protowire.VarintType, typ)
+		data = data[n:]
+		if num == unsubscribeForceField {
+			force = protowire.DecodeBool(v)
+		}
+	}
+	assert.True(t, force)
+
+	decoded := &pb.CommandUnsubscribe{}
+	assert.NoError(t, proto.Unmarshal(raw, decoded))
+	assert.Equal(t, uint64(1), decoded.GetConsumerId())
+	assert.Equal(t, uint64(2), decoded.GetRequestId())
+}
+
+func TestUnsubscribeError(t *testing.T) {
+	err := toUnsubscribeError(errors.New("server error: ConsumerBusy: Subscription has active connected consumers"))
+	assert.Equal(t, ConsumerBusy, err.(*Error).Result())
+
+	err = toUnsubscribeError(errors.New("server error: AuthorizationError: Client is not authorized"))
+	assert.Equal(t, AuthorizationError, err.(*Error).Result())
+
+	other := errors.New("server error: MetadataError: failed")
+	assert.Equal(t, other, toUnsubscribeError(other))
+}
+
+func TestPartitionConsumerSubscriptionStart(t *testing.T) {
+	for _, inclusive := range []bool{true, false} {
+		pc := partitionConsumer{
+			partitionIdx: 1,
+			options: &partitionConsumerOpts{
+				subscriptionStartID:     newTrackingMessageID(1, 5, -1, 0, 0, nil),
+				startMessageIDInclusive: inclusive,
+			},
+			log: plog.DefaultNopLogger(),
+		}
+
+		// the start message id of another partition does not apply
+		assert.NoError(t, pc.seekToSubscriptionStart())
+		assert.Nil(t, pc.subscriptionStart.get())
+
+		pc.subscriptionStart.set(newTrackingMessageID(1, 5, -1, 1, 0, nil))
+		assert.True(t, pc.messageShouldBeDiscarded(newTrackingMessageID(1, 4, -1, 1, 0, nil)))
+		assert.Equal(t, !inclusive, pc.messageShouldBeDiscarded(newTrackingMessageID(1, 5, -1, 1, 0, nil)))
+		assert.False(t, pc.messageShouldBeDiscarded(newTrackingMessageID(1, 6, -1, 1, 0, nil)))
+	}
+}
+
+func TestPartitionConsumerRejectMessages(t *testing.T) {
+	eventsCh := make(chan interface{}, 10)
+	pc := partitionConsumer{
+		eventsCh:  eventsCh,
+		queueSize: 100,
+		options: &partitionConsumerOpts{
+			messageFilter:   func(msg Message) bool { return msg.Key() != "filtered" },
+			filterRejection: AckAndDropMessage,
+			validateSchema:  true,
+			decodeFailure:   RouteMessageToDLQ,
+			schema:          NewJSONSchema(`{"type":"string"}`, nil),
+		},
+		metrics: newTestMetrics(),
+		log:     plog.DefaultNopLogger(),
+	}
+	pc.availablePermits = &availablePermits{pc: &pc}
+	pc.ackGroupingTracker = newAckGroupingTracker(&AckGroupingOptions{MaxSize: 0},
+		func(id MessageID) { pc.sendIndividualAck(id) },
+		func(id MessageID) { pc.sendCumulativeAck(id) }, nil, nil)
+
+	msgs := []*message{
+		{key: "kept", payLoad: []byte(`"value"`), msgID: newTrackingMessageID(1, 1, -1, 0, 0, nil)},
+		{key: "filtered", payLoad: []byte(`"value"`), msgID: newTrackingMessageID(1, 2, -1, 0, 0, nil)},
+		{key: "kept", payLoad: []byte(`{invalid`), msgID: newTrackingMessageID(1, 3, -1, 0, 0, nil)},
+	}
+	kept := pc.rejectMessages(msgs)
+
+	// the filtered message is acknowledged and its permit released
+	assert.Len(t, kept, 2)
+	assert.False(t, kept[0].rejected)
+	req := (<-eventsCh).(*ackRequest)
+	assert.Equal(t, int64(2), req.msgID.entryID)
+	assert.Equal(t, int32(1), pc.availablePermits.permits)
+
+	// the message failing the validation is routed to the DLQ with the reason
+	assert.True(t, kept[1].rejected)
+	assert.Contains(t, kept[1].properties[PropertyRejectionReason], "decode failure")
+
+	assert.Equal(t, float64(1), testutil.ToFloat64(pc.metrics.MessagesRejected.WithLabelValues("filter", "ack")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(pc.metrics.MessagesRejected.WithLabelValues("decode", "dlq")))
+}
+
+func TestChunkedMsgCtxMapBufferedBytesLimit(t *testing.T) {
+	memLimit := internal.NewMemoryLimitController(250)
+	pc := &partitionConsumer{
+		client: &client{memLimit: memLimit},
+		options: &partitionConsumerOpts{
+			maxPendingChunkedBytes:      200,
+			expireTimeOfIncompleteChunk: time.Hour,
+		},
+		metrics: internal.NewMetricsProvider(4, map[string]string{}, prometheus.NewRegistry()).
+			GetLeveledConsumerMetrics("topic", "sub"),
+		log: plog.DefaultNopLogger(),
+	}
+	c := newChunkedMsgCtxMap(10, pc)
+
+	c.addIfAbsent("a", 2, 100)
+	c.addIfAbsent("b", 2, 100)
+	assert.Equal(t, int64(200), memLimit.CurrentUsage())
+
+	// the oldest pending chunked message is discarded to fit within the bytes of the topic
+	c.addIfAbsent("c", 2, 50)
+	assert.Nil(t, c.get("a"))
+	assert.NotNil(t, c.get("b"))
+	assert.NotNil(t, c.get("c"))
+	assert.Equal(t, int64(150), c.bufferedBytes)
+	assert.Equal(t, int64(150), memLimit.CurrentUsage())
+
+	// and to fit within the memory limit of the client
+	memLimit.ForceReserveMemory(150)
+	c.addIfAbsent("d", 2, 10)
+	assert.Nil(t, c.get("b"))
+	assert.NotNil(t, c.get("d"))
+
+	// a chunked message over the limit alone is discarded
+	c.addIfAbsent("e", 2, 300)
+	assert.Nil(t, c.get("e"))
+	assert.Equal(t, 0, c.pendingQueue.Len())
+
+	assert.Equal(t, float64(5),
+		testutil.ToFloat64(pc.metrics.ChunkedMessagesDiscarded.WithLabelValues(chunkDiscardedMaxBytes)))
+	assert.Equal(t, float64(0), testutil.ToFloat64(pc.metrics.ChunkedMessagesPending))
+
+	c.addIfAbsent("f", 2, 100)
+	c.remove("f")
+	assert.Equal(t, int64(150), memLimit.CurrentUsage())
+	assert.Equal(t, float64(0), testutil.ToFloat64(pc.metrics.ChunkedBytesBuffered))
+}
+
+func TestPartitionConsumerPerfCounters(t *testing.T) {
+	pc := partitionConsumer{
+		queue:                internal.NewRingBuffer(1),
+		eventsCh:             make(chan interface{}, 1),
+		compressionProviders: sync.Map{},
+		options:              &partitionConsumerOpts{},
+		metrics:              newTestMetrics(),
+		decryptor:            crypto.NewNoopDecryptor(),
+	}
+	pc.ackGroupingTracker = newAckGroupingTracker(&AckGroupingOptions{MaxSize: 0},
+		func(id MessageID) { pc.sendIndividualAck(id) }, nil, nil, nil)
+	assert.Nil(t, pc.perf.state())
+
+	if err := pc.MessageReceived(nil, internal.NewBufferWrapper(rawBatchMessage10)); err != nil {
+		t.Fatal(err)
+	}
+	pc.queue.Drain(nil)
+
+	perf := pc.perf.state()
+	assert.NotNil(t, perf)
+	assert.Equal(t, int64(10), perf.Decode.Ops)
+	assert.Greater(t, perf.Decode.NsPerOp, float64(0))
+	assert.Equal(t, int64(1), perf.Dispatch.Ops)
+	// the entry is neither compressed nor encrypted
+	assert.Equal(t, int64(0), perf.Decompress.Ops)
+	assert.Equal(t, int64(0), perf.Decrypt.Ops)
+}
+
+func TestPartitionConsumerDispatcherQueue(t *testing.T) {
+	pc := &partitionConsumer{
+		queue:        internal.NewRingBuffer(10),
+		queueSize:    100,
+		messageCh:    make(chan ConsumerMessage),
+		closeCh:      make(chan struct{}),
+		connectedCh:  make(chan struct{}),
+		clearQueueCh: make(chan func(id *trackingMessageID)),
+		dlq:          &dlqRouter{},
+		options:      &partitionConsumerOpts{},
+		metrics:      newTestMetrics(),
+		log:          plog.DefaultNopLogger(),
+	}
+	pc.availablePermits = &availablePermits{pc: pc}
+	entry := func(entryIDs ...int64) []*message {
+		msgs := make([]*message, 0, len(entryIDs))
+		for _, id := range entryIDs {
+			msgs = append(msgs, &message{msgID: newTrackingMessageID(1, id, -1, 0, 0, nil)})
+		}
+		return msgs
+	}
+	go pc.dispatcher()
+	defer close(pc.closeCh)
+
+	// the entries taken from the queue at once are dispatched in order
+	pc.queue.Put(entry(1, 2))
+	pc.queue.Put(entry(3))
+	for _, id := range []int64{1, 2, 3} {
+		assert.Equal(t, id, (<-pc.messageCh).Message.ID().EntryID())
+	}
+
+	// clearing the queue drops the entries not dispatched yet
+	pc.queue.Put(entry(4))
+	pc.queue.Put(entry(5))
+	assert.Eventually(t, func() bool { return pc.queue.Size() == 0 }, time.Second, time.Millisecond)
+	next := make(chan *trackingMessageID, 1)
+	pc.clearQueueCh <- func(id *trackingMessageID) { next <- id }
+	assert.Equal(t, int64(5), (<-next).entryID)
+	assert.Equal(t, 0, pc.queue.Size())
+
+	pc.queue.Put(entry(6))
+	assert.Equal(t, int64(6), (<-pc.messageCh).Message.ID().EntryID())
+}
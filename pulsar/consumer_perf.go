@@ -0,0 +1,81 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsar
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// perfCounter accumulates the time spent in a stage of the consume path
+type perfCounter struct {
+	ops   int64
+	nanos int64
+}
+
+func (c *perfCounter) observe(elapsed time.Duration, ops int) {
+	atomic.AddInt64(&c.nanos, int64(elapsed))
+	atomic.AddInt64(&c.ops, int64(ops))
+}
+
+func (c *perfCounter) state() perfState {
+	s := perfState{Ops: atomic.LoadInt64(&c.ops)}
+	if s.Ops > 0 {
+		s.NsPerOp = float64(atomic.LoadInt64(&c.nanos)) / float64(s.Ops)
+	}
+	return s
+}
+
+// consumePerfCounters estimate the cost of the stages of the consume path of a partition consumer, averaged
+// since the consumer was created
+type consumePerfCounters struct {
+	// decode parses the metadata of the entries and builds the messages, by message
+	decode perfCounter
+	// decompress decompresses the payload of the entries, by entry
+	decompress perfCounter
+	// decrypt decrypts the payload of the entries, by entry
+	decrypt perfCounter
+	// dispatch hands the messages of the entries to the dispatcher, by entry, including the time waiting for
+	// the dispatcher to take them
+	dispatch perfCounter
+}
+
+type perfState struct {
+	Ops     int64   `json:"ops"`
+	NsPerOp float64 `json:"nsPerOp"`
+}
+
+type consumePerf struct {
+	Decode     perfState `json:"decode"`
+	Decompress perfState `json:"decompress"`
+	Decrypt    perfState `json:"decrypt"`
+	Dispatch   perfState `json:"dispatch"`
+}
+
+func (p *consumePerfCounters) state() *consumePerf {
+	s := &consumePerf{
+		Decode:     p.decode.state(),
+		Decompress: p.decompress.state(),
+		Decrypt:    p.decrypt.state(),
+		Dispatch:   p.dispatch.state(),
+	}
+	if s.Decode.Ops == 0 && s.Decrypt.Ops == 0 {
+		return nil
+	}
+	return s
+}
@@ -0,0 +1,128 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsar
+
+import (
+	"sync"
+	"time"
+)
+
+// RateLimit bounds the rate a consumer receives messages at, client-side: the flow permits letting the broker
+// dispatch more messages are only sent once the messages received so far fit in the rate. The first receiver
+// queue of messages is not paced, a lower ReceiverQueueSize makes the ingest smoother.
+type RateLimit struct {
+	// MessagesPerSecond is the maximum number of messages received per second, not positive for no limit
+	MessagesPerSecond int
+
+	// BytesPerSecond is the maximum number of bytes of entries received per second, not positive for no limit
+	BytesPerSecond int64
+}
+
+// rateLimiter is the token buckets of the RateLimit of a consumer, shared by all its partitions. The messages
+// are taken once received, the buckets going in debt, and the flow permits wait for the debt to be paid.
+type rateLimiter struct {
+	sync.Mutex
+	messages tokenBucket
+	bytes    tokenBucket
+	now      func() time.Time
+}
+
+// tokenBucket holds up to one second of its rate
+type tokenBucket struct {
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+// newRateLimiter returns nil if the RateLimit doesn't limit anything
+func newRateLimiter(limit RateLimit) *rateLimiter {
+	if limit.MessagesPerSecond <= 0 && limit.BytesPerSecond <= 0 {
+		return nil
+	}
+	return &rateLimiter{
+		messages: newTokenBucket(float64(limit.MessagesPerSecond)),
+		bytes:    newTokenBucket(float64(limit.BytesPerSecond)),
+		now:      time.Now,
+	}
+}
+
+func newTokenBucket(rate float64) tokenBucket {
+	if rate < 0 {
+		rate = 0
+	}
+	return tokenBucket{rate: rate, tokens: rate}
+}
+
+// take records messages received, it is a no-op on a nil rateLimiter
+func (l *rateLimiter) take(messages int, bytes int) {
+	if l == nil {
+		return
+	}
+	l.Lock()
+	defer l.Unlock()
+	now := l.now()
+	l.messages.take(float64(messages), now)
+	l.bytes.take(float64(bytes), now)
+}
+
+// delay returns how long the flow permits must wait for the messages received to fit in the rate
+func (l *rateLimiter) delay() time.Duration {
+	if l == nil {
+		return 0
+	}
+	l.Lock()
+	defer l.Unlock()
+	now := l.now()
+	if d := l.messages.delay(now); d > l.bytes.delay(now) {
+		return d
+	}
+	return l.bytes.delay(now)
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	if b.last.IsZero() {
+		b.last = now
+		return
+	}
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens += b.rate * elapsed.Seconds()
+		if b.tokens > b.rate {
+			b.tokens = b.rate
+		}
+		b.last = now
+	}
+}
+
+func (b *tokenBucket) take(n float64, now time.Time) {
+	if b.rate == 0 {
+		return
+	}
+	b.refill(now)
+	b.tokens -= n
+}
+
+func (b *tokenBucket) delay(now time.Time) time.Duration {
+	if b.rate == 0 {
+		return 0
+	}
+	b.refill(now)
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
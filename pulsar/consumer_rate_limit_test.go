@@ -0,0 +1,62 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsar
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimiter(t *testing.T) {
+	assert.Nil(t, newRateLimiter(RateLimit{}))
+	assert.Nil(t, newRateLimiter(RateLimit{MessagesPerSecond: -1}))
+
+	// a nil limiter doesn't limit
+	var nilLimiter *rateLimiter
+	nilLimiter.take(100, 100)
+	assert.Equal(t, time.Duration(0), nilLimiter.delay())
+
+	now := time.Now()
+	l := newRateLimiter(RateLimit{MessagesPerSecond: 10, BytesPerSecond: 1000})
+	l.now = func() time.Time { return now }
+
+	// one second of rate is available at first
+	l.take(10, 500)
+	assert.Equal(t, time.Duration(0), l.delay())
+
+	// the messages go in debt
+	l.take(5, 0)
+	assert.Equal(t, 500*time.Millisecond, l.delay())
+
+	// the bytes go deeper in debt
+	l.take(0, 1500)
+	assert.Equal(t, time.Second, l.delay())
+
+	now = now.Add(600 * time.Millisecond)
+	assert.Equal(t, 400*time.Millisecond, l.delay())
+
+	now = now.Add(400 * time.Millisecond)
+	assert.Equal(t, time.Duration(0), l.delay())
+
+	// the tokens don't accumulate beyond one second of rate
+	now = now.Add(time.Hour)
+	l.take(20, 0)
+	assert.Equal(t, time.Second, l.delay())
+}
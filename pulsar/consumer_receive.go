@@ -0,0 +1,135 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsar
+
+import (
+	"container/list"
+	"context"
+	"sync"
+)
+
+// receiverQueue serves the concurrent Receive calls of a consumer in FIFO order: only the caller at the head of
+// the queue waits for a message, the others wait for their turn until their own context is done. The zero value
+// is an empty queue.
+type receiverQueue struct {
+	sync.Mutex
+	busy    bool
+	waiters list.List // of chan struct{}, closed when it is the turn of the waiter
+}
+
+// acquire waits for the turn of the caller, it fails when the context is done or the consumer closed before
+func (q *receiverQueue) acquire(ctx context.Context, closeCh <-chan struct{}) error {
+	q.Lock()
+	if !q.busy {
+		q.busy = true
+		q.Unlock()
+		return nil
+	}
+	turn := make(chan struct{})
+	e := q.waiters.PushBack(turn)
+	q.Unlock()
+
+	var err error
+	select {
+	case <-turn:
+		return nil
+	case <-ctx.Done():
+		err = ctx.Err()
+	case <-closeCh:
+		err = newError(ConsumerClosed, "consumer closed")
+	}
+
+	q.Lock()
+	select {
+	case <-turn:
+		// the turn was given concurrently, pass it on
+		q.Unlock()
+		q.release()
+	default:
+		q.waiters.Remove(e)
+		q.Unlock()
+	}
+	return err
+}
+
+// tryAcquire takes the turn only when no caller has it or is waiting for it
+func (q *receiverQueue) tryAcquire() bool {
+	q.Lock()
+	defer q.Unlock()
+	if q.busy {
+		return false
+	}
+	q.busy = true
+	return true
+}
+
+// release gives the turn to the next waiter
+func (q *receiverQueue) release() {
+	q.Lock()
+	defer q.Unlock()
+	if e := q.waiters.Front(); e != nil {
+		q.waiters.Remove(e)
+		close(e.Value.(chan struct{}))
+	} else {
+		q.busy = false
+	}
+}
+
+// receive returns the next message of the channel once it is the turn of the caller
+func (q *receiverQueue) receive(ctx context.Context, messageCh <-chan ConsumerMessage,
+	closeCh <-chan struct{}) (Message, error) {
+	if err := q.acquire(ctx, closeCh); err != nil {
+		return nil, err
+	}
+	defer q.release()
+
+	select {
+	case <-closeCh:
+		return nil, newError(ConsumerClosed, "consumer closed")
+	case cm, ok := <-messageCh:
+		if !ok {
+			return nil, newError(ConsumerClosed, "consumer closed")
+		}
+		return cm.Message, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// tryReceive returns the next message of the channel if one is available and no caller is waiting for it
+func (q *receiverQueue) tryReceive(messageCh <-chan ConsumerMessage, closeCh <-chan struct{}) (Message, error) {
+	select {
+	case <-closeCh:
+		return nil, newError(ConsumerClosed, "consumer closed")
+	default:
+	}
+	if !q.tryAcquire() {
+		return nil, nil
+	}
+	defer q.release()
+
+	select {
+	case cm, ok := <-messageCh:
+		if !ok {
+			return nil, newError(ConsumerClosed, "consumer closed")
+		}
+		return cm.Message, nil
+	default:
+		return nil, nil
+	}
+}
@@ -0,0 +1,97 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsar
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func waitReceivers(t *testing.T, q *receiverQueue, n int) {
+	assert.Eventually(t, func() bool {
+		q.Lock()
+		defer q.Unlock()
+		return q.busy && q.waiters.Len() == n
+	}, time.Second, time.Millisecond)
+}
+
+func TestReceiverQueueFIFO(t *testing.T) {
+	var q receiverQueue
+	messageCh := make(chan ConsumerMessage)
+	closeCh := make(chan struct{})
+
+	results := make([]chan string, 3)
+	for i := range results {
+		results[i] = make(chan string, 1)
+		go func(res chan string) {
+			msg, err := q.receive(context.Background(), messageCh, closeCh)
+			assert.NoError(t, err)
+			res <- msg.Key()
+		}(results[i])
+		waitReceivers(t, &q, i)
+	}
+
+	for i, key := range []string{"a", "b", "c"} {
+		messageCh <- ConsumerMessage{Message: &message{key: key}}
+		assert.Equal(t, key, <-results[i])
+	}
+	q.Lock()
+	assert.False(t, q.busy)
+	q.Unlock()
+}
+
+func TestReceiverQueueDeadline(t *testing.T) {
+	var q receiverQueue
+	messageCh := make(chan ConsumerMessage, 1)
+	closeCh := make(chan struct{})
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := q.receive(context.Background(), messageCh, closeCh)
+		done <- err
+	}()
+	waitReceivers(t, &q, 0)
+
+	// a waiter gives up on its own deadline while the head keeps waiting
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := q.receive(ctx, messageCh, closeCh)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	waitReceivers(t, &q, 0)
+
+	messageCh <- ConsumerMessage{Message: &message{key: "a"}}
+	assert.NoError(t, <-done)
+
+	// a poll does not wait for a message
+	msg, err := q.tryReceive(messageCh, closeCh)
+	assert.NoError(t, err)
+	assert.Nil(t, msg)
+	messageCh <- ConsumerMessage{Message: &message{key: "b"}}
+	msg, err = q.tryReceive(messageCh, closeCh)
+	assert.NoError(t, err)
+	assert.Equal(t, "b", msg.Key())
+
+	close(closeCh)
+	_, err = q.tryReceive(messageCh, closeCh)
+	assert.Error(t, err)
+	_, err = q.receive(context.Background(), messageCh, closeCh)
+	assert.Error(t, err)
+}
@@ -37,9 +37,11 @@ const (
 )
 
 type regexConsumer struct {
-	client *client
-	dlq    *dlqRouter
-	rlq    *retryRouter
+	client      *client
+	dlq         *dlqRouter
+	rlq         *retryRouter
+	asyncErrors *consumerErrors
+	limiter     *rateLimiter
 
 	options ConsumerOptions
 
@@ -55,8 +57,11 @@ type regexConsumer struct {
 
 	closeOnce sync.Once
 	closeCh   chan struct{}
+	receivers receiverQueue
 
 	ticker *time.Ticker
+	// watcher has the broker push the topic changes, the topics are polled when it is not watching
+	watcher *topicListWatcher
 
 	log log.Logger
 
@@ -64,7 +69,8 @@ type regexConsumer struct {
 }
 
 func newRegexConsumer(c *client, opts ConsumerOptions, tn *internal.TopicName, pattern *regexp.Regexp,
-	msgCh chan ConsumerMessage, dlq *dlqRouter, rlq *retryRouter) (Consumer, error) {
+	msgCh chan ConsumerMessage, dlq *dlqRouter, rlq *retryRouter, asyncErrors *consumerErrors,
+	limiter *rateLimiter) (Consumer, error) {
 	rc := &regexConsumer{
 		client:    c,
 		dlq:       dlq,
@@ -72,6 +78,9 @@ func newRegexConsumer(c *client, opts ConsumerOptions, tn *internal.TopicName, p
 		options:   opts,
 		messageCh: msgCh,
 
+		asyncErrors: asyncErrors,
+		limiter:     limiter,
+
 		namespace: tn.Namespace,
 		pattern:   pattern,
 
@@ -84,14 +93,15 @@ func newRegexConsumer(c *client, opts ConsumerOptions, tn *internal.TopicName, p
 		log:          c.log.SubLogger(log.Fields{"topic": tn.Name}),
 		consumerName: opts.Name,
 	}
+	rc.watcher = newTopicListWatcher(c, tn, pattern, rc.log)
 
-	topics, err := rc.topics()
+	topics, err := rc.watchTopics()
 	if err != nil {
 		return nil, err
 	}
 
 	var errs error
-	for ce := range subscriber(c, topics, opts, msgCh, dlq, rlq) {
+	for ce := range subscriber(c, topics, opts, msgCh, dlq, rlq, asyncErrors, limiter) {
 		if ce.err != nil {
 			errs = pkgerrors.Wrapf(ce.err, "unable to subscribe to topic=%s", ce.topic)
 		} else {
@@ -103,6 +113,7 @@ func newRegexConsumer(c *client, opts ConsumerOptions, tn *internal.TopicName, p
 		for _, c := range rc.consumers {
 			c.Close()
 		}
+		rc.watcher.close()
 		return nil, errs
 	}
 
@@ -122,13 +133,61 @@ func (c *regexConsumer) Subscription() string {
 	return c.options.SubscriptionName
 }
 
+func (c *regexConsumer) KeyHashRanges() map[string][]KeyHashRange {
+	c.consumersLock.Lock()
+	defer c.consumersLock.Unlock()
+
+	ranges := make(map[string][]KeyHashRange)
+	for _, consumer := range c.consumers {
+		for t, r := range consumer.KeyHashRanges() {
+			ranges[t] = r
+		}
+	}
+	return ranges
+}
+
+func (c *regexConsumer) FlushPartitionAcks(topic string) error {
+	return flushPartitionAcks(c.copyConsumers(), topic)
+}
+
+func (c *regexConsumer) FlushAcks(ctx context.Context) error {
+	return flushAcks(ctx, c.copyConsumers())
+}
+
+// copyConsumers returns the consumers of the topics, so that the flushes don't hold the lock while waiting
+func (c *regexConsumer) copyConsumers() map[string]Consumer {
+	c.consumersLock.Lock()
+	defer c.consumersLock.Unlock()
+	consumers := make(map[string]Consumer, len(c.consumers))
+	for topic, consumer := range c.consumers {
+		consumers[topic] = consumer
+	}
+	return consumers
+}
+
+func (c *regexConsumer) Errors() <-chan error {
+	return c.asyncErrors.errors()
+}
+
 func (c *regexConsumer) Unsubscribe() error {
+	return c.unsubscribeAll(false)
+}
+
+func (c *regexConsumer) UnsubscribeForce() error {
+	return c.unsubscribeAll(true)
+}
+
+func (c *regexConsumer) unsubscribeAll(force bool) error {
 	var errs error
 	c.consumersLock.Lock()
 	defer c.consumersLock.Unlock()
 
 	for topic, consumer := range c.consumers {
-		if err := consumer.Unsubscribe(); err != nil {
+		unsubscribe := consumer.Unsubscribe
+		if force {
+			unsubscribe = consumer.UnsubscribeForce
+		}
+		if err := unsubscribe(); err != nil {
 			msg := fmt.Sprintf("unable to unsubscribe from topic=%s subscription=%s",
 				topic, c.Subscription())
 			errs = pkgerrors.Wrap(err, msg)
@@ -138,19 +197,12 @@ func (c *regexConsumer) Unsubscribe() error {
 }
 
 func (c *regexConsumer) Receive(ctx context.Context) (message Message, err error) {
-	for {
-		select {
-		case <-c.closeCh:
-			return nil, newError(ConsumerClosed, "consumer closed")
-		case cm, ok := <-c.messageCh:
-			if !ok {
-				return nil, newError(ConsumerClosed, "consumer closed")
-			}
-			return cm.Message, nil
-		case <-ctx.Done():
-			return nil, ctx.Err()
-		}
-	}
+	return c.receivers.receive(ctx, c.messageCh, c.closeCh)
+}
+
+// TryReceive returns a message if one is available right away, nil otherwise
+func (c *regexConsumer) TryReceive() (Message, error) {
+	return c.receivers.tryReceive(c.messageCh, c.closeCh)
 }
 
 // Chan return the messages chan to user
@@ -160,7 +212,50 @@ func (c *regexConsumer) Chan() <-chan ConsumerMessage {
 
 // Ack the consumption of a single message
 func (c *regexConsumer) Ack(msg Message) error {
-	return c.AckID(msg.ID())
+	return c.ackID(msg.ID(), msg)
+}
+
+// AckWithTxn the consumption of a single message as part of the transaction
+func (c *regexConsumer) AckWithTxn(msg Message, txn Transaction) error {
+	msgID := msg.ID()
+	mid := toTrackingMessageID(msgID)
+	if mid == nil {
+		c.log.Warnf("invalid message id type %T", msgID)
+		return errors.New("invalid message id type")
+	}
+
+	if mid.consumer == nil {
+		c.log.Warnf("unable to ack messageID=%+v can not determine topic", msgID)
+		return errors.New("unable to ack message because consumer is nil")
+	}
+
+	err := mid.consumer.AckIDWithTxn(msgID, txn)
+	if err == nil {
+		c.client.audit.acked(c.options.SubscriptionName, msgID, msg, false)
+	}
+	return err
+}
+
+// AckCumulativeWithTxn the reception of all the messages in the stream up to (and including)
+// the provided message as part of the transaction
+func (c *regexConsumer) AckCumulativeWithTxn(msg Message, txn Transaction) error {
+	msgID := msg.ID()
+	mid := toTrackingMessageID(msgID)
+	if mid == nil {
+		c.log.Warnf("invalid message id type %T", msgID)
+		return errors.New("invalid message id type")
+	}
+
+	if mid.consumer == nil {
+		c.log.Warnf("unable to ack messageID=%+v can not determine topic", msgID)
+		return errors.New("unable to ack message because consumer is nil")
+	}
+
+	err := mid.consumer.AckIDCumulativeWithTxn(msgID, txn)
+	if err == nil {
+		c.client.audit.acked(c.options.SubscriptionName, msgID, msg, true)
+	}
+	return err
 }
 
 func (c *regexConsumer) ReconsumeLater(msg Message, delay time.Duration) {
@@ -174,6 +269,10 @@ func (c *regexConsumer) ReconsumeLaterWithCustomProperties(msg Message, customPr
 
 // AckID the consumption of a single message, identified by its MessageID
 func (c *regexConsumer) AckID(msgID MessageID) error {
+	return c.ackID(msgID, nil)
+}
+
+func (c *regexConsumer) ackID(msgID MessageID, msg Message) error {
 	mid := toTrackingMessageID(msgID)
 	if mid == nil {
 		c.log.Warnf("invalid message id type %T", msgID)
@@ -185,22 +284,31 @@ func (c *regexConsumer) AckID(msgID MessageID) error {
 		return errors.New("consumer is nil in consumer_regex")
 	}
 
+	var err error
 	if c.options.AckWithResponse {
-		return mid.consumer.AckIDWithResponse(msgID)
+		err = mid.consumer.AckIDWithResponse(msgID)
+	} else {
+		err = mid.consumer.AckID(msgID)
 	}
-
-	return mid.consumer.AckID(msgID)
+	if err == nil {
+		c.client.audit.acked(c.options.SubscriptionName, msgID, msg, false)
+	}
+	return err
 }
 
 // AckCumulative the reception of all the messages in the stream up to (and including)
 // the provided message.
 func (c *regexConsumer) AckCumulative(msg Message) error {
-	return c.AckIDCumulative(msg.ID())
+	return c.ackIDCumulative(msg.ID(), msg)
 }
 
 // AckIDCumulative the reception of all the messages in the stream up to (and including)
 // the provided message, identified by its MessageID
 func (c *regexConsumer) AckIDCumulative(msgID MessageID) error {
+	return c.ackIDCumulative(msgID, nil)
+}
+
+func (c *regexConsumer) ackIDCumulative(msgID MessageID, msg Message) error {
 	mid := toTrackingMessageID(msgID)
 	if mid == nil {
 		c.log.Warnf("invalid message id type %T", msgID)
@@ -212,11 +320,16 @@ func (c *regexConsumer) AckIDCumulative(msgID MessageID) error {
 		return errors.New("unable to ack message because consumer is nil")
 	}
 
+	var err error
 	if c.options.AckWithResponse {
-		return mid.consumer.AckIDWithResponseCumulative(msgID)
+		err = mid.consumer.AckIDWithResponseCumulative(msgID)
+	} else {
+		err = mid.consumer.AckIDCumulative(msgID)
 	}
-
-	return mid.consumer.AckIDCumulative(msgID)
+	if err == nil {
+		c.client.audit.acked(c.options.SubscriptionName, msgID, msg, true)
+	}
+	return err
 }
 
 func (c *regexConsumer) Nack(msg Message) {
@@ -254,10 +367,21 @@ func (c *regexConsumer) NackID(msgID MessageID) {
 	mid.consumer.NackID(msgID)
 }
 
+func (c *regexConsumer) reloadNackRedeliveryDelay(delay time.Duration) {
+	c.consumersLock.Lock()
+	defer c.consumersLock.Unlock()
+	for _, consumer := range c.consumers {
+		if r, ok := consumer.(nackDelayReloadable); ok {
+			r.reloadNackRedeliveryDelay(delay)
+		}
+	}
+}
+
 func (c *regexConsumer) Close() {
 	c.closeOnce.Do(func() {
 		c.ticker.Stop()
 		close(c.closeCh)
+		c.watcher.close()
 
 		var wg sync.WaitGroup
 		c.consumersLock.Lock()
@@ -316,12 +440,20 @@ func (c *regexConsumer) monitor() {
 			if len(topics) > 0 && !c.closed() {
 				c.unsubscribe(topics)
 			}
+		case <-c.watcher.updatedCh:
+			if !c.closed() {
+				c.applyTopicListUpdates()
+			}
 		}
 	}
 }
 
 func (c *regexConsumer) discover() {
-	topics, err := c.topics()
+	if c.watcher.watching() {
+		// the broker pushes the changes
+		return
+	}
+	topics, err := c.watchTopics()
 	if err != nil {
 		c.log.WithError(err).Errorf("Failed to discover topics")
 		return
@@ -341,6 +473,29 @@ func (c *regexConsumer) discover() {
 	c.subscribeCh <- newTopics
 }
 
+// applyTopicListUpdates subscribes to the topics created and unsubscribes from the topics deleted since the
+// last notification of the watcher
+func (c *regexConsumer) applyTopicListUpdates() {
+	created, deleted := c.watcher.updates()
+	known := c.knownTopics()
+	newTopics := topicsDiff(created, known)
+	staleTopics := topicsDiff(deleted, topicsDiff(deleted, known))
+
+	c.log.
+		WithFields(log.Fields{
+			"new_topics": newTopics,
+			"old_topics": staleTopics,
+		}).
+		Debug("topic list update")
+
+	if len(staleTopics) > 0 {
+		c.unsubscribe(staleTopics)
+	}
+	if len(newTopics) > 0 {
+		c.subscribe(newTopics, c.dlq, c.rlq)
+	}
+}
+
 func (c *regexConsumer) knownTopics() []string {
 	c.consumersLock.Lock()
 	defer c.consumersLock.Unlock()
@@ -357,9 +512,10 @@ func (c *regexConsumer) knownTopics() []string {
 func (c *regexConsumer) subscribe(topics []string, dlq *dlqRouter, rlq *retryRouter) {
 	c.log.WithField("topics", topics).Debug("subscribe")
 	consumers := make(map[string]Consumer, len(topics))
-	for ce := range subscriber(c.client, topics, c.options, c.messageCh, dlq, rlq) {
+	for ce := range subscriber(c.client, topics, c.options, c.messageCh, dlq, rlq, c.asyncErrors, c.limiter) {
 		if ce.err != nil {
 			c.log.Warnf("Failed to subscribe to topic=%s", ce.topic)
+			c.asyncErrors.report(ConsumerOpSubscribe, ce.topic, nil, ce.err)
 		} else {
 			consumers[ce.topic] = ce.consumer
 		}
@@ -396,6 +552,21 @@ func (c *regexConsumer) unsubscribe(topics []string) {
 	}
 }
 
+// watchTopics returns the topics matching the pattern, registering the topic list watcher when the broker
+// supports it and polling the namespace otherwise.
+func (c *regexConsumer) watchTopics() ([]string, error) {
+	topics, err := c.watcher.watch()
+	if err == nil {
+		return topics, nil
+	}
+	if err == errTopicWatchersUnsupported {
+		c.log.Debug("Topic list watchers unsupported, polling the namespace")
+	} else {
+		c.log.WithError(err).Warn("Failed to watch the topics, polling the namespace")
+	}
+	return c.topics()
+}
+
 func (c *regexConsumer) topics() ([]string, error) {
 	topics, err := c.client.lookupService.GetTopicsOfNamespace(c.namespace, internal.Persistent)
 	if err != nil {
@@ -413,7 +584,7 @@ type consumerError struct {
 }
 
 func subscriber(c *client, topics []string, opts ConsumerOptions, ch chan ConsumerMessage,
-	dlq *dlqRouter, rlq *retryRouter) <-chan consumerError {
+	dlq *dlqRouter, rlq *retryRouter, asyncErrors *consumerErrors, limiter *rateLimiter) <-chan consumerError {
 	consumerErrorCh := make(chan consumerError, len(topics))
 	var wg sync.WaitGroup
 	wg.Add(len(topics))
@@ -425,7 +596,7 @@ func subscriber(c *client, topics []string, opts ConsumerOptions, ch chan Consum
 	for _, t := range topics {
 		go func(topic string) {
 			defer wg.Done()
-			c, err := newInternalConsumer(c, opts, topic, ch, dlq, rlq, true)
+			c, err := newInternalConsumer(c, opts, topic, ch, dlq, rlq, asyncErrors, limiter, true)
 			consumerErrorCh <- consumerError{
 				err:      err,
 				topic:    topic,
@@ -0,0 +1,182 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsar
+
+import (
+	"errors"
+	"regexp"
+	"sort"
+	"sync"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/apache/pulsar-client-go/pulsar/internal"
+	pb "github.com/apache/pulsar-client-go/pulsar/internal/pulsar_proto"
+	"github.com/apache/pulsar-client-go/pulsar/log"
+)
+
+// errTopicWatchersUnsupported is returned when the broker does not support the topic list watchers, the
+// regex consumer then discovers its topics by polling the namespace
+var errTopicWatchersUnsupported = errors.New("the broker does not support topic list watchers")
+
+// topicListWatcher has the broker notify the regex consumer of the topics created and deleted in the namespace,
+// instead of the consumer periodically listing all the topics of the namespace.
+type topicListWatcher struct {
+	client    *client
+	id        uint64
+	namespace string
+	// topicsPattern is the full name pattern, it is looked up to find the broker to watch on
+	topicsPattern string
+	pattern       *regexp.Regexp
+	log           log.Logger
+
+	sync.Mutex
+	cnx internal.Connection
+	// pending holds the topics changed since the last updates call, true when created and false when deleted
+	pending map[string]bool
+
+	updatedCh chan struct{}
+}
+
+func newTopicListWatcher(c *client, tn *internal.TopicName, pattern *regexp.Regexp,
+	logger log.Logger) *topicListWatcher {
+	return &topicListWatcher{
+		client:        c,
+		id:            c.rpcClient.NewConsumerID(),
+		namespace:     tn.Namespace,
+		topicsPattern: tn.Name,
+		pattern:       pattern,
+		log:           logger,
+		pending:       make(map[string]bool),
+		updatedCh:     make(chan struct{}, 1),
+	}
+}
+
+// watch registers the watcher on a broker and returns the topics currently matching the pattern. It fails with
+// errTopicWatchersUnsupported when the broker is too old to support it.
+func (w *topicListWatcher) watch() ([]string, error) {
+	lr, err := w.client.lookupService.Lookup(w.topicsPattern)
+	if err != nil {
+		return nil, err
+	}
+	cnx, err := w.client.cnxPool.GetConnection(lr.LogicalAddr, lr.PhysicalAddr)
+	if err != nil {
+		return nil, err
+	}
+	if !cnx.GetServerInfo().SupportsTopicWatchers {
+		return nil, errTopicWatchersUnsupported
+	}
+
+	// register before the request, the broker pushes the updates right after the success response
+	if err := cnx.AddTopicListWatcher(w.id, w); err != nil {
+		return nil, err
+	}
+	requestID := w.client.rpcClient.NewRequestID()
+	cmd := &pb.CommandWatchTopicList{
+		RequestId:     proto.Uint64(requestID),
+		WatcherId:     proto.Uint64(w.id),
+		Namespace:     proto.String(w.namespace),
+		TopicsPattern: proto.String(w.topicsPattern),
+	}
+	res, err := w.client.rpcClient.RequestOnCnx(cnx, requestID, pb.BaseCommand_WATCH_TOPIC_LIST, cmd)
+	if err != nil {
+		cnx.DeleteTopicListWatcher(w.id)
+		return nil, err
+	}
+
+	w.Lock()
+	w.cnx = cnx
+	w.Unlock()
+	w.log.WithField("cnx", cnx.ID()).Info("Watching the topics of the namespace")
+	return filterTopics(res.Response.GetWatchTopicListSuccess().GetTopic(), w.pattern), nil
+}
+
+// watching reports whether the watcher is registered on a connection
+func (w *topicListWatcher) watching() bool {
+	w.Lock()
+	defer w.Unlock()
+	return w.cnx != nil
+}
+
+// TopicListUpdated records the topics changes pushed by the broker and notifies the regex consumer
+func (w *topicListWatcher) TopicListUpdated(update *pb.CommandWatchTopicUpdate) {
+	created := filterTopics(update.GetNewTopics(), w.pattern)
+	deleted := filterTopics(update.GetDeletedTopics(), w.pattern)
+	if len(created) == 0 && len(deleted) == 0 {
+		return
+	}
+
+	w.Lock()
+	for _, t := range deleted {
+		w.pending[t] = false
+	}
+	for _, t := range created {
+		w.pending[t] = true
+	}
+	w.Unlock()
+
+	select {
+	case w.updatedCh <- struct{}{}:
+	default:
+	}
+}
+
+// ConnectionClosed drops the registration, the regex consumer watches again on its next discovery
+func (w *topicListWatcher) ConnectionClosed() {
+	w.Lock()
+	defer w.Unlock()
+	w.cnx = nil
+}
+
+// updates returns and resets the topics created and deleted since the last call
+func (w *topicListWatcher) updates() (created []string, deleted []string) {
+	w.Lock()
+	defer w.Unlock()
+	for t, isCreated := range w.pending {
+		if isCreated {
+			created = append(created, t)
+		} else {
+			deleted = append(deleted, t)
+		}
+		delete(w.pending, t)
+	}
+	sort.Strings(created)
+	sort.Strings(deleted)
+	return created, deleted
+}
+
+func (w *topicListWatcher) close() {
+	w.Lock()
+	cnx := w.cnx
+	w.cnx = nil
+	w.Unlock()
+	if cnx == nil {
+		return
+	}
+
+	cnx.DeleteTopicListWatcher(w.id)
+	requestID := w.client.rpcClient.NewRequestID()
+	cmd := &pb.CommandWatchTopicListClose{
+		RequestId: proto.Uint64(requestID),
+		WatcherId: proto.Uint64(w.id),
+	}
+	if _, err := w.client.rpcClient.RequestOnCnx(cnx, requestID, pb.BaseCommand_WATCH_TOPIC_LIST_CLOSE,
+		cmd); err != nil {
+		w.log.WithError(err).Warn("Failed to close the topic list watcher")
+	}
+}
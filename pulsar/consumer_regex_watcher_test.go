@@ -0,0 +1,63 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsar
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	pb "github.com/apache/pulsar-client-go/pulsar/internal/pulsar_proto"
+)
+
+func TestTopicListWatcherUpdates(t *testing.T) {
+	w := &topicListWatcher{
+		pattern:   regexp.MustCompile("public/default/foo.*"),
+		pending:   make(map[string]bool),
+		updatedCh: make(chan struct{}, 1),
+	}
+	assert.False(t, w.watching())
+
+	w.TopicListUpdated(&pb.CommandWatchTopicUpdate{
+		NewTopics: []string{"persistent://public/default/bar"},
+	})
+	assert.Len(t, w.updatedCh, 0, "topics not matching the pattern are ignored")
+
+	w.TopicListUpdated(&pb.CommandWatchTopicUpdate{
+		NewTopics: []string{
+			"persistent://public/default/foo-1",
+			"persistent://public/default/foo-2-partition-0",
+			"persistent://public/default/foo-2-partition-1",
+		},
+		DeletedTopics: []string{"persistent://public/default/foo-3"},
+	})
+	w.TopicListUpdated(&pb.CommandWatchTopicUpdate{
+		NewTopics:     []string{"persistent://public/default/foo-3"},
+		DeletedTopics: []string{"persistent://public/default/foo-1"},
+	})
+	assert.Len(t, w.updatedCh, 1)
+
+	created, deleted := w.updates()
+	assert.Equal(t, []string{"persistent://public/default/foo-2", "persistent://public/default/foo-3"}, created)
+	assert.Equal(t, []string{"persistent://public/default/foo-1"}, deleted)
+
+	created, deleted = w.updates()
+	assert.Empty(t, created)
+	assert.Empty(t, deleted)
+}
@@ -0,0 +1,115 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsar
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// SeekProgress is the progress of a seek of a partitioned topic, see ConsumerOptions.SeekProgressListener
+type SeekProgress struct {
+	// Partition is the partition topic the seek completed on
+	Partition string
+
+	// Err is the error of the seek of the partition, nil when the broker confirmed the reset of the cursor
+	Err error
+
+	// Done is the number of partitions the seek completed on, successfully or not, out of Total
+	Done  int
+	Total int
+}
+
+// SeekProgressListener is notified each time the seek of a partition of a topic completes
+type SeekProgressListener func(progress SeekProgress)
+
+// SeekError is returned by a seek of a partitioned topic failing on some of the partitions, the cursors of the
+// Succeeded partitions were reset. Its Result is SeekFailed.
+type SeekError struct {
+	// Failed are the errors of the seek by partition topic
+	Failed map[string]error
+
+	// Succeeded are the partition topics the broker confirmed the reset of the cursor of
+	Succeeded []string
+}
+
+func (e *SeekError) Error() string {
+	partitions := make([]string, 0, len(e.Failed))
+	for partition := range e.Failed {
+		partitions = append(partitions, partition)
+	}
+	sort.Strings(partitions)
+	failures := make([]string, 0, len(partitions))
+	for _, partition := range partitions {
+		failures = append(failures, fmt.Sprintf("%s: %v", partition, e.Failed[partition]))
+	}
+	return fmt.Sprintf("seek failed on %d of %d partitions: %s", len(e.Failed), len(e.Failed)+len(e.Succeeded),
+		strings.Join(failures, "; "))
+}
+
+// Unwrap returns an *Error with the SeekFailed result
+func (e *SeekError) Unwrap() error {
+	return newError(SeekFailed, e.Error())
+}
+
+type partitionSeekResult struct {
+	partition string
+	err       error
+}
+
+// seekPartitions runs seek on all the partitions concurrently and returns once the seek of each partition completed,
+// reporting the progress to the SeekProgressListener
+func (c *consumer) seekPartitions(seek func(pc *partitionConsumer) error) error {
+	resultCh := make(chan partitionSeekResult, len(c.consumers))
+	for _, pc := range c.consumers {
+		go func(pc *partitionConsumer) {
+			resultCh <- partitionSeekResult{partition: pc.topic, err: seek(pc)}
+		}(pc)
+	}
+
+	var seekErr *SeekError
+	succeeded := make([]string, 0, len(c.consumers))
+	for done := 1; done <= len(c.consumers); done++ {
+		res := <-resultCh
+		if res.err != nil {
+			c.log.WithError(res.err).WithField("partition", res.partition).Error("Failed to seek partition")
+			if seekErr == nil {
+				seekErr = &SeekError{Failed: make(map[string]error)}
+			}
+			seekErr.Failed[res.partition] = res.err
+		} else {
+			succeeded = append(succeeded, res.partition)
+		}
+		if c.options.SeekProgressListener != nil {
+			c.options.SeekProgressListener(SeekProgress{
+				Partition: res.partition,
+				Err:       res.err,
+				Done:      done,
+				Total:     len(c.consumers),
+			})
+		}
+	}
+
+	if seekErr != nil {
+		sort.Strings(succeeded)
+		seekErr.Succeeded = succeeded
+		return seekErr
+	}
+	return nil
+}
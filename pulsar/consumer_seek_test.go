@@ -0,0 +1,76 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsar
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	plog "github.com/apache/pulsar-client-go/pulsar/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConsumerSeekPartitions(t *testing.T) {
+	var mu sync.Mutex
+	var progress []SeekProgress
+	c := &consumer{
+		consumers: []*partitionConsumer{
+			{topic: "topic-partition-0"},
+			{topic: "topic-partition-1"},
+			{topic: "topic-partition-2"},
+		},
+		options: ConsumerOptions{
+			SeekProgressListener: func(p SeekProgress) {
+				mu.Lock()
+				defer mu.Unlock()
+				progress = append(progress, p)
+			},
+		},
+		log: plog.DefaultNopLogger(),
+	}
+
+	err := c.seekPartitions(func(pc *partitionConsumer) error {
+		if pc.topic == "topic-partition-1" {
+			return errors.New("connection closed")
+		}
+		return nil
+	})
+
+	var seekErr *SeekError
+	assert.True(t, errors.As(err, &seekErr))
+	assert.Equal(t, []string{"topic-partition-0", "topic-partition-2"}, seekErr.Succeeded)
+	assert.Len(t, seekErr.Failed, 1)
+	assert.EqualError(t, seekErr.Failed["topic-partition-1"], "connection closed")
+	assert.Equal(t, "seek failed on 1 of 3 partitions: topic-partition-1: connection closed", err.Error())
+
+	var pulsarErr *Error
+	assert.True(t, errors.As(err, &pulsarErr))
+	assert.Equal(t, SeekFailed, pulsarErr.Result())
+
+	assert.Len(t, progress, 3)
+	for i, p := range progress {
+		assert.Equal(t, i+1, p.Done)
+		assert.Equal(t, 3, p.Total)
+		assert.Equal(t, p.Partition == "topic-partition-1", p.Err != nil)
+	}
+
+	assert.NoError(t, c.seekPartitions(func(pc *partitionConsumer) error {
+		return nil
+	}))
+}
@@ -1282,6 +1282,115 @@ func TestConsumerSeek(t *testing.T) {
 	assert.Equal(t, fmt.Sprintf("hello-%d", N-50), string(msg.Payload()))
 }
 
+func TestConsumerStartMessageIDInclusive(t *testing.T) {
+	client, err := NewClient(ClientOptions{
+		URL: lookupURL,
+	})
+	assert.Nil(t, err)
+	defer client.Close()
+
+	topicName := newTopicName()
+	ctx := context.Background()
+
+	producer, err := client.CreateProducer(ProducerOptions{
+		Topic:           topicName,
+		DisableBatching: true,
+	})
+	assert.Nil(t, err)
+	defer producer.Close()
+
+	var ids []MessageID
+	for i := 0; i < 10; i++ {
+		id, err := producer.Send(ctx, &ProducerMessage{
+			Payload: []byte(fmt.Sprintf("hello-%d", i)),
+		})
+		assert.Nil(t, err)
+		ids = append(ids, id)
+	}
+
+	for _, inclusive := range []bool{true, false} {
+		consumer, err := client.Subscribe(ConsumerOptions{
+			Topic:                   topicName,
+			SubscriptionName:        fmt.Sprintf("sub-inclusive-%t", inclusive),
+			StartMessageID:          ids[5],
+			StartMessageIDInclusive: inclusive,
+		})
+		assert.Nil(t, err)
+
+		expected := 6
+		if inclusive {
+			expected = 5
+		}
+		msg, err := consumer.Receive(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, fmt.Sprintf("hello-%d", expected), string(msg.Payload()))
+		consumer.Close()
+	}
+}
+
+func TestConsumerNonDurableSubscription(t *testing.T) {
+	client, err := NewClient(ClientOptions{
+		URL: lookupURL,
+	})
+	assert.Nil(t, err)
+	defer client.Close()
+
+	topicName := newTopicName()
+	ctx := context.Background()
+
+	producer, err := client.CreateProducer(ProducerOptions{
+		Topic:           topicName,
+		DisableBatching: true,
+	})
+	assert.Nil(t, err)
+	defer producer.Close()
+
+	for i := 0; i < 10; i++ {
+		_, err := producer.Send(ctx, &ProducerMessage{
+			Payload: []byte(fmt.Sprintf("hello-%d", i)),
+		})
+		assert.Nil(t, err)
+	}
+
+	// a non-durable subscription starts from its initial position again once resubscribed
+	for i := 0; i < 2; i++ {
+		consumer, err := client.Subscribe(ConsumerOptions{
+			Topic:                       topicName,
+			SubscriptionName:            "sub-non-durable",
+			Type:                        Shared,
+			SubscriptionMode:            NonDurable,
+			SubscriptionInitialPosition: SubscriptionPositionEarliest,
+		})
+		assert.Nil(t, err)
+
+		for j := 0; j < 10; j++ {
+			msg, err := consumer.Receive(ctx)
+			assert.Nil(t, err)
+			assert.Equal(t, fmt.Sprintf("hello-%d", j), string(msg.Payload()))
+			consumer.Ack(msg)
+		}
+		consumer.Close()
+	}
+}
+
+func TestConsumerNonDurableStartID(t *testing.T) {
+	c := &consumer{options: ConsumerOptions{}}
+	assert.Nil(t, c.nonDurableStartID(0))
+
+	c.options.SubscriptionMode = NonDurable
+	assert.True(t, c.nonDurableStartID(0).equal(latestMessageID))
+	c.options.SubscriptionInitialPosition = SubscriptionPositionEarliest
+	assert.True(t, c.nonDurableStartID(0).equal(earliestMessageID))
+
+	start := &trackingMessageID{messageID: &messageID{ledgerID: 1, entryID: 2, batchIdx: -1, partitionIdx: 1}}
+	c.startMessageID = start
+	assert.Equal(t, start, c.nonDurableStartID(1))
+	assert.True(t, c.nonDurableStartID(0).equal(earliestMessageID))
+
+	c.startMessageID = &trackingMessageID{messageID: latestMessageID}
+	assert.True(t, c.nonDurableStartID(0).equal(latestMessageID))
+}
+
 func TestConsumerSeekByTime(t *testing.T) {
 	client, err := NewClient(ClientOptions{
 		URL: lookupURL,
@@ -3550,6 +3659,15 @@ func TestConsumerEncryptionWithoutKeyReader(t *testing.T) {
 		d := buffer.Read(uint32(meta.GetPayloadSize()))
 		assert.Equal(t, message, string(d))
 	}
+
+	// decrypt using the helper
+	decrypted, err := DecryptMessage(msg, MessageDecryptionInfo{
+		KeyReader: NewEncKeyReader("crypto/testdata/pub_key_rsa.pem",
+			"crypto/testdata/pri_key_rsa.pem"),
+	})
+	assert.Nil(t, err)
+	assert.Len(t, decrypted, 1)
+	assert.Equal(t, message, string(decrypted[0].Payload))
 }
 
 // TestEncryptDecryptRedeliveryOnFailure test redelivery failed messages
@@ -3873,7 +3991,7 @@ func TestBatchIndexAck(t *testing.T) {
 	for _, params := range configs {
 		option := params.ackGroupingOptions
 		if option == nil {
-			option = &AckGroupingOptions{1000, 10 * time.Millisecond}
+			option = &AckGroupingOptions{MaxSize: 1000, MaxTime: 10 * time.Millisecond}
 		}
 
 		t.Run(fmt.Sprintf("TestBatchIndexAck_WithResponse_%v_Cumulative_%v_AckGroupingOption_%v_%v",
@@ -3988,3 +4106,65 @@ func runBatchIndexAckTest(t *testing.T, ackWithResponse bool, cumulative bool, o
 
 	client.Close()
 }
+
+func TestSubscribeToDLQ(t *testing.T) {
+	client, err := NewClient(ClientOptions{
+		URL: lookupURL,
+	})
+	assert.Nil(t, err)
+	defer client.Close()
+
+	topic := newTopicName()
+
+	dlqConsumer, err := client.SubscribeToDLQ(topic, "my-sub", ConsumerOptions{})
+	assert.Nil(t, err)
+	defer dlqConsumer.Close()
+	assert.Equal(t, "persistent://public/default/"+topic+"-my-sub"+DlqTopicSuffix,
+		dlqConsumer.(*consumer).topic)
+	assert.Equal(t, "my-sub", dlqConsumer.Subscription())
+
+	customDlqTopic := newTopicName()
+	customConsumer, err := client.SubscribeToDLQ(topic, "my-sub", ConsumerOptions{
+		SubscriptionName: "reprocessing",
+		DLQ: &DLQPolicy{
+			MaxDeliveries:   3,
+			DeadLetterTopic: customDlqTopic,
+		},
+	})
+	assert.Nil(t, err)
+	defer customConsumer.Close()
+	assert.Equal(t, customDlqTopic, customConsumer.(*consumer).topic)
+	assert.Equal(t, "reprocessing", customConsumer.Subscription())
+
+	_, err = client.SubscribeToDLQ(topic, "my-sub", ConsumerOptions{Topic: topic})
+	assert.NotNil(t, err)
+}
+
+func TestConsumerUnsubscribeForce(t *testing.T) {
+	client, err := NewClient(ClientOptions{
+		URL: lookupURL,
+	})
+	assert.Nil(t, err)
+	defer client.Close()
+
+	topic := newTopicName()
+	consumers := make([]Consumer, 2)
+	for i := range consumers {
+		consumers[i], err = client.Subscribe(ConsumerOptions{
+			Topic:            topic,
+			SubscriptionName: "my-sub",
+			Type:             Shared,
+		})
+		assert.Nil(t, err)
+		defer consumers[i].Close()
+	}
+
+	// the subscription has an other connected consumer
+	err = consumers[0].Unsubscribe()
+	assert.Error(t, err)
+	var pulsarErr *Error
+	assert.True(t, errors.As(err, &pulsarErr))
+	assert.Equal(t, ConsumerBusy, pulsarErr.Result())
+
+	assert.Nil(t, consumers[0].UnsubscribeForce())
+}
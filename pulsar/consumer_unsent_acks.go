@@ -0,0 +1,193 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsar
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/apache/pulsar-client-go/pulsar/internal"
+	"github.com/bits-and-blooms/bitset"
+)
+
+// maxUnsentAcks bounds the individual acknowledgments kept to be sent again, the messages of the ones dropped
+// beyond are redelivered
+const maxUnsentAcks = 100000
+
+// unsentAcks tracks the acknowledgments which failed to be sent because the connection to the broker was closed,
+// so that they are sent again once the consumer has reconnected. Meanwhile, and until the next reconnection after
+// they are sent again, the messages they acknowledge are duplicates if the broker redelivers them.
+type unsentAcks struct {
+	sync.Mutex
+	maxIDs int
+
+	// ids and cumulativeID are the acknowledgments to send again, an id acknowledged by the others is not added
+	ids          []trackingMessageID
+	cumulativeID *trackingMessageID
+
+	// entries are the entries acknowledged by ids, by messageIDHash, with the bit set of the messages acknowledged
+	// if the entry is a batch which isn't entirely acknowledged, nil otherwise
+	entries map[int64]*bitset.BitSet
+	// resentEntries and resentCumulativeID are the acknowledgments sent again at the last reconnection
+	resentEntries      map[int64]*bitset.BitSet
+	resentCumulativeID *trackingMessageID
+}
+
+func newUnsentAcks() *unsentAcks {
+	return &unsentAcks{
+		maxIDs:  maxUnsentAcks,
+		entries: make(map[int64]*bitset.BitSet),
+	}
+}
+
+// add records the individual acknowledgments which failed to be sent, it returns the number of acknowledgments
+// dropped because too many are recorded
+func (u *unsentAcks) add(ids ...trackingMessageID) int {
+	if u == nil {
+		return 0
+	}
+	u.Lock()
+	defer u.Unlock()
+	dropped := 0
+	for _, id := range ids {
+		id := id
+		if isAckedBy(&id, u.entries, u.cumulativeID) {
+			continue
+		}
+		if len(u.ids) >= u.maxIDs {
+			dropped++
+			continue
+		}
+		u.ids = append(u.ids, id)
+		key := messageIDHash(&id)
+		if id.tracker == nil || !messageIDIsBatch(&id) || id.tracker.completed() {
+			u.entries[key] = nil
+			continue
+		}
+		ackSet, found := u.entries[key]
+		if found && ackSet == nil {
+			continue
+		}
+		if !found {
+			ackSet = bitset.New(uint(id.BatchSize()))
+			u.entries[key] = ackSet
+		}
+		ackSet.Set(uint(id.BatchIdx()))
+	}
+	return dropped
+}
+
+// addCumulative records a cumulative acknowledgment which failed to be sent
+func (u *unsentAcks) addCumulative(id trackingMessageID) {
+	if u == nil {
+		return
+	}
+	u.Lock()
+	defer u.Unlock()
+	if u.cumulativeID == nil || messageIDCompare(u.cumulativeID, &id) < 0 {
+		u.cumulativeID = &id
+	}
+}
+
+// isDuplicate returns true if the message is acknowledged by an acknowledgment which failed to be sent, or which
+// was sent again at the last reconnection
+func (u *unsentAcks) isDuplicate(id MessageID) bool {
+	if u == nil {
+		return false
+	}
+	u.Lock()
+	defer u.Unlock()
+	return isAckedBy(id, u.entries, u.cumulativeID) || isAckedBy(id, u.resentEntries, u.resentCumulativeID)
+}
+
+func isAckedBy(id MessageID, entries map[int64]*bitset.BitSet, cumulativeID *trackingMessageID) bool {
+	if cumulativeID != nil && messageIDCompare(cumulativeID, id) >= 0 {
+		return true
+	}
+	ackSet, found := entries[messageIDHash(id)]
+	if !found {
+		return false
+	}
+	return ackSet == nil || !messageIDIsBatch(id) || ackSet.Test(uint(id.BatchIdx()))
+}
+
+// reconnected returns the acknowledgments to send again on the new connection, they are the duplicates until the
+// next reconnection
+func (u *unsentAcks) reconnected() ([]trackingMessageID, *trackingMessageID) {
+	if u == nil {
+		return nil, nil
+	}
+	u.Lock()
+	defer u.Unlock()
+	ids, cumulativeID := u.ids, u.cumulativeID
+	u.resentEntries, u.resentCumulativeID = u.entries, u.cumulativeID
+	u.ids, u.cumulativeID = nil, nil
+	u.entries = make(map[int64]*bitset.BitSet)
+	return ids, cumulativeID
+}
+
+// clean forgets the acknowledgments, e.g. when the consumer seeks to redeliver the messages
+func (u *unsentAcks) clean() {
+	if u == nil {
+		return
+	}
+	u.Lock()
+	defer u.Unlock()
+	u.ids, u.cumulativeID = nil, nil
+	u.entries = make(map[int64]*bitset.BitSet)
+	u.resentEntries, u.resentCumulativeID = nil, nil
+}
+
+// isAckLost returns true if the acknowledgment may not have reached the broker because of the connection
+func isAckLost(err error) bool {
+	return internal.IsConnectionClosed(err) || errors.Is(err, internal.ErrRequestTimeOut)
+}
+
+// addUnsentAck records an acknowledgment which failed to be sent, or whose response was lost, to send it again
+// once the consumer has reconnected
+func (pc *partitionConsumer) addUnsentAck(ackType int, ids ...trackingMessageID) {
+	if ackType == cumulativeAck {
+		pc.unsentAcks.addCumulative(ids[0])
+		return
+	}
+	if dropped := pc.unsentAcks.add(ids...); dropped > 0 {
+		pc.log.WithField("dropped", dropped).Warn("Too many acknowledgments failed to be sent, " +
+			"their messages will be redelivered")
+	}
+}
+
+// resendUnsentAcks sends again the acknowledgments which failed to be sent before the consumer reconnected
+func (pc *partitionConsumer) resendUnsentAcks() {
+	ids, cumulativeID := pc.unsentAcks.reconnected()
+	if len(ids) == 0 && cumulativeID == nil {
+		return
+	}
+	pc.log.WithField("acks", len(ids)).Info("Sending again the acknowledgments which failed to be sent")
+	if len(ids) > 0 {
+		pc.eventsCh <- &ackListRequest{msgIDs: ids}
+		pc.metrics.AcksResent.Add(float64(len(ids)))
+	}
+	if cumulativeID != nil {
+		pc.eventsCh <- &ackRequest{
+			doneCh:  make(chan struct{}),
+			ackType: cumulativeAck,
+			msgID:   *cumulativeID,
+		}
+		pc.metrics.AcksResent.Inc()
+	}
+}
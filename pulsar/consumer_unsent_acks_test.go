@@ -0,0 +1,196 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsar
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/apache/pulsar-client-go/pulsar/internal"
+	pb "github.com/apache/pulsar-client-go/pulsar/internal/pulsar_proto"
+	plog "github.com/apache/pulsar-client-go/pulsar/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestUnsentAcks(t *testing.T) {
+	u := newUnsentAcks()
+	tracker := newAckTracker(3)
+	tracker.ack(0)
+	u.add(*newTrackingMessageID(1, 1, -1, 0, 0, nil), *newTrackingMessageID(1, 2, 0, 0, 3, tracker))
+
+	assert.True(t, u.isDuplicate(newTrackingMessageID(1, 1, -1, 0, 0, nil)))
+	// only the messages acknowledged of a batch which isn't entirely acknowledged are duplicates
+	assert.True(t, u.isDuplicate(newTrackingMessageID(1, 2, 0, 0, 3, nil)))
+	assert.False(t, u.isDuplicate(newTrackingMessageID(1, 2, 1, 0, 3, nil)))
+	assert.False(t, u.isDuplicate(newTrackingMessageID(1, 3, -1, 0, 0, nil)))
+
+	u.addCumulative(*newTrackingMessageID(1, 5, -1, 0, 0, nil))
+	u.addCumulative(*newTrackingMessageID(1, 4, -1, 0, 0, nil))
+	assert.True(t, u.isDuplicate(newTrackingMessageID(1, 5, -1, 0, 0, nil)))
+	assert.False(t, u.isDuplicate(newTrackingMessageID(1, 6, -1, 0, 0, nil)))
+
+	// the acknowledgments sent again stay duplicates until the next reconnection
+	ids, cumulativeID := u.reconnected()
+	assert.Len(t, ids, 2)
+	assert.Equal(t, int64(5), cumulativeID.entryID)
+	assert.True(t, u.isDuplicate(newTrackingMessageID(1, 1, -1, 0, 0, nil)))
+	assert.True(t, u.isDuplicate(newTrackingMessageID(1, 5, -1, 0, 0, nil)))
+
+	ids, cumulativeID = u.reconnected()
+	assert.Empty(t, ids)
+	assert.Nil(t, cumulativeID)
+	assert.False(t, u.isDuplicate(newTrackingMessageID(1, 1, -1, 0, 0, nil)))
+
+	u.add(*newTrackingMessageID(1, 7, -1, 0, 0, nil))
+	u.clean()
+	assert.False(t, u.isDuplicate(newTrackingMessageID(1, 7, -1, 0, 0, nil)))
+	ids, _ = u.reconnected()
+	assert.Empty(t, ids)
+
+	var nilAcks *unsentAcks
+	nilAcks.add(*newTrackingMessageID(1, 1, -1, 0, 0, nil))
+	assert.False(t, nilAcks.isDuplicate(newTrackingMessageID(1, 1, -1, 0, 0, nil)))
+}
+
+func TestUnsentAcksDedupAndCap(t *testing.T) {
+	u := newUnsentAcks()
+	u.maxIDs = 2
+	u.addCumulative(*newTrackingMessageID(1, 1, -1, 0, 0, nil))
+
+	// the acknowledgments already recorded are not added again
+	assert.Equal(t, 0, u.add(*newTrackingMessageID(1, 1, -1, 0, 0, nil), *newTrackingMessageID(1, 2, -1, 0, 0, nil),
+		*newTrackingMessageID(1, 2, -1, 0, 0, nil)))
+	assert.Equal(t, 1, u.add(*newTrackingMessageID(1, 3, -1, 0, 0, nil), *newTrackingMessageID(1, 4, -1, 0, 0, nil)))
+	assert.True(t, u.isDuplicate(newTrackingMessageID(1, 3, -1, 0, 0, nil)))
+	assert.False(t, u.isDuplicate(newTrackingMessageID(1, 4, -1, 0, 0, nil)))
+
+	ids, _ := u.reconnected()
+	require.Len(t, ids, 2)
+	assert.Equal(t, int64(2), ids[0].entryID)
+	assert.Equal(t, int64(3), ids[1].entryID)
+}
+
+// closedConnectionRPCClient fails to send the requests without response as the connection is closed
+type closedConnectionRPCClient struct {
+	internal.RPCClient
+}
+
+func (c *closedConnectionRPCClient) NewRequestID() uint64 {
+	return 1
+}
+
+func (c *closedConnectionRPCClient) RequestOnCnxNoWait(internal.Connection, pb.BaseCommand_Type,
+	proto.Message) error {
+	return internal.ErrConnectionClosed
+}
+
+type closedConnection struct {
+	internal.Connection
+}
+
+func TestPartitionConsumerResendUnsentAcks(t *testing.T) {
+	pc := &partitionConsumer{
+		client:     &client{rpcClient: &closedConnectionRPCClient{}},
+		eventsCh:   make(chan interface{}, 10),
+		options:    &partitionConsumerOpts{},
+		metrics:    newTestMetrics(),
+		log:        plog.DefaultNopLogger(),
+		unsentAcks: newUnsentAcks(),
+	}
+	pc.conn.Store(closedConnection{})
+
+	pc.internalAck(&ackRequest{
+		doneCh:  make(chan struct{}),
+		ackType: individualAck,
+		msgID:   *newTrackingMessageID(1, 1, -1, 0, 0, nil),
+	})
+	pc.internalAck(&ackRequest{
+		doneCh:  make(chan struct{}),
+		ackType: cumulativeAck,
+		msgID:   *newTrackingMessageID(1, 0, -1, 0, 0, nil),
+	})
+	pc.internalAckList(&ackListRequest{msgIDs: []trackingMessageID{*newTrackingMessageID(1, 3, -1, 0, 0, nil)}})
+
+	// the broker redelivering the messages before the reconnection
+	assert.True(t, pc.unsentAcks.isDuplicate(newTrackingMessageID(1, 0, -1, 0, 0, nil)))
+	assert.True(t, pc.unsentAcks.isDuplicate(newTrackingMessageID(1, 1, -1, 0, 0, nil)))
+	assert.False(t, pc.unsentAcks.isDuplicate(newTrackingMessageID(1, 2, -1, 0, 0, nil)))
+	assert.True(t, pc.unsentAcks.isDuplicate(newTrackingMessageID(1, 3, -1, 0, 0, nil)))
+
+	pc.resendUnsentAcks()
+	list := (<-pc.eventsCh).(*ackListRequest)
+	require.Len(t, list.msgIDs, 2)
+	assert.Equal(t, int64(1), list.msgIDs[0].entryID)
+	assert.Equal(t, int64(3), list.msgIDs[1].entryID)
+	cumulative := (<-pc.eventsCh).(*ackRequest)
+	assert.Equal(t, cumulativeAck, cumulative.ackType)
+	assert.Equal(t, int64(0), cumulative.msgID.entryID)
+
+	// nothing is sent again at the next reconnection
+	pc.resendUnsentAcks()
+	assert.Empty(t, pc.eventsCh)
+}
+
+func TestPartitionConsumerUnsentAcksLostResponse(t *testing.T) {
+	rpcClient := &asyncAckRPCClient{callbacks: make(chan func(*internal.RPCResult, error), 1)}
+	pc := &partitionConsumer{
+		client:     &client{rpcClient: rpcClient},
+		eventsCh:   make(chan interface{}, 10),
+		options:    &partitionConsumerOpts{ackWithResponse: true},
+		metrics:    newTestMetrics(),
+		log:        plog.DefaultNopLogger(),
+		unsentAcks: newUnsentAcks(),
+	}
+	pc.conn.Store(closedConnection{})
+
+	req := &ackRequest{
+		doneCh:  make(chan struct{}),
+		ackType: individualAck,
+		msgID:   *newTrackingMessageID(1, 1, -1, 0, 0, nil),
+	}
+	pc.internalAck(req)
+	(<-rpcClient.callbacks)(nil, internal.ErrConnectionClosed)
+	<-req.doneCh
+	assert.ErrorIs(t, req.err, internal.ErrConnectionClosed)
+
+	list := &ackListRequest{
+		doneCh: make(chan struct{}),
+		msgIDs: []trackingMessageID{*newTrackingMessageID(1, 2, -1, 0, 0, nil)},
+	}
+	pc.internalAckList(list)
+	(<-rpcClient.callbacks)(nil, internal.ErrRequestTimeOut)
+	<-list.doneCh
+	assert.ErrorIs(t, list.err, internal.ErrRequestTimeOut)
+
+	// an acknowledgment rejected by the broker is not sent again
+	rejected := &ackRequest{
+		doneCh:  make(chan struct{}),
+		ackType: individualAck,
+		msgID:   *newTrackingMessageID(1, 3, -1, 0, 0, nil),
+	}
+	pc.internalAck(rejected)
+	(<-rpcClient.callbacks)(nil, errors.New("rejected"))
+	<-rejected.doneCh
+
+	ids, _ := pc.unsentAcks.reconnected()
+	require.Len(t, ids, 2)
+	assert.Equal(t, int64(1), ids[0].entryID)
+	assert.Equal(t, int64(2), ids[1].entryID)
+}
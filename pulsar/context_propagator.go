@@ -0,0 +1,94 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsar
+
+import (
+	"context"
+	"fmt"
+)
+
+// ContextPropagator carries values of a context.Context, such as a tenant or request id, in the properties of
+// the messages. The propagators registered with ClientOptions.ContextPropagators inject the values of the context
+// given to Producer.Send and Producer.SendAsync into the properties of the message, and extract them from the
+// properties of the received messages, see MessageContext.
+type ContextPropagator interface {
+	// Inject sets the properties carrying the values of the context
+	Inject(ctx context.Context, properties map[string]string)
+
+	// Extract returns a context derived from ctx with the values carried by the properties
+	Extract(ctx context.Context, properties map[string]string) context.Context
+}
+
+// NewContextValuePropagator returns a propagator carrying the value stored in the context with the given key
+// in the given message property. The value must be a string or a fmt.Stringer, it is extracted as a string.
+func NewContextValuePropagator(key interface{}, property string) ContextPropagator {
+	return &contextValuePropagator{
+		key:      key,
+		property: property,
+	}
+}
+
+type contextValuePropagator struct {
+	key      interface{}
+	property string
+}
+
+func (p *contextValuePropagator) Inject(ctx context.Context, properties map[string]string) {
+	switch v := ctx.Value(p.key).(type) {
+	case string:
+		properties[p.property] = v
+	case fmt.Stringer:
+		properties[p.property] = v.String()
+	}
+}
+
+func (p *contextValuePropagator) Extract(ctx context.Context, properties map[string]string) context.Context {
+	if v, ok := properties[p.property]; ok {
+		return context.WithValue(ctx, p.key, v)
+	}
+	return ctx
+}
+
+// MessageContext returns a context derived from ctx with the values extracted from the properties of a message
+// received by a consumer or a reader, by the ContextPropagators of the client.
+func MessageContext(ctx context.Context, msg Message) context.Context {
+	m, ok := msg.(*message)
+	if !ok {
+		return ctx
+	}
+	for _, p := range m.propagators {
+		ctx = p.Extract(ctx, m.properties)
+	}
+	return ctx
+}
+
+// injectContext sets the properties of the message from the context, the properties are copied so
+// the map of the application is not modified
+func injectContext(ctx context.Context, propagators []ContextPropagator, msg *ProducerMessage) {
+	if len(propagators) == 0 || ctx == nil {
+		return
+	}
+	properties := make(map[string]string, len(msg.Properties)+len(propagators))
+	for k, v := range msg.Properties {
+		properties[k] = v
+	}
+	for _, p := range propagators {
+		p.Inject(ctx, properties)
+	}
+	msg.Properties = properties
+}
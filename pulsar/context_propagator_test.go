@@ -0,0 +1,87 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsar
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testContextKey string
+
+type testTenant struct {
+	name string
+}
+
+func (t testTenant) String() string {
+	return t.name
+}
+
+func TestContextValuePropagator(t *testing.T) {
+	requestKey := testContextKey("request-id")
+	tenantKey := testContextKey("tenant")
+	propagators := []ContextPropagator{
+		NewContextValuePropagator(requestKey, "x-request-id"),
+		NewContextValuePropagator(tenantKey, "x-tenant"),
+	}
+
+	ctx := context.WithValue(context.Background(), requestKey, "req-1")
+	ctx = context.WithValue(ctx, tenantKey, testTenant{name: "tenant-1"})
+
+	userProperties := map[string]string{"a": "b"}
+	msg := &ProducerMessage{Properties: userProperties}
+	injectContext(ctx, propagators, msg)
+
+	assert.Equal(t, map[string]string{
+		"a":            "b",
+		"x-request-id": "req-1",
+		"x-tenant":     "tenant-1",
+	}, msg.Properties)
+	// the properties of the application are not modified
+	assert.Equal(t, map[string]string{"a": "b"}, userProperties)
+
+	received := &message{
+		properties:  msg.Properties,
+		propagators: propagators,
+	}
+	msgCtx := MessageContext(context.Background(), received)
+	assert.Equal(t, "req-1", msgCtx.Value(requestKey))
+	assert.Equal(t, "tenant-1", msgCtx.Value(tenantKey))
+}
+
+func TestContextValuePropagatorMissingValue(t *testing.T) {
+	key := testContextKey("request-id")
+	propagators := []ContextPropagator{NewContextValuePropagator(key, "x-request-id")}
+
+	msg := &ProducerMessage{}
+	injectContext(context.WithValue(context.Background(), key, 42), propagators, msg)
+	assert.Empty(t, msg.Properties)
+
+	received := &message{
+		properties:  map[string]string{},
+		propagators: propagators,
+	}
+	assert.Nil(t, MessageContext(context.Background(), received).Value(key))
+
+	// no propagators, the message is left unchanged
+	msg = &ProducerMessage{}
+	injectContext(context.Background(), nil, msg)
+	assert.Nil(t, msg.Properties)
+}
@@ -39,5 +39,7 @@ const (
 	// individual messages in the batch.
 	// delivered encrypted message contains EncryptionContext which contains encryption
 	// and compression information in it using which application can decrypt the payload.
+	// The message, or its payload and EncryptionContext stored by the application, can be decrypted later,
+	// once the keys are available, with pulsar.DecryptMessage or pulsar.DecryptPayload.
 	ConsumerCryptoFailureActionConsume
 )
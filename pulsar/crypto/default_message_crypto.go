@@ -30,17 +30,24 @@ import (
 	"errors"
 	"fmt"
 	"sync"
+	"time"
 
 	"github.com/apache/pulsar-client-go/pulsar/log"
 )
 
+// fipsMinRSAKeySize is the smallest RSA modulus accepted in FIPS mode
+const fipsMinRSAKeySize = 2048
+
 // DefaultMessageCrypto implementation of the interface MessageCryto
 type DefaultMessageCrypto struct {
 	// data key which is used to encrypt/decrypt messages
 	dataKey []byte
 
-	// LoadingCache used by the consumer to cache already decrypted key
-	loadingCache sync.Map // map[string][]byte
+	// dataKeys used by the consumer to cache already decrypted data keys by their digest
+	dataKeys *keyCache
+
+	// privateKeys used by the consumer to cache the private keys by key name and metadata
+	privateKeys *keyCache
 
 	encryptedDataKeyMap sync.Map // map[string]EncryptionKeyInfo
 
@@ -51,6 +58,9 @@ type DefaultMessageCrypto struct {
 	cipherLock sync.Mutex
 
 	encryptLock sync.Mutex
+
+	// fipsMode restricts the key material to FIPS approved sizes
+	fipsMode bool
 }
 
 // NewDefaultMessageCrypto get the instance of message crypto
@@ -58,7 +68,8 @@ func NewDefaultMessageCrypto(logCtx string, keyGenNeeded bool, logger log.Logger
 
 	d := &DefaultMessageCrypto{
 		logCtx:              logCtx,
-		loadingCache:        sync.Map{},
+		dataKeys:            newKeyCache(DefaultKeyCacheTTL),
+		privateKeys:         newKeyCache(DefaultKeyCacheTTL),
 		encryptedDataKeyMap: sync.Map{},
 		logger:              logger,
 	}
@@ -74,6 +85,31 @@ func NewDefaultMessageCrypto(logCtx string, keyGenNeeded bool, logger log.Logger
 	return d, nil
 }
 
+// EnableFIPSMode restricts the message crypto to FIPS approved key material.
+// Once enabled, RSA keys shorter than 2048 bits are rejected for both encryption
+// and decryption of the data key.
+func (d *DefaultMessageCrypto) EnableFIPSMode() {
+	d.fipsMode = true
+}
+
+// SetKeyCacheTTL sets how long decrypted data keys and private keys are cached before
+// they are read again from the key reader. A non-positive ttl caches them forever.
+func (d *DefaultMessageCrypto) SetKeyCacheTTL(ttl time.Duration) {
+	d.dataKeys.setTTL(ttl)
+	d.privateKeys.setTTL(ttl)
+}
+
+// WithNewDataKey returns a message crypto with the settings of d encrypting with its own data key, so that the
+// recipients of the messages it encrypts can not decrypt the messages encrypted by d.
+func (d *DefaultMessageCrypto) WithNewDataKey() (*DefaultMessageCrypto, error) {
+	n, err := NewDefaultMessageCrypto(d.logCtx, true, d.logger)
+	if err != nil {
+		return nil, err
+	}
+	n.fipsMode = d.fipsMode
+	return n, nil
+}
+
 // AddPublicKeyCipher encrypt data key using keyCrypto and cache
 func (d *DefaultMessageCrypto) AddPublicKeyCipher(keyNames []string, keyReader KeyReader) error {
 	key, err := generateDataKey()
@@ -115,6 +151,10 @@ func (d *DefaultMessageCrypto) addPublicKeyCipher(keyName string, keyReader KeyR
 		return fmt.Errorf("only RSA keys are supported")
 	}
 
+	if err := d.checkFIPSKeySize(keyName, rsaPubKey.Size()*8); err != nil {
+		return err
+	}
+
 	encryptedDataKey, err := rsa.EncryptOAEP(sha1.New(), rand.Reader, rsaPubKey, d.dataKey, nil)
 	if err != nil {
 		return err
@@ -212,16 +252,14 @@ func (d *DefaultMessageCrypto) Encrypt(encKeys []string,
 func (d *DefaultMessageCrypto) Decrypt(msgMetadata MessageMetadataSupplier,
 	payload []byte,
 	keyReader KeyReader) ([]byte, error) {
-	// if data key is present, attempt to derypt using the existing key
-	if d.dataKey != nil {
-		decryptedData, err := d.getKeyAndDecryptData(msgMetadata, payload)
-		if err != nil {
-			d.logger.Error(err)
-		}
+	// attempt to decrypt using the cached data keys
+	decryptedData, err := d.getKeyAndDecryptData(msgMetadata, payload)
+	if err != nil {
+		d.logger.Error(err)
+	}
 
-		if decryptedData != nil {
-			return decryptedData, nil
-		}
+	if decryptedData != nil {
+		return decryptedData, nil
 	}
 
 	// data key is null or decryption failed. Attempt to regenerate data key
@@ -234,7 +272,7 @@ func (d *DefaultMessageCrypto) Decrypt(msgMetadata MessageMetadataSupplier,
 		}
 	}
 
-	if ecKeyInfo == nil || d.dataKey == nil {
+	if ecKeyInfo == nil {
 		// unable to decrypt data key
 		return nil, errors.New("unable to decrypt data key")
 	}
@@ -275,7 +313,7 @@ func (d *DefaultMessageCrypto) getKeyAndDecryptData(msgMetadata MessageMetadataS
 	for _, k := range msgMetadata.EncryptionKeys() {
 		msgDataKey := k.Key()
 		keyDigest := fmt.Sprintf("%x", md5.Sum(msgDataKey))
-		if storedSecretKey, ok := d.loadingCache.Load(keyDigest); ok {
+		if storedSecretKey, ok := d.dataKeys.get(keyDigest); ok {
 			decryptedData, err := d.decryptData(storedSecretKey.([]byte), msgMetadata, payload)
 			if err != nil {
 				d.logger.Error(err)
@@ -297,33 +335,57 @@ func (d *DefaultMessageCrypto) decryptDataKey(keyName string,
 	keyMeta map[string]string,
 	keyReader KeyReader) bool {
 
-	keyInfo, err := keyReader.PrivateKey(keyName, keyMeta)
+	// the data key is decrypted once per digest, batches of all partitions arriving
+	// at the same time wait for the first one instead of reading the key again
+	dataKeyDigest := fmt.Sprintf("%x", md5.Sum(encDatakey))
+	_, err := d.dataKeys.getOrLoad(dataKeyDigest, func() (interface{}, error) {
+		privateKeyID := privateKeyCacheKey(keyName, keyMeta)
+		rsaPriKey, err := d.privateKeys.getOrLoad(privateKeyID, func() (interface{}, error) {
+			return d.readPrivateKey(keyName, keyMeta, keyReader)
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		decryptedDataKey, err := rsa.DecryptOAEP(sha1.New(), rand.Reader,
+			rsaPriKey.(*rsa.PrivateKey), encDatakey, nil)
+		if err != nil {
+			// the cached private key may be stale if the key was rotated, read it again next time
+			d.privateKeys.invalidate(privateKeyID)
+			return nil, err
+		}
+		return decryptedDataKey, nil
+	})
 	if err != nil {
 		d.logger.Error(err)
 		return false
 	}
 
+	return true
+}
+
+func (d *DefaultMessageCrypto) readPrivateKey(keyName string,
+	keyMeta map[string]string,
+	keyReader KeyReader) (*rsa.PrivateKey, error) {
+	keyInfo, err := keyReader.PrivateKey(keyName, keyMeta)
+	if err != nil {
+		return nil, err
+	}
+
 	parsedKey, err := d.loadPrivateKey(keyInfo.Key())
 	if err != nil {
-		d.logger.Error(err)
-		return false
+		return nil, err
 	}
 
 	rsaPriKey, ok := parsedKey.(*rsa.PrivateKey)
 	if !ok {
-		d.logger.Error("only RSA keys are supported")
-		return false
+		return nil, errors.New("only RSA keys are supported")
 	}
 
-	decryptedDataKey, err := rsa.DecryptOAEP(sha1.New(), rand.Reader, rsaPriKey, encDatakey, nil)
-	if err != nil {
-		d.logger.Error(err)
-		return false
+	if err := d.checkFIPSKeySize(keyName, rsaPriKey.Size()*8); err != nil {
+		return nil, err
 	}
-	d.dataKey = decryptedDataKey
-	d.loadingCache.Store(fmt.Sprintf("%x", md5.Sum(encDatakey)), d.dataKey)
-
-	return true
+	return rsaPriKey, nil
 }
 
 func (d *DefaultMessageCrypto) loadPrivateKey(key []byte) (gocrypto.PrivateKey, error) {
@@ -358,6 +420,14 @@ func (d *DefaultMessageCrypto) loadPublicKey(key []byte) (gocrypto.PublicKey, er
 	return publickKey, nil
 }
 
+func (d *DefaultMessageCrypto) checkFIPSKeySize(keyName string, bits int) error {
+	if d.fipsMode && bits < fipsMinRSAKeySize {
+		return fmt.Errorf("FIPS mode requires RSA keys of at least %d bits, key %s has %d bits",
+			fipsMinRSAKeySize, keyName, bits)
+	}
+	return nil
+}
+
 func generateDataKey() ([]byte, error) {
 	key := make([]byte, 32)  // generate key of length 256 bits
 	_, err := rand.Read(key) // cryptographically secure random number
@@ -18,7 +18,10 @@
 package crypto
 
 import (
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	pb "github.com/apache/pulsar-client-go/pulsar/internal/pulsar_proto"
 	"github.com/apache/pulsar-client-go/pulsar/log"
@@ -163,3 +166,70 @@ func TestEncryptDecrypt(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, msg, string(decryptedData))
 }
+
+type countingKeyReader struct {
+	KeyReader
+	privateKeyReads int32
+}
+
+func (r *countingKeyReader) PrivateKey(keyName string, keyMeta map[string]string) (*EncryptionKeyInfo, error) {
+	atomic.AddInt32(&r.privateKeyReads, 1)
+	return r.KeyReader.PrivateKey(keyName, keyMeta)
+}
+
+func TestDecryptKeyCache(t *testing.T) {
+	msgMetadataSupplier := NewMessageMetadataSupplier(&pb.MessageMetadata{})
+	msg := "my-message-01"
+
+	msgCrypto, err := NewDefaultMessageCrypto("my-app", true, log.DefaultNopLogger())
+	assert.Nil(t, err)
+	encryptedData, err := msgCrypto.Encrypt(
+		[]string{"my-app.key"},
+		NewFileKeyReader("../crypto/testdata/pub_key_rsa.pem", ""),
+		msgMetadataSupplier,
+		[]byte(msg),
+	)
+	assert.Nil(t, err)
+
+	msgCryptoDecrypt, err := NewDefaultMessageCrypto("my-app", false, log.DefaultNopLogger())
+	assert.Nil(t, err)
+	now := time.Now()
+	msgCryptoDecrypt.dataKeys.now = func() time.Time { return now }
+	msgCryptoDecrypt.privateKeys.now = func() time.Time { return now }
+	msgCryptoDecrypt.SetKeyCacheTTL(time.Minute)
+
+	keyReader := &countingKeyReader{KeyReader: NewFileKeyReader("", "../crypto/testdata/pri_key_rsa.pem")}
+
+	// concurrent decryption of the first batches reads the private key once
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			decryptedData, err := msgCryptoDecrypt.Decrypt(msgMetadataSupplier, encryptedData, keyReader)
+			assert.Nil(t, err)
+			assert.Equal(t, msg, string(decryptedData))
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&keyReader.privateKeyReads))
+
+	// expired keys are read again
+	now = now.Add(2 * time.Minute)
+	decryptedData, err := msgCryptoDecrypt.Decrypt(msgMetadataSupplier, encryptedData, keyReader)
+	assert.Nil(t, err)
+	assert.Equal(t, msg, string(decryptedData))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&keyReader.privateKeyReads))
+}
+
+func TestWithNewDataKey(t *testing.T) {
+	msgCrypto, err := NewDefaultMessageCrypto("my-app", true, log.DefaultNopLogger())
+	assert.Nil(t, err)
+	msgCrypto.EnableFIPSMode()
+
+	other, err := msgCrypto.WithNewDataKey()
+	assert.Nil(t, err)
+	assert.True(t, other.fipsMode)
+	assert.NotEmpty(t, other.dataKey)
+	assert.NotEqual(t, msgCrypto.dataKey, other.dataKey)
+}
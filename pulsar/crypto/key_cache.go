@@ -0,0 +1,164 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package crypto
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultKeyCacheTTL is how long decrypted data keys and private keys are cached by default
+const DefaultKeyCacheTTL = 4 * time.Hour
+
+// keyCache caches key material for a limited time. Missing entries are loaded once,
+// concurrent lookups of the same entry wait for the in-flight load instead of hitting
+// the key reader again.
+type keyCache struct {
+	sync.Mutex
+	ttl     time.Duration
+	entries map[string]*keyCacheEntry
+
+	// now is replaced in tests
+	now func() time.Time
+}
+
+type keyCacheEntry struct {
+	loaded  chan struct{}
+	value   interface{}
+	err     error
+	expires time.Time
+}
+
+func newKeyCache(ttl time.Duration) *keyCache {
+	return &keyCache{
+		ttl:     ttl,
+		entries: make(map[string]*keyCacheEntry),
+		now:     time.Now,
+	}
+}
+
+func (c *keyCache) setTTL(ttl time.Duration) {
+	c.Lock()
+	defer c.Unlock()
+	c.ttl = ttl
+}
+
+// get returns the cached value without loading it
+func (c *keyCache) get(key string) (interface{}, bool) {
+	c.Lock()
+	e, ok := c.entries[key]
+	if ok && c.expired(e) {
+		delete(c.entries, key)
+		ok = false
+	}
+	c.Unlock()
+	if !ok {
+		return nil, false
+	}
+
+	select {
+	case <-e.loaded:
+		return e.value, e.err == nil
+	default:
+		// still loading
+		return nil, false
+	}
+}
+
+// getOrLoad returns the cached value, loading it with load if it is missing or expired.
+// Failed loads are not cached.
+func (c *keyCache) getOrLoad(key string, load func() (interface{}, error)) (interface{}, error) {
+	c.Lock()
+	e, ok := c.entries[key]
+	if ok && c.expired(e) {
+		ok = false
+	}
+	if ok {
+		c.Unlock()
+		<-e.loaded
+		return e.value, e.err
+	}
+	e = &keyCacheEntry{loaded: make(chan struct{})}
+	c.entries[key] = e
+	c.Unlock()
+
+	e.value, e.err = load()
+
+	c.Lock()
+	if e.err != nil {
+		if c.entries[key] == e {
+			delete(c.entries, key)
+		}
+	} else {
+		e.expires = c.now().Add(c.ttl)
+	}
+	c.Unlock()
+	close(e.loaded)
+
+	return e.value, e.err
+}
+
+func (c *keyCache) put(key string, value interface{}) {
+	e := &keyCacheEntry{loaded: make(chan struct{}), value: value}
+	close(e.loaded)
+
+	c.Lock()
+	defer c.Unlock()
+	e.expires = c.now().Add(c.ttl)
+	c.entries[key] = e
+}
+
+func (c *keyCache) invalidate(key string) {
+	c.Lock()
+	defer c.Unlock()
+	delete(c.entries, key)
+}
+
+// expired must be called with the lock held. Entries still loading never expire.
+func (c *keyCache) expired(e *keyCacheEntry) bool {
+	if c.ttl <= 0 {
+		return false
+	}
+	select {
+	case <-e.loaded:
+		return e.err == nil && !c.now().Before(e.expires)
+	default:
+		return false
+	}
+}
+
+// privateKeyCacheKey identifies a private key by its name and metadata, the metadata
+// carries the key version for key readers that rotate keys
+func privateKeyCacheKey(keyName string, keyMeta map[string]string) string {
+	var b strings.Builder
+	b.WriteString(keyName)
+	names := make([]string, 0, len(keyMeta))
+	for k := range keyMeta {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, k := range names {
+		b.WriteByte(0)
+		b.WriteString(k)
+		b.WriteByte('=')
+		b.WriteString(keyMeta[k])
+	}
+	return b.String()
+}
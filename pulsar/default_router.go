@@ -31,6 +31,21 @@ type defaultRouter struct {
 	cumulativeBatchSize uint32
 }
 
+// keyPartition returns the partition of a message routed by its key, false when the message has no key.
+func keyPartition(hashFunc func(string) uint32, message *ProducerMessage, numPartitions uint32) (int, bool) {
+	if len(message.OrderingKey) != 0 {
+		// When an OrderingKey is specified, use the hash of that key
+		return int(hashFunc(message.OrderingKey) % numPartitions), true
+	}
+
+	if len(message.Key) != 0 {
+		// When a key is specified, use the hash of that key
+		return int(hashFunc(message.Key) % numPartitions), true
+	}
+
+	return 0, false
+}
+
 // NewDefaultRouter set the message routing mode for the partitioned producer.
 // Default routing mode is round-robin routing if no partition key is specified.
 // If the batching is enabled, it honors the different thresholds for batching i.e. maximum batch size,
@@ -54,14 +69,8 @@ func NewDefaultRouter(
 			return 0
 		}
 
-		if len(message.OrderingKey) != 0 {
-			// When an OrderingKey is specified, use the hash of that key
-			return int(hashFunc(message.OrderingKey) % numPartitions)
-		}
-
-		if len(message.Key) != 0 {
-			// When a key is specified, use the hash of that key
-			return int(hashFunc(message.Key) % numPartitions)
+		if partition, ok := keyPartition(hashFunc, message, numPartitions); ok {
+			return partition
 		}
 
 		// If there's no key, we do round-robin across partition. If no batching go to next partition.
@@ -109,3 +118,39 @@ func NewDefaultRouter(
 		return int(partitionCursor % numPartitions)
 	}
 }
+
+// NewStickyPartitionRouter returns a router that sends the messages without key to the same partition for
+// switchFrequency batching intervals of maxBatchingDelay, then to the next partition, so the batches are as large as
+// the publish rate allows while every partition gets the same share of the messages in the long run.
+// The partition is derived from the clock, starting from a random partition, so it doesn't need any coordination
+// between the goroutines sending messages. Messages with a key are routed by the hash of the key.
+func NewStickyPartitionRouter(
+	hashFunc func(string) uint32,
+	maxBatchingDelay time.Duration,
+	switchFrequency int) func(*ProducerMessage, uint32) int {
+	switchInterval := maxBatchingDelay.Nanoseconds() * int64(switchFrequency)
+	if switchInterval <= 0 {
+		switchInterval = 1
+	}
+	startPartition := int64(rand.Uint32())
+	clock := func() int64 {
+		return time.Now().UnixNano()
+	}
+
+	return newStickyPartitionRouter(hashFunc, switchInterval, startPartition, clock)
+}
+
+func newStickyPartitionRouter(hashFunc func(string) uint32, switchInterval int64, startPartition int64,
+	clock func() int64) func(*ProducerMessage, uint32) int {
+	return func(message *ProducerMessage, numPartitions uint32) int {
+		if numPartitions == 1 {
+			return 0
+		}
+
+		if partition, ok := keyPartition(hashFunc, message, numPartitions); ok {
+			return partition
+		}
+
+		return int((clock()/switchInterval + startPartition) % int64(numPartitions))
+	}
+}
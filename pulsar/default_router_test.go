@@ -147,3 +147,28 @@ func TestDefaultRouterNoRoutingBecauseOnlyOnePartition(t *testing.T) {
 	assert.Equal(t, 0, p4)
 	assert.Equal(t, 0, p5)
 }
+
+func TestStickyPartitionRouter(t *testing.T) {
+	var now int64
+	router := newStickyPartitionRouter(internal.JavaStringHash, 100, 1, func() int64 {
+		return now
+	})
+	const numPartitions = uint32(3)
+
+	// the partition sticks for the switch interval
+	assert.Equal(t, 1, router(&ProducerMessage{}, numPartitions))
+	now = 99
+	assert.Equal(t, 1, router(&ProducerMessage{}, numPartitions))
+
+	counts := make([]int, numPartitions)
+	for now = 100; now < 100+300*100; now += 10 {
+		counts[router(&ProducerMessage{}, numPartitions)]++
+	}
+	assert.Equal(t, []int{1000, 1000, 1000}, counts)
+
+	// keys are still routed by their hash
+	key := "my-key"
+	assert.Equal(t, int(internal.JavaStringHash(key)%numPartitions), router(&ProducerMessage{Key: key}, numPartitions))
+
+	assert.Equal(t, 0, router(&ProducerMessage{}, 1))
+}
@@ -0,0 +1,183 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsar
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/apache/pulsar-client-go/pulsar/internal"
+)
+
+// DLQPolicyBuilder builds and validates a DLQPolicy, and the retry settings of a consumer:
+//
+//	err := pulsar.NewDLQPolicyBuilder(5).
+//		EnableRetry().
+//		RetryDelays(time.Second, 10*time.Second, time.Minute).
+//		InitialSubscription("dlq-audit").
+//		Apply(&consumerOptions)
+//
+// The inconsistent settings are reported by Build and Apply, rather than when consuming.
+type DLQPolicyBuilder struct {
+	policy DLQPolicy
+	retry  bool
+}
+
+// NewDLQPolicyBuilder returns a builder of a policy sending the messages to the dead letter topic after
+// maxDeliveries deliveries
+func NewDLQPolicyBuilder(maxDeliveries uint32) *DLQPolicyBuilder {
+	return &DLQPolicyBuilder{
+		policy: DLQPolicy{MaxDeliveries: maxDeliveries},
+	}
+}
+
+// DeadLetterTopic sets the dead letter topic, which defaults to a topic named after the topic and the subscription
+// of the consumer when retry is enabled
+func (b *DLQPolicyBuilder) DeadLetterTopic(topic string) *DLQPolicyBuilder {
+	b.policy.DeadLetterTopic = topic
+	return b
+}
+
+// EnableRetry enables the retry letter topic, see ConsumerOptions.RetryEnable
+func (b *DLQPolicyBuilder) EnableRetry() *DLQPolicyBuilder {
+	b.retry = true
+	return b
+}
+
+// RetryLetterTopic sets the retry letter topic, which defaults to a topic named after the topic and the
+// subscription of the consumer
+func (b *DLQPolicyBuilder) RetryLetterTopic(topic string) *DLQPolicyBuilder {
+	b.policy.RetryLetterTopic = topic
+	return b
+}
+
+// RetryDelays sets the delays of the successive retries, see DLQPolicy.RetryDelays
+func (b *DLQPolicyBuilder) RetryDelays(delays ...time.Duration) *DLQPolicyBuilder {
+	b.policy.RetryDelays = delays
+	return b
+}
+
+// InitialSubscription sets the subscription created along with the dead letter topic
+func (b *DLQPolicyBuilder) InitialSubscription(name string) *DLQPolicyBuilder {
+	b.policy.InitialSubscriptionName = name
+	return b
+}
+
+// ProducerOptions sets the options of the producers of the dead letter and retry letter topics
+func (b *DLQPolicyBuilder) ProducerOptions(options ProducerOptions) *DLQPolicyBuilder {
+	b.policy.ProducerOptions = options
+	return b
+}
+
+// MaxPendingMessages sets the maximum number of messages waiting to be sent to each of the dead letter and retry
+// letter topics, see DLQPolicy.MaxPendingMessages
+func (b *DLQPolicyBuilder) MaxPendingMessages(n int) *DLQPolicyBuilder {
+	b.policy.MaxPendingMessages = n
+	return b
+}
+
+// Build validates the settings and returns the policy
+func (b *DLQPolicyBuilder) Build() (*DLQPolicy, error) {
+	var errs []string
+	p := b.policy
+
+	if p.MaxDeliveries == 0 {
+		errs = append(errs, "MaxDeliveries needs to be > 0")
+	}
+	if p.DeadLetterTopic == "" && !b.retry {
+		errs = append(errs, "DeadLetterTopic is required unless retry is enabled")
+	}
+	if !b.retry {
+		if p.RetryLetterTopic != "" {
+			errs = append(errs, "RetryLetterTopic requires retry to be enabled")
+		}
+		if len(p.RetryDelays) > 0 {
+			errs = append(errs, "RetryDelays require retry to be enabled")
+		}
+	}
+	for _, topic := range []string{p.DeadLetterTopic, p.RetryLetterTopic} {
+		if topic == "" {
+			continue
+		}
+		if _, err := internal.ParseTopicName(topic); err != nil {
+			errs = append(errs, fmt.Sprintf("invalid topic name %q", topic))
+		}
+	}
+	if p.DeadLetterTopic != "" && p.DeadLetterTopic == p.RetryLetterTopic {
+		errs = append(errs, "DeadLetterTopic and RetryLetterTopic need to be different topics")
+	}
+	if len(p.RetryDelays) > int(p.MaxDeliveries) {
+		errs = append(errs, fmt.Sprintf("%d RetryDelays for %d MaxDeliveries, the extra delays would never be used",
+			len(p.RetryDelays), p.MaxDeliveries))
+	}
+	if p.MaxPendingMessages < 0 {
+		errs = append(errs, "MaxPendingMessages needs to be >= 0")
+	}
+	for _, delay := range p.RetryDelays {
+		if delay <= 0 {
+			errs = append(errs, "RetryDelays need to be > 0")
+			break
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, newError(InvalidConfiguration, "invalid DLQ policy: "+strings.Join(errs, ", "))
+	}
+	p.RetryDelays = append([]time.Duration(nil), p.RetryDelays...)
+	return &p, nil
+}
+
+// Apply validates the settings along with the options of the consumer, and sets the DLQ and RetryEnable options
+func (b *DLQPolicyBuilder) Apply(options *ConsumerOptions) error {
+	policy, err := b.Build()
+	if err != nil {
+		return err
+	}
+	// the broker counts the redeliveries, and delays the deliveries, only for shared subscriptions
+	if options.Type != Shared && options.Type != KeyShared {
+		if b.retry {
+			return newError(InvalidConfiguration,
+				"invalid DLQ policy: retry requires a Shared or KeyShared subscription")
+		}
+		return newError(InvalidConfiguration,
+			"invalid DLQ policy: the dead letter topic requires a Shared or KeyShared subscription")
+	}
+	options.DLQ = policy
+	options.RetryEnable = b.retry
+	return nil
+}
+
+// retryDelay returns the delay of the given retry, starting at 1, or 0 if the policy has no retry delays
+func (p *DLQPolicy) retryDelay(reconsumeTimes int) time.Duration {
+	if p == nil || len(p.RetryDelays) == 0 || reconsumeTimes < 1 {
+		return 0
+	}
+	if reconsumeTimes > len(p.RetryDelays) {
+		return p.RetryDelays[len(p.RetryDelays)-1]
+	}
+	return p.RetryDelays[reconsumeTimes-1]
+}
+
+// maxPendingMessages returns the maximum number of messages waiting to be sent to a dead letter or retry topic
+func (p *DLQPolicy) maxPendingMessages() int {
+	if p.MaxPendingMessages <= 0 {
+		return DefaultDLQMaxPendingMessages
+	}
+	return p.MaxPendingMessages
+}
@@ -0,0 +1,85 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsar
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDLQPolicyBuilder(t *testing.T) {
+	policy, err := NewDLQPolicyBuilder(3).
+		EnableRetry().
+		RetryDelays(time.Second, time.Minute).
+		InitialSubscription("audit").
+		Build()
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(3), policy.MaxDeliveries)
+	assert.Equal(t, "audit", policy.InitialSubscriptionName)
+	assert.Equal(t, []time.Duration{time.Second, time.Minute}, policy.RetryDelays)
+
+	policy, err = NewDLQPolicyBuilder(3).DeadLetterTopic("persistent://public/default/dlq").Build()
+	assert.Nil(t, err)
+	assert.Equal(t, "persistent://public/default/dlq", policy.DeadLetterTopic)
+}
+
+func TestDLQPolicyBuilderValidation(t *testing.T) {
+	tests := map[string]*DLQPolicyBuilder{
+		"no max deliveries":   NewDLQPolicyBuilder(0).DeadLetterTopic("dlq"),
+		"no dead letter":      NewDLQPolicyBuilder(3),
+		"retry topic":         NewDLQPolicyBuilder(3).DeadLetterTopic("dlq").RetryLetterTopic("retry"),
+		"retry delays":        NewDLQPolicyBuilder(3).DeadLetterTopic("dlq").RetryDelays(time.Second),
+		"same topics":         NewDLQPolicyBuilder(3).EnableRetry().DeadLetterTopic("t").RetryLetterTopic("t"),
+		"invalid topic":       NewDLQPolicyBuilder(3).DeadLetterTopic("invalid://topic"),
+		"too many delays":     NewDLQPolicyBuilder(1).EnableRetry().RetryDelays(time.Second, time.Minute),
+		"non positive delays": NewDLQPolicyBuilder(3).EnableRetry().RetryDelays(0),
+		"negative pending":    NewDLQPolicyBuilder(3).DeadLetterTopic("dlq").MaxPendingMessages(-1),
+	}
+	for name, b := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := b.Build()
+			assert.Error(t, err)
+			assert.Equal(t, InvalidConfiguration, err.(*Error).Result())
+		})
+	}
+}
+
+func TestDLQPolicyBuilderApply(t *testing.T) {
+	options := ConsumerOptions{SubscriptionName: "sub"}
+	err := NewDLQPolicyBuilder(3).EnableRetry().Apply(&options)
+	assert.Error(t, err)
+	assert.Nil(t, options.DLQ)
+
+	options.Type = Shared
+	err = NewDLQPolicyBuilder(3).EnableRetry().RetryDelays(time.Second).Apply(&options)
+	assert.Nil(t, err)
+	assert.True(t, options.RetryEnable)
+	assert.Equal(t, uint32(3), options.DLQ.MaxDeliveries)
+}
+
+func TestDLQPolicyRetryDelay(t *testing.T) {
+	var nilPolicy *DLQPolicy
+	assert.Equal(t, time.Duration(0), nilPolicy.retryDelay(1))
+
+	policy := &DLQPolicy{RetryDelays: []time.Duration{time.Second, time.Minute}}
+	assert.Equal(t, time.Second, policy.retryDelay(1))
+	assert.Equal(t, time.Minute, policy.retryDelay(2))
+	assert.Equal(t, time.Minute, policy.retryDelay(5))
+}
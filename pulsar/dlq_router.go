@@ -25,6 +25,9 @@ import (
 	"github.com/apache/pulsar-client-go/pulsar/log"
 )
 
+// DefaultDLQMaxPendingMessages is the default DLQPolicy.MaxPendingMessages
+const DefaultDLQMaxPendingMessages = 1000
+
 type dlqRouter struct {
 	client    Client
 	producer  Producer
@@ -50,7 +53,7 @@ func newDlqRouter(client Client, policy *DLQPolicy, logger log.Logger) (*dlqRout
 			return nil, newError(InvalidConfiguration, "DLQPolicy.Topic needs to be set to a valid topic name")
 		}
 
-		r.messageCh = make(chan ConsumerMessage)
+		r.messageCh = make(chan ConsumerMessage, policy.maxPendingMessages())
 		r.closeCh = make(chan interface{}, 1)
 		r.log = logger.SubLogger(log.Fields{"dlq-topic": policy.DeadLetterTopic})
 		go r.run()
@@ -80,8 +83,15 @@ func (r *dlqRouter) shouldSendToDlq(cm *ConsumerMessage) bool {
 	return msg.redeliveryCount >= r.policy.MaxDeliveries
 }
 
-func (r *dlqRouter) Chan() chan ConsumerMessage {
-	return r.messageCh
+// route passes the message to the router without blocking. The message is negatively acknowledged, to be
+// redelivered later, if too many messages are pending, e.g. while the DLQ topic is unavailable.
+func (r *dlqRouter) route(cm ConsumerMessage) {
+	select {
+	case r.messageCh <- cm:
+	default:
+		r.log.WithField("msgID", cm.ID()).Warn("Too many messages pending for the DLQ, negatively acknowledging")
+		cm.Consumer.Nack(cm)
+	}
 }
 
 func (r *dlqRouter) run() {
@@ -90,6 +100,10 @@ func (r *dlqRouter) run() {
 		case cm := <-r.messageCh:
 			r.log.WithField("msgID", cm.ID()).Debug("Got message for DLQ")
 			producer := r.getProducer(cm.Consumer.(*consumer).options.Schema)
+			if producer == nil {
+				r.log.Debug("Closed DLQ router")
+				return
+			}
 			msg := cm.Message.(*message)
 			msgID := msg.ID()
 
@@ -109,13 +123,20 @@ func (r *dlqRouter) run() {
 				Properties:          properties,
 				EventTime:           msg.EventTime(),
 				ReplicationClusters: msg.replicationClusters,
-			}, func(MessageID, *ProducerMessage, error) {
-				r.log.WithField("msgID", msgID).Debug("Sent message to DLQ")
-
+			}, func(_ MessageID, _ *ProducerMessage, err error) {
 				// The Producer ack might be coming from the connection go-routine that
 				// is also used by the consumer. In that case we would get a dead-lock
 				// if we'd try to ack.
-				go cm.Consumer.AckID(msgID)
+				if err != nil {
+					// the message is redelivered later instead of being lost
+					r.log.WithError(err).WithField("msgID", msgID).Error("Failed to send message to DLQ")
+					cm.Consumer.(*consumer).asyncErrors.report(ConsumerOpDLQ, msg.Topic(), msgID, err)
+					go cm.Consumer.Nack(cm)
+				} else {
+					r.log.WithField("msgID", msgID).Debug("Sent message to DLQ")
+					cm.Consumer.(*consumer).metrics.DlqPublished.Inc()
+					go cm.Consumer.AckID(msgID)
+				}
 			})
 
 		case <-r.closeCh:
@@ -136,6 +157,8 @@ func (r *dlqRouter) close() {
 	}
 }
 
+// getProducer returns the DLQ producer, created on first use. The creation is retried until the router is
+// closed, nil is returned then, while the messages wait in the router.
 func (r *dlqRouter) getProducer(schema Schema) Producer {
 	if r.producer != nil {
 		// Producer was already initialized
@@ -148,6 +171,7 @@ func (r *dlqRouter) getProducer(schema Schema) Producer {
 		opt := r.policy.ProducerOptions
 		opt.Topic = r.policy.DeadLetterTopic
 		opt.Schema = schema
+		opt.initialSubscriptionName = r.policy.InitialSubscriptionName
 
 		// the origin code sets to LZ4 compression with no options
 		// so the new design allows compression type to be overwritten but still set lz4 by default
@@ -158,7 +182,11 @@ func (r *dlqRouter) getProducer(schema Schema) Producer {
 
 		if err != nil {
 			r.log.WithError(err).Error("Failed to create DLQ producer")
-			time.Sleep(backoff.Next())
+			select {
+			case <-time.After(backoff.Next()):
+			case <-r.closeCh:
+				return nil
+			}
 			continue
 		} else {
 			r.producer = producer
@@ -0,0 +1,98 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsar
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/apache/pulsar-client-go/pulsar/log"
+	"github.com/stretchr/testify/assert"
+)
+
+type nackRecordingConsumer struct {
+	Consumer
+	sync.Mutex
+	nacked []MessageID
+}
+
+func (c *nackRecordingConsumer) Nack(msg Message) {
+	c.Lock()
+	defer c.Unlock()
+	c.nacked = append(c.nacked, msg.ID())
+}
+
+type failingProducerClient struct {
+	Client
+	attempts chan struct{}
+}
+
+func (c *failingProducerClient) CreateProducer(ProducerOptions) (Producer, error) {
+	c.attempts <- struct{}{}
+	return nil, errors.New("topic unavailable")
+}
+
+func TestDlqRouterBuffersMessages(t *testing.T) {
+	policy := &DLQPolicy{MaxDeliveries: 1, DeadLetterTopic: "dlq", MaxPendingMessages: 2}
+	r := &dlqRouter{
+		policy:    policy,
+		messageCh: make(chan ConsumerMessage, policy.maxPendingMessages()),
+		log:       log.DefaultNopLogger(),
+	}
+	consumer := &nackRecordingConsumer{}
+	for i := 0; i < 3; i++ {
+		r.route(ConsumerMessage{Consumer: consumer, Message: &message{msgID: newMessageID(1, int64(i), 0, 0, 0)}})
+	}
+
+	// the message beyond the pending ones is negatively acknowledged without blocking
+	assert.Len(t, r.messageCh, 2)
+	assert.Equal(t, []MessageID{newMessageID(1, 2, 0, 0, 0)}, consumer.nacked)
+	assert.Equal(t, DefaultDLQMaxPendingMessages, (&DLQPolicy{}).maxPendingMessages())
+}
+
+func TestRetryRouterProducerCreationStopsOnClose(t *testing.T) {
+	client := &failingProducerClient{attempts: make(chan struct{}, 10)}
+	r, err := newRetryRouter(client, &DLQPolicy{MaxDeliveries: 1, RetryLetterTopic: "rlq"}, true,
+		log.DefaultNopLogger())
+	assert.Nil(t, err)
+
+	consumer := &nackRecordingConsumer{}
+	r.route(RetryMessage{consumerMsg: ConsumerMessage{Consumer: consumer, Message: &message{}}})
+	r.route(RetryMessage{consumerMsg: ConsumerMessage{Consumer: consumer, Message: &message{}}})
+
+	// the creation of the producer is retried in the background while the messages are routed
+	select {
+	case <-client.attempts:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the producer was not created")
+	}
+	assert.Empty(t, consumer.nacked)
+
+	// the router stops retrying once closed
+	r.close()
+	assert.Eventually(t, func() bool {
+		return len(r.closeCh) == 0
+	}, 5*time.Second, 10*time.Millisecond)
+	for len(client.attempts) > 0 {
+		<-client.attempts
+	}
+	time.Sleep(500 * time.Millisecond)
+	assert.Empty(t, client.attempts)
+}
@@ -17,7 +17,19 @@
 
 package pulsar
 
-import "github.com/apache/pulsar-client-go/pulsar/crypto"
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/apache/pulsar-client-go/pulsar/crypto"
+	internalcrypto "github.com/apache/pulsar-client-go/pulsar/internal/crypto"
+	"github.com/apache/pulsar-client-go/pulsar/log"
+)
+
+// EncryptionKeySelector returns the names of the keys encrypting the data key of a message
+type EncryptionKeySelector func(msg *ProducerMessage) []string
 
 // ProducerEncryptionInfo encryption related fields required by the producer
 type ProducerEncryptionInfo struct {
@@ -33,6 +45,51 @@ type ProducerEncryptionInfo struct {
 	// ProducerCryptoFailureAction action to be taken on failure of message encryption
 	// default is ProducerCryptoFailureActionFail
 	ProducerCryptoFailureAction int
+
+	// KeySelector selects the encryption keys per message, e.g. from a tenant property, so that the
+	// recipients of a topic differ by message. The Keys are used for the messages it selects no key for.
+	// Each set of keys is encrypted with its own data key, cached for the lifetime of the producer, unless
+	// a custom MessageCrypto is set. A batch only holds consecutive messages of the same set of keys.
+	KeySelector EncryptionKeySelector
+}
+
+// keySetEncryptors caches the encryptor, and so the data key, of each set of keys selected by the key selector
+type keySetEncryptors struct {
+	info       *ProducerEncryptionInfo
+	log        log.Logger
+	encryptors map[string]internalcrypto.Encryptor
+}
+
+func newKeySetEncryptors(info *ProducerEncryptionInfo, logger log.Logger) *keySetEncryptors {
+	return &keySetEncryptors{
+		info:       info,
+		log:        logger,
+		encryptors: make(map[string]internalcrypto.Encryptor),
+	}
+}
+
+// get returns the encryptor of the set of keys, regardless of their order
+func (e *keySetEncryptors) get(keys []string) (internalcrypto.Encryptor, error) {
+	sorted := make([]string, len(keys))
+	copy(sorted, keys)
+	sort.Strings(sorted)
+	id := strings.Join(sorted, ",")
+	if encryptor, ok := e.encryptors[id]; ok {
+		return encryptor, nil
+	}
+
+	messageCrypto := e.info.MessageCrypto
+	if defaultCrypto, ok := messageCrypto.(*crypto.DefaultMessageCrypto); ok {
+		c, err := defaultCrypto.WithNewDataKey()
+		if err != nil {
+			return nil, newError(CryptoError, fmt.Sprintf("unable to create the data key of keys %v: %v", keys, err))
+		}
+		messageCrypto = c
+	}
+	encryptor := internalcrypto.NewProducerEncryptor(sorted, e.info.KeyReader, messageCrypto,
+		e.info.ProducerCryptoFailureAction, e.log)
+	e.encryptors[id] = encryptor
+	return encryptor, nil
 }
 
 // MessageDecryptionInfo encryption related fields required by the consumer to decrypt the message
@@ -45,4 +102,31 @@ type MessageDecryptionInfo struct {
 
 	// ConsumerCryptoFailureAction action to be taken on failure of message decryption
 	ConsumerCryptoFailureAction int
+
+	// KeyCacheTTL how long the default message crypto caches decrypted data keys and private keys
+	// before reading them again from the KeyReader. Default is 4 hours, a negative value keeps them
+	// cached until the consumer is closed.
+	KeyCacheTTL time.Duration
+}
+
+// configureKeyCache applies the key cache ttl to the default message crypto
+func configureKeyCache(decryption *MessageDecryptionInfo) {
+	defaultCrypto, ok := decryption.MessageCrypto.(*crypto.DefaultMessageCrypto)
+	if !ok || decryption.KeyCacheTTL == 0 {
+		return
+	}
+	defaultCrypto.SetKeyCacheTTL(decryption.KeyCacheTTL)
+}
+
+// enableFIPSMessageCrypto switches the message crypto to FIPS mode. Only the default
+// message crypto is known to rely on FIPS approved algorithms (RSA-OAEP and AES-GCM),
+// so any other implementation is rejected.
+func enableFIPSMessageCrypto(messageCrypto crypto.MessageCrypto) error {
+	defaultCrypto, ok := messageCrypto.(*crypto.DefaultMessageCrypto)
+	if !ok {
+		return newError(CryptoError,
+			fmt.Sprintf("FIPS mode only supports the default message crypto, got %T", messageCrypto))
+	}
+	defaultCrypto.EnableFIPSMode()
+	return nil
 }
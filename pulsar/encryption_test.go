@@ -0,0 +1,62 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsar
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/apache/pulsar-client-go/pulsar/crypto"
+	pb "github.com/apache/pulsar-client-go/pulsar/internal/pulsar_proto"
+	"github.com/apache/pulsar-client-go/pulsar/log"
+)
+
+func TestKeySetEncryptors(t *testing.T) {
+	messageCrypto, err := crypto.NewDefaultMessageCrypto("test", true, log.DefaultNopLogger())
+	assert.NoError(t, err)
+	keyReader := crypto.NewFileKeyReader("crypto/testdata/pub_key_rsa.pem", "crypto/testdata/pri_key_rsa.pem")
+	encryptors := newKeySetEncryptors(&ProducerEncryptionInfo{
+		KeyReader:     keyReader,
+		MessageCrypto: messageCrypto,
+	}, log.DefaultNopLogger())
+
+	ab, err := encryptors.get([]string{"tenant-b", "tenant-a"})
+	assert.NoError(t, err)
+	same, err := encryptors.get([]string{"tenant-a", "tenant-b"})
+	assert.NoError(t, err)
+	assert.Same(t, ab, same)
+	c, err := encryptors.get([]string{"tenant-c"})
+	assert.NoError(t, err)
+	assert.NotSame(t, ab, c)
+
+	mm := &pb.MessageMetadata{}
+	encrypted, err := ab.Encrypt([]byte("hello"), mm)
+	assert.NoError(t, err)
+	var names []string
+	for _, k := range mm.GetEncryptionKeys() {
+		names = append(names, k.GetKey())
+	}
+	assert.Equal(t, []string{"tenant-a", "tenant-b"}, names)
+
+	decryptor, err := crypto.NewDefaultMessageCrypto("test", false, log.DefaultNopLogger())
+	assert.NoError(t, err)
+	decrypted, err := decryptor.Decrypt(crypto.NewMessageMetadataSupplier(mm), encrypted, keyReader)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", string(decrypted))
+}
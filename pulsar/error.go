@@ -113,6 +113,10 @@ const (
 
 	// ClientMemoryBufferIsFull client limit buffer is full
 	ClientMemoryBufferIsFull
+	// NamespaceNotFound namespace not found
+	NamespaceNotFound
+	// SendCanceled means the send was canceled by Producer.CancelPending
+	SendCanceled
 )
 
 // Error implement error interface, composed of two parts: msg and result.
@@ -219,8 +223,18 @@ func getResultStr(r Result) string {
 		return "ProducerClosed"
 	case SchemaFailure:
 		return "SchemaFailure"
+	case ReachMaxPendingOps:
+		return "ReachMaxPendingOps"
+	case InvalidStatus:
+		return "InvalidStatus"
+	case TransactionError:
+		return "TransactionError"
 	case ClientMemoryBufferIsFull:
 		return "ClientMemoryBufferIsFull"
+	case NamespaceNotFound:
+		return "NamespaceNotFound"
+	case SendCanceled:
+		return "SendCanceled"
 	default:
 		return fmt.Sprintf("Result(%d)", r)
 	}
@@ -0,0 +1,129 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsar
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// TopicMessage is a message to send to a topic with Client.GroupSend
+type TopicMessage struct {
+	Topic   string
+	Message *ProducerMessage
+}
+
+// GroupSendResult is the result of sending a message of Client.GroupSend
+type GroupSendResult struct {
+	// MessageID is the id of the message, if it was sent
+	MessageID MessageID
+	// Err is the error of the message, if it was not sent. When the messages are sent in a transaction, the
+	// messages which were sent are not visible if an other message failed, and their error is the error aborting
+	// the transaction.
+	Err error
+}
+
+// GroupSendOptions configures Client.GroupSend
+type GroupSendOptions struct {
+	// Transactional sends the messages in a transaction, committed if all the messages are sent and aborted
+	// otherwise, so that the messages are visible to the consumers either all or none. The client must have
+	// been created with ClientOptions.EnableTransaction.
+	// By default, the messages are sent on a best-effort basis: each message is sent independently.
+	Transactional bool
+
+	// TransactionTimeout is the timeout of the transaction, see Client.NewTransaction.
+	TransactionTimeout time.Duration
+}
+
+// GroupSend sends messages to several topics and waits for all of them to be sent
+func (c *client) GroupSend(ctx context.Context, messages []TopicMessage,
+	options *GroupSendOptions) ([]GroupSendResult, error) {
+	if options == nil {
+		options = &GroupSendOptions{}
+	}
+
+	var txn Transaction
+	if options.Transactional {
+		var err error
+		if txn, err = c.NewTransaction(options.TransactionTimeout); err != nil {
+			return nil, err
+		}
+	}
+
+	results := make([]GroupSendResult, len(messages))
+	var wg sync.WaitGroup
+	for i, m := range messages {
+		p, err := c.ProducerFor(m.Topic)
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+		msg := m.Message
+		if txn != nil {
+			txnMsg := *msg
+			txnMsg.Transaction = txn
+			msg = &txnMsg
+		}
+		wg.Add(1)
+		result := &results[i]
+		p.SendAsync(ctx, msg, func(id MessageID, _ *ProducerMessage, err error) {
+			result.MessageID, result.Err = id, err
+			p.Close()
+			wg.Done()
+		})
+	}
+	wg.Wait()
+
+	failed := 0
+	var firstErr error
+	for _, r := range results {
+		if r.Err != nil {
+			if firstErr == nil {
+				firstErr = r.Err
+			}
+			failed++
+		}
+	}
+
+	if txn != nil {
+		var txnErr error
+		if failed > 0 {
+			txnErr = txn.Abort(ctx)
+			if txnErr == nil {
+				txnErr = newError(TransactionError, "transaction aborted after a failed message")
+			}
+		} else if txnErr = txn.Commit(ctx); txnErr == nil {
+			return results, nil
+		}
+		for i := range results {
+			if results[i].Err == nil {
+				results[i].MessageID, results[i].Err = nil, txnErr
+			}
+		}
+		if failed == 0 {
+			return results, txnErr
+		}
+	}
+
+	if failed > 0 {
+		return results, fmt.Errorf("%d of %d messages failed to be sent: %w", failed, len(messages), firstErr)
+	}
+	return results, nil
+}
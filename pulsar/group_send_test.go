@@ -0,0 +1,94 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsar
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientGroupSend(t *testing.T) {
+	client, err := NewClient(ClientOptions{
+		URL: lookupURL,
+	})
+	assert.NoError(t, err)
+	defer client.Close()
+
+	topics := []string{newTopicName(), newTopicName()}
+	consumers := make([]Consumer, len(topics))
+	for i, topic := range topics {
+		consumers[i], err = client.Subscribe(ConsumerOptions{
+			Topic:            topic,
+			SubscriptionName: "my-sub",
+		})
+		assert.NoError(t, err)
+		defer consumers[i].Close()
+	}
+
+	messages := make([]TopicMessage, 0, 4)
+	for i := 0; i < 4; i++ {
+		messages = append(messages, TopicMessage{
+			Topic:   topics[i%len(topics)],
+			Message: &ProducerMessage{Payload: []byte(fmt.Sprintf("msg-%d", i))},
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	results, err := client.GroupSend(ctx, messages, nil)
+	assert.NoError(t, err)
+	assert.Len(t, results, len(messages))
+	for _, r := range results {
+		assert.NoError(t, r.Err)
+		assert.NotNil(t, r.MessageID)
+	}
+
+	for i := 0; i < 4; i++ {
+		msg, err := consumers[i%len(topics)].Receive(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, fmt.Sprintf("msg-%d", i), string(msg.Payload()))
+	}
+
+	// a failed message does not prevent the others from being sent
+	messages = []TopicMessage{
+		{Topic: topics[0], Message: &ProducerMessage{Payload: []byte("ok")}},
+		{Topic: "invalid://topic", Message: &ProducerMessage{Payload: []byte("failed")}},
+	}
+	results, err = client.GroupSend(ctx, messages, nil)
+	assert.Error(t, err)
+	assert.NoError(t, results[0].Err)
+	assert.Error(t, results[1].Err)
+}
+
+func TestClientGroupSendTransactionNotEnabled(t *testing.T) {
+	client, err := NewClient(ClientOptions{
+		URL: lookupURL,
+	})
+	assert.NoError(t, err)
+	defer client.Close()
+
+	_, err = client.GroupSend(context.Background(), []TopicMessage{
+		{Topic: newTopicName(), Message: &ProducerMessage{Payload: []byte("msg")}},
+	}, &GroupSendOptions{Transactional: true})
+	assert.Error(t, err)
+	assert.Equal(t, InvalidConfiguration, err.(*Error).Result())
+}
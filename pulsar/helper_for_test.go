@@ -159,7 +159,7 @@ func topicStats(topic string) (map[string]interface{}, error) {
 
 func transactionStats(id *TxnID) (map[string]interface{}, error) {
 	var metadata map[string]interface{}
-	path := fmt.Sprintf("admin/v3/transactions/transactionMetadata/%d/%d", id.mostSigBits, id.leastSigBits)
+	path := fmt.Sprintf("admin/v3/transactions/transactionMetadata/%d/%d", id.MostSigBits, id.LeastSigBits)
 	err := httpGet(path, &metadata)
 	return metadata, err
 }
@@ -0,0 +1,419 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsar
+
+import (
+	"bufio"
+	"container/list"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultIdempotencyStoreMaxKeys is the default number of idempotency keys kept by scope in the idempotency stores
+const DefaultIdempotencyStoreMaxKeys = 10000
+
+// IdempotencyStore persists the sequence ids assigned to the idempotency keys of an IdempotentProducer. The keys
+// are grouped by scope, the producer name and partition the sequence ids belong to.
+type IdempotencyStore interface {
+	// Get returns the sequence id assigned to the key in the scope, if any
+	Get(scope, key string) (sequenceID int64, ok bool, err error)
+
+	// Put assigns the sequence id to the key in the scope
+	Put(scope, key string, sequenceID int64) error
+
+	// Delete removes the key from the scope
+	Delete(scope, key string) error
+
+	// LastSequenceID returns the highest sequence id assigned in the scope, or -1 if none
+	LastSequenceID(scope string) (int64, error)
+}
+
+// IdempotentProducer sends messages with idempotency keys, e.g. the idempotency keys of HTTP requests, mapped to
+// deterministic sequence ids so that the broker deduplicates the messages sent again for the same key, even
+// after a restart of the process when the store is persistent.
+//
+// The deduplication must be enabled on the namespace or topic and the producer must have a fixed
+// ProducerOptions.Name, the broker deduplicates the sequence ids by producer name and partition.
+// The messages without a key or an ordering key are routed by their idempotency key so that a message sent
+// again goes to the same partition.
+//
+// The sequence id of a key is kept when the message fails to be sent since the message may have been persisted,
+// e.g. on a timeout. The broker drops a message sent again with a sequence id lower than the ones persisted
+// after it, use Forget for the keys known not to be persisted to assign them a new sequence id.
+type IdempotentProducer struct {
+	sync.Mutex
+	producer Producer
+	store    IdempotencyStore
+	// scopes are the locks of the partitions, held while a sequence id is assigned and the message is sent
+	scopes map[string]*sync.Mutex
+}
+
+// NewIdempotentProducer creates an IdempotentProducer sending the messages with the producer and keeping the
+// sequence ids of the idempotency keys in the store
+func NewIdempotentProducer(producer Producer, store IdempotencyStore) *IdempotentProducer {
+	return &IdempotentProducer{
+		producer: producer,
+		store:    store,
+		scopes:   make(map[string]*sync.Mutex),
+	}
+}
+
+// Send sends the message with the idempotency key and waits for it to be acknowledged by the broker
+func (p *IdempotentProducer) Send(ctx context.Context, key string, msg *ProducerMessage) (MessageID, error) {
+	type sendResult struct {
+		id  MessageID
+		err error
+	}
+	done := make(chan sendResult, 1)
+	p.SendAsync(ctx, key, msg, func(id MessageID, _ *ProducerMessage, err error) {
+		done <- sendResult{id: id, err: err}
+	})
+	res := <-done
+	return res.id, res.err
+}
+
+// SendAsync sends the message with the idempotency key, the callback is called once the message is
+// acknowledged by the broker or failed to be sent
+func (p *IdempotentProducer) SendAsync(ctx context.Context, key string, msg *ProducerMessage,
+	callback func(MessageID, *ProducerMessage, error)) {
+	if key == "" {
+		callback(nil, msg, newError(InvalidMessage, "the idempotency key is required"))
+		return
+	}
+	partition := p.partition(key, msg)
+
+	// the sequence ids of a partition are sent in the order they are assigned, the other partitions aren't blocked
+	unlock := p.lockScope(idempotencyScope(partition))
+	defer unlock()
+
+	sequenceID, err := p.sequenceID(partition, key)
+	if err != nil {
+		callback(nil, msg, err)
+		return
+	}
+	sent := *msg
+	sent.SequenceID = &sequenceID
+	partition.SendAsync(ctx, &sent, func(id MessageID, _ *ProducerMessage, err error) {
+		callback(id, msg, err)
+	})
+}
+
+// Forget removes the sequence id assigned to the idempotency key, the next message sent with the key is
+// assigned a new sequence id
+func (p *IdempotentProducer) Forget(key string, msg *ProducerMessage) error {
+	scope := idempotencyScope(p.partition(key, msg))
+	unlock := p.lockScope(scope)
+	defer unlock()
+	return p.store.Delete(scope, key)
+}
+
+// lockScope locks the partition of the scope and returns the function unlocking it
+func (p *IdempotentProducer) lockScope(scope string) func() {
+	p.Lock()
+	lock, ok := p.scopes[scope]
+	if !ok {
+		lock = &sync.Mutex{}
+		p.scopes[scope] = lock
+	}
+	p.Unlock()
+
+	lock.Lock()
+	return lock.Unlock
+}
+
+// sequenceID returns the sequence id of the key, assigning the next one of the partition if it has none
+func (p *IdempotentProducer) sequenceID(partition Producer, key string) (int64, error) {
+	scope := idempotencyScope(partition)
+	if sequenceID, ok, err := p.store.Get(scope, key); err != nil || ok {
+		return sequenceID, err
+	}
+
+	last, err := p.store.LastSequenceID(scope)
+	if err != nil {
+		return 0, err
+	}
+	if published := partition.LastSequenceID(); published > last {
+		last = published
+	}
+	sequenceID := last + 1
+	if err = p.store.Put(scope, key, sequenceID); err != nil {
+		return 0, err
+	}
+	return sequenceID, nil
+}
+
+// partition returns the producer of the partition the message is sent to
+func (p *IdempotentProducer) partition(key string, msg *ProducerMessage) Producer {
+	pp, ok := p.producer.(*producer)
+	if !ok {
+		return p.producer
+	}
+	if msg.Key != "" || msg.OrderingKey != "" {
+		return pp.getPartition(msg)
+	}
+	producers := *(*[]Producer)(atomic.LoadPointer(&pp.producersPtr))
+	return producers[int(pp.hashFunc(key)%uint32(len(producers)))]
+}
+
+func idempotencyScope(partition Producer) string {
+	return partition.Name() + "@" + partition.Topic()
+}
+
+// idempotencyKeys are the sequence ids assigned to the keys of a scope, ordered from the most recently used
+type idempotencyKeys struct {
+	last int64
+	keys map[string]*list.Element
+	lru  *list.List
+}
+
+type idempotencyKey struct {
+	key        string
+	sequenceID int64
+}
+
+// idempotencyRecord is a change of the log of a file store, a record without key sets the last sequence id
+type idempotencyRecord struct {
+	Scope      string `json:"scope"`
+	Key        string `json:"key,omitempty"`
+	SequenceID int64  `json:"seq"`
+	Deleted    bool   `json:"deleted,omitempty"`
+}
+
+// minCompactedRecords is the number of records of the log under which a file store is not compacted
+const minCompactedRecords = 1024
+
+type memoryIdempotencyStore struct {
+	sync.Mutex
+	maxKeys int
+	scopes  map[string]*idempotencyKeys
+	path    string
+	// records is the number of records of the log, compacted once it is twice the number of keys
+	records int
+}
+
+// NewMemoryIdempotencyStore creates an IdempotencyStore keeping the sequence ids in memory, up to maxKeys keys
+// by scope, the least recently used keys are removed first.
+// If maxKeys is 0, DefaultIdempotencyStoreMaxKeys is used, if it is negative the keys are never removed.
+func NewMemoryIdempotencyStore(maxKeys int) IdempotencyStore {
+	if maxKeys == 0 {
+		maxKeys = DefaultIdempotencyStoreMaxKeys
+	}
+	return &memoryIdempotencyStore{
+		maxKeys: maxKeys,
+		scopes:  make(map[string]*idempotencyKeys),
+	}
+}
+
+// NewFileIdempotencyStore creates an IdempotencyStore keeping the sequence ids in memory and in a file, loaded if
+// it exists. The changes are appended to the file and synced to the disk before they are applied, the file being
+// compacted when it grows. See NewMemoryIdempotencyStore for maxKeys.
+func NewFileIdempotencyStore(path string, maxKeys int) (IdempotencyStore, error) {
+	s := NewMemoryIdempotencyStore(maxKeys).(*memoryIdempotencyStore)
+
+	f, err := os.Open(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err == nil {
+		defer f.Close()
+		reader := bufio.NewReader(f)
+		for {
+			line, err := reader.ReadBytes('\n')
+			if err == io.EOF {
+				// a last record without end of line was not entirely written, it was not applied either
+				break
+			} else if err != nil {
+				return nil, err
+			}
+			var record idempotencyRecord
+			if err = json.Unmarshal(line, &record); err != nil {
+				return nil, err
+			}
+			s.apply(record)
+		}
+	}
+
+	// the file is rewritten to start the log from the keys loaded
+	s.path = path
+	if err = s.compact(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *memoryIdempotencyStore) Get(scope, key string) (int64, bool, error) {
+	s.Lock()
+	defer s.Unlock()
+	if keys, ok := s.scopes[scope]; ok {
+		if e, ok := keys.keys[key]; ok {
+			keys.lru.MoveToFront(e)
+			return e.Value.(*idempotencyKey).sequenceID, true, nil
+		}
+	}
+	return 0, false, nil
+}
+
+func (s *memoryIdempotencyStore) Put(scope, key string, sequenceID int64) error {
+	s.Lock()
+	defer s.Unlock()
+	return s.append(idempotencyRecord{Scope: scope, Key: key, SequenceID: sequenceID})
+}
+
+func (s *memoryIdempotencyStore) Delete(scope, key string) error {
+	s.Lock()
+	defer s.Unlock()
+	if keys, ok := s.scopes[scope]; !ok || keys.keys[key] == nil {
+		return nil
+	}
+	return s.append(idempotencyRecord{Scope: scope, Key: key, Deleted: true})
+}
+
+func (s *memoryIdempotencyStore) LastSequenceID(scope string) (int64, error) {
+	s.Lock()
+	defer s.Unlock()
+	if keys, ok := s.scopes[scope]; ok {
+		return keys.last, nil
+	}
+	return -1, nil
+}
+
+// append writes the record to the log of the file, if any, and applies it
+func (s *memoryIdempotencyStore) append(record idempotencyRecord) error {
+	if s.path == "" {
+		s.apply(record)
+		return nil
+	}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(append(data, '\n')); err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	s.apply(record)
+	s.records++
+
+	keys := 0
+	for _, scope := range s.scopes {
+		keys += scope.lru.Len()
+	}
+	if s.records > minCompactedRecords && s.records > 2*keys {
+		return s.compact()
+	}
+	return nil
+}
+
+func (s *memoryIdempotencyStore) apply(record idempotencyRecord) {
+	keys, ok := s.scopes[record.Scope]
+	if !ok {
+		keys = &idempotencyKeys{last: -1, keys: make(map[string]*list.Element), lru: list.New()}
+		s.scopes[record.Scope] = keys
+	}
+	if record.Key == "" {
+		if record.SequenceID > keys.last {
+			keys.last = record.SequenceID
+		}
+		return
+	}
+
+	if e, ok := keys.keys[record.Key]; ok {
+		keys.lru.Remove(e)
+		delete(keys.keys, record.Key)
+	}
+	if record.Deleted {
+		return
+	}
+	keys.keys[record.Key] = keys.lru.PushFront(&idempotencyKey{key: record.Key, sequenceID: record.SequenceID})
+	if record.SequenceID > keys.last {
+		keys.last = record.SequenceID
+	}
+	if s.maxKeys > 0 && keys.lru.Len() > s.maxKeys {
+		oldest := keys.lru.Remove(keys.lru.Back()).(*idempotencyKey)
+		delete(keys.keys, oldest.key)
+	}
+}
+
+// compact rewrites the file with the keys of the store, from the least recently used, to a temporary file first
+// to never leave a truncated file
+func (s *memoryIdempotencyStore) compact() error {
+	f, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	records := 0
+	writer := bufio.NewWriter(f)
+	encoder := json.NewEncoder(writer)
+	for scope, keys := range s.scopes {
+		records++
+		if err = encoder.Encode(idempotencyRecord{Scope: scope, SequenceID: keys.last}); err != nil {
+			break
+		}
+		for e := keys.lru.Back(); e != nil && err == nil; e = e.Prev() {
+			key := e.Value.(*idempotencyKey)
+			records++
+			err = encoder.Encode(idempotencyRecord{Scope: scope, Key: key.key, SequenceID: key.sequenceID})
+		}
+		if err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = writer.Flush()
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), s.path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	s.records = records
+	syncDir(filepath.Dir(s.path))
+	return nil
+}
+
+// syncDir syncs the directory to the disk so that a file renamed in it is durable, which is not supported by all
+// the platforms
+func syncDir(dir string) {
+	if d, err := os.Open(dir); err == nil {
+		_ = d.Sync()
+		d.Close()
+	}
+}
@@ -0,0 +1,158 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsar
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type sequenceRecordingProducer struct {
+	Producer
+	lastSequenceID int64
+	sequenceIDs    []int64
+}
+
+func (p *sequenceRecordingProducer) Name() string {
+	return "my-producer"
+}
+
+func (p *sequenceRecordingProducer) Topic() string {
+	return "my-topic"
+}
+
+func (p *sequenceRecordingProducer) LastSequenceID() int64 {
+	return p.lastSequenceID
+}
+
+func (p *sequenceRecordingProducer) SendAsync(_ context.Context, msg *ProducerMessage,
+	callback func(MessageID, *ProducerMessage, error)) {
+	p.sequenceIDs = append(p.sequenceIDs, *msg.SequenceID)
+	callback(nil, msg, nil)
+}
+
+func TestIdempotentProducer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "idempotency.log")
+	store, err := NewFileIdempotencyStore(path, 0)
+	assert.Nil(t, err)
+
+	pp := &sequenceRecordingProducer{lastSequenceID: 9}
+	p := NewIdempotentProducer(pp, store)
+	ctx := context.Background()
+
+	for _, key := range []string{"a", "b", "a", "c", "b"} {
+		_, err := p.Send(ctx, key, &ProducerMessage{Payload: []byte(key)})
+		assert.Nil(t, err)
+	}
+	// the sequence ids follow the last one published and are reused for the same key
+	assert.Equal(t, []int64{10, 11, 10, 12, 11}, pp.sequenceIDs)
+
+	_, err = p.Send(ctx, "", &ProducerMessage{})
+	assert.Error(t, err)
+
+	// the keys are kept across restarts
+	store, err = NewFileIdempotencyStore(path, 0)
+	assert.Nil(t, err)
+	pp = &sequenceRecordingProducer{lastSequenceID: -1}
+	p = NewIdempotentProducer(pp, store)
+	assert.Nil(t, p.Forget("c", &ProducerMessage{}))
+	for _, key := range []string{"b", "c", "d"} {
+		_, err := p.Send(ctx, key, &ProducerMessage{Payload: []byte(key)})
+		assert.Nil(t, err)
+	}
+	assert.Equal(t, []int64{11, 13, 14}, pp.sequenceIDs)
+}
+
+func TestMemoryIdempotencyStoreMaxKeys(t *testing.T) {
+	store := NewMemoryIdempotencyStore(2)
+	assert.Nil(t, store.Put("scope", "a", 1))
+	assert.Nil(t, store.Put("scope", "b", 2))
+	// the least recently used key is removed
+	_, ok, _ := store.Get("scope", "a")
+	assert.True(t, ok)
+	assert.Nil(t, store.Put("scope", "c", 3))
+
+	_, ok, _ = store.Get("scope", "b")
+	assert.False(t, ok)
+	id, ok, _ := store.Get("scope", "a")
+	assert.True(t, ok)
+	assert.Equal(t, int64(1), id)
+
+	last, _ := store.LastSequenceID("scope")
+	assert.Equal(t, int64(3), last)
+	last, _ = store.LastSequenceID("other")
+	assert.Equal(t, int64(-1), last)
+}
+
+func TestFileIdempotencyStoreLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "idempotency.log")
+	store, err := NewFileIdempotencyStore(path, 0)
+	assert.Nil(t, err)
+	for i := int64(0); i < 2*minCompactedRecords; i++ {
+		assert.Nil(t, store.Put("scope", "a", i))
+	}
+	assert.Nil(t, store.Put("scope", "b", 5))
+	assert.Nil(t, store.Delete("scope", "b"))
+
+	// the log is compacted once it grows, the last sequence id of a deleted key is kept
+	assert.LessOrEqual(t, store.(*memoryIdempotencyStore).records, minCompactedRecords+2)
+	assert.Nil(t, store.Put("scope", "c", 2*minCompactedRecords))
+	assert.Nil(t, store.Delete("scope", "c"))
+
+	// a record not entirely written before a crash is ignored
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0600)
+	assert.Nil(t, err)
+	_, err = f.WriteString(`{"scope":"scope","key":"d"`)
+	assert.Nil(t, err)
+	assert.Nil(t, f.Close())
+
+	store, err = NewFileIdempotencyStore(path, 0)
+	assert.Nil(t, err)
+	id, ok, _ := store.Get("scope", "a")
+	assert.True(t, ok)
+	assert.Equal(t, int64(2*minCompactedRecords-1), id)
+	for _, key := range []string{"b", "c", "d"} {
+		_, ok, _ = store.Get("scope", key)
+		assert.False(t, ok)
+	}
+	last, _ := store.LastSequenceID("scope")
+	assert.Equal(t, int64(2*minCompactedRecords), last)
+}
+
+func TestIdempotentProducerLockScope(t *testing.T) {
+	p := NewIdempotentProducer(&sequenceRecordingProducer{}, NewMemoryIdempotencyStore(0))
+	unlock := p.lockScope("a")
+
+	// a partition blocked on a send doesn't block the other partitions
+	locked := make(chan struct{})
+	go func() {
+		p.lockScope("b")()
+		close(locked)
+	}()
+	select {
+	case <-locked:
+	case <-time.After(time.Second):
+		t.Fatal("the other partition must not be blocked")
+	}
+	unlock()
+}
@@ -27,6 +27,7 @@ import (
 
 	"google.golang.org/protobuf/proto"
 
+	"github.com/apache/pulsar-client-go/pulsar/internal"
 	pb "github.com/apache/pulsar-client-go/pulsar/internal/pulsar_proto"
 	"github.com/bits-and-blooms/bitset"
 )
@@ -247,6 +248,48 @@ func toTrackingMessageID(msgID MessageID) *trackingMessageID {
 	}
 }
 
+// toStartMessageID converts the message id a reader or consumer starts from to a trackingMessageID
+func toStartMessageID(msgID MessageID) (*trackingMessageID, error) {
+	if startMessageID := toTrackingMessageID(msgID); startMessageID != nil {
+		return startMessageID, nil
+	}
+	// a custom type satisfying MessageID may not be a messageID or trackingMessageID
+	// so re-create messageID using its data
+	deserMsgID, err := deserializeMessageID(msgID.Serialize())
+	if err != nil {
+		return nil, err
+	}
+	// de-serialized MessageID is a messageID
+	return &trackingMessageID{
+		messageID:    deserMsgID.(*messageID),
+		receivedTime: time.Now(),
+	}, nil
+}
+
+// checkStartMessageIDPartition checks the start message id belongs to the topic: to the partition of a partition
+// topic name, or else to one of its numPartitions partitions. EarliestMessageID, LatestMessageID and the message ids
+// without partition belong to every topic.
+func checkStartMessageIDPartition(start *trackingMessageID, topic string, numPartitions int) error {
+	if start.partitionIdx < 0 {
+		return nil
+	}
+	tn, err := internal.ParseTopicName(topic)
+	if err != nil {
+		return err
+	}
+
+	valid := int(start.partitionIdx) < numPartitions
+	if tn.Partition >= 0 {
+		valid = int(start.partitionIdx) == tn.Partition
+	}
+	if !valid {
+		return newError(InvalidConfiguration, fmt.Sprintf(
+			"invalid StartMessageID: %v belongs to partition %d which is not a partition of %s",
+			start.messageID, start.partitionIdx, topic))
+	}
+	return nil
+}
+
 func timeFromUnixTimestampMillis(timestamp uint64) time.Time {
 	ts := int64(timestamp) * int64(time.Millisecond)
 	seconds := ts / int64(time.Second)
@@ -291,6 +334,11 @@ type message struct {
 	encryptionContext   *EncryptionContext
 	index               *uint64
 	brokerPublishTime   *time.Time
+	kafkaTimestampType  KafkaTimestampType
+	deliverAtTime       time.Time
+	propagators         []ContextPropagator
+	// rejected is set for the messages routed to the DLQ by their rejection disposition
+	rejected bool
 }
 
 func (msg *message) Topic() string {
@@ -368,6 +416,14 @@ func (msg *message) BrokerPublishTime() *time.Time {
 	return msg.brokerPublishTime
 }
 
+func (msg *message) KafkaTimestampType() KafkaTimestampType {
+	return msg.kafkaTimestampType
+}
+
+func (msg *message) DeliverAtTime() time.Time {
+	return msg.deliverAtTime
+}
+
 func newAckTracker(size uint) *ackTracker {
 	batchIDs := bitset.New(size)
 	for i := uint(0); i < size; i++ {
@@ -384,6 +440,8 @@ type ackTracker struct {
 	size           uint
 	batchIDs       *bitset.BitSet
 	prevBatchAcked uint32
+	// receiptIDs are the ids of the messages of the batch acknowledged, for the receipt of the batch
+	receiptIDs []MessageID
 }
 
 func (t *ackTracker) ack(batchID int) bool {
@@ -408,6 +466,27 @@ func (t *ackTracker) ackCumulative(batchID int) bool {
 	return t.batchIDs.None()
 }
 
+// ackHoldingReceipt acknowledges the message of the batch, cumulatively or not, and holds receiptID until the batch
+// is acknowledged. It returns the ids held once the batch is acknowledged.
+func (t *ackTracker) ackHoldingReceipt(batchID int, cumulative bool, receiptID MessageID) (bool, []MessageID) {
+	t.Lock()
+	defer t.Unlock()
+	if cumulative {
+		for i := 0; i <= batchID; i++ {
+			t.batchIDs.Clear(uint(i))
+		}
+	} else {
+		t.batchIDs.Clear(uint(batchID))
+	}
+	t.receiptIDs = append(t.receiptIDs, receiptID)
+	if !t.batchIDs.None() {
+		return false, nil
+	}
+	ids := t.receiptIDs
+	t.receiptIDs = nil
+	return true, ids
+}
+
 func (t *ackTracker) hasPrevBatchAcked() bool {
 	return atomic.LoadUint32(&t.prevBatchAcked) == 1
 }
@@ -129,3 +129,24 @@ func TestAckingMessageIDBatchTwo(t *testing.T) {
 	assert.Equal(t, true, ids[0].ack())
 	assert.Equal(t, true, tracker.completed())
 }
+
+func TestCheckStartMessageIDPartition(t *testing.T) {
+	start := func(partitionIdx int32) *trackingMessageID {
+		return newTrackingMessageID(1, 2, -1, partitionIdx, 0, nil)
+	}
+
+	assert.Nil(t, checkStartMessageIDPartition(start(-1), "my-topic", 1))
+	assert.Nil(t, checkStartMessageIDPartition(&trackingMessageID{messageID: earliestMessageID}, "my-topic", 1))
+	assert.Nil(t, checkStartMessageIDPartition(start(0), "my-topic", 1))
+	assert.Nil(t, checkStartMessageIDPartition(start(2), "my-topic", 3))
+	assert.Nil(t, checkStartMessageIDPartition(start(2), "my-topic-partition-2", 1))
+
+	for _, err := range []error{
+		checkStartMessageIDPartition(start(3), "my-topic", 3),
+		checkStartMessageIDPartition(start(1), "my-topic", 1),
+		checkStartMessageIDPartition(start(0), "my-topic-partition-2", 1),
+	} {
+		assert.Error(t, err)
+		assert.Equal(t, InvalidConfiguration, err.(*Error).Result())
+	}
+}
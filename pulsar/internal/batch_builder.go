@@ -65,6 +65,9 @@ type BatchBuilder interface {
 	// Return the batch container batch message in multiple batches.
 	IsMultiBatches() bool
 
+	// SetEncryptor sets the encryptor of the next batches, the current batches must have been flushed.
+	SetEncryptor(encryptor crypto.Encryptor)
+
 	reset()
 	Close() error
 }
@@ -134,7 +137,8 @@ func newBatchContainer(
 	}
 
 	if compressionType != pb.CompressionType_NONE {
-		bc.msgMetadata.Compression = &compressionType
+		wireCompressionType := WireCompressionType(compressionType)
+		bc.msgMetadata.Compression = &wireCompressionType
 	}
 
 	return bc
@@ -287,10 +291,27 @@ func (bc *batchContainer) IsMultiBatches() bool {
 	return false
 }
 
+func (bc *batchContainer) SetEncryptor(encryptor crypto.Encryptor) {
+	bc.encryptor = encryptor
+}
+
 func (bc *batchContainer) Close() error {
 	return bc.compressionProvider.Close()
 }
 
+// CompressionTypeLZ4Frame selects the LZ4 frame format, it is not a wire compression type: the payloads are sent
+// with the LZ4 compression type, see WireCompressionType
+const CompressionTypeLZ4Frame = pb.CompressionType(-1)
+
+// WireCompressionType returns the compression type set in the metadata of the payloads compressed with the given
+// compression type
+func WireCompressionType(compressionType pb.CompressionType) pb.CompressionType {
+	if compressionType == CompressionTypeLZ4Frame {
+		return pb.CompressionType_LZ4
+	}
+	return compressionType
+}
+
 func GetCompressionProvider(
 	compressionType pb.CompressionType,
 	level compression.Level,
@@ -300,10 +321,14 @@ func GetCompressionProvider(
 		return compression.NewNoopProvider()
 	case pb.CompressionType_LZ4:
 		return compression.NewLz4Provider()
+	case CompressionTypeLZ4Frame:
+		return compression.NewLz4FrameProvider()
 	case pb.CompressionType_ZLIB:
 		return compression.NewZLibProvider()
 	case pb.CompressionType_ZSTD:
 		return compression.NewZStdProvider(level)
+	case pb.CompressionType_SNAPPY:
+		return compression.NewSnappyProvider(level)
 	default:
 		panic("unsupported compression type")
 	}
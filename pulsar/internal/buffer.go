@@ -115,7 +115,7 @@ func (b *buffer) IsWritable() bool {
 
 func (b *buffer) Read(size uint32) []byte {
 	// Check []byte slice size, avoid slice bounds out of range
-	if b.readerIdx+size > uint32(len(b.data)) {
+	if size > uint32(len(b.data))-b.readerIdx {
 		log.Errorf("The input size [%d] > byte slice of data size [%d]", b.readerIdx+size, len(b.data))
 		return nil
 	}
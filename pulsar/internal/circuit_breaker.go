@@ -0,0 +1,212 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	pb "github.com/apache/pulsar-client-go/pulsar/internal/pulsar_proto"
+)
+
+const (
+	// DefaultCircuitBreakerThreshold is the default number of consecutive failures opening the circuit
+	DefaultCircuitBreakerThreshold = 5
+
+	// DefaultCircuitBreakerOpenDuration is the default time the circuit stays open before a probe request
+	DefaultCircuitBreakerOpenDuration = 10 * time.Second
+)
+
+// ErrCircuitOpen is returned for the requests to a broker failed fast while its circuit breaker is open
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+// CircuitState is the state of the circuit breaker of a broker
+type CircuitState int
+
+const (
+	// CircuitClosed lets the requests through
+	CircuitClosed CircuitState = iota
+	// CircuitOpen fails the requests fast
+	CircuitOpen
+	// CircuitHalfOpen lets a single probe request through, closing the circuit when it succeeds
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "Closed"
+	case CircuitOpen:
+		return "Open"
+	case CircuitHalfOpen:
+		return "HalfOpen"
+	default:
+		return "Unknown"
+	}
+}
+
+// CircuitBreakers fail fast the requests to the brokers persistently failing. The circuit of a broker opens
+// after a number of consecutive failures, and lets a probe request through once it has been open for a while.
+// A nil CircuitBreakers lets all the requests through.
+type CircuitBreakers struct {
+	sync.Mutex
+	failureThreshold int
+	openDuration     time.Duration
+	brokers          map[string]*circuit
+	onStateChange    func(broker string, state CircuitState)
+	metrics          *Metrics
+
+	// now is replaced in tests
+	now func() time.Time
+}
+
+type circuit struct {
+	state    CircuitState
+	failures int
+	openedAt time.Time
+}
+
+// NewCircuitBreakers creates the circuit breakers of the brokers, it returns nil when failureThreshold is
+// negative. onStateChange, if not nil, is called on every state change, with the lock held so in order.
+func NewCircuitBreakers(failureThreshold int, openDuration time.Duration,
+	onStateChange func(broker string, state CircuitState), metrics *Metrics) *CircuitBreakers {
+	if failureThreshold < 0 {
+		return nil
+	}
+	if failureThreshold == 0 {
+		failureThreshold = DefaultCircuitBreakerThreshold
+	}
+	if openDuration <= 0 {
+		openDuration = DefaultCircuitBreakerOpenDuration
+	}
+	return &CircuitBreakers{
+		failureThreshold: failureThreshold,
+		openDuration:     openDuration,
+		brokers:          make(map[string]*circuit),
+		onStateChange:    onStateChange,
+		metrics:          metrics,
+		now:              time.Now,
+	}
+}
+
+// Allow returns ErrCircuitOpen when the request to the broker must fail fast
+func (b *CircuitBreakers) Allow(broker string) error {
+	if b == nil {
+		return nil
+	}
+	b.Lock()
+	defer b.Unlock()
+
+	c, ok := b.brokers[broker]
+	if !ok {
+		return nil
+	}
+	switch c.state {
+	case CircuitOpen:
+		if b.now().Sub(c.openedAt) >= b.openDuration {
+			b.setState(broker, c, CircuitHalfOpen)
+			return nil
+		}
+	case CircuitHalfOpen:
+		// the probe request is in flight
+	default:
+		return nil
+	}
+
+	if b.metrics != nil {
+		b.metrics.CircuitBreakerRejected.WithLabelValues(broker).Inc()
+	}
+	return fmt.Errorf("%w: broker %s", ErrCircuitOpen, broker)
+}
+
+// Record records the outcome of a request to the broker allowed by Allow
+func (b *CircuitBreakers) Record(broker string, failed bool) {
+	if b == nil {
+		return
+	}
+
+	b.Lock()
+	defer b.Unlock()
+
+	c, ok := b.brokers[broker]
+	if !ok {
+		if !failed {
+			return
+		}
+		c = &circuit{}
+		b.brokers[broker] = c
+	}
+
+	if !failed {
+		if c.state != CircuitClosed {
+			b.setState(broker, c, CircuitClosed)
+		}
+		// forget the healthy brokers
+		delete(b.brokers, broker)
+		return
+	}
+
+	c.failures++
+	if c.state == CircuitHalfOpen || (c.state == CircuitClosed && c.failures >= b.failureThreshold) {
+		c.openedAt = b.now()
+		b.setState(broker, c, CircuitOpen)
+	}
+}
+
+// State returns the state of the circuit of the broker
+func (b *CircuitBreakers) State(broker string) CircuitState {
+	if b == nil {
+		return CircuitClosed
+	}
+	b.Lock()
+	defer b.Unlock()
+	if c, ok := b.brokers[broker]; ok {
+		return c.state
+	}
+	return CircuitClosed
+}
+
+// setState must be called with the lock held
+func (b *CircuitBreakers) setState(broker string, c *circuit, state CircuitState) {
+	c.state = state
+	if b.metrics != nil {
+		b.metrics.CircuitBreakerState.WithLabelValues(broker).Set(float64(state))
+	}
+	if b.onStateChange != nil {
+		b.onStateChange(broker, state)
+	}
+}
+
+// isBrokerFailure tells the errors of an unhealthy broker from the errors of the requests which the broker
+// answered, e.g. a topic not found or a topic being unloaded with ServiceNotReady
+func isBrokerFailure(err error) bool {
+	if errors.Is(err, ErrRequestTimeOut) || errors.Is(err, ErrConnectionClosed) ||
+		errors.Is(err, errConnectionClosed) {
+		return true
+	}
+	var serverErr *ServerError
+	if errors.As(err, &serverErr) {
+		switch serverErr.Code {
+		case pb.ServerError_UnknownError, pb.ServerError_MetadataError:
+			return true
+		}
+	}
+	return false
+}
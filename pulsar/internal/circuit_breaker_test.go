@@ -0,0 +1,155 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package internal
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/apache/pulsar-client-go/pulsar/auth"
+	pb "github.com/apache/pulsar-client-go/pulsar/internal/pulsar_proto"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestCircuitBreakers(t *testing.T) {
+	var states []CircuitState
+	now := time.Now()
+	b := NewCircuitBreakers(3, time.Minute, func(broker string, state CircuitState) {
+		assert.Equal(t, "broker-1:6650", broker)
+		states = append(states, state)
+	}, nil)
+	b.now = func() time.Time { return now }
+
+	// consecutive failures open the circuit, a success resets them
+	b.Record("broker-1:6650", true)
+	b.Record("broker-1:6650", true)
+	b.Record("broker-1:6650", false)
+	b.Record("broker-1:6650", true)
+	b.Record("broker-1:6650", true)
+	assert.Nil(t, b.Allow("broker-1:6650"))
+	b.Record("broker-1:6650", true)
+	assert.Equal(t, CircuitOpen, b.State("broker-1:6650"))
+
+	err := b.Allow("broker-1:6650")
+	assert.True(t, errors.Is(err, ErrCircuitOpen))
+	assert.Nil(t, b.Allow("broker-2:6650"))
+
+	// a single probe is let through once the circuit has been open for a while
+	now = now.Add(time.Minute)
+	assert.Nil(t, b.Allow("broker-1:6650"))
+	assert.Equal(t, CircuitHalfOpen, b.State("broker-1:6650"))
+	assert.True(t, errors.Is(b.Allow("broker-1:6650"), ErrCircuitOpen))
+
+	// the failed probe opens the circuit again
+	b.Record("broker-1:6650", true)
+	assert.Equal(t, CircuitOpen, b.State("broker-1:6650"))
+
+	now = now.Add(time.Minute)
+	assert.Nil(t, b.Allow("broker-1:6650"))
+	b.Record("broker-1:6650", false)
+	assert.Equal(t, CircuitClosed, b.State("broker-1:6650"))
+	assert.Nil(t, b.Allow("broker-1:6650"))
+
+	assert.Equal(t, []CircuitState{CircuitOpen, CircuitHalfOpen, CircuitOpen, CircuitHalfOpen, CircuitClosed}, states)
+}
+
+func TestCircuitBreakersDisabled(t *testing.T) {
+	b := NewCircuitBreakers(-1, 0, nil, nil)
+	assert.Nil(t, b)
+	b.Record("broker-1:6650", true)
+	assert.Nil(t, b.Allow("broker-1:6650"))
+	assert.Equal(t, CircuitClosed, b.State("broker-1:6650"))
+}
+
+func TestIsBrokerFailure(t *testing.T) {
+	assert.True(t, isBrokerFailure(ErrRequestTimeOut))
+	assert.True(t, isBrokerFailure(ErrConnectionClosed))
+	assert.True(t, isBrokerFailure(&ServerError{Code: pb.ServerError_MetadataError, Message: "zookeeper down"}))
+	assert.False(t, isBrokerFailure(&ServerError{Code: pb.ServerError_ServiceNotReady, Message: "bundle unloading"}))
+	assert.False(t, isBrokerFailure(&ServerError{Code: pb.ServerError_TopicNotFound, Message: "topic not found"}))
+	assert.False(t, isBrokerFailure(errors.New("server error: ServiceNotReady: bundle is being unloaded")))
+}
+
+type singleConnectionPool struct {
+	ConnectionPool
+	cnx Connection
+}
+
+func (p *singleConnectionPool) GetConnectionWithAuth(_ *url.URL, _ *url.URL, _ auth.Provider) (Connection, error) {
+	return p.cnx, nil
+}
+
+// waitingProducerConnection answers the producer requests with a producer not ready yet
+type waitingProducerConnection struct {
+	Connection
+	callback func(*pb.BaseCommand, error)
+}
+
+func (c *waitingProducerConnection) SendRequest(requestID uint64, _ *pb.BaseCommand,
+	callback func(*pb.BaseCommand, error)) {
+	c.callback = callback
+	callback(&pb.BaseCommand{
+		Type: pb.BaseCommand_PRODUCER_SUCCESS.Enum(),
+		ProducerSuccess: &pb.CommandProducerSuccess{
+			RequestId:     proto.Uint64(requestID),
+			ProducerName:  proto.String("producer"),
+			ProducerReady: proto.Bool(false),
+		},
+	}, nil)
+}
+
+func TestCircuitBreakersWaitingProducerProbe(t *testing.T) {
+	now := time.Now()
+	b := NewCircuitBreakers(1, time.Minute, nil, nil)
+	b.now = func() time.Time { return now }
+	b.Record("broker-1:6650", true)
+	now = now.Add(time.Minute)
+
+	cnx := &waitingProducerConnection{}
+	c := &rpcClient{
+		pool:            &singleConnectionPool{cnx: cnx},
+		requestTimeout:  time.Second,
+		metrics:         NewMetricsProvider(4, map[string]string{}, prometheus.NewRegistry()),
+		circuitBreakers: b,
+	}
+	done := make(chan error, 1)
+	go func() {
+		_, err := c.RequestWithAuth(nil, &url.URL{Host: "broker-1:6650"}, &url.URL{Host: "proxy:6650"}, 1,
+			pb.BaseCommand_PRODUCER, &pb.CommandProducer{})
+		done <- err
+	}()
+
+	// the producer waiting for the exclusive access closes the circuit, the broker answered the probe
+	assert.Eventually(t, func() bool { return b.State("broker-1:6650") == CircuitClosed },
+		time.Second, 10*time.Millisecond)
+	assert.Nil(t, b.Allow("broker-1:6650"))
+
+	// the wait goes on past the request timeout until the connection fails the request
+	select {
+	case err := <-done:
+		t.Fatalf("unexpected completion %v", err)
+	case <-time.After(1500 * time.Millisecond):
+	}
+	cnx.callback(nil, errConnectionClosed)
+	assert.Equal(t, errConnectionClosed, <-done)
+	assert.Equal(t, CircuitClosed, b.State("broker-1:6650"))
+}
@@ -17,17 +17,23 @@
 
 package internal
 
-import "sync"
+import (
+	"sync"
+	"time"
+)
 
 // ClientHandlerMap is a simple concurrent-safe map for the client type
 type ClientHandlers struct {
 	handlers map[Closable]bool
-	l        *sync.RWMutex
+	// addedAt is the time each handler was added at
+	addedAt map[Closable]time.Time
+	l       *sync.RWMutex
 }
 
 func NewClientHandlers() ClientHandlers {
 	return ClientHandlers{
 		handlers: map[Closable]bool{},
+		addedAt:  map[Closable]time.Time{},
 		l:        &sync.RWMutex{},
 	}
 }
@@ -35,12 +41,21 @@ func (h *ClientHandlers) Add(c Closable) {
 	h.l.Lock()
 	defer h.l.Unlock()
 	h.handlers[c] = true
+	h.addedAt[c] = time.Now()
 }
 
 func (h *ClientHandlers) Del(c Closable) {
 	h.l.Lock()
 	defer h.l.Unlock()
 	delete(h.handlers, c)
+	delete(h.addedAt, c)
+}
+
+// AddedAt returns the time the handler was added at, the zero time if it is not registered
+func (h *ClientHandlers) AddedAt(c Closable) time.Time {
+	h.l.RLock()
+	defer h.l.RUnlock()
+	return h.addedAt[c]
 }
 
 func (h *ClientHandlers) Val(c Closable) bool {
@@ -49,6 +64,17 @@ func (h *ClientHandlers) Val(c Closable) bool {
 	return h.handlers[c]
 }
 
+// Handlers returns a snapshot of the registered handlers
+func (h *ClientHandlers) Handlers() []Closable {
+	h.l.RLock()
+	defer h.l.RUnlock()
+	handlers := make([]Closable, 0, len(h.handlers))
+	for handler := range h.handlers {
+		handlers = append(handlers, handler)
+	}
+	return handlers
+}
+
 func (h *ClientHandlers) Close() {
 	h.l.Lock()
 	handlers := make([]Closable, 0, len(h.handlers))
@@ -31,6 +31,7 @@ func TestClientHandlers(t *testing.T) {
 	closable := &testClosable{h: &h, closed: false}
 	h.Add(closable)
 	assert.True(t, h.Val(closable))
+	assert.False(t, h.AddedAt(closable).IsZero())
 
 	h.Close()
 	t.Log("closable is: ", closable.closed)
@@ -54,6 +55,7 @@ func TestClientHandlers_Del(t *testing.T) {
 
 	closable1.Close()
 	assert.False(t, h.Val(closable1))
+	assert.True(t, h.AddedAt(closable1).IsZero())
 	assert.True(t, h.Val(closable2))
 	assert.Len(t, h.handlers, 1)
 
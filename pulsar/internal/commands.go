@@ -62,6 +62,15 @@ func NewMessageReaderFromArray(headersAndPayload []byte) *MessageReader {
 	return NewMessageReader(NewBufferWrapper(headersAndPayload))
 }
 
+// NewBatchMessageReader returns a reader of the messages of an uncompressed batch payload, which has no headers
+// and metadata
+func NewBatchMessageReader(payload Buffer) *MessageReader {
+	return &MessageReader{
+		buffer:  payload,
+		batched: true,
+	}
+}
+
 // MessageReader provides helper methods to parse
 // the metadata and messages from the binary format
 // Wire format for a messages
@@ -108,8 +117,10 @@ func (r *MessageReader) ReadMessageMetadata() (*pb.MessageMetadata, error) {
 		return nil, fmt.Errorf("checksum mismatch received: 0x%x computed: 0x%x", checksum, computedChecksum)
 	}
 
-	size := r.buffer.ReadUint32()
-	data := r.buffer.Read(size)
+	data, err := r.readSized()
+	if err != nil {
+		return nil, err
+	}
 	var meta pb.MessageMetadata
 	if err := proto.Unmarshal(data, &meta); err != nil {
 		return nil, ErrCorruptedMessage
@@ -123,19 +134,38 @@ func (r *MessageReader) ReadMessageMetadata() (*pb.MessageMetadata, error) {
 }
 
 func (r *MessageReader) ReadBrokerMetadata() (*pb.BrokerEntryMetadata, error) {
+	if r.buffer.ReadableBytes() < 2 {
+		return nil, nil
+	}
 	magicNumber := binary.BigEndian.Uint16(r.buffer.Get(r.buffer.ReaderIndex(), 2))
 	if magicNumber != magicBrokerEntryMetadata {
 		return nil, nil
 	}
 	r.buffer.Skip(2)
-	size := r.buffer.ReadUint32()
+	data, err := r.readSized()
+	if err != nil {
+		return nil, err
+	}
 	var brokerEntryMetadata pb.BrokerEntryMetadata
-	if err := proto.Unmarshal(r.buffer.Read(size), &brokerEntryMetadata); err != nil {
+	if err := proto.Unmarshal(data, &brokerEntryMetadata); err != nil {
 		return nil, err
 	}
 	return &brokerEntryMetadata, nil
 }
 
+// readSized reads the data following its size, it fails with ErrCorruptedMessage if the size exceeds the data
+// left
+func (r *MessageReader) readSized() ([]byte, error) {
+	if r.buffer.ReadableBytes() < 4 {
+		return nil, ErrCorruptedMessage
+	}
+	size := r.buffer.ReadUint32()
+	if size > r.buffer.ReadableBytes() {
+		return nil, ErrCorruptedMessage
+	}
+	return r.buffer.Read(size), nil
+}
+
 func (r *MessageReader) ReadMessage() (*pb.SingleMessageMetadata, []byte, error) {
 	if r.buffer.ReadableBytes() == 0 && r.buffer.Capacity() > 0 {
 		return nil, nil, ErrEOM
@@ -158,11 +188,17 @@ func (r *MessageReader) readSingleMessage() (*pb.SingleMessageMetadata, []byte,
 	// Wire format
 	// [METADATA_SIZE][METADATA][PAYLOAD]
 
-	size := r.buffer.ReadUint32()
+	data, err := r.readSized()
+	if err != nil {
+		return nil, nil, err
+	}
 	var meta pb.SingleMessageMetadata
-	if err := proto.Unmarshal(r.buffer.Read(size), &meta); err != nil {
+	if err := proto.Unmarshal(data, &meta); err != nil {
 		return nil, nil, err
 	}
+	if meta.GetPayloadSize() < 0 || uint32(meta.GetPayloadSize()) > r.buffer.ReadableBytes() {
+		return nil, nil, ErrCorruptedMessage
+	}
 
 	return &meta, r.buffer.Read(uint32(meta.GetPayloadSize())), nil
 }
@@ -228,6 +264,10 @@ func baseCommand(cmdType pb.BaseCommand_Type, msg proto.Message) *pb.BaseCommand
 		cmd.AddSubscriptionToTxn = msg.(*pb.CommandAddSubscriptionToTxn)
 	case pb.BaseCommand_END_TXN:
 		cmd.EndTxn = msg.(*pb.CommandEndTxn)
+	case pb.BaseCommand_WATCH_TOPIC_LIST:
+		cmd.WatchTopicList = msg.(*pb.CommandWatchTopicList)
+	case pb.BaseCommand_WATCH_TOPIC_LIST_CLOSE:
+		cmd.WatchTopicListClose = msg.(*pb.CommandWatchTopicListClose)
 
 	default:
 		panic(fmt.Sprintf("Missing command type: %v", cmdType))
@@ -332,7 +372,10 @@ func SingleSend(wb Buffer,
 	msgMetadata *pb.MessageMetadata,
 	compressedPayload Buffer,
 	encryptor crypto.Encryptor,
-	maxMassageSize uint32) error {
+	maxMassageSize uint32,
+	useTxn bool,
+	mostSigBits uint64,
+	leastSigBits uint64) error {
 	cmdSend := baseCommand(
 		pb.BaseCommand_SEND,
 		&pb.CommandSend{
@@ -340,6 +383,10 @@ func SingleSend(wb Buffer,
 		},
 	)
 	cmdSend.Send.SequenceId = &sequenceID
+	if useTxn {
+		cmdSend.Send.TxnidMostBits = proto.Uint64(mostSigBits)
+		cmdSend.Send.TxnidLeastBits = proto.Uint64(leastSigBits)
+	}
 	if msgMetadata.GetTotalChunkMsgSize() > 1 {
 		isChunk := true
 		cmdSend.Send.IsChunk = &isChunk
@@ -18,6 +18,7 @@
 package internal
 
 import (
+	"encoding/binary"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -144,6 +145,72 @@ func TestReadMessagesBatchSize10(t *testing.T) {
 	assert.Equal(t, ErrEOM, err)
 }
 
+// withChecksum returns the message header, with the checksum of the data, followed by the data
+func withChecksum(data []byte) []byte {
+	msg := make([]byte, 6, 6+len(data))
+	binary.BigEndian.PutUint16(msg, magicCrc32c)
+	binary.BigEndian.PutUint32(msg[2:], Crc32cCheckSum(data))
+	return append(msg, data...)
+}
+
+func TestReadCorruptedMessage(t *testing.T) {
+	for name, data := range map[string][]byte{
+		"missing metadata size":  {},
+		"metadata size overflow": {0xff, 0xff, 0xff, 0xff, 0x0a},
+		"truncated metadata":     {0x00, 0x00, 0x00, 0x10, 0x0a},
+	} {
+		_, err := NewMessageReaderFromArray(withChecksum(data)).ReadMessageMetadata()
+		assert.Equal(t, ErrCorruptedMessage, err, name)
+	}
+
+	_, err := NewMessageReaderFromArray([]byte{0x0e, 0x02, 0xff, 0xff, 0xff, 0xff}).ReadBrokerMetadata()
+	assert.Equal(t, ErrCorruptedMessage, err)
+	meta, err := NewMessageReaderFromArray([]byte{0x0e}).ReadBrokerMetadata()
+	assert.NoError(t, err)
+	assert.Nil(t, meta)
+
+	// the batch of rawBatchMessage1 follows its header and metadata, the sizes of the truncated batches exceed it
+	batch := rawBatchMessage1[10+0x1f:]
+	for _, size := range []int{2, 10, len(batch) - 1} {
+		reader := NewBatchMessageReader(NewBufferWrapper(batch[:size]))
+		_, _, err := reader.ReadMessage()
+		assert.Equal(t, ErrCorruptedMessage, err, size)
+	}
+	_, payload, err := NewBatchMessageReader(NewBufferWrapper(batch)).ReadMessage()
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", string(payload))
+}
+
+// FuzzMessageReader reads arbitrary messages and batches, which must not make the reader panic. The regression
+// corpus is in testdata/fuzz/FuzzMessageReader, run with go test -fuzz=FuzzMessageReader to explore.
+func FuzzMessageReader(f *testing.F) {
+	f.Add(rawCompatSingleMessage)
+	f.Add(rawBatchMessage1)
+	f.Add(rawBatchMessage10)
+	f.Add(brokerEntryMeta)
+	f.Add(rawBatchMessage1[10+0x1f:])
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		reader := NewMessageReaderFromArray(data)
+		if _, err := reader.ReadBrokerMetadata(); err == nil {
+			if _, err := reader.ReadMessageMetadata(); err == nil {
+				readMessages(reader)
+			}
+		}
+		// the checksum rarely matches, the batches are also read without the header and metadata
+		readMessages(NewBatchMessageReader(NewBufferWrapper(data)))
+	})
+}
+
+func readMessages(reader *MessageReader) {
+	// the reader of an empty buffer doesn't fail, each message read otherwise consumes the buffer
+	for i := 0; i < 1000; i++ {
+		if _, _, err := reader.ReadMessage(); err != nil {
+			return
+		}
+	}
+}
+
 // Raw single message in old format
 // metadata properties:<key:"a" value:"1" > properties:<key:"b" value:"2" >
 // payload = "hello"
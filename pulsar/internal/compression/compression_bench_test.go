@@ -66,6 +66,8 @@ func testDecompression(b *testing.B, provider Provider) {
 var benchmarkProviders = []testProvider{
 	{"zlib", NewZLibProvider(), nil},
 	{"lz4", NewLz4Provider(), nil},
+	{"lz4-frame", NewLz4FrameProvider(), nil},
+	{"snappy", NewSnappyProvider(Default), nil},
 	{"zstd-pure-go-fastest", newPureGoZStdProvider(Faster), nil},
 	{"zstd-pure-go-default", newPureGoZStdProvider(Default), nil},
 	{"zstd-pure-go-best", newPureGoZStdProvider(Better), nil},
@@ -18,8 +18,13 @@
 package compression
 
 import (
+	"bytes"
+	"io"
+	"math/rand"
+	"strings"
 	"testing"
 
+	"github.com/pierrec/lz4"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -36,6 +41,8 @@ var providers = []testProvider{
 	{"lz4", NewLz4Provider(), []byte{0x50, 0x68, 0x65, 0x6c, 0x6c, 0x6f}},
 	{"zstd", NewZStdProvider(Default),
 		[]byte{0x28, 0xb5, 0x2f, 0xfd, 0x20, 0x05, 0x29, 0x00, 0x00, 0x68, 0x65, 0x6c, 0x6c, 0x6f}},
+	{"snappy", NewSnappyProvider(Default), []byte{0x05, 0x10, 0x68, 0x65, 0x6c, 0x6c, 0x6f}},
+	{"lz4-frame", NewLz4FrameProvider(), []byte{0x50, 0x68, 0x65, 0x6c, 0x6c, 0x6f}},
 }
 
 func TestCompression(t *testing.T) {
@@ -88,3 +95,48 @@ func TestDecompressionError(t *testing.T) {
 		})
 	}
 }
+
+func TestLz4DecompressFrame(t *testing.T) {
+	// as compressed by the lz4 command line
+	frame := []byte{0x04, 0x22, 0x4d, 0x18, 0x64, 0x40, 0xa7, 0x05, 0x00, 0x00, 0x80, 0x68,
+		0x65, 0x6c, 0x6c, 0x6f, 0x00, 0x00, 0x00, 0x00, 0xf9, 0x77, 0x00, 0xfb}
+	uncompressed, err := NewLz4Provider().Decompress(nil, frame, len("hello"))
+	assert.Nil(t, err)
+	assert.Equal(t, "hello", string(uncompressed))
+
+	data := []byte(strings.Repeat("test compression data ", 1000))
+	var b bytes.Buffer
+	w := lz4.NewWriter(&b)
+	_, err = w.Write(data)
+	assert.Nil(t, err)
+	assert.Nil(t, w.Close())
+	uncompressed, err = NewLz4Provider().Decompress(nil, b.Bytes(), len(data))
+	assert.Nil(t, err)
+	assert.Equal(t, data, uncompressed)
+}
+
+func TestLz4CompressFrame(t *testing.T) {
+	provider := NewLz4FrameProvider()
+	random := make([]byte, 3*lz4FrameBlockSize+1)
+	rand.New(rand.NewSource(1)).Read(random)
+	for _, data := range [][]byte{
+		random,
+		nil,
+		[]byte("hello"),
+		[]byte(strings.Repeat("test compression data ", 10000)),
+		bytes.Repeat([]byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}, lz4FrameBlockSize),
+	} {
+		compressed := provider.Compress(make([]byte, 0, provider.CompressMaxSize(len(data))), data)
+		assert.True(t, isLz4Frame(compressed))
+		assert.LessOrEqual(t, len(compressed), provider.CompressMaxSize(len(data)))
+
+		// the payloads are readable by the LZ4 libraries and by the block format provider
+		uncompressed, err := io.ReadAll(lz4.NewReader(bytes.NewReader(compressed)))
+		assert.Nil(t, err)
+		assert.Equal(t, len(data), len(uncompressed))
+		uncompressed, err = NewLz4Provider().Decompress(nil, compressed, len(data))
+		assert.Nil(t, err)
+		assert.Equal(t, len(data), len(uncompressed))
+		assert.True(t, bytes.Equal(data, uncompressed))
+	}
+}
@@ -18,11 +18,18 @@
 package compression
 
 import (
+	"bytes"
+	"encoding/binary"
+	"io"
+
 	"github.com/pierrec/lz4"
 )
 
 const (
 	minLz4DestinationBufferSize = 1024 * 1024
+
+	// lz4FrameMagic starts the payloads in the LZ4 frame format, which a block can not start with
+	lz4FrameMagic = 0x184D2204
 )
 
 type lz4Provider struct {
@@ -86,16 +93,28 @@ func writeSize(size int, dst []byte) int {
 	return i + 1
 }
 
+// Decompress decompresses both the LZ4 block format of the Pulsar clients and the LZ4 frame format, e.g. of the
+// messages produced through protocol handlers
 func (lz4Provider) Decompress(dst, src []byte, originalSize int) ([]byte, error) {
 	if cap(dst) >= originalSize {
 		dst = dst[0:originalSize] // Reuse dst buffer
 	} else {
 		dst = make([]byte, originalSize)
 	}
+	if isLz4Frame(src) {
+		if _, err := io.ReadFull(lz4.NewReader(bytes.NewReader(src)), dst); err != nil {
+			return nil, err
+		}
+		return dst, nil
+	}
 	_, err := lz4.UncompressBlock(src, dst)
 	return dst, err
 }
 
+func isLz4Frame(src []byte) bool {
+	return len(src) >= 4 && binary.LittleEndian.Uint32(src) == lz4FrameMagic
+}
+
 func (lz4Provider) Close() error {
 	return nil
 }
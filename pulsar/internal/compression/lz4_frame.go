@@ -0,0 +1,73 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package compression
+
+import (
+	"bytes"
+
+	"github.com/pierrec/lz4"
+)
+
+const (
+	// lz4FrameBlockSize is the smallest block size of the LZ4 frame format, which keeps the buffers of the
+	// writer small
+	lz4FrameBlockSize = 64 * 1024
+
+	// lz4FrameOverhead is the size of the frame header without the content size, the end mark and the content
+	// checksum
+	lz4FrameOverhead = 7 + 4 + 4
+
+	// lz4FrameBlockOverhead is the size of the header of each block
+	lz4FrameBlockOverhead = 4
+)
+
+type lz4FrameProvider struct {
+	lz4Provider
+	writer *lz4.Writer
+}
+
+// NewLz4FrameProvider returns a Provider compressing in the standard LZ4 frame format, as the LZ4 libraries and
+// command line do, instead of the LZ4 block format of the Pulsar clients. It decompresses both formats.
+func NewLz4FrameProvider() Provider {
+	return &lz4FrameProvider{
+		writer: lz4.NewWriter(nil),
+	}
+}
+
+func (l *lz4FrameProvider) CompressMaxSize(originalSize int) int {
+	// the blocks which don't shrink are stored uncompressed
+	blocks := (originalSize + lz4FrameBlockSize - 1) / lz4FrameBlockSize
+	return lz4FrameOverhead + originalSize + blocks*lz4FrameBlockOverhead
+}
+
+func (l *lz4FrameProvider) Compress(dst, data []byte) []byte {
+	b := bytes.NewBuffer(dst[:0])
+	l.writer.Reset(b)
+	l.writer.Header.BlockMaxSize = lz4FrameBlockSize
+	if _, err := l.writer.Write(data); err != nil {
+		panic("Failed to compress")
+	}
+	if err := l.writer.Close(); err != nil {
+		panic("Failed to compress")
+	}
+	return b.Bytes()
+}
+
+func (l *lz4FrameProvider) Clone() Provider {
+	return NewLz4FrameProvider()
+}
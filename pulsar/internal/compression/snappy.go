@@ -0,0 +1,71 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package compression
+
+import (
+	"fmt"
+
+	"github.com/klauspost/compress/s2"
+)
+
+type snappyProvider struct {
+	level Level
+}
+
+// NewSnappyProvider returns a Provider of the Snappy block format, the format of the SNAPPY compression of the
+// Pulsar clients
+func NewSnappyProvider(level Level) Provider {
+	return &snappyProvider{level: level}
+}
+
+func (snappyProvider) CompressMaxSize(originalSize int) int {
+	return s2.MaxEncodedLen(originalSize)
+}
+
+func (s *snappyProvider) Compress(dst, src []byte) []byte {
+	switch s.level {
+	case Better:
+		return s2.EncodeSnappyBetter(dst[:cap(dst)], src)
+	default:
+		return s2.EncodeSnappy(dst[:cap(dst)], src)
+	}
+}
+
+func (snappyProvider) Decompress(dst, src []byte, originalSize int) ([]byte, error) {
+	size, err := s2.DecodedLen(src)
+	if err != nil {
+		return nil, err
+	}
+	if size != originalSize {
+		return nil, fmt.Errorf("snappy: decompressed size %d, expected %d", size, originalSize)
+	}
+	if cap(dst) >= originalSize {
+		dst = dst[0:originalSize] // Reuse dst buffer
+	} else {
+		dst = make([]byte, originalSize)
+	}
+	return s2.Decode(dst, src)
+}
+
+func (s *snappyProvider) Clone() Provider {
+	return NewSnappyProvider(s.level)
+}
+
+func (snappyProvider) Close() error {
+	return nil
+}
@@ -18,6 +18,7 @@
 package internal
 
 import (
+	"crypto"
 	"crypto/tls"
 	"crypto/x509"
 	"errors"
@@ -44,20 +45,62 @@ const (
 )
 
 type TLSOptions struct {
-	KeyFile                 string
-	CertFile                string
+	KeyFile  string
+	CertFile string
+	// KeySigner, if set, holds the private key of the certificate file instead of the key file
+	KeySigner               crypto.Signer
 	TrustCertsFilePath      string
 	AllowInsecureConnection bool
 	ValidateHostname        bool
 	ServerName              string
+	FIPSMode                bool
+	// SessionCache, if set, is shared by the connections of the client to resume the TLS sessions on reconnects
+	SessionCache tls.ClientSessionCache
+	// CertificateExpiryWarning is how long before the expiry of the client or broker certificate a warning is
+	// logged when connecting, 0 to never log it
+	CertificateExpiryWarning time.Duration
+}
+
+// clientCertificate returns the client certificate of the options, nil if there is none
+func (o *TLSOptions) clientCertificate() (*tls.Certificate, error) {
+	if o.CertFile == "" {
+		return nil, nil
+	}
+	if o.KeySigner != nil {
+		return auth.LoadX509CertificateWithSigner(o.CertFile, o.KeySigner)
+	}
+	if o.KeyFile == "" {
+		return nil, nil
+	}
+	cert, err := tls.LoadX509KeyPair(o.CertFile, o.KeyFile)
+	if err != nil {
+		return nil, errors.New(err.Error())
+	}
+	return &cert, nil
 }
 
 var (
 	errConnectionClosed        = errors.New("connection closed")
 	errUnableRegisterListener  = errors.New("unable register listener when con closed")
 	errUnableAddConsumeHandler = errors.New("unable add consumer handler when con closed")
+	errUnableAddTopicWatcher   = errors.New("unable add topic list watcher when con closed")
 )
 
+// IsConnectionClosed returns true if the request failed because its connection is closed
+func IsConnectionClosed(err error) bool {
+	return errors.Is(err, ErrConnectionClosed) || errors.Is(err, errConnectionClosed)
+}
+
+// ServerError is the error of a request the broker answered with an error code
+type ServerError struct {
+	Code    pb.ServerError
+	Message string
+}
+
+func (e *ServerError) Error() string {
+	return fmt.Sprintf("server error: %s: %s", e.Code, e.Message)
+}
+
 // ConnectionListener is a user of a connection (eg. a producer or
 // a consumer) that can register itself to get notified
 // when the connection is closed.
@@ -69,20 +112,42 @@ type ConnectionListener interface {
 	ConnectionClosed()
 }
 
+// BrokerClosedHandler is implemented by the producers and consumers recovering from the broker closing them,
+// e.g. when the bundle of their topic is unloaded, differently from the connection being closed.
+type BrokerClosedHandler interface {
+	ClosedByBroker()
+}
+
 // Connection is a interface of client cnx.
 type Connection interface {
 	SendRequest(requestID uint64, req *pb.BaseCommand, callback func(*pb.BaseCommand, error))
+	// CancelRequest drops the pending request with the given id, its callback is never invoked.
+	CancelRequest(requestID uint64)
 	SendRequestNoWait(req *pb.BaseCommand) error
 	WriteData(data Buffer)
 	RegisterListener(id uint64, listener ConnectionListener) error
 	UnregisterListener(id uint64)
 	AddConsumeHandler(id uint64, handler ConsumerHandler) error
 	DeleteConsumeHandler(id uint64)
+	// AddTopicListWatcher registers the handler of the topic list updates pushed for the watcher with the given id
+	AddTopicListWatcher(id uint64, handler TopicListHandler) error
+	DeleteTopicListWatcher(id uint64)
 	ID() string
 	GetMaxMessageSize() int32
+	GetServerInfo() ServerInfo
 	Close()
 }
 
+// ServerInfo holds the broker version and the features negotiated during the connection handshake
+type ServerInfo struct {
+	ServerVersion               string
+	ProtocolVersion             int32
+	SupportsAuthRefresh         bool
+	SupportsBrokerEntryMetadata bool
+	SupportsPartialProducer     bool
+	SupportsTopicWatchers       bool
+}
+
 type ConsumerHandler interface {
 	MessageReceived(response *pb.CommandMessage, headersAndPayload Buffer) error
 
@@ -92,6 +157,15 @@ type ConsumerHandler interface {
 	ConnectionClosed()
 }
 
+// TopicListHandler is implemented by the topic list watchers of the pattern consumers, it is notified of the
+// topics created and deleted in the watched namespace.
+type TopicListHandler interface {
+	TopicListUpdated(update *pb.CommandWatchTopicUpdate)
+
+	// ConnectionClosed close the TCP connection.
+	ConnectionClosed()
+}
+
 type connectionState int32
 
 const (
@@ -138,6 +212,8 @@ type connection struct {
 	logicalAddr  *url.URL
 	physicalAddr *url.URL
 	cnx          net.Conn
+	// address family of the connected address, ipv4 or ipv6
+	addressFamily string
 
 	writeBufferLock sync.Mutex
 	writeBuffer     Buffer
@@ -150,7 +226,7 @@ type connection struct {
 
 	incomingRequestsWG sync.WaitGroup
 	incomingRequestsCh chan *request
-	incomingCmdCh      chan *incomingCmd
+	incomingCmdCh      chan []*incomingCmd
 	closeCh            chan interface{}
 	writeRequestsCh    chan Buffer
 
@@ -163,15 +239,38 @@ type connection struct {
 	consumerHandlersLock sync.RWMutex
 	consumerHandlers     map[uint64]ConsumerHandler
 
+	watchersLock sync.RWMutex
+	watchers     map[uint64]TopicListHandler
+
 	tlsOptions *TLSOptions
 	auth       auth.Provider
 
 	maxMessageSize int32
+	serverInfo     ServerInfo
 	metrics        *Metrics
 
 	keepAliveInterval time.Duration
 
 	lastActive time.Time
+
+	// the received commands are handled by the event loop group when set, otherwise by the run loop
+	eventLoop   *eventLoopGroup
+	dispatching int32
+}
+
+// ConnectionStats is the state of a connection to a broker
+type ConnectionStats struct {
+	LogicalAddr      string    `json:"logicalAddr"`
+	PhysicalAddr     string    `json:"physicalAddr"`
+	State            string    `json:"state"`
+	ServerVersion    string    `json:"serverVersion"`
+	ProtocolVersion  int32     `json:"protocolVersion"`
+	Producers        int       `json:"producers"`
+	Consumers        int       `json:"consumers"`
+	PendingRequests  int       `json:"pendingRequests"`
+	QueuedWrites     int       `json:"queuedWrites"`
+	LastDataReceived time.Time `json:"lastDataReceived"`
+	AddressFamily    string    `json:"addressFamily,omitempty"`
 }
 
 // connectionOptions defines configurations for creating connection.
@@ -184,6 +283,7 @@ type connectionOptions struct {
 	logger            log.Logger
 	metrics           *Metrics
 	keepAliveInterval time.Duration
+	eventLoop         *eventLoopGroup
 }
 
 func newConnection(opts connectionOptions) *connection {
@@ -201,7 +301,7 @@ func newConnection(opts connectionOptions) *connection {
 
 		closeCh:            make(chan interface{}),
 		incomingRequestsCh: make(chan *request, 10),
-		incomingCmdCh:      make(chan *incomingCmd, 10),
+		incomingCmdCh:      make(chan []*incomingCmd, 10),
 
 		// This channel is used to pass data from producers to the connection
 		// go routine. It can become contended or blocking if we have multiple
@@ -211,7 +311,9 @@ func newConnection(opts connectionOptions) *connection {
 		writeRequestsCh:  make(chan Buffer, 256),
 		listeners:        make(map[uint64]ConnectionListener),
 		consumerHandlers: make(map[uint64]ConsumerHandler),
+		watchers:         make(map[uint64]TopicListHandler),
 		metrics:          opts.metrics,
+		eventLoop:        opts.eventLoop,
 	}
 	cnx.setState(connectionInit)
 	cnx.reader = newConnectionReader(cnx)
@@ -230,6 +332,7 @@ func (c *connection) start() {
 		if c.connect() {
 			if c.doHandshake() {
 				c.metrics.ConnectionsOpened.Inc()
+				c.metrics.ConnectionsOpenedByAddressFamily.WithLabelValues(c.getAddressFamily()).Inc()
 				c.run()
 			} else {
 				c.metrics.ConnectionsHandshakeErrors.Inc()
@@ -253,7 +356,7 @@ func (c *connection) connect() bool {
 
 	if c.tlsOptions == nil {
 		// Clear text connection
-		cnx, err = net.DialTimeout("tcp", c.physicalAddr.Host, c.connectionTimeout)
+		cnx, err = newHappyEyeballsDialer(c.connectionTimeout).Dial(c.physicalAddr.Host)
 	} else {
 		// TLS connection
 		tlsConfig, err = c.getTLSConfig()
@@ -262,8 +365,7 @@ func (c *connection) connect() bool {
 			return false
 		}
 
-		d := &net.Dialer{Timeout: c.connectionTimeout}
-		cnx, err = tls.DialWithDialer(d, "tcp", c.physicalAddr.Host, tlsConfig)
+		cnx, err = c.dialTLS(tlsConfig)
 	}
 
 	if err != nil {
@@ -274,6 +376,7 @@ func (c *connection) connect() bool {
 
 	c.Lock()
 	c.cnx = cnx
+	c.addressFamily = addressFamily(cnx.RemoteAddr())
 	c.log = c.log.SubLogger(log.Fields{"local_addr": c.cnx.LocalAddr()})
 	c.log.Info("TCP connection established")
 	c.Unlock()
@@ -328,6 +431,15 @@ func (c *connection) doHandshake() bool {
 		c.log.Debug("No MaxMessageSize from handshake response, use default: ", MaxMessageSize)
 		c.maxMessageSize = MaxMessageSize
 	}
+	flags := cmd.Connected.GetFeatureFlags()
+	c.serverInfo = ServerInfo{
+		ServerVersion:               cmd.Connected.GetServerVersion(),
+		ProtocolVersion:             cmd.Connected.GetProtocolVersion(),
+		SupportsAuthRefresh:         flags.GetSupportsAuthRefresh(),
+		SupportsBrokerEntryMetadata: flags.GetSupportsBrokerEntryMetadata(),
+		SupportsPartialProducer:     flags.GetSupportsPartialProducer(),
+		SupportsTopicWatchers:       flags.GetSupportsTopicWatchers(),
+	}
 	c.log.Info("Connection is ready")
 	c.changeState(connectionReady)
 	return true
@@ -413,13 +525,18 @@ func (c *connection) run() {
 		}
 	}()
 
+	incomingCmdCh := c.incomingCmdCh
+	if c.eventLoop != nil {
+		incomingCmdCh = nil
+	}
+
 	for {
 		select {
 		case <-c.closeCh:
 			return
 
-		case cmd := <-c.incomingCmdCh:
-			c.internalReceivedCommand(cmd.cmd, cmd.headersAndPayload)
+		case cmds := <-incomingCmdCh:
+			c.internalReceivedCommands(cmds)
 		case data := <-c.writeRequestsCh:
 			if data == nil {
 				return
@@ -512,8 +629,17 @@ func (c *connection) writeCommand(cmd *pb.BaseCommand) {
 	c.internalWriteData(c.writeBuffer)
 }
 
-func (c *connection) receivedCommand(cmd *pb.BaseCommand, headersAndPayload Buffer) {
-	c.incomingCmdCh <- &incomingCmd{cmd, headersAndPayload}
+func (c *connection) receivedCommands(cmds []*incomingCmd) {
+	c.incomingCmdCh <- cmds
+	if c.eventLoop != nil {
+		c.eventLoop.schedule(c)
+	}
+}
+
+func (c *connection) internalReceivedCommands(cmds []*incomingCmd) {
+	for _, cmd := range cmds {
+		c.internalReceivedCommand(cmd.cmd, cmd.headersAndPayload)
+	}
 }
 
 func (c *connection) internalReceivedCommand(cmd *pb.BaseCommand, headersAndPayload Buffer) {
@@ -597,6 +723,10 @@ func (c *connection) internalReceivedCommand(cmd *pb.BaseCommand, headersAndPayl
 		c.handleResponse(cmd.EndTxnResponse.GetRequestId(), cmd)
 	case pb.BaseCommand_ACTIVE_CONSUMER_CHANGE:
 		c.handleActiveConsumerChange(cmd.GetActiveConsumerChange())
+	case pb.BaseCommand_WATCH_TOPIC_LIST_SUCCESS:
+		c.handleResponse(cmd.WatchTopicListSuccess.GetRequestId(), cmd)
+	case pb.BaseCommand_WATCH_TOPIC_UPDATE:
+		c.handleWatchTopicUpdate(cmd.GetWatchTopicUpdate())
 
 	default:
 		c.log.Errorf("Received invalid command type: %s", cmd.Type)
@@ -641,6 +771,10 @@ func (c *connection) SendRequest(requestID uint64, req *pb.BaseCommand,
 	}
 }
 
+func (c *connection) CancelRequest(requestID uint64) {
+	c.deletePendingRequest(requestID)
+}
+
 func (c *connection) SendRequestNoWait(req *pb.BaseCommand) error {
 	c.incomingRequestsWG.Add(1)
 	defer c.incomingRequestsWG.Done()
@@ -699,8 +833,7 @@ func (c *connection) handleResponseError(serverError *pb.CommandError) {
 		return
 	}
 
-	errMsg := fmt.Sprintf("server error: %s: %s", serverError.GetError(), serverError.GetMessage())
-	request.callback(nil, errors.New(errMsg))
+	request.callback(nil, &ServerError{Code: serverError.GetError(), Message: serverError.GetMessage()})
 }
 
 func (c *connection) handleAckResponse(ackResponse *pb.CommandAckResponse) {
@@ -878,7 +1011,11 @@ func (c *connection) handleCloseConsumer(closeConsumer *pb.CommandCloseConsumer)
 	c.log.Infof("Broker notification of Closed consumer: %d", consumerID)
 
 	if consumer, ok := c.consumerHandler(consumerID); ok {
-		consumer.ConnectionClosed()
+		if handler, ok := consumer.(BrokerClosedHandler); ok {
+			handler.ClosedByBroker()
+		} else {
+			consumer.ConnectionClosed()
+		}
 		c.DeleteConsumeHandler(consumerID)
 	} else {
 		c.log.WithField("consumerID", consumerID).Warnf("Consumer with ID not found while closing consumer")
@@ -895,13 +1032,27 @@ func (c *connection) handleActiveConsumerChange(consumerChange *pb.CommandActive
 	}
 }
 
+func (c *connection) handleWatchTopicUpdate(update *pb.CommandWatchTopicUpdate) {
+	watcherID := update.GetWatcherId()
+	c.watchersLock.RLock()
+	watcher, ok := c.watchers[watcherID]
+	c.watchersLock.RUnlock()
+	if ok {
+		watcher.TopicListUpdated(update)
+	} else {
+		c.log.WithField("watcherID", watcherID).Warn("Topic list watcher not found while topic list update")
+	}
+}
+
 func (c *connection) handleCloseProducer(closeProducer *pb.CommandCloseProducer) {
 	c.log.Infof("Broker notification of Closed producer: %d", closeProducer.GetProducerId())
 	producerID := closeProducer.GetProducerId()
 
 	producer, ok := c.deletePendingProducers(producerID)
 	// did we find a producer?
-	if ok {
+	if handler, isHandler := producer.(BrokerClosedHandler); ok && isHandler {
+		handler.ClosedByBroker()
+	} else if ok {
 		producer.ConnectionClosed()
 	} else {
 		c.log.WithField("producerID", producerID).Warn("Producer with ID not found while closing producer")
@@ -960,6 +1111,14 @@ func (c *connection) isIdle() bool {
 		}
 	}
 
+	{
+		c.watchersLock.RLock()
+		defer c.watchersLock.RUnlock()
+		if len(c.watchers) != 0 {
+			return false
+		}
+	}
+
 	if len(c.incomingRequestsCh) != 0 || len(c.writeRequestsCh) != 0 {
 		return false
 	}
@@ -1007,6 +1166,14 @@ func (c *connection) Close() {
 		}
 		c.consumerHandlersLock.Unlock()
 
+		watchers := make(map[uint64]TopicListHandler)
+		c.watchersLock.Lock()
+		for id, watcher := range c.watchers {
+			watchers[id] = watcher
+			delete(c.watchers, id)
+		}
+		c.watchersLock.Unlock()
+
 		// notify producers connection closed
 		for _, listener := range listeners {
 			listener.ConnectionClosed()
@@ -1017,6 +1184,11 @@ func (c *connection) Close() {
 			handler.ConnectionClosed()
 		}
 
+		// notify topic list watchers connection closed
+		for _, watcher := range watchers {
+			watcher.ConnectionClosed()
+		}
+
 		c.metrics.ConnectionsClosed.Inc()
 	})
 }
@@ -1043,9 +1215,77 @@ func (c *connection) closed() bool {
 	return connectionClosed == c.getState()
 }
 
+// dialTLS opens a TLS connection like tls.DialWithDialer, recording the duration of the TLS handshake
+func (c *connection) dialTLS(tlsConfig *tls.Config) (net.Conn, error) {
+	rawConn, err := newHappyEyeballsDialer(c.connectionTimeout).Dial(c.physicalAddr.Host)
+	if err != nil {
+		return nil, err
+	}
+
+	if tlsConfig.ServerName == "" {
+		tlsConfig.ServerName = c.physicalAddr.Hostname()
+	}
+	if c.connectionTimeout > 0 {
+		rawConn.SetDeadline(time.Now().Add(c.connectionTimeout))
+	}
+
+	start := time.Now()
+	tlsConn := tls.Client(rawConn, tlsConfig)
+	if err := tlsConn.Handshake(); err != nil {
+		rawConn.Close()
+		return nil, err
+	}
+	rawConn.SetDeadline(time.Time{})
+
+	c.metrics.TLSHandshakeLatency.Observe(time.Since(start).Seconds())
+	if tlsConn.ConnectionState().DidResume {
+		c.metrics.TLSSessionsResumed.Inc()
+	}
+	c.checkCertificatesExpiry(tlsConfig, tlsConn.ConnectionState())
+	return tlsConn, nil
+}
+
+// checkCertificatesExpiry exports the expiry of the client certificate and of the certificate served by the
+// broker, and warns when either one expires soon
+func (c *connection) checkCertificatesExpiry(tlsConfig *tls.Config, state tls.ConnectionState) {
+	broker := c.physicalAddr.Host
+	if len(tlsConfig.Certificates) > 0 && len(tlsConfig.Certificates[0].Certificate) > 0 {
+		leaf := tlsConfig.Certificates[0].Leaf
+		if leaf == nil {
+			var err error
+			if leaf, err = x509.ParseCertificate(tlsConfig.Certificates[0].Certificate[0]); err != nil {
+				c.log.WithError(err).Warn("Failed to parse the client certificate")
+			}
+		}
+		if leaf != nil {
+			c.metrics.TLSCertificateExpiry.WithLabelValues("client", "").Set(float64(leaf.NotAfter.Unix()))
+			c.warnCertificateExpiry("client", leaf)
+		}
+	}
+
+	if len(state.PeerCertificates) > 0 {
+		leaf := state.PeerCertificates[0]
+		c.metrics.TLSCertificateExpiry.WithLabelValues("broker", broker).Set(float64(leaf.NotAfter.Unix()))
+		c.warnCertificateExpiry("broker", leaf)
+	}
+}
+
+func (c *connection) warnCertificateExpiry(certificate string, leaf *x509.Certificate) {
+	if c.tlsOptions == nil || c.tlsOptions.CertificateExpiryWarning <= 0 {
+		return
+	}
+	warning := c.tlsOptions.CertificateExpiryWarning
+	if remaining := time.Until(leaf.NotAfter); remaining < warning {
+		c.log.WithField("subject", leaf.Subject.String()).
+			Warnf("The %s certificate expires on %s, in %s", certificate, leaf.NotAfter.Format(time.RFC3339),
+				remaining.Round(time.Minute))
+	}
+}
+
 func (c *connection) getTLSConfig() (*tls.Config, error) {
 	tlsConfig := &tls.Config{
 		InsecureSkipVerify: c.tlsOptions.AllowInsecureConnection,
+		ClientSessionCache: c.tlsOptions.SessionCache,
 	}
 
 	if c.tlsOptions.TrustCertsFilePath != "" {
@@ -1070,15 +1310,15 @@ func (c *connection) getTLSConfig() (*tls.Config, error) {
 		c.log.Debugf("getTLSConfig(): setting tlsConfig.ServerName = %+v", tlsConfig.ServerName)
 	}
 
-	if c.tlsOptions.CertFile != "" && c.tlsOptions.KeyFile != "" {
-		cert, err := tls.LoadX509KeyPair(c.tlsOptions.CertFile, c.tlsOptions.KeyFile)
-		if err != nil {
-			return nil, errors.New(err.Error())
-		}
-		tlsConfig.Certificates = []tls.Certificate{cert}
+	cert, err := c.tlsOptions.clientCertificate()
+	if err != nil {
+		return nil, err
+	}
+	if cert != nil {
+		tlsConfig.Certificates = []tls.Certificate{*cert}
 	}
 
-	cert, err := c.auth.GetTLSCertificate()
+	cert, err = c.auth.GetTLSCertificate()
 	if err != nil {
 		return nil, err
 	}
@@ -1087,6 +1327,12 @@ func (c *connection) getTLSConfig() (*tls.Config, error) {
 		tlsConfig.Certificates = []tls.Certificate{*cert}
 	}
 
+	if c.tlsOptions.FIPSMode {
+		if err := applyFIPS(tlsConfig); err != nil {
+			return nil, err
+		}
+	}
+
 	return tlsConfig, nil
 }
 
@@ -1109,6 +1355,25 @@ func (c *connection) DeleteConsumeHandler(id uint64) {
 	delete(c.consumerHandlers, id)
 }
 
+func (c *connection) AddTopicListWatcher(id uint64, handler TopicListHandler) error {
+	// do not add if connection is closed
+	if c.closed() {
+		c.log.Warnf("Closed connection unable add topic list watcher with id=%+v", id)
+		return errUnableAddTopicWatcher
+	}
+
+	c.watchersLock.Lock()
+	defer c.watchersLock.Unlock()
+	c.watchers[id] = handler
+	return nil
+}
+
+func (c *connection) DeleteTopicListWatcher(id uint64) {
+	c.watchersLock.Lock()
+	defer c.watchersLock.Unlock()
+	delete(c.watchers, id)
+}
+
 func (c *connection) consumerHandler(id uint64) (ConsumerHandler, bool) {
 	c.consumerHandlersLock.RLock()
 	defer c.consumerHandlersLock.RUnlock()
@@ -1123,3 +1388,45 @@ func (c *connection) ID() string {
 func (c *connection) GetMaxMessageSize() int32 {
 	return c.maxMessageSize
 }
+
+// getAddressFamily returns the address family of the connected broker address, empty if it is not a TCP address
+func (c *connection) getAddressFamily() string {
+	c.Lock()
+	defer c.Unlock()
+	return c.addressFamily
+}
+
+func (c *connection) stats() ConnectionStats {
+	serverInfo := c.GetServerInfo()
+
+	c.listenersLock.RLock()
+	producers := len(c.listeners)
+	c.listenersLock.RUnlock()
+
+	c.consumerHandlersLock.RLock()
+	consumers := len(c.consumerHandlers)
+	c.consumerHandlersLock.RUnlock()
+
+	c.pendingLock.Lock()
+	pendingRequests := len(c.pendingReqs)
+	c.pendingLock.Unlock()
+
+	return ConnectionStats{
+		LogicalAddr:      c.logicalAddr.String(),
+		PhysicalAddr:     c.physicalAddr.String(),
+		State:            c.getState().String(),
+		ServerVersion:    serverInfo.ServerVersion,
+		ProtocolVersion:  serverInfo.ProtocolVersion,
+		Producers:        producers,
+		Consumers:        consumers,
+		PendingRequests:  pendingRequests,
+		QueuedWrites:     len(c.writeRequestsCh),
+		LastDataReceived: c.lastDataReceived(),
+		AddressFamily:    c.getAddressFamily(),
+	}
+}
+
+// GetServerInfo returns the broker version and features negotiated in the handshake
+func (c *connection) GetServerInfo() ServerInfo {
+	return c.serverInfo
+}
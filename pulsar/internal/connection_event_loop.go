@@ -0,0 +1,176 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package internal
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// maxBatchesPerDispatch is the number of batches of commands of a connection handled before giving the turn to
+// the other connections
+const maxBatchesPerDispatch = 16
+
+// defaultBlockedDispatchTimeout is the time after which a goroutine still handling the commands of a connection is
+// considered blocked, e.g. by a consumer whose queue is full or by a send callback, and is replaced
+const defaultBlockedDispatchTimeout = 100 * time.Millisecond
+
+// eventLoopGroup handles the commands received on the connections with a fixed number of goroutines, instead of
+// one goroutine per connection. A connection with received commands is queued once and taken by the first idle
+// goroutine, which handles its commands in order; a busy connection is queued again after a few batches so it
+// doesn't hold back the others. A goroutine blocked by the handling of a command is replaced by a new one, so that
+// the other connections keep being handled, and exits once unblocked.
+type eventLoopGroup struct {
+	readyCh   chan *connection
+	closeCh   chan struct{}
+	closeOnce sync.Once
+
+	blockedTimeout time.Duration
+	loopsLock      sync.Mutex
+	loops          map[*eventLoop]struct{}
+}
+
+// eventLoop is the state of one goroutine of the group
+type eventLoop struct {
+	// dispatchStart is the time in nanoseconds the dispatch in progress started, 0 when idle
+	dispatchStart int64
+	// replaced is set when the goroutine was replaced while blocked
+	replaced int32
+}
+
+func newEventLoopGroup(size int) *eventLoopGroup {
+	return newEventLoopGroupWithTimeout(size, defaultBlockedDispatchTimeout)
+}
+
+func newEventLoopGroupWithTimeout(size int, blockedTimeout time.Duration) *eventLoopGroup {
+	g := &eventLoopGroup{
+		readyCh:        make(chan *connection, 1024),
+		closeCh:        make(chan struct{}),
+		blockedTimeout: blockedTimeout,
+		loops:          make(map[*eventLoop]struct{}, size),
+	}
+	g.loopsLock.Lock()
+	for i := 0; i < size; i++ {
+		g.startLocked()
+	}
+	g.loopsLock.Unlock()
+	go g.watch()
+	return g
+}
+
+func (g *eventLoopGroup) startLocked() {
+	l := &eventLoop{}
+	g.loops[l] = struct{}{}
+	go g.run(l)
+}
+
+func (g *eventLoopGroup) run(l *eventLoop) {
+	defer func() {
+		g.loopsLock.Lock()
+		delete(g.loops, l)
+		g.loopsLock.Unlock()
+	}()
+	for {
+		select {
+		case <-g.closeCh:
+			return
+		case c := <-g.readyCh:
+			more := g.dispatch(l, c)
+			for more && !g.requeue(c) {
+				more = g.dispatch(l, c)
+			}
+			if atomic.LoadInt32(&l.replaced) == 1 {
+				// a new goroutine took over while this one was blocked
+				return
+			}
+		}
+	}
+}
+
+func (g *eventLoopGroup) dispatch(l *eventLoop, c *connection) bool {
+	atomic.StoreInt64(&l.dispatchStart, time.Now().UnixNano())
+	defer atomic.StoreInt64(&l.dispatchStart, 0)
+	return c.dispatchReceivedCommands()
+}
+
+// watch replaces the goroutines blocked for longer than blockedTimeout
+func (g *eventLoopGroup) watch() {
+	ticker := time.NewTicker(g.blockedTimeout / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-g.closeCh:
+			return
+		case now := <-ticker.C:
+			g.loopsLock.Lock()
+			for l := range g.loops {
+				start := atomic.LoadInt64(&l.dispatchStart)
+				if start != 0 && now.UnixNano()-start > int64(g.blockedTimeout) &&
+					atomic.CompareAndSwapInt32(&l.replaced, 0, 1) {
+					delete(g.loops, l)
+					g.startLocked()
+				}
+			}
+			g.loopsLock.Unlock()
+		}
+	}
+}
+
+// requeue queues the connection behind the other ready connections, it fails when the queue is full
+func (g *eventLoopGroup) requeue(c *connection) bool {
+	select {
+	case g.readyCh <- c:
+		return true
+	default:
+		return false
+	}
+}
+
+// schedule queues the connection to have its received commands handled, unless it already is
+func (g *eventLoopGroup) schedule(c *connection) {
+	if !atomic.CompareAndSwapInt32(&c.dispatching, 0, 1) {
+		return
+	}
+	select {
+	case g.readyCh <- c:
+	case <-g.closeCh:
+	}
+}
+
+func (g *eventLoopGroup) close() {
+	g.closeOnce.Do(func() {
+		close(g.closeCh)
+	})
+}
+
+// dispatchReceivedCommands handles the batches of commands received on the connection, and returns true when there
+// are more to handle
+func (c *connection) dispatchReceivedCommands() bool {
+	for i := 0; i < maxBatchesPerDispatch; i++ {
+		select {
+		case cmds := <-c.incomingCmdCh:
+			c.internalReceivedCommands(cmds)
+		default:
+			atomic.StoreInt32(&c.dispatching, 0)
+			// a batch may have been received before the flag was reset
+			return len(c.incomingCmdCh) > 0 && atomic.CompareAndSwapInt32(&c.dispatching, 0, 1)
+		}
+	}
+	return true
+}
@@ -0,0 +1,198 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package internal
+
+import (
+	"encoding/binary"
+	"net"
+	"net/url"
+	"testing"
+	"time"
+
+	pb "github.com/apache/pulsar-client-go/pulsar/internal/pulsar_proto"
+	"github.com/apache/pulsar-client-go/pulsar/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+)
+
+type recordingConsumerHandler struct {
+	entriesCh chan uint64
+}
+
+func (h *recordingConsumerHandler) MessageReceived(response *pb.CommandMessage, _ Buffer) error {
+	h.entriesCh <- response.GetMessageId().GetEntryId()
+	return nil
+}
+
+func (h *recordingConsumerHandler) ActiveConsumerChanged(bool) {}
+
+func (h *recordingConsumerHandler) ConnectionClosed() {}
+
+func newTestConnection(eventLoop *eventLoopGroup) *connection {
+	return newConnection(connectionOptions{
+		logicalAddr:  &url.URL{Scheme: "pulsar", Host: "localhost:6650"},
+		physicalAddr: &url.URL{Scheme: "pulsar", Host: "localhost:6650"},
+		logger:       log.DefaultNopLogger(),
+		metrics:      NewMetricsProvider(4, map[string]string{}, prometheus.NewRegistry()),
+		eventLoop:    eventLoop,
+	})
+}
+
+func newTestMessageCommand(consumerID uint64, entryID uint64) *pb.BaseCommand {
+	return &pb.BaseCommand{
+		Type: pb.BaseCommand_MESSAGE.Enum(),
+		Message: &pb.CommandMessage{
+			ConsumerId: proto.Uint64(consumerID),
+			MessageId:  &pb.MessageIdData{LedgerId: proto.Uint64(1), EntryId: proto.Uint64(entryID)},
+		},
+	}
+}
+
+func TestConnectionReaderBatchesFrames(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+
+	c := newTestConnection(nil)
+	c.cnx = client
+	c.reader = newConnectionReader(c)
+	go c.reader.readFromConnection()
+
+	var frames []byte
+	for i := 0; i < 3; i++ {
+		data, err := proto.Marshal(baseCommand(pb.BaseCommand_PONG, &pb.CommandPong{}))
+		assert.Nil(t, err)
+		frame := make([]byte, 8, 8+len(data))
+		binary.BigEndian.PutUint32(frame, uint32(len(data)+4))
+		binary.BigEndian.PutUint32(frame[4:], uint32(len(data)))
+		frames = append(frames, append(frame, data...)...)
+	}
+	_, err := server.Write(frames)
+	assert.Nil(t, err)
+
+	// the frames read at once are handed over in one batch
+	select {
+	case cmds := <-c.incomingCmdCh:
+		assert.Len(t, cmds, 3)
+		for _, cmd := range cmds {
+			assert.Equal(t, pb.BaseCommand_PONG, cmd.cmd.GetType())
+		}
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "no command received")
+	}
+}
+
+func TestEventLoopGroup(t *testing.T) {
+	g := newEventLoopGroup(2)
+	defer g.close()
+
+	const numConnections = 4
+	const numCommands = 100
+	handlers := make([]*recordingConsumerHandler, numConnections)
+	for i := range handlers {
+		handlers[i] = &recordingConsumerHandler{entriesCh: make(chan uint64, numCommands)}
+		c := newTestConnection(g)
+		assert.Nil(t, c.AddConsumeHandler(1, handlers[i]))
+		go func() {
+			for entryID := uint64(0); entryID < numCommands; entryID += 2 {
+				c.receivedCommands([]*incomingCmd{
+					{cmd: newTestMessageCommand(1, entryID)},
+					{cmd: newTestMessageCommand(1, entryID+1)},
+				})
+			}
+		}()
+	}
+
+	// the commands of each connection are handled in order
+	for _, h := range handlers {
+		for expected := uint64(0); expected < numCommands; expected++ {
+			select {
+			case entryID := <-h.entriesCh:
+				assert.Equal(t, expected, entryID)
+			case <-time.After(5 * time.Second):
+				assert.FailNow(t, "commands not handled")
+			}
+		}
+	}
+}
+
+func TestConnectionCancelRequest(t *testing.T) {
+	c := newTestConnection(nil)
+	called := false
+	c.pendingReqs[1] = &request{id: proto.Uint64(1), callback: func(*pb.BaseCommand, error) { called = true }}
+
+	c.CancelRequest(1)
+	_, ok := c.findPendingRequest(1)
+	assert.False(t, ok)
+
+	c.handleResponse(1, &pb.BaseCommand{Type: pb.BaseCommand_LOOKUP_RESPONSE.Enum()})
+	assert.False(t, called)
+}
+
+type blockingConsumerHandler struct {
+	recordingConsumerHandler
+	unblockCh chan struct{}
+}
+
+func (h *blockingConsumerHandler) MessageReceived(response *pb.CommandMessage, buffer Buffer) error {
+	<-h.unblockCh
+	return h.recordingConsumerHandler.MessageReceived(response, buffer)
+}
+
+func TestEventLoopGroupBlockedConnection(t *testing.T) {
+	g := newEventLoopGroupWithTimeout(1, 20*time.Millisecond)
+	defer g.close()
+
+	// e.g. a consumer whose queue is full
+	blocked := &blockingConsumerHandler{
+		recordingConsumerHandler: recordingConsumerHandler{entriesCh: make(chan uint64, 2)},
+		unblockCh:                make(chan struct{}),
+	}
+	c1 := newTestConnection(g)
+	assert.Nil(t, c1.AddConsumeHandler(1, blocked))
+	c1.receivedCommands([]*incomingCmd{{cmd: newTestMessageCommand(1, 0)}})
+	c1.receivedCommands([]*incomingCmd{{cmd: newTestMessageCommand(1, 1)}})
+
+	// the other connections are handled while the only goroutine of the group is blocked
+	handler := &recordingConsumerHandler{entriesCh: make(chan uint64, 1)}
+	c2 := newTestConnection(g)
+	assert.Nil(t, c2.AddConsumeHandler(1, handler))
+	c2.receivedCommands([]*incomingCmd{{cmd: newTestMessageCommand(1, 0)}})
+	select {
+	case entryID := <-handler.entriesCh:
+		assert.Equal(t, uint64(0), entryID)
+	case <-time.After(5 * time.Second):
+		assert.FailNow(t, "commands not handled")
+	}
+
+	// the blocked connection resumes in order
+	close(blocked.unblockCh)
+	for expected := uint64(0); expected < 2; expected++ {
+		select {
+		case entryID := <-blocked.entriesCh:
+			assert.Equal(t, expected, entryID)
+		case <-time.After(5 * time.Second):
+			assert.FailNow(t, "commands not handled")
+		}
+	}
+	assert.Eventually(t, func() bool {
+		g.loopsLock.Lock()
+		defer g.loopsLock.Unlock()
+		return len(g.loops) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+}
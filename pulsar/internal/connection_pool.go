@@ -34,6 +34,14 @@ type ConnectionPool interface {
 	// GetConnection get a connection from ConnectionPool.
 	GetConnection(logicalAddr *url.URL, physicalAddr *url.URL) (Connection, error)
 
+	// GetConnectionWithAuth gets a connection authenticated with the given provider instead of the provider of
+	// the pool, the connections of each provider are separate. The provider must be initialized and comparable,
+	// e.g. a pointer, a nil provider is the provider of the pool.
+	GetConnectionWithAuth(logicalAddr *url.URL, physicalAddr *url.URL, provider auth.Provider) (Connection, error)
+
+	// Stats returns the state of the connections in the pool
+	Stats() []ConnectionStats
+
 	// Close all the connections in the pool
 	Close()
 }
@@ -48,6 +56,11 @@ type connectionPool struct {
 	roundRobinCnt         int32
 	keepAliveInterval     time.Duration
 	closeCh               chan struct{}
+	eventLoop             *eventLoopGroup
+	// ids of the providers of GetConnectionWithAuth in the keys of the connections
+	authIDs map[auth.Provider]int
+	// endpointFilter is consulted before connecting to an address, nil to connect to any
+	endpointFilter EndpointFilter
 
 	metrics *Metrics
 	log     log.Logger
@@ -56,32 +69,54 @@ type connectionPool struct {
 // NewConnectionPool init connection pool.
 func NewConnectionPool(
 	tlsOptions *TLSOptions,
-	auth auth.Provider,
+	authProvider auth.Provider,
 	connectionTimeout time.Duration,
 	keepAliveInterval time.Duration,
 	maxConnectionsPerHost int,
 	logger log.Logger,
 	metrics *Metrics,
-	connectionMaxIdleTime time.Duration) ConnectionPool {
+	connectionMaxIdleTime time.Duration,
+	ioThreads int,
+	endpointFilter EndpointFilter) ConnectionPool {
 	p := &connectionPool{
 		connections:           make(map[string]*connection),
+		authIDs:               make(map[auth.Provider]int),
 		tlsOptions:            tlsOptions,
-		auth:                  auth,
+		auth:                  authProvider,
 		connectionTimeout:     connectionTimeout,
 		maxConnectionsPerHost: int32(maxConnectionsPerHost),
 		keepAliveInterval:     keepAliveInterval,
 		log:                   logger,
 		metrics:               metrics,
 		closeCh:               make(chan struct{}),
+		endpointFilter:        endpointFilter,
+	}
+	if ioThreads > 0 {
+		p.eventLoop = newEventLoopGroup(ioThreads)
 	}
 	go p.checkAndCleanIdleConnections(connectionMaxIdleTime)
 	return p
 }
 
 func (p *connectionPool) GetConnection(logicalAddr *url.URL, physicalAddr *url.URL) (Connection, error) {
+	return p.GetConnectionWithAuth(logicalAddr, physicalAddr, nil)
+}
+
+func (p *connectionPool) GetConnectionWithAuth(logicalAddr *url.URL, physicalAddr *url.URL,
+	provider auth.Provider) (Connection, error) {
 	key := p.getMapKey(logicalAddr)
 
 	p.Lock()
+	if provider == nil || provider == p.auth {
+		provider = p.auth
+	} else {
+		id, ok := p.authIDs[provider]
+		if !ok {
+			id = len(p.authIDs) + 1
+			p.authIDs[provider] = id
+		}
+		key = fmt.Sprintf("%s-auth-%d", key, id)
+	}
 	conn, ok := p.connections[key]
 	if ok {
 		p.log.Debugf("Found connection in pool key=%s logical_addr=%+v physical_addr=%+v",
@@ -100,15 +135,20 @@ func (p *connectionPool) GetConnection(logicalAddr *url.URL, physicalAddr *url.U
 	}
 
 	if conn == nil {
+		if err := p.checkEndpoint(physicalAddr); err != nil {
+			p.Unlock()
+			return nil, err
+		}
 		conn = newConnection(connectionOptions{
 			logicalAddr:       logicalAddr,
 			physicalAddr:      physicalAddr,
 			tls:               p.tlsOptions,
 			connectionTimeout: p.connectionTimeout,
-			auth:              p.auth,
+			auth:              provider,
 			keepAliveInterval: p.keepAliveInterval,
 			logger:            p.log,
 			metrics:           p.metrics,
+			eventLoop:         p.eventLoop,
 		})
 		p.connections[key] = conn
 		p.Unlock()
@@ -123,6 +163,21 @@ func (p *connectionPool) GetConnection(logicalAddr *url.URL, physicalAddr *url.U
 	return conn, err
 }
 
+func (p *connectionPool) Stats() []ConnectionStats {
+	p.Lock()
+	connections := make([]*connection, 0, len(p.connections))
+	for _, c := range p.connections {
+		connections = append(connections, c)
+	}
+	p.Unlock()
+
+	stats := make([]ConnectionStats, 0, len(connections))
+	for _, c := range connections {
+		stats = append(stats, c.stats())
+	}
+	return stats
+}
+
 func (p *connectionPool) Close() {
 	p.Lock()
 	close(p.closeCh)
@@ -130,6 +185,9 @@ func (p *connectionPool) Close() {
 		delete(p.connections, k)
 		c.Close()
 	}
+	if p.eventLoop != nil {
+		p.eventLoop.close()
+	}
 	p.Unlock()
 }
 
@@ -19,6 +19,9 @@ package internal
 
 import (
 	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 
@@ -26,6 +29,18 @@ import (
 	"google.golang.org/protobuf/proto"
 )
 
+const (
+	// maxCommandsPerRead is the maximum number of commands decoded from the data read at once
+	maxCommandsPerRead = 64
+
+	// malformedFrameDumpSize is the number of bytes of a malformed frame logged to diagnose it
+	malformedFrameDumpSize = 64
+)
+
+// errMalformedFrame is the error of the frames which can't be decoded, e.g. sent by a misbehaving proxy, the
+// connection is closed since the following frames can't be trusted either
+var errMalformedFrame = errors.New("malformed frame")
+
 type connectionReader struct {
 	cnx    *connection
 	buffer Buffer
@@ -42,25 +57,42 @@ func newConnectionReader(cnx *connection) *connectionReader {
 
 func (r *connectionReader) readFromConnection() {
 	for {
-		cmd, headersAndPayload, err := r.readSingleCommand()
-		if err != nil {
-			if !r.cnx.closed() {
-				r.cnx.log.WithError(err).Infof("Error reading from connection")
-				r.cnx.Close()
+		// decode all the complete frames already read, so they are handed over in one batch
+		cmds := make([]*incomingCmd, 0, 1)
+		for len(cmds) == 0 || (len(cmds) < maxCommandsPerRead && r.hasCompleteFrame()) {
+			cmd, headersAndPayload, err := r.readSingleCommand()
+			if err != nil {
+				if !r.cnx.closed() {
+					r.cnx.log.WithError(err).Infof("Error reading from connection")
+					r.cnx.Close()
+				}
+				return
 			}
-			break
+
+			var payloadLen uint32
+			if headersAndPayload != nil {
+				payloadLen = headersAndPayload.ReadableBytes()
+			}
+			r.cnx.log.Debug("Got command! ", cmd, " with payload size: ", payloadLen,
+				" maxMsgSize: ", r.cnx.maxMessageSize)
+			cmds = append(cmds, &incomingCmd{cmd, headersAndPayload})
 		}
 
 		// Process
-		var payloadLen uint32
-		if headersAndPayload != nil {
-			payloadLen = headersAndPayload.ReadableBytes()
-		}
-		r.cnx.log.Debug("Got command! ", cmd, " with payload size: ", payloadLen, " maxMsgSize: ", r.cnx.maxMessageSize)
-		r.cnx.receivedCommand(cmd, headersAndPayload)
+		r.cnx.receivedCommands(cmds)
 	}
 }
 
+// hasCompleteFrame reports whether a complete frame can be read without reading from the connection
+func (r *connectionReader) hasCompleteFrame() bool {
+	readable := r.buffer.ReadableBytes()
+	if readable < 4 {
+		return false
+	}
+	frameSize := binary.BigEndian.Uint32(r.buffer.ReadableSlice()[:4])
+	return readable-4 >= frameSize
+}
+
 func (r *connectionReader) readSingleCommand() (cmd *pb.BaseCommand, headersAndPayload Buffer, err error) {
 	// First, we need to read the frame size
 	if r.buffer.ReadableBytes() < 4 {
@@ -75,8 +107,12 @@ func (r *connectionReader) readSingleCommand() (cmd *pb.BaseCommand, headersAndP
 
 	// We have enough to read frame size
 	frameSize := r.buffer.ReadUint32()
-	maxFrameSize := r.cnx.maxMessageSize + MessageFramePadding
-	if r.cnx.maxMessageSize != 0 && int32(frameSize) > maxFrameSize {
+	// the frames received before the broker told its max message size are bounded by the default one
+	maxFrameSize := uint32(MaxFrameSize)
+	if r.cnx.maxMessageSize > 0 {
+		maxFrameSize = uint32(r.cnx.maxMessageSize) + MessageFramePadding
+	}
+	if frameSize > maxFrameSize {
 		frameSizeError := fmt.Errorf("received too big frame size=%d maxFrameSize=%d", frameSize, maxFrameSize)
 		r.cnx.log.Error(frameSizeError)
 		r.cnx.Close()
@@ -93,17 +129,47 @@ func (r *connectionReader) readSingleCommand() (cmd *pb.BaseCommand, headersAndP
 	}
 
 	// We have now the complete frame
-	cmdSize := r.buffer.ReadUint32()
-	cmd, err = r.deserializeCmd(r.buffer.Read(cmdSize))
+	frame := r.buffer.Read(frameSize)
+	cmd, headersAndPayload, err = decodeFrame(frame)
 	if err != nil {
+		dump := frame
+		if len(dump) > malformedFrameDumpSize {
+			dump = dump[:malformedFrameDumpSize]
+		}
+		r.cnx.log.WithError(err).
+			WithField("frameSize", frameSize).
+			WithField("frame", hex.EncodeToString(dump)).
+			Warn("Closing the connection on a malformed frame")
+		r.cnx.Close()
 		return nil, nil, err
 	}
+	return cmd, headersAndPayload, nil
+}
+
+// decodeFrame decodes the command of a frame, without its size, and the headers and payload following the
+// command if any
+//
+// Wire format
+// [CMD_SIZE][CMD] [HEADERS_AND_PAYLOAD]
+func decodeFrame(frame []byte) (*pb.BaseCommand, Buffer, error) {
+	if len(frame) < 4 {
+		return nil, nil, fmt.Errorf("%w: frame size %d is too short for the command size", errMalformedFrame,
+			len(frame))
+	}
+	cmdSize := binary.BigEndian.Uint32(frame)
+	if uint64(cmdSize) > uint64(len(frame)-4) {
+		return nil, nil, fmt.Errorf("%w: command size %d exceeds the frame size %d", errMalformedFrame, cmdSize,
+			len(frame))
+	}
+	cmd := &pb.BaseCommand{}
+	if err := proto.Unmarshal(frame[4:4+cmdSize], cmd); err != nil {
+		return nil, nil, fmt.Errorf("%w: failed to parse protobuf command: %v", errMalformedFrame, err)
+	}
 
-	// Also read the eventual payload
-	headersAndPayloadSize := frameSize - (cmdSize + 4)
-	if cmdSize+4 < frameSize {
-		headersAndPayload = NewBuffer(int(headersAndPayloadSize))
-		headersAndPayload.Write(r.buffer.Read(headersAndPayloadSize))
+	var headersAndPayload Buffer
+	if rest := frame[4+cmdSize:]; len(rest) > 0 {
+		headersAndPayload = NewBuffer(len(rest))
+		headersAndPayload.Write(rest)
 	}
 	return cmd, headersAndPayload, nil
 }
@@ -135,14 +201,3 @@ func (r *connectionReader) readAtLeast(size uint32) error {
 	r.buffer.WrittenBytes(uint32(n))
 	return nil
 }
-
-func (r *connectionReader) deserializeCmd(data []byte) (*pb.BaseCommand, error) {
-	cmd := &pb.BaseCommand{}
-	err := proto.Unmarshal(data, cmd)
-	if err != nil {
-		r.cnx.log.WithError(err).Warn("Failed to parse protobuf command")
-		r.cnx.Close()
-		return nil, err
-	}
-	return cmd, nil
-}
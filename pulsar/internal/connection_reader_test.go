@@ -0,0 +1,127 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package internal
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	pb "github.com/apache/pulsar-client-go/pulsar/internal/pulsar_proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+// newTestFrame returns the frame of a command followed by the headers and payload, without the frame size
+func newTestFrame(t testing.TB, cmd *pb.BaseCommand, headersAndPayload []byte) []byte {
+	data, err := proto.Marshal(cmd)
+	require.NoError(t, err)
+	frame := make([]byte, 4, 4+len(data)+len(headersAndPayload))
+	binary.BigEndian.PutUint32(frame, uint32(len(data)))
+	frame = append(frame, data...)
+	return append(frame, headersAndPayload...)
+}
+
+func TestDecodeFrame(t *testing.T) {
+	frame := newTestFrame(t, newTestMessageCommand(1, 2), []byte("payload"))
+	cmd, headersAndPayload, err := decodeFrame(frame)
+	require.NoError(t, err)
+	assert.Equal(t, pb.BaseCommand_MESSAGE, cmd.GetType())
+	assert.Equal(t, uint64(2), cmd.GetMessage().GetMessageId().GetEntryId())
+	assert.Equal(t, "payload", string(headersAndPayload.ReadableSlice()))
+
+	cmd, headersAndPayload, err = decodeFrame(newTestFrame(t, baseCommand(pb.BaseCommand_PING, &pb.CommandPing{}), nil))
+	require.NoError(t, err)
+	assert.Equal(t, pb.BaseCommand_PING, cmd.GetType())
+	assert.Nil(t, headersAndPayload)
+
+	for name, frame := range map[string][]byte{
+		"empty":                 {},
+		"short":                 {0x00, 0x00},
+		"command size overflow": {0xff, 0xff, 0xff, 0xff, 0x08, 0x12},
+		"truncated command":     frame[:8],
+		"invalid command":       {0x00, 0x00, 0x00, 0x02, 0xff, 0xff},
+	} {
+		_, _, err := decodeFrame(frame)
+		assert.True(t, errors.Is(err, errMalformedFrame), name)
+	}
+}
+
+func TestConnectionReaderMalformedFrame(t *testing.T) {
+	for name, data := range map[string][]byte{
+		"short frame":           {0x00, 0x00, 0x00, 0x02, 0x00, 0x00},
+		"command size overflow": {0x00, 0x00, 0x00, 0x06, 0xff, 0xff, 0xff, 0xfe, 0x08, 0x12},
+		"too big frame":         {0xff, 0xff, 0xff, 0xff},
+	} {
+		t.Run(name, func(t *testing.T) {
+			client, server := net.Pipe()
+			defer server.Close()
+
+			c := newTestConnection(nil)
+			c.cnx = client
+			c.reader = newConnectionReader(c)
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				c.reader.readFromConnection()
+			}()
+
+			_, _ = server.Write(data)
+			// the connection is closed rather than the reader panicking
+			select {
+			case <-done:
+				assert.True(t, c.closed())
+			case <-time.After(5 * time.Second):
+				assert.Fail(t, "the malformed frame didn't close the connection")
+			}
+		})
+	}
+}
+
+// FuzzDecodeFrame decodes arbitrary frames, the frames which can't be decoded must fail with errMalformedFrame.
+// The regression corpus is in testdata/fuzz/FuzzDecodeFrame, run with go test -fuzz=FuzzDecodeFrame to explore.
+func FuzzDecodeFrame(f *testing.F) {
+	f.Add(newTestFrame(f, newTestMessageCommand(1, 2), []byte("payload")))
+	f.Add(newTestFrame(f, baseCommand(pb.BaseCommand_PONG, &pb.CommandPong{}), nil))
+	f.Add(newTestFrame(f, &pb.BaseCommand{
+		Type:        pb.BaseCommand_SEND_RECEIPT.Enum(),
+		SendReceipt: &pb.CommandSendReceipt{ProducerId: proto.Uint64(1), SequenceId: proto.Uint64(2)},
+	}, nil))
+
+	f.Fuzz(func(t *testing.T, frame []byte) {
+		cmd, headersAndPayload, err := decodeFrame(frame)
+		if err != nil {
+			if !errors.Is(err, errMalformedFrame) {
+				t.Fatalf("unexpected error %v", err)
+			}
+			return
+		}
+		if cmd == nil {
+			t.Fatal("nil command decoded")
+		}
+		if headersAndPayload != nil {
+			// the headers and payload of a message must not make the message reader panic either
+			reader := NewMessageReader(headersAndPayload)
+			_, _ = reader.ReadBrokerMetadata()
+			_, _ = reader.ReadMessageMetadata()
+		}
+	})
+}
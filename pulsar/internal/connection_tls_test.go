@@ -0,0 +1,121 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package internal
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/apache/pulsar-client-go/pulsar/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConnectionTLSSessionResumption(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{newTestCertificate(t, &key.PublicKey, key)},
+	})
+	assert.Nil(t, err)
+	defer listener.Close()
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			// the session ticket is sent along with the first write
+			_, _ = conn.Write([]byte{1})
+			conn.Close()
+		}
+	}()
+
+	metrics := NewMetricsProvider(4, map[string]string{}, prometheus.NewRegistry())
+	c := &connection{
+		physicalAddr:      &url.URL{Scheme: "pulsar+ssl", Host: listener.Addr().String()},
+		connectionTimeout: 5 * time.Second,
+		metrics:           metrics,
+		log:               log.DefaultNopLogger(),
+	}
+	sessionCache := tls.NewLRUClientSessionCache(8)
+
+	for i := 0; i < 2; i++ {
+		conn, err := c.dialTLS(&tls.Config{InsecureSkipVerify: true, ClientSessionCache: sessionCache})
+		assert.Nil(t, err)
+		_, err = conn.Read(make([]byte, 1))
+		assert.Nil(t, err)
+		conn.Close()
+	}
+
+	assert.Equal(t, 1, testutil.CollectAndCount(metrics.TLSHandshakeLatency))
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.TLSSessionsResumed))
+}
+
+func TestConnectionTLSCertificateExpiry(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	serverCert := newTestCertificate(t, &key.PublicKey, key)
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+	})
+	assert.Nil(t, err)
+	defer listener.Close()
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			_, _ = conn.Write([]byte{1})
+			conn.Close()
+		}
+	}()
+
+	metrics := NewMetricsProvider(4, map[string]string{}, prometheus.NewRegistry())
+	c := &connection{
+		physicalAddr:      &url.URL{Scheme: "pulsar+ssl", Host: listener.Addr().String()},
+		connectionTimeout: 5 * time.Second,
+		tlsOptions:        &TLSOptions{CertificateExpiryWarning: 24 * time.Hour},
+		metrics:           metrics,
+		log:               log.DefaultNopLogger(),
+	}
+
+	clientCert := newTestCertificate(t, &key.PublicKey, key)
+	conn, err := c.dialTLS(&tls.Config{InsecureSkipVerify: true, Certificates: []tls.Certificate{clientCert}})
+	assert.Nil(t, err)
+	conn.Close()
+
+	serverLeaf, err := x509.ParseCertificate(serverCert.Certificate[0])
+	assert.Nil(t, err)
+	clientLeaf, err := x509.ParseCertificate(clientCert.Certificate[0])
+	assert.Nil(t, err)
+	assert.Equal(t, float64(serverLeaf.NotAfter.Unix()),
+		testutil.ToFloat64(metrics.TLSCertificateExpiry.WithLabelValues("broker", listener.Addr().String())))
+	assert.Equal(t, float64(clientLeaf.NotAfter.Unix()),
+		testutil.ToFloat64(metrics.TLSCertificateExpiry.WithLabelValues("client", "")))
+}
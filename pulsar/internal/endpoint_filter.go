@@ -0,0 +1,55 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package internal
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// EndpointFilter accepts, returning nil, or rejects the address of a broker or proxy before connecting to it
+type EndpointFilter func(addr *url.URL) error
+
+// EndpointRejectedError is returned when the EndpointFilter rejects the address of a broker or proxy
+type EndpointRejectedError struct {
+	// Addr is the address rejected
+	Addr string
+	// Err is the error returned by the filter
+	Err error
+}
+
+func (e *EndpointRejectedError) Error() string {
+	return fmt.Sprintf("connection to %s rejected by the endpoint filter: %v", e.Addr, e.Err)
+}
+
+func (e *EndpointRejectedError) Unwrap() error {
+	return e.Err
+}
+
+// checkEndpoint fails with an EndpointRejectedError if the filter rejects the address, nil filters accept any
+func (p *connectionPool) checkEndpoint(addr *url.URL) error {
+	if p.endpointFilter == nil {
+		return nil
+	}
+	if err := p.endpointFilter(addr); err != nil {
+		p.metrics.ConnectionsRejected.Inc()
+		p.log.WithError(err).Warnf("Connection to %s rejected by the endpoint filter", addr)
+		return &EndpointRejectedError{Addr: addr.String(), Err: err}
+	}
+	return nil
+}
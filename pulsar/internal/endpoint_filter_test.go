@@ -0,0 +1,56 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package internal
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/apache/pulsar-client-go/pulsar/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConnectionPoolEndpointFilter(t *testing.T) {
+	var filtered []string
+	notAllowed := errors.New("host not allowed")
+	metrics := NewMetricsProvider(4, map[string]string{}, prometheus.NewRegistry())
+	pool := NewConnectionPool(nil, nil, time.Second, time.Minute, 1, log.DefaultNopLogger(), metrics, time.Minute, 0,
+		func(addr *url.URL) error {
+			filtered = append(filtered, addr.String())
+			return notAllowed
+		})
+	defer pool.Close()
+
+	logicalAddr, _ := url.Parse("pulsar://broker-1:6650")
+	physicalAddr, _ := url.Parse("pulsar://proxy:6650")
+	cnx, err := pool.GetConnection(logicalAddr, physicalAddr)
+	assert.Nil(t, cnx)
+
+	// the address dialed is filtered
+	var rejected *EndpointRejectedError
+	assert.True(t, errors.As(err, &rejected))
+	assert.Equal(t, "pulsar://proxy:6650", rejected.Addr)
+	assert.True(t, errors.Is(err, notAllowed))
+	assert.Equal(t, []string{"pulsar://proxy:6650"}, filtered)
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.ConnectionsRejected))
+	assert.Empty(t, pool.Stats())
+}
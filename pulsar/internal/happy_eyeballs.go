@@ -0,0 +1,187 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package internal
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+)
+
+// defaultConnectionAttemptDelay is the delay between the connection attempts to the successive addresses of a
+// host, as recommended by RFC 8305
+const defaultConnectionAttemptDelay = 250 * time.Millisecond
+
+const (
+	addressFamilyIPv4 = "ipv4"
+	addressFamilyIPv6 = "ipv6"
+)
+
+// happyEyeballsDialer connects to a host resolved to several addresses, possibly of both the IPv4 and IPv6
+// families, as described by RFC 8305: the addresses are tried in parallel with a short delay between the attempts,
+// alternating the families, and the first established connection is kept. A host with an unreachable IPv6
+// address then does not wait for the connection timeout before trying the IPv4 address.
+type happyEyeballsDialer struct {
+	timeout      time.Duration
+	attemptDelay time.Duration
+	lookup       func(ctx context.Context, host string) ([]net.IPAddr, error)
+	dial         func(ctx context.Context, network, address string) (net.Conn, error)
+}
+
+func newHappyEyeballsDialer(timeout time.Duration) *happyEyeballsDialer {
+	dialer := &net.Dialer{}
+	return &happyEyeballsDialer{
+		timeout:      timeout,
+		attemptDelay: defaultConnectionAttemptDelay,
+		lookup:       net.DefaultResolver.LookupIPAddr,
+		dial:         dialer.DialContext,
+	}
+}
+
+type dialResult struct {
+	conn net.Conn
+	err  error
+}
+
+// Dial connects to the address, a host and port
+func (d *happyEyeballsDialer) Dial(address string) (net.Conn, error) {
+	ctx := context.Background()
+	if d.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d.timeout)
+		defer cancel()
+	}
+
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	if net.ParseIP(host) != nil {
+		return d.dial(ctx, "tcp", address)
+	}
+	addrs, err := d.lookup(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	if len(addrs) == 0 {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	addrs = interleaveAddressFamilies(addrs)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make(chan dialResult, len(addrs))
+	attempt := func(addr net.IPAddr) {
+		conn, err := d.dial(ctx, "tcp", net.JoinHostPort(addr.String(), port))
+		results <- dialResult{conn: conn, err: err}
+	}
+
+	go attempt(addrs[0])
+	next, pending := 1, 1
+	var firstErr error
+	timer := time.NewTimer(d.attemptDelay)
+	defer timer.Stop()
+	for pending > 0 {
+		select {
+		case r := <-results:
+			pending--
+			if r.err == nil {
+				cancel()
+				// close the connections of the attempts still in progress
+				go func(pending int) {
+					for ; pending > 0; pending-- {
+						if late := <-results; late.conn != nil {
+							late.conn.Close()
+						}
+					}
+				}(pending)
+				return r.conn, nil
+			}
+			if firstErr == nil {
+				firstErr = r.err
+			}
+			// start the next attempt without waiting when an attempt fails
+			if next < len(addrs) {
+				go attempt(addrs[next])
+				next++
+				pending++
+				resetTimer(timer, d.attemptDelay)
+			}
+		case <-timer.C:
+			if next < len(addrs) {
+				go attempt(addrs[next])
+				next++
+				pending++
+				timer.Reset(d.attemptDelay)
+			}
+		}
+	}
+	if firstErr == nil {
+		firstErr = errors.New("no address to connect to")
+	}
+	return nil, firstErr
+}
+
+func resetTimer(timer *time.Timer, d time.Duration) {
+	if !timer.Stop() {
+		select {
+		case <-timer.C:
+		default:
+		}
+	}
+	timer.Reset(d)
+}
+
+// interleaveAddressFamilies orders the addresses alternating the address families, starting with the family of
+// the first address
+func interleaveAddressFamilies(addrs []net.IPAddr) []net.IPAddr {
+	var first, second []net.IPAddr
+	firstIsIPv4 := addrs[0].IP.To4() != nil
+	for _, addr := range addrs {
+		if (addr.IP.To4() != nil) == firstIsIPv4 {
+			first = append(first, addr)
+		} else {
+			second = append(second, addr)
+		}
+	}
+
+	interleaved := make([]net.IPAddr, 0, len(addrs))
+	for i := 0; i < len(first) || i < len(second); i++ {
+		if i < len(first) {
+			interleaved = append(interleaved, first[i])
+		}
+		if i < len(second) {
+			interleaved = append(interleaved, second[i])
+		}
+	}
+	return interleaved
+}
+
+// addressFamily returns the family of the IP address of a connection
+func addressFamily(addr net.Addr) string {
+	tcpAddr, ok := addr.(*net.TCPAddr)
+	if !ok {
+		return ""
+	}
+	if tcpAddr.IP.To4() != nil {
+		return addressFamilyIPv4
+	}
+	return addressFamilyIPv6
+}
@@ -0,0 +1,131 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package internal
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testAddrs(ips ...string) []net.IPAddr {
+	addrs := make([]net.IPAddr, len(ips))
+	for i, ip := range ips {
+		addrs[i] = net.IPAddr{IP: net.ParseIP(ip)}
+	}
+	return addrs
+}
+
+func TestInterleaveAddressFamilies(t *testing.T) {
+	addrs := interleaveAddressFamilies(testAddrs("::1", "::2", "::3", "10.0.0.1", "10.0.0.2"))
+	assert.Equal(t, testAddrs("::1", "10.0.0.1", "::2", "10.0.0.2", "::3"), addrs)
+
+	addrs = interleaveAddressFamilies(testAddrs("10.0.0.1", "::1"))
+	assert.Equal(t, testAddrs("10.0.0.1", "::1"), addrs)
+}
+
+type testDialer struct {
+	sync.Mutex
+	dialed []string
+	// addresses which never answer, the dial blocks until cancelled
+	blackholes map[string]bool
+	// addresses which refuse the connections
+	refused map[string]bool
+}
+
+func (d *testDialer) dial(ctx context.Context, network, address string) (net.Conn, error) {
+	d.Lock()
+	d.dialed = append(d.dialed, address)
+	d.Unlock()
+
+	switch {
+	case d.blackholes[address]:
+		<-ctx.Done()
+		return nil, ctx.Err()
+	case d.refused[address]:
+		return nil, errors.New("connection refused")
+	}
+	conn, _ := net.Pipe()
+	return conn, nil
+}
+
+func newTestHappyEyeballsDialer(d *testDialer, addrs []net.IPAddr) *happyEyeballsDialer {
+	return &happyEyeballsDialer{
+		timeout:      10 * time.Second,
+		attemptDelay: 20 * time.Millisecond,
+		lookup: func(ctx context.Context, host string) ([]net.IPAddr, error) {
+			return addrs, nil
+		},
+		dial: d.dial,
+	}
+}
+
+func TestHappyEyeballsBrokenIPv6(t *testing.T) {
+	d := &testDialer{blackholes: map[string]bool{"[::1]:6650": true}}
+	dialer := newTestHappyEyeballsDialer(d, testAddrs("::1", "127.0.0.1"))
+
+	start := time.Now()
+	conn, err := dialer.Dial("broker:6650")
+	assert.NoError(t, err)
+	assert.NotNil(t, conn)
+	// the IPv4 address is tried after the attempt delay, not after the connection timeout
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, []string{"[::1]:6650", "127.0.0.1:6650"}, d.dialed)
+}
+
+func TestHappyEyeballsRefused(t *testing.T) {
+	d := &testDialer{refused: map[string]bool{"[::1]:6650": true}}
+	dialer := newTestHappyEyeballsDialer(d, testAddrs("::1", "127.0.0.1"))
+	dialer.attemptDelay = time.Hour
+
+	// the next address is tried as soon as an attempt fails
+	conn, err := dialer.Dial("broker:6650")
+	assert.NoError(t, err)
+	assert.NotNil(t, conn)
+	assert.Equal(t, []string{"[::1]:6650", "127.0.0.1:6650"}, d.dialed)
+
+	d = &testDialer{refused: map[string]bool{"[::1]:6650": true, "127.0.0.1:6650": true}}
+	dialer = newTestHappyEyeballsDialer(d, testAddrs("::1", "127.0.0.1"))
+	_, err = dialer.Dial("broker:6650")
+	assert.Error(t, err)
+}
+
+func TestHappyEyeballsIPLiteral(t *testing.T) {
+	d := &testDialer{}
+	dialer := newTestHappyEyeballsDialer(d, nil)
+	dialer.lookup = func(ctx context.Context, host string) ([]net.IPAddr, error) {
+		t.Fatal("unexpected lookup")
+		return nil, nil
+	}
+
+	conn, err := dialer.Dial("127.0.0.1:6650")
+	assert.NoError(t, err)
+	assert.NotNil(t, conn)
+	assert.Equal(t, []string{"127.0.0.1:6650"}, d.dialed)
+}
+
+func TestAddressFamily(t *testing.T) {
+	assert.Equal(t, addressFamilyIPv4, addressFamily(&net.TCPAddr{IP: net.ParseIP("127.0.0.1")}))
+	assert.Equal(t, addressFamilyIPv6, addressFamily(&net.TCPAddr{IP: net.ParseIP("::1")}))
+	assert.Equal(t, "", addressFamily(&net.UnixAddr{Name: "socket"}))
+}
@@ -22,6 +22,7 @@ import (
 	"crypto/tls"
 	"crypto/x509"
 	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
 	"net/url"
@@ -32,8 +33,6 @@ import (
 	"github.com/apache/pulsar-client-go/pulsar/auth"
 
 	"github.com/apache/pulsar-client-go/pulsar/log"
-
-	"github.com/pkg/errors"
 )
 
 // httpClient is a base client that is used to make http httpRequest to the ServiceURL
@@ -53,6 +52,9 @@ func (c *httpClient) Close() {
 
 type HTTPClient interface {
 	Get(endpoint string, obj interface{}, params map[string]string) error
+	Put(endpoint string, in interface{}, params map[string]string) error
+	Post(endpoint string, in interface{}, params map[string]string) error
+	Delete(endpoint string, params map[string]string) error
 	Closable
 }
 
@@ -220,6 +222,43 @@ func (c *httpClient) GetWithOptions(endpoint string, obj interface{}, params map
 	return nil, err
 }
 
+// Put sends a PUT request with the JSON encoded body, a nil body sends an empty request
+func (c *httpClient) Put(endpoint string, in interface{}, params map[string]string) error {
+	return c.doWithBody(http.MethodPut, endpoint, in, params)
+}
+
+// Post sends a POST request with the JSON encoded body, a nil body sends an empty request
+func (c *httpClient) Post(endpoint string, in interface{}, params map[string]string) error {
+	return c.doWithBody(http.MethodPost, endpoint, in, params)
+}
+
+func (c *httpClient) Delete(endpoint string, params map[string]string) error {
+	return c.doWithBody(http.MethodDelete, endpoint, nil, params)
+}
+
+func (c *httpClient) doWithBody(method, endpoint string, in interface{}, params map[string]string) error {
+	req, err := c.newRequest(method, endpoint)
+	if err != nil {
+		return err
+	}
+
+	if params != nil {
+		query := req.url.Query()
+		for k, v := range params {
+			query.Add(k, v)
+		}
+		req.params = query
+	}
+	req.obj = in
+
+	resp, err := checkSuccessful(c.doRequest(req))
+	if err != nil {
+		return err
+	}
+	safeRespClose(resp)
+	return nil
+}
+
 func (c *httpClient) useragent() string {
 	return "Pulsar-httpClient-Go-v2"
 }
@@ -308,6 +347,16 @@ func safeRespClose(resp *http.Response) {
 	}
 }
 
+// HTTPError is the error of a request the REST API answered with an error status
+type HTTPError struct {
+	StatusCode int
+	Reason     string
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("Code: %d, Reason: %s", e.StatusCode, e.Reason)
+}
+
 // responseError is used to parse a response into a client error
 func responseError(resp *http.Response) error {
 	var e error
@@ -316,7 +365,7 @@ func responseError(resp *http.Response) error {
 	code := resp.StatusCode
 	if err != nil {
 		reason = err.Error()
-		return errors.Errorf("Code: %d, Reason: %s", code, reason)
+		return &HTTPError{StatusCode: code, Reason: reason}
 	}
 
 	err = json.Unmarshal(body, &e)
@@ -328,7 +377,7 @@ func responseError(resp *http.Response) error {
 		reason = "Unknown error"
 	}
 
-	return errors.Errorf("Code: %d, Reason: %s", code, reason)
+	return &HTTPError{StatusCode: code, Reason: reason}
 }
 
 func getDefaultTransport(tlsConfig *TLSOptions) (http.RoundTripper, error) {
@@ -336,6 +385,7 @@ func getDefaultTransport(tlsConfig *TLSOptions) (http.RoundTripper, error) {
 	if tlsConfig != nil {
 		cfg := &tls.Config{
 			InsecureSkipVerify: tlsConfig.AllowInsecureConnection,
+			ClientSessionCache: tlsConfig.SessionCache,
 		}
 		if len(tlsConfig.TrustCertsFilePath) > 0 {
 			rootCA, err := os.ReadFile(tlsConfig.TrustCertsFilePath)
@@ -346,12 +396,17 @@ func getDefaultTransport(tlsConfig *TLSOptions) (http.RoundTripper, error) {
 			cfg.RootCAs.AppendCertsFromPEM(rootCA)
 		}
 
-		if tlsConfig.CertFile != "" && tlsConfig.KeyFile != "" {
-			cert, err := tls.LoadX509KeyPair(tlsConfig.CertFile, tlsConfig.KeyFile)
-			if err != nil {
-				return nil, errors.New(err.Error())
+		cert, err := tlsConfig.clientCertificate()
+		if err != nil {
+			return nil, err
+		}
+		if cert != nil {
+			cfg.Certificates = []tls.Certificate{*cert}
+		}
+		if tlsConfig.FIPSMode {
+			if err := applyFIPS(cfg); err != nil {
+				return nil, err
 			}
-			cfg.Certificates = []tls.Certificate{cert}
 		}
 		transport.TLSClientConfig = cfg
 	}
@@ -0,0 +1,324 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package internal
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/klauspost/compress/s2"
+	"github.com/pierrec/lz4"
+
+	"github.com/apache/pulsar-client-go/pulsar/internal/compression"
+	pb "github.com/apache/pulsar-client-go/pulsar/internal/pulsar_proto"
+)
+
+const (
+	// kafkaEntryFormatProperty is the metadata property set by Kafka-on-Pulsar on the entries
+	// it writes, the value tells how the payload is encoded
+	kafkaEntryFormatProperty = "entry.format"
+
+	kafkaRecordBatchMagic = 2
+	// size of the record batch header, from the base offset up to the records count
+	kafkaRecordBatchHeaderSize = 61
+	// offset of the attributes, the crc covers all the bytes from there on
+	kafkaRecordBatchAttributesOffset = 21
+	// size of a record without key, value and header, its length, attributes, timestamp and offset deltas, key
+	// and value lengths and headers count all taking 1 byte at least
+	kafkaMinRecordSize = 7
+
+	kafkaCompressionCodecMask = 0x07
+	kafkaTimestampTypeMask    = 0x08
+
+	kafkaCompressionNone   = 0
+	kafkaCompressionGzip   = 1
+	kafkaCompressionSnappy = 2
+	kafkaCompressionLz4    = 3
+	kafkaCompressionZstd   = 4
+)
+
+// kafkaSnappyMagic starts the snappy records framed by the Java Kafka clients (xerial snappy), followed by the
+// version and the compatible version of the framing, and then by the snappy blocks prefixed by their length
+var kafkaSnappyMagic = []byte{0x82, 'S', 'N', 'A', 'P', 'P', 'Y', 0}
+
+const kafkaSnappyHeaderSize = 16
+
+var errKafkaRecordTruncated = errors.New("kafka record batch is truncated")
+
+// ErrKafkaCompressionUnsupported is returned when a Kafka record batch is compressed with an unknown codec
+var ErrKafkaCompressionUnsupported = errors.New("unsupported kafka compression codec")
+
+// kafkaZstdProviders are the providers decompressing the zstd records, shared by the consumers
+var kafkaZstdProviders = sync.Pool{
+	New: func() interface{} {
+		return compression.NewZStdProvider(compression.Default)
+	},
+}
+
+// KafkaRecordHeader is a header attached to a Kafka record
+type KafkaRecordHeader struct {
+	Key   string
+	Value []byte
+}
+
+// KafkaRecord is a single record of a Kafka record batch
+type KafkaRecord struct {
+	Offset    int64
+	Timestamp int64
+	Key       []byte
+	Value     []byte
+	Headers   []KafkaRecordHeader
+}
+
+// KafkaRecordBatch is a Kafka record batch (message format v2) as written by Kafka-on-Pulsar
+// when the `kafka` entry format is used
+type KafkaRecordBatch struct {
+	BaseOffset int64
+	// LogAppendTime is true when the record timestamps were set by the broker rather than the producer
+	LogAppendTime bool
+	Records       []KafkaRecord
+}
+
+// IsKafkaEntry checks whether the entry has been written by Kafka-on-Pulsar with a Kafka record batch payload
+func IsKafkaEntry(msgMeta *pb.MessageMetadata) bool {
+	for _, kv := range msgMeta.GetProperties() {
+		if kv.GetKey() == kafkaEntryFormatProperty {
+			format := kv.GetValue()
+			return format == "kafka" || format == "mixed_kafka"
+		}
+	}
+	return false
+}
+
+// ParseKafkaRecordBatch decodes a Kafka record batch
+func ParseKafkaRecordBatch(data []byte) (*KafkaRecordBatch, error) {
+	if len(data) < kafkaRecordBatchHeaderSize {
+		return nil, errKafkaRecordTruncated
+	}
+
+	header := kafkaDecoder{data: data}
+	baseOffset := header.int64()
+	batchLength := header.int32()
+	if int(batchLength) != len(data)-12 {
+		return nil, fmt.Errorf("kafka record batch length mismatch, expected %d got %d", batchLength, len(data)-12)
+	}
+	header.int32() // partition leader epoch
+	if magic := header.int8(); magic != kafkaRecordBatchMagic {
+		return nil, fmt.Errorf("unsupported kafka record batch magic %d", magic)
+	}
+	checksum := uint32(header.int32())
+	if computed := Crc32cCheckSum(data[kafkaRecordBatchAttributesOffset:]); computed != checksum {
+		return nil, fmt.Errorf("kafka record batch checksum mismatch received: 0x%x computed: 0x%x",
+			checksum, computed)
+	}
+	attributes := header.int16()
+	header.int32() // last offset delta
+	baseTimestamp := header.int64()
+	maxTimestamp := header.int64()
+	header.int64() // producer id
+	header.int16() // producer epoch
+	header.int32() // base sequence
+	count := header.int32()
+	if count < 0 {
+		return nil, fmt.Errorf("invalid kafka record count %d", count)
+	}
+
+	records, err := decompressKafkaRecords(attributes&kafkaCompressionCodecMask, data[kafkaRecordBatchHeaderSize:])
+	if err != nil {
+		return nil, err
+	}
+	// the records count is not trusted to allocate the records, it is bounded by the records size
+	if int(count) > len(records)/kafkaMinRecordSize {
+		return nil, fmt.Errorf("kafka record count %d exceeds the %d bytes of the records", count, len(records))
+	}
+
+	batch := &KafkaRecordBatch{
+		BaseOffset:    baseOffset,
+		LogAppendTime: attributes&kafkaTimestampTypeMask != 0,
+		Records:       make([]KafkaRecord, 0, count),
+	}
+
+	d := kafkaDecoder{data: records}
+	for i := int32(0); i < count; i++ {
+		d.varint() // record length
+		d.int8()   // record attributes
+		record := KafkaRecord{
+			Timestamp: baseTimestamp + d.varint(),
+			Offset:    baseOffset + d.varint(),
+			Key:       d.bytes(),
+			Value:     d.bytes(),
+		}
+		if batch.LogAppendTime {
+			record.Timestamp = maxTimestamp
+		}
+		numHeaders := d.varint()
+		for j := int64(0); j < numHeaders && d.err == nil; j++ {
+			record.Headers = append(record.Headers, KafkaRecordHeader{
+				Key:   string(d.bytes()),
+				Value: d.bytes(),
+			})
+		}
+		if d.err != nil {
+			return nil, d.err
+		}
+		batch.Records = append(batch.Records, record)
+	}
+
+	return batch, nil
+}
+
+func decompressKafkaRecords(codec int16, records []byte) ([]byte, error) {
+	switch codec {
+	case kafkaCompressionNone:
+		return records, nil
+	case kafkaCompressionGzip:
+		r, err := gzip.NewReader(bytes.NewReader(records))
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		return io.ReadAll(r)
+	case kafkaCompressionSnappy:
+		return decompressKafkaSnappy(records)
+	case kafkaCompressionLz4:
+		// the LZ4 frame format, of unknown uncompressed size
+		return io.ReadAll(lz4.NewReader(bytes.NewReader(records)))
+	case kafkaCompressionZstd:
+		provider := kafkaZstdProviders.Get().(compression.Provider)
+		defer kafkaZstdProviders.Put(provider)
+		// the uncompressed size is read from the zstd frames
+		return provider.Decompress(nil, records, 0)
+	default:
+		return nil, fmt.Errorf("%w %d", ErrKafkaCompressionUnsupported, codec)
+	}
+}
+
+// decompressKafkaSnappy decompresses the records framed by the Java Kafka clients, or a single snappy block as
+// written by other clients
+func decompressKafkaSnappy(records []byte) ([]byte, error) {
+	provider := compression.NewSnappyProvider(compression.Default)
+	if !bytes.HasPrefix(records, kafkaSnappyMagic) {
+		return decompressSnappyBlock(provider, nil, records)
+	}
+
+	if len(records) < kafkaSnappyHeaderSize {
+		return nil, errKafkaRecordTruncated
+	}
+	d := kafkaDecoder{data: records[kafkaSnappyHeaderSize:]}
+	var uncompressed []byte
+	for len(d.data) > 0 {
+		block := d.next(int(d.int32()))
+		if d.err != nil {
+			return nil, d.err
+		}
+		var err error
+		if uncompressed, err = decompressSnappyBlock(provider, uncompressed, block); err != nil {
+			return nil, err
+		}
+	}
+	return uncompressed, nil
+}
+
+// decompressSnappyBlock appends the uncompressed content of the snappy block to dst
+func decompressSnappyBlock(provider compression.Provider, dst, block []byte) ([]byte, error) {
+	size, err := s2.DecodedLen(block)
+	if err != nil {
+		return nil, err
+	}
+	uncompressed, err := provider.Decompress(nil, block, size)
+	if err != nil {
+		return nil, err
+	}
+	return append(dst, uncompressed...), nil
+}
+
+// kafkaDecoder reads the Kafka wire primitives, the first error is retained and
+// all the subsequent reads return zero values
+type kafkaDecoder struct {
+	data []byte
+	err  error
+}
+
+func (d *kafkaDecoder) next(size int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if len(d.data) < size {
+		d.err = errKafkaRecordTruncated
+		return nil
+	}
+	b := d.data[:size]
+	d.data = d.data[size:]
+	return b
+}
+
+func (d *kafkaDecoder) int8() int8 {
+	if b := d.next(1); b != nil {
+		return int8(b[0])
+	}
+	return 0
+}
+
+func (d *kafkaDecoder) int16() int16 {
+	if b := d.next(2); b != nil {
+		return int16(binary.BigEndian.Uint16(b))
+	}
+	return 0
+}
+
+func (d *kafkaDecoder) int32() int32 {
+	if b := d.next(4); b != nil {
+		return int32(binary.BigEndian.Uint32(b))
+	}
+	return 0
+}
+
+func (d *kafkaDecoder) int64() int64 {
+	if b := d.next(8); b != nil {
+		return int64(binary.BigEndian.Uint64(b))
+	}
+	return 0
+}
+
+// varint reads a zig-zag encoded variable length integer
+func (d *kafkaDecoder) varint() int64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Varint(d.data)
+	if n <= 0 {
+		d.err = errKafkaRecordTruncated
+		return 0
+	}
+	d.data = d.data[n:]
+	return v
+}
+
+// bytes reads a byte array prefixed by its varint length, a negative length means null
+func (d *kafkaDecoder) bytes() []byte {
+	size := d.varint()
+	if size < 0 {
+		return nil
+	}
+	return d.next(int(size))
+}
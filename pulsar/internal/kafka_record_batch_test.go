@@ -0,0 +1,208 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package internal
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"testing"
+
+	"github.com/klauspost/compress/s2"
+	"github.com/klauspost/compress/zstd"
+	"github.com/pierrec/lz4"
+
+	pb "github.com/apache/pulsar-client-go/pulsar/internal/pulsar_proto"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+)
+
+func appendVarint(b []byte, v int64) []byte {
+	buf := make([]byte, binary.MaxVarintLen64)
+	return append(b, buf[:binary.PutVarint(buf, v)]...)
+}
+
+func appendKafkaBytes(b []byte, data []byte) []byte {
+	if data == nil {
+		return appendVarint(b, -1)
+	}
+	b = appendVarint(b, int64(len(data)))
+	return append(b, data...)
+}
+
+func encodeKafkaRecordBatch(attributes int16, baseTimestamp int64, records []KafkaRecord) []byte {
+	var body []byte
+	for i, r := range records {
+		var rec []byte
+		rec = append(rec, 0)
+		rec = appendVarint(rec, r.Timestamp-baseTimestamp)
+		rec = appendVarint(rec, int64(i))
+		rec = appendKafkaBytes(rec, r.Key)
+		rec = appendKafkaBytes(rec, r.Value)
+		rec = appendVarint(rec, int64(len(r.Headers)))
+		for _, h := range r.Headers {
+			rec = appendKafkaBytes(rec, []byte(h.Key))
+			rec = appendKafkaBytes(rec, h.Value)
+		}
+		body = appendVarint(body, int64(len(rec)))
+		body = append(body, rec...)
+	}
+
+	body = compressKafkaRecords(attributes&kafkaCompressionCodecMask, body)
+
+	b := make([]byte, kafkaRecordBatchHeaderSize)
+	binary.BigEndian.PutUint64(b[0:], 100)
+	binary.BigEndian.PutUint32(b[8:], uint32(kafkaRecordBatchHeaderSize-12+len(body)))
+	b[16] = kafkaRecordBatchMagic
+	binary.BigEndian.PutUint16(b[21:], uint16(attributes))
+	binary.BigEndian.PutUint32(b[23:], uint32(len(records)-1))
+	binary.BigEndian.PutUint64(b[27:], uint64(baseTimestamp))
+	binary.BigEndian.PutUint64(b[35:], uint64(baseTimestamp+1000))
+	binary.BigEndian.PutUint32(b[57:], uint32(len(records)))
+	b = append(b, body...)
+	binary.BigEndian.PutUint32(b[17:], Crc32cCheckSum(b[kafkaRecordBatchAttributesOffset:]))
+	return b
+}
+
+// compressKafkaRecords compresses the records as the Java Kafka clients do
+func compressKafkaRecords(codec int16, records []byte) []byte {
+	var buf bytes.Buffer
+	switch codec {
+	case kafkaCompressionGzip:
+		w := gzip.NewWriter(&buf)
+		_, _ = w.Write(records)
+		_ = w.Close()
+	case kafkaCompressionSnappy:
+		// xerial snappy framing, with blocks of 10 bytes at most
+		buf.Write(kafkaSnappyMagic)
+		buf.Write([]byte{0, 0, 0, 1, 0, 0, 0, 1})
+		for len(records) > 0 {
+			n := 10
+			if n > len(records) {
+				n = len(records)
+			}
+			block := s2.EncodeSnappy(nil, records[:n])
+			_ = binary.Write(&buf, binary.BigEndian, int32(len(block)))
+			buf.Write(block)
+			records = records[n:]
+		}
+	case kafkaCompressionLz4:
+		w := lz4.NewWriter(&buf)
+		_, _ = w.Write(records)
+		_ = w.Close()
+	case kafkaCompressionZstd:
+		w, _ := zstd.NewWriter(&buf)
+		_, _ = w.Write(records)
+		_ = w.Close()
+	default:
+		return records
+	}
+	return buf.Bytes()
+}
+
+var testKafkaRecords = []KafkaRecord{
+	{
+		Timestamp: 1000,
+		Key:       []byte("key-1"),
+		Value:     []byte("value-1"),
+		Headers:   []KafkaRecordHeader{{Key: "h1", Value: []byte("v1")}, {Key: "h2", Value: []byte("v2")}},
+	},
+	{
+		Timestamp: 1500,
+		Value:     []byte("value-2"),
+	},
+}
+
+func TestParseKafkaRecordBatch(t *testing.T) {
+	for _, attributes := range []int16{kafkaCompressionNone, kafkaCompressionGzip, kafkaCompressionSnappy,
+		kafkaCompressionLz4, kafkaCompressionZstd} {
+		batch, err := ParseKafkaRecordBatch(encodeKafkaRecordBatch(attributes, 1000, testKafkaRecords))
+		assert.Nil(t, err)
+		assert.False(t, batch.LogAppendTime)
+		assert.Equal(t, int64(100), batch.BaseOffset)
+		assert.Len(t, batch.Records, 2)
+
+		assert.Equal(t, int64(100), batch.Records[0].Offset)
+		assert.Equal(t, int64(1000), batch.Records[0].Timestamp)
+		assert.Equal(t, []byte("key-1"), batch.Records[0].Key)
+		assert.Equal(t, []byte("value-1"), batch.Records[0].Value)
+		assert.Equal(t, testKafkaRecords[0].Headers, batch.Records[0].Headers)
+
+		assert.Equal(t, int64(101), batch.Records[1].Offset)
+		assert.Equal(t, int64(1500), batch.Records[1].Timestamp)
+		assert.Nil(t, batch.Records[1].Key)
+		assert.Empty(t, batch.Records[1].Headers)
+	}
+}
+
+func TestDecompressKafkaRecords(t *testing.T) {
+	records := []byte("the records of a kafka record batch")
+	for _, codec := range []int16{kafkaCompressionNone, kafkaCompressionGzip, kafkaCompressionSnappy,
+		kafkaCompressionLz4, kafkaCompressionZstd} {
+		uncompressed, err := decompressKafkaRecords(codec, compressKafkaRecords(codec, records))
+		assert.Nil(t, err)
+		assert.Equal(t, records, uncompressed)
+	}
+
+	// a single snappy block, without the framing of the Java clients
+	uncompressed, err := decompressKafkaRecords(kafkaCompressionSnappy, s2.EncodeSnappy(nil, records))
+	assert.Nil(t, err)
+	assert.Equal(t, records, uncompressed)
+
+	_, err = decompressKafkaRecords(kafkaCompressionSnappy, kafkaSnappyMagic)
+	assert.Equal(t, errKafkaRecordTruncated, err)
+
+	_, err = decompressKafkaRecords(5, records)
+	assert.ErrorIs(t, err, ErrKafkaCompressionUnsupported)
+}
+
+func TestParseKafkaRecordBatchLogAppendTime(t *testing.T) {
+	batch, err := ParseKafkaRecordBatch(encodeKafkaRecordBatch(kafkaTimestampTypeMask, 1000, testKafkaRecords))
+	assert.Nil(t, err)
+	assert.True(t, batch.LogAppendTime)
+	assert.Equal(t, int64(2000), batch.Records[0].Timestamp)
+	assert.Equal(t, int64(2000), batch.Records[1].Timestamp)
+}
+
+func TestParseKafkaRecordBatchCorrupted(t *testing.T) {
+	data := encodeKafkaRecordBatch(kafkaCompressionNone, 1000, testKafkaRecords)
+
+	_, err := ParseKafkaRecordBatch(data[:kafkaRecordBatchHeaderSize-1])
+	assert.Equal(t, errKafkaRecordTruncated, err)
+
+	data[len(data)-1] ^= 0xff
+	_, err = ParseKafkaRecordBatch(data)
+	assert.NotNil(t, err)
+
+	// a records count larger than the records can hold
+	data = encodeKafkaRecordBatch(kafkaCompressionNone, 1000, testKafkaRecords)
+	binary.BigEndian.PutUint32(data[57:], 1<<30)
+	binary.BigEndian.PutUint32(data[17:], Crc32cCheckSum(data[kafkaRecordBatchAttributesOffset:]))
+	_, err = ParseKafkaRecordBatch(data)
+	assert.ErrorContains(t, err, "exceeds")
+}
+
+func TestIsKafkaEntry(t *testing.T) {
+	assert.False(t, IsKafkaEntry(&pb.MessageMetadata{}))
+	assert.True(t, IsKafkaEntry(&pb.MessageMetadata{
+		Properties: []*pb.KeyValue{{Key: proto.String("entry.format"), Value: proto.String("kafka")}},
+	}))
+	assert.False(t, IsKafkaEntry(&pb.MessageMetadata{
+		Properties: []*pb.KeyValue{{Key: proto.String("entry.format"), Value: proto.String("pulsar")}},
+	}))
+}
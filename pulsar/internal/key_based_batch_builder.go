@@ -53,6 +53,44 @@ type keyBasedBatchContainer struct {
 	batchContainer
 	compressionType pb.CompressionType
 	level           compression.Level
+
+	// size is the number of bytes buffered in all the key batches
+	size uint32
+	// maxKeys bounds the number of keys with an open batch, not positive for no bound
+	maxKeys  int
+	eviction KeyBatchEviction
+	// openedAt orders the open key batches by creation, for EvictOldestKeyBatch
+	openedAt map[string]uint64
+	opened   uint64
+	evicted  []evictedBatch
+}
+
+// KeyBatchEviction selects the key batch flushed first when a new key would exceed the maximum number of keys
+type KeyBatchEviction int
+
+const (
+	// EvictLargestKeyBatch flushes the key batch buffering the most bytes
+	EvictLargestKeyBatch KeyBatchEviction = iota
+	// EvictOldestKeyBatch flushes the key batch opened first
+	EvictOldestKeyBatch
+)
+
+// KeyBatchLimiter is implemented by the batch builders keeping a batch per key, it bounds the number of keys
+// with an open batch so high-cardinality keys don't defeat batching and exhaust memory.
+type KeyBatchLimiter interface {
+	// SetMaxKeys sets the maximum number of keys with an open batch, a maximum not positive removes the bound.
+	// A message with a new key beyond the maximum flushes the batch selected by eviction instead of blocking.
+	SetMaxKeys(maxKeys int, eviction KeyBatchEviction)
+
+	// FlushEvicted returns the batches flushed by eviction since the last call, to be sent like FlushBatches.
+	FlushEvicted() (batchesData []Buffer, sequenceIDs []uint64, callbacks [][]interface{}, errors []error)
+}
+
+type evictedBatch struct {
+	data       Buffer
+	sequenceID uint64
+	callbacks  []interface{}
+	err        error
 }
 
 // newKeyBasedBatches init a keyBasedBatches
@@ -97,10 +135,12 @@ func NewKeyBasedBatchBuilder(
 		),
 		compressionType: compressionType,
 		level:           level,
+		openedAt:        map[string]uint64{},
 	}
 
 	if compressionType != pb.CompressionType_NONE {
-		bb.msgMetadata.Compression = &compressionType
+		wireCompressionType := WireCompressionType(compressionType)
+		bb.msgMetadata.Compression = &wireCompressionType
 	}
 
 	return bb, nil
@@ -108,7 +148,7 @@ func NewKeyBasedBatchBuilder(
 
 // IsFull checks if the size in the current batch meets or exceeds the maximum size allowed by the batch
 func (bc *keyBasedBatchContainer) IsFull() bool {
-	return bc.numMessages >= bc.maxMessages || bc.buffer.ReadableBytes() >= uint32(bc.maxBatchSize)
+	return bc.numMessages >= bc.maxMessages || bc.size >= uint32(bc.maxBatchSize)
 }
 
 func (bc *keyBasedBatchContainer) IsMultiBatches() bool {
@@ -123,7 +163,7 @@ func (bc *keyBasedBatchContainer) hasSpace(payload []byte) bool {
 		return true
 	}
 	msgSize := uint32(len(payload))
-	return bc.numMessages+1 <= bc.maxMessages && bc.buffer.ReadableBytes()+msgSize <= uint32(bc.maxBatchSize)
+	return bc.numMessages+1 <= bc.maxMessages && bc.size+msgSize <= uint32(bc.maxBatchSize)
 }
 
 // Add will add single message to key-based batch with message key.
@@ -149,6 +189,9 @@ func (bc *keyBasedBatchContainer) Add(
 	var msgKey = getMessageKey(metadata)
 	batchPart := bc.batches.Val(msgKey)
 	if batchPart == nil {
+		if bc.maxKeys > 0 && len(bc.batches.containers) >= bc.maxKeys {
+			bc.evict()
+		}
 		// create batchContainer for new key
 		t := newBatchContainer(
 			bc.maxMessages, bc.maxBatchSize, bc.maxMessageSize, bc.producerName, bc.producerID,
@@ -156,7 +199,10 @@ func (bc *keyBasedBatchContainer) Add(
 		)
 		batchPart = &t
 		bc.batches.Add(msgKey, &t)
+		bc.openedAt[msgKey] = bc.opened
+		bc.opened++
 	}
+	partSize := batchPart.buffer.ReadableBytes()
 
 	// add message to batch container
 	add := batchPart.Add(
@@ -167,7 +213,7 @@ func (bc *keyBasedBatchContainer) Add(
 	if !add {
 		return false
 	}
-	addSingleMessageToBatch(bc.buffer, metadata, payload)
+	bc.size += batchPart.buffer.ReadableBytes() - partSize
 
 	bc.numMessages++
 	bc.callbacks = append(bc.callbacks, callback)
@@ -181,11 +227,72 @@ func (bc *keyBasedBatchContainer) reset() {
 		container.reset()
 	}
 	bc.numMessages = 0
+	bc.size = 0
 	bc.buffer.Clear()
 	bc.callbacks = []interface{}{}
 	bc.msgMetadata.ReplicateTo = nil
 	bc.msgMetadata.DeliverAtTime = nil
 	bc.batches.containers = map[string]*batchContainer{}
+	bc.openedAt = map[string]uint64{}
+}
+
+func (bc *keyBasedBatchContainer) SetMaxKeys(maxKeys int, eviction KeyBatchEviction) {
+	bc.maxKeys = maxKeys
+	bc.eviction = eviction
+}
+
+// evict flushes the key batch selected by the eviction policy and keeps it until FlushEvicted
+func (bc *keyBasedBatchContainer) evict() {
+	var victimKey string
+	var victim *batchContainer
+	for k, container := range bc.batches.containers {
+		if victim == nil {
+			victimKey, victim = k, container
+			continue
+		}
+		switch bc.eviction {
+		case EvictOldestKeyBatch:
+			if bc.openedAt[k] < bc.openedAt[victimKey] {
+				victimKey, victim = k, container
+			}
+		default:
+			if container.buffer.ReadableBytes() > victim.buffer.ReadableBytes() {
+				victimKey, victim = k, container
+			}
+		}
+	}
+	if victim == nil {
+		return
+	}
+
+	bc.log.Debug("keyBasedBatchContainer evict: messages: ", victim.numMessages)
+	bc.numMessages -= victim.numMessages
+	bc.size -= victim.buffer.ReadableBytes()
+	b, s, c, err := victim.Flush()
+	if b != nil || err != nil {
+		bc.evicted = append(bc.evicted, evictedBatch{data: b, sequenceID: s, callbacks: c, err: err})
+	}
+	if err := victim.Close(); err != nil {
+		bc.log.WithError(err).Warn("failed to close the evicted key batch")
+	}
+	bc.batches.Del(victimKey)
+	delete(bc.openedAt, victimKey)
+}
+
+func (bc *keyBasedBatchContainer) FlushEvicted() (
+	batchesData []Buffer, sequenceIDs []uint64, callbacks [][]interface{}, errors []error,
+) {
+	if len(bc.evicted) == 0 {
+		return nil, nil, nil, nil
+	}
+	for _, e := range bc.evicted {
+		batchesData = append(batchesData, e.data)
+		sequenceIDs = append(sequenceIDs, e.sequenceID)
+		callbacks = append(callbacks, e.callbacks)
+		errors = append(errors, e.err)
+	}
+	bc.evicted = nil
+	return batchesData, sequenceIDs, callbacks, errors
 }
 
 // Flush all the messages buffered in multiple batches and wait until all
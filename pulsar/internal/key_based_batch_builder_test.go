@@ -0,0 +1,127 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package internal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/apache/pulsar-client-go/pulsar/internal/compression"
+	"github.com/apache/pulsar-client-go/pulsar/internal/crypto"
+	pb "github.com/apache/pulsar-client-go/pulsar/internal/pulsar_proto"
+	"github.com/apache/pulsar-client-go/pulsar/log"
+)
+
+type testBuffersPool struct{}
+
+func (testBuffersPool) GetBuffer() Buffer {
+	return NewBuffer(1024)
+}
+
+func newTestKeyBasedBatchBuilder(t *testing.T, maxKeys int, eviction KeyBatchEviction) *keyBasedBatchContainer {
+	bb, err := NewKeyBasedBatchBuilder(1000, 128*1024, 5*1024*1024, "test", 1,
+		pb.CompressionType_NONE, 0, testBuffersPool{}, log.DefaultNopLogger(), crypto.NewNoopEncryptor())
+	assert.Nil(t, err)
+	bb.(KeyBatchLimiter).SetMaxKeys(maxKeys, eviction)
+	return bb.(*keyBasedBatchContainer)
+}
+
+func addKeyMessage(t *testing.T, bb *keyBasedBatchContainer, key string, payload []byte) {
+	var sequenceID uint64
+	metadata := &pb.SingleMessageMetadata{
+		PayloadSize:  proto.Int32(int32(len(payload))),
+		PartitionKey: proto.String(key),
+	}
+	assert.True(t, bb.Add(metadata, &sequenceID, payload, key, nil, time.Time{}, nil, false))
+}
+
+func TestKeyBasedBatchBuilderEvictLargest(t *testing.T) {
+	bb := newTestKeyBasedBatchBuilder(t, 2, EvictLargestKeyBatch)
+
+	addKeyMessage(t, bb, "a", []byte("a"))
+	addKeyMessage(t, bb, "b", make([]byte, 100))
+	addKeyMessage(t, bb, "b", make([]byte, 100))
+	data, _, _, _ := bb.FlushEvicted()
+	assert.Nil(t, data)
+
+	size := bb.size
+	addKeyMessage(t, bb, "c", []byte("c"))
+	data, _, callbacks, errs := bb.FlushEvicted()
+	assert.Len(t, data, 1)
+	assert.Nil(t, errs[0])
+	assert.Equal(t, []interface{}{"b", "b"}, callbacks[0])
+	assert.Equal(t, 2, len(bb.batches.containers))
+	assert.Equal(t, uint(2), bb.numMessages)
+	assert.Less(t, bb.size, size)
+
+	data, _, _, _ = bb.FlushEvicted()
+	assert.Nil(t, data)
+
+	data, _, callbacks, _ = bb.FlushBatches()
+	assert.Len(t, data, 2)
+	assert.Equal(t, [][]interface{}{{"a"}, {"c"}}, callbacks)
+	assert.Equal(t, uint32(0), bb.size)
+}
+
+func TestKeyBasedBatchBuilderEvictOldest(t *testing.T) {
+	bb := newTestKeyBasedBatchBuilder(t, 2, EvictOldestKeyBatch)
+
+	addKeyMessage(t, bb, "a", []byte("a"))
+	addKeyMessage(t, bb, "b", make([]byte, 100))
+	addKeyMessage(t, bb, "c", []byte("c"))
+	_, _, callbacks, _ := bb.FlushEvicted()
+	assert.Equal(t, [][]interface{}{{"a"}}, callbacks)
+
+	addKeyMessage(t, bb, "d", []byte("d"))
+	_, _, callbacks, _ = bb.FlushEvicted()
+	assert.Equal(t, [][]interface{}{{"b"}}, callbacks)
+}
+
+func TestKeyBasedBatchBuilderUnboundedKeys(t *testing.T) {
+	bb := newTestKeyBasedBatchBuilder(t, -1, EvictLargestKeyBatch)
+
+	for _, key := range []string{"a", "b", "c", "d"} {
+		addKeyMessage(t, bb, key, []byte(key))
+	}
+	data, _, _, _ := bb.FlushEvicted()
+	assert.Nil(t, data)
+	assert.Equal(t, 4, len(bb.batches.containers))
+}
+
+func TestBatchBuilderLZ4Frame(t *testing.T) {
+	for _, newBuilder := range []BatcherBuilderProvider{NewBatchBuilder, NewKeyBasedBatchBuilder} {
+		bb, err := newBuilder(1000, 128*1024, 5*1024*1024, "test", 1,
+			CompressionTypeLZ4Frame, 0, testBuffersPool{}, log.DefaultNopLogger(), crypto.NewNoopEncryptor())
+		assert.Nil(t, err)
+
+		var metadata *pb.MessageMetadata
+		var provider compression.Provider
+		switch b := bb.(type) {
+		case *batchContainer:
+			metadata, provider = b.msgMetadata, b.compressionProvider
+		case *keyBasedBatchContainer:
+			metadata, provider = b.msgMetadata, b.compressionProvider
+		}
+		// the frames are sent with the LZ4 compression type
+		assert.Equal(t, pb.CompressionType_LZ4, metadata.GetCompression())
+		assert.Equal(t, []byte{0x04, 0x22, 0x4d, 0x18}, provider.Compress(nil, []byte("hello"))[:4])
+	}
+}
@@ -0,0 +1,61 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package internal
+
+// LookupOverride is the static lookup result of a topic, or the error returned by its lookups.
+type LookupOverride struct {
+	Result *LookupResult
+	Err    error
+}
+
+// overrideLookupService returns static lookup results for some topics and delegates the other
+// lookups to the wrapped lookup service.
+type overrideLookupService struct {
+	LookupService
+	overrides map[string]LookupOverride
+}
+
+// NewLookupServiceWithOverrides wraps the lookup service to pin topics to static brokers.
+// The overrides are keyed by fully qualified topic name, an override of a partitioned topic applies
+// to all its partitions unless a partition has its own override.
+func NewLookupServiceWithOverrides(ls LookupService, overrides map[string]LookupOverride) LookupService {
+	return &overrideLookupService{
+		LookupService: ls,
+		overrides:     overrides,
+	}
+}
+
+func (ls *overrideLookupService) Lookup(topic string) (*LookupResult, error) {
+	tn, err := ParseTopicName(topic)
+	if err != nil {
+		return ls.LookupService.Lookup(topic)
+	}
+
+	override, ok := ls.overrides[tn.Name]
+	if !ok && tn.Partition >= 0 {
+		override, ok = ls.overrides[TopicNameWithoutPartitionPart(tn)]
+	}
+	if !ok {
+		return ls.LookupService.Lookup(topic)
+	}
+
+	if override.Err != nil {
+		return nil, override.Err
+	}
+	return override.Result, nil
+}
@@ -0,0 +1,74 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package internal
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type mockDelegateLookupService struct {
+	LookupService
+	lookups []string
+}
+
+func (m *mockDelegateLookupService) Lookup(topic string) (*LookupResult, error) {
+	m.lookups = append(m.lookups, topic)
+	return &LookupResult{}, nil
+}
+
+func TestLookupServiceWithOverrides(t *testing.T) {
+	broker1, _ := url.Parse("pulsar://broker-1:6650")
+	broker2, _ := url.Parse("pulsar://broker-2:6650")
+	proxy, _ := url.Parse("pulsar://proxy:6650")
+	lookupErr := errors.New("lookup failed")
+
+	delegate := &mockDelegateLookupService{}
+	ls := NewLookupServiceWithOverrides(delegate, map[string]LookupOverride{
+		"persistent://public/default/pinned": {
+			Result: &LookupResult{LogicalAddr: broker1, PhysicalAddr: proxy},
+		},
+		"persistent://public/default/pinned-partition-1": {
+			Result: &LookupResult{LogicalAddr: broker2, PhysicalAddr: broker2},
+		},
+		"persistent://public/default/failing": {Err: lookupErr},
+	})
+
+	res, err := ls.Lookup("persistent://public/default/pinned")
+	assert.Nil(t, err)
+	assert.Equal(t, broker1, res.LogicalAddr)
+	assert.Equal(t, proxy, res.PhysicalAddr)
+
+	res, err = ls.Lookup("pinned-partition-0")
+	assert.Nil(t, err)
+	assert.Equal(t, broker1, res.LogicalAddr)
+
+	res, err = ls.Lookup("persistent://public/default/pinned-partition-1")
+	assert.Nil(t, err)
+	assert.Equal(t, broker2, res.LogicalAddr)
+
+	_, err = ls.Lookup("persistent://public/default/failing")
+	assert.Equal(t, lookupErr, err)
+
+	_, err = ls.Lookup("persistent://public/default/other")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"persistent://public/default/other"}, delegate.lookups)
+}
@@ -18,9 +18,12 @@
 package internal
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net/url"
+	"strings"
+	"time"
 
 	"google.golang.org/protobuf/proto"
 
@@ -28,6 +31,16 @@ import (
 	"github.com/apache/pulsar-client-go/pulsar/log"
 )
 
+var (
+	// ErrLookupRedirectLoop is returned when the brokers redirect a lookup back to a broker already asked
+	ErrLookupRedirectLoop = errors.New("lookup redirect loop")
+	// ErrMaxLookupRedirects is returned when a lookup is redirected more than the max number of redirects
+	ErrMaxLookupRedirects = errors.New("exceeded max number of redirection during topic lookup")
+)
+
+// DefaultMaxLookupRedirects is the max number of times a lookup follows the redirects of the brokers
+const DefaultMaxLookupRedirects = 20
+
 // LookupResult encapsulates a struct for lookup a request, containing two parts: LogicalAddr, PhysicalAddr.
 type LookupResult struct {
 	LogicalAddr  *url.URL
@@ -70,24 +83,64 @@ type LookupService interface {
 
 type lookupService struct {
 	rpcClient           RPCClient
+	serviceURL          *url.URL
+	maxRedirects        int
 	serviceNameResolver ServiceNameResolver
 	tlsEnabled          bool
 	listenerName        string
 	log                 log.Logger
 	metrics             *Metrics
+
+	// inFlight bounds the lookup and partitioned metadata requests outstanding at once, nil when unbounded
+	inFlight Semaphore
+	// lookupTimeout bounds the wait for a slot of inFlight
+	lookupTimeout time.Duration
 }
 
 // NewLookupService init a lookup service struct and return an object of LookupService.
+// The lookup and partitioned metadata requests are pipelined on the connection, with at most
+// maxConcurrentLookups of them outstanding at once. A value <= 0 does not bound them. A request waits for at most
+// lookupTimeout for its turn, a value <= 0 does not bound the wait.
+// A lookup follows at most maxRedirects redirects, DefaultMaxLookupRedirects when <= 0.
 func NewLookupService(rpcClient RPCClient, serviceURL *url.URL, serviceNameResolver ServiceNameResolver,
-	tlsEnabled bool, listenerName string, logger log.Logger, metrics *Metrics) LookupService {
-	return &lookupService{
+	tlsEnabled bool, listenerName string, maxConcurrentLookups int, maxRedirects int, lookupTimeout time.Duration,
+	logger log.Logger, metrics *Metrics) LookupService {
+	if maxRedirects <= 0 {
+		maxRedirects = DefaultMaxLookupRedirects
+	}
+	ls := &lookupService{
 		rpcClient:           rpcClient,
+		serviceURL:          serviceURL,
+		maxRedirects:        maxRedirects,
 		serviceNameResolver: serviceNameResolver,
 		tlsEnabled:          tlsEnabled,
 		log:                 logger.SubLogger(log.Fields{"serviceURL": serviceURL}),
 		metrics:             metrics,
 		listenerName:        listenerName,
+		lookupTimeout:       lookupTimeout,
+	}
+	if maxConcurrentLookups > 0 {
+		ls.inFlight = NewSemaphore(int32(maxConcurrentLookups))
 	}
+	return ls
+}
+
+// acquire waits for a slot to send a lookup request, the returned func releases it. It fails with
+// ErrRequestTimeOut when no slot is available within the lookup timeout.
+func (ls *lookupService) acquire() (func(), error) {
+	if ls.inFlight == nil {
+		return func() {}, nil
+	}
+	ctx := context.Background()
+	if ls.lookupTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, ls.lookupTimeout)
+		defer cancel()
+	}
+	if !ls.inFlight.Acquire(ctx) {
+		return nil, ErrRequestTimeOut
+	}
+	return ls.inFlight.Release, nil
 }
 
 func (ls *lookupService) GetSchema(topic string, schemaVersion []byte) (schema *pb.Schema, err error) {
@@ -132,11 +185,14 @@ func (ls *lookupService) getBrokerAddress(lr *pb.CommandLookupTopicResponse) (lo
 	return logicalAddress, physicalAddr, nil
 }
 
-// Follow brokers redirect up to certain number of times
-const lookupResultMaxRedirect = 20
-
 func (ls *lookupService) Lookup(topic string) (*LookupResult, error) {
 	ls.metrics.LookupRequestsCount.Inc()
+	release, err := ls.acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
 	id := ls.rpcClient.NewRequestID()
 	res, err := ls.rpcClient.RequestToAnyBroker(id, pb.BaseCommand_LOOKUP, &pb.CommandLookupTopic{
 		RequestId:              &id,
@@ -149,16 +205,31 @@ func (ls *lookupService) Lookup(topic string) (*LookupResult, error) {
 	}
 	ls.log.Debugf("Got topic{%s} lookup response: %+v", topic, res)
 
-	for i := 0; i < lookupResultMaxRedirect; i++ {
+	// the brokers the lookup was redirected to, to report the chain of redirects and detect the loops
+	chain := []string{ls.serviceURL.String()}
+	visited := make(map[string]bool)
+	for i := 0; i <= ls.maxRedirects; i++ {
 		lr := res.Response.LookupTopicResponse
 		switch *lr.Response {
 
 		case pb.CommandLookupTopicResponse_Redirect:
+			if i == ls.maxRedirects {
+				break
+			}
 			logicalAddress, physicalAddr, err := ls.getBrokerAddress(lr)
 			if err != nil {
 				return nil, err
 			}
 
+			chain = append(chain, logicalAddress.String())
+			// the same broker may be asked again for an authoritative answer
+			redirect := fmt.Sprintf("%s-%t", logicalAddress, lr.GetAuthoritative())
+			if visited[redirect] {
+				return nil, fmt.Errorf("%w for topic %s: %s", ErrLookupRedirectLoop, topic,
+					strings.Join(chain, " -> "))
+			}
+			visited[redirect] = true
+
 			ls.log.Debugf("Follow topic{%s} redirect to broker. %v / %v - Use proxy: %v",
 				topic, lr.BrokerServiceUrl, lr.BrokerServiceUrlTls, lr.ProxyThroughServiceUrl)
 
@@ -200,7 +271,8 @@ func (ls *lookupService) Lookup(topic string) (*LookupResult, error) {
 		}
 	}
 
-	return nil, errors.New("exceeded max number of redirection during topic lookup")
+	return nil, fmt.Errorf("%w (%d) for topic %s: %s", ErrMaxLookupRedirects, ls.maxRedirects, topic,
+		strings.Join(chain, " -> "))
 }
 
 func (ls *lookupService) GetPartitionedTopicMetadata(topic string) (*PartitionedTopicMetadata,
@@ -211,6 +283,12 @@ func (ls *lookupService) GetPartitionedTopicMetadata(topic string) (*Partitioned
 		return nil, err
 	}
 
+	release, err := ls.acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
 	id := ls.rpcClient.NewRequestID()
 	res, err := ls.rpcClient.RequestToAnyBroker(id, pb.BaseCommand_PARTITIONED_METADATA,
 		&pb.CommandPartitionedTopicMetadata{
@@ -254,7 +332,8 @@ func (ls *lookupService) GetTopicsOfNamespace(namespace string, mode GetTopicsOf
 		return nil, err
 	}
 	if res.Response.Error != nil {
-		return []string{}, errors.New(res.Response.GetError().String())
+		return []string{}, &ServerError{Code: res.Response.GetError().GetError(),
+			Message: res.Response.GetError().GetMessage()}
 	}
 
 	return res.Response.GetTopicsOfNamespaceResponse.GetTopics(), nil
@@ -20,16 +20,21 @@ package internal
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"io"
 	"net/url"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/stretchr/testify/assert"
 	"google.golang.org/protobuf/proto"
 
+	"github.com/apache/pulsar-client-go/pulsar/auth"
 	pb "github.com/apache/pulsar-client-go/pulsar/internal/pulsar_proto"
 	"github.com/apache/pulsar-client-go/pulsar/log"
 )
@@ -45,8 +50,7 @@ type mockedLookupRPCClient struct {
 
 // Create a new unique request id
 func (c *mockedLookupRPCClient) NewRequestID() uint64 {
-	c.requestIDGenerator++
-	return c.requestIDGenerator
+	return atomic.AddUint64(&c.requestIDGenerator, 1)
 }
 
 func (c *mockedLookupRPCClient) NewProducerID() uint64 {
@@ -105,12 +109,23 @@ func (c *mockedLookupRPCClient) RequestOnCnx(cnx Connection, requestID uint64, c
 	return nil, nil
 }
 
+func (c *mockedLookupRPCClient) RequestWithAuth(provider auth.Provider, logicalAddr *url.URL,
+	physicalAddr *url.URL, requestID uint64, cmdType pb.BaseCommand_Type, message proto.Message) (*RPCResult, error) {
+	assert.Fail(c.t, "Shouldn't be called")
+	return nil, nil
+}
+
 func (c *mockedLookupRPCClient) RequestOnCnxNoWait(cnx Connection, cmdType pb.BaseCommand_Type,
 	message proto.Message) error {
 	assert.Fail(c.t, "Shouldn't be called")
 	return nil
 }
 
+func (c *mockedLookupRPCClient) RequestOnCnxAsync(cnx Connection, requestID uint64, cmdType pb.BaseCommand_Type,
+	message proto.Message, callback func(*RPCResult, error)) {
+	assert.Fail(c.t, "Shouldn't be called")
+}
+
 func responseType(r pb.CommandLookupTopicResponse_LookupType) *pb.CommandLookupTopicResponse_LookupType {
 	return &r
 }
@@ -142,7 +157,8 @@ func TestLookupSuccess(t *testing.T) {
 	}
 
 	metricsProvider := NewMetricsProvider(4, map[string]string{}, prometheus.DefaultRegisterer)
-	ls := NewLookupService(mockedClient, url, serviceNameResolver, false, "", log.DefaultNopLogger(), metricsProvider)
+	ls := NewLookupService(mockedClient, url, serviceNameResolver, false, "", 0, 0, 0,
+		log.DefaultNopLogger(), metricsProvider)
 
 	lr, err := ls.Lookup("my-topic")
 	assert.NoError(t, err)
@@ -178,7 +194,8 @@ func TestTlsLookupSuccess(t *testing.T) {
 	}
 	metricsProvider := NewMetricsProvider(4, map[string]string{}, prometheus.DefaultRegisterer)
 
-	ls := NewLookupService(mockedClient, url, serviceNameResolver, true, "", log.DefaultNopLogger(), metricsProvider)
+	ls := NewLookupService(mockedClient, url, serviceNameResolver, true, "", 0, 0, 0,
+		log.DefaultNopLogger(), metricsProvider)
 
 	lr, err := ls.Lookup("my-topic")
 	assert.NoError(t, err)
@@ -214,7 +231,8 @@ func TestLookupWithProxy(t *testing.T) {
 		},
 	}
 	metricsProvider := NewMetricsProvider(4, map[string]string{}, prometheus.DefaultRegisterer)
-	ls := NewLookupService(mockedClient, url, serviceNameResolver, false, "", log.DefaultNopLogger(), metricsProvider)
+	ls := NewLookupService(mockedClient, url, serviceNameResolver, false, "", 0, 0, 0,
+		log.DefaultNopLogger(), metricsProvider)
 
 	lr, err := ls.Lookup("my-topic")
 	assert.NoError(t, err)
@@ -251,7 +269,7 @@ func TestTlsLookupWithProxy(t *testing.T) {
 	}
 	resolver := NewPulsarServiceNameResolver(url)
 	metricsProvider := NewMetricsProvider(4, map[string]string{}, prometheus.DefaultRegisterer)
-	ls := NewLookupService(mockedClient, url, resolver, true, "", log.DefaultNopLogger(), metricsProvider)
+	ls := NewLookupService(mockedClient, url, resolver, true, "", 0, 0, 0, log.DefaultNopLogger(), metricsProvider)
 
 	lr, err := ls.Lookup("my-topic")
 	assert.NoError(t, err)
@@ -300,7 +318,7 @@ func TestLookupWithRedirect(t *testing.T) {
 	}
 	resolver := NewPulsarServiceNameResolver(url)
 	metricsProvider := NewMetricsProvider(4, map[string]string{}, prometheus.DefaultRegisterer)
-	ls := NewLookupService(mockedClient, url, resolver, false, "", log.DefaultNopLogger(), metricsProvider)
+	ls := NewLookupService(mockedClient, url, resolver, false, "", 0, 0, 0, log.DefaultNopLogger(), metricsProvider)
 
 	lr, err := ls.Lookup("my-topic")
 	assert.NoError(t, err)
@@ -350,7 +368,7 @@ func TestTlsLookupWithRedirect(t *testing.T) {
 
 	resolver := NewPulsarServiceNameResolver(url)
 	metricsProvider := NewMetricsProvider(4, map[string]string{}, prometheus.DefaultRegisterer)
-	ls := NewLookupService(mockedClient, url, resolver, true, "", log.DefaultNopLogger(), metricsProvider)
+	ls := NewLookupService(mockedClient, url, resolver, true, "", 0, 0, 0, log.DefaultNopLogger(), metricsProvider)
 
 	lr, err := ls.Lookup("my-topic")
 	assert.NoError(t, err)
@@ -387,7 +405,7 @@ func TestLookupWithInvalidUrlResponse(t *testing.T) {
 	}
 	resolver := NewPulsarServiceNameResolver(url)
 	metricsProvider := NewMetricsProvider(4, map[string]string{}, prometheus.DefaultRegisterer)
-	ls := NewLookupService(mockedClient, url, resolver, false, "", log.DefaultNopLogger(), metricsProvider)
+	ls := NewLookupService(mockedClient, url, resolver, false, "", 0, 0, 0, log.DefaultNopLogger(), metricsProvider)
 
 	lr, err := ls.Lookup("my-topic")
 	assert.Error(t, err)
@@ -420,7 +438,7 @@ func TestLookupWithLookupFailure(t *testing.T) {
 
 	resolver := NewPulsarServiceNameResolver(url)
 	metricsProvider := NewMetricsProvider(4, map[string]string{}, prometheus.DefaultRegisterer)
-	ls := NewLookupService(mockedClient, url, resolver, false, "", log.DefaultNopLogger(), metricsProvider)
+	ls := NewLookupService(mockedClient, url, resolver, false, "", 0, 0, 0, log.DefaultNopLogger(), metricsProvider)
 
 	lr, err := ls.Lookup("my-topic")
 	assert.Error(t, err)
@@ -474,12 +492,23 @@ func (m mockedPartitionedTopicMetadataRPCClient) Request(logicalAddr *url.URL, p
 	return nil, nil
 }
 
+func (m mockedPartitionedTopicMetadataRPCClient) RequestWithAuth(provider auth.Provider, logicalAddr *url.URL,
+	physicalAddr *url.URL, requestID uint64, cmdType pb.BaseCommand_Type, message proto.Message) (*RPCResult, error) {
+	assert.Fail(m.t, "Shouldn't be called")
+	return nil, nil
+}
+
 func (m mockedPartitionedTopicMetadataRPCClient) RequestOnCnxNoWait(cnx Connection, cmdType pb.BaseCommand_Type,
 	message proto.Message) error {
 	assert.Fail(m.t, "Shouldn't be called")
 	return nil
 }
 
+func (m mockedPartitionedTopicMetadataRPCClient) RequestOnCnxAsync(cnx Connection, requestID uint64,
+	cmdType pb.BaseCommand_Type, message proto.Message, callback func(*RPCResult, error)) {
+	assert.Fail(m.t, "Shouldn't be called")
+}
+
 func (m mockedPartitionedTopicMetadataRPCClient) RequestOnCnx(cnx Connection, requestID uint64,
 	cmdType pb.BaseCommand_Type, message proto.Message) (*RPCResult, error) {
 	assert.Fail(m.t, "Shouldn't be called")
@@ -507,7 +536,7 @@ func TestGetPartitionedTopicMetadataSuccess(t *testing.T) {
 				Response:   pb.CommandPartitionedTopicMetadataResponse_Success.Enum(),
 			},
 		},
-	}, url, serviceNameResolver, false, "", log.DefaultNopLogger(),
+	}, url, serviceNameResolver, false, "", 0, 0, 0, log.DefaultNopLogger(),
 		NewMetricsProvider(4, map[string]string{}, prometheus.DefaultRegisterer))
 
 	metadata, err := ls.GetPartitionedTopicMetadata("my-topic")
@@ -540,7 +569,7 @@ func TestLookupSuccessWithMultipleHosts(t *testing.T) {
 				BrokerServiceUrl: proto.String("pulsar://broker-1:6650"),
 			},
 		},
-	}, url, serviceNameResolver, false, "", log.DefaultNopLogger(),
+	}, url, serviceNameResolver, false, "", 0, 0, 0, log.DefaultNopLogger(),
 		NewMetricsProvider(4, map[string]string{}, prometheus.DefaultRegisterer))
 
 	lr, err := ls.Lookup("my-topic")
@@ -567,6 +596,18 @@ func (c *MockHTTPClient) Get(endpoint string, obj interface{}, params map[string
 	return errors.New("not supported request")
 }
 
+func (c *MockHTTPClient) Put(endpoint string, in interface{}, params map[string]string) error {
+	return errors.New("not supported request")
+}
+
+func (c *MockHTTPClient) Post(endpoint string, in interface{}, params map[string]string) error {
+	return errors.New("not supported request")
+}
+
+func (c *MockHTTPClient) Delete(endpoint string, params map[string]string) error {
+	return errors.New("not supported request")
+}
+
 func mockHTTPGetLookupResult(obj interface{}) error {
 	jsonResponse := `{
    		"brokerUrl": "pulsar://broker-1:6650",
@@ -626,3 +667,173 @@ func TestHttpGetPartitionedTopicMetadataSuccess(t *testing.T) {
 
 	assert.Equal(t, 1, tMetadata.Partitions)
 }
+
+type blockingLookupRPCClient struct {
+	mockedLookupRPCClient
+
+	sync.Mutex
+	inFlight    int
+	maxInFlight int
+	started     chan struct{}
+	unblock     chan struct{}
+}
+
+func (c *blockingLookupRPCClient) RequestToAnyBroker(requestID uint64, cmdType pb.BaseCommand_Type,
+	message proto.Message) (*RPCResult, error) {
+	c.Lock()
+	c.inFlight++
+	if c.inFlight > c.maxInFlight {
+		c.maxInFlight = c.inFlight
+	}
+	c.Unlock()
+	c.started <- struct{}{}
+
+	<-c.unblock
+
+	c.Lock()
+	c.inFlight--
+	c.Unlock()
+	return &RPCResult{
+		Response: &pb.BaseCommand{
+			LookupTopicResponse: &pb.CommandLookupTopicResponse{
+				RequestId:        proto.Uint64(requestID),
+				Response:         responseType(pb.CommandLookupTopicResponse_Connect),
+				BrokerServiceUrl: proto.String("pulsar://broker-1:6650"),
+			},
+		},
+	}, nil
+}
+
+func TestLookupPipelining(t *testing.T) {
+	url, err := url.Parse("pulsar://example:6650")
+	assert.NoError(t, err)
+
+	for _, limit := range []int{0, 2} {
+		rpc := &blockingLookupRPCClient{
+			started: make(chan struct{}, 10),
+			unblock: make(chan struct{}),
+		}
+		ls := NewLookupService(rpc, url, NewPulsarServiceNameResolver(url), false, "", limit, 0, 0,
+			log.DefaultNopLogger(), NewMetricsProvider(4, map[string]string{}, prometheus.DefaultRegisterer))
+
+		var wg sync.WaitGroup
+		for i := 0; i < 5; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				lr, err := ls.Lookup(fmt.Sprintf("my-topic-%d", i))
+				assert.NoError(t, err)
+				assert.Equal(t, "pulsar://broker-1:6650", lr.LogicalAddr.String())
+			}(i)
+		}
+
+		expected := 5
+		if limit > 0 {
+			expected = limit
+		}
+		for i := 0; i < expected; i++ {
+			<-rpc.started
+		}
+		select {
+		case <-rpc.started:
+			t.Fatalf("more than %d lookups outstanding", expected)
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		close(rpc.unblock)
+		wg.Wait()
+		assert.Equal(t, expected, rpc.maxInFlight)
+	}
+}
+
+// redirectingLookupRPCClient redirects the lookups to the brokers in turn
+func TestLookupPipeliningTimeout(t *testing.T) {
+	url, err := url.Parse("pulsar://example:6650")
+	assert.NoError(t, err)
+
+	rpc := &blockingLookupRPCClient{
+		started: make(chan struct{}, 10),
+		unblock: make(chan struct{}),
+	}
+	ls := NewLookupService(rpc, url, NewPulsarServiceNameResolver(url), false, "", 1, 0, 50*time.Millisecond,
+		log.DefaultNopLogger(), NewMetricsProvider(4, map[string]string{}, prometheus.DefaultRegisterer))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, err := ls.Lookup("my-topic-1")
+		assert.NoError(t, err)
+	}()
+	<-rpc.started
+
+	// the lookup waiting for its turn fails once the lookup timeout expires
+	_, err = ls.Lookup("my-topic-2")
+	assert.ErrorIs(t, err, ErrRequestTimeOut)
+	_, err = ls.GetPartitionedTopicMetadata("my-topic-2")
+	assert.ErrorIs(t, err, ErrRequestTimeOut)
+
+	close(rpc.unblock)
+	<-done
+}
+
+type redirectingLookupRPCClient struct {
+	mockedLookupRPCClient
+	brokers []string
+}
+
+func (c *redirectingLookupRPCClient) redirect() *RPCResult {
+	broker := c.brokers[0]
+	c.brokers = append(c.brokers[1:], broker)
+	return &RPCResult{
+		Response: &pb.BaseCommand{
+			LookupTopicResponse: &pb.CommandLookupTopicResponse{
+				Response:         responseType(pb.CommandLookupTopicResponse_Redirect),
+				BrokerServiceUrl: proto.String(broker),
+			},
+		},
+	}
+}
+
+func (c *redirectingLookupRPCClient) RequestToAnyBroker(requestID uint64, cmdType pb.BaseCommand_Type,
+	message proto.Message) (*RPCResult, error) {
+	return c.redirect(), nil
+}
+
+func (c *redirectingLookupRPCClient) Request(logicalAddr *url.URL, physicalAddr *url.URL, requestID uint64,
+	cmdType pb.BaseCommand_Type, message proto.Message) (*RPCResult, error) {
+	return c.redirect(), nil
+}
+
+func TestLookupRedirectLoop(t *testing.T) {
+	url, err := url.Parse("pulsar://example:6650")
+	assert.NoError(t, err)
+
+	ls := NewLookupService(&redirectingLookupRPCClient{
+		brokers: []string{"pulsar://broker-1:6650", "pulsar://broker-2:6650"},
+	}, url, NewPulsarServiceNameResolver(url), false, "", 0, 0, 0, log.DefaultNopLogger(),
+		NewMetricsProvider(4, map[string]string{}, prometheus.DefaultRegisterer))
+
+	_, err = ls.Lookup("my-topic")
+	assert.ErrorIs(t, err, ErrLookupRedirectLoop)
+	assert.Contains(t, err.Error(),
+		"pulsar://example:6650 -> pulsar://broker-1:6650 -> pulsar://broker-2:6650 -> pulsar://broker-1:6650")
+}
+
+func TestLookupMaxRedirects(t *testing.T) {
+	url, err := url.Parse("pulsar://example:6650")
+	assert.NoError(t, err)
+
+	brokers := make([]string, 10)
+	for i := range brokers {
+		brokers[i] = fmt.Sprintf("pulsar://broker-%d:6650", i)
+	}
+	ls := NewLookupService(&redirectingLookupRPCClient{brokers: brokers},
+		url, NewPulsarServiceNameResolver(url), false, "", 0, 3, 0, log.DefaultNopLogger(),
+		NewMetricsProvider(4, map[string]string{}, prometheus.DefaultRegisterer))
+
+	_, err = ls.Lookup("my-topic")
+	assert.ErrorIs(t, err, ErrMaxLookupRedirects)
+	assert.Contains(t, err.Error(), "(3)")
+	assert.Contains(t, err.Error(), "pulsar://broker-1:6650 -> pulsar://broker-2:6650")
+	assert.NotContains(t, err.Error(), "broker-3")
+}
@@ -31,6 +31,7 @@ type MemoryLimitController interface {
 	CurrentUsage() int64
 	CurrentUsagePercent() float64
 	IsMemoryLimited() bool
+	SetLimit(limit int64)
 }
 
 type memoryLimitController struct {
@@ -67,7 +68,7 @@ func (m *memoryLimitController) TryReserveMemory(size int64) bool {
 		newUsage := current + size
 
 		// This condition means we allowed one request to go over the limit.
-		if m.IsMemoryLimited() && current > m.limit {
+		if m.IsMemoryLimited() && current > atomic.LoadInt64(&m.limit) {
 			return false
 		}
 
@@ -83,7 +84,8 @@ func (m *memoryLimitController) ForceReserveMemory(size int64) {
 
 func (m *memoryLimitController) ReleaseMemory(size int64) {
 	newUsage := atomic.AddInt64(&m.currentUsage, -size)
-	if newUsage+size > m.limit && newUsage <= m.limit {
+	limit := atomic.LoadInt64(&m.limit)
+	if newUsage+size > limit && newUsage <= limit {
 		m.chCond.broadcast()
 	}
 }
@@ -93,9 +95,17 @@ func (m *memoryLimitController) CurrentUsage() int64 {
 }
 
 func (m *memoryLimitController) CurrentUsagePercent() float64 {
-	return float64(atomic.LoadInt64(&m.currentUsage)) / float64(m.limit)
+	return float64(atomic.LoadInt64(&m.currentUsage)) / float64(atomic.LoadInt64(&m.limit))
 }
 
 func (m *memoryLimitController) IsMemoryLimited() bool {
-	return m.limit > 0
+	return atomic.LoadInt64(&m.limit) > 0
+}
+
+// SetLimit changes the limit, the callers waiting for memory are woken up if the limit is raised
+func (m *memoryLimitController) SetLimit(limit int64) {
+	old := atomic.SwapInt64(&m.limit, limit)
+	if limit <= 0 || (old > 0 && limit > old) {
+		m.chCond.broadcast()
+	}
 }
@@ -169,6 +169,28 @@ func TestStepRelease(t *testing.T) {
 	assert.Equal(t, int64(101), mlc.CurrentUsage())
 }
 
+func TestSetLimit(t *testing.T) {
+	mlc := NewMemoryLimitController(100)
+	assert.True(t, mlc.TryReserveMemory(101))
+
+	ch := make(chan int, 1)
+	go reserveMemory(mlc, ch)
+	assert.False(t, awaitCh(ch))
+
+	// raising the limit unblocks the waiting reservations
+	mlc.SetLimit(200)
+	assert.True(t, awaitCh(ch))
+	assert.Equal(t, int64(102), mlc.CurrentUsage())
+
+	mlc.SetLimit(50)
+	assert.False(t, mlc.TryReserveMemory(1))
+	assert.InDelta(t, 2.04, mlc.CurrentUsagePercent(), 0.000001)
+
+	mlc.SetLimit(-1)
+	assert.False(t, mlc.IsMemoryLimited())
+	assert.True(t, mlc.TryReserveMemory(1))
+}
+
 func reserveMemory(mlc MemoryLimitController, ch chan int) {
 	mlc.ReserveMemory(context.Background(), 1)
 	ch <- 1
@@ -23,6 +23,7 @@ import (
 
 type Metrics struct {
 	metricsLevel      int
+	registerer        prometheus.Registerer
 	messagesPublished *prometheus.CounterVec
 	bytesPublished    *prometheus.CounterVec
 	messagesPending   *prometheus.GaugeVec
@@ -30,6 +31,8 @@ type Metrics struct {
 	publishErrors     *prometheus.CounterVec
 	publishLatency    *prometheus.HistogramVec
 	publishRPCLatency *prometheus.HistogramVec
+	transformLatency  *prometheus.HistogramVec
+	transformErrors   *prometheus.CounterVec
 
 	messagesReceived   *prometheus.CounterVec
 	bytesReceived      *prometheus.CounterVec
@@ -39,6 +42,25 @@ type Metrics struct {
 	nacksCounter       *prometheus.CounterVec
 	dlqCounter         *prometheus.CounterVec
 	processingTime     *prometheus.HistogramVec
+	messagesRejected   *prometheus.CounterVec
+	chunkedPending     *prometheus.GaugeVec
+	chunkedBytes       *prometheus.GaugeVec
+	chunkedDiscarded   *prometheus.CounterVec
+	publishTimeSkew    *prometheus.GaugeVec
+	messageSize        *prometheus.HistogramVec
+	oversizedMessages  *prometheus.CounterVec
+
+	ackGroupingPendingAcks      *prometheus.GaugeVec
+	ackGroupingFlushes          *prometheus.CounterVec
+	ackGroupingFlushLatency     *prometheus.HistogramVec
+	ackGroupingCumulativeAckLag *prometheus.GaugeVec
+	acksResent                  *prometheus.CounterVec
+
+	// consumer metrics labeled with the subscription
+	redeliveryCount   *prometheus.HistogramVec
+	dlqPublished      *prometheus.CounterVec
+	rlqPublished      *prometheus.CounterVec
+	subscriptionNacks *prometheus.CounterVec
 
 	producersOpened            *prometheus.CounterVec
 	producersClosed            *prometheus.CounterVec
@@ -55,12 +77,20 @@ type Metrics struct {
 
 	// Metrics that are not labeled with specificity are immediately available
 	ConnectionsOpened                     prometheus.Counter
+	ConnectionsOpenedByAddressFamily      *prometheus.CounterVec
 	ConnectionsClosed                     prometheus.Counter
 	ConnectionsEstablishmentErrors        prometheus.Counter
+	ConnectionsRejected                   prometheus.Counter
 	ConnectionsHandshakeErrors            prometheus.Counter
+	TLSHandshakeLatency                   prometheus.Histogram
+	TLSSessionsResumed                    prometheus.Counter
+	TLSCertificateExpiry                  *prometheus.GaugeVec
 	LookupRequestsCount                   prometheus.Counter
 	PartitionedTopicMetadataRequestsCount prometheus.Counter
 	RPCRequestCount                       prometheus.Counter
+	RetriesRejected                       prometheus.Counter
+	CircuitBreakerState                   *prometheus.GaugeVec
+	CircuitBreakerRejected                *prometheus.CounterVec
 }
 
 type LeveledMetrics struct {
@@ -72,6 +102,9 @@ type LeveledMetrics struct {
 	PublishErrorsMsgTooLarge prometheus.Counter
 	PublishLatency           prometheus.Observer
 	PublishRPCLatency        prometheus.Observer
+	// PayloadTransformLatency and PayloadTransformErrors are labeled with the stage of the payload transformers
+	PayloadTransformLatency prometheus.ObserverVec
+	PayloadTransformErrors  *prometheus.CounterVec
 
 	MessagesReceived   prometheus.Counter
 	BytesReceived      prometheus.Counter
@@ -81,6 +114,36 @@ type LeveledMetrics struct {
 	NacksCounter       prometheus.Counter
 	DlqCounter         prometheus.Counter
 	ProcessingTime     prometheus.Observer
+	// MessagesRejected is labeled with the reason and the disposition of the rejection
+	MessagesRejected *prometheus.CounterVec
+	// ChunkedMessagesPending and ChunkedBytesBuffered are the incomplete chunked messages being reassembled
+	ChunkedMessagesPending prometheus.Gauge
+	ChunkedBytesBuffered   prometheus.Gauge
+	// ChunkedMessagesDiscarded is labeled with the reason the incomplete chunked messages are discarded
+	ChunkedMessagesDiscarded *prometheus.CounterVec
+	// PublishTimeSkew is the time the broker received the last message received with broker entry metadata
+	// minus its publish time
+	PublishTimeSkew prometheus.Gauge
+	// MessageSize is the size of the payloads of the messages received, OversizedMessages the number of them
+	// above ConsumerOptions.OversizedMessageThreshold which were not chunked
+	MessageSize       prometheus.Observer
+	OversizedMessages prometheus.Counter
+	// AckGroupingPendingAcks is the number of acknowledgments cached by the ack grouping trackers,
+	// AckGroupingFlushes is labeled with what triggered the flushes sending acknowledgments: size, time or request
+	AckGroupingPendingAcks  prometheus.Gauge
+	AckGroupingFlushes      *prometheus.CounterVec
+	AckGroupingFlushLatency prometheus.Observer
+	// AckGroupingCumulativeAckLag is the time the last cumulative acknowledgment sent waited in the tracker
+	AckGroupingCumulativeAckLag prometheus.Gauge
+	// AcksResent is the number of acknowledgments sent again after a reconnection, which failed to be sent while
+	// the consumer was disconnected
+	AcksResent prometheus.Counter
+
+	// Only available from GetLeveledConsumerMetrics
+	RedeliveryCount   prometheus.Observer
+	DlqPublished      prometheus.Counter
+	RlqPublished      prometheus.Counter
+	SubscriptionNacks prometheus.Counter
 
 	ProducersOpened            prometheus.Counter
 	ProducersClosed            prometheus.Counter
@@ -121,8 +184,11 @@ func NewMetricsProvider(metricsCardinality int, userDefinedLabels map[string]str
 		metricsLevelLabels = []string{"pulsar_tenant", "pulsar_namespace"}
 	}
 
+	subscriptionLabels := append(append([]string{}, metricsLevelLabels...), "subscription")
+
 	metrics := &Metrics{
 		metricsLevel: metricsCardinality,
+		registerer:   registerer,
 		messagesPublished: prometheus.NewCounterVec(prometheus.CounterOpts{
 			Name:        "pulsar_client_messages_published",
 			Help:        "Counter of messages published by the client",
@@ -167,6 +233,19 @@ func NewMetricsProvider(metricsCardinality int, userDefinedLabels map[string]str
 			Buckets:     []float64{.0005, .001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
 		}, metricsLevelLabels),
 
+		transformLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:        "pulsar_client_producer_payload_transform_seconds",
+			Help:        "Time spent in each stage of the payload transformers of the producers",
+			ConstLabels: constLabels,
+			Buckets:     []float64{.00001, .0001, .0005, .001, .005, .01, .05, .1},
+		}, append(append([]string{}, metricsLevelLabels...), "stage")),
+
+		transformErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name:        "pulsar_client_producer_payload_transform_errors",
+			Help:        "Counter of messages failed by each stage of the payload transformers of the producers",
+			ConstLabels: constLabels,
+		}, append(append([]string{}, metricsLevelLabels...), "stage")),
+
 		producersOpened: prometheus.NewCounterVec(prometheus.CounterOpts{
 			Name:        "pulsar_client_producers_opened",
 			Help:        "Counter of producers created by the client",
@@ -269,6 +348,81 @@ func NewMetricsProvider(metricsCardinality int, userDefinedLabels map[string]str
 			ConstLabels: constLabels,
 		}, metricsLevelLabels),
 
+		messagesRejected: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name:        "pulsar_client_consumer_rejected_messages",
+			Help:        "Counter of messages rejected by the filter or the schema validation of the consumers",
+			ConstLabels: constLabels,
+		}, append(append([]string{}, metricsLevelLabels...), "reason", "disposition")),
+
+		chunkedPending: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name:        "pulsar_client_consumer_chunked_messages_pending",
+			Help:        "Number of incomplete chunked messages being reassembled by the consumers",
+			ConstLabels: constLabels,
+		}, metricsLevelLabels),
+
+		chunkedBytes: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name:        "pulsar_client_consumer_chunked_bytes_buffered",
+			Help:        "Bytes buffered to reassemble the incomplete chunked messages of the consumers",
+			ConstLabels: constLabels,
+		}, metricsLevelLabels),
+
+		chunkedDiscarded: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name:        "pulsar_client_consumer_chunked_messages_discarded",
+			Help:        "Counter of incomplete chunked messages discarded, because expired or over the limits",
+			ConstLabels: constLabels,
+		}, append(append([]string{}, metricsLevelLabels...), "reason")),
+
+		publishTimeSkew: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "pulsar_client_consumer_publish_time_skew_seconds",
+			Help: "Time the broker received the last message received with broker entry metadata minus its " +
+				"publish time, the skew of the broker clock ahead of the producer clock plus the publish latency",
+			ConstLabels: constLabels,
+		}, metricsLevelLabels),
+
+		messageSize: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:        "pulsar_client_consumer_message_size_bytes",
+			Help:        "Size of the payloads of the messages received",
+			Buckets:     prometheus.ExponentialBuckets(128, 4, 10),
+			ConstLabels: constLabels,
+		}, metricsLevelLabels),
+
+		oversizedMessages: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name:        "pulsar_client_consumer_oversized_messages",
+			Help:        "Counter of messages received above the oversized message threshold without being chunked",
+			ConstLabels: constLabels,
+		}, metricsLevelLabels),
+
+		acksResent: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name:        "pulsar_client_consumer_acks_resent",
+			Help:        "Counter of acknowledgments sent again after a reconnection, which failed to be sent before",
+			ConstLabels: constLabels,
+		}, metricsLevelLabels),
+
+		ackGroupingPendingAcks: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name:        "pulsar_client_consumer_ack_grouping_pending_acks",
+			Help:        "Number of acknowledgments cached by the ack grouping trackers of the consumers",
+			ConstLabels: constLabels,
+		}, metricsLevelLabels),
+
+		ackGroupingFlushes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name:        "pulsar_client_consumer_ack_grouping_flushes",
+			Help:        "Counter of flushes of the ack grouping trackers sending acknowledgments, by trigger",
+			ConstLabels: constLabels,
+		}, append(append([]string{}, metricsLevelLabels...), "trigger")),
+
+		ackGroupingFlushLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:        "pulsar_client_consumer_ack_grouping_flush_latency_seconds",
+			Help:        "Time spent sending the acknowledgments flushed by the ack grouping trackers",
+			Buckets:     []float64{.00001, .0001, .0005, .001, .005, .01, .05, .1},
+			ConstLabels: constLabels,
+		}, metricsLevelLabels),
+
+		ackGroupingCumulativeAckLag: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name:        "pulsar_client_consumer_ack_grouping_cumulative_ack_lag_seconds",
+			Help:        "Time the last cumulative acknowledgment sent waited in the ack grouping tracker",
+			ConstLabels: constLabels,
+		}, metricsLevelLabels),
+
 		processingTime: prometheus.NewHistogramVec(prometheus.HistogramOpts{
 			Name:        "pulsar_client_consumer_processing_time_seconds",
 			Help:        "Time it takes for application to process messages",
@@ -276,6 +430,31 @@ func NewMetricsProvider(metricsCardinality int, userDefinedLabels map[string]str
 			ConstLabels: constLabels,
 		}, metricsLevelLabels),
 
+		redeliveryCount: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:        "pulsar_client_consumer_redelivery_count",
+			Help:        "Redelivery count of the messages received by the client",
+			Buckets:     []float64{0, 1, 2, 3, 5, 10, 16, 25, 50, 100},
+			ConstLabels: constLabels,
+		}, subscriptionLabels),
+
+		dlqPublished: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name:        "pulsar_client_consumer_dlq_published",
+			Help:        "Counter of messages published to the dead letter topic",
+			ConstLabels: constLabels,
+		}, subscriptionLabels),
+
+		rlqPublished: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name:        "pulsar_client_consumer_rlq_published",
+			Help:        "Counter of messages published to the retry letter topic",
+			ConstLabels: constLabels,
+		}, subscriptionLabels),
+
+		subscriptionNacks: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name:        "pulsar_client_consumer_subscription_nacks",
+			Help:        "Counter of messages nacked by client per subscription",
+			ConstLabels: constLabels,
+		}, subscriptionLabels),
+
 		readersOpened: prometheus.NewCounterVec(prometheus.CounterOpts{
 			Name:        "pulsar_client_readers_opened",
 			Help:        "Counter of readers created by the client",
@@ -294,6 +473,12 @@ func NewMetricsProvider(metricsCardinality int, userDefinedLabels map[string]str
 			ConstLabels: constLabels,
 		}),
 
+		ConnectionsOpenedByAddressFamily: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name:        "pulsar_client_connections_opened_by_address_family",
+			Help:        "Counter of connections created by the client by address family of the broker, ipv4 or ipv6",
+			ConstLabels: constLabels,
+		}, []string{"address_family"}),
+
 		ConnectionsClosed: prometheus.NewCounter(prometheus.CounterOpts{
 			Name:        "pulsar_client_connections_closed",
 			Help:        "Counter of connections closed by the client",
@@ -306,12 +491,38 @@ func NewMetricsProvider(metricsCardinality int, userDefinedLabels map[string]str
 			ConstLabels: constLabels,
 		}),
 
+		ConnectionsRejected: prometheus.NewCounter(prometheus.CounterOpts{
+			Name:        "pulsar_client_connections_rejected",
+			Help:        "Counter of connections to addresses rejected by the endpoint filter",
+			ConstLabels: constLabels,
+		}),
+
 		ConnectionsHandshakeErrors: prometheus.NewCounter(prometheus.CounterOpts{
 			Name:        "pulsar_client_connections_handshake_errors",
 			Help:        "Counter of errors in connections handshake (eg: authz)",
 			ConstLabels: constLabels,
 		}),
 
+		TLSHandshakeLatency: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:        "pulsar_client_tls_handshake_latency_seconds",
+			Help:        "TLS handshake latency of the connections to the brokers",
+			ConstLabels: constLabels,
+			Buckets:     []float64{.0005, .001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+		}),
+
+		TLSSessionsResumed: prometheus.NewCounter(prometheus.CounterOpts{
+			Name:        "pulsar_client_tls_sessions_resumed",
+			Help:        "Counter of TLS connections established by resuming a previous TLS session",
+			ConstLabels: constLabels,
+		}),
+
+		TLSCertificateExpiry: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "pulsar_client_tls_certificate_expiry_timestamp_seconds",
+			Help: "Expiry time, in seconds since the epoch, of the client certificate and of the certificates " +
+				"served by the brokers",
+			ConstLabels: constLabels,
+		}, []string{"certificate", "broker"}),
+
 		LookupRequestsCount: prometheus.NewCounter(prometheus.CounterOpts{
 			Name:        "pulsar_client_lookup_count",
 			Help:        "Counter of lookup requests made by the client",
@@ -329,6 +540,24 @@ func NewMetricsProvider(metricsCardinality int, userDefinedLabels map[string]str
 			Help:        "Counter of RPC requests made by the client",
 			ConstLabels: constLabels,
 		}),
+
+		RetriesRejected: prometheus.NewCounter(prometheus.CounterOpts{
+			Name:        "pulsar_client_retries_rejected",
+			Help:        "Counter of retries not attempted because the retry budget of the client was exhausted",
+			ConstLabels: constLabels,
+		}),
+
+		CircuitBreakerState: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name:        "pulsar_client_circuit_breaker_state",
+			Help:        "State of the circuit breaker of the brokers, 0 closed, 1 open and 2 half-open",
+			ConstLabels: constLabels,
+		}, []string{"broker"}),
+
+		CircuitBreakerRejected: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name:        "pulsar_client_circuit_breaker_rejected",
+			Help:        "Counter of requests failed fast because the circuit breaker of the broker was open",
+			ConstLabels: constLabels,
+		}, []string{"broker"}),
 	}
 
 	err := registerer.Register(metrics.messagesPublished)
@@ -373,6 +602,18 @@ func NewMetricsProvider(metricsCardinality int, userDefinedLabels map[string]str
 			metrics.publishRPCLatency = are.ExistingCollector.(*prometheus.HistogramVec)
 		}
 	}
+	err = registerer.Register(metrics.transformLatency)
+	if err != nil {
+		if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			metrics.transformLatency = are.ExistingCollector.(*prometheus.HistogramVec)
+		}
+	}
+	err = registerer.Register(metrics.transformErrors)
+	if err != nil {
+		if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			metrics.transformErrors = are.ExistingCollector.(*prometheus.CounterVec)
+		}
+	}
 	err = registerer.Register(metrics.messagesReceived)
 	if err != nil {
 		if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
@@ -415,12 +656,108 @@ func NewMetricsProvider(metricsCardinality int, userDefinedLabels map[string]str
 			metrics.dlqCounter = are.ExistingCollector.(*prometheus.CounterVec)
 		}
 	}
+	err = registerer.Register(metrics.messagesRejected)
+	if err != nil {
+		if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			metrics.messagesRejected = are.ExistingCollector.(*prometheus.CounterVec)
+		}
+	}
+	err = registerer.Register(metrics.chunkedPending)
+	if err != nil {
+		if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			metrics.chunkedPending = are.ExistingCollector.(*prometheus.GaugeVec)
+		}
+	}
+	err = registerer.Register(metrics.chunkedBytes)
+	if err != nil {
+		if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			metrics.chunkedBytes = are.ExistingCollector.(*prometheus.GaugeVec)
+		}
+	}
+	err = registerer.Register(metrics.chunkedDiscarded)
+	if err != nil {
+		if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			metrics.chunkedDiscarded = are.ExistingCollector.(*prometheus.CounterVec)
+		}
+	}
+	err = registerer.Register(metrics.publishTimeSkew)
+	if err != nil {
+		if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			metrics.publishTimeSkew = are.ExistingCollector.(*prometheus.GaugeVec)
+		}
+	}
+	err = registerer.Register(metrics.messageSize)
+	if err != nil {
+		if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			metrics.messageSize = are.ExistingCollector.(*prometheus.HistogramVec)
+		}
+	}
+	err = registerer.Register(metrics.oversizedMessages)
+	if err != nil {
+		if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			metrics.oversizedMessages = are.ExistingCollector.(*prometheus.CounterVec)
+		}
+	}
+	err = registerer.Register(metrics.acksResent)
+	if err != nil {
+		if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			metrics.acksResent = are.ExistingCollector.(*prometheus.CounterVec)
+		}
+	}
+	err = registerer.Register(metrics.ackGroupingPendingAcks)
+	if err != nil {
+		if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			metrics.ackGroupingPendingAcks = are.ExistingCollector.(*prometheus.GaugeVec)
+		}
+	}
+	err = registerer.Register(metrics.ackGroupingFlushes)
+	if err != nil {
+		if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			metrics.ackGroupingFlushes = are.ExistingCollector.(*prometheus.CounterVec)
+		}
+	}
+	err = registerer.Register(metrics.ackGroupingFlushLatency)
+	if err != nil {
+		if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			metrics.ackGroupingFlushLatency = are.ExistingCollector.(*prometheus.HistogramVec)
+		}
+	}
+	err = registerer.Register(metrics.ackGroupingCumulativeAckLag)
+	if err != nil {
+		if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			metrics.ackGroupingCumulativeAckLag = are.ExistingCollector.(*prometheus.GaugeVec)
+		}
+	}
 	err = registerer.Register(metrics.processingTime)
 	if err != nil {
 		if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
 			metrics.processingTime = are.ExistingCollector.(*prometheus.HistogramVec)
 		}
 	}
+	err = registerer.Register(metrics.redeliveryCount)
+	if err != nil {
+		if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			metrics.redeliveryCount = are.ExistingCollector.(*prometheus.HistogramVec)
+		}
+	}
+	err = registerer.Register(metrics.dlqPublished)
+	if err != nil {
+		if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			metrics.dlqPublished = are.ExistingCollector.(*prometheus.CounterVec)
+		}
+	}
+	err = registerer.Register(metrics.rlqPublished)
+	if err != nil {
+		if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			metrics.rlqPublished = are.ExistingCollector.(*prometheus.CounterVec)
+		}
+	}
+	err = registerer.Register(metrics.subscriptionNacks)
+	if err != nil {
+		if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			metrics.subscriptionNacks = are.ExistingCollector.(*prometheus.CounterVec)
+		}
+	}
 	err = registerer.Register(metrics.producersOpened)
 	if err != nil {
 		if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
@@ -499,6 +836,12 @@ func NewMetricsProvider(metricsCardinality int, userDefinedLabels map[string]str
 			metrics.ConnectionsOpened = are.ExistingCollector.(prometheus.Counter)
 		}
 	}
+	err = registerer.Register(metrics.ConnectionsOpenedByAddressFamily)
+	if err != nil {
+		if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			metrics.ConnectionsOpenedByAddressFamily = are.ExistingCollector.(*prometheus.CounterVec)
+		}
+	}
 	err = registerer.Register(metrics.ConnectionsClosed)
 	if err != nil {
 		if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
@@ -511,12 +854,36 @@ func NewMetricsProvider(metricsCardinality int, userDefinedLabels map[string]str
 			metrics.ConnectionsEstablishmentErrors = are.ExistingCollector.(prometheus.Counter)
 		}
 	}
+	err = registerer.Register(metrics.ConnectionsRejected)
+	if err != nil {
+		if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			metrics.ConnectionsRejected = are.ExistingCollector.(prometheus.Counter)
+		}
+	}
 	err = registerer.Register(metrics.ConnectionsHandshakeErrors)
 	if err != nil {
 		if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
 			metrics.ConnectionsHandshakeErrors = are.ExistingCollector.(prometheus.Counter)
 		}
 	}
+	err = registerer.Register(metrics.TLSHandshakeLatency)
+	if err != nil {
+		if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			metrics.TLSHandshakeLatency = are.ExistingCollector.(prometheus.Histogram)
+		}
+	}
+	err = registerer.Register(metrics.TLSSessionsResumed)
+	if err != nil {
+		if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			metrics.TLSSessionsResumed = are.ExistingCollector.(prometheus.Counter)
+		}
+	}
+	err = registerer.Register(metrics.TLSCertificateExpiry)
+	if err != nil {
+		if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			metrics.TLSCertificateExpiry = are.ExistingCollector.(*prometheus.GaugeVec)
+		}
+	}
 	err = registerer.Register(metrics.LookupRequestsCount)
 	if err != nil {
 		if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
@@ -535,9 +902,106 @@ func NewMetricsProvider(metricsCardinality int, userDefinedLabels map[string]str
 			metrics.RPCRequestCount = are.ExistingCollector.(prometheus.Counter)
 		}
 	}
+	err = registerer.Register(metrics.RetriesRejected)
+	if err != nil {
+		if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			metrics.RetriesRejected = are.ExistingCollector.(prometheus.Counter)
+		}
+	}
+	err = registerer.Register(metrics.CircuitBreakerState)
+	if err != nil {
+		if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			metrics.CircuitBreakerState = are.ExistingCollector.(*prometheus.GaugeVec)
+		}
+	}
+	err = registerer.Register(metrics.CircuitBreakerRejected)
+	if err != nil {
+		if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			metrics.CircuitBreakerRejected = are.ExistingCollector.(*prometheus.CounterVec)
+		}
+	}
 	return metrics
 }
 
+// UnregisterLeveledMetrics unregisters the metrics of the producers, consumers and readers, the series of the
+// ones created with these metrics are no longer exported
+func (mp *Metrics) UnregisterLeveledMetrics() {
+	mp.unregister(
+		mp.messagesPublished,
+		mp.bytesPublished,
+		mp.messagesPending,
+		mp.bytesPending,
+		mp.publishErrors,
+		mp.publishLatency,
+		mp.publishRPCLatency,
+		mp.transformLatency,
+		mp.transformErrors,
+		mp.messagesReceived,
+		mp.bytesReceived,
+		mp.prefetchedMessages,
+		mp.prefetchedBytes,
+		mp.acksCounter,
+		mp.nacksCounter,
+		mp.dlqCounter,
+		mp.processingTime,
+		mp.messagesRejected,
+		mp.chunkedPending,
+		mp.chunkedBytes,
+		mp.chunkedDiscarded,
+		mp.publishTimeSkew,
+		mp.messageSize,
+		mp.oversizedMessages,
+		mp.ackGroupingPendingAcks,
+		mp.ackGroupingFlushes,
+		mp.ackGroupingFlushLatency,
+		mp.ackGroupingCumulativeAckLag,
+		mp.acksResent,
+		mp.redeliveryCount,
+		mp.dlqPublished,
+		mp.rlqPublished,
+		mp.subscriptionNacks,
+		mp.producersOpened,
+		mp.producersClosed,
+		mp.producersReconnectFailure,
+		mp.producersReconnectMaxRetry,
+		mp.producersPartitions,
+		mp.consumersOpened,
+		mp.consumersClosed,
+		mp.consumersReconnectFailure,
+		mp.consumersReconnectMaxRetry,
+		mp.consumersPartitions,
+		mp.readersOpened,
+		mp.readersClosed,
+	)
+}
+
+// UnregisterClientMetrics unregisters the metrics which are not labeled with specificity
+func (mp *Metrics) UnregisterClientMetrics() {
+	mp.unregister(
+		mp.ConnectionsOpened,
+		mp.ConnectionsOpenedByAddressFamily,
+		mp.ConnectionsClosed,
+		mp.ConnectionsEstablishmentErrors,
+		mp.ConnectionsRejected,
+		mp.ConnectionsHandshakeErrors,
+		mp.TLSHandshakeLatency,
+		mp.TLSSessionsResumed,
+		mp.TLSCertificateExpiry,
+		mp.LookupRequestsCount,
+		mp.PartitionedTopicMetadataRequestsCount,
+		mp.RPCRequestCount,
+		mp.RetriesRejected,
+		mp.CircuitBreakerState,
+		mp.CircuitBreakerRejected,
+	)
+}
+
+func (mp *Metrics) unregister(collectors ...prometheus.Collector) {
+	for _, c := range collectors {
+		mp.registerer.Unregister(c)
+	}
+}
+
 func (mp *Metrics) GetLeveledMetrics(t string) *LeveledMetrics {
 	labels := make(map[string]string, 3)
 	tn, err := ParseTopicName(t)
@@ -565,6 +1029,8 @@ func (mp *Metrics) GetLeveledMetrics(t string) *LeveledMetrics {
 		PublishErrorsMsgTooLarge: mp.publishErrors.With(mergeMaps(labels, map[string]string{"error": "msg_too_large"})),
 		PublishLatency:           mp.publishLatency.With(labels),
 		PublishRPCLatency:        mp.publishRPCLatency.With(labels),
+		PayloadTransformLatency:  mp.transformLatency.MustCurryWith(labels),
+		PayloadTransformErrors:   mp.transformErrors.MustCurryWith(labels),
 
 		MessagesReceived:   mp.messagesReceived.With(labels),
 		BytesReceived:      mp.bytesReceived.With(labels),
@@ -574,6 +1040,20 @@ func (mp *Metrics) GetLeveledMetrics(t string) *LeveledMetrics {
 		NacksCounter:       mp.nacksCounter.With(labels),
 		DlqCounter:         mp.dlqCounter.With(labels),
 		ProcessingTime:     mp.processingTime.With(labels),
+		MessagesRejected:   mp.messagesRejected.MustCurryWith(labels),
+
+		ChunkedMessagesPending:   mp.chunkedPending.With(labels),
+		ChunkedBytesBuffered:     mp.chunkedBytes.With(labels),
+		ChunkedMessagesDiscarded: mp.chunkedDiscarded.MustCurryWith(labels),
+		PublishTimeSkew:          mp.publishTimeSkew.With(labels),
+		MessageSize:              mp.messageSize.With(labels),
+		OversizedMessages:        mp.oversizedMessages.With(labels),
+
+		AckGroupingPendingAcks:      mp.ackGroupingPendingAcks.With(labels),
+		AckGroupingFlushes:          mp.ackGroupingFlushes.MustCurryWith(labels),
+		AckGroupingFlushLatency:     mp.ackGroupingFlushLatency.With(labels),
+		AckGroupingCumulativeAckLag: mp.ackGroupingCumulativeAckLag.With(labels),
+		AcksResent:                  mp.acksResent.With(labels),
 
 		ProducersOpened:            mp.producersOpened.With(labels),
 		ProducersClosed:            mp.producersClosed.With(labels),
@@ -592,6 +1072,34 @@ func (mp *Metrics) GetLeveledMetrics(t string) *LeveledMetrics {
 	return lm
 }
 
+// GetLeveledConsumerMetrics returns the leveled metrics of the topic, including the consumer metrics
+// labeled with the subscription.
+func (mp *Metrics) GetLeveledConsumerMetrics(t string, subscription string) *LeveledMetrics {
+	lm := mp.GetLeveledMetrics(t)
+	if lm == nil {
+		return nil
+	}
+
+	tn, _ := ParseTopicName(t)
+	labels := map[string]string{"subscription": subscription}
+	switch mp.metricsLevel {
+	case 4:
+		labels["topic"] = TopicNameWithoutPartitionPart(tn)
+		fallthrough
+	case 3:
+		labels["pulsar_namespace"] = tn.Namespace
+		fallthrough
+	case 2:
+		labels["pulsar_tenant"] = tn.Tenant
+	}
+
+	lm.RedeliveryCount = mp.redeliveryCount.With(labels)
+	lm.DlqPublished = mp.dlqPublished.With(labels)
+	lm.RlqPublished = mp.rlqPublished.With(labels)
+	lm.SubscriptionNacks = mp.subscriptionNacks.With(labels)
+	return lm
+}
+
 func mergeMaps(a, b map[string]string) map[string]string {
 	res := make(map[string]string)
 	for k, v := range a {
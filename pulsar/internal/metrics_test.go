@@ -0,0 +1,54 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package internal
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLeveledConsumerMetrics(t *testing.T) {
+	metrics := NewMetricsProvider(4, map[string]string{}, prometheus.NewRegistry())
+
+	sub1 := metrics.GetLeveledConsumerMetrics("persistent://public/default/my-topic-partition-0", "sub-1")
+	sub2 := metrics.GetLeveledConsumerMetrics("persistent://public/default/my-topic-partition-1", "sub-2")
+	sub1Partition := metrics.GetLeveledConsumerMetrics("persistent://public/default/my-topic-partition-1", "sub-1")
+
+	sub1.SubscriptionNacks.Inc()
+	sub1Partition.SubscriptionNacks.Inc()
+	sub2.SubscriptionNacks.Inc()
+	sub1.DlqPublished.Inc()
+	sub2.RlqPublished.Inc()
+
+	assert.Equal(t, float64(2), testutil.ToFloat64(sub1.SubscriptionNacks))
+	assert.Equal(t, float64(1), testutil.ToFloat64(sub2.SubscriptionNacks))
+	assert.Equal(t, float64(1), testutil.ToFloat64(sub1.DlqPublished))
+	assert.Equal(t, float64(0), testutil.ToFloat64(sub1.RlqPublished))
+	assert.Equal(t, float64(1), testutil.ToFloat64(sub2.RlqPublished))
+
+	sub1.RedeliveryCount.Observe(0)
+	sub1.RedeliveryCount.Observe(3)
+	// one series per subscription, shared by the partitions of the topic
+	assert.Equal(t, 2, testutil.CollectAndCount(metrics.redeliveryCount))
+
+	// the subscription metrics are not created for producers
+	assert.Nil(t, metrics.GetLeveledMetrics("my-topic").RedeliveryCount)
+}
@@ -52,14 +52,38 @@ func (c *mockConsumer) Subscription() string {
 	return ""
 }
 
+func (c *mockConsumer) KeyHashRanges() map[string][]pulsar.KeyHashRange {
+	return nil
+}
+
+func (c *mockConsumer) FlushPartitionAcks(topic string) error {
+	return nil
+}
+
+func (c *mockConsumer) FlushAcks(ctx context.Context) error {
+	return nil
+}
+
+func (c *mockConsumer) Errors() <-chan error {
+	return nil
+}
+
 func (c *mockConsumer) Unsubscribe() error {
 	return nil
 }
 
+func (c *mockConsumer) UnsubscribeForce() error {
+	return nil
+}
+
 func (c *mockConsumer) Receive(ctx context.Context) (message pulsar.Message, err error) {
 	return nil, nil
 }
 
+func (c *mockConsumer) TryReceive() (pulsar.Message, error) {
+	return nil, nil
+}
+
 func (c *mockConsumer) Chan() <-chan pulsar.ConsumerMessage {
 	return nil
 }
@@ -72,6 +96,10 @@ func (c *mockConsumer) AckID(msgID pulsar.MessageID) error {
 	return nil
 }
 
+func (c *mockConsumer) AckIDList(msgIDs []pulsar.MessageID) error {
+	return nil
+}
+
 func (c *mockConsumer) AckCumulative(msg pulsar.Message) error {
 	return nil
 }
@@ -80,6 +108,14 @@ func (c *mockConsumer) AckIDCumulative(msgID pulsar.MessageID) error {
 	return nil
 }
 
+func (c *mockConsumer) AckWithTxn(msg pulsar.Message, txn pulsar.Transaction) error {
+	return nil
+}
+
+func (c *mockConsumer) AckCumulativeWithTxn(msg pulsar.Message, txn pulsar.Transaction) error {
+	return nil
+}
+
 func (c *mockConsumer) ReconsumeLater(msg pulsar.Message, delay time.Duration) {}
 
 func (c *mockConsumer) ReconsumeLaterWithCustomProperties(msg pulsar.Message, customProperties map[string]string,
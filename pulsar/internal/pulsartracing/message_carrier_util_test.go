@@ -134,3 +134,11 @@ func (msg *mockConsumerMessage) Index() *uint64 {
 func (msg *mockConsumerMessage) BrokerPublishTime() *time.Time {
 	return nil
 }
+
+func (msg *mockConsumerMessage) KafkaTimestampType() pulsar.KafkaTimestampType {
+	return pulsar.NoKafkaTimestampType
+}
+
+func (msg *mockConsumerMessage) DeliverAtTime() time.Time {
+	return time.Time{}
+}
@@ -59,6 +59,11 @@ func (p *mockProducer) SendAsync(context.Context, *pulsar.ProducerMessage,
 	func(pulsar.MessageID, *pulsar.ProducerMessage, error)) {
 }
 
+func (p *mockProducer) Republish(context.Context, pulsar.Message,
+	func(*pulsar.ProducerMessage)) (pulsar.MessageID, error) {
+	return nil, nil
+}
+
 func (p *mockProducer) LastSequenceID() int64 {
 	return 0
 }
@@ -67,4 +72,24 @@ func (p *mockProducer) Flush() error {
 	return nil
 }
 
+func (p *mockProducer) NumPartitions() uint32 {
+	return 1
+}
+
+func (p *mockProducer) PartitionFor(*pulsar.ProducerMessage) int {
+	return 0
+}
+
+func (p *mockProducer) WarmUp(context.Context) error {
+	return nil
+}
+
+func (p *mockProducer) Pending() []pulsar.PendingMessage {
+	return nil
+}
+
+func (p *mockProducer) CancelPending(context.Context) error {
+	return nil
+}
+
 func (p *mockProducer) Close() {}
@@ -0,0 +1,101 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package internal
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultRetryBudgetRatio is the default max ratio of the retries to the requests
+	DefaultRetryBudgetRatio = 0.2
+
+	// retryBudgetMinPerSecond is the number of retries always allowed per second, so the clients
+	// without requests to deposit into the budget still retry
+	retryBudgetMinPerSecond = 10
+)
+
+// RetryBudget bounds the retries of the client to a ratio of its requests, preventing the retries to amplify
+// the load on the brokers during an outage. Each request deposits the ratio into the budget and each retry
+// withdraws one from it. A nil RetryBudget allows all the retries.
+type RetryBudget struct {
+	sync.Mutex
+	ratio      float64
+	tokens     float64
+	lastRefill time.Time
+	metrics    *Metrics
+
+	// now is replaced in tests
+	now func() time.Time
+}
+
+// NewRetryBudget creates a retry budget allowing retries up to ratio of the requests, it returns nil to allow
+// all the retries when ratio is negative
+func NewRetryBudget(ratio float64, metrics *Metrics) *RetryBudget {
+	if ratio < 0 {
+		return nil
+	}
+	return &RetryBudget{
+		ratio:      ratio,
+		tokens:     retryBudgetMinPerSecond,
+		lastRefill: time.Now(),
+		metrics:    metrics,
+		now:        time.Now,
+	}
+}
+
+// Deposit records a request
+func (b *RetryBudget) Deposit() {
+	if b == nil {
+		return
+	}
+	b.Lock()
+	defer b.Unlock()
+	b.add(b.ratio)
+}
+
+// Withdraw records a retry, it returns false when the budget is exhausted and the retry must not be attempted
+func (b *RetryBudget) Withdraw() bool {
+	if b == nil {
+		return true
+	}
+	b.Lock()
+	defer b.Unlock()
+
+	now := b.now()
+	b.add(now.Sub(b.lastRefill).Seconds() * retryBudgetMinPerSecond)
+	b.lastRefill = now
+
+	if b.tokens < 1 {
+		if b.metrics != nil {
+			b.metrics.RetriesRejected.Inc()
+		}
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// add must be called with the lock held. The tokens are capped to bound the bursts of retries.
+func (b *RetryBudget) add(tokens float64) {
+	b.tokens += tokens
+	if b.tokens > retryBudgetMinPerSecond {
+		b.tokens = retryBudgetMinPerSecond
+	}
+}
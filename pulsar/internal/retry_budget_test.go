@@ -0,0 +1,61 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package internal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryBudget(t *testing.T) {
+	now := time.Now()
+	b := NewRetryBudget(0.2, nil)
+	b.now = func() time.Time { return now }
+	b.lastRefill = now
+
+	// the initial tokens allow a burst of retries
+	for i := 0; i < retryBudgetMinPerSecond; i++ {
+		assert.True(t, b.Withdraw())
+	}
+	assert.False(t, b.Withdraw())
+
+	// 5 requests deposit a retry
+	for i := 0; i < 4; i++ {
+		b.Deposit()
+	}
+	assert.False(t, b.Withdraw())
+	b.Deposit()
+	assert.True(t, b.Withdraw())
+	assert.False(t, b.Withdraw())
+
+	// a few retries per second are allowed without requests
+	now = now.Add(time.Second / 2)
+	for i := 0; i < retryBudgetMinPerSecond/2; i++ {
+		assert.True(t, b.Withdraw())
+	}
+	assert.False(t, b.Withdraw())
+}
+
+func TestRetryBudgetDisabled(t *testing.T) {
+	b := NewRetryBudget(-1, nil)
+	assert.Nil(t, b)
+	b.Deposit()
+	assert.True(t, b.Withdraw())
+}
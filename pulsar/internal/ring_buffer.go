@@ -0,0 +1,143 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package internal
+
+import (
+	"context"
+	"sync"
+)
+
+// RingBuffer is a bounded queue for many producers and a single consumer that dequeues the items in batches.
+// Unlike a channel, where each item costs a lock and possibly a wakeup of the consumer, the consumer is only woken
+// up when the queue goes from empty to non-empty and takes all the queued items with one lock.
+type RingBuffer struct {
+	items   []interface{}
+	headIdx int
+	size    int
+
+	mutex     sync.Mutex
+	isNotFull *sync.Cond
+	readyCh   chan struct{}
+}
+
+// NewRingBuffer init a ring buffer holding at most maxSize items
+func NewRingBuffer(maxSize int) *RingBuffer {
+	if maxSize <= 0 {
+		maxSize = 1
+	}
+	rb := &RingBuffer{
+		items:   make([]interface{}, maxSize),
+		readyCh: make(chan struct{}, 1),
+	}
+	rb.isNotFull = sync.NewCond(&rb.mutex)
+	return rb
+}
+
+// Put enqueue one item, block if the ring buffer is full
+func (rb *RingBuffer) Put(item interface{}) {
+	rb.mutex.Lock()
+	for rb.size == len(rb.items) {
+		rb.isNotFull.Wait()
+	}
+	rb.putLocked(item)
+}
+
+// PutContext enqueues one item like Put, it returns the error of the context, without enqueuing the item, when the
+// context is done before the ring buffer has room for it
+func (rb *RingBuffer) PutContext(ctx context.Context, item interface{}) error {
+	rb.mutex.Lock()
+	if rb.size == len(rb.items) && ctx.Done() != nil {
+		// wakes up the wait below when the context is done, the lock is held until the wait starts
+		stopCh := make(chan struct{})
+		defer close(stopCh)
+		go func() {
+			select {
+			case <-ctx.Done():
+				rb.mutex.Lock()
+				rb.isNotFull.Broadcast()
+				rb.mutex.Unlock()
+			case <-stopCh:
+			}
+		}()
+	}
+	for rb.size == len(rb.items) {
+		if err := ctx.Err(); err != nil {
+			rb.mutex.Unlock()
+			return err
+		}
+		rb.isNotFull.Wait()
+	}
+	rb.putLocked(item)
+	return nil
+}
+
+// putLocked enqueues the item with the lock held and releases the lock
+func (rb *RingBuffer) putLocked(item interface{}) {
+	rb.items[(rb.headIdx+rb.size)%len(rb.items)] = item
+	rb.size++
+	wakeUp := rb.size == 1
+	rb.mutex.Unlock()
+
+	if wakeUp {
+		select {
+		case rb.readyCh <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Ready returns a channel receiving a value when items are available after the ring buffer was drained.
+// The consumer is expected to call Drain every time it receives from the channel.
+func (rb *RingBuffer) Ready() <-chan struct{} {
+	return rb.readyCh
+}
+
+// Drain appends all the queued items to buf, in the order they were put, and returns the extended slice
+func (rb *RingBuffer) Drain(buf []interface{}) []interface{} {
+	rb.mutex.Lock()
+	defer rb.mutex.Unlock()
+
+	for rb.size > 0 {
+		end := rb.headIdx + rb.size
+		if end > len(rb.items) {
+			end = len(rb.items)
+		}
+		buf = append(buf, rb.items[rb.headIdx:end]...)
+		for i := rb.headIdx; i < end; i++ {
+			rb.items[i] = nil
+		}
+		rb.size -= end - rb.headIdx
+		rb.headIdx = end % len(rb.items)
+	}
+	rb.headIdx = 0
+	rb.isNotFull.Broadcast()
+	return buf
+}
+
+// Cap returns the maximum number of items of the ring buffer
+func (rb *RingBuffer) Cap() int {
+	return len(rb.items)
+}
+
+// Size return the current size of the ring buffer
+func (rb *RingBuffer) Size() int {
+	rb.mutex.Lock()
+	defer rb.mutex.Unlock()
+
+	return rb.size
+}
@@ -0,0 +1,167 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package internal
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRingBuffer(t *testing.T) {
+	rb := NewRingBuffer(3)
+	assert.Empty(t, rb.Drain(nil))
+
+	rb.Put(1)
+	rb.Put(2)
+	<-rb.Ready()
+	assert.Equal(t, []interface{}{1, 2}, rb.Drain(nil))
+	assert.Equal(t, 0, rb.Size())
+
+	// wraps around the end of the buffer
+	rb.Put(3)
+	assert.Equal(t, []interface{}{3}, rb.Drain(nil))
+	rb.Put(4)
+	rb.Put(5)
+	rb.Put(6)
+	<-rb.Ready()
+	assert.Equal(t, []interface{}{4, 5, 6}, rb.Drain(make([]interface{}, 0, 3)))
+
+	rb.Put(7)
+	rb.Put(8)
+	rb.Put(9)
+	putCh := make(chan struct{})
+	go func() {
+		// Stays blocked until the ring buffer is drained
+		rb.Put(10)
+		close(putCh)
+	}()
+
+	select {
+	case <-putCh:
+		assert.Fail(t, "Should be blocked while the ring buffer is full")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	assert.Equal(t, []interface{}{7, 8, 9}, rb.Drain(nil))
+	<-putCh
+	assert.Equal(t, []interface{}{10}, rb.Drain(nil))
+}
+
+func TestRingBufferConcurrentProducers(t *testing.T) {
+	const producers = 8
+	const items = 10000
+	rb := NewRingBuffer(64)
+
+	for p := 0; p < producers; p++ {
+		go func(p int) {
+			for i := 0; i < items; i++ {
+				rb.Put([2]int{p, i})
+			}
+		}(p)
+	}
+
+	next := make([]int, producers)
+	var buf []interface{}
+	for received := 0; received < producers*items; {
+		<-rb.Ready()
+		buf = rb.Drain(buf[:0])
+		for _, item := range buf {
+			v := item.([2]int)
+			// the items of a producer are dequeued in order
+			assert.Equal(t, next[v[0]], v[1])
+			next[v[0]]++
+		}
+		received += len(buf)
+	}
+}
+
+func TestRingBufferPutContext(t *testing.T) {
+	rb := NewRingBuffer(1)
+	assert.NoError(t, rb.PutContext(context.Background(), 1))
+
+	// the context is done while the ring buffer is full
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, rb.PutContext(ctx, 2), context.DeadlineExceeded)
+	assert.ErrorIs(t, rb.PutContext(ctx, 3), context.DeadlineExceeded)
+
+	// the item is enqueued once the ring buffer is drained
+	putCh := make(chan error)
+	go func() {
+		putCh <- rb.PutContext(context.Background(), 4)
+	}()
+	<-rb.Ready()
+	assert.Equal(t, []interface{}{1}, rb.Drain(nil))
+	assert.NoError(t, <-putCh)
+	assert.Equal(t, []interface{}{4}, rb.Drain(nil))
+}
+
+func benchmarkProducers(b *testing.B, producers int, put func()) {
+	var wg sync.WaitGroup
+	wg.Add(producers)
+	for p := 0; p < producers; p++ {
+		go func() {
+			defer wg.Done()
+			for i := 0; i < b.N/producers; i++ {
+				put()
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func BenchmarkRingBuffer(b *testing.B) {
+	rb := NewRingBuffer(1000)
+	done := make(chan struct{})
+	go func() {
+		var buf []interface{}
+		for received := 0; received < b.N/8*8; {
+			<-rb.Ready()
+			buf = rb.Drain(buf[:0])
+			received += len(buf)
+		}
+		close(done)
+	}()
+
+	b.ResetTimer()
+	benchmarkProducers(b, 8, func() {
+		rb.Put(struct{}{})
+	})
+	<-done
+}
+
+func BenchmarkChannel(b *testing.B) {
+	ch := make(chan interface{}, 1000)
+	done := make(chan struct{})
+	go func() {
+		for received := 0; received < b.N/8*8; received++ {
+			<-ch
+		}
+		close(done)
+	}()
+
+	b.ResetTimer()
+	benchmarkProducers(b, 8, func() {
+		ch <- struct{}{}
+	})
+	<-done
+}
@@ -23,6 +23,7 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/apache/pulsar-client-go/pulsar/auth"
 	"github.com/apache/pulsar-client-go/pulsar/log"
 
 	pb "github.com/apache/pulsar-client-go/pulsar/internal/pulsar_proto"
@@ -58,30 +59,49 @@ type RPCClient interface {
 	Request(logicalAddr *url.URL, physicalAddr *url.URL, requestID uint64,
 		cmdType pb.BaseCommand_Type, message proto.Message) (*RPCResult, error)
 
+	// RequestWithAuth sends a request on a connection authenticated with the given provider, see
+	// ConnectionPool.GetConnectionWithAuth
+	RequestWithAuth(provider auth.Provider, logicalAddr *url.URL, physicalAddr *url.URL, requestID uint64,
+		cmdType pb.BaseCommand_Type, message proto.Message) (*RPCResult, error)
+
 	RequestOnCnxNoWait(cnx Connection, cmdType pb.BaseCommand_Type, message proto.Message) error
 
 	RequestOnCnx(cnx Connection, requestID uint64, cmdType pb.BaseCommand_Type, message proto.Message) (*RPCResult, error)
+
+	// RequestOnCnxAsync sends a request on the connection without waiting for the result, the callback is called
+	// once with the result or ErrRequestTimeOut. The callback may be called by the connection, it must not block.
+	RequestOnCnxAsync(cnx Connection, requestID uint64, cmdType pb.BaseCommand_Type, message proto.Message,
+		callback func(*RPCResult, error))
 }
 
 type rpcClient struct {
 	serviceNameResolver ServiceNameResolver
 	pool                ConnectionPool
 	requestTimeout      time.Duration
+	requestTimeouts     map[pb.BaseCommand_Type]time.Duration
 	requestIDGenerator  uint64
 	producerIDGenerator uint64
 	consumerIDGenerator uint64
 	log                 log.Logger
 	metrics             *Metrics
+	retryBudget         *RetryBudget
+	circuitBreakers     *CircuitBreakers
 }
 
+// NewRPCClient returns a client whose requests time out after requestTimeout, or after the timeout of their command
+// type in requestTimeouts if any
 func NewRPCClient(serviceURL *url.URL, serviceNameResolver ServiceNameResolver, pool ConnectionPool,
-	requestTimeout time.Duration, logger log.Logger, metrics *Metrics) RPCClient {
+	requestTimeout time.Duration, requestTimeouts map[pb.BaseCommand_Type]time.Duration, logger log.Logger,
+	metrics *Metrics, retryBudget *RetryBudget, circuitBreakers *CircuitBreakers) RPCClient {
 	return &rpcClient{
 		serviceNameResolver: serviceNameResolver,
 		pool:                pool,
 		requestTimeout:      requestTimeout,
+		requestTimeouts:     requestTimeouts,
 		log:                 logger.SubLogger(log.Fields{"serviceURL": serviceURL}),
 		metrics:             metrics,
+		retryBudget:         retryBudget,
+		circuitBreakers:     circuitBreakers,
 	}
 }
 
@@ -94,7 +114,11 @@ func (c *rpcClient) RequestToAnyBroker(requestID uint64, cmdType pb.BaseCommand_
 	backoff := DefaultBackoff{100 * time.Millisecond}
 	// we can retry these requests because this kind of request is
 	// not specific to any particular broker
-	for time.Since(startTime) < c.requestTimeout {
+	for attempt := 0; time.Since(startTime) < c.requestTimeout; attempt++ {
+		if attempt > 0 && !c.retryBudget.Withdraw() {
+			c.log.WithError(err).Warn("Retry budget exhausted, not retrying the request")
+			break
+		}
 		host, err = c.serviceNameResolver.ResolveHost()
 		if err != nil {
 			c.log.WithError(err).Errorf("rpc client failed to resolve host")
@@ -116,9 +140,21 @@ func (c *rpcClient) RequestToAnyBroker(requestID uint64, cmdType pb.BaseCommand_
 
 func (c *rpcClient) Request(logicalAddr *url.URL, physicalAddr *url.URL, requestID uint64,
 	cmdType pb.BaseCommand_Type, message proto.Message) (*RPCResult, error) {
+	return c.RequestWithAuth(nil, logicalAddr, physicalAddr, requestID, cmdType, message)
+}
+
+func (c *rpcClient) RequestWithAuth(provider auth.Provider, logicalAddr *url.URL, physicalAddr *url.URL,
+	requestID uint64, cmdType pb.BaseCommand_Type, message proto.Message) (*RPCResult, error) {
 	c.metrics.RPCRequestCount.Inc()
-	cnx, err := c.pool.GetConnection(logicalAddr, physicalAddr)
+	c.retryBudget.Deposit()
+	// the breakers are keyed by the logical address, the physical address may be a proxy shared by all the brokers
+	broker := logicalAddr.Host
+	if err := c.circuitBreakers.Allow(broker); err != nil {
+		return nil, err
+	}
+	cnx, err := c.pool.GetConnectionWithAuth(logicalAddr, physicalAddr, provider)
 	if err != nil {
+		c.circuitBreakers.Record(broker, true)
 		return nil, err
 	}
 
@@ -131,20 +167,34 @@ func (c *rpcClient) Request(logicalAddr *url.URL, physicalAddr *url.URL, request
 		}, err}
 	})
 
-	timeoutCh := time.After(c.requestTimeout)
+	// the outcome is recorded once, the request may be a probe the circuit of the broker waits for
+	recorded := false
+	record := func(failed bool) {
+		if !recorded {
+			recorded = true
+			c.circuitBreakers.Record(broker, failed)
+		}
+	}
+
+	timeoutCh := time.After(c.timeout(cmdType))
 	for {
 		select {
 		case res := <-ch:
 			// Ignoring producer not ready response.
-			// Continue to wait for the producer to create successfully
+			// Continue to wait for the producer to create successfully, until the broker completes the request or
+			// the connection closes failing it. The broker answered so it is healthy.
 			if res.error == nil && *res.RPCResult.Response.Type == pb.BaseCommand_PRODUCER_SUCCESS {
 				if !*res.RPCResult.Response.ProducerSuccess.ProducerReady {
+					record(false)
 					timeoutCh = nil
 					break
 				}
 			}
+			record(res.error != nil && isBrokerFailure(res.error))
 			return res.RPCResult, res.error
 		case <-timeoutCh:
+			cnx.CancelRequest(requestID)
+			record(true)
 			return nil, ErrRequestTimeOut
 		}
 	}
@@ -153,6 +203,7 @@ func (c *rpcClient) Request(logicalAddr *url.URL, physicalAddr *url.URL, request
 func (c *rpcClient) RequestOnCnx(cnx Connection, requestID uint64, cmdType pb.BaseCommand_Type,
 	message proto.Message) (*RPCResult, error) {
 	c.metrics.RPCRequestCount.Inc()
+	c.retryBudget.Deposit()
 
 	ch := make(chan result, 1)
 
@@ -167,13 +218,47 @@ func (c *rpcClient) RequestOnCnx(cnx Connection, requestID uint64, cmdType pb.Ba
 	select {
 	case res := <-ch:
 		return res.RPCResult, res.error
-	case <-time.After(c.requestTimeout):
+	case <-time.After(c.timeout(cmdType)):
+		cnx.CancelRequest(requestID)
 		return nil, ErrRequestTimeOut
 	}
 }
 
+func (c *rpcClient) RequestOnCnxAsync(cnx Connection, requestID uint64, cmdType pb.BaseCommand_Type,
+	message proto.Message, callback func(*RPCResult, error)) {
+	c.metrics.RPCRequestCount.Inc()
+	c.retryBudget.Deposit()
+
+	// the response and the timeout race to complete the request, the loser doesn't wait since the connection
+	// may call back with its lock held
+	var completed int32
+	timer := time.AfterFunc(c.timeout(cmdType), func() {
+		if atomic.CompareAndSwapInt32(&completed, 0, 1) {
+			cnx.CancelRequest(requestID)
+			callback(nil, ErrRequestTimeOut)
+		}
+	})
+	cnx.SendRequest(requestID, baseCommand(cmdType, message), func(response *pb.BaseCommand, err error) {
+		if atomic.CompareAndSwapInt32(&completed, 0, 1) {
+			timer.Stop()
+			callback(&RPCResult{
+				Cnx:      cnx,
+				Response: response,
+			}, err)
+		}
+	})
+}
+
+func (c *rpcClient) timeout(cmdType pb.BaseCommand_Type) time.Duration {
+	if timeout, ok := c.requestTimeouts[cmdType]; ok {
+		return timeout
+	}
+	return c.requestTimeout
+}
+
 func (c *rpcClient) RequestOnCnxNoWait(cnx Connection, cmdType pb.BaseCommand_Type, message proto.Message) error {
 	c.metrics.RPCRequestCount.Inc()
+	c.retryBudget.Deposit()
 	return cnx.SendRequestNoWait(baseCommand(cmdType, message))
 }
 
@@ -0,0 +1,101 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package internal
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+)
+
+const fipsMinRSAKeySize = 2048
+
+// fipsCipherSuites is the list of TLS 1.2 cipher suites built only on FIPS 140 approved algorithms
+var fipsCipherSuites = []uint16{
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+}
+
+// fipsCurves is the list of FIPS 140 approved elliptic curves
+var fipsCurves = []tls.CurveID{
+	tls.CurveP256,
+	tls.CurveP384,
+	tls.CurveP521,
+}
+
+var errFIPSInsecureConnection = errors.New("FIPS mode does not allow insecure TLS connections")
+
+// applyFIPS restricts the TLS configuration to FIPS approved protocol versions, cipher suites
+// and curves, and validates the client certificates against the FIPS key requirements.
+func applyFIPS(cfg *tls.Config) error {
+	if cfg.InsecureSkipVerify {
+		return errFIPSInsecureConnection
+	}
+
+	// The TLS 1.3 cipher suites are not configurable and include ChaCha20-Poly1305,
+	// so the protocol is pinned to TLS 1.2 where the cipher suites can be restricted.
+	cfg.MinVersion = tls.VersionTLS12
+	cfg.MaxVersion = tls.VersionTLS12
+	cfg.CipherSuites = fipsCipherSuites
+	cfg.CurvePreferences = fipsCurves
+
+	for i := range cfg.Certificates {
+		if err := validateFIPSCertificate(&cfg.Certificates[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateFIPSCertificate(cert *tls.Certificate) error {
+	if len(cert.Certificate) == 0 {
+		return nil
+	}
+
+	leaf := cert.Leaf
+	if leaf == nil {
+		var err error
+		leaf, err = x509.ParseCertificate(cert.Certificate[0])
+		if err != nil {
+			return err
+		}
+	}
+
+	switch key := leaf.PublicKey.(type) {
+	case *rsa.PublicKey:
+		if key.N.BitLen() < fipsMinRSAKeySize {
+			return fmt.Errorf("FIPS mode requires RSA certificate keys of at least %d bits, got %d bits",
+				fipsMinRSAKeySize, key.N.BitLen())
+		}
+	case *ecdsa.PublicKey:
+		switch key.Curve {
+		case elliptic.P256(), elliptic.P384(), elliptic.P521():
+		default:
+			return fmt.Errorf("FIPS mode does not allow the certificate curve %s", key.Curve.Params().Name)
+		}
+	default:
+		return fmt.Errorf("FIPS mode does not allow certificate keys of type %T", leaf.PublicKey)
+	}
+	return nil
+}
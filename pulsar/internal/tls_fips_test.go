@@ -0,0 +1,76 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package internal
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestCertificate(t *testing.T, pub, priv interface{}) tls.Certificate {
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "pulsar-client"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, pub, priv)
+	assert.Nil(t, err)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: priv}
+}
+
+func TestApplyFIPS(t *testing.T) {
+	cfg := &tls.Config{}
+	assert.Nil(t, applyFIPS(cfg))
+	assert.Equal(t, uint16(tls.VersionTLS12), cfg.MinVersion)
+	assert.Equal(t, uint16(tls.VersionTLS12), cfg.MaxVersion)
+	assert.Equal(t, fipsCipherSuites, cfg.CipherSuites)
+	assert.Equal(t, fipsCurves, cfg.CurvePreferences)
+}
+
+func TestApplyFIPSInsecureConnection(t *testing.T) {
+	err := applyFIPS(&tls.Config{InsecureSkipVerify: true})
+	assert.Equal(t, errFIPSInsecureConnection, err)
+}
+
+func TestApplyFIPSCertificates(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	cfg := &tls.Config{Certificates: []tls.Certificate{newTestCertificate(t, &ecKey.PublicKey, ecKey)}}
+	assert.Nil(t, applyFIPS(cfg))
+
+	weakRSAKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	assert.Nil(t, err)
+	cfg = &tls.Config{Certificates: []tls.Certificate{newTestCertificate(t, &weakRSAKey.PublicKey, weakRSAKey)}}
+	assert.NotNil(t, applyFIPS(cfg))
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+	cfg = &tls.Config{Certificates: []tls.Certificate{newTestCertificate(t, &rsaKey.PublicKey, rsaKey)}}
+	assert.Nil(t, applyFIPS(cfg))
+}
@@ -0,0 +1,204 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsar
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrKeyOrderingBroken is wrapped by the errors of the sends not attempted because a previous send of the same
+// key failed, see ProducerOptions.MaxInflightPerKey. The application retries them after the failed send to
+// preserve the order of the key.
+var ErrKeyOrderingBroken = errors.New("a previous send of the key failed")
+
+// keyOrderedSender chains the sends of each key, a send is only handed to the producer once the sends of the
+// same key ahead of it are completed or within the in-flight window, while the sends of different keys proceed
+// in parallel. The order is kept across failures only with a window of 1: with a larger window, the sends
+// already handed to the producer when one of them fails are not stopped.
+type keyOrderedSender struct {
+	sync.Mutex
+	maxInflight int
+	keys        map[string]*keySends
+	send        func(ctx context.Context, msg *ProducerMessage, callback func(MessageID, *ProducerMessage, error))
+}
+
+type keySends struct {
+	inflight    int
+	waiting     []*keyedSend
+	dispatching bool
+}
+
+type keyedSend struct {
+	ctx      context.Context
+	msg      *ProducerMessage
+	callback func(MessageID, *ProducerMessage, error)
+	// dequeued is closed once the send no longer waits, nil if its context can't be cancelled
+	dequeued chan struct{}
+}
+
+func (ks *keySends) remove(send *keyedSend) bool {
+	for i, waiting := range ks.waiting {
+		if waiting == send {
+			ks.waiting = append(ks.waiting[:i], ks.waiting[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (send *keyedSend) dequeue() {
+	if send.dequeued != nil {
+		close(send.dequeued)
+	}
+}
+
+func newKeyOrderedSender(maxInflight int,
+	send func(context.Context, *ProducerMessage, func(MessageID, *ProducerMessage, error))) *keyOrderedSender {
+	if maxInflight <= 0 {
+		return nil
+	}
+	return &keyOrderedSender{
+		maxInflight: maxInflight,
+		keys:        make(map[string]*keySends),
+		send:        send,
+	}
+}
+
+func orderingKeyOf(msg *ProducerMessage) string {
+	if msg.OrderingKey != "" {
+		return msg.OrderingKey
+	}
+	return msg.Key
+}
+
+// sendAsync queues the send behind the sends of the same key, the messages without key are sent right away
+func (s *keyOrderedSender) sendAsync(ctx context.Context, msg *ProducerMessage,
+	callback func(MessageID, *ProducerMessage, error)) {
+	key := orderingKeyOf(msg)
+	if key == "" {
+		s.send(ctx, msg, callback)
+		return
+	}
+
+	s.Lock()
+	ks, ok := s.keys[key]
+	if !ok {
+		ks = &keySends{}
+		s.keys[key] = ks
+	}
+	send := &keyedSend{ctx: ctx, msg: msg, callback: callback}
+	if ctx.Done() != nil {
+		send.dequeued = make(chan struct{})
+		go s.cancelOnDone(key, ks, send)
+	}
+	ks.waiting = append(ks.waiting, send)
+	if ks.dispatching {
+		s.Unlock()
+		return
+	}
+	ks.dispatching = true
+	s.Unlock()
+
+	s.dispatch(key, ks)
+}
+
+// cancelOnDone fails the send if its context is done while it still waits for the sends ahead of it
+func (s *keyOrderedSender) cancelOnDone(key string, ks *keySends, send *keyedSend) {
+	select {
+	case <-send.dequeued:
+		return
+	case <-send.ctx.Done():
+	}
+
+	s.Lock()
+	removed := ks.remove(send)
+	if removed {
+		s.releaseLocked(key, ks)
+	}
+	s.Unlock()
+
+	if removed && send.callback != nil {
+		send.callback(nil, send.msg, send.ctx.Err())
+	}
+}
+
+// dispatch hands the waiting sends of the key to the producer, in order, while the in-flight window allows.
+// A single goroutine dispatches the sends of a key at a time.
+func (s *keyOrderedSender) dispatch(key string, ks *keySends) {
+	for {
+		s.Lock()
+		if ks.inflight >= s.maxInflight || len(ks.waiting) == 0 {
+			ks.dispatching = false
+			s.releaseLocked(key, ks)
+			s.Unlock()
+			return
+		}
+		next := ks.waiting[0]
+		ks.waiting[0] = nil
+		ks.waiting = ks.waiting[1:]
+		ks.inflight++
+		next.dequeue()
+		s.Unlock()
+
+		s.send(next.ctx, next.msg, func(id MessageID, msg *ProducerMessage, err error) {
+			s.completed(key, ks, next, id, err)
+		})
+	}
+}
+
+func (s *keyOrderedSender) completed(key string, ks *keySends, sent *keyedSend, id MessageID, err error) {
+	s.Lock()
+	ks.inflight--
+	var failed []*keyedSend
+	if err != nil {
+		failed = ks.waiting
+		ks.waiting = nil
+		for _, f := range failed {
+			f.dequeue()
+		}
+	}
+	redispatch := !ks.dispatching && len(ks.waiting) > 0
+	if redispatch {
+		ks.dispatching = true
+	}
+	s.releaseLocked(key, ks)
+	s.Unlock()
+
+	if sent.callback != nil {
+		sent.callback(id, sent.msg, err)
+	}
+	for _, f := range failed {
+		if f.callback != nil {
+			f.callback(nil, f.msg, fmt.Errorf("%w: %v", ErrKeyOrderingBroken, err))
+		}
+	}
+	if redispatch {
+		// the callbacks are invoked by the goroutines of the client, which must not block on a full queue
+		go s.dispatch(key, ks)
+	}
+}
+
+// releaseLocked forgets the key once it has no send left
+func (s *keyOrderedSender) releaseLocked(key string, ks *keySends) {
+	if ks.inflight == 0 && len(ks.waiting) == 0 && !ks.dispatching && s.keys[key] == ks {
+		delete(s.keys, key)
+	}
+}
@@ -0,0 +1,157 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsar
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type recordingSends struct {
+	sync.Mutex
+	sent      []string
+	callbacks []func(MessageID, *ProducerMessage, error)
+	msgs      []*ProducerMessage
+}
+
+func (r *recordingSends) send(_ context.Context, msg *ProducerMessage,
+	callback func(MessageID, *ProducerMessage, error)) {
+	r.Lock()
+	defer r.Unlock()
+	r.sent = append(r.sent, string(msg.Payload))
+	r.callbacks = append(r.callbacks, callback)
+	r.msgs = append(r.msgs, msg)
+}
+
+func (r *recordingSends) get() []string {
+	r.Lock()
+	defer r.Unlock()
+	return append([]string(nil), r.sent...)
+}
+
+func (r *recordingSends) complete(i int, err error) {
+	r.Lock()
+	callback, msg := r.callbacks[i], r.msgs[i]
+	r.Unlock()
+	callback(nil, msg, err)
+}
+
+func TestKeyOrderedSender(t *testing.T) {
+	r := &recordingSends{}
+	s := newKeyOrderedSender(1, r.send)
+
+	results := make(chan string, 10)
+	callback := func(_ MessageID, msg *ProducerMessage, err error) {
+		if err != nil {
+			results <- string(msg.Payload) + ":" + err.Error()
+			return
+		}
+		results <- string(msg.Payload)
+	}
+
+	s.sendAsync(context.Background(), &ProducerMessage{Key: "a", Payload: []byte("a1")}, callback)
+	s.sendAsync(context.Background(), &ProducerMessage{Key: "a", Payload: []byte("a2")}, callback)
+	s.sendAsync(context.Background(), &ProducerMessage{OrderingKey: "b", Key: "a", Payload: []byte("b1")}, callback)
+	s.sendAsync(context.Background(), &ProducerMessage{Payload: []byte("none")}, callback)
+
+	// a2 waits for a1 while the other keys are sent right away
+	assert.Equal(t, []string{"a1", "b1", "none"}, r.get())
+
+	r.complete(0, nil)
+	assert.Equal(t, "a1", <-results)
+	assert.Eventually(t, func() bool { return len(r.get()) == 4 }, time.Second, time.Millisecond)
+	assert.Equal(t, "a2", r.get()[3])
+
+	// the sends waiting behind a failed send fail
+	s.sendAsync(context.Background(), &ProducerMessage{Key: "a", Payload: []byte("a3")}, callback)
+	r.complete(3, errors.New("send timeout"))
+	assert.Equal(t, "a2:send timeout", <-results)
+	res := <-results
+	assert.Contains(t, res, "a3:")
+	assert.Contains(t, res, ErrKeyOrderingBroken.Error())
+	assert.Len(t, r.get(), 4)
+
+	r.complete(1, nil)
+	r.complete(2, nil)
+	assert.Equal(t, "b1", <-results)
+	assert.Equal(t, "none", <-results)
+
+	s.Lock()
+	assert.Empty(t, s.keys)
+	s.Unlock()
+}
+
+func TestKeyOrderedSenderInflightWindow(t *testing.T) {
+	r := &recordingSends{}
+	s := newKeyOrderedSender(2, r.send)
+
+	for _, payload := range []string{"a1", "a2", "a3"} {
+		s.sendAsync(context.Background(), &ProducerMessage{Key: "a", Payload: []byte(payload)}, nil)
+	}
+	assert.Equal(t, []string{"a1", "a2"}, r.get())
+
+	r.complete(0, nil)
+	assert.Eventually(t, func() bool { return len(r.get()) == 3 }, time.Second, time.Millisecond)
+	assert.Equal(t, []string{"a1", "a2", "a3"}, r.get())
+
+	assert.Nil(t, newKeyOrderedSender(0, r.send))
+}
+
+func TestKeyOrderedSenderCancelWaiting(t *testing.T) {
+	r := &recordingSends{}
+	s := newKeyOrderedSender(1, r.send)
+
+	errs := make(chan error, 3)
+	callback := func(_ MessageID, _ *ProducerMessage, err error) {
+		errs <- err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s.sendAsync(context.Background(), &ProducerMessage{Key: "a", Payload: []byte("a1")}, callback)
+	s.sendAsync(ctx, &ProducerMessage{Key: "a", Payload: []byte("a2")}, callback)
+	s.sendAsync(context.Background(), &ProducerMessage{Key: "a", Payload: []byte("a3")}, callback)
+
+	// the cancelled send no longer waits and is never handed to the producer
+	cancel()
+	assert.ErrorIs(t, <-errs, context.Canceled)
+	r.complete(0, nil)
+	assert.NoError(t, <-errs)
+	assert.Eventually(t, func() bool { return len(r.get()) == 2 }, time.Second, time.Millisecond)
+	assert.Equal(t, []string{"a1", "a3"}, r.get())
+
+	r.complete(1, nil)
+	assert.NoError(t, <-errs)
+	s.Lock()
+	assert.Empty(t, s.keys)
+	s.Unlock()
+}
+
+func TestProducerSendKeyOrderedContext(t *testing.T) {
+	r := &recordingSends{}
+	p := &producer{keyOrdered: newKeyOrderedSender(1, r.send)}
+	p.keyOrdered.sendAsync(context.Background(), &ProducerMessage{Key: "a", Payload: []byte("a1")}, nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := p.Send(ctx, &ProducerMessage{Key: "a", Payload: []byte("a2")})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
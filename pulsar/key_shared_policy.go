@@ -20,9 +20,13 @@ package pulsar
 import (
 	"fmt"
 
+	"github.com/apache/pulsar-client-go/pulsar/internal"
 	pb "github.com/apache/pulsar-client-go/pulsar/internal/pulsar_proto"
 )
 
+// stickyKeyHashRangeSize is the size of the hash range Key_Shared subscriptions split the keys into
+const stickyKeyHashRangeSize = 65536
+
 type KeySharedPolicyMode int
 
 const (
@@ -77,6 +81,41 @@ func toProtoKeySharedMeta(ksp *KeySharedPolicy) *pb.KeySharedMeta {
 	return meta
 }
 
+// KeyHashRange is a range of sticky key hashes, both ends included, assigned to a Key_Shared consumer
+type KeyHashRange struct {
+	Start int
+	End   int
+}
+
+// Contains reports whether the sticky key hash is in the range
+func (r KeyHashRange) Contains(hash int) bool {
+	return hash >= r.Start && hash <= r.End
+}
+
+// StickyKeyHash returns the hash the broker uses to dispatch a message to a Key_Shared consumer, the key
+// being the ordering key of the message if it has one, or else its key.
+func StickyKeyHash(key string) int {
+	return int(internal.Murmur3_32Hash(key) % stickyKeyHashRangeSize)
+}
+
+// KeyHashRangesListener can be implemented by the ConsumerOptions.EventListener to be notified when the key hash
+// ranges assigned to the consumer on a partition change, so the state of the keys can be loaded or evicted.
+// The ranges are empty while the consumer is disconnected from the partition.
+type KeyHashRangesListener interface {
+	KeyHashRangesChanged(consumer Consumer, topicName string, partition int32, ranges []KeyHashRange)
+}
+
+func toKeyHashRanges(ksp *KeySharedPolicy) []KeyHashRange {
+	if ksp == nil || ksp.Mode != KeySharedPolicyModeSticky {
+		return nil
+	}
+	ranges := make([]KeyHashRange, 0, len(ksp.HashRanges)/2)
+	for i := 0; i < len(ksp.HashRanges); i += 2 {
+		ranges = append(ranges, KeyHashRange{Start: ksp.HashRanges[i], End: ksp.HashRanges[i+1]})
+	}
+	return ranges
+}
+
 func validateHashRanges(hashRanges []int) error {
 	sz := len(hashRanges)
 	if sz == 0 || sz%2 != 0 {
@@ -89,3 +89,43 @@ func TestValidateHashRanges(t *testing.T) {
 		})
 	}
 }
+
+type keyHashRangesRecorder struct {
+	ConsumerEventListener
+	changes [][]KeyHashRange
+}
+
+func (r *keyHashRangesRecorder) KeyHashRangesChanged(_ Consumer, _ string, _ int32, ranges []KeyHashRange) {
+	r.changes = append(r.changes, ranges)
+}
+
+func TestKeyHashRanges(t *testing.T) {
+	ksp, err := NewKeySharedPolicySticky([]int{0, 100, 200, 300})
+	assert.Nil(t, err)
+	ranges := toKeyHashRanges(ksp)
+	assert.Equal(t, []KeyHashRange{{Start: 0, End: 100}, {Start: 200, End: 300}}, ranges)
+	assert.True(t, ranges[0].Contains(100))
+	assert.False(t, ranges[0].Contains(101))
+	assert.Nil(t, toKeyHashRanges(&KeySharedPolicy{Mode: KeySharedPolicyModeAutoSplit}))
+
+	hash := StickyKeyHash("my-key")
+	assert.True(t, hash >= 0 && hash < stickyKeyHashRangeSize)
+	assert.Equal(t, hash, StickyKeyHash("my-key"))
+
+	recorder := &keyHashRangesRecorder{}
+	pc := &partitionConsumer{
+		options: &partitionConsumerOpts{
+			subscriptionType:      KeyShared,
+			consumerEventListener: recorder,
+		},
+	}
+	assert.Nil(t, pc.KeyHashRanges())
+
+	pc.setKeyHashRanges(ranges)
+	assert.Equal(t, ranges, pc.KeyHashRanges())
+	pc.setKeyHashRanges(nil)
+	assert.Nil(t, pc.KeyHashRanges())
+	// no change when the consumer had no ranges
+	pc.setKeyHashRanges(nil)
+	assert.Equal(t, [][]KeyHashRange{ranges, nil}, recorder.changes)
+}
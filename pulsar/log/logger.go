@@ -50,6 +50,12 @@ type Logger interface {
 	Errorf(format string, args ...interface{})
 }
 
+// LevelSetter is implemented by the loggers whose level can be changed at runtime.
+// The level is one of "trace", "debug", "info", "warn" or "error".
+type LevelSetter interface {
+	SetLevel(level string) error
+}
+
 // Entry describes the interface for the logger entry.
 type Entry interface {
 	WithFields(fields Fields) Entry
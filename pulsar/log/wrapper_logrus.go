@@ -18,6 +18,8 @@
 package log
 
 import (
+	"fmt"
+
 	"github.com/sirupsen/logrus"
 )
 
@@ -41,6 +43,23 @@ func (l *logrusWrapper) SubLogger(fs Fields) Logger {
 	}
 }
 
+// SetLevel changes the level of the underlying logrus.Logger, which is shared with the sub loggers
+func (l *logrusWrapper) SetLevel(level string) error {
+	lvl, err := logrus.ParseLevel(level)
+	if err != nil {
+		return err
+	}
+	switch logger := l.l.(type) {
+	case *logrus.Logger:
+		logger.SetLevel(lvl)
+	case *logrus.Entry:
+		logger.Logger.SetLevel(lvl)
+	default:
+		return fmt.Errorf("the level of %T can not be changed", l.l)
+	}
+	return nil
+}
+
 func (l *logrusWrapper) WithFields(fs Fields) Entry {
 	return logrusEntry{
 		e: l.l.WithFields(logrus.Fields(fs)),
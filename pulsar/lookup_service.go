@@ -0,0 +1,87 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsar
+
+import (
+	"github.com/apache/pulsar-client-go/pulsar/internal"
+	pb "github.com/apache/pulsar-client-go/pulsar/internal/pulsar_proto"
+)
+
+// LookupResult is the address of the broker serving a topic, LogicalAddr is the broker service URL and
+// PhysicalAddr is the address the client connects to, either the broker itself or a proxy.
+type LookupResult = internal.LookupResult
+
+// PartitionedTopicMetadata is the metadata of a partitioned topic.
+type PartitionedTopicMetadata = internal.PartitionedTopicMetadata
+
+// TopicsOfNamespaceMode selects the topics returned by LookupService.GetTopicsOfNamespace.
+type TopicsOfNamespaceMode = internal.GetTopicsOfNamespaceMode
+
+const (
+	// TopicsOfNamespacePersistent selects the persistent topics of the namespace
+	TopicsOfNamespacePersistent TopicsOfNamespaceMode = internal.Persistent
+	// TopicsOfNamespaceNonPersistent selects the non-persistent topics of the namespace
+	TopicsOfNamespaceNonPersistent TopicsOfNamespaceMode = internal.NonPersistent
+	// TopicsOfNamespaceAll selects all the topics of the namespace
+	TopicsOfNamespaceAll TopicsOfNamespaceMode = internal.All
+)
+
+// LookupService resolves the brokers serving the topics and the metadata of topics and namespaces.
+// The client uses a lookup service over the binary protocol or the HTTP service URL, it can be wrapped
+// or replaced with ClientOptions.LookupServiceWrapper, e.g. to add caching or tracing, or to resolve the
+// brokers through a service-mesh control plane.
+type LookupService interface {
+	// Lookup returns the address of the broker serving the topic.
+	Lookup(topic string) (*LookupResult, error)
+
+	// GetPartitionedTopicMetadata returns the partitioned metadata of the topic,
+	// non-partitioned topics have 0 partitions.
+	GetPartitionedTopicMetadata(topic string) (*PartitionedTopicMetadata, error)
+
+	// GetTopicsOfNamespace returns the names of the topics of the namespace.
+	GetTopicsOfNamespace(namespace string, mode TopicsOfNamespaceMode) ([]string, error)
+
+	// Close releases the resources of the lookup service, it is called when the client is closed.
+	Close()
+}
+
+// pluggableLookupService adapts a user provided LookupService to the internal lookup service,
+// the schemas are still fetched from the brokers with the default lookup service.
+type pluggableLookupService struct {
+	LookupService
+	defaultService internal.LookupService
+}
+
+func newPluggableLookupService(defaultService internal.LookupService,
+	wrapper func(LookupService) LookupService) internal.LookupService {
+	ls := wrapper(defaultService)
+	if ls == nil {
+		return defaultService
+	}
+	if internalService, ok := ls.(internal.LookupService); ok {
+		return internalService
+	}
+	return &pluggableLookupService{
+		LookupService:  ls,
+		defaultService: defaultService,
+	}
+}
+
+func (ls *pluggableLookupService) GetSchema(topic string, schemaVersion []byte) (*pb.Schema, error) {
+	return ls.defaultService.GetSchema(topic, schemaVersion)
+}
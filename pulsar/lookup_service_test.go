@@ -0,0 +1,59 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsar
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type countingLookupService struct {
+	LookupService
+	lookups int
+}
+
+func (ls *countingLookupService) Lookup(topic string) (*LookupResult, error) {
+	ls.lookups++
+	return ls.LookupService.Lookup(topic)
+}
+
+func TestLookupServiceWrapper(t *testing.T) {
+	var wrapper *countingLookupService
+	c, err := NewClient(ClientOptions{
+		URL: "pulsar://localhost:6650",
+		LookupOverrides: map[string]LookupOverride{
+			"pinned": {BrokerServiceURL: "pulsar://broker-1:6650"},
+		},
+		LookupServiceWrapper: func(defaultService LookupService) LookupService {
+			wrapper = &countingLookupService{LookupService: defaultService}
+			return wrapper
+		},
+	})
+	assert.Nil(t, err)
+	defer c.Close()
+
+	ci := c.(*client)
+	_, ok := ci.lookupService.(*pluggableLookupService)
+	assert.True(t, ok)
+
+	res, err := ci.lookupService.Lookup("pinned")
+	assert.Nil(t, err)
+	assert.Equal(t, "pulsar://broker-1:6650", res.LogicalAddr.String())
+	assert.Equal(t, 1, wrapper.lookups)
+}
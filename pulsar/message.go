@@ -45,10 +45,13 @@ type ProducerMessage struct {
 	// that the event "happened", as opposed to when the message is being published.
 	EventTime time.Time
 
-	// ReplicationClusters override the replication clusters for this message.
+	// ReplicationClusters override the replication clusters for this message, restricting the replication of
+	// a geo-replicated topic to a subset of its clusters. The names are the cluster names of the Pulsar instance.
+	// Such messages are not batched.
 	ReplicationClusters []string
 
-	// DisableReplication disables the replication for this message
+	// DisableReplication disables the replication for this message, the message is only stored in the local
+	// cluster. It takes precedence over the ReplicationClusters.
 	DisableReplication bool
 
 	// SequenceID sets the sequence id to assign to the current message
@@ -69,6 +72,25 @@ type ProducerMessage struct {
 	//Schema assign to the current message
 	//Note: messages may have a different schema from producer schema, use it instead of producer schema when assigned
 	Schema Schema
+
+	// Transaction assign the message to a transaction, the message is only visible to consumers
+	// once the transaction is committed. Transactional messages are never batched.
+	Transaction Transaction
+
+	// DisableCompression sends the message without compression, e.g. for payloads which are already compressed.
+	// The compression applies to whole batches, so the message is not batched.
+	DisableCompression bool
+}
+
+// localCluster restricts the replication of a message to the cluster it is published to
+const localCluster = "__local__"
+
+// replicateTo returns the replication clusters of the message metadata, nil to replicate to all the clusters
+func (m *ProducerMessage) replicateTo() []string {
+	if m.DisableReplication {
+		return []string{localCluster}
+	}
+	return m.ReplicationClusters
 }
 
 // Message abstraction used in Pulsar
@@ -136,8 +158,31 @@ type Message interface {
 	// BrokerPublishTime returns broker publish time from broker entry metadata,
 	// or empty if the feature is not enabled in the broker.
 	BrokerPublishTime() *time.Time
+
+	// KafkaTimestampType returns the Kafka timestamp type of a record produced through Kafka-on-Pulsar,
+	// or NoKafkaTimestampType if the message was not decoded from a Kafka entry.
+	KafkaTimestampType() KafkaTimestampType
+
+	// DeliverAtTime returns the time the message was scheduled to be delivered at with
+	// ProducerMessage.DeliverAfter or ProducerMessage.DeliverAt, or the zero time if the message was not delayed.
+	// A message received before this time has been delivered early, with a remaining delay of
+	// time.Until(msg.DeliverAtTime()).
+	DeliverAtTime() time.Time
 }
 
+// KafkaTimestampType is the timestamp type of a Kafka record, which tells whether the
+// event time has been set by the Kafka producer or by the broker on append
+type KafkaTimestampType int
+
+const (
+	// NoKafkaTimestampType the message has not been decoded from a Kafka entry
+	NoKafkaTimestampType KafkaTimestampType = iota
+	// KafkaCreateTime the event time has been set by the Kafka producer
+	KafkaCreateTime
+	// KafkaLogAppendTime the event time has been set by the broker when appending the record
+	KafkaLogAppendTime
+)
+
 // MessageID identifier for a particular message
 type MessageID interface {
 	// Serialize the message id into a sequence of bytes that can be stored somewhere else
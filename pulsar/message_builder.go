@@ -0,0 +1,189 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsar
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/apache/pulsar-client-go/pulsar/internal"
+)
+
+// MessageBuilder builds a ProducerMessage, validating the combinations of its fields when built rather than
+// when sent. A builder can be reused, the built messages do not share their properties or clusters with it.
+type MessageBuilder struct {
+	topic string
+	msg   ProducerMessage
+}
+
+// NewMessage returns the builder of a message to send on the topic, the topic is used to validate the message
+func NewMessage(topic string) *MessageBuilder {
+	return &MessageBuilder{topic: topic}
+}
+
+// Payload sets the payload of the message, exclusive with Value
+func (b *MessageBuilder) Payload(payload []byte) *MessageBuilder {
+	b.msg.Payload = payload
+	return b
+}
+
+// Value sets the value encoded by the schema into the payload, exclusive with Payload
+func (b *MessageBuilder) Value(value interface{}) *MessageBuilder {
+	b.msg.Value = value
+	return b
+}
+
+// Key sets the key of the message, used for routing and key shared subscriptions
+func (b *MessageBuilder) Key(key string) *MessageBuilder {
+	b.msg.Key = key
+	return b
+}
+
+// OrderingKey sets the key used for the ordering of key shared subscriptions instead of the key
+func (b *MessageBuilder) OrderingKey(key string) *MessageBuilder {
+	b.msg.OrderingKey = key
+	return b
+}
+
+// Property adds a property to the message
+func (b *MessageBuilder) Property(key, value string) *MessageBuilder {
+	if b.msg.Properties == nil {
+		b.msg.Properties = make(map[string]string)
+	}
+	b.msg.Properties[key] = value
+	return b
+}
+
+// Properties adds the properties to the message
+func (b *MessageBuilder) Properties(properties map[string]string) *MessageBuilder {
+	for k, v := range properties {
+		b.Property(k, v)
+	}
+	return b
+}
+
+// EventTime sets the application defined event time of the message
+func (b *MessageBuilder) EventTime(t time.Time) *MessageBuilder {
+	b.msg.EventTime = t
+	return b
+}
+
+// ReplicationClusters restricts the replication of the message to the clusters
+func (b *MessageBuilder) ReplicationClusters(clusters ...string) *MessageBuilder {
+	b.msg.ReplicationClusters = append(b.msg.ReplicationClusters, clusters...)
+	return b
+}
+
+// DisableReplication disables the replication of the message to the other clusters
+func (b *MessageBuilder) DisableReplication() *MessageBuilder {
+	b.msg.DisableReplication = true
+	return b
+}
+
+// SequenceID sets the sequence id of the message instead of the one assigned by the producer
+func (b *MessageBuilder) SequenceID(id int64) *MessageBuilder {
+	b.msg.SequenceID = &id
+	return b
+}
+
+// DeliverAfter delays the delivery of the message, exclusive with DeliverAt
+func (b *MessageBuilder) DeliverAfter(delay time.Duration) *MessageBuilder {
+	b.msg.DeliverAfter = delay
+	return b
+}
+
+// DeliverAt delays the delivery of the message until the time, exclusive with DeliverAfter
+func (b *MessageBuilder) DeliverAt(t time.Time) *MessageBuilder {
+	b.msg.DeliverAt = t
+	return b
+}
+
+// Schema sets the schema of the message instead of the producer one
+func (b *MessageBuilder) Schema(schema Schema) *MessageBuilder {
+	b.msg.Schema = schema
+	return b
+}
+
+// Transaction sends the message as part of the transaction
+func (b *MessageBuilder) Transaction(txn Transaction) *MessageBuilder {
+	b.msg.Transaction = txn
+	return b
+}
+
+// DisableCompression sends the message uncompressed, outside of any batch
+func (b *MessageBuilder) DisableCompression() *MessageBuilder {
+	b.msg.DisableCompression = true
+	return b
+}
+
+// Build validates the message and returns it. It fails with InvalidMessage when fields are set together
+// that the producer would reject or silently ignore:
+//   - Payload with Value
+//   - DeliverAt with DeliverAfter, or a negative DeliverAfter
+//   - OrderingKey with a delayed delivery, the delayed messages are not delivered in order
+//   - ReplicationClusters with DisableReplication
+//   - a negative SequenceID
+//   - a delayed delivery or a Transaction on a non-persistent topic
+func (b *MessageBuilder) Build() (*ProducerMessage, error) {
+	tn, err := internal.ParseTopicName(b.topic)
+	if err != nil {
+		return nil, newError(InvalidTopicName, err.Error())
+	}
+
+	m := &b.msg
+	delayed := !m.DeliverAt.IsZero() || m.DeliverAfter != 0
+	persistent := tn.Domain == "persistent"
+	switch {
+	case m.Payload != nil && m.Value != nil:
+		return nil, b.invalid("a payload and a value can not be both set")
+	case !m.DeliverAt.IsZero() && m.DeliverAfter != 0:
+		return nil, b.invalid("deliver at and deliver after can not be both set")
+	case m.DeliverAfter < 0:
+		return nil, b.invalid(fmt.Sprintf("negative deliver after %v", m.DeliverAfter))
+	case m.OrderingKey != "" && delayed:
+		return nil, b.invalid("an ordering key can not be set on a delayed message")
+	case len(m.ReplicationClusters) > 0 && m.DisableReplication:
+		return nil, b.invalid("replication clusters can not be set when the replication is disabled")
+	case m.SequenceID != nil && *m.SequenceID < 0:
+		return nil, b.invalid(fmt.Sprintf("negative sequence id %d", *m.SequenceID))
+	case delayed && !persistent:
+		return nil, b.invalid("delayed delivery is not supported on non-persistent topics")
+	case m.Transaction != nil && !persistent:
+		return nil, b.invalid("transactions are not supported on non-persistent topics")
+	}
+
+	msg := *m
+	if m.Properties != nil {
+		msg.Properties = make(map[string]string, len(m.Properties))
+		for k, v := range m.Properties {
+			msg.Properties[k] = v
+		}
+	}
+	if m.ReplicationClusters != nil {
+		msg.ReplicationClusters = append([]string(nil), m.ReplicationClusters...)
+	}
+	if m.SequenceID != nil {
+		id := *m.SequenceID
+		msg.SequenceID = &id
+	}
+	return &msg, nil
+}
+
+func (b *MessageBuilder) invalid(msg string) error {
+	return newError(InvalidMessage, fmt.Sprintf("invalid message for topic %s: %s", b.topic, msg))
+}
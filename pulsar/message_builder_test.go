@@ -0,0 +1,76 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsar
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMessageBuilder(t *testing.T) {
+	b := NewMessage("my-topic").
+		Payload([]byte("hello")).
+		Key("k").
+		Property("a", "1").
+		ReplicationClusters("us-east").
+		SequenceID(7).
+		DeliverAfter(time.Second)
+	msg, err := b.Build()
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", string(msg.Payload))
+	assert.Equal(t, "k", msg.Key)
+	assert.Equal(t, map[string]string{"a": "1"}, msg.Properties)
+	assert.Equal(t, []string{"us-east"}, msg.ReplicationClusters)
+	assert.Equal(t, int64(7), *msg.SequenceID)
+	assert.Equal(t, time.Second, msg.DeliverAfter)
+
+	// the built message does not change with the builder
+	b.Property("b", "2").ReplicationClusters("us-west").SequenceID(8)
+	assert.Equal(t, map[string]string{"a": "1"}, msg.Properties)
+	assert.Equal(t, []string{"us-east"}, msg.ReplicationClusters)
+	assert.Equal(t, int64(7), *msg.SequenceID)
+}
+
+func TestMessageBuilderValidation(t *testing.T) {
+	np := "non-persistent://public/default/my-topic"
+	for name, b := range map[string]*MessageBuilder{
+		"payload and value":     NewMessage("my-topic").Payload([]byte("a")).Value("a"),
+		"deliver at and after":  NewMessage("my-topic").DeliverAt(time.Now()).DeliverAfter(time.Second),
+		"negative deliver":      NewMessage("my-topic").DeliverAfter(-time.Second),
+		"delayed ordering key":  NewMessage("my-topic").OrderingKey("k").DeliverAfter(time.Second),
+		"disabled replication":  NewMessage("my-topic").ReplicationClusters("us-east").DisableReplication(),
+		"negative sequence id":  NewMessage("my-topic").SequenceID(-1),
+		"non-persistent delay":  NewMessage(np).DeliverAt(time.Now()),
+		"non-persistent txn":    NewMessage(np).Transaction(&transaction{}),
+		"invalid topic message": NewMessage("invalid://topic"),
+	} {
+		t.Run(name, func(t *testing.T) {
+			msg, err := b.Build()
+			assert.Nil(t, msg)
+			var perr *Error
+			assert.True(t, errors.As(err, &perr))
+		})
+	}
+
+	msg, err := NewMessage(np).Key("k").DisableReplication().Build()
+	assert.NoError(t, err)
+	assert.True(t, msg.DisableReplication)
+}
@@ -0,0 +1,135 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsar
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/apache/pulsar-client-go/pulsar/crypto"
+	"github.com/apache/pulsar-client-go/pulsar/internal"
+	pb "github.com/apache/pulsar-client-go/pulsar/internal/pulsar_proto"
+	"github.com/apache/pulsar-client-go/pulsar/log"
+	"google.golang.org/protobuf/proto"
+)
+
+// DecryptedMessage is a message decrypted with DecryptMessage or DecryptPayload
+type DecryptedMessage struct {
+	Payload    []byte
+	Key        string
+	Properties map[string]string
+	EventTime  time.Time
+}
+
+// DecryptMessage decrypts a message delivered encrypted because of crypto.ConsumerCryptoFailureActionConsume,
+// e.g. once the decryption keys are available again. The payload is also uncompressed, and a batch message is
+// split in the messages of the batch.
+func DecryptMessage(msg Message, decryption MessageDecryptionInfo) ([]DecryptedMessage, error) {
+	msgs, err := DecryptPayload(msg.Payload(), msg.GetEncryptionContext(), decryption)
+	if err != nil {
+		return nil, err
+	}
+	if msg.GetEncryptionContext().BatchSize == 0 {
+		msgs[0].Key = msg.Key()
+		msgs[0].Properties = msg.Properties()
+		msgs[0].EventTime = msg.EventTime()
+	}
+	return msgs, nil
+}
+
+// DecryptPayload decrypts the payload of a message delivered encrypted because of
+// crypto.ConsumerCryptoFailureActionConsume, which was stored along with its encryption context.
+// See DecryptMessage.
+func DecryptPayload(payload []byte, encCtx *EncryptionContext,
+	decryption MessageDecryptionInfo) ([]DecryptedMessage, error) {
+	if encCtx == nil {
+		return nil, newError(InvalidMessage, "message is not encrypted")
+	}
+	if decryption.KeyReader == nil {
+		return nil, newError(CryptoError, "KeyReader is required to decrypt the message")
+	}
+
+	messageCrypto := decryption.MessageCrypto
+	if messageCrypto == nil {
+		var err error
+		messageCrypto, err = crypto.NewDefaultMessageCrypto("decrypt", false, log.DefaultNopLogger())
+		if err != nil {
+			return nil, newError(CryptoError, err.Error())
+		}
+	}
+
+	msgMeta := encryptionContextMetadata(encCtx)
+	decrypted, err := messageCrypto.Decrypt(crypto.NewMessageMetadataSupplier(msgMeta), payload,
+		decryption.KeyReader)
+	if err != nil {
+		return nil, newError(CryptoError, fmt.Sprintf("failed to decrypt the message: %v", err))
+	}
+
+	provider, err := newCompressionProvider(msgMeta.GetCompression())
+	if err != nil {
+		return nil, err
+	}
+	defer provider.Close()
+	uncompressed, err := provider.Decompress(nil, decrypted, encCtx.UncompressedSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress the message: %w", err)
+	}
+
+	if encCtx.BatchSize == 0 {
+		return []DecryptedMessage{{Payload: uncompressed}}, nil
+	}
+
+	reader := internal.NewBatchMessageReader(internal.NewBufferWrapper(uncompressed))
+	msgs := make([]DecryptedMessage, 0, encCtx.BatchSize)
+	for i := 0; i < encCtx.BatchSize; i++ {
+		smm, p, err := reader.ReadMessage()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read message %d of the batch: %w", i, err)
+		}
+		msg := DecryptedMessage{
+			Payload:    p,
+			Key:        smm.GetPartitionKey(),
+			Properties: internal.ConvertToStringMap(smm.GetProperties()),
+		}
+		if smm.EventTime != nil {
+			msg.EventTime = timeFromUnixTimestampMillis(smm.GetEventTime())
+		}
+		msgs = append(msgs, msg)
+	}
+	return msgs, nil
+}
+
+// encryptionContextMetadata is the reverse of createEncryptionContext
+func encryptionContextMetadata(encCtx *EncryptionContext) *pb.MessageMetadata {
+	compression := pb.CompressionType(encCtx.CompressionType)
+	msgMeta := &pb.MessageMetadata{
+		EncryptionParam: encCtx.Param,
+		Compression:     &compression,
+	}
+	if encCtx.Algorithm != "" {
+		msgMeta.EncryptionAlgo = &encCtx.Algorithm
+	}
+	for name, key := range encCtx.Keys {
+		msgMeta.EncryptionKeys = append(msgMeta.EncryptionKeys, &pb.EncryptionKeys{
+			Key:      proto.String(name),
+			Value:    key.KeyValue,
+			Metadata: internal.ConvertFromStringMap(key.Metadata),
+		})
+	}
+	return msgMeta
+}
@@ -0,0 +1,60 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsar
+
+import (
+	"testing"
+
+	"github.com/apache/pulsar-client-go/pulsar/crypto"
+	"github.com/apache/pulsar-client-go/pulsar/internal/compression"
+	cryptointernal "github.com/apache/pulsar-client-go/pulsar/internal/crypto"
+	pb "github.com/apache/pulsar-client-go/pulsar/internal/pulsar_proto"
+	"github.com/apache/pulsar-client-go/pulsar/log"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestDecryptPayload(t *testing.T) {
+	keyReader := crypto.NewFileKeyReader("crypto/testdata/pub_key_rsa.pem", "crypto/testdata/pri_key_rsa.pem")
+	messageCrypto, err := crypto.NewDefaultMessageCrypto("encrypt", true, log.DefaultNopLogger())
+	assert.Nil(t, err)
+	encryptor := cryptointernal.NewProducerEncryptor([]string{"client-rsa.pem"}, keyReader, messageCrypto,
+		crypto.ProducerCryptoFailureActionFail, log.DefaultNopLogger())
+
+	payload := []byte("hello encrypted world")
+	compressed := compression.NewZLibProvider().Compress(nil, payload)
+	msgMeta := &pb.MessageMetadata{
+		Compression:      pb.CompressionType_ZLIB.Enum(),
+		UncompressedSize: proto.Uint32(uint32(len(payload))),
+	}
+	encrypted, err := encryptor.Encrypt(compressed, msgMeta)
+	assert.Nil(t, err)
+
+	encCtx := createEncryptionContext(msgMeta)
+
+	_, err = DecryptPayload(encrypted, encCtx, MessageDecryptionInfo{})
+	assert.Error(t, err)
+
+	msgs, err := DecryptPayload(encrypted, encCtx, MessageDecryptionInfo{KeyReader: keyReader})
+	assert.Nil(t, err)
+	assert.Len(t, msgs, 1)
+	assert.Equal(t, payload, msgs[0].Payload)
+
+	_, err = DecryptPayload(encrypted, nil, MessageDecryptionInfo{KeyReader: keyReader})
+	assert.Error(t, err)
+}
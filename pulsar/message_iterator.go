@@ -0,0 +1,45 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsar
+
+import "context"
+
+// MessageIterator receives messages from a consumer and acknowledges them automatically: the caller marks
+// each message as done once it has been processed, in any order, and the iterator cumulatively acknowledges
+// the messages up to the highest contiguous done message of each partition. Messages that were received but
+// not marked as done are never acknowledged, so they are redelivered after a crash (at-least-once). A message
+// redelivered before the watermark moved past it, e.g. after a reconnection, keeps its place and must be marked
+// as done again.
+// It can only be used with Exclusive or Failover subscriptions.
+type MessageIterator interface {
+	// Next receives the next message, blocking until a message is available or the context is done.
+	Next(ctx context.Context) (Message, error)
+
+	// Done marks the message as processed, acknowledging cumulatively the contiguous range of done messages
+	// of its partition.
+	Done(msg Message) error
+
+	// Pending returns the number of messages received and not yet acknowledged.
+	Pending() int
+}
+
+// NewMessageIterator creates a message iterator receiving the messages of the consumer.
+// The consumer must not be used to receive or acknowledge messages while the iterator is in use.
+func NewMessageIterator(consumer Consumer) (MessageIterator, error) {
+	return newMessageIterator(consumer)
+}
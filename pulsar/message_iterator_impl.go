@@ -0,0 +1,149 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsar
+
+import (
+	"context"
+	"sync"
+)
+
+// iteratorMessageKey identifies a message within a partition
+type iteratorMessageKey struct {
+	ledgerID int64
+	entryID  int64
+	batchIdx int32
+}
+
+type iteratorMessage struct {
+	id   MessageID
+	done bool
+}
+
+type messageIterator struct {
+	sync.Mutex
+	consumer Consumer
+	// partition topic -> messages in the receive order, a message being pending once whatever its redeliveries
+	pending map[string][]*iteratorMessage
+	// partition topic -> message id -> message
+	index map[string]map[iteratorMessageKey]*iteratorMessage
+}
+
+func newMessageIterator(consumer Consumer) (*messageIterator, error) {
+	if consumer == nil {
+		return nil, newError(InvalidConfiguration, "consumer is required")
+	}
+	if subType, ok := consumerSubscriptionType(consumer); ok && subType != Exclusive && subType != Failover {
+		return nil, newError(InvalidConfiguration,
+			"cumulative acknowledgement is only supported on Exclusive or Failover subscriptions")
+	}
+
+	return &messageIterator{
+		consumer: consumer,
+		pending:  make(map[string][]*iteratorMessage),
+		index:    make(map[string]map[iteratorMessageKey]*iteratorMessage),
+	}, nil
+}
+
+func consumerSubscriptionType(c Consumer) (SubscriptionType, bool) {
+	switch consumer := c.(type) {
+	case *consumer:
+		return consumer.options.Type, true
+	case *multiTopicConsumer:
+		return consumer.options.Type, true
+	case *regexConsumer:
+		return consumer.options.Type, true
+	}
+	return Exclusive, false
+}
+
+func newIteratorMessageKey(id MessageID) iteratorMessageKey {
+	return iteratorMessageKey{
+		ledgerID: id.LedgerID(),
+		entryID:  id.EntryID(),
+		batchIdx: id.BatchIdx(),
+	}
+}
+
+func (it *messageIterator) Next(ctx context.Context) (Message, error) {
+	msg, err := it.consumer.Receive(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	it.Lock()
+	defer it.Unlock()
+
+	topic := msg.Topic()
+	if it.index[topic] == nil {
+		it.index[topic] = make(map[iteratorMessageKey]*iteratorMessage)
+	}
+	key := newIteratorMessageKey(msg.ID())
+	if m, ok := it.index[topic][key]; ok {
+		// a redelivered message keeps its place, it is processed again before the watermark moves past it
+		m.id = msg.ID()
+		m.done = false
+		return msg, nil
+	}
+	m := &iteratorMessage{id: msg.ID()}
+	it.pending[topic] = append(it.pending[topic], m)
+	it.index[topic][key] = m
+	return msg, nil
+}
+
+func (it *messageIterator) Done(msg Message) error {
+	it.Lock()
+	topic := msg.Topic()
+	m, ok := it.index[topic][newIteratorMessageKey(msg.ID())]
+	if !ok {
+		it.Unlock()
+		return newError(InvalidMessage, "the message was not received from the iterator or is already done")
+	}
+	m.done = true
+
+	// move the watermark past the contiguous done messages
+	pending := it.pending[topic]
+	var watermark MessageID
+	i := 0
+	for ; i < len(pending) && pending[i].done; i++ {
+		watermark = pending[i].id
+		delete(it.index[topic], newIteratorMessageKey(pending[i].id))
+	}
+	if i == len(pending) {
+		delete(it.pending, topic)
+		delete(it.index, topic)
+	} else {
+		it.pending[topic] = pending[i:]
+	}
+	it.Unlock()
+
+	if watermark == nil {
+		return nil
+	}
+	return it.consumer.AckIDCumulative(watermark)
+}
+
+func (it *messageIterator) Pending() int {
+	it.Lock()
+	defer it.Unlock()
+
+	n := 0
+	for _, pending := range it.pending {
+		n += len(pending)
+	}
+	return n
+}
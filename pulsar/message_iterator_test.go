@@ -0,0 +1,121 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsar
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type iteratorTestConsumer struct {
+	Consumer
+	messages []Message
+	acked    []MessageID
+}
+
+func (c *iteratorTestConsumer) Receive(ctx context.Context) (Message, error) {
+	msg := c.messages[0]
+	c.messages = c.messages[1:]
+	return msg, nil
+}
+
+func (c *iteratorTestConsumer) AckIDCumulative(msgID MessageID) error {
+	c.acked = append(c.acked, msgID)
+	return nil
+}
+
+func TestMessageIteratorWatermark(t *testing.T) {
+	newMsg := func(topic string, entryID int64) Message {
+		return &message{topic: topic, msgID: newMessageID(1, entryID, 0, 0, 0)}
+	}
+	c := &iteratorTestConsumer{
+		messages: []Message{
+			newMsg("topic-partition-0", 1),
+			newMsg("topic-partition-1", 1),
+			newMsg("topic-partition-0", 2),
+			newMsg("topic-partition-0", 3),
+		},
+	}
+
+	it, err := newMessageIterator(c)
+	assert.Nil(t, err)
+
+	var msgs []Message
+	for i := 0; i < 4; i++ {
+		msg, err := it.Next(context.Background())
+		assert.Nil(t, err)
+		msgs = append(msgs, msg)
+	}
+	assert.Equal(t, 4, it.Pending())
+
+	// done out of order, nothing contiguous yet
+	assert.Nil(t, it.Done(msgs[3]))
+	assert.Empty(t, c.acked)
+
+	// the other partition is acknowledged independently
+	assert.Nil(t, it.Done(msgs[1]))
+	assert.Equal(t, []MessageID{msgs[1].ID()}, c.acked)
+
+	assert.Nil(t, it.Done(msgs[0]))
+	assert.Equal(t, []MessageID{msgs[1].ID(), msgs[0].ID()}, c.acked)
+
+	// the gap is filled, the watermark moves to the last message
+	assert.Nil(t, it.Done(msgs[2]))
+	assert.Equal(t, []MessageID{msgs[1].ID(), msgs[0].ID(), msgs[3].ID()}, c.acked)
+	assert.Equal(t, 0, it.Pending())
+
+	assert.NotNil(t, it.Done(msgs[2]))
+}
+
+func TestMessageIteratorRedelivery(t *testing.T) {
+	newMsg := func(entryID int64) Message {
+		return &message{topic: "topic", msgID: newMessageID(1, entryID, 0, 0, 0)}
+	}
+	c := &iteratorTestConsumer{
+		messages: []Message{newMsg(1), newMsg(2), newMsg(1), newMsg(2)},
+	}
+	it, err := newMessageIterator(c)
+	assert.Nil(t, err)
+	next := func() Message {
+		msg, err := it.Next(context.Background())
+		assert.Nil(t, err)
+		return msg
+	}
+
+	next()
+	assert.Nil(t, it.Done(next()))
+
+	// the redelivered messages are pending once, the message done before its redelivery must be done again
+	first, second := next(), next()
+	assert.Equal(t, 2, it.Pending())
+	assert.Nil(t, it.Done(first))
+	assert.Equal(t, []MessageID{first.ID()}, c.acked)
+	assert.Nil(t, it.Done(second))
+	assert.Equal(t, []MessageID{first.ID(), second.ID()}, c.acked)
+	assert.Equal(t, 0, it.Pending())
+}
+
+func TestMessageIteratorSubscriptionType(t *testing.T) {
+	_, err := newMessageIterator(&consumer{options: ConsumerOptions{Type: Shared}})
+	assert.NotNil(t, err)
+
+	_, err = newMessageIterator(&consumer{options: ConsumerOptions{Type: Failover}})
+	assert.Nil(t, err)
+}
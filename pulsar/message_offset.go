@@ -0,0 +1,93 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsar
+
+import (
+	"fmt"
+)
+
+// An offset encodes the position of a message in a partition in a positive int64, for applications migrating
+// from Kafka that store integer offsets: the ledger id takes the high 31 bits, the entry id the next 20 bits and
+// the batch index the low 12 bits. Offsets compare as the message ids they encode, so they increase with the
+// messages of a partition; they are not contiguous though, unlike Kafka offsets.
+const (
+	offsetBatchBits  = 12
+	offsetEntryBits  = 20
+	offsetLedgerBits = 31
+
+	offsetMaxBatchIdx = 1<<offsetBatchBits - 1
+	offsetMaxEntryID  = 1<<offsetEntryBits - 1
+	offsetMaxLedgerID = 1<<offsetLedgerBits - 1
+
+	// offsetNoBatchIdx encodes the batch index of a message that is not part of a batch, which comes after
+	// the messages of a batch in the same entry
+	offsetNoBatchIdx = offsetMaxBatchIdx
+)
+
+// MessageIDToOffset returns the offset of a message id within its partition.
+// It fails for the earliest and latest message ids and when a part of the id doesn't fit in its bits.
+func MessageIDToOffset(id MessageID) (int64, error) {
+	if id == nil {
+		return 0, newError(InvalidMessage, "message id is nil")
+	}
+	ledgerID, entryID, batchIdx := id.LedgerID(), id.EntryID(), int64(id.BatchIdx())
+	if ledgerID < 0 || ledgerID > offsetMaxLedgerID {
+		return 0, newError(InvalidMessage, fmt.Sprintf("ledger id %d of %v can't be encoded in an offset",
+			ledgerID, id))
+	}
+	if entryID < 0 || entryID > offsetMaxEntryID {
+		return 0, newError(InvalidMessage, fmt.Sprintf("entry id %d of %v can't be encoded in an offset",
+			entryID, id))
+	}
+	if batchIdx < 0 {
+		batchIdx = offsetNoBatchIdx
+	} else if batchIdx >= offsetNoBatchIdx {
+		return 0, newError(InvalidMessage, fmt.Sprintf("batch index %d of %v can't be encoded in an offset",
+			batchIdx, id))
+	}
+	return ledgerID<<(offsetEntryBits+offsetBatchBits) | entryID<<offsetBatchBits | batchIdx, nil
+}
+
+// OffsetToMessageID returns the message id at an offset of the partition, the offset being returned by
+// MessageIDToOffset or MessageOffset for a message without broker index.
+func OffsetToMessageID(offset int64, partition int32) (MessageID, error) {
+	if offset < 0 {
+		return nil, newError(InvalidMessage, fmt.Sprintf("invalid offset %d", offset))
+	}
+	ledgerID := offset >> (offsetEntryBits + offsetBatchBits)
+	entryID := offset >> offsetBatchBits & offsetMaxEntryID
+	batchIdx := int32(offset & offsetMaxBatchIdx)
+	if batchIdx == offsetNoBatchIdx {
+		batchIdx = -1
+	}
+	return NewMessageID(ledgerID, entryID, batchIdx, partition), nil
+}
+
+// MessageOffset returns the offset of a message within its partition. When the broker adds the index to the entry
+// metadata (with the AppendIndexMetadataInterceptor) the index is returned: it is contiguous like a Kafka offset but
+// can't be turned back into a message id. Otherwise it returns the offset of the message id.
+// All the messages of a partition must have an index, or none, for the offsets to be comparable.
+func MessageOffset(msg Message) (int64, error) {
+	if msg == nil {
+		return 0, newError(InvalidMessage, "message is nil")
+	}
+	if index := msg.Index(); index != nil {
+		return int64(*index), nil
+	}
+	return MessageIDToOffset(msg.ID())
+}
@@ -0,0 +1,73 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsar
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMessageIDOffset(t *testing.T) {
+	ids := []MessageID{
+		NewMessageID(1, 0, 0, 2),
+		NewMessageID(1, 0, 1, 2),
+		NewMessageID(1, 1, -1, 2),
+		NewMessageID(1, offsetMaxEntryID, -1, 2),
+		NewMessageID(2, 0, 0, 2),
+		NewMessageID(offsetMaxLedgerID, 7, 4000, 2),
+	}
+
+	var previous int64 = -1
+	for _, id := range ids {
+		offset, err := MessageIDToOffset(id)
+		assert.NoError(t, err)
+		assert.Greater(t, offset, previous)
+		previous = offset
+
+		back, err := OffsetToMessageID(offset, 2)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, messageIDCompare(id, back))
+		assert.Equal(t, id.PartitionIdx(), back.PartitionIdx())
+	}
+
+	_, err := MessageIDToOffset(EarliestMessageID())
+	assert.Error(t, err)
+	_, err = MessageIDToOffset(LatestMessageID())
+	assert.Error(t, err)
+	_, err = MessageIDToOffset(NewMessageID(1, offsetMaxEntryID+1, -1, 0))
+	assert.Error(t, err)
+	_, err = MessageIDToOffset(NewMessageID(1, 1, offsetNoBatchIdx, 0))
+	assert.Error(t, err)
+	_, err = OffsetToMessageID(-1, 0)
+	assert.Error(t, err)
+}
+
+func TestMessageOffset(t *testing.T) {
+	msg := &message{msgID: NewMessageID(3, 4, -1, 0)}
+	offset, err := MessageOffset(msg)
+	assert.NoError(t, err)
+	expected, _ := MessageIDToOffset(msg.ID())
+	assert.Equal(t, expected, offset)
+
+	index := uint64(42)
+	msg.index = &index
+	offset, err = MessageOffset(msg)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(42), offset)
+}
@@ -0,0 +1,129 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsar
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// ErrPropertyNotFound is returned by the typed property getters when the property is not set
+var ErrPropertyNotFound = errors.New("property not found")
+
+// The typed property helpers below read and write message properties with a shared encoding, so that values set by
+// a producer with SetXxxProperty can be read back by any consumer with the matching XxxProperty getter:
+//   - int64 values are encoded in base 10
+//   - bool values are encoded as "true" or "false"
+//   - time.Time values are encoded in UTC with the RFC 3339 format and nanosecond precision
+//   - []byte values are encoded in standard base64
+//
+// The setters take a pointer to the properties, e.g. &msg.Properties, and allocate the map when it is nil.
+
+// SetInt64Property sets an int64 property
+func SetInt64Property(properties *map[string]string, key string, value int64) {
+	setProperty(properties, key, strconv.FormatInt(value, 10))
+}
+
+// Int64Property returns the int64 property set with SetInt64Property
+func Int64Property(properties map[string]string, key string) (int64, error) {
+	value, err := lookupProperty(properties, key)
+	if err != nil {
+		return 0, err
+	}
+	i, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, invalidProperty(key, "int64", err)
+	}
+	return i, nil
+}
+
+// SetBoolProperty sets a bool property
+func SetBoolProperty(properties *map[string]string, key string, value bool) {
+	setProperty(properties, key, strconv.FormatBool(value))
+}
+
+// BoolProperty returns the bool property set with SetBoolProperty
+func BoolProperty(properties map[string]string, key string) (bool, error) {
+	value, err := lookupProperty(properties, key)
+	if err != nil {
+		return false, err
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, invalidProperty(key, "bool", err)
+	}
+	return b, nil
+}
+
+// SetTimeProperty sets a time.Time property
+func SetTimeProperty(properties *map[string]string, key string, value time.Time) {
+	setProperty(properties, key, value.UTC().Format(time.RFC3339Nano))
+}
+
+// TimeProperty returns the time.Time property set with SetTimeProperty, in UTC
+func TimeProperty(properties map[string]string, key string) (time.Time, error) {
+	value, err := lookupProperty(properties, key)
+	if err != nil {
+		return time.Time{}, err
+	}
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return time.Time{}, invalidProperty(key, "time", err)
+	}
+	return t.UTC(), nil
+}
+
+// SetBytesProperty sets a []byte property
+func SetBytesProperty(properties *map[string]string, key string, value []byte) {
+	setProperty(properties, key, base64.StdEncoding.EncodeToString(value))
+}
+
+// BytesProperty returns the []byte property set with SetBytesProperty
+func BytesProperty(properties map[string]string, key string) ([]byte, error) {
+	value, err := lookupProperty(properties, key)
+	if err != nil {
+		return nil, err
+	}
+	b, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return nil, invalidProperty(key, "bytes", err)
+	}
+	return b, nil
+}
+
+func setProperty(properties *map[string]string, key, value string) {
+	if *properties == nil {
+		*properties = make(map[string]string)
+	}
+	(*properties)[key] = value
+}
+
+func lookupProperty(properties map[string]string, key string) (string, error) {
+	value, ok := properties[key]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrPropertyNotFound, key)
+	}
+	return value, nil
+}
+
+func invalidProperty(key, kind string, err error) error {
+	return fmt.Errorf("property %s is not a valid %s: %w", key, kind, err)
+}
@@ -0,0 +1,88 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsar
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTypedProperties(t *testing.T) {
+	props := map[string]string{}
+	now := time.Date(2022, 3, 4, 5, 6, 7, 8, time.FixedZone("CET", 3600))
+
+	SetInt64Property(&props, "count", -42)
+	SetBoolProperty(&props, "retry", true)
+	SetTimeProperty(&props, "created", now)
+	SetBytesProperty(&props, "digest", []byte{0, 1, 0xff})
+
+	assert.Equal(t, "-42", props["count"])
+	assert.Equal(t, "true", props["retry"])
+	assert.Equal(t, "2022-03-04T04:06:07.000000008Z", props["created"])
+	assert.Equal(t, "AAH/", props["digest"])
+
+	i, err := Int64Property(props, "count")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(-42), i)
+
+	b, err := BoolProperty(props, "retry")
+	assert.NoError(t, err)
+	assert.True(t, b)
+
+	ts, err := TimeProperty(props, "created")
+	assert.NoError(t, err)
+	assert.True(t, now.Equal(ts))
+	assert.Equal(t, time.UTC, ts.Location())
+
+	bytes, err := BytesProperty(props, "digest")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0, 1, 0xff}, bytes)
+}
+
+func TestTypedPropertiesErrors(t *testing.T) {
+	props := map[string]string{"count": "abc"}
+
+	_, err := Int64Property(props, "missing")
+	assert.True(t, errors.Is(err, ErrPropertyNotFound))
+
+	_, err = Int64Property(props, "count")
+	assert.Error(t, err)
+	assert.False(t, errors.Is(err, ErrPropertyNotFound))
+
+	_, err = BoolProperty(props, "count")
+	assert.Error(t, err)
+
+	_, err = TimeProperty(props, "count")
+	assert.Error(t, err)
+
+	_, err = BytesProperty(map[string]string{"digest": "%%"}, "digest")
+	assert.Error(t, err)
+}
+
+func TestTypedPropertiesNilMap(t *testing.T) {
+	msg := &ProducerMessage{}
+	SetInt64Property(&msg.Properties, "count", 1)
+	assert.Equal(t, map[string]string{"count": "1"}, msg.Properties)
+
+	var options ProducerOptions
+	SetBoolProperty(&options.Properties, "retry", false)
+	assert.Equal(t, map[string]string{"retry": "false"}, options.Properties)
+}
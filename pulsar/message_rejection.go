@@ -0,0 +1,133 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsar
+
+import "fmt"
+
+// PropertyRejectionReason is the property holding the reason a message routed to the dead letter topic by
+// RouteMessageToDLQ was rejected
+const PropertyRejectionReason = "REJECTION_REASON"
+
+const (
+	rejectedByFilter = "filter"
+	rejectedByDecode = "decode"
+)
+
+// MessageDisposition is what a consumer does with a message rejected by its MessageFilter or failing the schema
+// validation, see ConsumerOptions.FilterRejection and ConsumerOptions.DecodeFailure
+type MessageDisposition int
+
+const (
+	// AckAndDropMessage acknowledges the message without delivering it to the application
+	AckAndDropMessage MessageDisposition = iota
+	// NackMessage negatively acknowledges the message without delivering it, for it to be redelivered later
+	NackMessage
+	// RouteMessageToDLQ sends the message to the dead letter topic of the DLQ policy of the consumer, with the
+	// reason it was rejected in the PropertyRejectionReason property
+	RouteMessageToDLQ
+	// DeliverMessage delivers the message to the application anyway, the rejection is only counted
+	DeliverMessage
+)
+
+func (d MessageDisposition) String() string {
+	switch d {
+	case AckAndDropMessage:
+		return "ack"
+	case NackMessage:
+		return "nack"
+	case RouteMessageToDLQ:
+		return "dlq"
+	case DeliverMessage:
+		return "deliver"
+	}
+	return "unknown"
+}
+
+// rejectMessages returns the messages to deliver, after the ones rejected by the filter or failing the schema
+// validation have been handled as set by their disposition. It is called by the dispatcher.
+func (pc *partitionConsumer) rejectMessages(msgs []*message) []*message {
+	if pc.options.messageFilter == nil && !pc.options.validateSchema {
+		return msgs
+	}
+
+	kept := msgs[:0]
+	for _, msg := range msgs {
+		reason, detail, disposition := pc.rejection(msg)
+		if reason == "" {
+			kept = append(kept, msg)
+			continue
+		}
+
+		pc.metrics.MessagesRejected.WithLabelValues(reason, disposition.String()).Inc()
+		pc.log.WithField("msgID", msg.msgID).WithField("reason", detail).
+			Debugf("Message rejected, disposition: %s", disposition)
+
+		switch disposition {
+		case DeliverMessage:
+			kept = append(kept, msg)
+			continue
+		case RouteMessageToDLQ:
+			if msg.properties == nil {
+				msg.properties = make(map[string]string)
+			}
+			msg.properties[PropertyRejectionReason] = detail
+			msg.rejected = true
+			kept = append(kept, msg)
+			continue
+		case NackMessage:
+			pc.ackHoleTracker.delivered(msg.msgID)
+			pc.NackID(msg.msgID)
+		default:
+			pc.ackHoleTracker.delivered(msg.msgID)
+			// the ack may have to wait for the events loop, which may itself be waiting for the dispatcher
+			go pc.AckID(msg.msgID)
+		}
+
+		pc.metrics.PrefetchedMessages.Dec()
+		pc.metrics.PrefetchedBytes.Sub(float64(len(msg.payLoad)))
+		pc.availablePermits.inc()
+	}
+	return kept
+}
+
+// rejection returns why the message is rejected, with the disposition that applies, or an empty reason
+func (pc *partitionConsumer) rejection(msg *message) (reason string, detail string, disposition MessageDisposition) {
+	if pc.options.validateSchema {
+		if err := pc.validateSchema(msg); err != nil {
+			return rejectedByDecode, fmt.Sprintf("decode failure: %v", err), pc.options.decodeFailure
+		}
+	}
+	if pc.options.messageFilter != nil && !pc.options.messageFilter(msg) {
+		return rejectedByFilter, "rejected by filter", pc.options.filterRejection
+	}
+	return "", "", 0
+}
+
+func (pc *partitionConsumer) validateSchema(msg *message) error {
+	schema := pc.options.schema
+	if msg.schemaVersion != nil && pc.schemaInfoCache != nil {
+		var err error
+		if schema, err = pc.schemaInfoCache.Get(msg.schemaVersion); err != nil {
+			return err
+		}
+	}
+	if schema == nil {
+		return nil
+	}
+	return schema.Validate(msg.payLoad)
+}
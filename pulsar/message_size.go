@@ -0,0 +1,59 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsar
+
+// defaultOversizedMessageThreshold is the size of the payloads, 1 MiB, above which the messages would rather be
+// chunked by the producers to avoid loading the broker and the consumers with large entries
+const defaultOversizedMessageThreshold = 1 << 20
+
+func oversizedMessageThreshold(threshold int) int {
+	if threshold == 0 {
+		return defaultOversizedMessageThreshold
+	}
+	if threshold < 0 {
+		return 0
+	}
+	return threshold
+}
+
+// OversizedMessageListener can be implemented by the ConsumerOptions.EventListener to be notified of the messages
+// received with a payload above ConsumerOptions.OversizedMessageThreshold which were not chunked, often the sign of
+// producers skipping chunking.
+type OversizedMessageListener interface {
+	OversizedMessageReceived(consumer Consumer, topicName string, msgID MessageID, size int)
+}
+
+// observeMessageSize exports the size of the payload of a message received and reports it when it is above the
+// oversized message threshold and the message was not chunked
+func (pc *partitionConsumer) observeMessageSize(msgID MessageID, size int, chunked bool) {
+	pc.metrics.MessageSize.Observe(float64(size))
+
+	threshold := pc.options.oversizedMessageThreshold
+	if chunked || threshold <= 0 || size <= threshold {
+		return
+	}
+	pc.metrics.OversizedMessages.Inc()
+	pc.log.WithField("msgID", msgID).WithField("size", size).
+		Warnf("Received a message larger than %d bytes which was not chunked", threshold)
+
+	if listener, ok := pc.options.consumerEventListener.(OversizedMessageListener); ok {
+		pc.callbacks.execute(pc.topic, func() {
+			listener.OversizedMessageReceived(pc.parentConsumer, pc.topic, msgID, size)
+		})
+	}
+}
@@ -0,0 +1,76 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsar
+
+import (
+	"testing"
+
+	"github.com/apache/pulsar-client-go/pulsar/internal"
+	plog "github.com/apache/pulsar-client-go/pulsar/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+type oversizedMessageRecorder struct {
+	sizes []int
+}
+
+func (r *oversizedMessageRecorder) BecameActive(Consumer, string, int32) {}
+
+func (r *oversizedMessageRecorder) BecameInactive(Consumer, string, int32) {}
+
+func (r *oversizedMessageRecorder) OversizedMessageReceived(_ Consumer, _ string, _ MessageID, size int) {
+	r.sizes = append(r.sizes, size)
+}
+
+func TestOversizedMessageThreshold(t *testing.T) {
+	assert.Equal(t, defaultOversizedMessageThreshold, oversizedMessageThreshold(0))
+	assert.Equal(t, 0, oversizedMessageThreshold(-1))
+	assert.Equal(t, 1024, oversizedMessageThreshold(1024))
+}
+
+func TestObserveMessageSize(t *testing.T) {
+	recorder := &oversizedMessageRecorder{}
+	pc := &partitionConsumer{
+		options: &partitionConsumerOpts{
+			oversizedMessageThreshold: 1024,
+			consumerEventListener:     recorder,
+		},
+		metrics: internal.NewMetricsProvider(4, map[string]string{}, prometheus.NewRegistry()).
+			GetLeveledConsumerMetrics("topic", "sub"),
+		log: plog.DefaultNopLogger(),
+	}
+	msgID := newMessageID(1, 2, 0, 0, 0)
+
+	pc.observeMessageSize(msgID, 100, false)
+	pc.observeMessageSize(msgID, 1024, false)
+	assert.Equal(t, 0.0, testutil.ToFloat64(pc.metrics.OversizedMessages))
+
+	pc.observeMessageSize(msgID, 2048, false)
+	assert.Equal(t, 1.0, testutil.ToFloat64(pc.metrics.OversizedMessages))
+	assert.Equal(t, []int{2048}, recorder.sizes)
+
+	// the chunked messages are only observed
+	pc.observeMessageSize(msgID, 4096, true)
+	assert.Equal(t, 1.0, testutil.ToFloat64(pc.metrics.OversizedMessages))
+
+	pc.options.oversizedMessageThreshold = 0
+	pc.observeMessageSize(msgID, 4096, false)
+	assert.Equal(t, 1.0, testutil.ToFloat64(pc.metrics.OversizedMessages))
+}
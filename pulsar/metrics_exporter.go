@@ -0,0 +1,259 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsar
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/apache/pulsar-client-go/pulsar/log"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+const defaultMetricsExportInterval = time.Minute
+
+// MetricType is the type of a metric exported to a MetricsExporter
+type MetricType string
+
+const (
+	// MetricCounter is a value which only increases, e.g. the number of messages published
+	MetricCounter MetricType = "counter"
+	// MetricGauge is a value which increases and decreases, e.g. the number of messages pending
+	MetricGauge MetricType = "gauge"
+	// MetricHistogram is the distribution of observations, e.g. the publish latencies
+	MetricHistogram MetricType = "histogram"
+)
+
+// MetricFamily is a metric of the client, such as pulsar_client_messages_published, with its samples by labels
+type MetricFamily struct {
+	Name    string         `json:"name"`
+	Help    string         `json:"help,omitempty"`
+	Type    MetricType     `json:"type"`
+	Samples []MetricSample `json:"samples"`
+}
+
+// MetricSample is the value of a metric for a set of labels
+type MetricSample struct {
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// Value is the value of the counters and gauges
+	Value float64 `json:"value"`
+
+	// Histogram is the distribution of the histograms
+	Histogram *HistogramSample `json:"histogram,omitempty"`
+}
+
+// HistogramSample is the distribution of the observations of a histogram
+type HistogramSample struct {
+	Count uint64  `json:"count"`
+	Sum   float64 `json:"sum"`
+
+	// Buckets are the cumulative counts of the observations less than or equal to their upper bound, the
+	// observations above the last upper bound are only in Count
+	Buckets []HistogramBucket `json:"buckets"`
+}
+
+// HistogramBucket is the cumulative count of the observations of a histogram up to UpperBound
+type HistogramBucket struct {
+	UpperBound float64 `json:"upperBound"`
+	Count      uint64  `json:"count"`
+}
+
+// MetricsExporter exports the metrics of a client, besides the Prometheus registerer, for the environments
+// Prometheus can't scrape such as short-lived batch jobs. See ClientOptions.MetricsExporter.
+type MetricsExporter interface {
+	// Export exports the metrics of the client gathered at the given time. It's called from a goroutine of the
+	// client, one export at a time, and must return once the context is done.
+	Export(ctx context.Context, timestamp time.Time, metrics []MetricFamily) error
+}
+
+// NewJSONMetricsExporter returns a MetricsExporter writing each export as a line of JSON to w, e.g. os.Stdout
+// to export the metrics to the console or the log of a job
+func NewJSONMetricsExporter(w io.Writer) MetricsExporter {
+	return &jsonMetricsExporter{w: w}
+}
+
+type jsonMetricsExporter struct {
+	sync.Mutex
+	w io.Writer
+}
+
+type jsonMetricsExport struct {
+	Time    time.Time      `json:"time"`
+	Metrics []MetricFamily `json:"metrics"`
+}
+
+func (e *jsonMetricsExporter) Export(_ context.Context, timestamp time.Time, metrics []MetricFamily) error {
+	data, err := json.Marshal(jsonMetricsExport{Time: timestamp, Metrics: metrics})
+	if err != nil {
+		return err
+	}
+	e.Lock()
+	defer e.Unlock()
+	_, err = e.w.Write(append(data, '\n'))
+	return err
+}
+
+// metricsExport gathers the metrics of the client from its registerer and exports them every interval, and a
+// last time when the client is closed
+type metricsExport struct {
+	gatherer prometheus.Gatherer
+	exporter MetricsExporter
+	interval time.Duration
+	log      log.Logger
+
+	closeOnce sync.Once
+	closeCh   chan struct{}
+	doneCh    chan struct{}
+}
+
+func newMetricsExport(options ClientOptions, logger log.Logger) (*metricsExport, error) {
+	if options.MetricsExporter == nil {
+		return nil, nil
+	}
+	registerer := options.MetricsRegisterer
+	if registerer == nil {
+		registerer = prometheus.DefaultRegisterer
+	}
+	gatherer, ok := registerer.(prometheus.Gatherer)
+	if !ok {
+		return nil, newError(InvalidConfiguration,
+			"MetricsExporter requires a MetricsRegisterer which is also a prometheus.Gatherer")
+	}
+	interval := options.MetricsExportInterval
+	if interval <= 0 {
+		interval = defaultMetricsExportInterval
+	}
+	e := &metricsExport{
+		gatherer: gatherer,
+		exporter: options.MetricsExporter,
+		interval: interval,
+		log:      logger,
+		closeCh:  make(chan struct{}),
+		doneCh:   make(chan struct{}),
+	}
+	go e.run()
+	return e, nil
+}
+
+func (e *metricsExport) run() {
+	defer close(e.doneCh)
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			e.export()
+		case <-e.closeCh:
+			e.export()
+			return
+		}
+	}
+}
+
+func (e *metricsExport) export() {
+	// the metrics gathered are exported even if some collectors failed
+	families, err := e.gatherer.Gather()
+	if err != nil {
+		e.log.WithError(err).Warn("Failed to gather some of the metrics to export")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), e.interval)
+	defer cancel()
+	if err := e.exporter.Export(ctx, time.Now(), toMetricFamilies(families)); err != nil {
+		e.log.WithError(err).Warn("Failed to export the metrics")
+	}
+}
+
+// close exports the metrics a last time and stops exporting them
+func (e *metricsExport) close() {
+	if e == nil {
+		return
+	}
+	e.closeOnce.Do(func() {
+		close(e.closeCh)
+	})
+	<-e.doneCh
+}
+
+// toMetricFamilies converts the metrics of the client gathered from the registerer, which may have other metrics
+func toMetricFamilies(families []*dto.MetricFamily) []MetricFamily {
+	metrics := make([]MetricFamily, 0, len(families))
+	for _, family := range families {
+		if !strings.HasPrefix(family.GetName(), "pulsar_client_") {
+			continue
+		}
+		metric := MetricFamily{
+			Name:    family.GetName(),
+			Help:    family.GetHelp(),
+			Samples: make([]MetricSample, 0, len(family.GetMetric())),
+		}
+		switch family.GetType() {
+		case dto.MetricType_COUNTER:
+			metric.Type = MetricCounter
+		case dto.MetricType_GAUGE:
+			metric.Type = MetricGauge
+		case dto.MetricType_HISTOGRAM:
+			metric.Type = MetricHistogram
+		default:
+			continue
+		}
+		for _, m := range family.GetMetric() {
+			metric.Samples = append(metric.Samples, toMetricSample(m))
+		}
+		metrics = append(metrics, metric)
+	}
+	return metrics
+}
+
+func toMetricSample(m *dto.Metric) MetricSample {
+	sample := MetricSample{}
+	if len(m.GetLabel()) > 0 {
+		sample.Labels = make(map[string]string, len(m.GetLabel()))
+		for _, label := range m.GetLabel() {
+			sample.Labels[label.GetName()] = label.GetValue()
+		}
+	}
+	switch {
+	case m.Counter != nil:
+		sample.Value = m.GetCounter().GetValue()
+	case m.Gauge != nil:
+		sample.Value = m.GetGauge().GetValue()
+	case m.Histogram != nil:
+		h := m.GetHistogram()
+		sample.Histogram = &HistogramSample{
+			Count:   h.GetSampleCount(),
+			Sum:     h.GetSampleSum(),
+			Buckets: make([]HistogramBucket, 0, len(h.GetBucket())),
+		}
+		for _, b := range h.GetBucket() {
+			// the +Inf bucket, which can't be encoded in JSON, is the count
+			if math.IsInf(b.GetUpperBound(), 1) {
+				continue
+			}
+			sample.Histogram.Buckets = append(sample.Histogram.Buckets,
+				HistogramBucket{UpperBound: b.GetUpperBound(), Count: b.GetCumulativeCount()})
+		}
+	}
+	return sample
+}
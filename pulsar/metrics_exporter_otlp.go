@@ -0,0 +1,253 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsar
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/apache/pulsar-client-go/pulsar/internal"
+)
+
+const (
+	otlpScopeName = "github.com/apache/pulsar-client-go"
+
+	// otlpCumulativeTemporality is AGGREGATION_TEMPORALITY_CUMULATIVE, the counters and histograms of the client
+	// accumulate since it's been created
+	otlpCumulativeTemporality = 2
+)
+
+// NewOTLPMetricsExporter returns a MetricsExporter posting the metrics with the OTLP/HTTP protocol, encoded in
+// JSON, to the metrics endpoint of an OpenTelemetry collector, e.g. http://localhost:4318/v1/metrics. The headers,
+// e.g. an authorization header, are added to each request.
+func NewOTLPMetricsExporter(endpoint string, headers map[string]string) MetricsExporter {
+	return &otlpMetricsExporter{
+		endpoint:  endpoint,
+		headers:   headers,
+		client:    &http.Client{},
+		startTime: time.Now(),
+	}
+}
+
+type otlpMetricsExporter struct {
+	endpoint  string
+	headers   map[string]string
+	client    *http.Client
+	startTime time.Time
+}
+
+func (e *otlpMetricsExporter) Export(ctx context.Context, timestamp time.Time, metrics []MetricFamily) error {
+	body, err := json.Marshal(e.toRequest(timestamp, metrics))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range e.headers {
+		req.Header.Set(name, value)
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("OTLP metrics export to %s failed with status %s: %s", e.endpoint, resp.Status, msg)
+	}
+	return nil
+}
+
+// the OTLP/HTTP JSON encoding of the ExportMetricsServiceRequest, the 64 bits integers are encoded as strings
+
+type otlpRequest struct {
+	ResourceMetrics []otlpResourceMetrics `json:"resourceMetrics"`
+}
+
+type otlpResourceMetrics struct {
+	Resource     otlpResource       `json:"resource"`
+	ScopeMetrics []otlpScopeMetrics `json:"scopeMetrics"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeMetrics struct {
+	Scope   otlpScope    `json:"scope"`
+	Metrics []otlpMetric `json:"metrics"`
+}
+
+type otlpScope struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type otlpMetric struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	Sum         *otlpSum       `json:"sum,omitempty"`
+	Gauge       *otlpGauge     `json:"gauge,omitempty"`
+	Histogram   *otlpHistogram `json:"histogram,omitempty"`
+}
+
+type otlpSum struct {
+	DataPoints             []otlpNumberDataPoint `json:"dataPoints"`
+	AggregationTemporality int                   `json:"aggregationTemporality"`
+	IsMonotonic            bool                  `json:"isMonotonic"`
+}
+
+type otlpGauge struct {
+	DataPoints []otlpNumberDataPoint `json:"dataPoints"`
+}
+
+type otlpHistogram struct {
+	DataPoints             []otlpHistogramDataPoint `json:"dataPoints"`
+	AggregationTemporality int                      `json:"aggregationTemporality"`
+}
+
+type otlpNumberDataPoint struct {
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	TimeUnixNano      string          `json:"timeUnixNano"`
+	AsDouble          float64         `json:"asDouble"`
+}
+
+type otlpHistogramDataPoint struct {
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	TimeUnixNano      string          `json:"timeUnixNano"`
+	Count             string          `json:"count"`
+	Sum               float64         `json:"sum"`
+	BucketCounts      []string        `json:"bucketCounts"`
+	ExplicitBounds    []float64       `json:"explicitBounds"`
+}
+
+type otlpAttribute struct {
+	Key   string             `json:"key"`
+	Value otlpAttributeValue `json:"value"`
+}
+
+type otlpAttributeValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+func (e *otlpMetricsExporter) toRequest(timestamp time.Time, metrics []MetricFamily) otlpRequest {
+	startTime := strconv.FormatInt(e.startTime.UnixNano(), 10)
+	now := strconv.FormatInt(timestamp.UnixNano(), 10)
+
+	otlpMetrics := make([]otlpMetric, 0, len(metrics))
+	for _, family := range metrics {
+		metric := otlpMetric{Name: family.Name, Description: family.Help}
+		switch family.Type {
+		case MetricCounter:
+			metric.Sum = &otlpSum{
+				DataPoints:             toOTLPNumberDataPoints(family.Samples, startTime, now),
+				AggregationTemporality: otlpCumulativeTemporality,
+				IsMonotonic:            true,
+			}
+		case MetricGauge:
+			metric.Gauge = &otlpGauge{DataPoints: toOTLPNumberDataPoints(family.Samples, startTime, now)}
+		case MetricHistogram:
+			metric.Histogram = &otlpHistogram{
+				DataPoints:             toOTLPHistogramDataPoints(family.Samples, startTime, now),
+				AggregationTemporality: otlpCumulativeTemporality,
+			}
+		default:
+			continue
+		}
+		otlpMetrics = append(otlpMetrics, metric)
+	}
+
+	return otlpRequest{ResourceMetrics: []otlpResourceMetrics{{
+		Resource: otlpResource{Attributes: []otlpAttribute{
+			{Key: "service.name", Value: otlpAttributeValue{StringValue: "pulsar-client-go"}},
+		}},
+		ScopeMetrics: []otlpScopeMetrics{{
+			Scope:   otlpScope{Name: otlpScopeName, Version: internal.Version},
+			Metrics: otlpMetrics,
+		}},
+	}}}
+}
+
+func toOTLPNumberDataPoints(samples []MetricSample, startTime, now string) []otlpNumberDataPoint {
+	points := make([]otlpNumberDataPoint, 0, len(samples))
+	for _, sample := range samples {
+		points = append(points, otlpNumberDataPoint{
+			Attributes:        toOTLPAttributes(sample.Labels),
+			StartTimeUnixNano: startTime,
+			TimeUnixNano:      now,
+			AsDouble:          sample.Value,
+		})
+	}
+	return points
+}
+
+func toOTLPHistogramDataPoints(samples []MetricSample, startTime, now string) []otlpHistogramDataPoint {
+	points := make([]otlpHistogramDataPoint, 0, len(samples))
+	for _, sample := range samples {
+		h := sample.Histogram
+		if h == nil {
+			continue
+		}
+		// OTLP counts the observations per bucket rather than cumulatively, with a last bucket for the
+		// observations above the last bound
+		bounds := make([]float64, 0, len(h.Buckets))
+		counts := make([]string, 0, len(h.Buckets)+1)
+		var previous uint64
+		for _, b := range h.Buckets {
+			bounds = append(bounds, b.UpperBound)
+			counts = append(counts, strconv.FormatUint(b.Count-previous, 10))
+			previous = b.Count
+		}
+		counts = append(counts, strconv.FormatUint(h.Count-previous, 10))
+		points = append(points, otlpHistogramDataPoint{
+			Attributes:        toOTLPAttributes(sample.Labels),
+			StartTimeUnixNano: startTime,
+			TimeUnixNano:      now,
+			Count:             strconv.FormatUint(h.Count, 10),
+			Sum:               h.Sum,
+			BucketCounts:      counts,
+			ExplicitBounds:    bounds,
+		})
+	}
+	return points
+}
+
+func toOTLPAttributes(labels map[string]string) []otlpAttribute {
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	attributes := make([]otlpAttribute, 0, len(keys))
+	for _, key := range keys {
+		attributes = append(attributes, otlpAttribute{Key: key, Value: otlpAttributeValue{StringValue: labels[key]}})
+	}
+	return attributes
+}
@@ -0,0 +1,232 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsar
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/apache/pulsar-client-go/pulsar/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestMetricsRegistry(t *testing.T) *prometheus.Registry {
+	registry := prometheus.NewRegistry()
+	published := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "pulsar_client_messages_published",
+		Help: "Counter of messages published by the client",
+	}, []string{"topic"})
+	pending := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "pulsar_client_producer_pending_messages",
+		Help: "Counter of messages pending to be published by the client",
+	})
+	latency := prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "pulsar_client_producer_latency_seconds",
+		Help:    "Publish latency experienced by the client",
+		Buckets: []float64{.01, .1, 1},
+	})
+	other := prometheus.NewCounter(prometheus.CounterOpts{Name: "other_counter"})
+	registry.MustRegister(published, pending, latency, other)
+
+	published.WithLabelValues("persistent://public/default/my-topic").Add(3)
+	pending.Set(2)
+	latency.Observe(.005)
+	latency.Observe(.05)
+	latency.Observe(5)
+	other.Inc()
+	return registry
+}
+
+func TestToMetricFamilies(t *testing.T) {
+	families, err := newTestMetricsRegistry(t).Gather()
+	require.NoError(t, err)
+
+	metrics := toMetricFamilies(families)
+	require.Len(t, metrics, 3)
+	byName := make(map[string]MetricFamily)
+	for _, m := range metrics {
+		byName[m.Name] = m
+	}
+
+	published := byName["pulsar_client_messages_published"]
+	assert.Equal(t, MetricCounter, published.Type)
+	assert.Equal(t, "Counter of messages published by the client", published.Help)
+	require.Len(t, published.Samples, 1)
+	assert.Equal(t, map[string]string{"topic": "persistent://public/default/my-topic"}, published.Samples[0].Labels)
+	assert.Equal(t, 3.0, published.Samples[0].Value)
+
+	pending := byName["pulsar_client_producer_pending_messages"]
+	assert.Equal(t, MetricGauge, pending.Type)
+	require.Len(t, pending.Samples, 1)
+	assert.Nil(t, pending.Samples[0].Labels)
+	assert.Equal(t, 2.0, pending.Samples[0].Value)
+
+	latency := byName["pulsar_client_producer_latency_seconds"]
+	assert.Equal(t, MetricHistogram, latency.Type)
+	require.Len(t, latency.Samples, 1)
+	assert.Equal(t, &HistogramSample{
+		Count: 3,
+		Sum:   5.055,
+		Buckets: []HistogramBucket{
+			{UpperBound: .01, Count: 1},
+			{UpperBound: .1, Count: 2},
+			{UpperBound: 1, Count: 2},
+		},
+	}, latency.Samples[0].Histogram)
+}
+
+func TestJSONMetricsExporter(t *testing.T) {
+	var buf bytes.Buffer
+	exporter := NewJSONMetricsExporter(&buf)
+	timestamp := time.Date(2022, 3, 4, 5, 6, 7, 0, time.UTC)
+	metrics := []MetricFamily{{
+		Name:    "pulsar_client_messages_published",
+		Type:    MetricCounter,
+		Samples: []MetricSample{{Labels: map[string]string{"topic": "my-topic"}, Value: 3}},
+	}}
+
+	require.NoError(t, exporter.Export(context.Background(), timestamp, metrics))
+	require.NoError(t, exporter.Export(context.Background(), timestamp, metrics))
+
+	lines := bytes.Split(bytes.TrimSuffix(buf.Bytes(), []byte("\n")), []byte("\n"))
+	require.Len(t, lines, 2)
+	assert.JSONEq(t, `{"time":"2022-03-04T05:06:07Z","metrics":[{"name":"pulsar_client_messages_published",`+
+		`"type":"counter","samples":[{"labels":{"topic":"my-topic"},"value":3}]}]}`, string(lines[0]))
+}
+
+type recordingMetricsExporter struct {
+	sync.Mutex
+	exports [][]MetricFamily
+}
+
+func (e *recordingMetricsExporter) Export(_ context.Context, _ time.Time, metrics []MetricFamily) error {
+	e.Lock()
+	defer e.Unlock()
+	e.exports = append(e.exports, metrics)
+	return nil
+}
+
+func (e *recordingMetricsExporter) count() int {
+	e.Lock()
+	defer e.Unlock()
+	return len(e.exports)
+}
+
+func TestMetricsExport(t *testing.T) {
+	exporter := &recordingMetricsExporter{}
+	export, err := newMetricsExport(ClientOptions{
+		MetricsRegisterer:     newTestMetricsRegistry(t),
+		MetricsExporter:       exporter,
+		MetricsExportInterval: 10 * time.Millisecond,
+	}, log.DefaultNopLogger())
+	require.NoError(t, err)
+
+	assert.Eventually(t, func() bool { return exporter.count() >= 2 }, time.Second, 5*time.Millisecond)
+
+	// the metrics are exported a last time when closed
+	count := exporter.count()
+	export.close()
+	assert.Equal(t, count+1, exporter.count())
+	assert.Len(t, exporter.exports[count], 3)
+	export.close()
+}
+
+func TestMetricsExportOptions(t *testing.T) {
+	export, err := newMetricsExport(ClientOptions{}, log.DefaultNopLogger())
+	assert.NoError(t, err)
+	assert.Nil(t, export)
+	export.close()
+
+	_, err = newMetricsExport(ClientOptions{
+		MetricsRegisterer: prometheus.WrapRegistererWithPrefix("prefix_", prometheus.NewRegistry()),
+		MetricsExporter:   &recordingMetricsExporter{},
+	}, log.DefaultNopLogger())
+	assert.Error(t, err)
+	if pulsarErr, ok := err.(*Error); assert.True(t, ok) {
+		assert.Equal(t, InvalidConfiguration, pulsarErr.Result())
+	}
+}
+
+func TestOTLPMetricsExporter(t *testing.T) {
+	var body map[string]interface{}
+	var header http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		data, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(data, &body); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	families, err := newTestMetricsRegistry(t).Gather()
+	require.NoError(t, err)
+	exporter := NewOTLPMetricsExporter(server.URL+"/v1/metrics", map[string]string{"Authorization": "Bearer token"})
+	timestamp := time.Unix(10, 0)
+	require.NoError(t, exporter.Export(context.Background(), timestamp, toMetricFamilies(families)))
+
+	assert.Equal(t, "application/json", header.Get("Content-Type"))
+	assert.Equal(t, "Bearer token", header.Get("Authorization"))
+
+	scope := body["resourceMetrics"].([]interface{})[0].(map[string]interface{})["scopeMetrics"].([]interface{})[0]
+	metrics := scope.(map[string]interface{})["metrics"].([]interface{})
+	require.Len(t, metrics, 3)
+	byName := make(map[string]map[string]interface{})
+	for _, m := range metrics {
+		metric := m.(map[string]interface{})
+		byName[metric["name"].(string)] = metric
+	}
+
+	sum := byName["pulsar_client_messages_published"]["sum"].(map[string]interface{})
+	assert.Equal(t, true, sum["isMonotonic"])
+	assert.Equal(t, 2.0, sum["aggregationTemporality"])
+	point := sum["dataPoints"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, 3.0, point["asDouble"])
+	assert.Equal(t, "10000000000", point["timeUnixNano"])
+	assert.Equal(t, []interface{}{map[string]interface{}{"key": "topic",
+		"value": map[string]interface{}{"stringValue": "persistent://public/default/my-topic"}}}, point["attributes"])
+
+	gauge := byName["pulsar_client_producer_pending_messages"]["gauge"].(map[string]interface{})
+	assert.Equal(t, 2.0, gauge["dataPoints"].([]interface{})[0].(map[string]interface{})["asDouble"])
+
+	histogram := byName["pulsar_client_producer_latency_seconds"]["histogram"].(map[string]interface{})
+	point = histogram["dataPoints"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, "3", point["count"])
+	assert.Equal(t, []interface{}{.01, .1, 1.0}, point["explicitBounds"])
+	assert.Equal(t, []interface{}{"1", "1", "0", "1"}, point["bucketCounts"])
+}
+
+func TestOTLPMetricsExporterError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	exporter := NewOTLPMetricsExporter(server.URL, nil)
+	err := exporter.Export(context.Background(), time.Now(), nil)
+	assert.ErrorContains(t, err, "503")
+}
@@ -0,0 +1,99 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsar
+
+import (
+	"strings"
+
+	"github.com/apache/pulsar-client-go/pulsar/internal"
+)
+
+// namespaceDefaults holds the default options registered for the namespaces, keyed by "tenant/namespace"
+type namespaceDefaults struct {
+	producers map[string]*ProducerOptions
+	consumers map[string]*ConsumerOptions
+}
+
+func newNamespaceDefaults(producers map[string]*ProducerOptions,
+	consumers map[string]*ConsumerOptions) *namespaceDefaults {
+	if len(producers) == 0 && len(consumers) == 0 {
+		return nil
+	}
+	d := &namespaceDefaults{
+		producers: make(map[string]*ProducerOptions, len(producers)),
+		consumers: make(map[string]*ConsumerOptions, len(consumers)),
+	}
+	for namespace, options := range producers {
+		d.producers[normalizeNamespace(namespace)] = options
+	}
+	for namespace, options := range consumers {
+		d.consumers[normalizeNamespace(namespace)] = options
+	}
+	return d
+}
+
+// normalizeNamespace accepts the namespaces as "tenant/namespace", "tenant/namespace/*" or with the domain,
+// e.g. "persistent://tenant/namespace"
+func normalizeNamespace(namespace string) string {
+	if idx := strings.Index(namespace, "://"); idx >= 0 {
+		namespace = namespace[idx+3:]
+	}
+	return strings.TrimSuffix(strings.TrimSuffix(namespace, "*"), "/")
+}
+
+// namespaceOf returns the namespace of the topics, or an empty string if they are not all in the same namespace
+func namespaceOf(topics ...string) string {
+	namespace := ""
+	for _, topic := range topics {
+		if topic == "" {
+			continue
+		}
+		tn, err := internal.ParseTopicName(topic)
+		if err != nil {
+			return ""
+		}
+		if namespace != "" && namespace != tn.Namespace {
+			return ""
+		}
+		namespace = tn.Namespace
+	}
+	return namespace
+}
+
+// applyProducer sets the fields of the options left to their zero value from the defaults of the namespace
+// of the topic, if any
+func (d *namespaceDefaults) applyProducer(options *ProducerOptions) {
+	if d == nil {
+		return
+	}
+	if defaults, ok := d.producers[namespaceOf(options.Topic)]; ok && defaults != nil {
+		applyDefaultOptions(options, defaults)
+	}
+}
+
+// applyConsumer sets the fields of the options left to their zero value from the defaults of the namespace
+// of the topics, if any. The topics of a multi-topics consumer must all be in the same namespace.
+func (d *namespaceDefaults) applyConsumer(options *ConsumerOptions) {
+	if d == nil {
+		return
+	}
+	topics := append([]string{options.Topic, options.TopicsPattern}, options.Topics...)
+	if defaults, ok := d.consumers[namespaceOf(topics...)]; ok && defaults != nil {
+		applyDefaultOptions(options, defaults)
+	}
+}
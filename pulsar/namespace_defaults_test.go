@@ -0,0 +1,77 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsar
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNamespaceOf(t *testing.T) {
+	assert.Equal(t, "public/default", namespaceOf("my-topic"))
+	assert.Equal(t, "tenant/bulk", namespaceOf("persistent://tenant/bulk/t1", "tenant/bulk/t2", ""))
+	assert.Equal(t, "tenant/orders", namespaceOf("persistent://tenant/orders/orders-.*"))
+	assert.Equal(t, "", namespaceOf("tenant/bulk/t1", "tenant/orders/t2"))
+	assert.Equal(t, "", namespaceOf("invalid/topic"))
+}
+
+func TestNamespaceDefaults(t *testing.T) {
+	d := newNamespaceDefaults(map[string]*ProducerOptions{
+		"tenant/bulk/*": {CompressionType: ZSTD, SendTimeout: time.Minute},
+	}, map[string]*ConsumerOptions{
+		"persistent://tenant/orders": {DLQ: &DLQPolicy{MaxDeliveries: 3}, Type: Shared},
+	})
+
+	producer := ProducerOptions{Topic: "tenant/bulk/events", SendTimeout: time.Second}
+	d.applyProducer(&producer)
+	assert.Equal(t, ZSTD, producer.CompressionType)
+	assert.Equal(t, time.Second, producer.SendTimeout)
+
+	other := ProducerOptions{Topic: "tenant/orders/events"}
+	d.applyProducer(&other)
+	assert.Equal(t, NoCompression, other.CompressionType)
+
+	consumer := ConsumerOptions{Topics: []string{"tenant/orders/a", "tenant/orders/b"}, Type: Failover}
+	d.applyConsumer(&consumer)
+	assert.Equal(t, uint32(3), consumer.DLQ.MaxDeliveries)
+	assert.Equal(t, Failover, consumer.Type)
+
+	assert.Nil(t, newNamespaceDefaults(nil, nil))
+	var none *namespaceDefaults
+	none.applyProducer(&other)
+	none.applyConsumer(&consumer)
+}
+
+func TestParseClientConfigNamespaces(t *testing.T) {
+	options, err := parseClientConfig([]byte(`
+url: pulsar://localhost:6650
+namespaces:
+  tenant/bulk:
+    producer:
+      compressionType: lz4
+  tenant/orders:
+    consumer:
+      type: shared
+`))
+	assert.Nil(t, err)
+	assert.Equal(t, LZ4, options.NamespaceProducerOptions["tenant/bulk"].CompressionType)
+	assert.Equal(t, Shared, options.NamespaceConsumerOptions["tenant/orders"].Type)
+	assert.Nil(t, options.NamespaceConsumerOptions["tenant/bulk"])
+}
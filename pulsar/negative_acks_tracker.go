@@ -24,6 +24,9 @@ import (
 	log "github.com/apache/pulsar-client-go/pulsar/log"
 )
 
+// minNackTick bounds the interval the redeliveries are checked at, which is a third of the delay
+const minNackTick = 10 * time.Millisecond
+
 type redeliveryConsumer interface {
 	Redeliver(msgIds []messageID)
 }
@@ -59,12 +62,32 @@ func newNegativeAcksTracker(rc redeliveryConsumer, delay time.Duration,
 		t.delay = delay
 	}
 
-	t.tick = time.NewTicker(t.delay / 3)
+	t.tick = time.NewTicker(nackTick(t.delay))
 
 	go t.track()
 	return t
 }
 
+// setDelay changes the redelivery delay of the messages negatively acknowledged afterwards,
+// it has no effect when a NackBackoffPolicy is used
+func (t *negativeAcksTracker) setDelay(delay time.Duration) {
+	t.Lock()
+	defer t.Unlock()
+
+	if t.nackBackoff != nil || t.delay == delay {
+		return
+	}
+	t.delay = delay
+	t.tick.Reset(nackTick(delay))
+}
+
+func nackTick(delay time.Duration) time.Duration {
+	if tick := delay / 3; tick > minNackTick {
+		return tick
+	}
+	return minNackTick
+}
+
 func (t *negativeAcksTracker) Add(msgID *messageID) {
 	// Always clear up the batch index since we want to track the nack
 	// for the entire batch
@@ -110,6 +110,30 @@ func TestNacksTracker(t *testing.T) {
 	nacks.Close()
 }
 
+func TestNacksTrackerSetDelay(t *testing.T) {
+	nmc := newNackMockedConsumer(nil)
+	nacks := newNegativeAcksTracker(nmc, time.Minute, nil, log.DefaultNopLogger())
+	defer nacks.Close()
+
+	// the messages negatively acknowledged after the change use the new delay
+	nacks.setDelay(testNackDelay)
+	nacks.Add(&messageID{
+		ledgerID: 1,
+		entryID:  1,
+	})
+
+	msgIds := make([]messageID, 0)
+	for id := range nmc.Wait() {
+		msgIds = append(msgIds, id)
+	}
+	assert.Equal(t, 1, len(msgIds))
+
+	// a delay shorter than 3ns must not reset the ticker to 0, which panics
+	nacks.setDelay(time.Nanosecond)
+	assert.Equal(t, minNackTick, nackTick(time.Nanosecond))
+	assert.Equal(t, time.Second, nackTick(3*time.Second))
+}
+
 func TestNacksWithBatchesTracker(t *testing.T) {
 	nmc := newNackMockedConsumer(nil)
 	nacks := newNegativeAcksTracker(nmc, testNackDelay, nil, log.DefaultNopLogger())
@@ -253,6 +277,14 @@ func (msg *mockMessage1) BrokerPublishTime() *time.Time {
 	return nil
 }
 
+func (msg *mockMessage1) KafkaTimestampType() KafkaTimestampType {
+	return NoKafkaTimestampType
+}
+
+func (msg *mockMessage1) DeliverAtTime() time.Time {
+	return time.Time{}
+}
+
 type mockMessage2 struct {
 	properties map[string]string
 }
@@ -328,3 +360,11 @@ func (msg *mockMessage2) Index() *uint64 {
 func (msg *mockMessage2) BrokerPublishTime() *time.Time {
 	return nil
 }
+
+func (msg *mockMessage2) KafkaTimestampType() KafkaTimestampType {
+	return NoKafkaTimestampType
+}
+
+func (msg *mockMessage2) DeliverAtTime() time.Time {
+	return time.Time{}
+}
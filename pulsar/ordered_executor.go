@@ -0,0 +1,111 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsar
+
+import (
+	"sync"
+
+	"github.com/apache/pulsar-client-go/pulsar/internal"
+)
+
+const defaultCallbackQueueSize = 1000
+
+// orderedExecutor invokes the callbacks with a fixed number of goroutines, each one with its own bounded queue.
+// The callbacks with the same key, i.e. of the same partition, are queued to the same goroutine so they are invoked
+// in order and never interleave, while the different partitions are spread over the goroutines.
+// A nil orderedExecutor invokes the callbacks in place.
+type orderedExecutor struct {
+	queues  []chan func()
+	closeCh chan struct{}
+
+	// lock prevents the queues from being closed while a callback is queued
+	lock      sync.RWMutex
+	closed    bool
+	closeOnce sync.Once
+}
+
+// newOrderedExecutor returns an executor with the given number of goroutines, or nil when threads <= 0
+func newOrderedExecutor(threads int, queueSize int) *orderedExecutor {
+	if threads <= 0 {
+		return nil
+	}
+	if queueSize <= 0 {
+		queueSize = defaultCallbackQueueSize
+	}
+
+	e := &orderedExecutor{
+		queues:  make([]chan func(), threads),
+		closeCh: make(chan struct{}),
+	}
+	for i := range e.queues {
+		e.queues[i] = make(chan func(), queueSize)
+		go e.run(e.queues[i])
+	}
+	return e
+}
+
+func (e *orderedExecutor) run(queue chan func()) {
+	for task := range queue {
+		task()
+	}
+}
+
+// execute queues the task behind the other tasks with the same key, blocking while the queue is full.
+// The task is invoked in place once the executor is closed.
+func (e *orderedExecutor) execute(key string, task func()) {
+	if e == nil || !e.enqueue(key, task) {
+		task()
+	}
+}
+
+func (e *orderedExecutor) enqueue(key string, task func()) bool {
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+	if e.closed {
+		return false
+	}
+
+	select {
+	case e.queueOf(key) <- task:
+		return true
+	case <-e.closeCh:
+		return false
+	}
+}
+
+func (e *orderedExecutor) queueOf(key string) chan func() {
+	return e.queues[internal.Murmur3_32Hash(key)%uint32(len(e.queues))]
+}
+
+// close stops the goroutines once they have invoked the queued tasks
+func (e *orderedExecutor) close() {
+	if e == nil {
+		return
+	}
+	e.closeOnce.Do(func() {
+		// unblocks the tasks waiting for room in a queue
+		close(e.closeCh)
+
+		e.lock.Lock()
+		defer e.lock.Unlock()
+		e.closed = true
+		for _, queue := range e.queues {
+			close(queue)
+		}
+	})
+}
@@ -0,0 +1,95 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsar
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOrderedExecutorKeepsOrderPerKey(t *testing.T) {
+	e := newOrderedExecutor(4, 10)
+	defer e.close()
+
+	var lock sync.Mutex
+	invoked := make(map[string][]int)
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("topic-partition-%d", i%5)
+		i := i
+		wg.Add(1)
+		e.execute(key, func() {
+			defer wg.Done()
+			lock.Lock()
+			invoked[key] = append(invoked[key], i)
+			lock.Unlock()
+		})
+	}
+	wg.Wait()
+
+	for key, values := range invoked {
+		assert.Len(t, values, 20, key)
+		for j := 1; j < len(values); j++ {
+			assert.Less(t, values[j-1], values[j], key)
+		}
+	}
+}
+
+func TestOrderedExecutorRunsKeysConcurrently(t *testing.T) {
+	e := newOrderedExecutor(2, 10)
+	defer e.close()
+
+	// find two keys handled by different goroutines
+	keys := []string{"topic-partition-0"}
+	for i := 1; len(keys) < 2; i++ {
+		key := fmt.Sprintf("topic-partition-%d", i)
+		if e.queueOf(key) != e.queueOf(keys[0]) {
+			keys = append(keys, key)
+		}
+	}
+
+	blocked := make(chan struct{})
+	e.execute(keys[0], func() { <-blocked })
+	defer close(blocked)
+
+	done := make(chan struct{})
+	e.execute(keys[1], func() { close(done) })
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("the callback was held back by another partition")
+	}
+}
+
+func TestOrderedExecutorInPlace(t *testing.T) {
+	var e *orderedExecutor
+	invoked := false
+	e.execute("topic", func() { invoked = true })
+	assert.True(t, invoked)
+	e.close()
+
+	e = newOrderedExecutor(1, 1)
+	e.close()
+	invoked = false
+	e.execute("topic", func() { invoked = true })
+	assert.True(t, invoked)
+}
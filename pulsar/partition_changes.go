@@ -0,0 +1,80 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsar
+
+import (
+	"time"
+)
+
+// PartitionsChangedEvent describes a change of the number of partitions a producer routes its messages to
+type PartitionsChangedEvent struct {
+	// Topic is the partitioned topic
+	Topic string
+
+	// OldPartitions and NewPartitions are the numbers of partitions before and after the change
+	OldPartitions int
+	NewPartitions int
+
+	// Added are the partitions whose producers were created, empty when the partitions were removed
+	Added []string
+
+	// DetectedAt is when the partitioned metadata reported the new number of partitions
+	DetectedAt time.Time
+}
+
+// PartitionsChangedListener is notified when a producer routes its messages to a new number of partitions, e.g. to
+// rehash a state aggregated per partition. Set it as ProducerOptions.PartitionsChangedListener.
+type PartitionsChangedListener interface {
+	PartitionsChanged(event PartitionsChangedEvent)
+}
+
+// PartitionsRemovedEvent describes the partitions a producer or consumer stopped using because the partitioned
+// metadata of the topic reports fewer partitions than known, e.g. when the topic was deleted and recreated with
+// fewer partitions.
+type PartitionsRemovedEvent struct {
+	// Topic is the partitioned topic
+	Topic string
+
+	// OldPartitions and NewPartitions are the numbers of partitions before and after the change
+	OldPartitions int
+	NewPartitions int
+
+	// Removed are the partitions whose producers or consumers were closed
+	Removed []string
+
+	// DetectedAt is when the partitioned metadata reported fewer partitions
+	DetectedAt time.Time
+}
+
+// PartitionsRemovedListener is notified when a producer or consumer closes the producers or consumers of the
+// partitions removed from its topic. Set it as ProducerOptions.PartitionsRemovedListener, or implement it with the
+// ConsumerOptions.EventListener.
+type PartitionsRemovedListener interface {
+	PartitionsRemoved(event PartitionsRemovedEvent)
+}
+
+// partitionsToReuse returns whether each of the current partition producers or consumers, named by their
+// topics, is reused for the partition at the same index. The ones not reused are closed, they serve a removed
+// partition or a topic recreated with another name, e.g. a partitioned topic recreated as non-partitioned.
+func partitionsToReuse(current []string, partitions []string) []bool {
+	reuse := make([]bool, len(current))
+	for i, topic := range current {
+		reuse[i] = i < len(partitions) && topic == partitions[i]
+	}
+	return reuse
+}
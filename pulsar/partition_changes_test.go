@@ -0,0 +1,75 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsar
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPartitionsToReuse(t *testing.T) {
+	topic := "persistent://public/default/my-topic"
+	partitions := func(n int) []string {
+		names := make([]string, n)
+		for i := range names {
+			names[i] = topic + "-partition-" + string(rune('0'+i))
+		}
+		return names
+	}
+
+	// no current partitions
+	assert.Empty(t, partitionsToReuse(nil, partitions(3)))
+
+	// partitions added: all the current ones are reused
+	assert.Equal(t, []bool{true, true}, partitionsToReuse(partitions(2), partitions(3)))
+
+	// partitions removed: the ones past the new count are closed
+	assert.Equal(t, []bool{true, true, false, false}, partitionsToReuse(partitions(4), partitions(2)))
+
+	// recreated as non-partitioned
+	assert.Equal(t, []bool{false, false}, partitionsToReuse(partitions(2), []string{topic}))
+}
+
+type partitionsChangedRecorder struct {
+	events []PartitionsChangedEvent
+}
+
+func (r *partitionsChangedRecorder) PartitionsChanged(event PartitionsChangedEvent) {
+	r.events = append(r.events, event)
+}
+
+func TestProducerPartitionsChanged(t *testing.T) {
+	topic := "persistent://public/default/my-topic"
+	p := &producer{topic: topic, options: &ProducerOptions{}}
+	// no listener
+	p.partitionsChanged(1, 2, []string{topic + "-partition-1"}, time.Now())
+
+	r := &partitionsChangedRecorder{}
+	p.options.PartitionsChangedListener = r
+	detectedAt := time.Now()
+	p.partitionsChanged(1, 3, []string{topic + "-partition-1", topic + "-partition-2"}, detectedAt)
+	assert.Equal(t, []PartitionsChangedEvent{{
+		Topic:         topic,
+		OldPartitions: 1,
+		NewPartitions: 3,
+		Added:         []string{topic + "-partition-1", topic + "-partition-2"},
+		DetectedAt:    detectedAt,
+	}}, r.events)
+}
@@ -40,6 +40,10 @@ const (
 	LZ4
 	ZLib
 	ZSTD
+	Snappy
+	// LZ4Frame compresses with LZ4 in the standard LZ4 frame format instead of the LZ4 block format, the messages
+	// are sent with the LZ4 compression type
+	LZ4Frame
 )
 
 type CompressionLevel int
@@ -120,9 +124,16 @@ type ProducerOptions struct {
 	//  - LZ4
 	//  - ZLIB
 	//  - ZSTD
+	//  - Snappy
+	//  - LZ4Frame
 	//
 	// Note: ZSTD is supported since Pulsar 2.3. Consumers will need to be at least at that
 	// release in order to be able to receive messages compressed with ZSTD.
+	//
+	// Snappy requires a broker speaking at least protocol version 14, creating the producer fails with
+	// UnsupportedVersionError otherwise. Consumers decompress both the LZ4 block format of the Pulsar clients and
+	// the standard LZ4 frame format, e.g. of the messages produced through protocol handlers. LZ4Frame produces
+	// messages in the frame format, which the consumers of the clients only decoding LZ4 blocks can't read.
 	CompressionType
 
 	// CompressionLevel defines the desired compression level. Options:
@@ -136,6 +147,13 @@ type ProducerOptions struct {
 	// partition index where the message should be routed to
 	MessageRouter func(*ProducerMessage, TopicMetadata) int
 
+	// PartitionSwitchFrequency enables the sticky partition routing of the messages without key: they are all
+	// routed to the same partition for PartitionSwitchFrequency batching intervals (BatchingMaxPublishDelay)
+	// before switching to the next partition, see NewStickyPartitionRouter. It improves the batching compared to the
+	// default router, which switches whenever a batch is full, while spreading the messages evenly in the long run.
+	// It is ignored when batching is disabled or a MessageRouter is set. Default is 0, disabled.
+	PartitionSwitchFrequency int
+
 	// DisableBatching controls whether automatic batching of messages is enabled for the producer. By default batching
 	// is enabled.
 	// When batching is enabled, multiple calls to Producer.sendAsync can result in a single batch to be sent to the
@@ -151,6 +169,19 @@ type ProducerOptions struct {
 	// interval or until
 	BatchingMaxPublishDelay time.Duration
 
+	// AdaptiveBatching tunes the publish delay of the batches between a minimum and a maximum from the arrival rate
+	// of the messages, instead of always waiting BatchingMaxPublishDelay: the batches are published as soon as they
+	// are expected to be filled up to a target ratio, or after the minimum delay when the messages arrive too slowly
+	// to fill them anyway. It favors the latency at low rates and the throughput at high rates without tuning the
+	// delay per service. Default is nil, the publish delay is BatchingMaxPublishDelay.
+	AdaptiveBatching *AdaptiveBatchingOptions
+
+	// MaxUnflushedDuration bounds the time the messages of a batch wait to be sent while the producer is blocked
+	// because the pending queue or the memory limit is full (default: BatchingMaxPublishDelay). A longer duration
+	// lets the batches fill up under backpressure, the messages of a batch hold room in the pending queue until
+	// they are sent.
+	MaxUnflushedDuration time.Duration
+
 	// BatchingMaxMessages specifies the maximum number of messages permitted in a batch. (default: 1000)
 	// If set to a value greater than 1, messages will be queued until this threshold is reached or
 	// BatchingMaxSize (see below) has been reached or the batch interval has elapsed.
@@ -165,6 +196,12 @@ type ProducerOptions struct {
 	// in ProducerInterceptor interface
 	Interceptors ProducerInterceptors
 
+	// PayloadTransformers is a chain of stages transforming the payload of each message, after the encoding with
+	// the schema and before the batching, compression and encryption of the producer. A message failing a stage is
+	// not sent, its callback receives the error. The time spent in each stage is exported by the
+	// pulsar_client_producer_payload_transform_seconds metric. The consumers receive the transformed payloads.
+	PayloadTransformers []PayloadTransformer
+
 	// Schema represents the schema implementation.
 	Schema Schema
 
@@ -182,14 +219,39 @@ type ProducerOptions struct {
 	// - KeyBasedBatchBuilder
 	BatcherBuilderType
 
+	// BatchingMaxKeys bounds the number of keys with an open batch in the KeyBasedBatchBuilder (default: 1000).
+	// A message with a new key beyond the bound flushes the batch selected by BatchingKeyEviction rather than
+	// blocking, so high-cardinality keys don't exhaust memory. A negative value removes the bound.
+	BatchingMaxKeys int
+
+	// BatchingKeyEviction selects the key batch flushed when BatchingMaxKeys is reached
+	// (default: EvictLargestKeyBatch)
+	BatchingKeyEviction KeyBatchEviction
+
 	// PartitionsAutoDiscoveryInterval is the time interval for the background process to discover new partitions
 	// Default is 1 minute
 	PartitionsAutoDiscoveryInterval time.Duration
 
+	// PartitionsRemovedListener is notified when the producer closes the producers of the partitions removed from
+	// the topic, which happens when the partitioned metadata reports fewer partitions than known, e.g. when the
+	// topic was recreated with fewer partitions.
+	PartitionsRemovedListener PartitionsRemovedListener
+
+	// PartitionsChangedListener is notified when the producer detects a new number of partitions of the topic,
+	// once its messages are routed to the new partitions.
+	PartitionsChangedListener PartitionsChangedListener
+
 	// Disable multiple Schame Version
 	// Default false
 	DisableMultiSchema bool
 
+	// SchemaVersion pins the producer to a registered version of its Schema, e.g. created with NewSchemaVersion.
+	// The version is checked to hold the definition of the Schema when the producer is created, and it is stamped
+	// on the messages sent with the Schema whatever the version the broker returns on reconnects, so that rolling
+	// back a schema change doesn't silently switch the producer to another version.
+	// Default is nil, the version returned by the broker is used.
+	SchemaVersion []byte
+
 	// Encryption specifies the fields required to encrypt a message
 	Encryption *ProducerEncryptionInfo
 
@@ -202,11 +264,43 @@ type ProducerOptions struct {
 	// It will actually only take effect if it is smaller than the maxMessageSize from the broker.
 	ChunkMaxMessageSize uint
 
+	// MaxInflightPerKey, if positive, makes the order of the messages of each key on the broker match the order of
+	// the Send and SendAsync calls, also when the sends fail and are retried by the application without
+	// deduplication. The sends of a key, its OrderingKey or else its Key, are chained: at most MaxInflightPerKey of
+	// them are handed to the partition producers at a time, the next ones waiting for them to complete, while the
+	// sends of different keys proceed in parallel. When a send fails, the sends of the key still waiting fail with
+	// an error wrapping ErrKeyOrderingBroken, and a send whose context is done while it waits fails with the error
+	// of the context. The order is only guaranteed across failures with 1, a send being attempted once the previous
+	// send of the key succeeded: with more, the sends already handed to the partition producers when a send fails
+	// are not stopped and may be persisted after it. Default is 0, the messages are handed to the partition
+	// producers right away.
+	MaxInflightPerKey int
+
 	// The type of access to the topic that the producer requires. (default ProducerAccessModeShared)
 	// Options:
 	// - ProducerAccessModeShared
 	// - ProducerAccessModeExclusive
 	ProducerAccessMode
+
+	// WarmUpRoundTrip makes Producer.WarmUp also send a no-op request to the broker owning each partition, an
+	// authoritative lookup that the broker answers itself, so the broker-side ownership check and the request path
+	// of the connection are exercised before the first message is sent.
+	WarmUpRoundTrip bool
+
+	// Authentication, if set, is the authentication of the producer instead of the authentication of the client,
+	// e.g. for a gateway publishing on behalf of several tenants. The producer uses separate connections to the
+	// brokers, shared with the producers and consumers with the same Authentication instance, while the lookups
+	// are done with the authentication of the client. The provider is initialized on first use.
+	Authentication Authentication
+
+	// ExplicitZeroFields lists the names of the fields, e.g. "CompressionType" or "DisableBatching", left to their
+	// zero value on purpose, such as NoCompression or false, which are not set from the default producer options
+	// of the client, see ClientOptions.DefaultProducerOptions and ClientOptions.NamespaceProducerOptions.
+	ExplicitZeroFields []string
+
+	// initialSubscriptionName is the name of a subscription created along with the topic, see
+	// DLQPolicy.InitialSubscriptionName
+	initialSubscriptionName string
 }
 
 // Producer is used to publish messages on a topic
@@ -230,6 +324,12 @@ type Producer interface {
 	// the eventual error in publishing
 	SendAsync(context.Context, *ProducerMessage, func(MessageID, *ProducerMessage, error))
 
+	// Republish sends a message consumed from another topic, preserving its identity: the payload, key,
+	// ordering key, event time, properties and schema are copied from the consumed message.
+	// The optional transform is called on the copy before it is sent, e.g. to add properties.
+	// This call will be blocking until is successfully acknowledged by the Pulsar broker.
+	Republish(ctx context.Context, msg Message, transform func(*ProducerMessage)) (MessageID, error)
+
 	// LastSequenceID get the last sequence id that was published by this producer.
 	// This represent either the automatically assigned or custom sequence id (set on the ProducerMessage) that
 	// was published and acknowledged by the broker.
@@ -242,6 +342,32 @@ type Producer interface {
 	// persisted.
 	Flush() error
 
+	// NumPartitions returns the number of partitions of the topic, 1 for a non-partitioned topic.
+	NumPartitions() uint32
+
+	// PartitionFor returns the index of the partition the message would be routed to, without sending it.
+	// With the default router a message with a key or an ordering key is routed by the hash of the key, which is
+	// stable for as long as the number of partitions doesn't change, and -1 is returned for a message without key
+	// since those are spread across the partitions. A custom MessageRouter is called as it would be for a send.
+	PartitionFor(msg *ProducerMessage) int
+
+	// WarmUp looks up the broker owning each partition of the topic and establishes the connections to them
+	// ahead of time, and with ProducerOptions.WarmUpRoundTrip also sends a no-op request to each of them, so the
+	// first message sent after a deployment doesn't pay the cold start. It returns the first error encountered, or
+	// the context error when the context is done before the warm-up completes.
+	WarmUp(ctx context.Context) error
+
+	// Pending returns a summary of the messages sent by this producer and not acknowledged by the broker yet,
+	// oldest first for each partition. The messages still buffered in the current batch are not included.
+	Pending() []PendingMessage
+
+	// CancelPending fails the messages not acknowledged by the broker yet, including the ones of the current batch,
+	// immediately with ErrSendCanceled instead of waiting out the send timeout, e.g. during an emergency shutdown.
+	// It returns the context error when the context is done before the messages are canceled, including while the
+	// producer is too busy to take the request. The messages already written to the connection may still be
+	// persisted by the broker after they are canceled.
+	CancelPending(ctx context.Context) error
+
 	// Close the producer and releases resources allocated
 	// No more writes will be accepted from this producer. Waits until all pending write request are persisted. In case
 	// of errors, pending writes will not be retried.
@@ -0,0 +1,155 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsar
+
+import (
+	"math"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultAdaptiveBatchingMinPublishDelay = time.Millisecond
+	defaultAdaptiveBatchingTargetFillRatio = 0.5
+
+	// adaptiveBatchingRateWeight is the weight of the last arrival rate observed in the rate estimated
+	adaptiveBatchingRateWeight = 0.3
+)
+
+// AdaptiveBatchingOptions tunes the publish delay of the batches between MinPublishDelay and MaxPublishDelay from
+// the arrival rate of the messages, see ProducerOptions.AdaptiveBatching
+type AdaptiveBatchingOptions struct {
+	// MinPublishDelay is the shortest publish delay, used when the messages arrive too slowly to fill the batches up
+	// to TargetFillRatio within MaxPublishDelay, since waiting for more messages would only delay them.
+	// Default is 1ms.
+	MinPublishDelay time.Duration
+
+	// MaxPublishDelay is the longest publish delay. Default is BatchingMaxPublishDelay.
+	MaxPublishDelay time.Duration
+
+	// TargetFillRatio is the fraction of BatchingMaxMessages, or of BatchingMaxSize, the batches are expected to fill
+	// up to before their publish delay expires. Default is 0.5.
+	TargetFillRatio float64
+}
+
+// adaptiveBatchDelay estimates the arrival rate of the messages of a producer to tune its batch publish delay. It's
+// owned by the events loop of the producer, except the current delay which is read atomically.
+type adaptiveBatchDelay struct {
+	minDelay       time.Duration
+	maxDelay       time.Duration
+	targetMessages float64
+	targetBytes    float64
+
+	// messages and bytes are the messages added since windowStart
+	messages    int
+	bytes       int
+	windowStart time.Time
+
+	// messageRate and byteRate are the arrival rates estimated, per second
+	messageRate float64
+	byteRate    float64
+
+	delay int64
+}
+
+// newAdaptiveBatchDelay returns the delay of a producer estimating the arrival rate of the messages from start
+func newAdaptiveBatchDelay(options *AdaptiveBatchingOptions, maxPublishDelay time.Duration, maxMessages,
+	maxSize uint, start time.Time) *adaptiveBatchDelay {
+	minDelay := options.MinPublishDelay
+	if minDelay <= 0 {
+		minDelay = defaultAdaptiveBatchingMinPublishDelay
+	}
+	maxDelay := options.MaxPublishDelay
+	if maxDelay <= 0 {
+		maxDelay = maxPublishDelay
+	}
+	if maxDelay < minDelay {
+		maxDelay = minDelay
+	}
+	ratio := options.TargetFillRatio
+	if ratio <= 0 || ratio > 1 {
+		ratio = defaultAdaptiveBatchingTargetFillRatio
+	}
+	return &adaptiveBatchDelay{
+		minDelay:       minDelay,
+		maxDelay:       maxDelay,
+		targetMessages: ratio * float64(maxMessages),
+		targetBytes:    ratio * float64(maxSize),
+		windowStart:    start,
+		delay:          int64(minDelay),
+	}
+}
+
+// add records a message added to a batch
+func (d *adaptiveBatchDelay) add(size int) {
+	d.messages++
+	d.bytes += size
+}
+
+// next returns the publish delay of a batch starting at the given time, from the arrival rate of the messages
+// since the previous batch started
+func (d *adaptiveBatchDelay) next(now time.Time) time.Duration {
+	if elapsed := now.Sub(d.windowStart).Seconds(); elapsed > 0 {
+		d.messageRate = ewma(d.messageRate, float64(d.messages)/elapsed)
+		d.byteRate = ewma(d.byteRate, float64(d.bytes)/elapsed)
+		d.messages, d.bytes = 0, 0
+		d.windowStart = now
+	}
+
+	// the time to fill the batch up to the target, whichever of the messages and the bytes limits comes first
+	fill := math.Inf(1)
+	if d.messageRate > 0 {
+		fill = math.Min(fill, d.targetMessages/d.messageRate)
+	}
+	if d.byteRate > 0 {
+		fill = math.Min(fill, d.targetBytes/d.byteRate)
+	}
+
+	var delay time.Duration
+	switch {
+	case fill > d.maxDelay.Seconds():
+		// the batches won't reach the target anyway, favor the latency
+		delay = d.minDelay
+	case fill < d.minDelay.Seconds():
+		delay = d.minDelay
+	default:
+		delay = time.Duration(fill * float64(time.Second))
+	}
+	atomic.StoreInt64(&d.delay, int64(delay))
+	return delay
+}
+
+// current returns the last publish delay, it's safe to call from any goroutine
+func (d *adaptiveBatchDelay) current() time.Duration {
+	return time.Duration(atomic.LoadInt64(&d.delay))
+}
+
+func ewma(estimate, observed float64) float64 {
+	if estimate == 0 {
+		return observed
+	}
+	return adaptiveBatchingRateWeight*observed + (1-adaptiveBatchingRateWeight)*estimate
+}
+
+// nextBatchDelay returns the publish delay of a batch starting now
+func (p *partitionProducer) nextBatchDelay(now time.Time) time.Duration {
+	if p.adaptiveDelay == nil {
+		return p.batchingMaxPublishDelay
+	}
+	return p.adaptiveDelay.next(now)
+}
@@ -0,0 +1,125 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsar
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// simulateArrivals adds the messages arriving at the given rate during a second, starting a batch every interval,
+// and returns the last publish delay
+func simulateArrivals(d *adaptiveBatchDelay, start time.Time, rate, size int,
+	interval time.Duration) time.Duration {
+	var delay time.Duration
+	perBatch := int(float64(rate) * interval.Seconds())
+	for now := start.Add(interval); now.Sub(start) <= time.Second; now = now.Add(interval) {
+		for i := 0; i < perBatch; i++ {
+			d.add(size)
+		}
+		delay = d.next(now)
+	}
+	return delay
+}
+
+func TestAdaptiveBatchDelay(t *testing.T) {
+	options := &AdaptiveBatchingOptions{
+		MinPublishDelay: time.Millisecond,
+		MaxPublishDelay: 100 * time.Millisecond,
+		TargetFillRatio: 0.5,
+	}
+	newDelay := func() *adaptiveBatchDelay {
+		return newAdaptiveBatchDelay(options, 10*time.Millisecond, 100, 128*1024, time.Unix(0, 0))
+	}
+
+	// 50 messages arrive within 50ms
+	d := newDelay()
+	delay := simulateArrivals(d, d.windowStart, 1000, 10, 10*time.Millisecond)
+	assert.InDelta(t, 50*time.Millisecond, delay, float64(time.Millisecond))
+	assert.Equal(t, delay, d.current())
+
+	// the batches can't be filled within the maximum delay
+	d = newDelay()
+	delay = simulateArrivals(d, d.windowStart, 10, 10, 100*time.Millisecond)
+	assert.Equal(t, time.Millisecond, delay)
+
+	// the batches are filled before the minimum delay
+	d = newDelay()
+	delay = simulateArrivals(d, d.windowStart, 200000, 10, time.Millisecond)
+	assert.Equal(t, time.Millisecond, delay)
+
+	// 64KB arrive within 6.4ms, before 50 messages
+	d = newDelay()
+	delay = simulateArrivals(d, d.windowStart, 1000, 10*1024, 10*time.Millisecond)
+	assert.InDelta(t, 6400*time.Microsecond, delay, float64(100*time.Microsecond))
+}
+
+func TestAdaptiveBatchDelayRateChange(t *testing.T) {
+	start := time.Unix(0, 0)
+	d := newAdaptiveBatchDelay(&AdaptiveBatchingOptions{MaxPublishDelay: 100 * time.Millisecond}, 0, 100, 1<<20, start)
+
+	assert.InDelta(t, 50*time.Millisecond, simulateArrivals(d, start, 1000, 10, 10*time.Millisecond),
+		float64(time.Millisecond))
+	// the delay follows the rate once it doubles
+	assert.InDelta(t, 25*time.Millisecond, simulateArrivals(d, start.Add(time.Second), 2000, 10, 10*time.Millisecond),
+		float64(time.Millisecond))
+}
+
+func TestAdaptiveBatchDelayDefaults(t *testing.T) {
+	d := newAdaptiveBatchDelay(&AdaptiveBatchingOptions{}, 10*time.Millisecond, 1000, 128*1024, time.Now())
+	assert.Equal(t, defaultAdaptiveBatchingMinPublishDelay, d.minDelay)
+	assert.Equal(t, 10*time.Millisecond, d.maxDelay)
+	assert.Equal(t, 500.0, d.targetMessages)
+	assert.Equal(t, defaultAdaptiveBatchingMinPublishDelay, d.current())
+
+	d = newAdaptiveBatchDelay(&AdaptiveBatchingOptions{MinPublishDelay: time.Second, TargetFillRatio: 2},
+		10*time.Millisecond, 1000, 128*1024, time.Now())
+	assert.Equal(t, time.Second, d.maxDelay)
+	assert.Equal(t, 500.0, d.targetMessages)
+}
+
+func TestProducerNextBatchDelay(t *testing.T) {
+	p := &partitionProducer{batchingMaxPublishDelay: 10 * time.Millisecond}
+	assert.Equal(t, 10*time.Millisecond, p.nextBatchDelay(time.Now()))
+
+	// 500 messages fill the batches up to the target
+	start := time.Unix(0, 0)
+	for _, tc := range []struct {
+		name     string
+		messages int
+		elapsed  time.Duration
+		delay    time.Duration
+	}{
+		// the batches can't be filled within the maximum delay
+		{"slow", 10, time.Second, time.Millisecond},
+		{"filled within the maximum delay", 100, time.Millisecond, 5 * time.Millisecond},
+		// the batches are filled before the minimum delay
+		{"fast", 1000, time.Millisecond, time.Millisecond},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			p.adaptiveDelay = newAdaptiveBatchDelay(&AdaptiveBatchingOptions{}, p.batchingMaxPublishDelay, 1000,
+				128*1024, start)
+			for i := 0; i < tc.messages; i++ {
+				p.adaptiveDelay.add(10)
+			}
+			assert.Equal(t, tc.delay, p.nextBatchDelay(start.Add(tc.elapsed)))
+		})
+	}
+}
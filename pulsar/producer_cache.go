@@ -0,0 +1,174 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsar
+
+import (
+	"sync"
+	"time"
+)
+
+const defaultProducerCacheIdleTime = time.Minute
+
+// producerCache shares the producers of the topics between the callers of Client.ProducerFor. A producer is
+// closed when it was released by all the callers and not used again during the idle time.
+type producerCache struct {
+	sync.Mutex
+	entries  map[string]*producerCacheEntry
+	idleTime time.Duration
+	create   func(topic string) (Producer, error)
+	closed   bool
+}
+
+type producerCacheEntry struct {
+	// closed when the producer is created
+	ready    chan struct{}
+	producer Producer
+	err      error
+
+	refs int
+	// closes the producer when it is not used during the idle time
+	evictTimer *time.Timer
+}
+
+// cachedProducer is the producer returned by Client.ProducerFor, closing it releases the shared producer
+type cachedProducer struct {
+	Producer
+	cache     *producerCache
+	topic     string
+	entry     *producerCacheEntry
+	closeOnce sync.Once
+}
+
+func newProducerCache(idleTime time.Duration, create func(topic string) (Producer, error)) *producerCache {
+	if idleTime == 0 {
+		idleTime = defaultProducerCacheIdleTime
+	}
+	return &producerCache{
+		entries:  make(map[string]*producerCacheEntry),
+		idleTime: idleTime,
+		create:   create,
+	}
+}
+
+func (c *producerCache) get(topic string) (Producer, error) {
+	c.Lock()
+	if c.closed {
+		c.Unlock()
+		return nil, newError(AlreadyClosedError, "the client is closed")
+	}
+	entry, ok := c.entries[topic]
+	if !ok {
+		entry = &producerCacheEntry{ready: make(chan struct{})}
+		c.entries[topic] = entry
+	}
+	entry.refs++
+	if entry.evictTimer != nil {
+		entry.evictTimer.Stop()
+		entry.evictTimer = nil
+	}
+	c.Unlock()
+
+	if !ok {
+		// the producer is created outside the lock to not block the other topics
+		entry.producer, entry.err = c.create(topic)
+		if entry.err != nil {
+			c.Lock()
+			delete(c.entries, topic)
+			c.Unlock()
+		}
+		close(entry.ready)
+	} else {
+		<-entry.ready
+	}
+
+	if entry.err != nil {
+		return nil, entry.err
+	}
+	return &cachedProducer{
+		Producer: entry.producer,
+		cache:    c,
+		topic:    topic,
+		entry:    entry,
+	}, nil
+}
+
+func (c *producerCache) release(topic string, entry *producerCacheEntry) {
+	c.Lock()
+	defer c.Unlock()
+
+	entry.refs--
+	if entry.refs > 0 || c.closed || c.entries[topic] != entry {
+		return
+	}
+	if c.idleTime < 0 {
+		// the producers are kept until the client is closed
+		return
+	}
+	entry.evictTimer = time.AfterFunc(c.idleTime, func() {
+		c.evict(topic, entry)
+	})
+}
+
+func (c *producerCache) evict(topic string, entry *producerCacheEntry) {
+	c.Lock()
+	if entry.refs > 0 || c.entries[topic] != entry {
+		c.Unlock()
+		return
+	}
+	delete(c.entries, topic)
+	c.Unlock()
+
+	entry.producer.Close()
+}
+
+// size returns the number of cached producers
+func (c *producerCache) size() int {
+	c.Lock()
+	defer c.Unlock()
+	return len(c.entries)
+}
+
+// close stops the eviction of the producers, which are closed with the client
+func (c *producerCache) close() {
+	c.Lock()
+	defer c.Unlock()
+
+	c.closed = true
+	for _, entry := range c.entries {
+		if entry.evictTimer != nil {
+			entry.evictTimer.Stop()
+		}
+	}
+	c.entries = make(map[string]*producerCacheEntry)
+}
+
+// Close releases the shared producer, it is closed when it is no longer used
+func (p *cachedProducer) Close() {
+	p.closeOnce.Do(func() {
+		p.cache.release(p.topic, p.entry)
+	})
+}
+
+// ProducerFor returns a producer of the topic shared with the other callers
+func (c *client) ProducerFor(topic string) (Producer, error) {
+	return c.producerCache.get(topic)
+}
+
+func (c *client) createCachedProducer(topic string) (Producer, error) {
+	return c.CreateProducer(ProducerOptions{Topic: topic})
+}
@@ -0,0 +1,137 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsar
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type closeCountingProducer struct {
+	Producer
+	topic  string
+	closed int32
+}
+
+func (p *closeCountingProducer) Topic() string {
+	return p.topic
+}
+
+func (p *closeCountingProducer) Close() {
+	atomic.AddInt32(&p.closed, 1)
+}
+
+type testProducerFactory struct {
+	sync.Mutex
+	created []*closeCountingProducer
+}
+
+func (f *testProducerFactory) create(topic string) (Producer, error) {
+	if topic == "invalid" {
+		return nil, errors.New("invalid topic")
+	}
+	f.Lock()
+	defer f.Unlock()
+	p := &closeCountingProducer{topic: topic}
+	f.created = append(f.created, p)
+	return p, nil
+}
+
+func TestProducerCacheSharesProducers(t *testing.T) {
+	factory := &testProducerFactory{}
+	cache := newProducerCache(time.Hour, factory.create)
+
+	var wg sync.WaitGroup
+	producers := make([]Producer, 10)
+	for i := range producers {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			p, err := cache.get("topic")
+			assert.NoError(t, err)
+			producers[i] = p
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Len(t, factory.created, 1)
+	assert.Equal(t, 1, cache.size())
+	for _, p := range producers {
+		assert.Equal(t, "topic", p.Topic())
+	}
+
+	_, err := cache.get("invalid")
+	assert.Error(t, err)
+	assert.Equal(t, 1, cache.size())
+}
+
+func TestProducerCacheIdleEviction(t *testing.T) {
+	factory := &testProducerFactory{}
+	cache := newProducerCache(50*time.Millisecond, factory.create)
+
+	p1, err := cache.get("topic")
+	assert.NoError(t, err)
+	p2, err := cache.get("topic")
+	assert.NoError(t, err)
+
+	// the producer is kept while it is used
+	p1.Close()
+	p1.Close()
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, 1, cache.size())
+
+	// a released producer used again before the idle time is kept
+	p2.Close()
+	p3, err := cache.get("topic")
+	assert.NoError(t, err)
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, 1, cache.size())
+	assert.Len(t, factory.created, 1)
+
+	p3.Close()
+	assert.Eventually(t, func() bool {
+		return cache.size() == 0
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&factory.created[0].closed))
+
+	// a new producer is created after the eviction
+	p4, err := cache.get("topic")
+	assert.NoError(t, err)
+	defer p4.Close()
+	assert.Len(t, factory.created, 2)
+}
+
+func TestProducerCacheClose(t *testing.T) {
+	factory := &testProducerFactory{}
+	cache := newProducerCache(-1, factory.create)
+
+	p, err := cache.get("topic")
+	assert.NoError(t, err)
+	p.Close()
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, 1, cache.size())
+
+	cache.close()
+	_, err = cache.get("topic")
+	assert.Error(t, err)
+}
@@ -43,6 +43,9 @@ const (
 	// defaultMaxMessagesPerBatch init default num of entries in per batch.
 	defaultMaxMessagesPerBatch = 1000
 
+	// defaultBatchingMaxKeys init default num of keys with an open batch in the key based batch builder
+	defaultBatchingMaxKeys = 1000
+
 	// defaultPartitionsAutoDiscoveryInterval init default time interval for partitions auto discovery
 	defaultPartitionsAutoDiscoveryInterval = 1 * time.Minute
 )
@@ -56,10 +59,14 @@ type producer struct {
 	producersPtr  unsafe.Pointer
 	numPartitions uint32
 	messageRouter func(*ProducerMessage, TopicMetadata) int
+	hashFunc      func(string) uint32
 	closeOnce     sync.Once
 	stopDiscovery func()
 	log           log.Logger
 	metrics       *internal.LeveledMetrics
+
+	// keyOrdered chains the sends of each key, nil unless MaxInflightPerKey is set
+	keyOrdered *keyOrderedSender
 }
 
 func getHashingFunction(s HashingScheme) func(string) uint32 {
@@ -78,6 +85,14 @@ func newProducer(client *client, options *ProducerOptions) (*producer, error) {
 		return nil, newError(InvalidTopicName, "Topic name is required for producer")
 	}
 
+	if err := client.checkTopicsExist(options.Topic); err != nil {
+		return nil, err
+	}
+
+	if _, err := client.initAuth(options.Authentication); err != nil {
+		return nil, err
+	}
+
 	if options.SendTimeout == 0 {
 		options.SendTimeout = defaultSendTimeout
 	}
@@ -87,6 +102,9 @@ func newProducer(client *client, options *ProducerOptions) (*producer, error) {
 	if options.BatchingMaxSize == 0 {
 		options.BatchingMaxSize = defaultMaxBatchSize
 	}
+	if options.BatchingMaxKeys == 0 {
+		options.BatchingMaxKeys = defaultBatchingMaxKeys
+	}
 	if options.BatchingMaxPublishDelay <= 0 {
 		options.BatchingMaxPublishDelay = defaultBatchingMaxPublishDelay
 	}
@@ -103,20 +121,34 @@ func newProducer(client *client, options *ProducerOptions) (*producer, error) {
 		topic:   options.Topic,
 		client:  client,
 		log:     client.log.SubLogger(log.Fields{"topic": options.Topic}),
-		metrics: client.metrics.GetLeveledMetrics(options.Topic),
+		metrics: client.resourceMetrics().GetLeveledMetrics(options.Topic),
 	}
 
 	if options.Interceptors == nil {
 		options.Interceptors = defaultProducerInterceptors
 	}
 
+	p.keyOrdered = newKeyOrderedSender(options.MaxInflightPerKey,
+		func(ctx context.Context, msg *ProducerMessage, callback func(MessageID, *ProducerMessage, error)) {
+			p.getPartition(msg).SendAsync(ctx, msg, callback)
+		})
+
+	p.hashFunc = getHashingFunction(options.HashingScheme)
 	if options.MessageRouter == nil {
-		internalRouter := NewDefaultRouter(
-			getHashingFunction(options.HashingScheme),
-			options.BatchingMaxMessages,
-			options.BatchingMaxSize,
-			options.BatchingMaxPublishDelay,
-			options.DisableBatching)
+		var internalRouter func(*ProducerMessage, uint32) int
+		if options.PartitionSwitchFrequency > 0 && !options.DisableBatching {
+			internalRouter = NewStickyPartitionRouter(
+				p.hashFunc,
+				options.BatchingMaxPublishDelay,
+				options.PartitionSwitchFrequency)
+		} else {
+			internalRouter = NewDefaultRouter(
+				p.hashFunc,
+				options.BatchingMaxMessages,
+				options.BatchingMaxSize,
+				options.BatchingMaxPublishDelay,
+				options.DisableBatching)
+		}
 		p.messageRouter = func(message *ProducerMessage, metadata TopicMetadata) int {
 			return internalRouter(message, metadata.NumPartitions())
 		}
@@ -132,7 +164,7 @@ func newProducer(client *client, options *ProducerOptions) (*producer, error) {
 
 	encryption := options.Encryption
 	// add default message crypto if not provided
-	if encryption != nil && len(encryption.Keys) > 0 {
+	if encryption != nil && (len(encryption.Keys) > 0 || encryption.KeySelector != nil) {
 		if encryption.KeyReader == nil {
 			return nil, fmt.Errorf("encryption is enabled, KeyReader can not be nil")
 		}
@@ -147,6 +179,12 @@ func newProducer(client *client, options *ProducerOptions) (*producer, error) {
 			}
 			p.options.Encryption.MessageCrypto = messageCrypto
 		}
+
+		if client.fipsMode {
+			if err := enableFIPSMessageCrypto(encryption.MessageCrypto); err != nil {
+				return nil, err
+			}
+		}
 	}
 
 	err := p.internalCreatePartitionsProducers()
@@ -191,6 +229,7 @@ func (p *producer) internalCreatePartitionsProducers() error {
 	if err != nil {
 		return err
 	}
+	detectedAt := time.Now()
 
 	oldNumPartitions := 0
 	newNumPartitions := len(partitions)
@@ -215,12 +254,18 @@ func (p *producer) internalCreatePartitionsProducers() error {
 
 	p.producers = make([]Producer, newNumPartitions)
 
-	// When for some reason (eg: forced deletion of sub partition) causes oldNumPartitions> newNumPartitions,
-	// we need to rebuild the cache of new producers, otherwise the array will be out of bounds.
-	if oldProducers != nil && oldNumPartitions < newNumPartitions {
-		// Copy over the existing consumer instances
-		for i := 0; i < oldNumPartitions; i++ {
+	// When the topic has fewer partitions (eg: recreated with fewer partitions), the producers of the removed
+	// partitions are closed instead of reconnecting to partitions which don't exist anymore
+	oldTopics := make([]string, oldNumPartitions)
+	for i, producer := range oldProducers {
+		oldTopics[i] = producer.Topic()
+	}
+	var removed []Producer
+	for i, reuse := range partitionsToReuse(oldTopics, partitions) {
+		if reuse {
 			p.producers[i] = oldProducers[i]
+		} else {
+			removed = append(removed, oldProducers[i])
 		}
 	}
 
@@ -230,16 +275,21 @@ func (p *producer) internalCreatePartitionsProducers() error {
 		err       error
 	}
 
-	startPartition := oldNumPartitions
-	partitionsToAdd := newNumPartitions - oldNumPartitions
-	if partitionsToAdd < 0 {
-		partitionsToAdd = newNumPartitions
-		startPartition = 0
+	partitionsToAdd := 0
+	for _, producer := range p.producers {
+		if producer == nil {
+			partitionsToAdd++
+		}
 	}
 	c := make(chan ProducerError, partitionsToAdd)
 
-	for partitionIdx := startPartition; partitionIdx < newNumPartitions; partitionIdx++ {
+	var added []string
+	for partitionIdx := 0; partitionIdx < newNumPartitions; partitionIdx++ {
+		if p.producers[partitionIdx] != nil {
+			continue
+		}
 		partition := partitions[partitionIdx]
+		added = append(added, partition)
 
 		go func(partitionIdx int, partition string) {
 			prod, e := newPartitionProducer(p.client, partition, p.options, partitionIdx, p.metrics)
@@ -251,6 +301,7 @@ func (p *producer) internalCreatePartitionsProducers() error {
 		}(partitionIdx, partition)
 	}
 
+	var created []Producer
 	for i := 0; i < partitionsToAdd; i++ {
 		pe, ok := <-c
 		if ok {
@@ -258,30 +309,69 @@ func (p *producer) internalCreatePartitionsProducers() error {
 				err = pe.err
 			} else {
 				p.producers[pe.partition] = pe.prod
+				created = append(created, pe.prod)
 			}
 		}
 	}
 
 	if err != nil {
-		// Since there were some failures, cleanup all the partitions that succeeded in creating the producers
-		for _, producer := range p.producers {
-			if producer != nil {
-				producer.Close()
-			}
+		// Since there were some failures, cleanup the partitions that succeeded in creating the producers
+		for _, producer := range created {
+			producer.Close()
+		}
+		if oldProducers != nil {
+			p.producers = oldProducers
 		}
 		return err
 	}
 
-	if newNumPartitions < oldNumPartitions {
-		p.metrics.ProducersPartitions.Set(float64(newNumPartitions))
-	} else {
-		p.metrics.ProducersPartitions.Add(float64(partitionsToAdd))
+	if len(removed) > 0 {
+		p.removePartitions(removed, oldNumPartitions, newNumPartitions)
 	}
+
+	p.metrics.ProducersPartitions.Add(float64(len(created) - len(removed)))
 	atomic.StorePointer(&p.producersPtr, unsafe.Pointer(&p.producers))
 	atomic.StoreUint32(&p.numPartitions, uint32(len(p.producers)))
+	if oldProducers != nil {
+		p.partitionsChanged(oldNumPartitions, newNumPartitions, added, detectedAt)
+	}
 	return nil
 }
 
+// partitionsChanged notifies the listener once the messages are routed to the new number of partitions
+func (p *producer) partitionsChanged(oldNumPartitions, newNumPartitions int, added []string,
+	detectedAt time.Time) {
+	if p.options.PartitionsChangedListener == nil {
+		return
+	}
+	p.options.PartitionsChangedListener.PartitionsChanged(PartitionsChangedEvent{
+		Topic:         p.topic,
+		OldPartitions: oldNumPartitions,
+		NewPartitions: newNumPartitions,
+		Added:         added,
+		DetectedAt:    detectedAt,
+	})
+}
+
+// removePartitions closes the producers of the partitions removed from the topic
+func (p *producer) removePartitions(removed []Producer, oldNumPartitions, newNumPartitions int) {
+	event := PartitionsRemovedEvent{
+		Topic:         p.topic,
+		OldPartitions: oldNumPartitions,
+		NewPartitions: newNumPartitions,
+		DetectedAt:    time.Now(),
+	}
+	for _, producer := range removed {
+		event.Removed = append(event.Removed, producer.Topic())
+		producer.Close()
+	}
+	p.log.WithField("removed", event.Removed).
+		Warn("Closed the producers of the partitions removed from the topic")
+	if p.options.PartitionsRemovedListener != nil {
+		p.options.PartitionsRemovedListener.PartitionsRemoved(event)
+	}
+}
+
 func (p *producer) Topic() string {
 	return p.topic
 }
@@ -297,15 +387,96 @@ func (p *producer) NumPartitions() uint32 {
 	return atomic.LoadUint32(&p.numPartitions)
 }
 
+func (p *producer) PartitionFor(msg *ProducerMessage) int {
+	numPartitions := p.NumPartitions()
+	if p.options.MessageRouter != nil {
+		return p.messageRouter(msg, p) % int(numPartitions)
+	}
+	if numPartitions == 1 {
+		return 0
+	}
+	if partition, ok := keyPartition(p.hashFunc, msg, numPartitions); ok {
+		return partition
+	}
+	return -1
+}
+
 func (p *producer) Send(ctx context.Context, msg *ProducerMessage) (MessageID, error) {
-	return p.getPartition(msg).Send(ctx, msg)
+	if p.keyOrdered == nil {
+		return p.getPartition(msg).Send(ctx, msg)
+	}
+
+	type sendResult struct {
+		id  MessageID
+		err error
+	}
+	done := make(chan sendResult, 1)
+	p.keyOrdered.sendAsync(ctx, msg, func(id MessageID, _ *ProducerMessage, err error) {
+		done <- sendResult{id: id, err: err}
+	})
+	select {
+	case res := <-done:
+		return res.id, res.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
 }
 
 func (p *producer) SendAsync(ctx context.Context, msg *ProducerMessage,
 	callback func(MessageID, *ProducerMessage, error)) {
+	if p.keyOrdered != nil {
+		p.keyOrdered.sendAsync(ctx, msg, callback)
+		return
+	}
 	p.getPartition(msg).SendAsync(ctx, msg, callback)
 }
 
+func (p *producer) Republish(ctx context.Context, msg Message, transform func(*ProducerMessage)) (MessageID, error) {
+	return republish(ctx, p, msg, transform)
+}
+
+func republish(ctx context.Context, p Producer, msg Message, transform func(*ProducerMessage)) (MessageID, error) {
+	producerMsg, err := newRepublishMessage(msg)
+	if err != nil {
+		return nil, err
+	}
+	if transform != nil {
+		transform(producerMsg)
+	}
+	return p.Send(ctx, producerMsg)
+}
+
+// newRepublishMessage copies a consumed message into a message to publish
+func newRepublishMessage(msg Message) (*ProducerMessage, error) {
+	if msg == nil {
+		return nil, newError(InvalidMessage, "message to republish is nil")
+	}
+
+	properties := make(map[string]string, len(msg.Properties()))
+	for k, v := range msg.Properties() {
+		properties[k] = v
+	}
+
+	producerMsg := &ProducerMessage{
+		Payload:     msg.Payload(),
+		Key:         msg.Key(),
+		OrderingKey: msg.OrderingKey(),
+		Properties:  properties,
+		EventTime:   msg.EventTime(),
+	}
+
+	// the schema version is local to the topic, the schema itself is republished so the producer
+	// registers it on its own topic
+	if m, ok := msg.(*message); ok && len(m.schemaVersion) > 0 && m.schemaInfoCache != nil {
+		schema, err := m.schemaInfoCache.Get(m.schemaVersion)
+		if err != nil {
+			return nil, newError(SchemaFailure, fmt.Sprintf("failed to get the schema of the message: %v", err))
+		}
+		producerMsg.Schema = schema
+	}
+	return producerMsg, nil
+}
+
 func (p *producer) getPartition(msg *ProducerMessage) Producer {
 	// Since partitions can only increase, it's ok if the producers list
 	// is updated in between. The numPartition is updated only after the list.
@@ -346,6 +517,61 @@ func (p *producer) Flush() error {
 	return nil
 }
 
+func (p *producer) WarmUp(ctx context.Context) error {
+	p.RLock()
+	producers := make([]Producer, len(p.producers))
+	copy(producers, p.producers)
+	p.RUnlock()
+
+	errs := make(chan error, len(producers))
+	for _, pp := range producers {
+		go func(pp Producer) {
+			errs <- pp.WarmUp(ctx)
+		}(pp)
+	}
+
+	var firstErr error
+	for range producers {
+		if err := <-errs; err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func (p *producer) Pending() []PendingMessage {
+	p.RLock()
+	defer p.RUnlock()
+
+	var pending []PendingMessage
+	for _, pp := range p.producers {
+		pending = append(pending, pp.Pending()...)
+	}
+	return pending
+}
+
+func (p *producer) CancelPending(ctx context.Context) error {
+	p.RLock()
+	producers := make([]Producer, len(p.producers))
+	copy(producers, p.producers)
+	p.RUnlock()
+
+	errs := make(chan error, len(producers))
+	for _, pp := range producers {
+		go func(pp Producer) {
+			errs <- pp.CancelPending(ctx)
+		}(pp)
+	}
+
+	var firstErr error
+	for range producers {
+		if err := <-errs; err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
 func (p *producer) Close() {
 	p.closeOnce.Do(func() {
 		p.stopDiscovery()
@@ -73,18 +73,32 @@ type partitionProducer struct {
 
 	conn uAtomic.Value
 
+	// callbacks invokes the send callbacks of the partition in order, nil to invoke them in place
+	callbacks *orderedExecutor
+
+	// unload tracks the recovery from the broker closing the producer
+	unload *topicUnload
+
 	options                  *ProducerOptions
 	producerName             string
 	userProvidedProducerName bool
 	producerID               uint64
 	batchBuilder             internal.BatchBuilder
 	sequenceIDGenerator      *uint64
-	batchFlushTicker         *time.Ticker
-	encryptor                internalcrypto.Encryptor
-	compressionProvider      compression.Provider
-
-	// Channel where app is posting messages to be published
-	eventsChan      chan interface{}
+	batchFlushTimer          *time.Timer
+	batchingMaxPublishDelay  time.Duration
+	maxUnflushedDuration     time.Duration
+	// batchStartTime is the time the first message was added to the current batch, zero if the batch is empty
+	batchStartTime      time.Time
+	encryptor           internalcrypto.Encryptor
+	compressionProvider compression.Provider
+	// keySets holds the encryptors of the keys selected per message, nil without encryption key selector
+	keySets *keySetEncryptors
+	// batchEncryptor is the encryptor of the current batch when the keys are selected per message
+	batchEncryptor internalcrypto.Encryptor
+
+	// Queue where app is posting messages to be published
+	eventsQueue     *internal.RingBuffer
 	closeCh         chan struct{}
 	connectClosedCh chan connectionClosed
 
@@ -97,6 +111,12 @@ type partitionProducer struct {
 	epoch            uint64
 	schemaCache      *schemaCache
 	topicEpoch       *uint64
+	lastError        lastError
+	// affinity is the broker the producer is connected to, see ClientOptions.StickyReconnect
+	affinity brokerAffinity
+
+	// adaptiveDelay tunes the publish delay of the batches, nil without ProducerOptions.AdaptiveBatching
+	adaptiveDelay *adaptiveBatchDelay
 }
 
 type schemaCache struct {
@@ -135,6 +155,11 @@ func newPartitionProducer(client *client, topic string, options *ProducerOptions
 		batchingMaxPublishDelay = defaultBatchingMaxPublishDelay
 	}
 
+	maxUnflushedDuration := options.MaxUnflushedDuration
+	if maxUnflushedDuration <= 0 {
+		maxUnflushedDuration = batchingMaxPublishDelay
+	}
+
 	var maxPendingMessages int
 	if options.MaxPendingMessages == 0 {
 		maxPendingMessages = 1000
@@ -145,16 +170,17 @@ func newPartitionProducer(client *client, topic string, options *ProducerOptions
 	logger := client.log.SubLogger(log.Fields{"topic": topic})
 
 	p := &partitionProducer{
-		client:           client,
-		topic:            topic,
-		log:              logger,
-		options:          options,
-		producerID:       client.rpcClient.NewProducerID(),
-		eventsChan:       make(chan interface{}, maxPendingMessages),
-		connectClosedCh:  make(chan connectionClosed, 10),
-		closeCh:          make(chan struct{}),
-		batchFlushTicker: time.NewTicker(batchingMaxPublishDelay),
-		compressionProvider: internal.GetCompressionProvider(pb.CompressionType(options.CompressionType),
+		client:          client,
+		callbacks:       client.callbackExecutor,
+		topic:           topic,
+		log:             logger,
+		options:         options,
+		producerID:      client.rpcClient.NewProducerID(),
+		eventsQueue:     internal.NewRingBuffer(maxPendingMessages),
+		connectClosedCh: make(chan connectionClosed, 10),
+		closeCh:         make(chan struct{}),
+		batchFlushTimer: time.NewTimer(batchingMaxPublishDelay),
+		compressionProvider: internal.GetCompressionProvider(compressionTypeOf(options.CompressionType),
 			compression.Level(options.CompressionLevel)),
 		publishSemaphore: internal.NewSemaphore(int32(maxPendingMessages)),
 		pendingQueue:     internal.NewBlockingQueue(maxPendingMessages),
@@ -163,9 +189,15 @@ func newPartitionProducer(client *client, topic string, options *ProducerOptions
 		metrics:          metrics,
 		epoch:            0,
 		schemaCache:      newSchemaCache(),
+
+		batchingMaxPublishDelay: batchingMaxPublishDelay,
+		maxUnflushedDuration:    maxUnflushedDuration,
 	}
-	if p.options.DisableBatching {
-		p.batchFlushTicker.Stop()
+	// the timer is armed when a message is added to an empty batch
+	p.batchFlushTimer.Stop()
+	if options.AdaptiveBatching != nil && !options.DisableBatching {
+		p.adaptiveDelay = newAdaptiveBatchDelay(options.AdaptiveBatching, batchingMaxPublishDelay,
+			options.BatchingMaxMessages, options.BatchingMaxSize, time.Now())
 	}
 	p.setProducerState(producerInit)
 
@@ -175,15 +207,24 @@ func newPartitionProducer(client *client, topic string, options *ProducerOptions
 		p.schemaInfo = nil
 	}
 
+	if options.SchemaVersion != nil {
+		if err := p.pinSchemaVersion(options.SchemaVersion); err != nil {
+			p.batchFlushTimer.Stop()
+			return nil, err
+		}
+	}
+
 	if options.Name != "" {
 		p.producerName = options.Name
 		p.userProvidedProducerName = true
 	} else {
 		p.userProvidedProducerName = false
 	}
+	// assigned before connecting since the connection may be closed as soon as the producer is created
+	p.unload = newTopicUnload(client, topic, p.producerName, p.log)
 	err := p.grabCnx()
 	if err != nil {
-		p.batchFlushTicker.Stop()
+		p.batchFlushTimer.Stop()
 		logger.WithError(err).Error("Failed to create producer at newPartitionProducer")
 		return nil, err
 	}
@@ -193,6 +234,8 @@ func newPartitionProducer(client *client, topic string, options *ProducerOptions
 		"producerID":    p.producerID,
 	})
 
+	p.unload.rename(p.producerName, p.log)
+
 	p.log.WithField("cnx", p._getConn().ID()).Info("Created producer")
 	p.setProducerState(producerReady)
 
@@ -205,14 +248,40 @@ func newPartitionProducer(client *client, topic string, options *ProducerOptions
 	return p, nil
 }
 
+// compressionTypeOf returns the compression type of the batch builders and compression providers
+func compressionTypeOf(compressionType CompressionType) pb.CompressionType {
+	if compressionType == LZ4Frame {
+		return internal.CompressionTypeLZ4Frame
+	}
+	return pb.CompressionType(compressionType)
+}
+
+// checkBrokerFeatures fails if the broker serving the topic does not support the configured compression type
+func (p *partitionProducer) checkBrokerFeatures(lr *internal.LookupResult) error {
+	if p.options.CompressionType != Snappy {
+		return nil
+	}
+	cnx, err := p.client.cnxPool.GetConnectionWithAuth(lr.LogicalAddr, lr.PhysicalAddr,
+		authProviderOf(p.options.Authentication))
+	if err != nil {
+		return err
+	}
+	info := newBrokerInfo(cnx.GetServerInfo())
+	return info.require("Snappy compression", info.Features.Snappy)
+}
+
 func (p *partitionProducer) grabCnx() error {
-	lr, err := p.client.lookupService.Lookup(p.topic)
+	lr, err := p.affinity.lookup(p.client, p.topic)
 	if err != nil {
 		p.log.WithError(err).Warn("Failed to lookup topic")
 		return err
 	}
 
 	p.log.Debug("Lookup result: ", lr)
+	if err := p.checkBrokerFeatures(lr); err != nil {
+		p.log.WithError(err).Error("Failed to create producer")
+		return err
+	}
 	id := p.client.rpcClient.NewRequestID()
 
 	// set schema info for producer
@@ -253,7 +322,12 @@ func (p *partitionProducer) grabCnx() error {
 	if len(p.options.Properties) > 0 {
 		cmdProducer.Metadata = toKeyValues(p.options.Properties)
 	}
-	res, err := p.client.rpcClient.Request(lr.LogicalAddr, lr.PhysicalAddr, id, pb.BaseCommand_PRODUCER, cmdProducer)
+
+	if p.options.initialSubscriptionName != "" {
+		cmdProducer.InitialSubscriptionName = proto.String(p.options.initialSubscriptionName)
+	}
+	res, err := p.client.rpcClient.RequestWithAuth(authProviderOf(p.options.Authentication), lr.LogicalAddr,
+		lr.PhysicalAddr, id, pb.BaseCommand_PRODUCER, cmdProducer)
 	if err != nil {
 		p.log.WithError(err).Error("Failed to create producer at send PRODUCER request")
 		return err
@@ -271,6 +345,9 @@ func (p *partitionProducer) grabCnx() error {
 	} else {
 		p.encryptor = internalcrypto.NewNoopEncryptor()
 	}
+	if p.keySets == nil && p.options.Encryption != nil && p.options.Encryption.KeySelector != nil {
+		p.keySets = newKeySetEncryptors(p.options.Encryption, p.log)
+	}
 
 	if p.sequenceIDGenerator == nil {
 		nextSequenceID := uint64(res.Response.ProducerSuccess.GetLastSequenceId() + 1)
@@ -278,15 +355,18 @@ func (p *partitionProducer) grabCnx() error {
 	}
 
 	schemaVersion := res.Response.ProducerSuccess.GetSchemaVersion()
-	if len(schemaVersion) != 0 {
+	if len(schemaVersion) != 0 && p.options.SchemaVersion == nil {
 		p.schemaCache.Put(p.schemaInfo, schemaVersion)
 	}
 
 	p._setConn(res.Cnx)
+	p.affinity.connected(lr)
 	err = p._getConn().RegisterListener(p.producerID, p)
 	if err != nil {
 		return err
 	}
+	// the paused messages are in the pending queue, resent below
+	p.unload.resume()
 
 	if !p.options.DisableBatching && p.batchBuilder == nil {
 		provider, err := GetBatcherBuilderProvider(p.options.BatcherBuilderType)
@@ -295,7 +375,7 @@ func (p *partitionProducer) grabCnx() error {
 		}
 		maxMessageSize := uint32(p._getConn().GetMaxMessageSize())
 		p.batchBuilder, err = provider(p.options.BatchingMaxMessages, p.options.BatchingMaxSize,
-			maxMessageSize, p.producerName, p.producerID, pb.CompressionType(p.options.CompressionType),
+			maxMessageSize, p.producerName, p.producerID, compressionTypeOf(p.options.CompressionType),
 			compression.Level(p.options.CompressionLevel),
 			p,
 			p.log,
@@ -303,6 +383,10 @@ func (p *partitionProducer) grabCnx() error {
 		if err != nil {
 			return err
 		}
+		p.batchEncryptor = p.encryptor
+		if limiter, ok := p.batchBuilder.(internal.KeyBatchLimiter); ok {
+			limiter.SetMaxKeys(p.options.BatchingMaxKeys, p.options.BatchingKeyEviction)
+		}
 	}
 
 	p.log.WithFields(log.Fields{
@@ -355,6 +439,47 @@ func (p *partitionProducer) ConnectionClosed() {
 	p.connectClosedCh <- connectionClosed{}
 }
 
+// ClosedByBroker pauses the sends and reconnects right away, the broker closes the producer when the bundle
+// of the topic is unloaded or the ownership of the topic is transferred to another broker
+func (p *partitionProducer) ClosedByBroker() {
+	p.affinity.forget()
+	if !p.unload.begin() {
+		p.ConnectionClosed()
+		return
+	}
+	p.log.Info("Producer closed by the broker, pausing the sends until reconnected")
+	p.connectClosedCh <- connectionClosed{}
+}
+
+// writeData writes the data to the connection unless the sends are paused, the paused messages stay in the
+// pending queue and are resent once reconnected
+func (p *partitionProducer) writeData(data internal.Buffer) {
+	if p.unload.paused() {
+		return
+	}
+	p._getConn().WriteData(data)
+}
+
+// pinSchemaVersion checks the registered schema version holds the schema of the producer, and stamps it on the
+// messages sent with the schema
+func (p *partitionProducer) pinSchemaVersion(version []byte) error {
+	if p.schemaInfo == nil {
+		return newError(InvalidConfiguration, "SchemaVersion requires a Schema")
+	}
+	pbSchema, err := p.client.lookupService.GetSchema(p.topic, version)
+	if err != nil {
+		return newError(SchemaFailure, fmt.Sprintf("failed to get the schema version %x: %v", version, err))
+	}
+	if pbSchema == nil || SchemaType(pbSchema.GetType()) != p.schemaInfo.Type ||
+		string(pbSchema.GetSchemaData()) != p.schemaInfo.Schema {
+		return newError(SchemaFailure, fmt.Sprintf("the schema version %x of the topic %s is not the schema of "+
+			"the producer", version, p.topic))
+	}
+	p.log.Infof("Pinned the producer to the schema version %x", version)
+	p.schemaCache.Put(p.schemaInfo, version)
+	return nil
+}
+
 func (p *partitionProducer) getOrCreateSchema(schemaInfo *SchemaInfo) (schemaVersion []byte, err error) {
 
 	tmpSchemaType := pb.Schema_Type(int32(schemaInfo.Type))
@@ -393,10 +518,13 @@ func (p *partitionProducer) reconnectToBroker() {
 		maxRetry = int(*p.options.MaxReconnectToBroker)
 	}
 
-	for maxRetry != 0 {
+	p.affinity.reconnecting(p.client.stickyReconnect)
+	lastErr := errors.New("max reconnection attempts reached")
+	for attempt := 0; maxRetry != 0; attempt++ {
 		if p.getProducerState() != producerReady {
 			// Producer is already closing
 			p.log.Info("producer state not ready, exit reconnect")
+			p.unload.finish(errProducerClosed)
 			return
 		}
 
@@ -405,21 +533,36 @@ func (p *partitionProducer) reconnectToBroker() {
 			defaultBackoff     = internal.DefaultBackoff{}
 		)
 
-		if p.options.BackoffPolicy == nil {
+		backoffPolicy := p.options.BackoffPolicy
+		if backoffPolicy == nil {
+			backoffPolicy = p.client.reconnectBackoffPolicy()
+		}
+		if backoffPolicy == nil {
 			delayReconnectTime = defaultBackoff.Next()
 		} else {
-			delayReconnectTime = p.options.BackoffPolicy.Next()
+			delayReconnectTime = backoffPolicy.Next()
+		}
+		if attempt == 0 && p.unload.paused() {
+			// look the topic up again right away, it's likely already owned by another broker
+			delayReconnectTime = 0
 		}
 		p.log.Info("Reconnecting to broker in ", delayReconnectTime)
 		time.Sleep(delayReconnectTime)
+		if attempt > 0 && !p.client.retryBudget.Withdraw() {
+			p.log.Warn("Retry budget exhausted, delaying the reconnection")
+			continue
+		}
 		atomic.AddUint64(&p.epoch, 1)
 		err := p.grabCnx()
 		if err == nil {
 			// Successfully reconnected
 			p.log.WithField("cnx", p._getConn().ID()).Info("Reconnected producer to broker")
+			p.unload.finish(nil)
 			return
 		}
+		lastErr = err
 		p.log.WithError(err).Error("Failed to create producer at reconnect")
+		p.lastError.set(err)
 		errMsg := err.Error()
 		if strings.Contains(errMsg, errTopicNotFount) {
 			// when topic is deleted, we should give up reconnection.
@@ -435,6 +578,7 @@ func (p *partitionProducer) reconnectToBroker() {
 			p.metrics.ProducersReconnectMaxRetry.Inc()
 		}
 	}
+	p.unload.finish(lastErr)
 }
 
 func (p *partitionProducer) runEventsLoop() {
@@ -451,19 +595,26 @@ func (p *partitionProducer) runEventsLoop() {
 		}
 	}()
 
+	var events []interface{}
 	for {
 		select {
-		case i := <-p.eventsChan:
-			switch v := i.(type) {
-			case *sendRequest:
-				p.internalSend(v)
-			case *flushRequest:
-				p.internalFlush(v)
-			case *closeProducer:
-				p.internalClose(v)
-				return
+		case <-p.eventsQueue.Ready():
+			events = p.eventsQueue.Drain(events[:0])
+			for i, event := range events {
+				events[i] = nil
+				switch v := event.(type) {
+				case *sendRequest:
+					p.internalSend(v)
+				case *flushRequest:
+					p.internalFlush(v)
+				case *cancelPendingRequest:
+					p.internalCancelPending(v)
+				case *closeProducer:
+					p.internalClose(v)
+					return
+				}
 			}
-		case <-p.batchFlushTicker.C:
+		case <-p.batchFlushTimer.C:
 			p.internalFlushCurrentBatch()
 		}
 	}
@@ -533,6 +684,16 @@ func (p *partitionProducer) internalSend(request *sendRequest) {
 		uncompressedPayload = schemaPayload
 	}
 
+	if len(p.options.PayloadTransformers) > 0 {
+		msg, uncompressedPayload, err = p.transformPayload(msg, uncompressedPayload)
+		if err != nil {
+			p.releaseSemaphoreAndMem(uncompressedPayloadSize)
+			request.callback(nil, request.msg, err)
+			p.log.WithError(err).Error("Failed to transform the payload")
+			return
+		}
+	}
+
 	if schema != nil {
 		schemaVersion = p.schemaCache.Get(schema.GetSchemaInfo())
 		if schemaVersion == nil {
@@ -556,19 +717,28 @@ func (p *partitionProducer) internalSend(request *sendRequest) {
 
 	mm := p.genMetadata(msg, uncompressedSize, deliverAt)
 
-	// set default ReplicationClusters when DisableReplication
-	if msg.DisableReplication {
-		msg.ReplicationClusters = []string{"__local__"}
+	encryptor, err := p.messageEncryptor(msg)
+	if err != nil {
+		p.releaseSemaphoreAndMem(uncompressedPayloadSize)
+		request.callback(nil, request.msg, err)
+		p.log.WithError(err).Error("Failed to select the encryption keys of the message")
+		return
 	}
 
 	sendAsBatch := !p.options.DisableBatching &&
-		msg.ReplicationClusters == nil &&
-		deliverAt.UnixNano() < 0
+		mm.ReplicateTo == nil &&
+		deliverAt.UnixNano() < 0 &&
+		msg.Transaction == nil &&
+		!msg.DisableCompression
 
 	// Once the batching is enabled, it can close blockCh early to make block finish
 	if sendAsBatch {
 		request.stopBlock()
 	} else {
+		// the messages of the current batch were sent before this one
+		if !p.batchStartTime.IsZero() {
+			p.internalFlushCurrentBatch()
+		}
 		// update sequence id for metadata, make the size of msgMetadata more accurate
 		// batch sending will update sequence ID in the BatchBuilder
 		p.updateMetadataSeqID(mm, msg)
@@ -581,15 +751,19 @@ func (p *partitionProducer) internalSend(request *sendRequest) {
 	var compressedSize int
 	var checkSize int
 	if !sendAsBatch {
-		compressedPayload = p.compressionProvider.Compress(nil, uncompressedPayload)
-		compressedSize = len(compressedPayload)
-		checkSize = compressedSize
+		if msg.DisableCompression {
+			compressedPayload = uncompressedPayload
+		} else {
+			compressedPayload = p.compressionProvider.Compress(nil, uncompressedPayload)
 
-		// set the compress type in msgMetaData
-		compressionType := pb.CompressionType(p.options.CompressionType)
-		if compressionType != pb.CompressionType_NONE {
-			mm.Compression = &compressionType
+			// set the compress type in msgMetaData
+			compressionType := internal.WireCompressionType(compressionTypeOf(p.options.CompressionType))
+			if compressionType != pb.CompressionType_NONE {
+				mm.Compression = &compressionType
+			}
 		}
+		compressedSize = len(compressedPayload)
+		checkSize = compressedSize
 	} else {
 		// final check for batching message is in serializeMessage
 		// this is a double check
@@ -669,20 +843,30 @@ func (p *partitionProducer) internalSend(request *sendRequest) {
 					p.releaseSemaphoreAndMem(uncompressedPayloadSize - int64(rhs))
 					return
 				}
-				p.internalSingleSend(mm, compressedPayload[lhs:rhs], nsr, uint32(maxMessageSize))
+				p.internalSingleSend(mm, compressedPayload[lhs:rhs], nsr, uint32(maxMessageSize), encryptor)
 			}
 			// close the blockCh when all the chunks acquired permits
 			request.stopBlock()
 		} else {
 			// close the blockCh when totalChunks is 1 (it has acquired permits)
 			request.stopBlock()
-			p.internalSingleSend(mm, compressedPayload, request, uint32(maxMessageSize))
+			p.internalSingleSend(mm, compressedPayload, request, uint32(maxMessageSize), encryptor)
 		}
 	} else {
+		if p.keySets != nil && encryptor != p.batchEncryptor {
+			// a batch is encrypted with the keys of all its messages
+			if !p.batchStartTime.IsZero() {
+				p.internalFlushCurrentBatch()
+			}
+			p.batchBuilder.SetEncryptor(encryptor)
+			p.batchEncryptor = encryptor
+		}
 		smm := p.genSingleMessageMetadataInBatch(msg, uncompressedSize)
+		request.sequenceID = smm.GetSequenceId()
 		multiSchemaEnabled := !p.options.DisableMultiSchema
 		added := p.batchBuilder.Add(smm, p.sequenceIDGenerator, uncompressedPayload, request,
-			msg.ReplicationClusters, deliverAt, schemaVersion, multiSchemaEnabled)
+			mm.ReplicateTo, deliverAt, schemaVersion, multiSchemaEnabled)
+		p.internalFlushEvictedBatches()
 		if !added {
 			// The current batch is full.. flush it and retry
 
@@ -690,7 +874,7 @@ func (p *partitionProducer) internalSend(request *sendRequest) {
 
 			// after flushing try again to add the current payload
 			if ok := p.batchBuilder.Add(smm, p.sequenceIDGenerator, uncompressedPayload, request,
-				msg.ReplicationClusters, deliverAt, schemaVersion, multiSchemaEnabled); !ok {
+				mm.ReplicateTo, deliverAt, schemaVersion, multiSchemaEnabled); !ok {
 				p.releaseSemaphoreAndMem(uncompressedPayloadSize)
 				request.callback(nil, request.msg, errFailAddToBatch)
 				p.log.WithField("size", uncompressedSize).
@@ -699,6 +883,13 @@ func (p *partitionProducer) internalSend(request *sendRequest) {
 				return
 			}
 		}
+		if p.adaptiveDelay != nil {
+			p.adaptiveDelay.add(uncompressedSize)
+		}
+		if p.batchStartTime.IsZero() {
+			p.batchStartTime = time.Now()
+			p.batchFlushTimer.Reset(p.nextBatchDelay(p.batchStartTime))
+		}
 		if request.flushImmediately {
 
 			p.internalFlushCurrentBatch()
@@ -707,13 +898,25 @@ func (p *partitionProducer) internalSend(request *sendRequest) {
 	}
 }
 
+// messageEncryptor returns the encryptor of the keys selected for the message, the producer encryptor when none
+func (p *partitionProducer) messageEncryptor(msg *ProducerMessage) (internalcrypto.Encryptor, error) {
+	if p.keySets == nil {
+		return p.encryptor, nil
+	}
+	keys := p.options.Encryption.KeySelector(msg)
+	if len(keys) == 0 {
+		return p.encryptor, nil
+	}
+	return p.keySets.get(keys)
+}
+
 func (p *partitionProducer) genMetadata(msg *ProducerMessage,
 	uncompressedSize int,
 	deliverAt time.Time) (mm *pb.MessageMetadata) {
 	mm = &pb.MessageMetadata{
 		ProducerName:     &p.producerName,
 		PublishTime:      proto.Uint64(internal.TimestampMillis(time.Now())),
-		ReplicateTo:      msg.ReplicationClusters,
+		ReplicateTo:      msg.replicateTo(),
 		UncompressedSize: proto.Uint32(uint32(uncompressedSize)),
 	}
 
@@ -777,7 +980,8 @@ func (p *partitionProducer) genSingleMessageMetadataInBatch(msg *ProducerMessage
 func (p *partitionProducer) internalSingleSend(mm *pb.MessageMetadata,
 	compressedPayload []byte,
 	request *sendRequest,
-	maxMessageSize uint32) {
+	maxMessageSize uint32,
+	encryptor internalcrypto.Encryptor) {
 	msg := request.msg
 
 	payloadBuf := internal.NewBuffer(len(compressedPayload))
@@ -789,6 +993,16 @@ func (p *partitionProducer) internalSingleSend(mm *pb.MessageMetadata,
 	}
 
 	sid := *mm.SequenceId
+	request.sequenceID = sid
+
+	var useTxn bool
+	var mostSigBits, leastSigBits uint64
+	if msg.Transaction != nil {
+		txnID := msg.Transaction.GetTxnID()
+		useTxn = true
+		mostSigBits = txnID.MostSigBits
+		leastSigBits = txnID.LeastSigBits
+	}
 
 	if err := internal.SingleSend(
 		buffer,
@@ -796,8 +1010,11 @@ func (p *partitionProducer) internalSingleSend(mm *pb.MessageMetadata,
 		sid,
 		mm,
 		payloadBuf,
-		p.encryptor,
+		encryptor,
 		maxMessageSize,
+		useTxn,
+		mostSigBits,
+		leastSigBits,
 	); err != nil {
 		request.callback(nil, request.msg, err)
 		p.releaseSemaphoreAndMem(int64(len(msg.Payload)))
@@ -811,7 +1028,7 @@ func (p *partitionProducer) internalSingleSend(mm *pb.MessageMetadata,
 		sequenceID:   sid,
 		sendRequests: []interface{}{request},
 	})
-	p._getConn().WriteData(buffer)
+	p.writeData(buffer)
 }
 
 type pendingItem struct {
@@ -824,6 +1041,7 @@ type pendingItem struct {
 }
 
 func (p *partitionProducer) internalFlushCurrentBatch() {
+	p.disarmBatchFlushTimer()
 	if p.batchBuilder.IsMultiBatches() {
 		p.internalFlushCurrentBatches()
 		return
@@ -857,7 +1075,7 @@ func (p *partitionProducer) internalFlushCurrentBatch() {
 		sequenceID:   sequenceID,
 		sendRequests: callbacks,
 	})
-	p._getConn().WriteData(batchData)
+	p.writeData(batchData)
 }
 
 func (p *partitionProducer) failTimeoutMessages() {
@@ -880,6 +1098,12 @@ func (p *partitionProducer) failTimeoutMessages() {
 			t.Reset(p.options.SendTimeout)
 			continue
 		}
+		if p.unload.inGracePeriod() {
+			// the messages are resent once reconnected, do not fail them while the topic is unloaded
+			t.Reset(p.options.SendTimeout)
+			continue
+		}
+
 		oldestItem := item.(*pendingItem)
 		if nextWaiting := diff(oldestItem.sentAt); nextWaiting > 0 {
 			// none of these pending messages have timed out, wait and retry
@@ -933,29 +1157,7 @@ func (p *partitionProducer) failTimeoutMessages() {
 
 			pi := item.(*pendingItem)
 			pi.Lock()
-
-			for _, i := range pi.sendRequests {
-				sr := i.(*sendRequest)
-				if sr.msg != nil {
-					size := len(sr.msg.Payload)
-					p.releaseSemaphoreAndMem(int64(size))
-					p.metrics.MessagesPending.Dec()
-					p.metrics.BytesPending.Sub(float64(size))
-					p.metrics.PublishErrorsTimeout.Inc()
-					p.log.WithError(errSendTimeout).
-						WithField("size", size).
-						WithField("properties", sr.msg.Properties)
-				}
-
-				if sr.callback != nil {
-					sr.callbackOnce.Do(func() {
-						sr.callback(nil, sr.msg, errSendTimeout)
-					})
-				}
-			}
-
-			// flag the send has completed with error, flush make no effect
-			pi.Complete()
+			p.failPendingItem(pi, errSendTimeout)
 			pi.Unlock()
 
 			// finally reached the last view item, current iteration ends
@@ -967,8 +1169,60 @@ func (p *partitionProducer) failTimeoutMessages() {
 	}
 }
 
+// failPendingItem fails the send requests of an item polled from the pending queue, the item must be locked
+func (p *partitionProducer) failPendingItem(pi *pendingItem, err error) {
+	for _, i := range pi.sendRequests {
+		sr := i.(*sendRequest)
+		if sr.msg != nil {
+			size := len(sr.msg.Payload)
+			p.releaseSemaphoreAndMem(int64(size))
+			p.metrics.MessagesPending.Dec()
+			p.metrics.BytesPending.Sub(float64(size))
+			if err == errSendTimeout {
+				p.metrics.PublishErrorsTimeout.Inc()
+			}
+			p.log.WithError(err).
+				WithField("size", size).
+				WithField("properties", sr.msg.Properties)
+		}
+
+		if sr.callback != nil {
+			sr.callbackOnce.Do(func() {
+				sr.callback(nil, sr.msg, err)
+			})
+		}
+	}
+
+	// flag the send has completed with error, flush make no effect
+	pi.Complete()
+}
+
+// disarmBatchFlushTimer stops the batch timer, it is armed again by the next message added to the batch
+func (p *partitionProducer) disarmBatchFlushTimer() {
+	p.batchStartTime = time.Time{}
+	if !p.batchFlushTimer.Stop() {
+		select {
+		case <-p.batchFlushTimer.C:
+		default:
+		}
+	}
+}
+
 func (p *partitionProducer) internalFlushCurrentBatches() {
-	batchesData, sequenceIDs, callbacks, errs := p.batchBuilder.FlushBatches()
+	p.internalFlushEvictedBatches()
+	p.sendBatches(p.batchBuilder.FlushBatches())
+}
+
+// internalFlushEvictedBatches sends the key batches the batch builder flushed to bound its number of keys
+func (p *partitionProducer) internalFlushEvictedBatches() {
+	if limiter, ok := p.batchBuilder.(internal.KeyBatchLimiter); ok {
+		p.sendBatches(limiter.FlushEvicted())
+	}
+}
+
+func (p *partitionProducer) sendBatches(
+	batchesData []internal.Buffer, sequenceIDs []uint64, callbacks [][]interface{}, errs []error,
+) {
 	if batchesData == nil {
 		return
 	}
@@ -999,9 +1253,8 @@ func (p *partitionProducer) internalFlushCurrentBatches() {
 			sequenceID:   sequenceIDs[i],
 			sendRequests: callbacks[i],
 		})
-		p._getConn().WriteData(batchesData[i])
+		p.writeData(batchesData[i])
 	}
-
 }
 
 func (p *partitionProducer) internalFlush(fr *flushRequest) {
@@ -1062,6 +1315,14 @@ func (p *partitionProducer) Send(ctx context.Context, msg *ProducerMessage) (Mes
 
 func (p *partitionProducer) SendAsync(ctx context.Context, msg *ProducerMessage,
 	callback func(MessageID, *ProducerMessage, error)) {
+	if callback != nil && p.callbacks != nil {
+		userCallback := callback
+		callback = func(id MessageID, msg *ProducerMessage, err error) {
+			p.callbacks.execute(p.topic, func() {
+				userCallback(id, msg, err)
+			})
+		}
+	}
 	p.internalSendAsync(ctx, msg, callback, false)
 }
 
@@ -1073,6 +1334,15 @@ func (p *partitionProducer) internalSendAsync(ctx context.Context, msg *Producer
 		return
 	}
 
+	if msg.Transaction != nil {
+		txnCallback, err := p.registerTxnSend(msg.Transaction, callback)
+		if err != nil {
+			callback(nil, msg, err)
+			return
+		}
+		callback = txnCallback
+	}
+
 	// bc only works when DisableBlockIfQueueFull is false
 	bc := make(chan struct{})
 
@@ -1089,9 +1359,10 @@ func (p *partitionProducer) internalSendAsync(ctx context.Context, msg *Producer
 		blockCh:          bc,
 		closeBlockChOnce: &sync.Once{},
 	}
+	injectContext(ctx, p.client.propagators, msg)
 	p.options.Interceptors.BeforeSend(p, msg)
 
-	p.eventsChan <- sr
+	p.eventsQueue.Put(sr)
 
 	if !p.options.DisableBlockIfQueueFull {
 		// block if queue full
@@ -1099,6 +1370,33 @@ func (p *partitionProducer) internalSendAsync(ctx context.Context, msg *Producer
 	}
 }
 
+// registerTxnSend registers the send operation with the transaction and returns a callback
+// which completes the operation before invoking the given callback
+func (p *partitionProducer) registerTxnSend(t Transaction,
+	callback func(MessageID, *ProducerMessage, error)) (func(MessageID, *ProducerMessage, error), error) {
+	txn, err := toTransaction(t)
+	if err != nil {
+		return nil, err
+	}
+	if err := txn.registerSendOrAckOp(); err != nil {
+		return nil, err
+	}
+	if err := txn.registerProducerTopic(p.topic); err != nil {
+		txn.endSendOrAckOp(err)
+		return nil, err
+	}
+
+	var once sync.Once
+	return func(id MessageID, msg *ProducerMessage, err error) {
+		once.Do(func() {
+			txn.endSendOrAckOp(err)
+		})
+		if callback != nil {
+			callback(id, msg, err)
+		}
+	}, nil
+}
+
 func (p *partitionProducer) ReceivedSendReceipt(response *pb.CommandSendReceipt) {
 	pi, ok := p.pendingQueue.Peek().(*pendingItem)
 
@@ -1151,7 +1449,7 @@ func (p *partitionProducer) ReceivedSendReceipt(response *pb.CommandSendReceipt)
 				p.metrics.BytesPending.Sub(payloadSize)
 			}
 
-			if sr.callback != nil || len(p.options.Interceptors) > 0 {
+			if sr.callback != nil || len(p.options.Interceptors) > 0 || p.client.audit != nil {
 				msgID := newMessageID(
 					int64(response.MessageId.GetLedgerId()),
 					int64(response.MessageId.GetEntryId()),
@@ -1189,6 +1487,7 @@ func (p *partitionProducer) ReceivedSendReceipt(response *pb.CommandSendReceipt)
 						sr.callback(msgID, sr.msg, nil)
 					}
 					p.options.Interceptors.OnSendAcknowledgement(p, sr.msg, msgID)
+					p.client.audit.produced(p.topic, msgID, sr.msg)
 				}
 			}
 		}
@@ -1226,11 +1525,16 @@ func (p *partitionProducer) internalClose(req *closeProducer) {
 
 	p.setProducerState(producerClosed)
 	p._getConn().UnregisterListener(p.producerID)
-	p.batchFlushTicker.Stop()
+	p.batchFlushTimer.Stop()
 
 	close(p.closeCh)
 }
 
+func (p *partitionProducer) Republish(ctx context.Context, msg Message,
+	transform func(*ProducerMessage)) (MessageID, error) {
+	return republish(ctx, p, msg, transform)
+}
+
 func (p *partitionProducer) LastSequenceID() int64 {
 	return atomic.LoadInt64(&p.lastSequenceID)
 }
@@ -1240,11 +1544,81 @@ func (p *partitionProducer) Flush() error {
 		doneCh: make(chan struct{}),
 		err:    nil,
 	}
-	p.eventsChan <- flushReq
+	p.eventsQueue.Put(flushReq)
 
 	// wait for the flush request to complete
-	<-flushReq.doneCh
-	return flushReq.err
+	if p.client.flushTimeout <= 0 {
+		<-flushReq.doneCh
+		return flushReq.err
+	}
+	timer := time.NewTimer(p.client.flushTimeout)
+	defer timer.Stop()
+	select {
+	case <-flushReq.doneCh:
+		return flushReq.err
+	case <-timer.C:
+		return newError(TimeoutError, fmt.Sprintf("flush not completed within %v", p.client.flushTimeout))
+	}
+}
+
+func (p *partitionProducer) NumPartitions() uint32 {
+	return 1
+}
+
+func (p *partitionProducer) PartitionFor(msg *ProducerMessage) int {
+	return 0
+}
+
+func (p *partitionProducer) WarmUp(ctx context.Context) error {
+	if p.getProducerState() != producerReady {
+		return errProducerClosed
+	}
+
+	doneCh := make(chan error, 1)
+	go func() {
+		doneCh <- p.warmUp()
+	}()
+
+	select {
+	case err := <-doneCh:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (p *partitionProducer) warmUp() error {
+	lr, err := p.client.lookupService.Lookup(p.topic)
+	if err != nil {
+		p.log.WithError(err).Warn("Failed to lookup topic during warm-up")
+		return err
+	}
+
+	cnx, err := p.client.cnxPool.GetConnectionWithAuth(lr.LogicalAddr, lr.PhysicalAddr,
+		authProviderOf(p.options.Authentication))
+	if err != nil {
+		p.log.WithError(err).Warn("Failed to connect to broker during warm-up")
+		return err
+	}
+
+	if !p.options.WarmUpRoundTrip {
+		return nil
+	}
+
+	id := p.client.rpcClient.NewRequestID()
+	res, err := p.client.rpcClient.RequestOnCnx(cnx, id, pb.BaseCommand_LOOKUP, &pb.CommandLookupTopic{
+		RequestId:     proto.Uint64(id),
+		Topic:         proto.String(p.topic),
+		Authoritative: proto.Bool(true),
+	})
+	if err != nil {
+		p.log.WithError(err).Warn("Failed to send no-op request during warm-up")
+		return err
+	}
+	if lr := res.Response.LookupTopicResponse; lr != nil && lr.GetResponse() == pb.CommandLookupTopicResponse_Failed {
+		return errors.New(lr.GetError().String())
+	}
+	return nil
 }
 
 func (p *partitionProducer) getProducerState() producerState {
@@ -1268,7 +1642,7 @@ func (p *partitionProducer) Close() {
 	}
 
 	cp := &closeProducer{doneCh: make(chan struct{})}
-	p.eventsChan <- cp
+	p.eventsQueue.Put(cp)
 
 	// wait for close producer request to complete
 	<-cp.doneCh
@@ -1287,6 +1661,7 @@ type sendRequest struct {
 	chunkID          int
 	uuid             string
 	chunkRecorder    *chunkRecorder
+	sequenceID       uint64
 }
 
 // stopBlock can be invoked multiple times safety
@@ -1350,11 +1725,13 @@ func (p *partitionProducer) canAddToQueue(sr *sendRequest, uncompressedPayloadSi
 		}
 
 	} else {
-		if !p.publishSemaphore.Acquire(sr.ctx) {
+		if !p.waitOrFlush(sr.ctx, p.publishSemaphore.Acquire) {
 			sr.callback(nil, sr.msg, errContextExpired)
 			return false
 		}
-		if !p.client.memLimit.ReserveMemory(sr.ctx, uncompressedPayloadSize) {
+		if !p.waitOrFlush(sr.ctx, func(ctx context.Context) bool {
+			return p.client.memLimit.ReserveMemory(ctx, uncompressedPayloadSize)
+		}) {
 			p.publishSemaphore.Release()
 			sr.callback(nil, sr.msg, errContextExpired)
 			return false
@@ -1365,6 +1742,26 @@ func (p *partitionProducer) canAddToQueue(sr *sendRequest, uncompressedPayloadSi
 	return true
 }
 
+// waitOrFlush waits for room in the pending queue or in the memory limit. The messages of the current batch hold
+// room which is only released once they are sent, so the batch is flushed if it stays unflushed for
+// maxUnflushedDuration while waiting.
+func (p *partitionProducer) waitOrFlush(ctx context.Context, wait func(ctx context.Context) bool) bool {
+	if !p.batchStartTime.IsZero() {
+		flushCtx, cancel := context.WithDeadline(ctx, p.batchStartTime.Add(p.maxUnflushedDuration))
+		ok := wait(flushCtx)
+		cancel()
+		if ok {
+			return true
+		}
+		if ctx.Err() != nil {
+			return false
+		}
+		p.log.Debug("Flushing the current batch while waiting for room in the pending queue")
+		p.internalFlushCurrentBatch()
+	}
+	return wait(ctx)
+}
+
 type chunkRecorder struct {
 	chunkedMsgID chunkMessageID
 }
@@ -0,0 +1,116 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsar
+
+import (
+	"context"
+	"time"
+)
+
+// ErrSendCanceled is the error the sends canceled by Producer.CancelPending fail with
+var ErrSendCanceled = newError(SendCanceled, "pending message send canceled")
+
+// PendingMessage summarizes a message sent by a producer and not acknowledged by the broker yet
+type PendingMessage struct {
+	// Topic is the topic, or the partition of the topic, the message is sent to
+	Topic string
+	// SequenceID is the sequence id assigned to the message
+	SequenceID int64
+	// Key is the key of the message
+	Key string
+	// Size is the size of the payload of the message in bytes
+	Size int
+	// SentAt is the time the message was written to the connection
+	SentAt time.Time
+}
+
+type cancelPendingRequest struct {
+	doneCh chan struct{}
+}
+
+func (p *partitionProducer) Pending() []PendingMessage {
+	items := p.pendingQueue.ReadableSlice()
+	pending := make([]PendingMessage, 0, len(items))
+	for _, item := range items {
+		pi := item.(*pendingItem)
+		pi.Lock()
+		if !pi.completed {
+			for _, i := range pi.sendRequests {
+				sr := i.(*sendRequest)
+				// the flush requests have no message, a chunked message is summarized by its first chunk
+				if sr.msg == nil || sr.chunkID > 0 {
+					continue
+				}
+				pending = append(pending, PendingMessage{
+					Topic:      p.topic,
+					SequenceID: int64(sr.sequenceID),
+					Key:        sr.msg.Key,
+					Size:       len(sr.msg.Payload),
+					SentAt:     pi.sentAt,
+				})
+			}
+		}
+		pi.Unlock()
+	}
+	return pending
+}
+
+func (p *partitionProducer) CancelPending(ctx context.Context) error {
+	if p.getProducerState() != producerReady {
+		return errProducerClosed
+	}
+
+	req := &cancelPendingRequest{doneCh: make(chan struct{})}
+	if err := p.eventsQueue.PutContext(ctx, req); err != nil {
+		return err
+	}
+
+	select {
+	case <-req.doneCh:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (p *partitionProducer) internalCancelPending(req *cancelPendingRequest) {
+	defer close(req.doneCh)
+
+	// the messages of the current batch are sent first to be failed along with the others
+	if p.batchBuilder != nil {
+		p.internalFlushCurrentBatch()
+	}
+
+	canceled := 0
+	for {
+		item := p.pendingQueue.Poll()
+		if item == nil {
+			break
+		}
+		pi := item.(*pendingItem)
+		pi.Lock()
+		if !pi.completed {
+			canceled += len(pi.sendRequests)
+			p.failPendingItem(pi, ErrSendCanceled)
+		}
+		pi.Unlock()
+	}
+	if canceled > 0 {
+		p.log.Warnf("Canceled %d pending send requests", canceled)
+	}
+}
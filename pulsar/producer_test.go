@@ -30,6 +30,8 @@ import (
 	"time"
 
 	"github.com/apache/pulsar-client-go/pulsar/internal"
+	pb "github.com/apache/pulsar-client-go/pulsar/internal/pulsar_proto"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/stretchr/testify/assert"
 
 	"github.com/apache/pulsar-client-go/pulsar/crypto"
@@ -154,6 +156,12 @@ func TestProducerAsyncSend(t *testing.T) {
 	assert.Equal(t, 0, errors.Size())
 }
 
+func TestCompressionTypeOf(t *testing.T) {
+	assert.Equal(t, pb.CompressionType_ZSTD, compressionTypeOf(ZSTD))
+	assert.Equal(t, internal.CompressionTypeLZ4Frame, compressionTypeOf(LZ4Frame))
+	assert.Equal(t, pb.CompressionType_LZ4, internal.WireCompressionType(compressionTypeOf(LZ4Frame)))
+}
+
 func TestProducerCompression(t *testing.T) {
 	type testProvider struct {
 		name            string
@@ -196,6 +204,48 @@ func TestProducerCompression(t *testing.T) {
 	}
 }
 
+func TestProducerDisableCompression(t *testing.T) {
+	client, err := NewClient(ClientOptions{
+		URL: serviceURL,
+	})
+	assert.NoError(t, err)
+	defer client.Close()
+
+	topic := newTopicName()
+	consumer, err := client.Subscribe(ConsumerOptions{
+		Topic:            topic,
+		SubscriptionName: "sub-1",
+	})
+	assert.NoError(t, err)
+	defer consumer.Close()
+
+	producer, err := client.CreateProducer(ProducerOptions{
+		Topic:           topic,
+		CompressionType: LZ4,
+	})
+	assert.NoError(t, err)
+	defer producer.Close()
+
+	ctx := context.Background()
+	for i := 0; i < 10; i++ {
+		producer.SendAsync(ctx, &ProducerMessage{
+			Payload:            []byte(fmt.Sprintf("msg-%d", i)),
+			DisableCompression: i%2 == 0,
+		}, func(id MessageID, message *ProducerMessage, err error) {
+			assert.NoError(t, err)
+		})
+	}
+	assert.NoError(t, producer.Flush())
+
+	for i := 0; i < 10; i++ {
+		msg, err := consumer.Receive(ctx)
+		assert.NoError(t, err)
+		// the messages sent outside of the batches keep their order
+		assert.Equal(t, fmt.Sprintf("msg-%d", i), string(msg.Payload()))
+		consumer.Ack(msg)
+	}
+}
+
 func TestProducerLastSequenceID(t *testing.T) {
 	client, err := NewClient(ClientOptions{
 		URL: serviceURL,
@@ -840,9 +890,10 @@ func TestDelayAbsolute(t *testing.T) {
 	assert.Nil(t, err)
 	defer consumer.Close()
 
+	deliverAt := time.Now().Add(3 * time.Second)
 	ID, err := producer.Send(context.Background(), &ProducerMessage{
 		Payload:   []byte("test"),
-		DeliverAt: time.Now().Add(3 * time.Second),
+		DeliverAt: deliverAt,
 	})
 	assert.Nil(t, err)
 	assert.NotNil(t, ID)
@@ -858,6 +909,8 @@ func TestDelayAbsolute(t *testing.T) {
 	msg, err = consumer.Receive(ctx)
 	assert.Nil(t, err)
 	assert.NotNil(t, msg)
+	assert.Equal(t, deliverAt.UnixNano()/int64(time.Millisecond),
+		msg.DeliverAtTime().UnixNano()/int64(time.Millisecond))
 	canc()
 }
 
@@ -1905,3 +1958,311 @@ func TestMemLimitContextCancel(t *testing.T) {
 	})
 	assert.NoError(t, err)
 }
+
+func TestNewRepublishMessage(t *testing.T) {
+	eventTime := time.Now()
+	msg := &message{
+		payLoad:     []byte("payload"),
+		key:         "key",
+		orderingKey: "ordering-key",
+		properties:  map[string]string{"a": "1"},
+		eventTime:   eventTime,
+	}
+
+	producerMsg, err := newRepublishMessage(msg)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("payload"), producerMsg.Payload)
+	assert.Equal(t, "key", producerMsg.Key)
+	assert.Equal(t, "ordering-key", producerMsg.OrderingKey)
+	assert.Equal(t, eventTime, producerMsg.EventTime)
+	assert.Equal(t, map[string]string{"a": "1"}, producerMsg.Properties)
+	assert.Nil(t, producerMsg.Schema)
+
+	// the properties of the consumed message are not modified by the transformations
+	producerMsg.Properties["b"] = "2"
+	assert.Equal(t, map[string]string{"a": "1"}, msg.Properties())
+
+	_, err = newRepublishMessage(nil)
+	assert.NotNil(t, err)
+}
+
+func TestProducerRepublish(t *testing.T) {
+	client, err := NewClient(ClientOptions{
+		URL: serviceURL,
+	})
+	assert.NoError(t, err)
+	defer client.Close()
+
+	sourceTopic := newTopicName()
+	targetTopic := newTopicName()
+	ctx := context.Background()
+
+	sourceProducer, err := client.CreateProducer(ProducerOptions{
+		Topic: sourceTopic,
+	})
+	assert.Nil(t, err)
+	defer sourceProducer.Close()
+
+	targetProducer, err := client.CreateProducer(ProducerOptions{
+		Topic: targetTopic,
+	})
+	assert.Nil(t, err)
+	defer targetProducer.Close()
+
+	sourceConsumer, err := client.Subscribe(ConsumerOptions{
+		Topic:            sourceTopic,
+		SubscriptionName: "sub",
+	})
+	assert.Nil(t, err)
+	defer sourceConsumer.Close()
+
+	targetConsumer, err := client.Subscribe(ConsumerOptions{
+		Topic:            targetTopic,
+		SubscriptionName: "sub",
+	})
+	assert.Nil(t, err)
+	defer targetConsumer.Close()
+
+	eventTime := time.UnixMilli(1600000000000)
+	_, err = sourceProducer.Send(ctx, &ProducerMessage{
+		Payload:     []byte("hello"),
+		Key:         "key",
+		OrderingKey: "ordering-key",
+		Properties:  map[string]string{"a": "1"},
+		EventTime:   eventTime,
+	})
+	assert.Nil(t, err)
+
+	msg, err := sourceConsumer.Receive(ctx)
+	assert.Nil(t, err)
+
+	_, err = targetProducer.Republish(ctx, msg, func(producerMsg *ProducerMessage) {
+		producerMsg.Properties["relayed"] = "true"
+	})
+	assert.Nil(t, err)
+
+	republished, err := targetConsumer.Receive(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("hello"), republished.Payload())
+	assert.Equal(t, "key", republished.Key())
+	assert.Equal(t, "ordering-key", republished.OrderingKey())
+	assert.Equal(t, eventTime, republished.EventTime())
+	assert.Equal(t, map[string]string{"a": "1", "relayed": "true"}, republished.Properties())
+}
+
+type flushCountingBatchBuilder struct {
+	internal.BatchBuilder
+	flushes int
+}
+
+func (b *flushCountingBatchBuilder) IsMultiBatches() bool {
+	return false
+}
+
+func (b *flushCountingBatchBuilder) Flush() (internal.Buffer, uint64, []interface{}, error) {
+	b.flushes++
+	return nil, 0, nil, nil
+}
+
+func TestProducerWaitOrFlush(t *testing.T) {
+	batchBuilder := &flushCountingBatchBuilder{}
+	p := &partitionProducer{
+		log:                  plog.DefaultNopLogger(),
+		batchBuilder:         batchBuilder,
+		batchFlushTimer:      time.NewTimer(time.Hour),
+		maxUnflushedDuration: 50 * time.Millisecond,
+	}
+	defer p.batchFlushTimer.Stop()
+
+	// the room is released once the batch is sent
+	flushed := func(ctx context.Context) bool {
+		if batchBuilder.flushes > 0 {
+			return true
+		}
+		<-ctx.Done()
+		return false
+	}
+
+	// the batch is empty, nothing to flush
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	assert.False(t, p.waitOrFlush(ctx, flushed))
+	cancel()
+	assert.Equal(t, 0, batchBuilder.flushes)
+
+	p.batchStartTime = time.Now()
+	start := time.Now()
+	assert.True(t, p.waitOrFlush(context.Background(), flushed))
+	assert.Equal(t, 1, batchBuilder.flushes)
+	assert.True(t, time.Since(start) >= 40*time.Millisecond)
+	assert.True(t, p.batchStartTime.IsZero())
+}
+
+func TestProducerPendingAndCancel(t *testing.T) {
+	p := &partitionProducer{
+		client:           &client{memLimit: internal.NewMemoryLimitController(1000)},
+		topic:            "my-topic",
+		log:              plog.DefaultNopLogger(),
+		pendingQueue:     internal.NewBlockingQueue(10),
+		eventsQueue:      internal.NewRingBuffer(10),
+		publishSemaphore: internal.NewSemaphore(10),
+		metrics: internal.NewMetricsProvider(4, map[string]string{}, prometheus.NewRegistry()).
+			GetLeveledMetrics("my-topic"),
+	}
+
+	var errs []error
+	newRequest := func(key string, sequenceID uint64) *sendRequest {
+		assert.True(t, p.publishSemaphore.TryAcquire())
+		return &sendRequest{
+			msg:          &ProducerMessage{Key: key, Payload: []byte("hello")},
+			sequenceID:   sequenceID,
+			callbackOnce: &sync.Once{},
+			callback: func(_ MessageID, _ *ProducerMessage, err error) {
+				errs = append(errs, err)
+			},
+		}
+	}
+	sentAt := time.Now()
+	p.pendingQueue.Put(&pendingItem{sentAt: sentAt, sequenceID: 1,
+		sendRequests: []interface{}{newRequest("a", 1), newRequest("b", 2)}})
+	p.pendingQueue.Put(&pendingItem{sentAt: sentAt, sequenceID: 3,
+		sendRequests: []interface{}{newRequest("c", 3)}})
+
+	assert.Equal(t, []PendingMessage{
+		{Topic: "my-topic", SequenceID: 1, Key: "a", Size: 5, SentAt: sentAt},
+		{Topic: "my-topic", SequenceID: 2, Key: "b", Size: 5, SentAt: sentAt},
+		{Topic: "my-topic", SequenceID: 3, Key: "c", Size: 5, SentAt: sentAt},
+	}, p.Pending())
+
+	// the producer is not ready
+	assert.ErrorIs(t, p.CancelPending(context.Background()), errProducerClosed)
+
+	p.internalCancelPending(&cancelPendingRequest{doneCh: make(chan struct{})})
+	assert.Equal(t, []error{ErrSendCanceled, ErrSendCanceled, ErrSendCanceled}, errs)
+	assert.Equal(t, SendCanceled, errs[0].(*Error).Result())
+	assert.Empty(t, p.Pending())
+	assert.Equal(t, 0, p.pendingQueue.Size())
+	// the permits of the canceled messages are released
+	for i := 0; i < 10; i++ {
+		assert.True(t, p.publishSemaphore.TryAcquire())
+	}
+
+	// the events queue is full
+	p.setProducerState(producerReady)
+	for i := 0; i < p.eventsQueue.Cap(); i++ {
+		p.eventsQueue.Put(&flushRequest{})
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, p.CancelPending(ctx), context.DeadlineExceeded)
+}
+
+func TestProducerWarmUp(t *testing.T) {
+	client, err := NewClient(ClientOptions{
+		URL: serviceURL,
+	})
+	assert.NoError(t, err)
+	defer client.Close()
+
+	topicAdminURL := "admin/v2/persistent/public/default/TestProducerWarmUp/partitions"
+	err = httpPut(topicAdminURL, 3)
+	defer httpDelete(topicAdminURL)
+	assert.NoError(t, err)
+
+	producer, err := client.CreateProducer(ProducerOptions{
+		Topic:           "persistent://public/default/TestProducerWarmUp",
+		WarmUpRoundTrip: true,
+	})
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	assert.NoError(t, producer.WarmUp(ctx))
+
+	_, err = producer.Send(ctx, &ProducerMessage{Payload: []byte("hello")})
+	assert.NoError(t, err)
+
+	producer.Close()
+	assert.ErrorIs(t, producer.WarmUp(ctx), errProducerClosed)
+}
+
+func TestProducerPartitionFor(t *testing.T) {
+	p := &producer{
+		options:       &ProducerOptions{},
+		numPartitions: 4,
+		hashFunc:      internal.JavaStringHash,
+		messageRouter: func(*ProducerMessage, TopicMetadata) int {
+			t.Fatal("the default router must not be called")
+			return 0
+		},
+	}
+
+	msg := &ProducerMessage{Key: "my-key"}
+	assert.Equal(t, int(internal.JavaStringHash("my-key")%4), p.PartitionFor(msg))
+
+	// the ordering key takes precedence over the key
+	msg.OrderingKey = "my-ordering-key"
+	assert.Equal(t, int(internal.JavaStringHash("my-ordering-key")%4), p.PartitionFor(msg))
+
+	assert.Equal(t, -1, p.PartitionFor(&ProducerMessage{}))
+
+	p.numPartitions = 1
+	assert.Equal(t, 0, p.PartitionFor(&ProducerMessage{}))
+
+	p.numPartitions = 4
+	p.options.MessageRouter = func(msg *ProducerMessage, metadata TopicMetadata) int {
+		return int(metadata.NumPartitions()) - 1
+	}
+	p.messageRouter = p.options.MessageRouter
+	assert.Equal(t, 3, p.PartitionFor(&ProducerMessage{}))
+}
+
+func TestProducerPinSchemaVersion(t *testing.T) {
+	schema := NewJSONSchema(`{"type":"string"}`, nil)
+	other := NewJSONSchema(`{"type":"int"}`, nil)
+	p := &partitionProducer{
+		client: &client{
+			lookupService: &mockTopicsLookupService{schemas: map[string]*pb.Schema{
+				string(NewSchemaVersion(1)): {Type: pb.Schema_Json.Enum(), SchemaData: []byte(other.Schema)},
+				string(NewSchemaVersion(2)): {Type: pb.Schema_Json.Enum(), SchemaData: []byte(schema.Schema)},
+			}},
+		},
+		topic:       "my-topic",
+		log:         plog.DefaultNopLogger(),
+		schemaInfo:  schema.GetSchemaInfo(),
+		schemaCache: newSchemaCache(),
+	}
+
+	// the version must hold the schema of the producer
+	assert.Error(t, p.pinSchemaVersion(NewSchemaVersion(1)))
+	assert.Error(t, p.pinSchemaVersion(NewSchemaVersion(3)))
+	assert.Nil(t, p.schemaCache.Get(schema.GetSchemaInfo()))
+
+	assert.NoError(t, p.pinSchemaVersion(NewSchemaVersion(2)))
+	assert.Equal(t, NewSchemaVersion(2), p.schemaCache.Get(schema.GetSchemaInfo()))
+}
+
+func TestProducerReplicationMetadata(t *testing.T) {
+	p := &partitionProducer{producerName: "my-producer"}
+
+	msg := &ProducerMessage{}
+	assert.Nil(t, p.genMetadata(msg, 0, time.Time{}).ReplicateTo)
+
+	msg = &ProducerMessage{ReplicationClusters: []string{"us-east", "us-west"}}
+	assert.Equal(t, []string{"us-east", "us-west"}, p.genMetadata(msg, 0, time.Time{}).ReplicateTo)
+
+	msg = &ProducerMessage{ReplicationClusters: []string{"us-east"}, DisableReplication: true}
+	assert.Equal(t, []string{"__local__"}, p.genMetadata(msg, 0, time.Time{}).ReplicateTo)
+	assert.Equal(t, []string{"us-east"}, msg.ReplicationClusters, "the message is left untouched")
+}
+
+func TestProducerFlushTimeout(t *testing.T) {
+	p := &partitionProducer{
+		client:      &client{flushTimeout: 10 * time.Millisecond},
+		eventsQueue: internal.NewRingBuffer(10),
+	}
+
+	// the flush request is never processed
+	err := p.Flush()
+	assert.Error(t, err)
+	assert.Equal(t, TimeoutError, err.(*Error).Result())
+}
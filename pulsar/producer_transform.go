@@ -0,0 +1,68 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsar
+
+import (
+	"fmt"
+	"time"
+)
+
+// PayloadTransformer is a stage of ProducerOptions.PayloadTransformers, transforming the payload of the messages
+// before they are batched, compressed and encrypted by the producer, e.g. to encrypt some fields of the payload or
+// to frame it in a custom format.
+type PayloadTransformer interface {
+	// Name identifies the stage in the metrics and in the errors
+	Name() string
+
+	// Transform returns the payload to send in place of payload, the payload of the message or the output of the
+	// previous stage, and the properties to add to the message, if any, e.g. to describe the format of the
+	// payload to the consumers. The message must not be modified.
+	Transform(msg *ProducerMessage, payload []byte) ([]byte, map[string]string, error)
+}
+
+// transformPayload runs the payload through the PayloadTransformers, it returns the message with the properties
+// added by the stages, a copy if any was added
+func (p *partitionProducer) transformPayload(msg *ProducerMessage, payload []byte) (
+	*ProducerMessage, []byte, error) {
+	var properties map[string]string
+	for _, stage := range p.options.PayloadTransformers {
+		start := time.Now()
+		transformed, added, err := stage.Transform(msg, payload)
+		if err != nil {
+			p.metrics.PayloadTransformErrors.WithLabelValues(stage.Name()).Inc()
+			return nil, nil, fmt.Errorf("payload transformer %s failed: %w", stage.Name(), err)
+		}
+		p.metrics.PayloadTransformLatency.WithLabelValues(stage.Name()).Observe(time.Since(start).Seconds())
+		payload = transformed
+		if len(added) > 0 && properties == nil {
+			properties = make(map[string]string, len(msg.Properties)+len(added))
+			for k, v := range msg.Properties {
+				properties[k] = v
+			}
+		}
+		for k, v := range added {
+			properties[k] = v
+		}
+	}
+	if properties == nil {
+		return msg, payload, nil
+	}
+	copied := *msg
+	copied.Properties = properties
+	return &copied, payload, nil
+}
@@ -0,0 +1,85 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsar
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/apache/pulsar-client-go/pulsar/internal"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+type testTransformer struct {
+	name       string
+	transform  func(payload []byte) ([]byte, error)
+	properties map[string]string
+}
+
+func (t *testTransformer) Name() string {
+	return t.name
+}
+
+func (t *testTransformer) Transform(msg *ProducerMessage, payload []byte) ([]byte, map[string]string, error) {
+	transformed, err := t.transform(payload)
+	return transformed, t.properties, err
+}
+
+func TestProducerTransformPayload(t *testing.T) {
+	upper := &testTransformer{name: "upper", transform: func(payload []byte) ([]byte, error) {
+		return bytes.ToUpper(payload), nil
+	}}
+	frame := &testTransformer{
+		name: "frame",
+		transform: func(payload []byte) ([]byte, error) {
+			return append([]byte{0x01}, payload...), nil
+		},
+		properties: map[string]string{"format": "framed-v1"},
+	}
+	p := &partitionProducer{
+		options: &ProducerOptions{PayloadTransformers: []PayloadTransformer{upper, frame}},
+		metrics: internal.NewMetricsProvider(4, map[string]string{}, prometheus.NewRegistry()).
+			GetLeveledMetrics("my-topic"),
+	}
+
+	msg := &ProducerMessage{Properties: map[string]string{"a": "b"}}
+	transformed, payload, err := p.transformPayload(msg, []byte("hello"))
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("\x01HELLO"), payload)
+	assert.Equal(t, map[string]string{"a": "b", "format": "framed-v1"}, transformed.Properties)
+	// the message of the application is not modified
+	assert.Equal(t, map[string]string{"a": "b"}, msg.Properties)
+	assert.Equal(t, 2, testutil.CollectAndCount(p.metrics.PayloadTransformLatency.(prometheus.Collector)))
+
+	// the message is unchanged without properties to add
+	p.options.PayloadTransformers = []PayloadTransformer{upper}
+	transformed, _, err = p.transformPayload(msg, []byte("hello"))
+	assert.NoError(t, err)
+	assert.Same(t, msg, transformed)
+
+	failing := &testTransformer{name: "failing", transform: func(payload []byte) ([]byte, error) {
+		return nil, errors.New("invalid payload")
+	}}
+	p.options.PayloadTransformers = []PayloadTransformer{upper, failing}
+	_, _, err = p.transformPayload(msg, []byte("hello"))
+	assert.EqualError(t, err, "payload transformer failing failed: invalid payload")
+	assert.Equal(t, float64(1), testutil.ToFloat64(p.metrics.PayloadTransformErrors.WithLabelValues("failing")))
+}
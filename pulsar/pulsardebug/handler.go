@@ -0,0 +1,113 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package pulsardebug exposes the state of the registered Pulsar clients over HTTP and expvar, for tools that
+// don't scrape Prometheus metrics. Importing the package publishes the "pulsar" expvar variable, served by
+// expvar.Handler under /debug/vars, unless another package already published a variable with that name.
+package pulsardebug
+
+import (
+	"bytes"
+	"encoding/json"
+	"expvar"
+	"net/http"
+	"sync"
+
+	"github.com/apache/pulsar-client-go/pulsar"
+)
+
+var registry = struct {
+	sync.RWMutex
+	clients map[string]pulsar.Client
+}{clients: make(map[string]pulsar.Client)}
+
+func init() {
+	publish("pulsar")
+}
+
+// publish publishes the state of the registered clients as the expvar variable with the given name, it returns
+// false without publishing when the name is already taken since expvar.Publish panics on duplicated names
+func publish(name string) bool {
+	if expvar.Get(name) != nil {
+		return false
+	}
+	expvar.Publish(name, expvar.Func(func() interface{} {
+		return states()
+	}))
+	return true
+}
+
+// Register makes the state of the client available under the given name, replacing the client previously
+// registered with that name
+func Register(name string, client pulsar.Client) {
+	registry.Lock()
+	defer registry.Unlock()
+	registry.clients[name] = client
+}
+
+// Unregister removes the client registered with the given name, it should be called before closing the client
+func Unregister(name string) {
+	registry.Lock()
+	defer registry.Unlock()
+	delete(registry.clients, name)
+}
+
+// Handler returns an HTTP handler responding with the state of the registered clients by name, see
+// pulsar.Client.DumpState. The "client" query parameter restricts the response to the state of one client.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if name := r.URL.Query().Get("client"); name != "" {
+			registry.RLock()
+			client, ok := registry.clients[name]
+			registry.RUnlock()
+			if !ok {
+				http.Error(w, "unknown client "+name, http.StatusNotFound)
+				return
+			}
+			pulsar.NewStateHandler(client).ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(states()); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
+
+// states returns the state of the registered clients by name
+func states() map[string]json.RawMessage {
+	registry.RLock()
+	clients := make(map[string]pulsar.Client, len(registry.clients))
+	for name, client := range registry.clients {
+		clients[name] = client
+	}
+	registry.RUnlock()
+
+	states := make(map[string]json.RawMessage, len(clients))
+	for name, client := range clients {
+		buf := &bytes.Buffer{}
+		if err := client.DumpState(buf); err != nil {
+			states[name], _ = json.Marshal(map[string]string{"error": err.Error()})
+			continue
+		}
+		states[name] = buf.Bytes()
+	}
+	return states
+}
@@ -0,0 +1,80 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsardebug
+
+import (
+	"encoding/json"
+	"errors"
+	"expvar"
+	"io"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/apache/pulsar-client-go/pulsar"
+	"github.com/stretchr/testify/assert"
+)
+
+type stateClient struct {
+	pulsar.Client
+	state string
+	err   error
+}
+
+func (c *stateClient) DumpState(w io.Writer) error {
+	if c.err != nil {
+		return c.err
+	}
+	_, err := io.WriteString(w, c.state)
+	return err
+}
+
+func TestHandler(t *testing.T) {
+	Register("orders", &stateClient{state: `{"producers":[]}`})
+	Register("payments", &stateClient{err: errors.New("boom")})
+	defer Unregister("orders")
+	defer Unregister("payments")
+
+	recorder := httptest.NewRecorder()
+	Handler().ServeHTTP(recorder, httptest.NewRequest("GET", "/debug/pulsar", nil))
+	assert.Equal(t, 200, recorder.Code)
+	var states map[string]map[string]interface{}
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &states))
+	assert.Equal(t, map[string]interface{}{"producers": []interface{}{}}, states["orders"])
+	assert.Equal(t, map[string]interface{}{"error": "boom"}, states["payments"])
+
+	recorder = httptest.NewRecorder()
+	Handler().ServeHTTP(recorder, httptest.NewRequest("GET", "/debug/pulsar?client=orders", nil))
+	assert.Equal(t, 200, recorder.Code)
+	assert.Equal(t, `{"producers":[]}`, recorder.Body.String())
+
+	recorder = httptest.NewRecorder()
+	Handler().ServeHTTP(recorder, httptest.NewRequest("GET", "/debug/pulsar?client=unknown", nil))
+	assert.Equal(t, 404, recorder.Code)
+
+	assert.Contains(t, expvar.Get("pulsar").String(), `"orders"`)
+}
+
+func TestPublishTakenName(t *testing.T) {
+	expvar.NewString("pulsar-taken").Set("other")
+
+	assert.NotPanics(t, func() {
+		assert.False(t, publish("pulsar-taken"))
+		assert.False(t, publish("pulsar"))
+	})
+	assert.Equal(t, `"other"`, expvar.Get("pulsar-taken").String())
+}
@@ -56,6 +56,11 @@ type ReaderOptions struct {
 	// Default is `false` and the reader will start from the "next" message
 	StartMessageIDInclusive bool
 
+	// ValidateStartMessageID, if true, checks at creation that the `StartMessageID` is not beyond the last message
+	// of the topic, e.g. a stale message id of a deleted and re-created topic or one of another topic, and fails
+	// with an InvalidConfiguration error rather than reading from an unexpected position. Default is false.
+	ValidateStartMessageID bool
+
 	// MessageChannel sets a `MessageChannel` for the consumer
 	// When a message is received, it will be pushed to the channel for consumption
 	MessageChannel chan ReaderMessage
@@ -92,6 +97,10 @@ type ReaderOptions struct {
 	// BackoffPolicy parameterize the following options in the reconnection logic to
 	// allow users to customize the reconnection logic (minBackoff, maxBackoff and jitterPercentage)
 	BackoffPolicy internal.BackoffPolicy
+
+	// Interceptors is a chain of interceptors. These interceptors will be called at some points defined in
+	// ReaderInterceptor interface.
+	Interceptors ReaderInterceptors
 }
 
 // Reader can be used to scan through all the messages currently available in a topic.
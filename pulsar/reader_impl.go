@@ -30,6 +30,9 @@ import (
 
 const (
 	defaultReceiverQueueSize = 1000
+
+	// readerMetricsSubscription labels the metrics of the readers without subscription name
+	readerMetricsSubscription = "reader"
 )
 
 type reader struct {
@@ -40,6 +43,20 @@ type reader struct {
 	lastMessageInBroker *trackingMessageID
 	log                 log.Logger
 	metrics             *internal.LeveledMetrics
+	interceptors        ReaderInterceptors
+}
+
+// readerSubscription returns the subscription of the reader and the subscription labeling its metrics, which is
+// fixed when the subscription name is random not to create new series for each reader
+func readerSubscription(options ReaderOptions) (string, string) {
+	if options.SubscriptionName != "" {
+		return options.SubscriptionName, options.SubscriptionName
+	}
+	prefix := options.SubscriptionRolePrefix
+	if prefix == "" {
+		prefix = "reader"
+	}
+	return prefix + "-" + generateRandomName(), readerMetricsSubscription
 }
 
 func newReader(client *client, options ReaderOptions) (Reader, error) {
@@ -51,30 +68,21 @@ func newReader(client *client, options ReaderOptions) (Reader, error) {
 		return nil, newError(InvalidConfiguration, "StartMessageID is required")
 	}
 
-	startMessageID := toTrackingMessageID(options.StartMessageID)
-	if startMessageID == nil {
-		// a custom type satisfying MessageID may not be a messageID or trackingMessageID
-		// so re-create messageID using its data
-		deserMsgID, err := deserializeMessageID(options.StartMessageID.Serialize())
-		if err != nil {
-			return nil, err
-		}
-		// de-serialized MessageID is a messageID
-		startMessageID = &trackingMessageID{
-			messageID:    deserMsgID.(*messageID),
-			receivedTime: time.Now(),
-		}
+	if err := client.checkTopicsExist(options.Topic); err != nil {
+		return nil, err
 	}
 
-	subscriptionName := options.SubscriptionName
-	if subscriptionName == "" {
-		subscriptionName = options.SubscriptionRolePrefix
-		if subscriptionName == "" {
-			subscriptionName = "reader"
-		}
-		subscriptionName += "-" + generateRandomName()
+	startMessageID, err := toStartMessageID(options.StartMessageID)
+	if err != nil {
+		return nil, err
+	}
+	// a reader reads a non-partitioned topic or a single partition
+	if err := checkStartMessageIDPartition(startMessageID, options.Topic, 1); err != nil {
+		return nil, err
 	}
 
+	subscriptionName, metricsSubscription := readerSubscription(options)
+
 	receiverQueueSize := options.ReceiverQueueSize
 	if receiverQueueSize <= 0 {
 		receiverQueueSize = defaultReceiverQueueSize
@@ -91,6 +99,16 @@ func newReader(client *client, options ReaderOptions) (Reader, error) {
 		options.Decryption.MessageCrypto = messageCrypto
 	}
 
+	if options.Decryption != nil {
+		configureKeyCache(options.Decryption)
+	}
+
+	if options.Decryption != nil && client.fipsMode {
+		if err := enableFIPSMessageCrypto(options.Decryption.MessageCrypto); err != nil {
+			return nil, err
+		}
+	}
+
 	consumerOptions := &partitionConsumerOpts{
 		topic:                      options.Topic,
 		consumerName:               options.Name,
@@ -99,7 +117,7 @@ func newReader(client *client, options ReaderOptions) (Reader, error) {
 		receiverQueueSize:          receiverQueueSize,
 		startMessageID:             startMessageID,
 		startMessageIDInclusive:    options.StartMessageIDInclusive,
-		subscriptionMode:           nonDurable,
+		subscriptionMode:           NonDurable,
 		readCompacted:              options.ReadCompacted,
 		metadata:                   options.Properties,
 		nackRedeliveryDelay:        defaultNackRedeliveryDelay,
@@ -107,13 +125,19 @@ func newReader(client *client, options ReaderOptions) (Reader, error) {
 		decryption:                 options.Decryption,
 		schema:                     options.Schema,
 		backoffPolicy:              options.BackoffPolicy,
+		validateStartMessageID:     options.ValidateStartMessageID,
+	}
+
+	if options.Interceptors == nil {
+		options.Interceptors = defaultReaderInterceptors
 	}
 
 	reader := &reader{
-		client:    client,
-		messageCh: make(chan ConsumerMessage),
-		log:       client.log.SubLogger(log.Fields{"topic": options.Topic}),
-		metrics:   client.metrics.GetLeveledMetrics(options.Topic),
+		client:       client,
+		messageCh:    make(chan ConsumerMessage),
+		interceptors: options.Interceptors,
+		log:          client.log.SubLogger(log.Fields{"topic": options.Topic}),
+		metrics:      client.resourceMetrics().GetLeveledConsumerMetrics(options.Topic, metricsSubscription),
 	}
 
 	// Provide dummy dlq router with not dlq policy
@@ -149,8 +173,10 @@ func (r *reader) Next(ctx context.Context) (Message, error) {
 			// it will specify the subscription position anyway
 			msgID := cm.Message.ID()
 			if mid := toTrackingMessageID(msgID); mid != nil {
+				r.interceptors.BeforeRead(ReaderMessage{Reader: r, Message: cm.Message})
 				r.pc.lastDequeuedMsg = mid
 				r.pc.AckID(mid)
+				r.interceptors.AfterRead(r, msgID)
 				return cm.Message, nil
 			}
 			return nil, newError(InvalidMessage, fmt.Sprintf("invalid message id type %T", msgID))
@@ -0,0 +1,43 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsar
+
+type ReaderInterceptor interface {
+	// BeforeRead This is called just before the message is returned by Reader.Next.
+	BeforeRead(message ReaderMessage)
+
+	// AfterRead This is called once the reader moved past the message, after it has been acknowledged
+	// on the reader subscription.
+	AfterRead(reader Reader, msgID MessageID)
+}
+
+type ReaderInterceptors []ReaderInterceptor
+
+func (x ReaderInterceptors) BeforeRead(message ReaderMessage) {
+	for i := range x {
+		x[i].BeforeRead(message)
+	}
+}
+
+func (x ReaderInterceptors) AfterRead(reader Reader, msgID MessageID) {
+	for i := range x {
+		x[i].AfterRead(reader, msgID)
+	}
+}
+
+var defaultReaderInterceptors = make(ReaderInterceptors, 0)
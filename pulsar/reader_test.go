@@ -20,6 +20,7 @@ package pulsar
 import (
 	"context"
 	"fmt"
+	"strings"
 	"testing"
 	"time"
 
@@ -49,6 +50,20 @@ func TestReaderConfigErrors(t *testing.T) {
 	assert.NotNil(t, err)
 }
 
+func TestReaderSubscription(t *testing.T) {
+	name, metricsName := readerSubscription(ReaderOptions{SubscriptionName: "my-sub"})
+	assert.Equal(t, "my-sub", name)
+	assert.Equal(t, "my-sub", metricsName)
+
+	// the metrics of the random subscriptions are labeled with a fixed subscription
+	name, metricsName = readerSubscription(ReaderOptions{SubscriptionRolePrefix: "my-role"})
+	assert.True(t, strings.HasPrefix(name, "my-role-"))
+	assert.Equal(t, readerMetricsSubscription, metricsName)
+	other, _ := readerSubscription(ReaderOptions{})
+	assert.True(t, strings.HasPrefix(other, "reader-"))
+	assert.NotEqual(t, name, other)
+}
+
 func TestReaderConfigSubscribeName(t *testing.T) {
 	client, err := NewClient(ClientOptions{
 		URL: lookupURL,
@@ -135,6 +150,56 @@ func TestReaderConnectError(t *testing.T) {
 	assert.Equal(t, err.Error(), "connection error")
 }
 
+func TestReaderValidateStartMessageID(t *testing.T) {
+	client, err := NewClient(ClientOptions{
+		URL: lookupURL,
+	})
+
+	assert.Nil(t, err)
+	defer client.Close()
+
+	topic := newTopicName()
+	ctx := context.Background()
+
+	producer, err := client.CreateProducer(ProducerOptions{
+		Topic:           topic,
+		DisableBatching: true,
+	})
+	assert.Nil(t, err)
+	defer producer.Close()
+
+	msgID, err := producer.Send(ctx, &ProducerMessage{
+		Payload: []byte("hello"),
+	})
+	assert.NoError(t, err)
+
+	reader, err := client.CreateReader(ReaderOptions{
+		Topic:                  topic,
+		StartMessageID:         msgID,
+		ValidateStartMessageID: true,
+	})
+	assert.Nil(t, err)
+	reader.Close()
+
+	// a message id beyond the last message of the topic
+	stale := newMessageID(msgID.LedgerID()+1000, 0, -1, msgID.PartitionIdx(), 0)
+	_, err = client.CreateReader(ReaderOptions{
+		Topic:                  topic,
+		StartMessageID:         stale,
+		ValidateStartMessageID: true,
+	})
+	assert.Error(t, err)
+	assert.Equal(t, InvalidConfiguration, err.(*Error).Result())
+
+	// a message id of another partition
+	_, err = client.CreateReader(ReaderOptions{
+		Topic:          topic,
+		StartMessageID: newMessageID(msgID.LedgerID(), msgID.EntryID(), -1, 3, 0),
+	})
+	assert.Error(t, err)
+	assert.Equal(t, InvalidConfiguration, err.(*Error).Result())
+}
+
 func TestReaderOnSpecificMessage(t *testing.T) {
 	client, err := NewClient(ClientOptions{
 		URL: lookupURL,
@@ -862,3 +927,64 @@ func TestReaderWithBackoffPolicy(t *testing.T) {
 	partitionConsumerImp.reconnectToBroker()
 	assert.True(t, backoff.IsExpectedIntervalFrom(startTime))
 }
+
+type countingReaderInterceptor struct {
+	beforeRead int
+	afterRead  int
+	readIDs    []MessageID
+}
+
+func (i *countingReaderInterceptor) BeforeRead(message ReaderMessage) {
+	i.beforeRead++
+}
+
+func (i *countingReaderInterceptor) AfterRead(reader Reader, msgID MessageID) {
+	i.afterRead++
+	i.readIDs = append(i.readIDs, msgID)
+}
+
+func TestReaderInterceptors(t *testing.T) {
+	client, err := NewClient(ClientOptions{
+		URL: lookupURL,
+	})
+	assert.Nil(t, err)
+	defer client.Close()
+
+	topic := newTopicName()
+	ctx := context.Background()
+
+	interceptor := &countingReaderInterceptor{}
+	reader, err := client.CreateReader(ReaderOptions{
+		Topic:          topic,
+		StartMessageID: EarliestMessageID(),
+		Interceptors:   ReaderInterceptors{interceptor},
+	})
+	assert.Nil(t, err)
+	defer reader.Close()
+
+	producer, err := client.CreateProducer(ProducerOptions{
+		Topic: topic,
+	})
+	assert.Nil(t, err)
+	defer producer.Close()
+
+	var sentIDs []MessageID
+	for i := 0; i < 5; i++ {
+		msgID, err := producer.Send(ctx, &ProducerMessage{
+			Payload: []byte(fmt.Sprintf("hello-%d", i)),
+		})
+		assert.NoError(t, err)
+		sentIDs = append(sentIDs, msgID)
+	}
+
+	for i := 0; i < 5; i++ {
+		_, err := reader.Next(ctx)
+		assert.NoError(t, err)
+	}
+
+	assert.Equal(t, 5, interceptor.beforeRead)
+	assert.Equal(t, 5, interceptor.afterRead)
+	for i := range sentIDs {
+		assert.Equal(t, sentIDs[i].Serialize(), interceptor.readIDs[i].Serialize())
+	}
+}
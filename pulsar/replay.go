@@ -0,0 +1,90 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsar
+
+import (
+	"context"
+	"time"
+)
+
+// PartitionReplay is the history a partition still retains to replay the messages published since a start time,
+// see Client.CheckReplay
+type PartitionReplay struct {
+	// Partition is the partition topic, or the topic itself if it is not partitioned
+	Partition string
+
+	// EarliestMessageID is the first message retained by the partition, nil if the partition has no message
+	EarliestMessageID MessageID
+
+	// EarliestPublishTime is the publish time of the first message retained by the partition
+	EarliestPublishTime time.Time
+
+	// Trimmed is true if the first message retained by the partition was published after the start time, so the
+	// messages published since the start time may have been removed by the retention policy before the replay
+	Trimmed bool
+}
+
+// CheckReplay reads the first message retained by each partition of the topic to report the partitions whose
+// history is already trimmed at the start time, before replaying them from the start time, e.g. with
+// Reader.SeekByTime, instead of silently replaying a part of the requested history.
+func (c *client) CheckReplay(ctx context.Context, topic string, start time.Time) ([]PartitionReplay, error) {
+	partitions, err := c.TopicPartitions(topic)
+	if err != nil {
+		return nil, err
+	}
+
+	replays := make([]PartitionReplay, 0, len(partitions))
+	for _, partition := range partitions {
+		replay, err := c.checkPartitionReplay(ctx, partition, start)
+		if err != nil {
+			return nil, err
+		}
+		replays = append(replays, replay)
+	}
+	return replays, nil
+}
+
+func (c *client) checkPartitionReplay(ctx context.Context, partition string, start time.Time) (PartitionReplay, error) {
+	reader, err := c.CreateReader(ReaderOptions{
+		Topic:          partition,
+		StartMessageID: EarliestMessageID(),
+	})
+	if err != nil {
+		return PartitionReplay{}, err
+	}
+	defer reader.Close()
+
+	if !reader.HasNext() {
+		return newPartitionReplay(partition, start, nil), nil
+	}
+	msg, err := reader.Next(ctx)
+	if err != nil {
+		return PartitionReplay{}, err
+	}
+	return newPartitionReplay(partition, start, msg), nil
+}
+
+func newPartitionReplay(partition string, start time.Time, earliest Message) PartitionReplay {
+	replay := PartitionReplay{Partition: partition}
+	if earliest != nil {
+		replay.EarliestMessageID = earliest.ID()
+		replay.EarliestPublishTime = earliest.PublishTime()
+		replay.Trimmed = replay.EarliestPublishTime.After(start)
+	}
+	return replay
+}
@@ -0,0 +1,85 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsar
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewPartitionReplay(t *testing.T) {
+	start := time.Unix(1000, 0)
+	msg := &message{
+		msgID:       newMessageID(1, 2, -1, 0, 0),
+		publishTime: start.Add(time.Minute),
+	}
+
+	replay := newPartitionReplay("topic-partition-0", start, msg)
+	assert.Equal(t, "topic-partition-0", replay.Partition)
+	assert.Equal(t, msg.msgID, replay.EarliestMessageID)
+	assert.Equal(t, start.Add(time.Minute), replay.EarliestPublishTime)
+	assert.True(t, replay.Trimmed)
+
+	assert.False(t, newPartitionReplay("topic-partition-0", start.Add(time.Hour), msg).Trimmed)
+
+	replay = newPartitionReplay("topic-partition-1", start, nil)
+	assert.Nil(t, replay.EarliestMessageID)
+	assert.False(t, replay.Trimmed)
+}
+
+func TestClientCheckReplay(t *testing.T) {
+	client, err := NewClient(ClientOptions{
+		URL: lookupURL,
+	})
+	assert.Nil(t, err)
+	defer client.Close()
+
+	randomName := newTopicName()
+	topic := "persistent://public/default/" + randomName
+	testURL := adminURL + "/" + "admin/v2/persistent/public/default/" + randomName + "/partitions"
+	makeHTTPCall(t, http.MethodPut, testURL, "2")
+
+	producer, err := client.CreateProducer(ProducerOptions{
+		Topic:           topic,
+		DisableBatching: true,
+		MessageRouter: func(msg *ProducerMessage, metadata TopicMetadata) int {
+			return 0
+		},
+	})
+	assert.Nil(t, err)
+	defer producer.Close()
+
+	before := time.Now().Add(-time.Minute)
+	id, err := producer.Send(context.Background(), &ProducerMessage{Payload: []byte("hello")})
+	assert.Nil(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	replays, err := client.CheckReplay(ctx, topic, before)
+	assert.Nil(t, err)
+	assert.Len(t, replays, 2)
+	assert.Equal(t, id.LedgerID(), replays[0].EarliestMessageID.LedgerID())
+	assert.Equal(t, id.EntryID(), replays[0].EarliestMessageID.EntryID())
+	assert.True(t, replays[0].Trimmed)
+	assert.Nil(t, replays[1].EarliestMessageID)
+	assert.False(t, replays[1].Trimmed)
+}
@@ -68,7 +68,7 @@ func newRetryRouter(client Client, policy *DLQPolicy, retryEnabled bool, logger
 			return nil, newError(InvalidConfiguration, "DLQPolicy.RetryLetterTopic needs to be set to a valid topic name")
 		}
 
-		r.messageCh = make(chan RetryMessage)
+		r.messageCh = make(chan RetryMessage, policy.maxPendingMessages())
 		r.closeCh = make(chan interface{}, 1)
 		r.log = logger.SubLogger(log.Fields{"rlq-topic": policy.RetryLetterTopic})
 		go r.run()
@@ -76,8 +76,16 @@ func newRetryRouter(client Client, policy *DLQPolicy, retryEnabled bool, logger
 	return r, nil
 }
 
-func (r *retryRouter) Chan() chan RetryMessage {
-	return r.messageCh
+// route passes the message to the router without blocking. The message is negatively acknowledged, to be
+// redelivered later, if too many messages are pending, e.g. while the RLQ topic is unavailable.
+func (r *retryRouter) route(rm RetryMessage) {
+	select {
+	case r.messageCh <- rm:
+	default:
+		r.log.WithField("msgID", rm.consumerMsg.ID()).
+			Warn("Too many messages pending for the RLQ, negatively acknowledging")
+		rm.consumerMsg.Consumer.Nack(rm.consumerMsg)
+	}
 }
 
 func (r *retryRouter) run() {
@@ -86,15 +94,25 @@ func (r *retryRouter) run() {
 		case rm := <-r.messageCh:
 			r.log.WithField("msgID", rm.consumerMsg.ID()).Debug("Got message for RLQ")
 			producer := r.getProducer()
+			if producer == nil {
+				r.log.Debug("Closed RLQ router")
+				return
+			}
 
 			msgID := rm.consumerMsg.ID()
 			producer.SendAsync(context.Background(), &rm.producerMsg, func(messageID MessageID,
 				producerMessage *ProducerMessage, err error) {
 				if err != nil {
 					r.log.WithError(err).WithField("msgID", msgID).Error("Failed to send message to RLQ")
+					if c, ok := rm.consumerMsg.Consumer.(*consumer); ok {
+						c.asyncErrors.report(ConsumerOpRLQ, c.topic, msgID, err)
+					}
 					rm.consumerMsg.Consumer.Nack(rm.consumerMsg)
 				} else {
 					r.log.WithField("msgID", msgID).Debug("Succeed to send message to RLQ")
+					if c, ok := rm.consumerMsg.Consumer.(*consumer); ok {
+						c.metrics.RlqPublished.Inc()
+					}
 					rm.consumerMsg.Consumer.AckID(msgID)
 				}
 			})
@@ -117,6 +135,8 @@ func (r *retryRouter) close() {
 	}
 }
 
+// getProducer returns the RLQ producer, created on first use. The creation is retried until the router is
+// closed, nil is returned then, while the messages wait in the router.
 func (r *retryRouter) getProducer() Producer {
 	if r.producer != nil {
 		// Producer was already initialized
@@ -138,7 +158,11 @@ func (r *retryRouter) getProducer() Producer {
 
 		if err != nil {
 			r.log.WithError(err).Error("Failed to create RLQ producer")
-			time.Sleep(backoff.Next())
+			select {
+			case <-time.After(backoff.Next()):
+			case <-r.closeCh:
+				return nil
+			}
 			continue
 		} else {
 			r.producer = producer
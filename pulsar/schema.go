@@ -20,6 +20,7 @@ package pulsar
 import (
 	"bytes"
 	"crypto/sha256"
+	"encoding/binary"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
@@ -29,6 +30,7 @@ import (
 	log "github.com/sirupsen/logrus"
 
 	"github.com/linkedin/goavro/v2"
+	"google.golang.org/protobuf/encoding/protowire"
 	"google.golang.org/protobuf/proto"
 )
 
@@ -71,6 +73,13 @@ func (s SchemaInfo) hash() string {
 	return hex.EncodeToString(h.Sum(nil))
 }
 
+// NewSchemaVersion returns the schema version with the given number, as listed by the schema registry
+func NewSchemaVersion(version int64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, uint64(version))
+	return b
+}
+
 type Schema interface {
 	Encode(v interface{}) ([]byte, error)
 	Decode(data []byte, v interface{}) error
@@ -165,7 +174,10 @@ func (js *JSONSchema) Decode(data []byte, v interface{}) error {
 }
 
 func (js *JSONSchema) Validate(message []byte) error {
-	return js.Decode(message, nil)
+	if !json.Valid(message) {
+		return newError(InvalidMessage, "data received by JSONSchema is not valid JSON")
+	}
+	return nil
 }
 
 func (js *JSONSchema) GetSchemaInfo() *SchemaInfo {
@@ -211,8 +223,18 @@ func (ps *ProtoSchema) Decode(data []byte, v interface{}) error {
 	return proto.Unmarshal(data, v.(proto.Message))
 }
 
+// Validate checks the message is made of well-formed protobuf fields, the schema has no message type to check
+// the fields against
 func (ps *ProtoSchema) Validate(message []byte) error {
-	return ps.Decode(message, nil)
+	for len(message) > 0 {
+		_, _, n := protowire.ConsumeField(message)
+		if n < 0 {
+			return newError(InvalidMessage, fmt.Sprintf("data received by ProtoSchema is not valid protobuf: %v",
+				protowire.ParseError(n)))
+		}
+		message = message[n:]
+	}
+	return nil
 }
 
 func (ps *ProtoSchema) GetSchemaInfo() *SchemaInfo {
@@ -284,7 +306,10 @@ func (as *AvroSchema) Decode(data []byte, v interface{}) error {
 }
 
 func (as *AvroSchema) Validate(message []byte) error {
-	return as.Decode(message, nil)
+	if _, _, err := as.Codec.NativeFromBinary(message); err != nil {
+		return newError(InvalidMessage, fmt.Sprintf("data received by AvroSchema is not valid: %v", err))
+	}
+	return nil
 }
 
 func (as *AvroSchema) GetSchemaInfo() *SchemaInfo {
@@ -316,7 +341,7 @@ func (ss *StringSchema) Decode(data []byte, v interface{}) error {
 }
 
 func (ss *StringSchema) Validate(message []byte) error {
-	return ss.Decode(message, nil)
+	return nil
 }
 
 func (ss *StringSchema) GetSchemaInfo() *SchemaInfo {
@@ -346,7 +371,7 @@ func (bs *BytesSchema) Decode(data []byte, v interface{}) error {
 }
 
 func (bs *BytesSchema) Validate(message []byte) error {
-	return bs.Decode(message, nil)
+	return nil
 }
 
 func (bs *BytesSchema) GetSchemaInfo() *SchemaInfo {
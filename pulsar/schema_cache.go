@@ -0,0 +1,169 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsar
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/apache/pulsar-client-go/pulsar/internal"
+)
+
+// schemaStore keeps the schemas fetched by the consumers of a client, by topic and schema version, so that they
+// can be exported to and imported from a file
+type schemaStore struct {
+	sync.RWMutex
+	schemas map[schemaStoreKey]SchemaInfo
+}
+
+type schemaStoreKey struct {
+	topic   string
+	version string
+}
+
+// schemaCacheEntry is the JSON representation of a schema written by Client.ExportSchemaCache
+type schemaCacheEntry struct {
+	Topic      string            `json:"topic"`
+	Version    string            `json:"version"`
+	Name       string            `json:"name"`
+	Type       SchemaType        `json:"type"`
+	Schema     string            `json:"schema"`
+	Properties map[string]string `json:"properties,omitempty"`
+}
+
+func newSchemaStore() *schemaStore {
+	return &schemaStore{
+		schemas: make(map[schemaStoreKey]SchemaInfo),
+	}
+}
+
+func (s *schemaStore) get(topic, version string) (SchemaInfo, bool) {
+	s.RLock()
+	defer s.RUnlock()
+	info, ok := s.schemas[schemaStoreKey{topic: topic, version: version}]
+	return info, ok
+}
+
+func (s *schemaStore) put(topic, version string, info SchemaInfo) {
+	s.Lock()
+	defer s.Unlock()
+	s.schemas[schemaStoreKey{topic: topic, version: version}] = info
+}
+
+func (s *schemaStore) export(w io.Writer) error {
+	s.RLock()
+	entries := make([]schemaCacheEntry, 0, len(s.schemas))
+	for key, info := range s.schemas {
+		entries = append(entries, schemaCacheEntry{
+			Topic:      key.topic,
+			Version:    key.version,
+			Name:       info.Name,
+			Type:       info.Type,
+			Schema:     info.Schema,
+			Properties: info.Properties,
+		})
+	}
+	s.RUnlock()
+	return json.NewEncoder(w).Encode(entries)
+}
+
+func (s *schemaStore) load(r io.Reader) error {
+	var entries []schemaCacheEntry
+	if err := json.NewDecoder(r).Decode(&entries); err != nil {
+		return err
+	}
+	s.Lock()
+	defer s.Unlock()
+	for _, e := range entries {
+		s.schemas[schemaStoreKey{topic: e.Topic, version: e.Version}] = SchemaInfo{
+			Name:       e.Name,
+			Type:       e.Type,
+			Schema:     e.Schema,
+			Properties: e.Properties,
+		}
+	}
+	return nil
+}
+
+// loadFile imports the schemas of a file written by saveFile, a missing file is ignored
+func (s *schemaStore) loadFile(path string) error {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+	return s.load(f)
+}
+
+// saveFile exports the schemas to a file, written to a temporary file first to never leave a truncated file
+func (s *schemaStore) saveFile(path string) error {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if err = s.export(f); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err = f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// schemaStoreTopic returns the topic under which the schemas of a topic are stored, the partitions of a topic
+// share the schemas of the topic
+func schemaStoreTopic(topic string) string {
+	tn, err := internal.ParseTopicName(topic)
+	if err != nil {
+		return topic
+	}
+	return internal.TopicNameWithoutPartitionPart(tn)
+}
+
+// ExportSchemaCache writes the schemas fetched by the consumers of the client to w
+func (c *client) ExportSchemaCache(w io.Writer) error {
+	return c.schemas.export(w)
+}
+
+// ImportSchemaCache reads schemas written by ExportSchemaCache from r
+func (c *client) ImportSchemaCache(r io.Reader) error {
+	if err := c.schemas.load(r); err != nil {
+		return newError(InvalidConfiguration, fmt.Sprintf("invalid schema cache: %v", err))
+	}
+	return nil
+}
+
+// DecodeWithSchemaInfo decodes the payload of a message with the schema described by info into v, without a
+// client, e.g. in offline tools reading dumped messages with the schema from Client.ExportSchemaCache or from
+// the schema registry.
+func DecodeWithSchemaInfo(payload []byte, info SchemaInfo, v interface{}) error {
+	schema, err := NewSchema(info.Type, []byte(info.Schema), info.Properties)
+	if err != nil {
+		return err
+	}
+	return schema.Decode(payload, v)
+}
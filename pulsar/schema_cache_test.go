@@ -0,0 +1,100 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsar
+
+import (
+	"bytes"
+	"encoding/hex"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSchemaStoreExportImport(t *testing.T) {
+	store := newSchemaStore()
+	info := SchemaInfo{
+		Name:       "test",
+		Type:       STRING,
+		Properties: map[string]string{"key": "value"},
+	}
+	store.put("persistent://public/default/topic", "00", info)
+
+	var buf bytes.Buffer
+	assert.NoError(t, store.export(&buf))
+
+	imported := newSchemaStore()
+	assert.NoError(t, imported.load(&buf))
+	got, ok := imported.get("persistent://public/default/topic", "00")
+	assert.True(t, ok)
+	assert.Equal(t, info, got)
+	_, ok = imported.get("persistent://public/default/topic", "01")
+	assert.False(t, ok)
+
+	assert.Error(t, imported.load(bytes.NewBufferString("{")))
+}
+
+func TestSchemaStoreFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "schemas.json")
+
+	// a missing file is ignored
+	store := newSchemaStore()
+	assert.NoError(t, store.loadFile(path))
+
+	store.put("persistent://public/default/topic", "00", SchemaInfo{Name: "test", Type: STRING})
+	assert.NoError(t, store.saveFile(path))
+
+	loaded := newSchemaStore()
+	assert.NoError(t, loaded.loadFile(path))
+	_, ok := loaded.get("persistent://public/default/topic", "00")
+	assert.True(t, ok)
+}
+
+func TestSchemaInfoCacheFromStore(t *testing.T) {
+	c := &client{schemas: newSchemaStore()}
+	version := []byte{0, 0, 0, 0, 0, 0, 0, 1}
+	c.schemas.put("persistent://public/default/topic", hex.EncodeToString(version),
+		SchemaInfo{Name: "test", Type: STRING})
+
+	// the partitions share the schemas of the topic, the lookup service is not used
+	cache := newSchemaInfoCache(c, "persistent://public/default/topic-partition-1")
+	schema, err := cache.Get(version)
+	assert.NoError(t, err)
+	assert.Equal(t, STRING, schema.GetSchemaInfo().Type)
+}
+
+func TestDecodeWithSchemaInfo(t *testing.T) {
+	var s *string
+	assert.NoError(t, DecodeWithSchemaInfo([]byte("hello"), SchemaInfo{Type: STRING}, &s))
+	assert.Equal(t, "hello", *s)
+
+	type record struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+	}
+	info := SchemaInfo{
+		Type: JSON,
+		Schema: `{"type":"record","name":"record","fields":[{"name":"id","type":"int"},` +
+			`{"name":"name","type":"string"}]}`,
+	}
+	var r record
+	assert.NoError(t, DecodeWithSchemaInfo([]byte(`{"id":1,"name":"a"}`), info, &r))
+	assert.Equal(t, record{ID: 1, Name: "a"}, r)
+
+	assert.Error(t, DecodeWithSchemaInfo(nil, SchemaInfo{Type: SchemaType(1000)}, &s))
+}
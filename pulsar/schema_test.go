@@ -429,3 +429,24 @@ func TestDoubleSchema(t *testing.T) {
 	assert.Equal(t, res, float64(1))
 	defer consumer.Close()
 }
+
+func TestSchemaValidate(t *testing.T) {
+	jsonSchema := NewJSONSchema(exampleSchemaDef, nil)
+	assert.Nil(t, jsonSchema.Validate([]byte(`{"id":1,"name":"pulsar"}`)))
+	assert.NotNil(t, jsonSchema.Validate([]byte(`{"id":1`)))
+
+	avroSchema := NewAvroSchema(exampleSchemaDef, nil)
+	payload, err := avroSchema.Encode(testAvro{ID: 1, Name: "pulsar"})
+	assert.Nil(t, err)
+	assert.Nil(t, avroSchema.Validate(payload))
+	assert.NotNil(t, avroSchema.Validate(payload[:1]))
+
+	protoSchema := NewProtoSchema(protoSchemaDef, nil)
+	payload, err = protoSchema.Encode(&pb.Test{Num: 100, Msf: "pulsar"})
+	assert.Nil(t, err)
+	assert.Nil(t, protoSchema.Validate(payload))
+	assert.NotNil(t, protoSchema.Validate(payload[:len(payload)-1]))
+
+	assert.Nil(t, NewStringSchema(nil).Validate([]byte("pulsar")))
+	assert.Nil(t, NewBytesSchema(nil).Validate([]byte{1}))
+}
@@ -0,0 +1,99 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsar
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/apache/pulsar-client-go/pulsar/internal"
+	pb "github.com/apache/pulsar-client-go/pulsar/internal/pulsar_proto"
+)
+
+// checkTopicsExist verifies that the namespaces and the topics exist when the topic existence check is enabled.
+// The check is best effort: failures other than a missing resource are logged and the creation proceeds.
+func (c *client) checkTopicsExist(topics ...string) error {
+	if !c.topicExistenceCheck {
+		return nil
+	}
+
+	// namespace -> topics of the namespace, so the listing happens once per namespace
+	namespaceTopics := make(map[string][]string)
+	for _, topic := range topics {
+		tn, err := internal.ParseTopicName(topic)
+		if err != nil {
+			return newError(InvalidTopicName, err.Error())
+		}
+		if tn.Domain != "persistent" {
+			continue
+		}
+
+		metadata, err := c.lookupService.GetPartitionedTopicMetadata(tn.Name)
+		if err == nil && metadata != nil && metadata.Partitions > 0 {
+			continue
+		}
+
+		list, ok := namespaceTopics[tn.Namespace]
+		if !ok {
+			list, err = c.lookupService.GetTopicsOfNamespace(tn.Namespace, internal.Persistent)
+			if err != nil {
+				if isNotFoundError(err) {
+					return newError(NamespaceNotFound, fmt.Sprintf("namespace %s does not exist, "+
+						"create it with `pulsar-admin namespaces create %s`", tn.Namespace, tn.Namespace))
+				}
+				c.log.WithError(err).Warnf("Failed to verify the existence of topic %s", tn.Name)
+				continue
+			}
+			namespaceTopics[tn.Namespace] = list
+		}
+
+		if !containsTopic(list, tn) {
+			return newError(TopicNotFound, fmt.Sprintf("topic %s does not exist, create it with "+
+				"`pulsar-admin topics create %s` or enable topic auto-creation on the broker", tn.Name, tn.Name))
+		}
+	}
+	return nil
+}
+
+// containsTopic checks if the topic, or one of its partitions, is in the topics of the namespace
+func containsTopic(namespaceTopics []string, tn *internal.TopicName) bool {
+	for _, t := range namespaceTopics {
+		if t == tn.Name {
+			return true
+		}
+		if tn.Partition < 0 {
+			if other, err := internal.ParseTopicName(t); err == nil &&
+				internal.TopicNameWithoutPartitionPart(other) == tn.Name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isNotFoundError checks if a lookup error reports a missing resource, either from the binary protocol or
+// from the REST API
+func isNotFoundError(err error) bool {
+	var serverErr *internal.ServerError
+	if errors.As(err, &serverErr) {
+		return serverErr.Code == pb.ServerError_TopicNotFound
+	}
+	var httpErr *internal.HTTPError
+	return errors.As(err, &httpErr) && httpErr.StatusCode == http.StatusNotFound
+}
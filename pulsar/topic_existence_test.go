@@ -0,0 +1,119 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsar
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/apache/pulsar-client-go/pulsar/internal"
+	pb "github.com/apache/pulsar-client-go/pulsar/internal/pulsar_proto"
+	"github.com/apache/pulsar-client-go/pulsar/log"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockTopicsLookupService struct {
+	partitions      map[string]int
+	namespaceTopics map[string][]string
+	listErr         error
+	schemas         map[string]*pb.Schema
+}
+
+func (m *mockTopicsLookupService) Lookup(topic string) (*internal.LookupResult, error) {
+	return nil, errors.New("not supported")
+}
+
+func (m *mockTopicsLookupService) GetPartitionedTopicMetadata(topic string) (*internal.PartitionedTopicMetadata,
+	error) {
+	return &internal.PartitionedTopicMetadata{Partitions: m.partitions[topic]}, nil
+}
+
+func (m *mockTopicsLookupService) GetTopicsOfNamespace(namespace string,
+	mode internal.GetTopicsOfNamespaceMode) ([]string, error) {
+	if m.listErr != nil {
+		return nil, m.listErr
+	}
+	topics, ok := m.namespaceTopics[namespace]
+	if !ok {
+		return nil, &internal.HTTPError{StatusCode: 404, Reason: "Namespace does not exist"}
+	}
+	return topics, nil
+}
+
+func (m *mockTopicsLookupService) GetSchema(topic string, schemaVersion []byte) (*pb.Schema, error) {
+	if schema, ok := m.schemas[string(schemaVersion)]; ok {
+		return schema, nil
+	}
+	return nil, errors.New("not supported")
+}
+
+func (m *mockTopicsLookupService) Close() {}
+
+func TestCheckTopicsExist(t *testing.T) {
+	c := &client{
+		lookupService: &mockTopicsLookupService{
+			partitions: map[string]int{"persistent://public/default/partitioned": 2},
+			namespaceTopics: map[string][]string{
+				"public/default": {
+					"persistent://public/default/my-topic",
+					"persistent://public/default/other-partition-0",
+				},
+			},
+		},
+		topicExistenceCheck: true,
+		log:                 log.DefaultNopLogger(),
+	}
+
+	assert.Nil(t, c.checkTopicsExist("my-topic", "partitioned", "other", "non-persistent://public/default/np"))
+
+	err := c.checkTopicsExist("missing-topic")
+	assert.NotNil(t, err)
+	assert.Equal(t, TopicNotFound, err.(*Error).Result())
+	assert.Contains(t, err.Error(), "pulsar-admin topics create persistent://public/default/missing-topic")
+
+	err = c.checkTopicsExist("persistent://public/missing-ns/my-topic")
+	assert.NotNil(t, err)
+	assert.Equal(t, NamespaceNotFound, err.(*Error).Result())
+	assert.Contains(t, err.Error(), "public/missing-ns")
+}
+
+func TestCheckTopicsExistBestEffort(t *testing.T) {
+	c := &client{
+		lookupService:       &mockTopicsLookupService{listErr: errors.New("ServiceNotReady")},
+		topicExistenceCheck: true,
+		log:                 log.DefaultNopLogger(),
+	}
+	assert.Nil(t, c.checkTopicsExist("my-topic"))
+
+	c.topicExistenceCheck = false
+	c.lookupService = &mockTopicsLookupService{}
+	assert.Nil(t, c.checkTopicsExist("missing-topic"))
+}
+
+func TestIsNotFoundError(t *testing.T) {
+	assert.True(t, isNotFoundError(&internal.HTTPError{StatusCode: 404, Reason: "Namespace does not exist"}))
+	assert.True(t, isNotFoundError(fmt.Errorf("lookup failed: %w",
+		&internal.ServerError{Code: pb.ServerError_TopicNotFound, Message: "Namespace does not exist"})))
+
+	// the errors are classified by their code rather than their message
+	assert.False(t, isNotFoundError(&internal.HTTPError{StatusCode: 500, Reason: "TopicNotFound"}))
+	assert.False(t, isNotFoundError(&internal.ServerError{Code: pb.ServerError_ServiceNotReady,
+		Message: "Code: 404"}))
+	assert.False(t, isNotFoundError(errors.New("Code: 404, Reason: Namespace does not exist")))
+}
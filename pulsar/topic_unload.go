@@ -0,0 +1,183 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsar
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/apache/pulsar-client-go/pulsar/log"
+)
+
+const defaultTopicUnloadGracePeriod = 30 * time.Second
+
+// TopicUnloadEvent describes how a producer or a consumer handled the broker closing it, which the broker does
+// when the bundle of the topic is unloaded or the ownership of the topic is transferred to another broker.
+type TopicUnloadEvent struct {
+	// Topic is the topic, or the partition, of the producer or consumer
+	Topic string
+
+	// Name is the name of the producer, or the subscription of the consumer
+	Name string
+
+	// Recovered is true when the producer or consumer reconnected within the TopicUnloadGracePeriod
+	Recovered bool
+
+	// Duration is the time elapsed since the broker closed the producer or consumer
+	Duration time.Duration
+
+	// Err is why the producer or consumer did not recover, nil when Recovered
+	Err error
+}
+
+// TopicUnloadListener is notified once for each time the broker closes a producer or a consumer, either when it
+// reconnected or when the grace period elapsed. It's called from the goroutines of the client and must not block.
+type TopicUnloadListener interface {
+	TopicUnloaded(event TopicUnloadEvent)
+}
+
+// topicUnload tracks the recovery of a producer or consumer closed by the broker. The sends are paused while
+// the recovery is in progress, and the send timeouts are not reported within the grace period.
+type topicUnload struct {
+	sync.Mutex
+	topic    string
+	name     string
+	grace    time.Duration
+	listener TopicUnloadListener
+	log      log.Logger
+
+	inProgress  int32
+	withinGrace int32
+	start       time.Time
+	timer       *time.Timer
+}
+
+func newTopicUnload(c *client, topic, name string, logger log.Logger) *topicUnload {
+	return &topicUnload{
+		topic:    topic,
+		name:     name,
+		grace:    c.topicUnloadGracePeriod,
+		listener: c.topicUnloadListener,
+		log:      logger,
+	}
+}
+
+// rename sets the name and the logger of the producer, which are only known once the broker created it
+func (u *topicUnload) rename(name string, logger log.Logger) {
+	u.Lock()
+	defer u.Unlock()
+	u.name = name
+	u.log = logger
+}
+
+// begin starts the recovery, it returns false when the graceful handling of the unloads is disabled
+func (u *topicUnload) begin() bool {
+	if u == nil || u.grace < 0 {
+		return false
+	}
+
+	u.Lock()
+	defer u.Unlock()
+	if !u.start.IsZero() {
+		// closed again by the broker while reconnecting
+		return true
+	}
+	u.start = time.Now()
+	atomic.StoreInt32(&u.inProgress, 1)
+	atomic.StoreInt32(&u.withinGrace, 1)
+	u.timer = time.AfterFunc(u.grace, u.expire)
+	return true
+}
+
+// paused reports whether the sends are paused until reconnected
+func (u *topicUnload) paused() bool {
+	return u != nil && atomic.LoadInt32(&u.inProgress) == 1
+}
+
+// inGracePeriod reports whether the errors are not reported yet, expecting the recovery to complete
+func (u *topicUnload) inGracePeriod() bool {
+	return u != nil && atomic.LoadInt32(&u.withinGrace) == 1
+}
+
+// resume resumes the sends once reconnected
+func (u *topicUnload) resume() {
+	if u != nil {
+		atomic.StoreInt32(&u.inProgress, 0)
+	}
+}
+
+// finish completes the recovery, err is nil when reconnected
+func (u *topicUnload) finish(err error) {
+	if u == nil {
+		return
+	}
+
+	u.Lock()
+	if u.start.IsZero() {
+		u.Unlock()
+		return
+	}
+	u.timer.Stop()
+	notify := atomic.LoadInt32(&u.withinGrace) == 1
+	event := TopicUnloadEvent{
+		Topic:     u.topic,
+		Name:      u.name,
+		Recovered: err == nil,
+		Duration:  time.Since(u.start),
+		Err:       err,
+	}
+	logger := u.log
+	u.start = time.Time{}
+	atomic.StoreInt32(&u.inProgress, 0)
+	atomic.StoreInt32(&u.withinGrace, 0)
+	u.Unlock()
+
+	if err == nil {
+		logger.Infof("Recovered from the topic unload in %v", event.Duration)
+	}
+	if notify {
+		u.notify(event)
+	}
+}
+
+func (u *topicUnload) expire() {
+	u.Lock()
+	if u.start.IsZero() || atomic.LoadInt32(&u.withinGrace) == 0 {
+		u.Unlock()
+		return
+	}
+	atomic.StoreInt32(&u.withinGrace, 0)
+	event := TopicUnloadEvent{
+		Topic:    u.topic,
+		Name:     u.name,
+		Duration: time.Since(u.start),
+		Err:      newError(TimeoutError, "not reconnected within the topic unload grace period"),
+	}
+	logger := u.log
+	u.Unlock()
+
+	logger.Warnf("Not recovered from the topic unload within %v", u.grace)
+	u.notify(event)
+}
+
+func (u *topicUnload) notify(event TopicUnloadEvent) {
+	if u.listener != nil {
+		u.listener.TopicUnloaded(event)
+	}
+}
@@ -0,0 +1,126 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsar
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/apache/pulsar-client-go/pulsar/log"
+	"github.com/stretchr/testify/assert"
+)
+
+type topicUnloadRecorder struct {
+	sync.Mutex
+	events []TopicUnloadEvent
+}
+
+func (r *topicUnloadRecorder) TopicUnloaded(event TopicUnloadEvent) {
+	r.Lock()
+	defer r.Unlock()
+	r.events = append(r.events, event)
+}
+
+func (r *topicUnloadRecorder) get() []TopicUnloadEvent {
+	r.Lock()
+	defer r.Unlock()
+	return append([]TopicUnloadEvent(nil), r.events...)
+}
+
+func newTestTopicUnload(grace time.Duration, listener TopicUnloadListener) *topicUnload {
+	c := &client{topicUnloadGracePeriod: grace, topicUnloadListener: listener}
+	return newTopicUnload(c, "persistent://public/default/my-topic", "my-producer", log.DefaultNopLogger())
+}
+
+func TestTopicUnloadRecovered(t *testing.T) {
+	recorder := &topicUnloadRecorder{}
+	u := newTestTopicUnload(time.Minute, recorder)
+
+	assert.True(t, u.begin())
+	assert.True(t, u.paused())
+	assert.True(t, u.inGracePeriod())
+
+	// closed again while reconnecting
+	assert.True(t, u.begin())
+
+	u.resume()
+	assert.False(t, u.paused())
+	u.finish(nil)
+	assert.False(t, u.inGracePeriod())
+
+	events := recorder.get()
+	assert.Len(t, events, 1)
+	assert.True(t, events[0].Recovered)
+	assert.Nil(t, events[0].Err)
+	assert.Equal(t, "my-producer", events[0].Name)
+
+	// reconnecting after a connection failure is not an unload
+	u.finish(nil)
+	assert.Len(t, recorder.get(), 1)
+}
+
+func TestTopicUnloadGracePeriodElapsed(t *testing.T) {
+	recorder := &topicUnloadRecorder{}
+	u := newTestTopicUnload(10*time.Millisecond, recorder)
+
+	assert.True(t, u.begin())
+	assert.Eventually(t, func() bool { return len(recorder.get()) == 1 }, time.Second, 5*time.Millisecond)
+	assert.False(t, u.inGracePeriod())
+	assert.True(t, u.paused())
+
+	events := recorder.get()
+	assert.False(t, events[0].Recovered)
+	assert.NotNil(t, events[0].Err)
+
+	// recovering after the grace period does not notify again
+	u.finish(nil)
+	assert.False(t, u.paused())
+	assert.Len(t, recorder.get(), 1)
+}
+
+func TestTopicUnloadDisabled(t *testing.T) {
+	recorder := &topicUnloadRecorder{}
+	u := newTestTopicUnload(-1, recorder)
+
+	assert.False(t, u.begin())
+	assert.False(t, u.paused())
+	u.finish(nil)
+	assert.Empty(t, recorder.get())
+}
+
+func TestTopicUnloadRename(t *testing.T) {
+	recorder := &topicUnloadRecorder{}
+	u := newTestTopicUnload(time.Minute, recorder)
+
+	// closed by the broker while the producer is created
+	assert.True(t, u.begin())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		u.finish(nil)
+	}()
+	u.rename("standalone-0-1", log.DefaultNopLogger())
+	<-done
+
+	assert.True(t, u.begin())
+	u.finish(nil)
+	events := recorder.get()
+	assert.Len(t, events, 2)
+	assert.Equal(t, "standalone-0-1", events[1].Name)
+}
@@ -17,7 +17,81 @@
 
 package pulsar
 
+import (
+	"context"
+	"fmt"
+)
+
+// TxnState The state of the transaction. Check the state of the transaction before executing some operation
+// with this transaction is necessary.
+type TxnState int32
+
+const (
+	_ TxnState = iota
+	// TxnOpen The transaction in TxnOpen state can be used to send/ack messages.
+	TxnOpen
+	// TxnCommitting The state of the transaction will be TxnCommitting after the commit method is called.
+	// The transaction in TxnCommitting state can be committed again.
+	TxnCommitting
+	// TxnAborting The state of the transaction will be TxnAborting after the abort method is called.
+	// The transaction in TxnAborting state can be aborted again.
+	TxnAborting
+	// TxnCommitted The state of the transaction will be TxnCommitted after the commit method is executed success.
+	// This means that all the operations with the transaction are success.
+	TxnCommitted
+	// TxnAborted The state of the transaction will be TxnAborted after the abort method is executed success.
+	// This means that all the operations with the transaction are aborted.
+	TxnAborted
+	// TxnError The state of the transaction will be TxnError after the operation of transaction get a non-retryable
+	// error.
+	TxnError
+	// TxnTimeout The state of the transaction will be TxnTimeout after the transaction timeout.
+	TxnTimeout
+)
+
+func (s TxnState) String() string {
+	switch s {
+	case TxnOpen:
+		return "TxnOpen"
+	case TxnCommitting:
+		return "TxnCommitting"
+	case TxnAborting:
+		return "TxnAborting"
+	case TxnCommitted:
+		return "TxnCommitted"
+	case TxnAborted:
+		return "TxnAborted"
+	case TxnError:
+		return "TxnError"
+	case TxnTimeout:
+		return "TxnTimeout"
+	default:
+		return fmt.Sprintf("TxnState(%d)", int32(s))
+	}
+}
+
+// TxnID An identifier for representing a transaction.
 type TxnID struct {
-	mostSigBits  uint64
-	leastSigBits uint64
+	// MostSigBits The most significant 64 bits of this TxnID.
+	MostSigBits uint64
+	// LeastSigBits The least significant 64 bits of this TxnID.
+	LeastSigBits uint64
+}
+
+func (id TxnID) String() string {
+	return fmt.Sprintf("(%d,%d)", id.MostSigBits, id.LeastSigBits)
+}
+
+// Transaction used to guarantee exactly-once
+type Transaction interface {
+	// Commit You can commit the transaction after all the sending/acknowledging operations with the transaction
+	// success.
+	Commit(context.Context) error
+	// Abort You can abort the transaction when you want to abort all the sending/acknowledging operations
+	// with the transaction.
+	Abort(context.Context) error
+	// GetState Get the state of the transaction.
+	GetState() TxnState
+	// GetTxnID Get the identified ID of the transaction.
+	GetTxnID() TxnID
 }
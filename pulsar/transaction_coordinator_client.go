@@ -77,6 +77,15 @@ func (tc *transactionCoordinatorClient) grabConn(partition uint64) error {
 		return err
 	}
 
+	cnx, err := tc.client.cnxPool.GetConnection(lr.LogicalAddr, lr.PhysicalAddr)
+	if err != nil {
+		return err
+	}
+	info := newBrokerInfo(cnx.GetServerInfo())
+	if err := info.require("Transactions", info.Features.Transactions); err != nil {
+		return err
+	}
+
 	requestID := tc.client.rpcClient.NewRequestID()
 	cmdTCConnect := pb.CommandTcClientConnectRequest{
 		RequestId: proto.Uint64(requestID),
@@ -133,11 +142,11 @@ func (tc *transactionCoordinatorClient) addPublishPartitionToTxn(id *TxnID, part
 	requestID := tc.client.rpcClient.NewRequestID()
 	cmdAddPartitions := &pb.CommandAddPartitionToTxn{
 		RequestId:      proto.Uint64(requestID),
-		TxnidMostBits:  proto.Uint64(id.mostSigBits),
-		TxnidLeastBits: proto.Uint64(id.leastSigBits),
+		TxnidMostBits:  proto.Uint64(id.MostSigBits),
+		TxnidLeastBits: proto.Uint64(id.LeastSigBits),
 		Partitions:     partitions,
 	}
-	_, err := tc.client.rpcClient.RequestOnCnx(tc.cons[id.mostSigBits], requestID,
+	_, err := tc.client.rpcClient.RequestOnCnx(tc.cons[id.MostSigBits], requestID,
 		pb.BaseCommand_ADD_PARTITION_TO_TXN, cmdAddPartitions)
 	tc.semaphore.Release()
 	return err
@@ -156,11 +165,11 @@ func (tc *transactionCoordinatorClient) addSubscriptionToTxn(id *TxnID, topic st
 	}
 	cmdAddSubscription := &pb.CommandAddSubscriptionToTxn{
 		RequestId:      proto.Uint64(requestID),
-		TxnidMostBits:  proto.Uint64(id.mostSigBits),
-		TxnidLeastBits: proto.Uint64(id.leastSigBits),
+		TxnidMostBits:  proto.Uint64(id.MostSigBits),
+		TxnidLeastBits: proto.Uint64(id.LeastSigBits),
 		Subscription:   []*pb.Subscription{sub},
 	}
-	_, err := tc.client.rpcClient.RequestOnCnx(tc.cons[id.mostSigBits], requestID,
+	_, err := tc.client.rpcClient.RequestOnCnx(tc.cons[id.MostSigBits], requestID,
 		pb.BaseCommand_ADD_SUBSCRIPTION_TO_TXN, cmdAddSubscription)
 	tc.semaphore.Release()
 	return err
@@ -175,10 +184,10 @@ func (tc *transactionCoordinatorClient) endTxn(id *TxnID, action pb.TxnAction) e
 	cmdEndTxn := &pb.CommandEndTxn{
 		RequestId:      proto.Uint64(requestID),
 		TxnAction:      &action,
-		TxnidMostBits:  proto.Uint64(id.mostSigBits),
-		TxnidLeastBits: proto.Uint64(id.leastSigBits),
+		TxnidMostBits:  proto.Uint64(id.MostSigBits),
+		TxnidLeastBits: proto.Uint64(id.LeastSigBits),
 	}
-	_, err := tc.client.rpcClient.RequestOnCnx(tc.cons[id.mostSigBits], requestID, pb.BaseCommand_END_TXN, cmdEndTxn)
+	_, err := tc.client.rpcClient.RequestOnCnx(tc.cons[id.MostSigBits], requestID, pb.BaseCommand_END_TXN, cmdEndTxn)
 	tc.semaphore.Release()
 	return err
 }
@@ -0,0 +1,226 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsar
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	pb "github.com/apache/pulsar-client-go/pulsar/internal/pulsar_proto"
+	"github.com/apache/pulsar-client-go/pulsar/log"
+)
+
+const defaultTransactionTimeout = 3 * time.Minute
+
+type subscription struct {
+	topic        string
+	subscription string
+}
+
+type transaction struct {
+	sync.Mutex
+	txnID     TxnID
+	state     TxnState
+	tcClient  *transactionCoordinatorClient
+	timeoutAt time.Time
+
+	registerLock             sync.Mutex
+	registerPartitions       map[string]bool
+	registerAckSubscriptions map[subscription]bool
+
+	// pendingOps tracks the send and ack operations in progress, they must all
+	// complete before the transaction can be committed
+	pendingOps sync.WaitGroup
+	// opsErr is the first error returned by an operation of the transaction, a transaction
+	// with a failed operation can only be aborted
+	opsErr error
+
+	log log.Logger
+}
+
+func newTransaction(id TxnID, tcClient *transactionCoordinatorClient, timeout time.Duration) *transaction {
+	return &transaction{
+		txnID:                    id,
+		state:                    TxnOpen,
+		tcClient:                 tcClient,
+		timeoutAt:                time.Now().Add(timeout),
+		registerPartitions:       make(map[string]bool),
+		registerAckSubscriptions: make(map[subscription]bool),
+		log:                      tcClient.log.SubLogger(log.Fields{"txnID": id.String()}),
+	}
+}
+
+func (txn *transaction) GetState() TxnState {
+	txn.Lock()
+	defer txn.Unlock()
+	return txn.state
+}
+
+func (txn *transaction) GetTxnID() TxnID {
+	return txn.txnID
+}
+
+func (txn *transaction) Commit(ctx context.Context) error {
+	if err := txn.beginEnd(TxnCommitting); err != nil {
+		return err
+	}
+
+	if err := txn.waitPendingOps(ctx); err != nil {
+		return err
+	}
+
+	txn.Lock()
+	opsErr := txn.opsErr
+	txn.Unlock()
+	if opsErr != nil {
+		// some operation of the transaction failed, committing would lose them so abort instead
+		if err := txn.tcClient.endTxn(&txn.txnID, pb.TxnAction_ABORT); err != nil {
+			txn.setState(TxnError)
+			return err
+		}
+		txn.setState(TxnAborted)
+		return newError(TransactionError,
+			fmt.Sprintf("transaction %s has been aborted because an operation failed: %v", txn.txnID, opsErr))
+	}
+
+	if err := txn.tcClient.endTxn(&txn.txnID, pb.TxnAction_COMMIT); err != nil {
+		txn.setState(TxnError)
+		return err
+	}
+	txn.setState(TxnCommitted)
+	return nil
+}
+
+func (txn *transaction) Abort(ctx context.Context) error {
+	if err := txn.beginEnd(TxnAborting); err != nil {
+		return err
+	}
+
+	if err := txn.waitPendingOps(ctx); err != nil {
+		return err
+	}
+
+	if err := txn.tcClient.endTxn(&txn.txnID, pb.TxnAction_ABORT); err != nil {
+		txn.setState(TxnError)
+		return err
+	}
+	txn.setState(TxnAborted)
+	return nil
+}
+
+// beginEnd moves the transaction to the committing or aborting state, ending an
+// already ending transaction again is allowed so a failed attempt can be retried
+func (txn *transaction) beginEnd(state TxnState) error {
+	txn.Lock()
+	defer txn.Unlock()
+	if txn.state != TxnOpen && txn.state != state {
+		return newError(InvalidStatus, fmt.Sprintf("expect transaction %s state is %s, but actual state is %s",
+			txn.txnID, TxnOpen, txn.state))
+	}
+	txn.state = state
+	return nil
+}
+
+func (txn *transaction) waitPendingOps(ctx context.Context) error {
+	doneCh := make(chan struct{})
+	go func() {
+		txn.pendingOps.Wait()
+		close(doneCh)
+	}()
+
+	select {
+	case <-doneCh:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (txn *transaction) setState(state TxnState) {
+	txn.Lock()
+	defer txn.Unlock()
+	txn.state = state
+}
+
+// registerSendOrAckOp must be called before sending or acknowledging a message with the transaction,
+// it makes sure the transaction is still open and that the commit waits for the operation to complete
+func (txn *transaction) registerSendOrAckOp() error {
+	txn.Lock()
+	defer txn.Unlock()
+	if txn.state == TxnOpen && time.Now().After(txn.timeoutAt) {
+		txn.state = TxnTimeout
+	}
+	if txn.state != TxnOpen {
+		return newError(InvalidStatus, fmt.Sprintf("expect transaction %s state is %s, but actual state is %s",
+			txn.txnID, TxnOpen, txn.state))
+	}
+	txn.pendingOps.Add(1)
+	return nil
+}
+
+// endSendOrAckOp must be called once the operation registered with registerSendOrAckOp completes
+func (txn *transaction) endSendOrAckOp(err error) {
+	if err != nil {
+		txn.Lock()
+		if txn.opsErr == nil {
+			txn.opsErr = err
+		}
+		txn.Unlock()
+		txn.log.WithError(err).Warn("Operation of the transaction failed")
+	}
+	txn.pendingOps.Done()
+}
+
+// registerProducerTopic registers the topic messages are published to with the transaction coordinator
+func (txn *transaction) registerProducerTopic(topic string) error {
+	txn.registerLock.Lock()
+	defer txn.registerLock.Unlock()
+	if txn.registerPartitions[topic] {
+		return nil
+	}
+	if err := txn.tcClient.addPublishPartitionToTxn(&txn.txnID, []string{topic}); err != nil {
+		return err
+	}
+	txn.registerPartitions[topic] = true
+	return nil
+}
+
+// registerAckTopic registers the subscription messages are acknowledged on with the transaction coordinator
+func (txn *transaction) registerAckTopic(topic string, sub string) error {
+	txn.registerLock.Lock()
+	defer txn.registerLock.Unlock()
+	key := subscription{topic: topic, subscription: sub}
+	if txn.registerAckSubscriptions[key] {
+		return nil
+	}
+	if err := txn.tcClient.addSubscriptionToTxn(&txn.txnID, topic, sub); err != nil {
+		return err
+	}
+	txn.registerAckSubscriptions[key] = true
+	return nil
+}
+
+func toTransaction(t Transaction) (*transaction, error) {
+	txn, ok := t.(*transaction)
+	if !ok {
+		return nil, newError(TransactionError, fmt.Sprintf("invalid transaction type %T", t))
+	}
+	return txn, nil
+}
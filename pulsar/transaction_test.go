@@ -18,7 +18,10 @@
 package pulsar
 
 import (
+	"context"
+
 	pb "github.com/apache/pulsar-client-go/pulsar/internal/pulsar_proto"
+	"github.com/apache/pulsar-client-go/pulsar/log"
 	"github.com/stretchr/testify/assert"
 
 	"testing"
@@ -96,3 +99,149 @@ func createTcClient(t *testing.T) (*transactionCoordinatorClient, *client) {
 
 	return tcClient, c.(*client)
 }
+
+func newTestTransaction(timeout time.Duration) *transaction {
+	tc := &transactionCoordinatorClient{log: log.DefaultNopLogger()}
+	return newTransaction(TxnID{MostSigBits: 1, LeastSigBits: 2}, tc, timeout)
+}
+
+func TestTransactionRegisterOp(t *testing.T) {
+	txn := newTestTransaction(time.Minute)
+	assert.Equal(t, TxnOpen, txn.GetState())
+	assert.Equal(t, TxnID{MostSigBits: 1, LeastSigBits: 2}, txn.GetTxnID())
+
+	assert.NoError(t, txn.registerSendOrAckOp())
+	txn.endSendOrAckOp(nil)
+
+	// a transaction being committed can not be used anymore
+	assert.NoError(t, txn.beginEnd(TxnCommitting))
+	err := txn.registerSendOrAckOp()
+	assert.Error(t, err)
+	assert.Equal(t, InvalidStatus, err.(*Error).Result())
+
+	// committing an aborting transaction is not allowed
+	assert.Error(t, txn.beginEnd(TxnAborting))
+}
+
+func TestTransactionTimeout(t *testing.T) {
+	txn := newTestTransaction(-time.Second)
+	assert.Error(t, txn.registerSendOrAckOp())
+	assert.Equal(t, TxnTimeout, txn.GetState())
+}
+
+func TestTransactionWaitPendingOps(t *testing.T) {
+	txn := newTestTransaction(time.Minute)
+	assert.NoError(t, txn.registerSendOrAckOp())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, txn.waitPendingOps(ctx))
+
+	txn.endSendOrAckOp(nil)
+	assert.NoError(t, txn.waitPendingOps(context.Background()))
+}
+
+func TestTxnAckSet(t *testing.T) {
+	assert.Nil(t, txnAckSet(newTrackingMessageID(1, 1, -1, 0, 0, nil), individualAck))
+
+	msgID := newTrackingMessageID(1, 1, 2, 0, 5, nil)
+	// 0b11011 with the batch index 2 cleared
+	assert.Equal(t, []int64{27}, txnAckSet(msgID, individualAck))
+	// 0b11000 with the batch index 0 to 2 cleared
+	assert.Equal(t, []int64{24}, txnAckSet(msgID, cumulativeAck))
+}
+
+func TestTxnPipelineOptions(t *testing.T) {
+	c := &client{}
+	_, err := NewTxnPipeline(c, TxnPipelineOptions{})
+	assert.Error(t, err)
+
+	process := func(ctx context.Context, msg Message) ([]*ProducerMessage, error) {
+		return nil, nil
+	}
+	_, err = NewTxnPipeline(c, TxnPipelineOptions{
+		Consumer:                &consumer{},
+		Producer:                &producer{},
+		Process:                 process,
+		AckCumulative:           true,
+		MaxInFlightTransactions: 2,
+	})
+	assert.Error(t, err)
+
+	p, err := NewTxnPipeline(c, TxnPipelineOptions{
+		Consumer: &consumer{},
+		Producer: &producer{},
+		Process:  process,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, p.(*txnPipeline).options.MaxInFlightTransactions)
+	assert.Equal(t, defaultTxnPipelineTransactionTimeout, p.(*txnPipeline).options.TransactionTimeout)
+}
+
+// interruptedCommitTxn is a transaction whose first commit is interrupted before ending it
+type interruptedCommitTxn struct {
+	Transaction
+	state   TxnState
+	commits int
+	aborts  int
+}
+
+func (txn *interruptedCommitTxn) Commit(context.Context) error {
+	txn.commits++
+	if txn.commits == 1 {
+		txn.state = TxnCommitting
+		return context.Canceled
+	}
+	txn.state = TxnCommitted
+	return nil
+}
+
+func (txn *interruptedCommitTxn) Abort(context.Context) error {
+	txn.aborts++
+	return nil
+}
+
+func (txn *interruptedCommitTxn) GetState() TxnState {
+	return txn.state
+}
+
+type txnPipelineClient struct {
+	Client
+	txn Transaction
+}
+
+func (c *txnPipelineClient) NewTransaction(time.Duration) (Transaction, error) {
+	return c.txn, nil
+}
+
+type txnPipelineConsumer struct {
+	Consumer
+	nacks int
+}
+
+func (c *txnPipelineConsumer) AckWithTxn(Message, Transaction) error {
+	return nil
+}
+
+func (c *txnPipelineConsumer) Nack(Message) {
+	c.nacks++
+}
+
+func TestTxnPipelineInterruptedCommit(t *testing.T) {
+	txn := &interruptedCommitTxn{state: TxnOpen}
+	consumer := &txnPipelineConsumer{}
+	p, err := newTxnPipeline(&txnPipelineClient{txn: txn}, TxnPipelineOptions{
+		Consumer: consumer,
+		Producer: &producer{},
+		Process: func(ctx context.Context, msg Message) ([]*ProducerMessage, error) {
+			return nil, nil
+		},
+	})
+	assert.NoError(t, err)
+
+	// the transaction can't be aborted once committing, the commit is completed
+	p.processMessage(context.Background(), &message{})
+	assert.Equal(t, 2, txn.commits)
+	assert.Equal(t, 0, txn.aborts)
+	assert.Equal(t, 0, consumer.nacks)
+}
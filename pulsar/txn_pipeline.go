@@ -0,0 +1,69 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsar
+
+import (
+	"context"
+	"time"
+)
+
+// TxnPipelineOptions contains the options for creating a TxnPipeline
+type TxnPipelineOptions struct {
+	// Consumer the input messages are received from.
+	// This argument is required when constructing the pipeline.
+	Consumer Consumer
+
+	// Producer the output messages are published with.
+	// This argument is required when constructing the pipeline.
+	Producer Producer
+
+	// Process transforms an input message into the messages to publish on the output topic.
+	// Returning an error aborts the transaction of the input message, which is then negatively acknowledged.
+	// This argument is required when constructing the pipeline.
+	Process func(ctx context.Context, msg Message) ([]*ProducerMessage, error)
+
+	// TransactionTimeout is the timeout of the transaction opened for each input message. Default to 1 minute.
+	TransactionTimeout time.Duration
+
+	// MaxInFlightTransactions is the maximum number of input messages processed concurrently, each one in its
+	// own transaction. Default to 1, which processes the messages sequentially.
+	MaxInFlightTransactions int
+
+	// AckCumulative acknowledges the input messages cumulatively rather than individually. It can only be used
+	// with Exclusive or Failover subscriptions and requires MaxInFlightTransactions to be 1 so transactions never
+	// overlap on the acknowledged range.
+	AckCumulative bool
+
+	// OnError is called with the input message whenever its transaction is aborted.
+	OnError func(msg Message, err error)
+}
+
+// TxnPipeline receives messages, processes them, publishes the results to the output topic and acknowledges
+// the input, all inside one transaction per input message. Either all of them take effect or none, so each
+// input message is processed exactly once.
+type TxnPipeline interface {
+	// Run processes the input messages until the context is done or the consumer is closed.
+	// It waits for the in-flight transactions to complete before returning.
+	Run(ctx context.Context) error
+}
+
+// NewTxnPipeline creates a transactional pipeline, the client must have been created with
+// ClientOptions.EnableTransaction.
+func NewTxnPipeline(client Client, options TxnPipelineOptions) (TxnPipeline, error) {
+	return newTxnPipeline(client, options)
+}
@@ -0,0 +1,166 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pulsar
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const defaultTxnPipelineTransactionTimeout = time.Minute
+
+type txnPipeline struct {
+	client  Client
+	options TxnPipelineOptions
+}
+
+func newTxnPipeline(client Client, options TxnPipelineOptions) (*txnPipeline, error) {
+	if client == nil {
+		return nil, newError(InvalidConfiguration, "client is required")
+	}
+	if options.Consumer == nil {
+		return nil, newError(InvalidConfiguration, "Consumer is required")
+	}
+	if options.Producer == nil {
+		return nil, newError(InvalidConfiguration, "Producer is required")
+	}
+	if options.Process == nil {
+		return nil, newError(InvalidConfiguration, "Process is required")
+	}
+	if options.TransactionTimeout <= 0 {
+		options.TransactionTimeout = defaultTxnPipelineTransactionTimeout
+	}
+	if options.MaxInFlightTransactions <= 0 {
+		options.MaxInFlightTransactions = 1
+	}
+	if options.AckCumulative && options.MaxInFlightTransactions > 1 {
+		return nil, newError(InvalidConfiguration, "AckCumulative requires MaxInFlightTransactions to be 1")
+	}
+
+	return &txnPipeline{
+		client:  client,
+		options: options,
+	}, nil
+}
+
+func (p *txnPipeline) Run(ctx context.Context) error {
+	// the semaphore bounds the number of in-flight transactions
+	sem := make(chan struct{}, p.options.MaxInFlightTransactions)
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	for {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		msg, err := p.options.Consumer.Receive(ctx)
+		if err != nil {
+			<-sem
+			return err
+		}
+
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			p.processMessage(ctx, msg)
+		}()
+	}
+}
+
+func (p *txnPipeline) processMessage(ctx context.Context, msg Message) {
+	txn, err := p.client.NewTransaction(p.options.TransactionTimeout)
+	if err != nil {
+		p.fail(msg, err)
+		return
+	}
+
+	if err = p.processInTxn(ctx, msg, txn); err == nil {
+		err = txn.Commit(ctx)
+	}
+	if err == nil {
+		return
+	}
+
+	// use a fresh context, the transaction must be ended even if the pipeline context is done
+	endCtx, cancel := context.WithTimeout(context.Background(), p.options.TransactionTimeout)
+	defer cancel()
+	switch txn.GetState() {
+	case TxnOpen, TxnAborting:
+		if abortErr := txn.Abort(endCtx); abortErr != nil {
+			err = fmt.Errorf("%w, abort failed: %v", err, abortErr)
+		}
+	case TxnCommitting:
+		// the commit was interrupted before ending the transaction, e.g. by the pipeline context, while the
+		// message was processed, the transaction can't be aborted anymore so the commit is completed
+		commitErr := txn.Commit(endCtx)
+		if commitErr == nil {
+			return
+		}
+		err = fmt.Errorf("%w, commit failed: %v", err, commitErr)
+	}
+	p.fail(msg, err)
+}
+
+func (p *txnPipeline) processInTxn(ctx context.Context, msg Message, txn Transaction) error {
+	outputs, err := p.options.Process(ctx, msg)
+	if err != nil {
+		return err
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var sendErr error
+	wg.Add(len(outputs))
+	for _, out := range outputs {
+		out.Transaction = txn
+		p.options.Producer.SendAsync(ctx, out, func(_ MessageID, _ *ProducerMessage, err error) {
+			if err != nil {
+				mu.Lock()
+				if sendErr == nil {
+					sendErr = err
+				}
+				mu.Unlock()
+			}
+			wg.Done()
+		})
+	}
+	wg.Wait()
+	if sendErr != nil {
+		return sendErr
+	}
+
+	if p.options.AckCumulative {
+		return p.options.Consumer.AckCumulativeWithTxn(msg, txn)
+	}
+	return p.options.Consumer.AckWithTxn(msg, txn)
+}
+
+func (p *txnPipeline) fail(msg Message, err error) {
+	p.options.Consumer.Nack(msg)
+	if p.options.OnError != nil {
+		p.options.OnError(msg, err)
+	}
+}